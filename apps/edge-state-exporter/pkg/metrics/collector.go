@@ -2,27 +2,45 @@ package metrics
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os/exec"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/power-edge/power-edge/apps/edge-state-exporter/pkg/config"
 )
 
+// Content types for the text formats the exporter serves
+const (
+	contentTypeText        = "text/plain; version=0.0.4; charset=utf-8"
+	contentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
 // Collector collects and exposes Prometheus metrics
 type Collector struct {
 	state   *config.State
-	metrics map[string]MetricValue
+	mu      sync.Mutex
+	metrics map[string]MetricValue // Keyed by series: name and labels
 }
 
-// MetricValue represents a single metric
+// MetricValue represents a single sample of a gauge
 type MetricValue struct {
+	Name        string
 	Value       float64
 	Labels      map[string]string
 	Description string
 }
 
+// set records a sample, replacing the previous one of the same series
+func (c *Collector) set(m MetricValue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics[m.Name+formatLabels(m.Labels)] = m
+}
+
 // NewCollector creates a new metrics collector
 func NewCollector(state *config.State) *Collector {
 	return &Collector{
@@ -61,7 +79,8 @@ func (c *Collector) checkServices(services []config.ServiceConfig) error {
 			log.Printf("  ✗ %s: %s (expected: %s)", svc.Name, status, svc.State)
 		}
 
-		c.metrics[fmt.Sprintf("service_compliant{name=%q}", svc.Name)] = MetricValue{
+		c.set(MetricValue{
+			Name:  "edge_state_service_compliant",
 			Value: compliant,
 			Labels: map[string]string{
 				"name":     svc.Name,
@@ -69,7 +88,7 @@ func (c *Collector) checkServices(services []config.ServiceConfig) error {
 				"actual":   status,
 			},
 			Description: "Service compliance (1 = compliant, 0 = non-compliant)",
-		}
+		})
 	}
 
 	return nil
@@ -89,7 +108,8 @@ func (c *Collector) checkSysctl(params map[string]string) error {
 			log.Printf("  ✗ %s: %s (expected: %s)", key, actualValue, expectedValue)
 		}
 
-		c.metrics[fmt.Sprintf("sysctl_compliant{key=%q}", key)] = MetricValue{
+		c.set(MetricValue{
+			Name:  "edge_state_sysctl_compliant",
 			Value: compliant,
 			Labels: map[string]string{
 				"key":      key,
@@ -97,33 +117,99 @@ func (c *Collector) checkSysctl(params map[string]string) error {
 				"actual":   actualValue,
 			},
 			Description: "Sysctl parameter compliance (1 = compliant, 0 = non-compliant)",
-		}
+		})
 	}
 
 	return nil
 }
 
-// Handler returns an HTTP handler for Prometheus metrics
+// Handler returns an HTTP handler for Prometheus metrics. It serves the
+// Prometheus text format, or OpenMetrics when the scraper asks for it.
 func (c *Collector) Handler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
-
-		// Write metrics in Prometheus format
-		for name, metric := range c.metrics {
-			// Write HELP
-			fmt.Fprintf(w, "# HELP %s\n", metric.Description)
-			// Write TYPE
-			fmt.Fprintf(w, "# TYPE edge_state_compliance gauge\n")
-			// Write metric
-			fmt.Fprintf(w, "edge_state_compliance%s %v\n", name, metric.Value)
+		openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+		if openMetrics {
+			w.Header().Set("Content-Type", contentTypeOpenMetrics)
+		} else {
+			w.Header().Set("Content-Type", contentTypeText)
 		}
-
-		// Write metadata
-		fmt.Fprintf(w, "# HELP edge_state_info Edge state information\n")
-		fmt.Fprintf(w, "# TYPE edge_state_info gauge\n")
-		fmt.Fprintf(w, "edge_state_info{site=%q,environment=%q} 1\n",
-			c.state.Metadata.Site,
-			c.state.Metadata.Environment,
-		)
+		c.write(w, openMetrics)
 	})
 }
+
+// write renders every metric family with exactly one HELP and TYPE line
+// ahead of its samples. Families and samples are sorted so scrapes are stable.
+func (c *Collector) write(w io.Writer, openMetrics bool) {
+	c.mu.Lock()
+	families := make(map[string][]MetricValue)
+	for _, m := range c.metrics {
+		families[m.Name] = append(families[m.Name], m)
+	}
+	c.mu.Unlock()
+
+	families["edge_state_info"] = []MetricValue{{
+		Name:  "edge_state_info",
+		Value: 1,
+		Labels: map[string]string{
+			"site":        c.state.Metadata.Site,
+			"environment": c.state.Metadata.Environment,
+		},
+		Description: "Edge state information",
+	}}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		samples := families[name]
+		sort.Slice(samples, func(i, j int) bool {
+			return formatLabels(samples[i].Labels) < formatLabels(samples[j].Labels)
+		})
+
+		fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(samples[0].Description, openMetrics))
+		fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+		for _, m := range samples {
+			fmt.Fprintf(w, "%s%s %v\n", name, formatLabels(m.Labels), m.Value)
+		}
+	}
+
+	if openMetrics {
+		fmt.Fprint(w, "# EOF\n")
+	}
+}
+
+// formatLabels renders labels as {k="v",...} sorted by name, with values
+// escaped per the text format ("" when there are none)
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, labelValueEscaper.Replace(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+// escapeHelp escapes HELP text; OpenMetrics also escapes quotes there
+func escapeHelp(help string, openMetrics bool) string {
+	if openMetrics {
+		return labelValueEscaper.Replace(help)
+	}
+	return helpEscaper.Replace(help)
+}
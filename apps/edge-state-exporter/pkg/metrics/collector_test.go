@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/power-edge/power-edge/apps/edge-state-exporter/pkg/config"
+)
+
+func testCollector() *Collector {
+	c := NewCollector(&config.State{})
+	c.state.Metadata.Site = `lab "north"`
+	for _, name := range []string{"ssh", "docker"} {
+		c.set(MetricValue{
+			Name:        "edge_state_service_compliant",
+			Value:       1,
+			Labels:      map[string]string{"name": name, "expected": "running", "actual": "active"},
+			Description: "Service compliance (1 = compliant, 0 = non-compliant)",
+		})
+	}
+	c.set(MetricValue{
+		Name:        "edge_state_sysctl_compliant",
+		Labels:      map[string]string{"key": "net.ipv4.tcp_rmem", "expected": "4096\t87380\t6291456", "actual": "a\\b\nc"},
+		Description: "Sysctl parameter compliance (1 = compliant, 0 = non-compliant)",
+	})
+	return c
+}
+
+func TestCollector_HandlerParses(t *testing.T) {
+	rec := httptest.NewRecorder()
+	testCollector().Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("output doesn't parse: %v\n%s", err, body)
+	}
+
+	want := map[string]int{
+		"edge_state_info":              1,
+		"edge_state_service_compliant": 2,
+		"edge_state_sysctl_compliant":  1,
+	}
+	for name, samples := range want {
+		family, ok := families[name]
+		if !ok {
+			t.Errorf("missing family %s", name)
+			continue
+		}
+		if got := len(family.GetMetric()); got != samples {
+			t.Errorf("%s has %d samples, want %d", name, got, samples)
+		}
+	}
+
+	// Escaped label values survive the round trip
+	for _, label := range families["edge_state_sysctl_compliant"].GetMetric()[0].GetLabel() {
+		if label.GetName() == "actual" && label.GetValue() != "a\\b\nc" {
+			t.Errorf("actual = %q, want %q", label.GetValue(), "a\\b\nc")
+		}
+	}
+	if n := strings.Count(body, "# TYPE edge_state_service_compliant "); n != 1 {
+		t.Errorf("TYPE line for edge_state_service_compliant appears %d times, want 1", n)
+	}
+}
+
+func TestCollector_HandlerOpenMetrics(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rec := httptest.NewRecorder()
+	testCollector().Handler().ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Errorf("Content-Type = %q, want OpenMetrics", ct)
+	}
+	if !strings.HasSuffix(rec.Body.String(), "# EOF\n") {
+		t.Errorf("OpenMetrics output must end with # EOF:\n%s", rec.Body.String())
+	}
+}
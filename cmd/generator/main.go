@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"go/format"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"text/template"
 	"unicode"
@@ -41,6 +43,7 @@ type Property struct {
 	Maximum     *int                   `yaml:"maximum"`
 	MinLength   *int                   `yaml:"minLength"`
 	MaxLength   *int                   `yaml:"maxLength"`
+	Examples    []interface{}          `yaml:"examples"`
 
 	// Code generation directives
 	XGenerateStruct string                 `yaml:"x-generate-struct"`
@@ -63,6 +66,10 @@ type GeneratedType struct {
 	Fields      []Field
 	EnumValues  []string
 	Description string
+
+	// Validation code for structs, filled in by addValidations
+	Patterns    []PatternVar
+	Validations []string
 }
 
 // Field represents a struct field
@@ -73,6 +80,8 @@ type Field struct {
 	YAMLTag     string
 	Description string
 	Validations []string
+	Required    bool     // Listed in the parent schema's required array
+	Prop        Property // Schema property, with $ref constraints resolved
 }
 
 func main() {
@@ -106,8 +115,15 @@ func main() {
 		schemas[basename] = &schema
 	}
 
+	// Resolve $refs, including those into other schema files
+	buildSymbols(schemas)
+	if err := resolveRefs(schemas); err != nil {
+		log.Fatalf("Failed to resolve references: %v", err)
+	}
+
 	// Generate types from schemas
 	types := extractTypes(schemas)
+	cases := addValidations(types)
 
 	// Generate Go code
 	if err := os.MkdirAll(*outputDir, 0755); err != nil {
@@ -118,6 +134,10 @@ func main() {
 		log.Fatalf("Failed to generate code: %v", err)
 	}
 
+	if err := generateTests(cases, filepath.Join(*outputDir, "generated_test.go")); err != nil {
+		log.Fatalf("Failed to generate tests: %v", err)
+	}
+
 	log.Printf("✅ Successfully generated %d types", len(types))
 }
 
@@ -125,11 +145,14 @@ func extractTypes(schemas map[string]*Schema) []GeneratedType {
 	var types []GeneratedType
 	seen := make(map[string]bool) // Track seen type names to avoid duplicates
 
-	for schemaName, schema := range schemas {
+	// Maps are walked in key order so the output is the same on every run
+	for _, schemaName := range slices.Sorted(maps.Keys(schemas)) {
+		schema := schemas[schemaName]
 		log.Printf("Extracting types from: %s", schemaName)
 
 		// Process definitions
-		for defName, defProp := range schema.Definitions {
+		for _, defName := range slices.Sorted(maps.Keys(schema.Definitions)) {
+			defProp := schema.Definitions[defName]
 			if defProp.XGenerateEnum != "" {
 				if !seen[defProp.XGenerateEnum] {
 					types = append(types, GeneratedType{
@@ -201,7 +224,8 @@ func extractTypes(schemas map[string]*Schema) []GeneratedType {
 func extractStruct(name string, properties map[string]Property, required []string, description string) GeneratedType {
 	var fields []Field
 
-	for propName, prop := range properties {
+	for _, propName := range slices.Sorted(maps.Keys(properties)) {
+		prop := properties[propName]
 		fieldName := prop.XGenerateField
 		if fieldName == "" {
 			fieldName = toGoName(propName)
@@ -213,6 +237,8 @@ func extractStruct(name string, properties map[string]Property, required []strin
 			JSONTag:     propName,
 			YAMLTag:     propName,
 			Description: prop.Description,
+			Required:    contains(required, propName),
+			Prop:        resolveConstraints(prop),
 		})
 	}
 
@@ -227,7 +253,8 @@ func extractStruct(name string, properties map[string]Property, required []strin
 func extractNestedStructs(properties map[string]Property, required []string) []GeneratedType {
 	var types []GeneratedType
 
-	for _, prop := range properties {
+	for _, propName := range slices.Sorted(maps.Keys(properties)) {
+		prop := properties[propName]
 		if prop.XGenerateStruct != "" {
 			types = append(types, extractStruct(prop.XGenerateStruct, prop.Properties, prop.Required, prop.Description))
 			// Recursively extract nested structs
@@ -281,11 +308,9 @@ func inferGoType(prop Property) string {
 		return prop.XGenerateMap
 	}
 
-	// Handle $ref
+	// Handle $ref (already resolved to a symbol table key)
 	if prop.Ref != "" {
-		parts := strings.Split(prop.Ref, "/")
-		refName := parts[len(parts)-1]
-		return toGoName(refName)
+		return refGoType(prop.Ref)
 	}
 
 	return mapSchemaTypeToGo(prop)
@@ -353,6 +378,7 @@ func toPascalCase(s string) string {
 func generateGoCode(types []GeneratedType, outputFile string) error {
 	tmpl := template.Must(template.New("go").Funcs(template.FuncMap{
 		"quote":   func(s string) string { return fmt.Sprintf("%q", s) },
+		"raw":     rawString,
 		"goIdent": toGoName,
 		"formatDoc": formatDocComment,
 	}).Parse(goTemplate))
@@ -401,6 +427,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"reflect"
+	"regexp"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -414,11 +443,42 @@ const (
 {{$typeName := .Name}}{{range $i, $val := .EnumValues}}	{{$typeName}}{{$val | goIdent}} {{$typeName}} = {{$val | quote}}
 {{end}})
 
+// Valid reports whether v is one of the defined {{.Name}} values
+func (v {{.Name}}) Valid() bool {
+	switch v {
+	case {{range $i, $val := .EnumValues}}{{if $i}}, {{end}}{{$typeName}}{{$val | goIdent}}{{end}}:
+		return true
+	}
+	return false
+}
+
 {{else if .IsStruct}}
 {{formatDoc .Name .Description}}
 type {{.Name}} struct {
-{{range .Fields}}	{{.Name}} {{.GoType}} ` + "`json:\"{{.JSONTag}}\" yaml:\"{{.YAMLTag}}\"`" + ` // {{.Description}}
+{{range .Fields}}	{{.Name}} {{.GoType}} ` + "`json:\"{{.JSONTag}}{{if not .Required}},omitempty{{end}}\" yaml:\"{{.YAMLTag}}{{if not .Required}},omitempty{{end}}\"`" + ` // {{.Description}}
 {{end}}}
+{{if .Patterns}}
+var (
+{{range .Patterns}}	{{.Name}} = regexp.MustCompile({{raw .Expr}})
+{{end}})
+{{end}}
+// Validate checks {{.Name}} against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *{{.Name}}) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *{{.Name}}) validate(prefix string, errs *ValidationErrors) {
+{{range .Validations}}{{.}}
+{{end}}	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
 
 {{else}}
 {{formatDoc .Name .Description}}
@@ -434,12 +494,7 @@ func LoadStateConfig(path string) (*State, error) {
 		return nil, fmt.Errorf("read file: %w", err)
 	}
 
-	var config State
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("parse yaml: %w", err)
-	}
-
-	return &config, nil
+	return ParseState(data)
 }
 
 // LoadWatcherConfig loads watcher configuration from YAML file
@@ -456,4 +511,35 @@ func LoadWatcherConfig(path string) (*WatcherConfig, error) {
 
 	return &config, nil
 }
+
+// FieldError describes a single field that violates the schema
+type FieldError struct {
+	Field   string ` + "`json:\"field\"`" + `   // Path to the field, e.g. services[0].state
+	Message string ` + "`json:\"message\"`" + ` // What is wrong with it
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors collects every field error found by Validate
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return fmt.Sprintf("validation failed: %s", strings.Join(msgs, "; "))
+}
+
+func (e *ValidationErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// extraValidator is implemented (outside generated code) by types that need
+// checks the schema can't express; their generated validate calls it
+type extraValidator interface {
+	validateExtra(prefix string, errs *ValidationErrors)
+}
 `
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// symbol is a definition that $refs can point at
+type symbol struct {
+	Name string   // Definition name, e.g. unix_path
+	Def  Property // The definition itself
+}
+
+// symbols is the global symbol table of every schema's definitions, keyed by
+// canonical ref: "<schema file>#/definitions/<name>"
+var symbols = make(map[string]symbol)
+
+// buildSymbols indexes the definitions of all schemas, keyed by file name
+func buildSymbols(schemas map[string]*Schema) {
+	for file, schema := range schemas {
+		for name, def := range schema.Definitions {
+			symbols[file+"#/definitions/"+name] = symbol{Name: name, Def: def}
+		}
+	}
+}
+
+// resolveRefs rewrites every $ref in schemas to its canonical symbol table
+// key, so later passes don't need to know which file a property came from.
+// Refs may be local (#/definitions/x), name another schema file
+// (common.schema.yaml#/definitions/x) or use its $id.
+func resolveRefs(schemas map[string]*Schema) error {
+	ids := make(map[string]string, len(schemas))
+	for file, schema := range schemas {
+		if schema.ID != "" {
+			ids[schema.ID] = file
+		}
+	}
+
+	for file, schema := range schemas {
+		r := refResolver{file: file, ids: ids}
+		if err := r.properties(schema.Properties); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if err := r.properties(schema.Definitions); err != nil {
+			return fmt.Errorf("%s: definitions: %w", file, err)
+		}
+	}
+
+	// Definitions were rewritten in place; refresh the table's copies
+	for file, schema := range schemas {
+		for name, def := range schema.Definitions {
+			symbols[file+"#/definitions/"+name] = symbol{Name: name, Def: def}
+		}
+	}
+	return nil
+}
+
+type refResolver struct {
+	file string            // Schema file being resolved
+	ids  map[string]string // $id -> schema file
+}
+
+func (r refResolver) properties(props map[string]Property) error {
+	for name, prop := range props {
+		if err := r.property(&prop); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		props[name] = prop
+	}
+	return nil
+}
+
+func (r refResolver) property(prop *Property) error {
+	if prop.Ref != "" {
+		key, err := r.canonical(prop.Ref)
+		if err != nil {
+			return err
+		}
+		prop.Ref = key
+	}
+	if prop.Items != nil {
+		if err := r.property(prop.Items); err != nil {
+			return fmt.Errorf("items: %w", err)
+		}
+	}
+	return r.properties(prop.Properties)
+}
+
+// canonical returns the symbol table key for ref
+func (r refResolver) canonical(ref string) (string, error) {
+	doc, pointer, ok := strings.Cut(ref, "#")
+	if !ok || !strings.HasPrefix(pointer, "/definitions/") || strings.Count(pointer, "/") != 2 {
+		return "", fmt.Errorf("unsupported $ref %q: must point at #/definitions/<name>", ref)
+	}
+
+	file := r.file
+	if doc != "" {
+		if f, ok := r.ids[doc]; ok {
+			file = f
+		} else {
+			file = path.Base(doc)
+		}
+	}
+
+	key := file + "#" + pointer
+	if _, ok := symbols[key]; !ok {
+		return "", fmt.Errorf("unresolved $ref %q: no definition %s", ref, key)
+	}
+	return key, nil
+}
+
+// refGoType returns the Go type a resolved $ref generates
+func refGoType(ref string) string {
+	sym := symbols[ref]
+	switch def := sym.Def; {
+	case def.XGenerateEnum != "":
+		return def.XGenerateEnum
+	case def.XGenerateType != "":
+		return def.XGenerateType
+	case def.XGenerateStruct != "":
+		return def.XGenerateStruct
+	case def.XGenerateConst:
+		return toGoName(sym.Name)
+	default:
+		// No named type is generated; inline the definition's type
+		return inferGoType(def)
+	}
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// PatternVar is a package-level compiled regexp used by generated validation
+type PatternVar struct {
+	Name string
+	Expr string
+}
+
+// TestCase is one generated Validate test
+type TestCase struct {
+	Name    string
+	Literal string
+	WantErr bool
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// rawString renders s as a Go raw string literal where possible
+func rawString(s string) string {
+	if strings.Contains(s, "`") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// resolveConstraints copies constraints from a $ref'd definition onto prop,
+// keeping any the property sets itself
+func resolveConstraints(prop Property) Property {
+	if prop.Ref != "" {
+		if sym, ok := symbols[prop.Ref]; ok {
+			def := sym.Def
+			if prop.Pattern == "" {
+				prop.Pattern = def.Pattern
+			}
+			if prop.Minimum == nil {
+				prop.Minimum = def.Minimum
+			}
+			if prop.Maximum == nil {
+				prop.Maximum = def.Maximum
+			}
+			if prop.MinLength == nil {
+				prop.MinLength = def.MinLength
+			}
+			if prop.MaxLength == nil {
+				prop.MaxLength = def.MaxLength
+			}
+			if len(prop.Enum) == 0 {
+				prop.Enum = def.Enum
+			}
+			if len(prop.Examples) == 0 {
+				prop.Examples = def.Examples
+			}
+		}
+	}
+	if prop.Items != nil {
+		items := resolveConstraints(*prop.Items)
+		prop.Items = &items
+	}
+	return prop
+}
+
+// typeKind classifies a Go type name for validation purposes
+type typeKind int
+
+const (
+	kindOther typeKind = iota
+	kindString
+	kindEnum
+	kindInt
+	kindFloat
+	kindBool
+	kindStruct
+	kindSlice
+	kindMap
+)
+
+func kindOf(goType string, index map[string]GeneratedType) typeKind {
+	switch {
+	case strings.HasPrefix(goType, "[]"):
+		return kindSlice
+	case strings.HasPrefix(goType, "map["):
+		return kindMap
+	case goType == "string":
+		return kindString
+	case goType == "int":
+		return kindInt
+	case goType == "float64":
+		return kindFloat
+	case goType == "bool":
+		return kindBool
+	}
+	t, ok := index[goType]
+	switch {
+	case !ok:
+		return kindOther
+	case t.IsEnum:
+		return kindEnum
+	case t.IsStruct:
+		return kindStruct
+	default:
+		return kindOf(t.GoType, index)
+	}
+}
+
+// addValidations fills in the validation code of every struct type and
+// returns test cases exercising it
+func addValidations(types []GeneratedType) []TestCase {
+	index := make(map[string]GeneratedType, len(types))
+	for _, t := range types {
+		index[t.Name] = t
+	}
+
+	var cases []TestCase
+	for i := range types {
+		t := &types[i]
+		if !t.IsStruct {
+			continue
+		}
+		for _, f := range t.Fields {
+			t.Validations = append(t.Validations, fieldValidations(t, f, index)...)
+		}
+
+		valid, ok := sampleStruct(t.Name, index, 0)
+		if ok {
+			cases = append(cases, TestCase{Name: t.Name + "/valid", Literal: valid})
+		}
+		if invalid, ok := invalidStruct(*t, index); ok {
+			cases = append(cases, TestCase{Name: t.Name + "/invalid", Literal: invalid, WantErr: true})
+		}
+	}
+
+	sort.Slice(cases, func(i, j int) bool { return cases[i].Name < cases[j].Name })
+	return cases
+}
+
+func hasRequired(t GeneratedType) bool {
+	for _, f := range t.Fields {
+		if f.Required {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldValidations returns the Go statements validating one struct field
+func fieldValidations(t *GeneratedType, f Field, index map[string]GeneratedType) []string {
+	var lines []string
+	field := "x." + f.Name
+	path := fmt.Sprintf("prefix+%q", f.JSONTag)
+	prop := f.Prop
+
+	switch kindOf(f.GoType, index) {
+	case kindString, kindEnum:
+		if f.Required {
+			lines = append(lines, fmt.Sprintf("\tif %s == \"\" {\n\t\terrs.add(%s, \"is required\")\n\t}", field, path))
+		}
+		lines = append(lines, stringChecks(t, f.Name, field, path, prop, kindOf(f.GoType, index) == kindEnum, true)...)
+
+	case kindInt, kindFloat:
+		verb := "%d"
+		if kindOf(f.GoType, index) == kindFloat {
+			verb = "%v"
+		}
+		guard := field + " != 0 && "
+		if f.Required {
+			guard = ""
+		}
+		if prop.Minimum != nil {
+			lines = append(lines, fmt.Sprintf("\tif %s%s < %d {\n\t\terrs.add(%s, \"must be at least %d, got %s\", %s)\n\t}", guard, field, *prop.Minimum, path, *prop.Minimum, verb, field))
+		}
+		if prop.Maximum != nil {
+			lines = append(lines, fmt.Sprintf("\tif %s > %d {\n\t\terrs.add(%s, \"must be at most %d, got %s\", %s)\n\t}", field, *prop.Maximum, path, *prop.Maximum, verb, field))
+		}
+
+	case kindStruct:
+		// An omitted optional object must not fail on its own required fields
+		if !f.Required && hasRequired(index[f.GoType]) {
+			lines = append(lines, fmt.Sprintf("\tif !reflect.ValueOf(%s).IsZero() {\n\t\t%s.validate(prefix+%q, errs)\n\t}", field, field, f.JSONTag+"."))
+		} else {
+			lines = append(lines, fmt.Sprintf("\t%s.validate(prefix+%q, errs)", field, f.JSONTag+"."))
+		}
+
+	case kindMap:
+		if f.Required {
+			lines = append(lines, fmt.Sprintf("\tif len(%s) == 0 {\n\t\terrs.add(%s, \"is required\")\n\t}", field, path))
+		}
+
+	case kindSlice:
+		if f.Required {
+			lines = append(lines, fmt.Sprintf("\tif len(%s) == 0 {\n\t\terrs.add(%s, \"is required\")\n\t}", field, path))
+		}
+		elem := strings.TrimPrefix(f.GoType, "[]")
+		itemPath := fmt.Sprintf("fmt.Sprintf(\"%%s%s[%%d]\", prefix, i)", f.JSONTag)
+		elemPath := fmt.Sprintf("fmt.Sprintf(\"%%s%s[%%d].\", prefix, i)", f.JSONTag)
+		switch kind := kindOf(elem, index); kind {
+		case kindStruct:
+			lines = append(lines, fmt.Sprintf("\tfor i := range %s {\n\t\t%s[i].validate(%s, errs)\n\t}", field, field, elemPath))
+		case kindString, kindEnum:
+			var items Property
+			if prop.Items != nil {
+				items = *prop.Items
+			}
+			// Items are always checked: an empty element is not "absent"
+			checks := stringChecks(t, f.Name+"Item", "v", itemPath, items, kind == kindEnum, false)
+			if len(checks) > 0 {
+				for j, c := range checks {
+					checks[j] = strings.ReplaceAll(c, "\n", "\n\t")
+				}
+				lines = append(lines, fmt.Sprintf("\tfor i, v := range %s {\n\t%s\n\t}", field, strings.Join(checks, "\n\t")))
+			}
+		}
+	}
+
+	return lines
+}
+
+// stringChecks validates enum membership, pattern and length of a string
+// value. With skipEmpty, an empty value is treated as absent.
+func stringChecks(t *GeneratedType, name, value, path string, prop Property, isEnum, skipEmpty bool) []string {
+	var lines []string
+	guard := ""
+	if skipEmpty {
+		guard = value + " != \"\" && "
+	}
+
+	if isEnum {
+		lines = append(lines, fmt.Sprintf("\tif %s!%s.Valid() {\n\t\terrs.add(%s, \"must be one of %s, got %%q\", %s)\n\t}", guard, value, path, strings.Join(prop.Enum, ", "), value))
+	} else if len(prop.Enum) > 0 {
+		values := make([]string, 0, len(prop.Enum)+1)
+		if skipEmpty {
+			values = append(values, `""`)
+		}
+		for _, v := range prop.Enum {
+			values = append(values, strconv.Quote(v))
+		}
+		lines = append(lines, fmt.Sprintf("\tswitch %s {\n\tcase %s:\n\tdefault:\n\t\terrs.add(%s, \"must be one of %s, got %%q\", %s)\n\t}", value, strings.Join(values, ", "), path, strings.Join(prop.Enum, ", "), value))
+	}
+
+	if prop.Pattern != "" {
+		varName := "pattern" + t.Name + name
+		t.Patterns = append(t.Patterns, PatternVar{Name: varName, Expr: prop.Pattern})
+		lines = append(lines, fmt.Sprintf("\tif %s!%s.MatchString(string(%s)) {\n\t\terrs.add(%s, \"must match %%s, got %%q\", %s, %s)\n\t}", guard, varName, value, path, varName, value))
+	}
+	if prop.MinLength != nil {
+		lines = append(lines, fmt.Sprintf("\tif %slen(%s) < %d {\n\t\terrs.add(%s, \"must be at least %d characters\")\n\t}", guard, value, *prop.MinLength, path, *prop.MinLength))
+	}
+	if prop.MaxLength != nil {
+		lines = append(lines, fmt.Sprintf("\tif len(%s) > %d {\n\t\terrs.add(%s, \"must be at most %d characters\")\n\t}", value, *prop.MaxLength, path, *prop.MaxLength))
+	}
+
+	return lines
+}
+
+// sampleStrings are tried, in order, when a required string has no example
+var sampleStrings = []string{"example", "/example", "1.0", "example.service"}
+
+// sampleString picks a value satisfying prop's constraints
+func sampleString(prop Property) (string, bool) {
+	var candidates []string
+	for _, ex := range prop.Examples {
+		if s, ok := ex.(string); ok {
+			candidates = append(candidates, s)
+		}
+	}
+	if s, ok := prop.Default.(string); ok {
+		candidates = append(candidates, s)
+	}
+	candidates = append(candidates, prop.Enum...)
+	if len(prop.Enum) == 0 {
+		candidates = append(candidates, sampleStrings...)
+	}
+
+	for _, c := range candidates {
+		if prop.Pattern != "" && !regexp.MustCompile(prop.Pattern).MatchString(c) {
+			continue
+		}
+		if prop.MinLength != nil && len(c) < *prop.MinLength {
+			continue
+		}
+		if prop.MaxLength != nil && len(c) > *prop.MaxLength {
+			continue
+		}
+		return c, true
+	}
+	return "", false
+}
+
+// sampleValue returns a Go literal for a valid value of a required field
+func sampleValue(goType string, prop Property, index map[string]GeneratedType, depth int) (string, bool) {
+	switch kindOf(goType, index) {
+	case kindString, kindEnum:
+		if kindOf(goType, index) == kindEnum && len(prop.Enum) == 0 {
+			prop.Enum = index[goType].EnumValues
+		}
+		s, ok := sampleString(prop)
+		return strconv.Quote(s), ok
+	case kindInt, kindFloat:
+		n := 1
+		if prop.Minimum != nil {
+			n = *prop.Minimum
+		}
+		if prop.Maximum != nil && n > *prop.Maximum {
+			return "", false
+		}
+		return strconv.Itoa(n), true
+	case kindStruct:
+		return sampleStruct(goType, index, depth+1)
+	case kindMap:
+		return goType + `{"example": "example"}`, true
+	case kindSlice:
+		var items Property
+		if prop.Items != nil {
+			items = *prop.Items
+		}
+		elemType := strings.TrimPrefix(goType, "[]")
+		elem, ok := sampleValue(elemType, items, index, depth)
+		// Element types are implied inside a slice literal
+		return goType + "{" + strings.TrimPrefix(elem, elemType) + "}", ok
+	}
+	return "", false
+}
+
+// sampleStruct returns a literal of typeName with every required field set
+func sampleStruct(typeName string, index map[string]GeneratedType, depth int) (string, bool) {
+	if depth > 5 {
+		return "", false
+	}
+	var fields []string
+	for _, f := range index[typeName].Fields {
+		if !f.Required || kindOf(f.GoType, index) == kindBool {
+			continue
+		}
+		v, ok := sampleValue(f.GoType, f.Prop, index, depth)
+		if !ok {
+			return "", false
+		}
+		fields = append(fields, f.Name+": "+v)
+	}
+	return typeName + "{" + strings.Join(fields, ", ") + "}", true
+}
+
+// invalidStruct returns a literal of t that violates at least one constraint
+func invalidStruct(t GeneratedType, index map[string]GeneratedType) (string, bool) {
+	// The zero value fails any required field whose absence is detectable
+	for _, f := range t.Fields {
+		if !f.Required {
+			continue
+		}
+		switch kindOf(f.GoType, index) {
+		case kindString, kindEnum, kindSlice, kindMap:
+			return t.Name + "{}", true
+		case kindInt, kindFloat:
+			if f.Prop.Minimum != nil && *f.Prop.Minimum > 0 {
+				return t.Name + "{}", true
+			}
+		}
+	}
+
+	// Otherwise start from a valid instance and break one field
+	valid, ok := sampleStruct(t.Name, index, 0)
+	if !ok {
+		return "", false
+	}
+	for _, f := range t.Fields {
+		if f.Required {
+			continue
+		}
+		bad, ok := violatingValue(f, index)
+		if !ok {
+			continue
+		}
+		sep := ", "
+		if strings.HasSuffix(valid, "{}") {
+			sep = ""
+		}
+		return strings.TrimSuffix(valid, "}") + sep + f.Name + ": " + bad + "}", true
+	}
+	return "", false
+}
+
+// violatingValue returns a literal that fails the field's constraints
+func violatingValue(f Field, index map[string]GeneratedType) (string, bool) {
+	prop := f.Prop
+	switch kindOf(f.GoType, index) {
+	case kindEnum:
+		return `"invalid"`, true
+	case kindString:
+		if len(prop.Enum) > 0 {
+			return `"invalid"`, true
+		}
+		if prop.Pattern != "" {
+			re := regexp.MustCompile(prop.Pattern)
+			for _, c := range []string{"\x00", "invalid value"} {
+				if !re.MatchString(c) {
+					return strconv.Quote(c), true
+				}
+			}
+		}
+	case kindInt, kindFloat:
+		if prop.Maximum != nil {
+			return strconv.Itoa(*prop.Maximum + 1), true
+		}
+		if prop.Minimum != nil && *prop.Minimum-1 != 0 {
+			return strconv.Itoa(*prop.Minimum - 1), true
+		}
+	}
+	return "", false
+}
+
+func generateTests(cases []TestCase, outputFile string) error {
+	tmpl := template.Must(template.New("test").Funcs(template.FuncMap{
+		"quote": strconv.Quote,
+	}).Parse(testTemplate))
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, map[string]interface{}{
+		"Cases": cases,
+	}); err != nil {
+		return fmt.Errorf("execute template: %w", err)
+	}
+
+	formatted, err := format.Source([]byte(buf.String()))
+	if err != nil {
+		log.Printf("Warning: gofmt failed: %v", err)
+		formatted = []byte(buf.String())
+	}
+
+	if err := os.WriteFile(outputFile, formatted, 0644); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	return nil
+}
+
+const testTemplate = `// Code generated by schema generator. DO NOT EDIT.
+
+package config
+
+import "testing"
+
+func TestGeneratedValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{ Validate() error }
+		wantErr bool
+	}{
+{{range .Cases}}		{ {{quote .Name}}, &{{.Literal}}, {{.WantErr}} },
+{{end}}	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.value.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+`
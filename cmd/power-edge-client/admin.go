@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/power-edge/power-edge/pkg/reconciler"
+)
+
+// loadAdminToken reads the bearer token guarding the /admin endpoints.
+// Without a token file the endpoints are not served at all.
+func loadAdminToken(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	token, err := readSecretFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read admin token: %w", err)
+	}
+	return token, nil
+}
+
+// readSecretFile returns the trimmed contents of a file holding a token,
+// failing if it is empty
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return secret, nil
+}
+
+// requireToken rejects requests that don't carry token as a bearer token
+func requireToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="power-edge"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// adminModeHandler switches the reconcile mode at runtime:
+// POST {"mode": "enforce"} answers with the previous and new mode. The change
+// lasts until the next restart, or a SIGHUP that finds the watcher config's
+// reconcile setting changed.
+func adminModeHandler(recon *reconciler.Reconciler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			Mode string `json:"mode"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		// An empty mode would parse as disabled; make turning enforcement off explicit
+		if req.Mode == "" {
+			http.Error(w, "missing mode (want disabled, dry-run or enforce)", http.StatusBadRequest)
+			return
+		}
+		mode, err := parseReconcileMode(req.Mode)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("⚙️  Reconcile mode change to %s requested via /admin/mode from %s", mode, r.RemoteAddr)
+		previous := recon.GetMode()
+		recon.SetMode(mode)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"mode":     string(mode),
+			"previous": string(previous),
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/power-edge/power-edge/pkg/config"
+	"github.com/power-edge/power-edge/pkg/logging"
+	"github.com/power-edge/power-edge/pkg/reconciler"
+)
+
+// Exit codes for the diff subcommand, modelled on `diff` / `terraform plan -detailed-exitcode`
+const (
+	diffExitNoChanges = 0
+	diffExitError     = 1
+	diffExitChanges   = 2
+)
+
+// runDiff compares a candidate state file against the node's actual state and
+// prints the changes enforcing it would make. Nothing is modified and no server
+// is contacted, so it can run in CI as a pre-merge check for GitOps changes.
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Show reconciler logs")
+	var logOpts logging.Options
+	logOpts.AddFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: power-edge-client diff [-v] <candidate-state.yaml>\n\n")
+		fmt.Fprintf(fs.Output(), "Shows what would change on this node if the candidate state were enforced.\n")
+		fmt.Fprintf(fs.Output(), "Exit status is 0 if compliant, 2 if changes are pending and 1 on error.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return diffExitError
+	}
+	path := fs.Arg(0)
+
+	state, err := config.LoadStateConfig(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to load candidate state: %v\n", err)
+		return diffExitError
+	}
+
+	if err := setupSubcommandLogging(logOpts, *verbose); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return diffExitError
+	}
+
+	recon := reconciler.NewReconciler(reconciler.ModeDryRun)
+	results, err := recon.ReconcileAll(context.Background(), state)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to compute plan: %v\n", err)
+		return diffExitError
+	}
+
+	return printDiff(os.Stdout, path, results)
+}
+
+// printDiff writes the plan for results and returns the diff exit code
+func printDiff(w io.Writer, path string, results []reconciler.ReconcileResult) int {
+	changes, failures := 0, 0
+
+	fmt.Fprintf(w, "Plan for %s against %s:\n\n", path, getHostname())
+	for _, result := range results {
+		switch {
+		case result.Error != nil:
+			failures++
+			fmt.Fprintf(w, "  ! %s/%s: %v\n", result.ResourceType, result.ResourceName, result.Error)
+		case !result.WasCompliant:
+			changes++
+			fmt.Fprintf(w, "  ~ %s/%s: %s\n", result.ResourceType, result.ResourceName, result.Action)
+			for _, line := range strings.Split(strings.TrimSuffix(result.Diff, "\n"), "\n") {
+				if line != "" {
+					fmt.Fprintf(w, "      %s\n", line)
+				}
+			}
+		}
+	}
+
+	if changes == 0 && failures == 0 {
+		fmt.Fprintf(w, "  No changes. %d resources compliant.\n", len(results))
+		return diffExitNoChanges
+	}
+
+	fmt.Fprintf(w, "\n%d to change, %d unchanged, %d failed to evaluate.\n",
+		changes, len(results)-changes-failures, failures)
+
+	if failures > 0 {
+		return diffExitError
+	}
+	return diffExitChanges
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/power-edge/power-edge/pkg/config"
+	"github.com/power-edge/power-edge/pkg/gitops"
+)
+
+// gitopsFlags configures pulling state from a Git repository
+type gitopsFlags struct {
+	repo            string
+	branch          string
+	statePath       string
+	interval        time.Duration
+	verifySignature bool
+	allowedSigners  string
+	signersKeyring  string
+	sshKey          string
+	knownHosts      string
+	tokenFile       string
+	tokenUsername   string
+}
+
+func (f *gitopsFlags) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&f.repo, "gitops-repo", "", "Git repository to pull state from (unset disables GitOps sync)")
+	fs.StringVar(&f.branch, "gitops-branch", "main", "Branch of -gitops-repo to follow")
+	fs.StringVar(&f.statePath, "gitops-state-path", "state.yaml", "Path of the state file within -gitops-repo")
+	fs.DurationVar(&f.interval, "gitops-interval", 30*time.Second, "Interval between pulls of -gitops-repo")
+	fs.BoolVar(&f.verifySignature, "gitops-verify-signature", false, "Only apply state from commits GPG-signed by a trusted key")
+	fs.StringVar(&f.allowedSigners, "gitops-allowed-signers", "", "Comma-separated fingerprints allowed to sign commits (default any key in -gitops-signers-keyring)")
+	fs.StringVar(&f.signersKeyring, "gitops-signers-keyring", "", "Public keyring holding the signers' keys (default the agent user's GnuPG keyring)")
+	fs.StringVar(&f.sshKey, "gitops-ssh-key", "", "SSH deploy key for -gitops-repo")
+	fs.StringVar(&f.knownHosts, "gitops-known-hosts", "", "known_hosts file for -gitops-ssh-key; enables strict host key checking")
+	fs.StringVar(&f.tokenFile, "gitops-token-file", "", "File holding an HTTPS access token for -gitops-repo")
+	fs.StringVar(&f.tokenUsername, "gitops-token-username", "", "Username sent with the token (default oauth2)")
+}
+
+// config builds the sync configuration, cloning under dataDir and handing
+// each new state to onUpdate
+func (f *gitopsFlags) config(dataDir string, onUpdate func(*config.State)) (gitops.Config, error) {
+	var token string
+	if f.tokenFile != "" {
+		var err error
+		if token, err = readSecretFile(f.tokenFile); err != nil {
+			return gitops.Config{}, fmt.Errorf("failed to read GitOps token: %w", err)
+		}
+	}
+
+	var signers []string
+	for _, fp := range strings.Split(f.allowedSigners, ",") {
+		if fp = strings.TrimSpace(fp); fp != "" {
+			signers = append(signers, fp)
+		}
+	}
+	if len(signers) > 0 && !f.verifySignature {
+		return gitops.Config{}, fmt.Errorf("-gitops-allowed-signers requires -gitops-verify-signature")
+	}
+
+	return gitops.Config{
+		RepoURL:         f.repo,
+		Branch:          f.branch,
+		StatePath:       f.statePath,
+		LocalPath:       filepath.Join(dataDir, "gitops"),
+		PollInterval:    f.interval,
+		VerifySignature: f.verifySignature,
+		AllowedSigners:  signers,
+		SignersKeyring:  f.signersKeyring,
+		Auth: gitops.Auth{
+			SSHKeyPath:    f.sshKey,
+			SSHKnownHosts: f.knownHosts,
+			Token:         token,
+			TokenUsername: f.tokenUsername,
+		},
+		OnUpdate: func(state *config.State) error {
+			onUpdate(state)
+			return nil
+		},
+	}, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/power-edge/power-edge/pkg/reconciler"
+)
+
+// readiness tracks whether the agent has finished starting up
+type readiness struct {
+	mu     sync.Mutex
+	reason string // Why the agent isn't ready; empty once it is
+}
+
+func newReadiness(reason string) *readiness {
+	return &readiness{reason: reason}
+}
+
+// NotReady marks the agent as not ready for the given reason
+func (r *readiness) NotReady(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reason = reason
+}
+
+// Ready marks the agent as ready
+func (r *readiness) Ready() {
+	r.NotReady("")
+}
+
+// Reason returns why the agent isn't ready, or "" if it is
+func (r *readiness) Reason() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reason
+}
+
+// healthHandler serves liveness: the process is up and neither the
+// reconciler nor any watcher goroutine has failed
+func healthHandler(recon *reconciler.Reconciler, watchers *watcherHandle) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var problems []string
+		if err := recon.HealthCheck(); err != nil {
+			problems = append(problems, fmt.Sprintf("reconciler: %v", err))
+		}
+		if eventWatcher := watchers.Get(); eventWatcher != nil {
+			if err := eventWatcher.Health(); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+
+		if len(problems) > 0 {
+			writeHealth(w, http.StatusServiceUnavailable, "unhealthy", strings.Join(problems, "; "))
+			return
+		}
+		writeHealth(w, http.StatusOK, "healthy", "")
+	}
+}
+
+// readyzHandler serves readiness: 503 until the initial state is loaded and
+// watchers, if enabled, have started
+func readyzHandler(ready *readiness) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if reason := ready.Reason(); reason != "" {
+			writeHealth(w, http.StatusServiceUnavailable, "not ready", reason)
+			return
+		}
+		writeHealth(w, http.StatusOK, "ready", "")
+	}
+}
+
+func writeHealth(w http.ResponseWriter, code int, status, reason string) {
+	body := map[string]string{
+		"status":  status,
+		"version": Version,
+	}
+	if reason != "" {
+		body["reason"] = reason
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
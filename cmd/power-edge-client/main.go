@@ -1,22 +1,35 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/power-edge/power-edge/pkg/apply"
 	"github.com/power-edge/power-edge/pkg/config"
+	"github.com/power-edge/power-edge/pkg/gitops"
+	"github.com/power-edge/power-edge/pkg/logging"
 	"github.com/power-edge/power-edge/pkg/metrics"
 	"github.com/power-edge/power-edge/pkg/reconciler"
 	"github.com/power-edge/power-edge/pkg/watcher"
@@ -31,17 +44,71 @@ var (
 )
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diff":
+			os.Exit(runDiff(os.Args[2:]))
+		case "plan":
+			os.Exit(runPlan(os.Args[2:]))
+		case "preflight":
+			os.Exit(runPreflight(os.Args[2:]))
+		case "reconcile":
+			os.Exit(runReconcile(os.Args[2:]))
+		case "validate":
+			os.Exit(runValidate(os.Args[2:]))
+		}
+	}
+
 	// Flags
-	stateConfig := flag.String("state-config", "/etc/power-edge/state.yaml", "Path to local state configuration (fallback)")
+	var stateConfigs stateFiles
+	flag.Var(&stateConfigs, "state-config", "Path to local state configuration (fallback), \"-\" for stdin or an http(s) URL; repeat to overlay files in order (default /etc/power-edge/state.yaml)")
 	watcherConfig := flag.String("watcher-config", "/etc/power-edge/watcher.yaml", "Path to watcher configuration")
 	listenAddr := flag.String("listen", ":9100", "Prometheus metrics listen address")
 	checkInterval := flag.Duration("check-interval", 30*time.Second, "State check interval")
+	checkSplay := flag.Float64("check-splay", 0.1, "Randomize each wait between checks by up to this fraction of -check-interval, so nodes started together don't hit the server at once (0 disables)")
 	reconcileMode := flag.String("reconcile", "disabled", "Reconciliation mode: disabled, dry-run, enforce")
 	serverURL := flag.String("server-url", "", "Power Edge server URL (e.g., http://localhost:8080)")
 	nodeID := flag.String("node-id", "", "Node ID (defaults to hostname)")
+	retryAttempts := flag.Int("retry-attempts", reconciler.DefaultRetryPolicy.MaxAttempts, "Max attempts per resource in enforce mode (1 disables retries)")
+	retryDelay := flag.Duration("retry-delay", reconciler.DefaultRetryPolicy.BaseDelay, "Base delay for exponential retry backoff")
+	stateWait := flag.Duration("state-wait", 30*time.Second, "Long-poll timeout for state changes pushed by the server (0 disables)")
+	pollTimeout := flag.Duration("poll-timeout", 0, "Total timeout for each long-poll request; must exceed -state-wait (default -state-wait plus 15s)")
+	serverTimeout := flag.Duration("server-timeout", defaultServerTimeout, "Total timeout for each ordinary request to the server")
+	fetchAttempts := flag.Int("fetch-attempts", 5, "Attempts to fetch state from the server at startup before falling back to the local file")
+	fetchMaxDelay := flag.Duration("fetch-max-delay", 30*time.Second, "Upper bound for the backoff between startup fetch attempts")
+	fetchTimeout := flag.Duration("fetch-timeout", 10*time.Second, "Timeout for each state fetch from the server")
+	httpReadTimeout := flag.Duration("http-read-timeout", 5*time.Second, "Read timeout of the metrics and status HTTP server")
+	httpWriteTimeout := flag.Duration("http-write-timeout", 10*time.Second, "Write timeout of the metrics and status HTTP server")
+	httpIdleTimeout := flag.Duration("http-idle-timeout", 120*time.Second, "Keep-alive idle timeout of the metrics and status HTTP server")
+	heartbeatInterval := flag.Duration("heartbeat-interval", 30*time.Second, "Interval between heartbeats sent to the server")
+	pushgatewayURL := flag.String("pushgateway-url", "", "Also push metrics to this Prometheus Pushgateway, for nodes that can't be scraped (e.g. behind NAT)")
+	pushInterval := flag.Duration("push-interval", 30*time.Second, "Interval between pushes to the Pushgateway")
+	caCert := flag.String("ca-cert", "", "PEM CA bundle used to verify the server's TLS certificate")
+	insecureSkipVerify := flag.Bool("insecure-skip-verify", false, "Skip TLS certificate verification (testing only)")
+	workers := flag.Int("workers", runtime.NumCPU(), "Resources of the same type reconciled concurrently")
+	commandTimeout := flag.Duration("command-timeout", apply.DefaultCommandTimeout, "Kill any command run while applying state (systemctl, apt-get, ...) after this long")
+	sudo := flag.String("sudo", "sudo", "Command that system-changing commands (systemctl, apt-get, ...) run through, e.g. \"sudo -n\" or doas; empty runs them directly, as root")
+	resultsFile := flag.String("results-file", "", "Append every reconcile result as NDJSON to this file (\"-\" for stdout)")
+	dataDir := flag.String("data-dir", "/var/lib/power-edge", "Directory for the agent's own persistent data")
+	adminTokenFile := flag.String("admin-token-file", "", "File holding the bearer token for the /admin endpoints (unset disables them)")
+	lastApplied := flag.Bool("last-applied", true, "Record what enforce passes applied under -data-dir and report changes made while the agent was down (disable for stateless deployments)")
+	sourceCache := flag.Bool("source-cache", true, "Keep https file sources with a sha256 under -data-dir and download each only once")
 	version := flag.Bool("version", false, "Print version and exit")
+	var gitopsOpts gitopsFlags
+	gitopsOpts.addFlags(flag.CommandLine)
+	var logOpts logging.Options
+	logOpts.AddFlags(flag.CommandLine)
 	flag.Parse()
 
+	if err := logging.Setup(os.Stderr, logOpts); err != nil {
+		logging.Fatalf("Invalid logging flags: %v", err)
+	}
+
+	if len(stateConfigs) == 0 {
+		stateConfigs = stateFiles{defaultStateConfig}
+	}
+
 	// Version info
 	if *version {
 		fmt.Printf("edge-state-exporter %s\n", Version)
@@ -54,7 +121,7 @@ func main() {
 	if *nodeID == "" {
 		hostname, err := os.Hostname()
 		if err != nil {
-			log.Fatalf("Failed to get hostname: %v", err)
+			logging.Fatalf("Failed to get hostname: %v", err)
 		}
 		*nodeID = hostname
 	}
@@ -62,118 +129,302 @@ func main() {
 	log.Printf("🚀 Starting power-edge-client %s", Version)
 	log.Printf("   Node ID:           %s", *nodeID)
 	log.Printf("   Server URL:        %s", *serverURL)
-	log.Printf("   Local State:       %s (fallback)", *stateConfig)
+	log.Printf("   Local State:       %s (fallback)", stateConfigs)
 	log.Printf("   Watcher Config:    %s", *watcherConfig)
 	log.Printf("   Listen Addr:       %s", *listenAddr)
-	log.Printf("   Check Interval:    %s", *checkInterval)
+	if *checkSplay < 0 || *checkSplay >= 1 {
+		logging.Fatalf("-check-splay must be at least 0 and less than 1, got %g", *checkSplay)
+	}
+	log.Printf("   Check Interval:    %s (±%g%%)", *checkInterval, *checkSplay*100)
 	log.Printf("   Reconcile Mode:    %s", *reconcileMode)
 
+	// Configure the server API clients
+	if *pollTimeout == 0 {
+		*pollTimeout = *stateWait + pollGrace
+	}
+	if *stateWait > 0 && *pollTimeout <= *stateWait {
+		logging.Fatalf("-poll-timeout (%s) must exceed -state-wait (%s)", *pollTimeout, *stateWait)
+	}
+	client, err := newAPIClient(*caCert, *insecureSkipVerify, *serverTimeout)
+	if err != nil {
+		logging.Fatalf("Failed to configure TLS: %v", err)
+	}
+	apiClient = client
+	pollClient = withTimeout(client, *pollTimeout)
+	if *insecureSkipVerify {
+		slog.Warn("   ⚠️  TLS certificate verification disabled")
+	}
+
+	// Initialize reconciler
+	reconMode, err := parseReconcileMode(*reconcileMode)
+	if err != nil {
+		logging.Fatalf("Invalid -reconcile: %v", err)
+	}
+	switch reconMode {
+	case reconciler.ModeEnforce:
+		log.Println("⚙️  Reconciliation: ENFORCE (will actively fix drift)")
+	case reconciler.ModeDryRun:
+		log.Println("🔍 Reconciliation: DRY-RUN (will log changes without applying)")
+	default:
+		log.Println("👁️  Reconciliation: DISABLED (monitor-only mode)")
+	}
+	reconcilerInstance := reconciler.NewReconciler(reconMode)
+	reconcilerInstance.SetRetryPolicy(reconciler.RetryPolicy{
+		MaxAttempts: *retryAttempts,
+		BaseDelay:   *retryDelay,
+		MaxDelay:    reconciler.DefaultRetryPolicy.MaxDelay,
+	})
+	reconcilerInstance.SetWorkers(*workers)
+	reconcilerInstance.SetCommandTimeout(*commandTimeout)
+	reconcilerInstance.SetPrivilegeCommand(strings.Fields(*sudo))
+	if reconMode == reconciler.ModeEnforce {
+		if err := reconcilerInstance.CheckPrivileges(context.Background()); err != nil {
+			logging.Fatalf("Cannot enforce state: %v", err)
+		}
+	}
+
+	var resultWriter *reconciler.ResultWriter
+	if *resultsFile != "" {
+		out, err := openResultsFile(*resultsFile)
+		if err != nil {
+			logging.Fatalf("Failed to open results file: %v", err)
+		}
+		defer out.Close()
+		resultWriter = reconciler.NewResultWriter(out)
+		reconcilerInstance.SetResultWriter(resultWriter)
+		log.Printf("   Writing reconcile results to %s", *resultsFile)
+	}
+
+	var lastAppliedStore *reconciler.LastAppliedStore
+	if *lastApplied {
+		path := filepath.Join(*dataDir, "last-applied.json")
+		lastAppliedStore, err = reconciler.OpenLastAppliedStore(path)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("   ⚠️  Not recording last-applied state: %v", err))
+		} else {
+			reconcilerInstance.SetLastAppliedStore(lastAppliedStore)
+			log.Printf("   Recording last-applied state in %s", path)
+		}
+	}
+
+	if *sourceCache {
+		dir := filepath.Join(*dataDir, "cache", "sources")
+		reconcilerInstance.SetSourceCache(apply.NewSourceCache(dir))
+		log.Printf("   Caching downloaded file sources in %s", dir)
+	}
+
+	// Serve health endpoints while the state is still loading, so supervisors
+	// can tell a slow startup from a dead process
+	watchers := &watcherHandle{}
+	ready := newReadiness("loading initial state")
+	http.HandleFunc("/health", healthHandler(reconcilerInstance, watchers))
+	http.HandleFunc("/readyz", readyzHandler(ready))
+	http.HandleFunc("/version", versionHandler)
+
+	adminToken, err := loadAdminToken(*adminTokenFile)
+	if err != nil {
+		logging.Fatalf("Failed to configure admin endpoints: %v", err)
+	}
+	if adminToken != "" {
+		http.HandleFunc("/admin/mode", requireToken(adminToken, adminModeHandler(reconcilerInstance)))
+	}
+
+	// Live results and watcher events for dashboards
+	events := newEventBroker()
+	http.HandleFunc("/events/stream", eventStreamHandler(events))
+
+	server := &http.Server{
+		Addr:         *listenAddr,
+		ReadTimeout:  *httpReadTimeout,
+		WriteTimeout: *httpWriteTimeout,
+		IdleTimeout:  *httpIdleTimeout,
+	}
+
+	// Start server in goroutine
+	go func() {
+		log.Printf("📊 HTTP server listening on %s", *listenAddr)
+		log.Printf("   /metrics - Prometheus metrics")
+		log.Printf("   /health  - Liveness check")
+		log.Printf("   /readyz  - Readiness check")
+		log.Printf("   /version - Version info")
+		log.Printf("   /status  - Live system status")
+		log.Printf("   /events/stream - Live reconcile and watcher events (SSE)")
+		if adminToken != "" {
+			log.Printf("   /admin/mode - Change reconcile mode (POST, bearer token)")
+		}
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logging.Fatalf("HTTP server error: %v", err)
+		}
+	}()
+
 	// Load state configuration
 	log.Println("📖 Loading state configuration...")
 	var state *config.State
-	var err error
 
 	// Try to fetch from server first
 	if *serverURL != "" {
 		log.Printf("   Attempting to fetch state from server: %s", *serverURL)
-		state, err = fetchStateFromServer(*serverURL, *nodeID)
+		state, err = fetchStateWithRetry(*serverURL, *nodeID, *fetchTimeout, reconciler.RetryPolicy{
+			MaxAttempts: *fetchAttempts,
+			BaseDelay:   time.Second,
+			MaxDelay:    *fetchMaxDelay,
+		})
 		if err != nil {
-			log.Printf("   ⚠️  Failed to fetch from server: %v", err)
-			log.Printf("   📁 Falling back to local file: %s", *stateConfig)
-			state, err = config.LoadStateConfig(*stateConfig)
+			slog.Warn(fmt.Sprintf("   ⚠️  Failed to fetch from server: %v", err))
+			log.Printf("   📁 Falling back to local file: %s", stateConfigs)
+			state, err = stateConfigs.load()
 			if err != nil {
-				log.Fatalf("Failed to load local state config: %v", err)
+				logging.Fatalf("Failed to load local state config: %v", err)
 			}
+			staleSince := "unknown"
+			if modTime, ok := stateConfigs.lastModified(); ok {
+				staleSince = modTime.Format(time.RFC3339)
+			}
+			log.Printf("   🚨 USING STALE LOCAL STATE: %s was last written %s and may not match the server", stateConfigs, staleSince)
 		} else {
 			log.Printf("   ✅ Fetched state from server")
 			// Save to local file for offline operation
-			if err := saveStateToLocalFile(*stateConfig, state); err != nil {
-				log.Printf("   ⚠️  Failed to save state to local file: %v", err)
+			if err := stateConfigs.save(state); err != nil {
+				slog.Warn(fmt.Sprintf("   ⚠️  Failed to save state to local file: %v", err))
 			}
 		}
 	} else {
 		// No server configured, use local file only
-		log.Printf("   📁 Loading from local file: %s", *stateConfig)
-		state, err = config.LoadStateConfig(*stateConfig)
+		log.Printf("   📁 Loading from local file: %s", stateConfigs)
+		state, err = stateConfigs.load()
 		if err != nil {
-			log.Fatalf("Failed to load state config: %v", err)
+			logging.Fatalf("Failed to load state config: %v", err)
 		}
 	}
 
 	log.Printf("   Loaded state: %s (%s)", state.Metadata.Site, state.Metadata.Environment)
 
+	// Before the first pass overwrites the records
+	if lastAppliedStore != nil {
+		reportOfflineDrift(reconcilerInstance, lastAppliedStore, resultWriter)
+	}
+
 	watcherCfg, err := config.LoadWatcherConfig(*watcherConfig)
 	if err != nil {
-		log.Fatalf("Failed to load watcher config: %v", err)
+		logging.Fatalf("Failed to load watcher config: %v", err)
 	}
 	log.Printf("   Loaded watcher config (watchers enabled: %v)", watcherCfg.Watchers.Enabled)
-
-	// Initialize reconciler
-	var reconMode reconciler.ReconcileMode
-	switch *reconcileMode {
-	case "enforce":
-		reconMode = reconciler.ModeEnforce
-		log.Println("⚙️  Reconciliation: ENFORCE (will actively fix drift)")
-	case "dry-run":
-		reconMode = reconciler.ModeDryRun
-		log.Println("🔍 Reconciliation: DRY-RUN (will log changes without applying)")
-	default:
-		reconMode = reconciler.ModeDisabled
-		log.Println("👁️  Reconciliation: DISABLED (monitor-only mode)")
+	if watcherCfg.Reconcile != "" {
+		mode, err := parseReconcileMode(string(watcherCfg.Reconcile))
+		if err != nil {
+			logging.Fatalf("Invalid reconcile in watcher config: %v", err)
+		}
+		reconcilerInstance.SetMode(mode)
+		log.Printf("   Reconcile mode %s set by watcher config, overriding -reconcile", mode)
 	}
-	reconcilerInstance := reconciler.NewReconciler(reconMode)
 
 	// Initialize metrics
 	metricsCollector := metrics.NewCollector(state)
+	metricsCollector.SetBuildInfo(Version, GitCommit, BuildTime)
+	metricsCollector.Registry().MustRegister(watcher.DroppedEvents, streamDropped)
+	reconcilerInstance.SetResultObserver(func(record reconciler.ResultRecord) {
+		metricsCollector.RecordResult(record)
+		events.Publish("result", record)
+	})
 
 	// Initialize watchers
-	var eventWatcher *watcher.EventWatcher
 	if watcherCfg.Watchers.Enabled {
+		ready.NotReady("starting watchers")
 		log.Println("🔍 Initializing event watchers...")
-		eventWatcher = watcher.NewEventWatcher(watcherCfg, reconcilerInstance, state)
-		if err := eventWatcher.Start(context.Background()); err != nil {
-			log.Fatalf("Failed to start watchers: %v", err)
+		eventWatcher, err := startWatcher(watcherCfg, reconcilerInstance, state, events)
+		if err != nil {
+			logging.Fatalf("Failed to start watchers: %v", err)
 		}
+		watchers.Set(eventWatcher)
 		log.Println("   ✅ Event watchers started")
 	} else {
-		log.Println("⚠️  Event watchers disabled")
+		slog.Warn("⚠️  Event watchers disabled")
 	}
 
 	// Start periodic state checker
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go runPeriodicChecks(ctx, state, metricsCollector, reconcilerInstance, *checkInterval)
-
-	// Start HTTP server for Prometheus metrics
+	states := newStateHolder(state)
+	stateChanged := make(chan struct{}, 1)
 	http.Handle("/metrics", metricsCollector.Handler())
-	http.HandleFunc("/health", healthHandler)
-	http.HandleFunc("/version", versionHandler)
-	http.HandleFunc("/status", statusHandler(state, metricsCollector, reconcilerInstance, eventWatcher))
+	http.HandleFunc("/status", statusHandler(states, reconcilerInstance, watchers))
+	ready.Ready()
 
-	server := &http.Server{
-		Addr:         *listenAddr,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
+	// Record what each pass changed on the server, for auditing
+	if *serverURL != "" {
+		reconcilerInstance.SetChangeHandler(func(ctx context.Context, records []reconciler.ResultRecord) {
+			if err := pushEvents(ctx, *serverURL, *nodeID, records); err != nil {
+				slog.Warn(fmt.Sprintf("   ⚠️  Failed to push change events: %v", err))
+			}
+		})
 	}
 
-	// Start server in goroutine
-	go func() {
-		log.Printf("📊 HTTP server listening on %s", *listenAddr)
-		log.Printf("   /metrics - Prometheus metrics")
-		log.Printf("   /health  - Health check")
-		log.Printf("   /version - Version info")
-		log.Printf("   /status  - Live system status")
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("HTTP server error: %v", err)
+	go runPeriodicChecks(ctx, states, stateChanged, metricsCollector, reconcilerInstance, resultWriter, *checkInterval, *checkSplay, *serverURL, *nodeID)
+
+	// Swaps in state pushed to the server or committed to Git
+	applyState := func(newState *config.State) {
+		states.Set(newState)
+		if eventWatcher := watchers.Get(); eventWatcher != nil {
+			eventWatcher.SetState(newState)
 		}
-	}()
+		if err := stateConfigs.save(newState); err != nil {
+			slog.Warn(fmt.Sprintf("   ⚠️  Failed to save state to local file: %v", err))
+		}
+		requestReconcile(stateChanged)
+	}
+
+	// Pick up state pushed to the server without waiting for the next tick
+	if *serverURL != "" && *stateWait > 0 {
+		go watchServerState(ctx, *serverURL, *nodeID, *stateWait, states, applyState)
+	}
+
+	if gitopsOpts.repo != "" {
+		cfg, err := gitopsOpts.config(*dataDir, applyState)
+		if err != nil {
+			logging.Fatalf("Failed to configure GitOps sync: %v", err)
+		}
+		go func() {
+			if err := gitops.NewGitOpsSync(cfg).Start(ctx); err != nil {
+				slog.Error(fmt.Sprintf("GitOps sync stopped: %v", err))
+			}
+		}()
+	}
+
+	if *serverURL != "" {
+		go runHeartbeats(ctx, *serverURL, *nodeID, *heartbeatInterval, states)
+	}
+
+	// Push metrics for nodes Prometheus can't reach; /metrics keeps serving
+	if *pushgatewayURL != "" {
+		if *pushInterval <= 0 {
+			logging.Fatalf("-push-interval must be positive, got %s", *pushInterval)
+		}
+		log.Printf("📤 Pushing metrics to %s every %s", *pushgatewayURL, *pushInterval)
+		go runMetricsPush(ctx, metricsCollector, withTimeout(apiClient, min(*pushInterval, *serverTimeout)), *pushgatewayURL, *nodeID, *pushInterval)
+	}
+
+	reload := &reloader{
+		stateConfigs:  stateConfigs,
+		watcherConfig: *watcherConfig,
+		serverURL:     *serverURL,
+		nodeID:        *nodeID,
+		recon:         reconcilerInstance,
+		states:        states,
+		watchers:      watchers,
+		watcherCfg:    watcherCfg,
+		flagMode:      reconMode,
+		configMode:    watcherCfg.Reconcile,
+		events:        events,
+		stateChanged:  stateChanged,
+	}
 
-	// Wait for shutdown signal
+	// Reload on SIGHUP, shut down on SIGINT/SIGTERM
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := <-sigChan; sig == syscall.SIGHUP; sig = <-sigChan {
+		reload.reload()
+	}
 
 	log.Println("🛑 Shutting down gracefully...")
 
@@ -181,63 +432,76 @@ func main() {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
 
+	events.Close()
 	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Printf("HTTP server shutdown error: %v", err)
+		slog.Error(fmt.Sprintf("HTTP server shutdown error: %v", err))
 	}
 
 	// Stop watchers
-	if eventWatcher != nil {
+	if eventWatcher := watchers.Get(); eventWatcher != nil {
 		if err := eventWatcher.Stop(); err != nil {
-			log.Printf("Watcher shutdown error: %v", err)
+			slog.Error(fmt.Sprintf("Watcher shutdown error: %v", err))
 		}
 	}
 
 	log.Println("✅ Shutdown complete")
 }
 
-func runPeriodicChecks(ctx context.Context, state *config.State, collector *metrics.Collector, recon *reconciler.Reconciler, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+func runPeriodicChecks(ctx context.Context, states *stateHolder, stateChanged <-chan struct{}, collector *metrics.Collector, recon *reconciler.Reconciler, resultWriter *reconciler.ResultWriter, interval time.Duration, splay float64, serverURL, nodeID string) {
+	timer := time.NewTimer(splayed(interval, splay))
+	defer timer.Stop()
 
-	// Run initial check
-	log.Println("🔍 Running initial state check...")
-	if err := collector.CheckAndUpdate(state); err != nil {
-		log.Printf("State check error: %v", err)
-	}
+	check := func(kind string) {
+		state := states.Get()
+		runID := reconciler.NewRunID(state)
+		log.Printf("🔍 Running %s state check (run %s)...", kind, runID)
+		if err := collector.CheckAndUpdate(recon.CommandContext(ctx), state); err != nil {
+			slog.Error(fmt.Sprintf("State check error: %v", err))
+		}
 
-	// Run initial reconciliation
-	if recon.GetMode() != reconciler.ModeDisabled {
-		log.Println("🔧 Running initial reconciliation...")
-		if _, err := recon.ReconcileAll(ctx, state); err != nil {
-			log.Printf("Reconciliation error: %v", err)
+		var report reconciler.DriftReport
+		if recon.Enabled(state) {
+			log.Printf("🔧 Running %s reconciliation (run %s)...", kind, runID)
+			start := time.Now()
+			results, err := recon.ReconcileAll(reconciler.WithRunID(ctx, runID), state)
+			if err != nil {
+				slog.Error(fmt.Sprintf("Reconciliation error: %v", err))
+			}
+			collector.RecordReconcile(results, time.Since(start))
+			report = reconciler.ResultsReport(results)
+		} else if resultWriter != nil || serverURL != "" {
+			// Nothing is reconciled, but the results stream and the server
+			// still get the checks
+			report, _ = recon.Report(reconciler.WithRunID(ctx, runID), state)
+			if resultWriter != nil {
+				if err := resultWriter.WriteReport(reconciler.ModeDisabled, report); err != nil {
+					slog.Warn(fmt.Sprintf("   ⚠️  Failed to write results: %v", err))
+				}
+			}
 		}
+		reportCompliance(ctx, serverURL, nodeID, report)
 	}
 
+	check("initial")
+
 	for {
 		select {
-		case <-ticker.C:
-			log.Println("🔍 Running periodic state check...")
-			if err := collector.CheckAndUpdate(state); err != nil {
-				log.Printf("State check error: %v", err)
-			}
-
-			// Run periodic reconciliation
-			if recon.GetMode() != reconciler.ModeDisabled {
-				log.Println("🔧 Running periodic reconciliation...")
-				if _, err := recon.ReconcileAll(ctx, state); err != nil {
-					log.Printf("Reconciliation error: %v", err)
-				}
-			}
+		case <-timer.C:
+			check("periodic")
+			timer.Reset(splayed(interval, splay))
+		case <-stateChanged:
+			check("on-demand")
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `{"status":"healthy","version":"%s"}`, Version)
+// splayed returns interval moved by a random amount of up to splay times
+// interval either way. Waiting a freshly splayed interval each time keeps the
+// average period while nodes started together drift apart.
+func splayed(interval time.Duration, splay float64) time.Duration {
+	return interval + time.Duration((2*rand.Float64()-1)*splay*float64(interval))
 }
 
 func versionHandler(w http.ResponseWriter, r *http.Request) {
@@ -246,8 +510,9 @@ func versionHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, `{"version":"%s","git_commit":"%s","build_time":"%s"}`, Version, GitCommit, BuildTime)
 }
 
-func statusHandler(state *config.State, collector *metrics.Collector, recon *reconciler.Reconciler, watcher *watcher.EventWatcher) http.HandlerFunc {
+func statusHandler(states *stateHolder, recon *reconciler.Reconciler, watchers *watcherHandle) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		state := states.Get()
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 
@@ -262,6 +527,7 @@ func statusHandler(state *config.State, collector *metrics.Collector, recon *rec
 		case reconciler.ModeEnforce:
 			modeStr = "enforce"
 		}
+		services, failedUnits := getServiceStatus(state)
 
 		status := map[string]interface{}{
 			"timestamp": time.Now().UTC().Format(time.RFC3339),
@@ -275,34 +541,60 @@ func statusHandler(state *config.State, collector *metrics.Collector, recon *rec
 			"reconciliation": map[string]interface{}{
 				"mode":    modeStr,
 				"enabled": mode != reconciler.ModeDisabled,
+				"freeze":  getFreezeStatus(state),
 			},
-			"watchers": map[string]interface{}{
-				"enabled": watcher != nil,
-			},
-			"compliance": getComplianceStatus(state, collector),
-			"services":   getServiceStatus(state),
-			"sysctl":     getSysctlStatus(state),
-			"firewall":   getFirewallStatus(state),
+			"watchers":     getWatcherStatus(watchers),
+			"compliance":   getComplianceStatus(r.Context(), state, recon),
+			"services":     services,
+			"failed_units": failedUnits,
+			"sysctl":       getSysctlStatus(state),
+			"firewall":     getFirewallStatus(recon.CommandContext(r.Context())),
 		}
 
 		json.NewEncoder(w).Encode(status)
 	}
 }
 
+// getFreezeStatus reports whether a change freeze is holding enforcement back
+func getFreezeStatus(state *config.State) map[string]interface{} {
+	status := map[string]interface{}{
+		"active": state.Freeze.Active(time.Now()),
+	}
+	if state.Freeze.Until != "" {
+		status["until"] = state.Freeze.Until
+	}
+	if state.Freeze.Reason != "" {
+		status["reason"] = state.Freeze.Reason
+	}
+	return status
+}
+
+// getWatcherStatus reports whether watchers are enabled and, when they are,
+// the health and event counters of each one
+func getWatcherStatus(watchers *watcherHandle) map[string]interface{} {
+	status := map[string]interface{}{
+		"enabled": false,
+	}
+	eventWatcher := watchers.Get()
+	if eventWatcher == nil {
+		return status
+	}
+
+	status["enabled"] = true
+	for name, stats := range eventWatcher.Stats() {
+		status[name] = stats
+	}
+	return status
+}
+
 func getHostname() string {
 	hostname, _ := os.Hostname()
 	return hostname
 }
 
 func getOSInfo() string {
-	data, err := os.ReadFile("/etc/os-release")
-	if err != nil {
-		return "unknown"
-	}
-	for _, line := range strings.Split(string(data), "\n") {
-		if strings.HasPrefix(line, "PRETTY_NAME=") {
-			return strings.Trim(strings.TrimPrefix(line, "PRETTY_NAME="), "\"")
-		}
+	if name := readOSRelease()["PRETTY_NAME"]; name != "" {
+		return name
 	}
 	return "unknown"
 }
@@ -329,78 +621,99 @@ func getUptime() string {
 	return "unknown"
 }
 
-func getComplianceStatus(state *config.State, collector *metrics.Collector) map[string]interface{} {
-	// Get current metrics
-	compliant, total := 0, 0
-
-	// Count service compliance
-	for range state.Services {
-		total++
-		if collector != nil {
-			// Check if service is compliant (simplified)
-			compliant++
+func getComplianceStatus(ctx context.Context, state *config.State, recon *reconciler.Reconciler) map[string]interface{} {
+	report, err := recon.CachedReport(ctx, state)
+	if err != nil {
+		return map[string]interface{}{
+			"error": err.Error(),
 		}
 	}
+	return complianceSummary(report)
+}
 
-	// Count sysctl compliance
-	for range state.Sysctl {
-		total++
-		compliant++
-	}
-
-	percentage := 0.0
-	if total > 0 {
-		percentage = float64(compliant) / float64(total) * 100
-	}
-
+// complianceSummary is the compliance section of /status and of the reports
+// pushed to the server
+func complianceSummary(report reconciler.DriftReport) map[string]interface{} {
 	return map[string]interface{}{
-		"total":      total,
-		"compliant":  compliant,
-		"percentage": percentage,
+		"total":      report.Total(),
+		"compliant":  len(report.Compliant),
+		"drifted":    len(report.Drifted),
+		"errors":     len(report.Errors),
+		"percentage": report.CompliancePercentage(),
+		"by_type":    report.ByType(),
+		"report":     report,
 	}
 }
 
-func getServiceStatus(state *config.State) []map[string]interface{} {
-	services := []map[string]interface{}{}
-	for _, svc := range state.Services {
-		status := map[string]interface{}{
-			"name":    svc.Name,
-			"enabled": svc.Enabled,
-			"running": isServiceRunning(string(svc.Name)),
+// getServiceStatus reports the live state of each managed service, along
+// with the names of those systemd considers failed
+func getServiceStatus(state *config.State) (services []map[string]interface{}, failedUnits []string) {
+	services = make([]map[string]interface{}, len(state.Services))
+	apply.CheckEach(len(state.Services), apply.DefaultCheckConcurrency, func(i int) {
+		svc := state.Services[i]
+		activeState, subState := unitState(string(svc.Name))
+		services[i] = map[string]interface{}{
+			"name":      svc.Name,
+			"enabled":   svc.Enabled,
+			"running":   activeState == "active",
+			"failed":    activeState == "failed",
+			"sub_state": subState,
+		}
+	})
+
+	failedUnits = []string{}
+	for i, svc := range state.Services {
+		if services[i]["failed"] == true {
+			failedUnits = append(failedUnits, string(svc.Name))
 		}
-		services = append(services, status)
 	}
-	return services
+	return services, failedUnits
 }
 
-func isServiceRunning(name string) bool {
-	cmd := exec.Command("systemctl", "is-active", name)
+// unitState returns a unit's ActiveState (active, inactive, failed, ...) and
+// SubState (running, dead, auto-restart, ...). Both are empty if systemctl
+// can't be queried.
+func unitState(name string) (activeState, subState string) {
+	cmd := exec.Command("systemctl", "show", "-p", "ActiveState,SubState", name)
 	output, _ := cmd.Output()
-	return strings.TrimSpace(string(output)) == "active"
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "ActiveState":
+			activeState = value
+		case "SubState":
+			subState = value
+		}
+	}
+	return activeState, subState
 }
 
+// getSysctlStatus reports the live value of each managed sysctl key, sorted
+// by key
 func getSysctlStatus(state *config.State) []map[string]interface{} {
-	params := []map[string]interface{}{}
-	for key, expectedValue := range state.Sysctl {
-		cmd := exec.Command("sysctl", "-n", string(key))
-		output, err := cmd.Output()
+	keys := slices.Sorted(maps.Keys(state.Sysctl))
+	params := make([]map[string]interface{}, len(keys))
+	apply.CheckEach(len(keys), apply.DefaultCheckConcurrency, func(i int) {
+		key, expectedValue := keys[i], state.Sysctl[keys[i]]
+		output, err := exec.Command("sysctl", "-n", key).Output()
 		currentValue := strings.TrimSpace(string(output))
 
-		status := map[string]interface{}{
-			"key":      key,
-			"expected": expectedValue,
-			"current":  currentValue,
+		params[i] = map[string]interface{}{
+			"key":       key,
+			"expected":  expectedValue,
+			"current":   currentValue,
 			"compliant": err == nil && currentValue == expectedValue,
 		}
-		params = append(params, status)
-	}
+	})
 	return params
 }
 
-func getFirewallStatus(state *config.State) map[string]interface{} {
+// getFirewallStatus reports the firewall's rules, running ufw or iptables
+// with the privilege command carried by ctx
+func getFirewallStatus(ctx context.Context) map[string]interface{} {
 	// Check UFW first
 	if cmd := exec.Command("command", "-v", "ufw"); cmd.Run() == nil {
-		output, err := exec.Command("sudo", "ufw", "status", "numbered").Output()
+		output, err := apply.PrivilegedOutput(ctx, "ufw", "status", "numbered")
 		if err == nil {
 			return map[string]interface{}{
 				"type":   "ufw",
@@ -411,7 +724,7 @@ func getFirewallStatus(state *config.State) map[string]interface{} {
 	}
 
 	// Check iptables
-	output, err := exec.Command("sudo", "iptables", "-L", "-n", "-v").Output()
+	output, err := apply.PrivilegedOutput(ctx, "iptables", "-L", "-n", "-v")
 	if err == nil {
 		return map[string]interface{}{
 			"type":  "iptables",
@@ -425,32 +738,311 @@ func getFirewallStatus(state *config.State) map[string]interface{} {
 	}
 }
 
-// fetchStateFromServer retrieves node state from the power-edge-server
-func fetchStateFromServer(serverURL, nodeID string) (*config.State, error) {
+// errNodeNotFound means the server has no state for this node; retrying won't help
+var errNodeNotFound = errors.New("node not found on server (have you initialized it?)")
+
+// fetchStateWithRetry fetches state from the server, backing off between
+// attempts so a server that is briefly down during a deploy doesn't push the
+// agent onto stale local state
+func fetchStateWithRetry(serverURL, nodeID string, timeout time.Duration, policy reconciler.RetryPolicy) (*config.State, error) {
+	var err error
+	for attempt := 1; ; attempt++ {
+		var state *config.State
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		state, err = fetchStateFromServer(ctx, serverURL, nodeID)
+		cancel()
+		if err == nil {
+			return state, nil
+		}
+		if errors.Is(err, errNodeNotFound) || attempt >= policy.MaxAttempts {
+			break
+		}
+
+		delay := policy.Delay(attempt)
+		slog.Warn(fmt.Sprintf("   ⚠️  Fetch attempt %d/%d failed: %v (retrying in %s)", attempt, policy.MaxAttempts, err, delay))
+		time.Sleep(delay)
+	}
+	return nil, err
+}
+
+// errStateNotModified means the server's state matches the last state
+// fetched, which is returned alongside it
+var errStateNotModified = errors.New("state not modified on server")
+
+// lastFetched is the last state fetched from the server and its ETag, so a
+// conditional GET can skip downloading and decoding unchanged state
+var lastFetched struct {
+	sync.Mutex
+	etag  string
+	state *config.State
+}
+
+// fetchStateFromServer retrieves node state from the power-edge-server. If
+// the state is unchanged since the last fetch, the cached state is returned
+// with errStateNotModified.
+func fetchStateFromServer(ctx context.Context, serverURL, nodeID string) (*config.State, error) {
 	url := fmt.Sprintf("%s/api/v1/nodes/%s", serverURL, nodeID)
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch state: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	lastFetched.Lock()
+	etag, cached := lastFetched.etag, lastFetched.state
+	lastFetched.Unlock()
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch state: %w", describeTLSError(err))
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("node not found on server (have you initialized it?)")
+	// Asking for gzip ourselves turns off the transport's transparent
+	// decompression
+	var body io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress state: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached, errStateNotModified
+	} else if resp.StatusCode == http.StatusNotFound {
+		return nil, errNodeNotFound
 	} else if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
+		msg, _ := io.ReadAll(body)
+		return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(msg))
 	}
 
 	// Decode YAML response
 	var state config.State
-	if err := yaml.NewDecoder(resp.Body).Decode(&state); err != nil {
+	if err := yaml.NewDecoder(body).Decode(&state); err != nil {
 		return nil, fmt.Errorf("failed to decode state: %w", err)
 	}
 
+	lastFetched.Lock()
+	lastFetched.etag, lastFetched.state = resp.Header.Get("ETag"), &state
+	lastFetched.Unlock()
+
 	return &state, nil
 }
 
+// runHeartbeats tells the server this node is alive, along with the versions
+// it is running, until ctx is cancelled
+func runHeartbeats(ctx context.Context, serverURL, nodeID string, interval time.Duration, states *stateHolder) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	packages := apply.NewPackageApplier()
+	for {
+		if err := sendHeartbeat(ctx, serverURL, nodeID); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️  Failed to send heartbeat: %v", err))
+		} else if err := pushVersions(ctx, serverURL, nodeID, collectVersions(ctx, states.Get(), packages)); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️  Failed to report versions: %v", err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runMetricsPush pushes metrics to a Pushgateway every interval. A failed
+// push is logged and retried on the next tick.
+func runMetricsPush(ctx context.Context, collector *metrics.Collector, client *http.Client, url, nodeID string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := collector.Push(ctx, client, url, nodeID); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️  %v", err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sendHeartbeat posts a heartbeat for this node to the power-edge-server
+func sendHeartbeat(ctx context.Context, serverURL, nodeID string) error {
+	url := fmt.Sprintf("%s/api/v1/nodes/%s/heartbeat", serverURL, nodeID)
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return describeTLSError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// reportCompliance pushes the compliance summary of the check that just ran
+// to the server. Failures are logged and otherwise ignored so an unreachable
+// server never stops the local check loop.
+func reportCompliance(ctx context.Context, serverURL, nodeID string, report reconciler.DriftReport) {
+	if serverURL == "" {
+		return
+	}
+
+	summary := complianceSummary(report)
+	summary["node_id"] = nodeID
+	summary["timestamp"] = time.Now().UTC().Format(time.RFC3339)
+
+	if err := pushComplianceToServer(ctx, serverURL, nodeID, summary); err != nil {
+		slog.Warn(fmt.Sprintf("   ⚠️  Failed to report compliance to server: %v", err))
+	}
+}
+
+// pushComplianceToServer uploads a compliance summary to the power-edge-server
+func pushComplianceToServer(ctx context.Context, serverURL, nodeID string, summary map[string]interface{}) error {
+	url := fmt.Sprintf("%s/api/v1/nodes/%s/compliance", serverURL, nodeID)
+
+	body, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to marshal compliance: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push compliance: %w", describeTLSError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// defaultStateConfig is the local state file used when --state-config isn't given
+const defaultStateConfig = "/etc/power-edge/state.yaml"
+
+// stateFiles is the --state-config flag: local state documents that are
+// merged in order with config.Merge, so a site-wide base can be followed by
+// per-node overrides. Besides file paths, "-" reads a document from stdin
+// and an http(s) URL fetches one.
+type stateFiles []string
+
+func (f *stateFiles) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stateFiles) Set(path string) error {
+	*f = append(*f, path)
+	return nil
+}
+
+// load reads, merges and validates the state documents
+func (f stateFiles) load() (*config.State, error) {
+	return loadStateSources(f...)
+}
+
+// lastModified returns the newest modification time of the files; stdin
+// and URLs have none
+func (f stateFiles) lastModified() (time.Time, bool) {
+	var newest time.Time
+	for _, path := range f {
+		if !isLocalState(path) {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, !newest.IsZero()
+}
+
+// save caches state fetched from the server for offline operation. Merged
+// state can't be split back into its layers, so nothing is written when
+// several files are configured, nor when the one source isn't a file.
+func (f stateFiles) save(state *config.State) error {
+	if len(f) != 1 || !isLocalState(f[0]) {
+		return nil
+	}
+	return saveStateToLocalFile(f[0], state)
+}
+
+// reportOfflineDrift logs the resources changed since the agent last enforced
+// them, i.e. out of band while it was not running
+func reportOfflineDrift(recon *reconciler.Reconciler, store *reconciler.LastAppliedStore, resultWriter *reconciler.ResultWriter) {
+	report := recon.CheckLastApplied(context.Background(), store)
+	if report.Total() == 0 {
+		return
+	}
+
+	if len(report.Drifted) == 0 && len(report.Errors) == 0 {
+		log.Printf("   ✅ No changes since last applied (%d resources)", report.Total())
+		return
+	}
+
+	log.Printf("   🚨 %d resources changed since last applied:", len(report.Drifted))
+	for _, drift := range report.Drifted {
+		log.Printf("      %s/%s: %s", drift.Type, drift.Name, drift.Action)
+	}
+	for _, drift := range report.Errors {
+		slog.Warn(fmt.Sprintf("      ⚠️  %s/%s: could not check: %s", drift.Type, drift.Name, drift.Error))
+	}
+
+	if resultWriter != nil {
+		if err := resultWriter.WriteReport(recon.GetMode(), report); err != nil {
+			slog.Warn(fmt.Sprintf("   ⚠️  Failed to write results: %v", err))
+		}
+	}
+}
+
+// openResultsFile opens the --results-file destination for appending
+func openResultsFile(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+}
+
+// nopWriteCloser keeps stdout open when the results file is closed
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
 // saveStateToLocalFile saves state to local file for offline operation
 func saveStateToLocalFile(path string, state *config.State) error {
 	data, err := yaml.Marshal(state)
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/power-edge/power-edge/pkg/apply"
+	"github.com/power-edge/power-edge/pkg/logging"
+	"github.com/power-edge/power-edge/pkg/reconciler"
+)
+
+// Exit codes for the reconcile subcommand
+const (
+	reconcileExitCompliant = 0 // Nothing to do, or every change was applied
+	reconcileExitDrift     = 1 // Drift left in place by a resource in dry-run mode
+	reconcileExitFailed    = 2 // A resource failed, or the state couldn't be loaded
+)
+
+// oneshotReport is the JSON document printed by the reconcile subcommand
+type oneshotReport struct {
+	RunID   string          `json:"run_id,omitempty"`
+	Mode    string          `json:"mode"`
+	Summary oneshotSummary  `json:"summary"`
+	Results []oneshotResult `json:"results"`
+}
+
+type oneshotSummary struct {
+	Total     int `json:"total"`
+	Compliant int `json:"compliant"`
+	Changed   int `json:"changed"` // Drifted where dry-run, fixed where enforced
+	Failed    int `json:"failed"`
+}
+
+type oneshotResult struct {
+	Type       string `json:"type"`
+	Name       string `json:"name"`
+	Compliant  bool   `json:"compliant"`
+	Action     string `json:"action,omitempty"`
+	Diff       string `json:"diff,omitempty"` // Planned file content change in dry-run
+	Error      string `json:"error,omitempty"`
+	Stack      string `json:"stack,omitempty"` // Recovered panic
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// setupSubcommandLogging discards logs unless -v is set, since subcommands
+// print their own output on stdout
+func setupSubcommandLogging(opts logging.Options, verbose bool) error {
+	out := io.Writer(os.Stderr)
+	if !verbose {
+		out = io.Discard
+	}
+	return logging.Setup(out, opts)
+}
+
+// runReconcile runs a single reconcile pass against local state files and
+// exits, without watchers, the metrics server or a server connection. It is
+// meant for CI smoke tests and cron-driven enforcement.
+func runReconcile(args []string) int {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	var stateConfigs stateFiles
+	fs.Var(&stateConfigs, "state-config", "Path to state configuration, \"-\" for stdin or an http(s) URL; repeat to overlay files in order (default /etc/power-edge/state.yaml)")
+	mode := fs.String("reconcile", "dry-run", "Reconciliation mode: dry-run or enforce")
+	workers := fs.Int("workers", runtime.NumCPU(), "Resources of the same type reconciled concurrently")
+	commandTimeout := fs.Duration("command-timeout", apply.DefaultCommandTimeout, "Kill any command run while applying state after this long")
+	sudo := fs.String("sudo", "sudo", "Command that system-changing commands run through, e.g. \"sudo -n\" or doas; empty runs them directly, as root")
+	verbose := fs.Bool("v", false, "Show reconciler logs")
+	var logOpts logging.Options
+	logOpts.AddFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: power-edge-client reconcile [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Runs one reconcile pass, prints the results as JSON and exits.\n")
+		fmt.Fprintf(fs.Output(), "Exit status is 0 if compliant, 1 if drift was found in dry-run mode and 2 on failure.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if len(stateConfigs) == 0 {
+		stateConfigs = stateFiles{defaultStateConfig}
+	}
+
+	reconMode, err := parseReconcileMode(*mode)
+	if err == nil && reconMode == reconciler.ModeDisabled {
+		err = fmt.Errorf("reconcile mode must be dry-run or enforce")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return reconcileExitFailed
+	}
+
+	state, err := stateConfigs.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to load state: %v\n", err)
+		return reconcileExitFailed
+	}
+
+	if err := setupSubcommandLogging(logOpts, *verbose); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return reconcileExitFailed
+	}
+
+	recon := reconciler.NewReconciler(reconMode)
+	recon.SetWorkers(*workers)
+	recon.SetCommandTimeout(*commandTimeout)
+	recon.SetPrivilegeCommand(strings.Fields(*sudo))
+	if reconMode == reconciler.ModeEnforce {
+		if err := recon.CheckPrivileges(context.Background()); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return reconcileExitFailed
+		}
+	}
+	results, err := recon.ReconcileAll(context.Background(), state)
+
+	report, code := buildOneshotReport(reconMode, results)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if encErr := enc.Encode(report); encErr != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to write results: %v\n", encErr)
+		return reconcileExitFailed
+	}
+
+	// ReconcileAll only fails outright when the pass is aborted, e.g. by the pre-hook
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Reconcile failed: %v\n", err)
+		return reconcileExitFailed
+	}
+	return code
+}
+
+// buildOneshotReport summarizes results and picks the exit code. Whether a
+// change was applied is read from each result rather than the global mode,
+// since per-type overrides and freezes change the mode a resource ran in.
+func buildOneshotReport(mode reconciler.ReconcileMode, results []reconciler.ReconcileResult) (oneshotReport, int) {
+	report := oneshotReport{
+		Mode:    string(mode),
+		Results: make([]oneshotResult, 0, len(results)),
+	}
+	drifted := false
+
+	for _, result := range results {
+		if report.RunID == "" {
+			report.RunID = result.RunID
+		}
+
+		entry := oneshotResult{
+			Type:       result.ResourceType,
+			Name:       result.ResourceName,
+			Compliant:  result.WasCompliant,
+			Action:     result.Action,
+			Diff:       result.Diff,
+			Stack:      result.Stack,
+			DurationMS: result.Duration.Milliseconds(),
+		}
+		switch {
+		case result.Error != nil:
+			entry.Error = result.Error.Error()
+			report.Summary.Failed++
+		case result.WasCompliant:
+			report.Summary.Compliant++
+		default:
+			report.Summary.Changed++
+			drifted = drifted || result.DryRun
+		}
+		report.Results = append(report.Results, entry)
+	}
+	report.Summary.Total = len(results)
+
+	switch {
+	case report.Summary.Failed > 0:
+		return report, reconcileExitFailed
+	case drifted:
+		return report, reconcileExitDrift
+	default:
+		return report, reconcileExitCompliant
+	}
+}
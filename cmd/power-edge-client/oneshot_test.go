@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+	"github.com/power-edge/power-edge/pkg/reconciler"
+)
+
+// reconcileExitCode runs one pass over state like the reconcile subcommand
+// and returns its exit code
+func reconcileExitCode(t *testing.T, mode reconciler.ReconcileMode, state *config.State) int {
+	t.Helper()
+	recon := reconciler.NewReconciler(mode)
+	results, err := recon.ReconcileAll(context.Background(), state)
+	if err != nil {
+		t.Fatalf("ReconcileAll() error = %v", err)
+	}
+	_, code := buildOneshotReport(mode, results)
+	return code
+}
+
+func TestBuildOneshotReport_ModeOverrides(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      reconciler.ReconcileMode
+		overrides config.ReconcileOverrides
+		wantCode  int
+		wantFile  bool
+	}{
+		{
+			name:     "enforce",
+			mode:     reconciler.ModeEnforce,
+			wantCode: reconcileExitCompliant,
+			wantFile: true,
+		},
+		{
+			name:     "dry-run",
+			mode:     reconciler.ModeDryRun,
+			wantCode: reconcileExitDrift,
+		},
+		{
+			name:      "enforce with files in dry-run",
+			mode:      reconciler.ModeEnforce,
+			overrides: config.ReconcileOverrides{Files: config.ReconcileModeDryRun},
+			wantCode:  reconcileExitDrift,
+		},
+		{
+			name:      "dry-run with files enforced",
+			mode:      reconciler.ModeDryRun,
+			overrides: config.ReconcileOverrides{Files: config.ReconcileModeEnforce},
+			wantCode:  reconcileExitCompliant,
+			wantFile:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.conf")
+			state := &config.State{
+				Reconcile: tt.overrides,
+				Files:     []config.FileConfig{{Path: config.UnixPath(path), Content: "app"}},
+			}
+
+			if code := reconcileExitCode(t, tt.mode, state); code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d", code, tt.wantCode)
+			}
+			if _, err := os.Stat(path); (err == nil) != tt.wantFile {
+				t.Errorf("file written = %v, want %v", err == nil, tt.wantFile)
+			}
+		})
+	}
+}
+
+func TestBuildOneshotReport_Frozen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.conf")
+	state := &config.State{
+		Freeze: config.FreezeConfig{Enabled: true, Reason: "release"},
+		Files:  []config.FileConfig{{Path: config.UnixPath(path), Content: "app"}},
+	}
+
+	// The freeze downgrades enforce to dry-run, so the drift is still there
+	if code := reconcileExitCode(t, reconciler.ModeEnforce, state); code != reconcileExitDrift {
+		t.Errorf("exit code = %d, want %d", code, reconcileExitDrift)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("file written during a freeze")
+	}
+}
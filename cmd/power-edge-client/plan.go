@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/power-edge/power-edge/pkg/apply"
+	"github.com/power-edge/power-edge/pkg/logging"
+	"github.com/power-edge/power-edge/pkg/reconciler"
+)
+
+// planDocument is the JSON document printed by the plan subcommand
+type planDocument struct {
+	Node    string                     `json:"node"`
+	Actions []reconciler.PlannedAction `json:"actions"`
+}
+
+// runPlan prints the ordered actions enforcing the local state would take, as
+// one reviewable document rather than interleaved dry-run logs. Exit codes
+// follow the diff subcommand.
+func runPlan(args []string) int {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	var stateConfigs stateFiles
+	fs.Var(&stateConfigs, "state-config", "Path to state configuration, \"-\" for stdin or an http(s) URL; repeat to overlay files in order (default /etc/power-edge/state.yaml)")
+	output := fs.String("o", "table", "Output format: table or json")
+	commandTimeout := fs.Duration("command-timeout", apply.DefaultCommandTimeout, "Kill any command run while checking state after this long")
+	verbose := fs.Bool("v", false, "Show reconciler logs")
+	var logOpts logging.Options
+	logOpts.AddFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: power-edge-client plan [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Prints the ordered actions enforcing the state would take, without running them.\n")
+		fmt.Fprintf(fs.Output(), "Exit status is 0 if compliant, 2 if changes are pending and 1 on error.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "❌ Unknown output format %q (want table or json)\n", *output)
+		return diffExitError
+	}
+	if len(stateConfigs) == 0 {
+		stateConfigs = stateFiles{defaultStateConfig}
+	}
+
+	state, err := stateConfigs.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to load state: %v\n", err)
+		return diffExitError
+	}
+
+	if err := setupSubcommandLogging(logOpts, *verbose); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return diffExitError
+	}
+
+	recon := reconciler.NewReconciler(reconciler.ModeEnforce)
+	recon.SetCommandTimeout(*commandTimeout)
+	plan, planErr := recon.Plan(context.Background(), state)
+
+	doc := planDocument{Node: getHostname(), Actions: plan}
+	if doc.Actions == nil {
+		doc.Actions = []reconciler.PlannedAction{}
+	}
+	if *output == "json" {
+		err = writePlanJSON(os.Stdout, doc)
+	} else {
+		err = writePlanTable(os.Stdout, doc)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to write plan: %v\n", err)
+		return diffExitError
+	}
+
+	switch {
+	case planErr != nil:
+		fmt.Fprintf(os.Stderr, "❌ Plan is incomplete: %v\n", planErr)
+		return diffExitError
+	case len(plan) > 0:
+		return diffExitChanges
+	default:
+		return diffExitNoChanges
+	}
+}
+
+// writePlanJSON prints doc as indented JSON
+func writePlanJSON(w io.Writer, doc any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// writePlanTable prints the plan as an aligned table, one action per row
+func writePlanTable(w io.Writer, doc planDocument) error {
+	if len(doc.Actions) == 0 {
+		_, err := fmt.Fprintf(w, "No changes planned for %s.\n", doc.Node)
+		return err
+	}
+
+	fmt.Fprintf(w, "Plan for %s:\n\n", doc.Node)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tTYPE\tNAME\tACTION\tREASON")
+	for _, a := range doc.Actions {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", a.Step, a.Type, a.Name, a.Action, a.Reason)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d actions planned.\n", len(doc.Actions))
+	return err
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/power-edge/power-edge/pkg/apply"
+	"github.com/power-edge/power-edge/pkg/logging"
+	"github.com/power-edge/power-edge/pkg/reconciler"
+)
+
+// preflightDocument is the JSON document printed by the preflight subcommand
+type preflightDocument struct {
+	Node     string                        `json:"node"`
+	Problems []reconciler.PreflightProblem `json:"problems"`
+}
+
+// runPreflight prints the planned actions that are predicted to fail, e.g.
+// because a package name doesn't resolve, before a node is switched to
+// enforce. Exit status is 0 if nothing is predicted to fail, 2 if something
+// is and 1 on error.
+func runPreflight(args []string) int {
+	fs := flag.NewFlagSet("preflight", flag.ExitOnError)
+	var stateConfigs stateFiles
+	fs.Var(&stateConfigs, "state-config", "Path to state configuration, \"-\" for stdin or an http(s) URL; repeat to overlay files in order (default /etc/power-edge/state.yaml)")
+	output := fs.String("o", "table", "Output format: table or json")
+	commandTimeout := fs.Duration("command-timeout", apply.DefaultCommandTimeout, "Kill any command run while checking state after this long")
+	sudo := fs.String("sudo", "sudo", "Command that system-changing commands would run through, e.g. \"sudo -n\" or doas; empty runs them directly, as root")
+	verbose := fs.Bool("v", false, "Show reconciler logs")
+	var logOpts logging.Options
+	logOpts.AddFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: power-edge-client preflight [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Checks, without changing anything, whether the actions enforcing the state would take can succeed.\n")
+		fmt.Fprintf(fs.Output(), "Exit status is 0 if none is predicted to fail, 2 if some are and 1 on error.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "❌ Unknown output format %q (want table or json)\n", *output)
+		return diffExitError
+	}
+	if len(stateConfigs) == 0 {
+		stateConfigs = stateFiles{defaultStateConfig}
+	}
+
+	state, err := stateConfigs.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to load state: %v\n", err)
+		return diffExitError
+	}
+
+	if err := setupSubcommandLogging(logOpts, *verbose); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return diffExitError
+	}
+
+	recon := reconciler.NewReconciler(reconciler.ModeEnforce)
+	recon.SetCommandTimeout(*commandTimeout)
+	recon.SetPrivilegeCommand(strings.Fields(*sudo))
+	problems, err := recon.Preflight(context.Background(), state)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Preflight failed: %v\n", err)
+		return diffExitError
+	}
+
+	doc := preflightDocument{Node: getHostname(), Problems: problems}
+	if doc.Problems == nil {
+		doc.Problems = []reconciler.PreflightProblem{}
+	}
+	if *output == "json" {
+		err = writePlanJSON(os.Stdout, doc)
+	} else {
+		err = writePreflightTable(os.Stdout, doc)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to write preflight report: %v\n", err)
+		return diffExitError
+	}
+
+	if len(problems) > 0 {
+		return diffExitChanges
+	}
+	return diffExitNoChanges
+}
+
+// writePreflightTable prints one row per resource predicted to fail
+func writePreflightTable(w io.Writer, doc preflightDocument) error {
+	if len(doc.Problems) == 0 {
+		_, err := fmt.Fprintf(w, "No planned actions on %s are predicted to fail.\n", doc.Node)
+		return err
+	}
+
+	fmt.Fprintf(w, "Predicted failures on %s:\n\n", doc.Node)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tNAME\tACTIONS\tPROBLEM")
+	for _, p := range doc.Problems {
+		actions := strings.Join(p.Actions, "; ")
+		if actions == "" {
+			actions = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.Type, p.Name, actions, p.Problem)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d resources predicted to fail.\n", len(doc.Problems))
+	return err
+}
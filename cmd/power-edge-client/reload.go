@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"sync"
+
+	"github.com/power-edge/power-edge/pkg/config"
+	"github.com/power-edge/power-edge/pkg/reconciler"
+	"github.com/power-edge/power-edge/pkg/watcher"
+)
+
+// watcherHandle holds the running event watcher, which is replaced on reload
+type watcherHandle struct {
+	mu      sync.Mutex
+	watcher *watcher.EventWatcher
+}
+
+// Get returns the running watcher, or nil if watchers are disabled
+func (h *watcherHandle) Get() *watcher.EventWatcher {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.watcher
+}
+
+// Set replaces the running watcher
+func (h *watcherHandle) Set(w *watcher.EventWatcher) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.watcher = w
+}
+
+// parseReconcileMode converts a --reconcile value to a reconciler mode
+func parseReconcileMode(mode string) (reconciler.ReconcileMode, error) {
+	switch mode {
+	case "enforce":
+		return reconciler.ModeEnforce, nil
+	case "dry-run":
+		return reconciler.ModeDryRun, nil
+	case "disabled", "":
+		return reconciler.ModeDisabled, nil
+	default:
+		return reconciler.ModeDisabled, fmt.Errorf("unknown reconcile mode %q (want disabled, dry-run or enforce)", mode)
+	}
+}
+
+// startWatcher starts event watchers for cfg, or returns nil if they are disabled
+func startWatcher(cfg *config.WatcherConfig, recon *reconciler.Reconciler, state *config.State, events *eventBroker) (*watcher.EventWatcher, error) {
+	if !cfg.Watchers.Enabled {
+		return nil, nil
+	}
+	w := watcher.NewEventWatcher(cfg, recon, state)
+	w.SetObserver(func(event watcher.Event) { events.Publish("watcher", event) })
+	if err := w.Start(context.Background()); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// requestReconcile schedules an immediate check without blocking; a pending
+// request already covers any new one
+func requestReconcile(ch chan<- struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// reloader re-reads configuration on SIGHUP and swaps it into the running
+// agent, leaving the HTTP server and metrics untouched. The reconcile mode is
+// re-read from the watcher config's reconcile setting, and only applied when
+// that setting changed, so a switch made through /admin/mode survives
+// reloads that don't touch it.
+type reloader struct {
+	stateConfigs  stateFiles
+	watcherConfig string
+	serverURL     string
+	nodeID        string
+
+	recon        *reconciler.Reconciler
+	states       *stateHolder
+	watchers     *watcherHandle
+	watcherCfg   *config.WatcherConfig // Config the running watchers were started with
+	flagMode     reconciler.ReconcileMode
+	configMode   config.ReconcileMode // Watcher config reconcile setting at the last load
+	events       *eventBroker
+	stateChanged chan<- struct{}
+}
+
+// reload loads and validates the new configuration, then applies it. If
+// anything fails to load or validate, the running configuration is kept.
+func (rl *reloader) reload() {
+	log.Println("🔄 Reloading configuration...")
+
+	watcherCfg, err := config.LoadWatcherConfig(rl.watcherConfig)
+	if err == nil && watcherCfg.Version == "" {
+		err = fmt.Errorf("missing version")
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("   ❌ Invalid watcher config %s, keeping current config: %v", rl.watcherConfig, err))
+		return
+	}
+
+	mode, err := rl.mode(watcherCfg.Reconcile)
+	if err != nil {
+		slog.Error(fmt.Sprintf("   ❌ Invalid reconcile in watcher config %s, keeping current config: %v", rl.watcherConfig, err))
+		return
+	}
+
+	// State the server reports unchanged was validated when first fetched
+	state, err := loadState(rl.serverURL, rl.nodeID, rl.stateConfigs)
+	unchanged := errors.Is(err, errStateNotModified) && state == rl.states.Get()
+	if errors.Is(err, errStateNotModified) {
+		err = nil
+	} else if err == nil {
+		err = state.Validate()
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("   ❌ Invalid state, keeping current config: %v", err))
+		return
+	}
+
+	// Everything loaded; swap it in
+	modeChanged := mode != rl.recon.GetMode()
+	rl.recon.SetMode(mode)
+	rl.configMode = watcherCfg.Reconcile
+	rl.states.Set(state)
+
+	if old := rl.watchers.Get(); old != nil {
+		if err := old.Stop(); err != nil {
+			slog.Warn(fmt.Sprintf("   ⚠️  Watcher shutdown error: %v", err))
+		}
+		rl.watchers.Set(nil)
+	}
+	w, err := startWatcher(watcherCfg, rl.recon, state, rl.events)
+	if err != nil {
+		slog.Error(fmt.Sprintf("   ❌ Failed to start watchers with new config, restoring previous: %v", err))
+		w, err = startWatcher(rl.watcherCfg, rl.recon, state, rl.events)
+		if err != nil {
+			slog.Error(fmt.Sprintf("   ❌ Failed to restart previous watchers: %v", err))
+		}
+	} else {
+		rl.watcherCfg = watcherCfg
+	}
+	rl.watchers.Set(w)
+
+	log.Printf("   ✅ Reloaded (mode: %s, watchers enabled: %v, revision: %s)", mode, w != nil, state.Revision())
+	if unchanged && !modeChanged {
+		log.Println("   ⏭️  State unchanged on server, skipping reconcile")
+		return
+	}
+	requestReconcile(rl.stateChanged)
+}
+
+// loadState fetches the node's state from the server, falling back to the
+// local files when no server is configured or it can't be reached. State the
+// server reports unchanged comes back with errStateNotModified.
+func loadState(serverURL, nodeID string, files stateFiles) (*config.State, error) {
+	if serverURL != "" {
+		state, err := fetchStateFromServer(context.Background(), serverURL, nodeID)
+		if errors.Is(err, errStateNotModified) {
+			return state, err // The local file already holds it
+		}
+		if err == nil {
+			if err := files.save(state); err != nil {
+				slog.Warn(fmt.Sprintf("   ⚠️  Failed to save state to local file: %v", err))
+			}
+			return state, nil
+		}
+		slog.Warn(fmt.Sprintf("   ⚠️  Failed to fetch from server, using local file: %v", err))
+	}
+	return files.load()
+}
+
+// mode returns the reconcile mode a reload leaves the agent in. A reconcile
+// setting that changed since the last load is applied, falling back to
+// -reconcile when it was removed; otherwise the current mode is kept.
+func (rl *reloader) mode(setting config.ReconcileMode) (reconciler.ReconcileMode, error) {
+	switch {
+	case setting == rl.configMode:
+		return rl.recon.GetMode(), nil
+	case setting == "":
+		return rl.flagMode, nil
+	default:
+		return parseReconcileMode(string(setting))
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+	"github.com/power-edge/power-edge/pkg/reconciler"
+)
+
+func TestReloaderMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		configMode config.ReconcileMode // Setting at the last load
+		setting    config.ReconcileMode // Setting now
+		want       reconciler.ReconcileMode
+		wantErr    bool
+	}{
+		{name: "unset keeps the runtime mode", want: reconciler.ModeEnforce},
+		{name: "unchanged keeps the runtime mode", configMode: "dry-run", setting: "dry-run", want: reconciler.ModeEnforce},
+		{name: "changed is applied", configMode: "dry-run", setting: "disabled", want: reconciler.ModeDisabled},
+		{name: "added is applied", setting: "dry-run", want: reconciler.ModeDryRun},
+		{name: "removed falls back to -reconcile", configMode: "disabled", want: reconciler.ModeDryRun},
+		{name: "invalid", setting: "enfoce", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Started with -reconcile=dry-run, then switched through /admin/mode
+			rl := &reloader{
+				recon:      reconciler.NewReconciler(reconciler.ModeEnforce),
+				flagMode:   reconciler.ModeDryRun,
+				configMode: tt.configMode,
+			}
+			got, err := rl.mode(tt.setting)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("mode() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// stdinSource is the -state-config value that reads state from stdin
+const stdinSource = "-"
+
+// stateURLTimeout bounds fetching a -state-config URL
+const stateURLTimeout = 30 * time.Second
+
+// stdinState holds stdin once read: it can only be read once, and a reload
+// re-reads every source
+var stdinState struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// isStateURL reports whether source is fetched over HTTP rather than read
+// from disk. These are plain GETs, e.g. from an object store, not the
+// power-edge-server API.
+func isStateURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// isLocalState reports whether source is a file on disk
+func isLocalState(source string) bool {
+	return source != stdinSource && !isStateURL(source)
+}
+
+// readStateSource returns the raw state document source refers to: a file
+// path, "-" for stdin, or an http(s) URL
+func readStateSource(source string) ([]byte, error) {
+	switch {
+	case source == stdinSource:
+		stdinState.once.Do(func() {
+			stdinState.data, stdinState.err = io.ReadAll(os.Stdin)
+		})
+		if stdinState.err != nil {
+			return nil, fmt.Errorf("read stdin: %w", stdinState.err)
+		}
+		return stdinState.data, nil
+	case isStateURL(source):
+		return fetchStateURL(source)
+	default:
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("read file: %w", err)
+		}
+		return data, nil
+	}
+}
+
+// fetchStateURL downloads a state document with a plain GET
+func fetchStateURL(url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), stateURLTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch state: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch state: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+	return data, nil
+}
+
+// loadStateSources parses each source and merges them in order, like
+// config.LoadStateConfigs, then validates the result
+func loadStateSources(sources ...string) (*config.State, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no state files given")
+	}
+
+	var merged *config.State
+	for _, source := range sources {
+		data, err := readStateSource(source)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		state, err := config.ParseState(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		merged = config.Merge(merged, state)
+	}
+
+	if err := merged.Validate(); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// stateHolder shares the current desired state between the check loop, the
+// status endpoint and the server state watcher
+type stateHolder struct {
+	mu    sync.RWMutex
+	state *config.State
+}
+
+func newStateHolder(state *config.State) *stateHolder {
+	return &stateHolder{state: state}
+}
+
+// Get returns the current desired state
+func (h *stateHolder) Get() *config.State {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.state
+}
+
+// Set replaces the desired state
+func (h *stateHolder) Set(state *config.State) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.state = state
+}
+
+// watchServerState long-polls the server for state changes and calls
+// onChange with each new state until ctx is cancelled
+func watchServerState(ctx context.Context, serverURL, nodeID string, wait time.Duration, states *stateHolder, onChange func(*config.State)) {
+	const errorBackoff = 5 * time.Second
+
+	for {
+		state, err := waitForStateChange(ctx, serverURL, nodeID, states.Get().Revision(), wait)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Warn(fmt.Sprintf("⚠️  Failed to watch state on server: %v", err))
+			select {
+			case <-time.After(errorBackoff):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		if state == nil {
+			continue // Timed out without a change
+		}
+
+		log.Printf("📥 State changed on server (revision %s)", state.Revision())
+		onChange(state)
+	}
+}
+
+// waitForStateChange blocks on the server's long-poll endpoint. It returns
+// the new state, or nil if nothing changed before wait elapsed.
+func waitForStateChange(ctx context.Context, serverURL, nodeID, revision string, wait time.Duration) (*config.State, error) {
+	query := url.Values{"wait": {wait.String()}}
+	if revision != "" {
+		query.Set("revision", revision)
+	}
+	endpoint := fmt.Sprintf("%s/api/v1/nodes/%s/state?%s", serverURL, nodeID, query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := pollClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to poll state: %w", describeTLSError(err))
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, nil
+	case http.StatusOK:
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var state config.State
+	if err := yaml.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return nil, fmt.Errorf("failed to decode state: %w", err)
+	}
+
+	return &state, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// streamBuffer is how many messages a slow subscriber may fall behind
+	// before messages for it are dropped
+	streamBuffer = 256
+	// streamKeepalive is how often an idle stream gets a comment, so proxies
+	// don't time it out
+	streamKeepalive = 15 * time.Second
+)
+
+// streamDropped counts messages dropped because a subscriber's buffer was full
+var streamDropped = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "power_edge_event_stream_dropped_total",
+	Help: "Messages for /events/stream subscribers dropped because the subscriber fell behind",
+})
+
+// streamMessage is one Server-Sent Event
+type streamMessage struct {
+	event string
+	data  []byte
+}
+
+// eventBroker fans reconcile results and watcher events out to the
+// subscribers of /events/stream. Publishing never blocks: a subscriber that
+// falls behind loses messages instead of holding up reconciliation.
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan streamMessage]struct{}
+	closed      bool
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subscribers: make(map[chan streamMessage]struct{})}
+}
+
+// Publish sends v, as JSON, to every subscriber as an event of the given kind
+func (b *eventBroker) Publish(event string, v interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.subscribers) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("⚠️  Failed to encode %s for the event stream: %v", event, err))
+		return
+	}
+	msg := streamMessage{event: event, data: data}
+	for ch := range b.subscribers {
+		select {
+		case ch <- msg:
+		default:
+			streamDropped.Inc()
+		}
+	}
+}
+
+// subscribe returns a channel receiving every message published from now
+// on. It is closed by unsubscribe or Close; nil means the broker is closed.
+func (b *eventBroker) subscribe() chan streamMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	ch := make(chan streamMessage, streamBuffer)
+	b.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (b *eventBroker) unsubscribe(ch chan streamMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Close ends every stream, so shutdown doesn't wait for subscribers to
+// disconnect
+func (b *eventBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// eventStreamHandler serves GET /events/stream: reconcile results as
+// "result" events and watcher events as "watcher" events, each with a JSON
+// payload, for as long as the client stays connected
+func eventStreamHandler(b *eventBroker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ch := b.subscribe()
+		if ch == nil {
+			http.Error(w, "Shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		defer b.unsubscribe(ch)
+
+		// The server's write timeout is meant for ordinary requests
+		rc := http.NewResponseController(w)
+		rc.SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		keepalive := time.NewTicker(streamKeepalive)
+		defer keepalive.Stop()
+		for {
+			var err error
+			select {
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.event, msg.data)
+			case <-keepalive.C:
+				_, err = fmt.Fprint(w, ": keepalive\n\n")
+			case <-r.Context().Done():
+				return
+			}
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
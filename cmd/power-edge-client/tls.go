@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// defaultServerTimeout bounds each ordinary request to the server
+const defaultServerTimeout = 30 * time.Second
+
+// pollGrace is how much longer than the wait it asks for a long-poll request
+// may take by default, covering the server's response and the network
+const pollGrace = 15 * time.Second
+
+// apiClient is used for ordinary requests to the power-edge-server, and
+// pollClient for long-polls, which outlast them by design. Both are replaced
+// in main from the TLS and timeout options.
+var (
+	apiClient  = &http.Client{Timeout: defaultServerTimeout}
+	pollClient = &http.Client{Timeout: 30*time.Second + pollGrace}
+)
+
+// newAPIClient builds the HTTP client used to talk to the server. caCert adds
+// a PEM bundle to the system roots; insecure disables verification entirely.
+// timeout bounds each request as a whole, including reading the body.
+func newAPIClient(caCert string, insecure bool, timeout time.Duration) (*http.Client, error) {
+	if caCert == "" && !insecure {
+		return &http.Client{Timeout: timeout}, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecure,
+	}
+
+	if caCert != "" {
+		pem, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caCert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
+
+// withTimeout returns a copy of client with a different total timeout,
+// sharing its transport and so its TLS settings and connections
+func withTimeout(client *http.Client, timeout time.Duration) *http.Client {
+	copied := *client
+	copied.Timeout = timeout
+	return &copied
+}
+
+// describeTLSError turns certificate and handshake failures into an error that
+// says what went wrong, instead of a generic connection failure
+func describeTLSError(err error) error {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		verification     *tls.CertificateVerificationError
+		recordHeader     tls.RecordHeaderError
+	)
+
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return fmt.Errorf("TLS handshake failed: server certificate is signed by an unknown authority (use --ca-cert): %w", err)
+	case errors.As(err, &hostname):
+		return fmt.Errorf("TLS handshake failed: server certificate does not match host %q: %w", hostname.Host, err)
+	case errors.As(err, &invalid):
+		return fmt.Errorf("TLS handshake failed: server certificate is invalid: %w", err)
+	case errors.As(err, &verification):
+		return fmt.Errorf("TLS handshake failed: could not verify server certificate: %w", err)
+	case errors.As(err, &recordHeader):
+		return fmt.Errorf("TLS handshake failed: server does not appear to speak TLS (is it running without --tls-cert?): %w", err)
+	}
+	return err
+}
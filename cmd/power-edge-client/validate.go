@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// runValidate checks state files against the bundled schema without touching
+// the system or contacting a server. Overlays are checked one by one for
+// unknown fields and types, and the merged result against the full schema.
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	var stateConfigs stateFiles
+	fs.Var(&stateConfigs, "state-config", "Path to state configuration, \"-\" for stdin or an http(s) URL; repeat to overlay files in order (default /etc/power-edge/state.yaml)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: power-edge-client validate [-state-config <file>]...\n\n")
+		fmt.Fprintf(fs.Output(), "Validates state files against the schema. Exit status is 0 if valid and 1 otherwise.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if len(stateConfigs) == 0 {
+		stateConfigs = stateFiles{defaultStateConfig}
+	}
+
+	var merged *config.State
+	for _, path := range stateConfigs {
+		data, err := readStateSource(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s: %v\n", path, err)
+			return 1
+		}
+		state, err := config.ParseStateStrict(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s: %v\n", path, err)
+			return 1
+		}
+		merged = config.Merge(merged, state)
+	}
+
+	if err := merged.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s: %v\n", stateConfigs.String(), err)
+		return 1
+	}
+
+	fmt.Printf("✓ %s: valid\n", stateConfigs.String())
+	return 0
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/power-edge/power-edge/pkg/apply"
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// collectVersions gathers what the node is running: kernel, OS release,
+// agent version and the installed version of every managed package
+func collectVersions(ctx context.Context, state *config.State, packages *apply.PackageApplier) config.SystemVersions {
+	release := readOSRelease()
+	versions := config.SystemVersions{
+		CollectedAt:  time.Now().UTC().Format(time.RFC3339),
+		Kernel:       getKernel(),
+		OSID:         release["ID"],
+		OSVersion:    release["VERSION_ID"],
+		OSPrettyName: release["PRETTY_NAME"],
+		Agent:        Version,
+	}
+
+	if state == nil || len(state.Packages) == 0 {
+		return versions
+	}
+	versions.Packages = make(map[string]string, len(state.Packages))
+	for _, pkg := range state.Packages {
+		installed, version, _, err := packages.Check(ctx, pkg.Name)
+		if err != nil || !installed {
+			continue
+		}
+		versions.Packages[pkg.Name] = version
+	}
+	return versions
+}
+
+// readOSRelease parses /etc/os-release into its KEY=value pairs
+func readOSRelease() map[string]string {
+	release := make(map[string]string)
+	data, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		return release
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		release[key] = strings.Trim(value, `"'`)
+	}
+	return release
+}
+
+// pushVersions uploads the node's system versions to the power-edge-server
+func pushVersions(ctx context.Context, serverURL, nodeID string, versions config.SystemVersions) error {
+	url := fmt.Sprintf("%s/api/v1/nodes/%s/versions", serverURL, nodeID)
+
+	body, err := json.Marshal(versions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal versions: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return describeTLSError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
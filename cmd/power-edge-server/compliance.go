@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// unassignedSite groups nodes whose state has no metadata.site
+const unassignedSite = "unassigned"
+
+// ComplianceCounts counts nodes by compliance. Offline nodes, whose last
+// report may be stale, are only counted as offline; unknown nodes reported
+// an error instead of a compliance summary.
+type ComplianceCounts struct {
+	Total     int `json:"total"`
+	Compliant int `json:"compliant"`
+	Drifted   int `json:"drifted"`
+	Offline   int `json:"offline"`
+	Unknown   int `json:"unknown"`
+}
+
+// FleetCompliance is the fleet-wide compliance summary, overall and per site
+type FleetCompliance struct {
+	Timestamp time.Time `json:"timestamp"`
+	ComplianceCounts
+	BySite map[string]ComplianceCounts `json:"by_site"`
+}
+
+func (c *ComplianceCounts) add(status string) {
+	c.Total++
+	switch status {
+	case "compliant":
+		c.Compliant++
+	case "drifted":
+		c.Drifted++
+	case "offline":
+		c.Offline++
+	default:
+		c.Unknown++
+	}
+}
+
+// scanNodeIDs calls fn with the ID of every node that has a key ending in
+// suffix. It walks the keyspace with SCAN rather than KEYS, so large fleets
+// don't block Redis.
+func (s *Server) scanNodeIDs(ctx context.Context, suffix string, fn func(nodeID string) error) error {
+	pattern := fmt.Sprintf("%s:nodes:*:%s", s.version, suffix)
+
+	iter := s.redis.Scan(ctx, 0, pattern, 0).Iterator()
+	for iter.Next(ctx) {
+		// Extract node ID from key: v1:nodes:{node-id}:{suffix}
+		parts := strings.Split(iter.Val(), ":")
+		if len(parts) < 3 {
+			continue
+		}
+		if err := fn(parts[2]); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+// complianceSummaryHandler aggregates every node's compliance report, so a
+// dashboard can show fleet health with one call
+func (s *Server) complianceSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	summary := FleetCompliance{
+		Timestamp: time.Now().UTC(),
+		BySite:    map[string]ComplianceCounts{},
+	}
+
+	err := s.scanNodeIDs(ctx, "compliance", func(nodeID string) error {
+		status, err := s.nodeComplianceStatus(ctx, nodeID)
+		if err != nil {
+			return err
+		}
+		site, err := s.nodeSite(ctx, nodeID)
+		if err != nil {
+			return err
+		}
+
+		summary.add(status)
+		counts := summary.BySite[site]
+		counts.add(status)
+		summary.BySite[site] = counts
+		return nil
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to summarize compliance: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// nodeComplianceStatus classifies a node as compliant, drifted, offline or
+// unknown from its heartbeat and last compliance report
+func (s *Server) nodeComplianceStatus(ctx context.Context, nodeID string) (string, error) {
+	// The heartbeat key expires after the TTL, so existence means online
+	online, err := s.redis.Exists(ctx, s.NodeHeartbeatKey(nodeID)).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to check heartbeat: %w", err)
+	}
+	if online == 0 {
+		return "offline", nil
+	}
+
+	data, err := s.get(ctx, s.NodeComplianceKey(nodeID))
+	if err == redis.Nil {
+		// Deleted since the scan saw it
+		return "unknown", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get compliance for %s: %w", nodeID, err)
+	}
+
+	var report struct {
+		Total   *int   `json:"total"`
+		Drifted int    `json:"drifted"`
+		Errors  int    `json:"errors"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil || report.Error != "" || report.Total == nil {
+		return "unknown", nil
+	}
+	if report.Drifted > 0 || report.Errors > 0 {
+		return "drifted", nil
+	}
+	return "compliant", nil
+}
+
+// nodeSite returns the site from the node's state metadata, which may come
+// from one of its profiles
+func (s *Server) nodeSite(ctx context.Context, nodeID string) (string, error) {
+	data, err := s.renderNodeState(ctx, nodeID)
+	if err == redis.Nil {
+		return unassignedSite, nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get state for %s: %w", nodeID, err)
+	}
+
+	var state config.State
+	if err := yaml.Unmarshal(data, &state); err != nil || state.Metadata.Site == "" {
+		return unassignedSite, nil
+	}
+	return state.Metadata.Site, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// minCompressSize is the smallest response body worth compressing; below it
+// the encoding overhead outweighs the savings
+const minCompressSize = 1024
+
+// compress encodes response bodies with gzip or deflate when the client
+// accepts it. Bodies under minCompressSize, bodiless responses and ones the
+// handler already encoded, like gzipped metrics, are sent as they are.
+func compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedEncoding picks gzip, or failing that deflate, from an
+// Accept-Encoding header, or returns "" if the client accepts neither
+func acceptedEncoding(header string) string {
+	// A coding listed with q=0 is refused, even if * accepts everything
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		accepted[coding] = true
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				accepted[coding] = false
+			}
+		}
+	}
+
+	for _, coding := range []string{"gzip", "deflate"} {
+		ok, listed := accepted[coding]
+		if ok || !listed && accepted["*"] {
+			return coding
+		}
+	}
+	return ""
+}
+
+// compressWriter holds back the status and the start of the body until it
+// knows whether the body is large enough to compress
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int            // Status to send once the encoding is decided
+	buf      []byte         // Body written before the encoding is decided
+	decided  bool           // Whether the status has been sent
+	enc      io.WriteCloser // Compressor, if the body is being compressed
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if c.decided {
+		if c.enc != nil {
+			return c.enc.Write(p)
+		}
+		return c.ResponseWriter.Write(p)
+	}
+
+	c.buf = append(c.buf, p...)
+	if len(c.buf) >= minCompressSize {
+		if err := c.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// start sends the status and the held back body, compressed if compress is
+// set and the response allows it
+func (c *compressWriter) start(compress bool) error {
+	c.decided = true
+	header := c.Header()
+	if compress && header.Get("Content-Encoding") == "" && c.status != http.StatusNoContent && c.status != http.StatusNotModified {
+		// Sniffing would otherwise see the compressed bytes
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", http.DetectContentType(c.buf))
+		}
+		header.Set("Content-Encoding", c.encoding)
+		header.Del("Content-Length")
+		if c.encoding == "gzip" {
+			c.enc = gzip.NewWriter(c.ResponseWriter)
+		} else {
+			c.enc = zlib.NewWriter(c.ResponseWriter)
+		}
+	}
+	if c.status != 0 {
+		c.ResponseWriter.WriteHeader(c.status)
+	}
+
+	buf := c.buf
+	c.buf = nil
+	if c.enc != nil {
+		_, err := c.enc.Write(buf)
+		return err
+	}
+	_, err := c.ResponseWriter.Write(buf)
+	return err
+}
+
+// Close sends a body that stayed under minCompressSize as it is, or
+// finishes the compressed stream
+func (c *compressWriter) Close() error {
+	if !c.decided {
+		if err := c.start(false); err != nil {
+			return err
+		}
+	}
+	if c.enc != nil {
+		return c.enc.Close()
+	}
+	return nil
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, which
+// long-polls use to extend their write deadline
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
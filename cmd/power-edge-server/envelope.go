@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envelopeMagic prefixes every sealed payload stored in Redis. Values without
+// it are treated as plaintext so encryption can be enabled on an existing
+// keyspace; they are re-sealed the next time they are written.
+const envelopeMagic = "pe-enc:v1:"
+
+// Envelope encrypts payloads at rest with AES-GCM. Every sealed value records
+// the ID of the key that produced it, so keys can be rotated by adding a new
+// key to the keyring and making it active while older values remain readable.
+type Envelope struct {
+	keys     map[string]cipher.AEAD
+	activeID string
+}
+
+// LoadEnvelope builds an envelope from a keyring. source is a keyring file,
+// or "env:NAME" to read the keyring from the environment variable NAME. Each
+// non-comment line has the form "<key-id>=<base64 key>" with a 16, 24 or 32
+// byte AES key; entries may also be separated by commas, which suits
+// environment variables. activeID selects the key used for new writes; if
+// empty the last key is used.
+func LoadEnvelope(source, activeID string) (*Envelope, error) {
+	if name, ok := strings.CutPrefix(source, "env:"); ok {
+		keyring, set := os.LookupEnv(name)
+		if !set {
+			return nil, fmt.Errorf("keyring environment variable %s is not set", name)
+		}
+		return ParseKeyring(keyring, source, activeID)
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keyring: %w", err)
+	}
+	return ParseKeyring(string(data), source, activeID)
+}
+
+// ParseKeyring builds an envelope from keyring text in the format
+// LoadEnvelope reads. source names where it came from in errors.
+func ParseKeyring(keyring, source, activeID string) (*Envelope, error) {
+	env := &Envelope{keys: make(map[string]cipher.AEAD)}
+	lastID := ""
+
+	for lineNo, line := range strings.Split(keyring, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		for _, entry := range strings.Split(line, ",") {
+			id, encoded, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			id = strings.TrimSpace(id)
+			if !ok || id == "" || strings.Contains(id, ":") {
+				return nil, fmt.Errorf("keyring line %d: expected <key-id>=<base64 key>", lineNo+1)
+			}
+
+			key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+			if err != nil {
+				return nil, fmt.Errorf("keyring line %d: invalid base64 key: %w", lineNo+1, err)
+			}
+
+			if err := env.AddKey(id, key); err != nil {
+				return nil, fmt.Errorf("keyring line %d: %w", lineNo+1, err)
+			}
+			lastID = id
+		}
+	}
+
+	if len(env.keys) == 0 {
+		return nil, fmt.Errorf("keyring %s contains no keys", source)
+	}
+
+	if activeID == "" {
+		activeID = lastID
+	}
+	if _, ok := env.keys[activeID]; !ok {
+		return nil, fmt.Errorf("active key %q not found in keyring", activeID)
+	}
+	env.activeID = activeID
+
+	return env, nil
+}
+
+// AddKey registers an AES key under id
+func (e *Envelope) AddKey(id string, key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("invalid key %q: %w", id, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("invalid key %q: %w", id, err)
+	}
+	e.keys[id] = aead
+	return nil
+}
+
+// ActiveKeyID returns the ID of the key used for new writes
+func (e *Envelope) ActiveKeyID() string {
+	return e.activeID
+}
+
+// Seal encrypts plaintext with the active key. The Redis key the value is
+// stored under is bound as additional data so ciphertexts can't be swapped
+// between nodes or resources.
+//
+// Format: pe-enc:v1:<key-id>:<hex nonce>\n<ciphertext>
+func (e *Envelope) Seal(redisKey string, plaintext []byte) ([]byte, error) {
+	aead := e.keys[e.activeID]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	header := fmt.Sprintf("%s%s:%s\n", envelopeMagic, e.activeID, hex.EncodeToString(nonce))
+	return aead.Seal([]byte(header), nonce, plaintext, []byte(redisKey)), nil
+}
+
+// Open decrypts a value produced by Seal. Plaintext values (written before
+// encryption was enabled) are returned unchanged.
+func (e *Envelope) Open(redisKey string, data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+
+	header, ciphertext, ok := bytes.Cut(data, []byte("\n"))
+	if !ok {
+		return nil, fmt.Errorf("malformed envelope: missing header terminator")
+	}
+
+	id, nonceHex, ok := strings.Cut(strings.TrimPrefix(string(header), envelopeMagic), ":")
+	if !ok {
+		return nil, fmt.Errorf("malformed envelope header")
+	}
+
+	aead, found := e.keys[id]
+	if !found {
+		return nil, fmt.Errorf("unknown encryption key %q", id)
+	}
+
+	nonce, err := hex.DecodeString(nonceHex)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("malformed envelope nonce")
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(redisKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt with key %q: %w", id, err)
+	}
+	return plaintext, nil
+}
+
+// IsSealed reports whether data carries an envelope header
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(envelopeMagic))
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeKeyring writes a keyring file with a fresh key for each ID
+func writeKeyring(t *testing.T, ids ...string) string {
+	t.Helper()
+	var lines []string
+	for i, id := range ids {
+		key := bytes.Repeat([]byte{byte(i + 1)}, 32)
+		lines = append(lines, id+"="+base64.StdEncoding.EncodeToString(key))
+	}
+	path := filepath.Join(t.TempDir(), "keyring")
+	if err := os.WriteFile(path, []byte("# test keyring\n"+strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write keyring: %v", err)
+	}
+	return path
+}
+
+func TestEnvelope_SealOpen(t *testing.T) {
+	env, err := LoadEnvelope(writeKeyring(t, "k1"), "")
+	if err != nil {
+		t.Fatalf("LoadEnvelope() error = %v", err)
+	}
+
+	plaintext := []byte(`{"version":"1.0"}`)
+	sealed, err := env.Seal("node:n1:state", plaintext)
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if !IsSealed(sealed) || bytes.Contains(sealed, plaintext) {
+		t.Fatalf("Seal() = %q, want an envelope hiding the plaintext", sealed)
+	}
+
+	opened, err := env.Open("node:n1:state", sealed)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Errorf("Open() = %q, want %q", opened, plaintext)
+	}
+}
+
+func TestEnvelope_KeyRotation(t *testing.T) {
+	keyring := writeKeyring(t, "old", "new")
+
+	old, err := LoadEnvelope(keyring, "old")
+	if err != nil {
+		t.Fatalf("LoadEnvelope() error = %v", err)
+	}
+	sealed, err := old.Seal("node:n1:state", []byte("before rotation"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	// The last key in the file is active by default
+	rotated, err := LoadEnvelope(keyring, "")
+	if err != nil {
+		t.Fatalf("LoadEnvelope() error = %v", err)
+	}
+	if rotated.ActiveKeyID() != "new" {
+		t.Fatalf("ActiveKeyID() = %q, want new", rotated.ActiveKeyID())
+	}
+
+	opened, err := rotated.Open("node:n1:state", sealed)
+	if err != nil {
+		t.Fatalf("Open() with the old key still in the keyring: %v", err)
+	}
+	if string(opened) != "before rotation" {
+		t.Errorf("Open() = %q, want %q", opened, "before rotation")
+	}
+
+	resealed, err := rotated.Seal("node:n1:state", opened)
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if !bytes.HasPrefix(resealed, []byte(envelopeMagic+"new:")) {
+		t.Errorf("Seal() after rotation = %q, want it sealed with the new key", resealed)
+	}
+
+	// Dropping the old key from the keyring makes its values unreadable
+	newOnly, err := LoadEnvelope(writeKeyring(t, "new"), "")
+	if err != nil {
+		t.Fatalf("LoadEnvelope() error = %v", err)
+	}
+	if _, err := newOnly.Open("node:n1:state", sealed); err == nil || !strings.Contains(err.Error(), `unknown encryption key "old"`) {
+		t.Errorf("Open() without the old key error = %v, want unknown key", err)
+	}
+}
+
+func TestEnvelope_AADMismatch(t *testing.T) {
+	env, err := LoadEnvelope(writeKeyring(t, "k1"), "")
+	if err != nil {
+		t.Fatalf("LoadEnvelope() error = %v", err)
+	}
+	sealed, err := env.Seal("node:n1:state", []byte("n1 state"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	// A value copied under another Redis key must not decrypt
+	if _, err := env.Open("node:n2:state", sealed); err == nil {
+		t.Error("Open() under another Redis key succeeded, want an error")
+	}
+}
+
+func TestEnvelope_Tampered(t *testing.T) {
+	env, err := LoadEnvelope(writeKeyring(t, "k1"), "")
+	if err != nil {
+		t.Fatalf("LoadEnvelope() error = %v", err)
+	}
+	sealed, err := env.Seal("node:n1:state", []byte("n1 state"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 0xff
+	if _, err := env.Open("node:n1:state", tampered); err == nil {
+		t.Error("Open() of a tampered ciphertext succeeded, want an error")
+	}
+	if _, err := env.Open("node:n1:state", []byte(envelopeMagic+"k1:zz\nxx")); err == nil {
+		t.Error("Open() of a malformed nonce succeeded, want an error")
+	}
+}
+
+func TestEnvelope_PlaintextPassthrough(t *testing.T) {
+	env, err := LoadEnvelope(writeKeyring(t, "k1"), "")
+	if err != nil {
+		t.Fatalf("LoadEnvelope() error = %v", err)
+	}
+
+	// Values written before encryption was enabled are read as they are
+	plaintext := []byte(`{"version":"1.0"}`)
+	if IsSealed(plaintext) {
+		t.Fatal("IsSealed() = true for plaintext")
+	}
+	opened, err := env.Open("node:n1:state", plaintext)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Errorf("Open() = %q, want the plaintext unchanged", opened)
+	}
+}
+
+func TestLoadEnvelope_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		keyring  string
+		activeID string
+		wantErr  string
+	}{
+		{"empty", "# no keys\n", "", "contains no keys"},
+		{"missing separator", "k1\n", "", "expected <key-id>=<base64 key>"},
+		{"colon in id", "a:b=" + base64.StdEncoding.EncodeToString(make([]byte, 32)) + "\n", "", "expected <key-id>=<base64 key>"},
+		{"bad base64", "k1=not base64!\n", "", "invalid base64 key"},
+		{"bad key size", "k1=" + base64.StdEncoding.EncodeToString(make([]byte, 10)) + "\n", "", "invalid key"},
+		{"unknown active key", "k1=" + base64.StdEncoding.EncodeToString(make([]byte, 32)) + "\n", "k2", `active key "k2" not found`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keyring")
+			if err := os.WriteFile(path, []byte(tt.keyring), 0600); err != nil {
+				t.Fatalf("Failed to write keyring: %v", err)
+			}
+			if _, err := LoadEnvelope(path, tt.activeID); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadEnvelope() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadEnvelope_Sources(t *testing.T) {
+	k1 := "k1=" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	k2 := "k2=" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+
+	// Sealed with the keyring file, opened with the same keys from elsewhere
+	fromFile, err := LoadEnvelope(writeKeyring(t, "k1", "k2"), "")
+	if err != nil {
+		t.Fatalf("LoadEnvelope() error = %v", err)
+	}
+	sealed, err := fromFile.Seal("node:edge-01:state", []byte("services: []\n"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	t.Setenv("TEST_PE_KEYRING", k1+","+k2)
+	fromEnv, err := LoadEnvelope("env:TEST_PE_KEYRING", "")
+	if err != nil {
+		t.Fatalf("LoadEnvelope(env:) error = %v", err)
+	}
+	fromFlag, err := ParseKeyring(k1+", "+k2, "-encryption-key", "")
+	if err != nil {
+		t.Fatalf("ParseKeyring() error = %v", err)
+	}
+
+	for name, env := range map[string]*Envelope{"env": fromEnv, "flag": fromFlag} {
+		if env.ActiveKeyID() != "k2" {
+			t.Errorf("%s: ActiveKeyID() = %q, want the last key k2", name, env.ActiveKeyID())
+		}
+		if opened, err := env.Open("node:edge-01:state", sealed); err != nil || string(opened) != "services: []\n" {
+			t.Errorf("%s: Open() = %q, %v", name, opened, err)
+		}
+	}
+
+	if _, err := LoadEnvelope("env:TEST_PE_KEYRING_UNSET", ""); err == nil || !strings.Contains(err.Error(), "is not set") {
+		t.Errorf("LoadEnvelope() with an unset variable error = %v", err)
+	}
+	if _, err := ParseKeyring(k1+",k2", "-encryption-key", ""); err == nil || !strings.Contains(err.Error(), "expected <key-id>=<base64 key>") {
+		t.Errorf("ParseKeyring() with a bad entry error = %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultEventsLimit = 100
+	maxEventsPerPost   = 1000
+)
+
+// NodeEventsKey returns the Redis key for the stream of changes agents made
+// to a node
+func (s *Server) NodeEventsKey(nodeID string) string {
+	return fmt.Sprintf("%s:nodes:%s:events", s.version, nodeID)
+}
+
+// changeEvent holds the fields of an agent's change record the server checks;
+// the record itself is stored as sent
+type changeEvent struct {
+	Time         time.Time `json:"time"`
+	ResourceType string    `json:"resource_type"`
+	ResourceName string    `json:"resource_name"`
+}
+
+// postNodeEvents handles POST /api/v1/nodes/{id}/events, appending the
+// agent's change records to the node's stream. The stream is capped at
+// eventsMaxLen entries, dropping the oldest.
+func (s *Server) postNodeEvents(ctx context.Context, w http.ResponseWriter, r *http.Request, nodeID string) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(raw) > maxEventsPerPost {
+		http.Error(w, fmt.Sprintf("At most %d events per request", maxEventsPerPost), http.StatusRequestEntityTooLarge)
+		return
+	}
+	for i, data := range raw {
+		var event changeEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid event %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+		if event.Time.IsZero() || event.ResourceType == "" || event.ResourceName == "" {
+			http.Error(w, fmt.Sprintf("Invalid event %d: time, resource_type and resource_name are required", i), http.StatusBadRequest)
+			return
+		}
+	}
+
+	key := s.NodeEventsKey(nodeID)
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, data := range raw {
+			sealed, err := s.seal(key, data)
+			if err != nil {
+				return err
+			}
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: key,
+				MaxLen: s.eventsMaxLen,
+				Approx: true,
+				Values: map[string]interface{}{"event": sealed},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to store events: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"node_id": nodeID,
+		"stored":  len(raw),
+	})
+}
+
+// getNodeEvents handles GET /api/v1/nodes/{id}/events[?limit=N], returning
+// the node's most recent change events, newest first
+func (s *Server) getNodeEvents(ctx context.Context, w http.ResponseWriter, r *http.Request, nodeID string) {
+	limit := int64(defaultEventsLimit)
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	key := s.NodeEventsKey(nodeID)
+	messages, err := s.redis.XRevRangeN(ctx, key, "+", "-", limit).Result()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get events: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	events := make([]map[string]interface{}, 0, len(messages))
+	for _, msg := range messages {
+		event, err := s.readEvent(key, msg)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("⚠️  Skipping event %s of node %s: %v", msg.ID, nodeID, err))
+			continue
+		}
+		events = append(events, event)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"node_id": nodeID,
+		"events":  events,
+		"count":   len(events),
+	})
+}
+
+// readEvent decodes a stream entry, adding its ID and the time the server
+// received it
+func (s *Server) readEvent(key string, msg redis.XMessage) (map[string]interface{}, error) {
+	raw, ok := msg.Values["event"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing event field")
+	}
+	data, err := s.open(key, []byte(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("corrupt event: %w", err)
+	}
+	event["id"] = msg.ID
+	// Stream IDs start with the server's millisecond timestamp
+	if ms, err := strconv.ParseInt(strings.SplitN(msg.ID, "-", 2)[0], 10, 64); err == nil {
+		event["received_at"] = time.UnixMilli(ms).UTC().Format(time.RFC3339)
+	}
+	return event, nil
+}
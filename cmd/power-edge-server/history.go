@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// HistoryEntry is a previous version of a node's state
+type HistoryEntry struct {
+	ArchivedAt time.Time `json:"archived_at"`
+	Revision   string    `json:"revision,omitempty"`
+	State      string    `json:"state"` // YAML, exactly as it was stored
+}
+
+// archiveNodeState pushes the node's current state onto its history list,
+// trimming the list to the configured size. Newest entries come first.
+func (s *Server) archiveNodeState(ctx context.Context, nodeID string) error {
+	if s.historySize <= 0 {
+		return nil
+	}
+
+	previous, err := s.get(ctx, s.NodeStateKey(nodeID))
+	if err == redis.Nil {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	entry := HistoryEntry{
+		ArchivedAt: time.Now().UTC(),
+		State:      string(previous),
+	}
+	var state config.State
+	if err := yaml.Unmarshal(previous, &state); err == nil {
+		entry.Revision = state.Revision()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	key := s.NodeHistoryKey(nodeID)
+	sealed, err := s.seal(key, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, sealed)
+		pipe.LTrim(ctx, key, 0, s.historySize-1)
+		return nil
+	})
+	return err
+}
+
+// readHistoryEntry decodes a raw history list element
+func (s *Server) readHistoryEntry(nodeID string, raw []byte) (HistoryEntry, error) {
+	var entry HistoryEntry
+
+	data, err := s.open(s.NodeHistoryKey(nodeID), raw)
+	if err != nil {
+		return entry, err
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, fmt.Errorf("corrupt history entry: %w", err)
+	}
+	return entry, nil
+}
+
+// listNodeHistory returns the stored previous versions (newest first) without their content
+func (s *Server) listNodeHistory(ctx context.Context, w http.ResponseWriter, r *http.Request, nodeID string) {
+	raw, err := s.redis.LRange(ctx, s.NodeHistoryKey(nodeID), 0, -1).Result()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get history: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	versions := []map[string]interface{}{}
+	for i, item := range raw {
+		entry, err := s.readHistoryEntry(nodeID, []byte(item))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to read history entry %d: %v", i, err), http.StatusInternalServerError)
+			return
+		}
+		versions = append(versions, map[string]interface{}{
+			"index":       i,
+			"archived_at": entry.ArchivedAt.Format(time.RFC3339),
+			"revision":    entry.Revision,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"node_id":  nodeID,
+		"versions": versions,
+		"count":    len(versions),
+	})
+}
+
+// getNodeHistoryEntry returns a previous version as YAML, ready to be PUT back
+func (s *Server) getNodeHistoryEntry(ctx context.Context, w http.ResponseWriter, r *http.Request, nodeID, index string) {
+	i, err := strconv.ParseInt(index, 10, 64)
+	if err != nil || i < 0 {
+		http.Error(w, "History index must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+
+	raw, err := s.redis.LIndex(ctx, s.NodeHistoryKey(nodeID), i).Bytes()
+	if err == redis.Nil {
+		http.Error(w, "History entry not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get history: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	entry, err := s.readHistoryEntry(nodeID, raw)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("⚠️  Failed to read history entry %d for node %s: %v", i, nodeID, err))
+		http.Error(w, fmt.Sprintf("Failed to read history entry: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.Header().Set("X-Archived-At", entry.ArchivedAt.Format(time.RFC3339))
+	w.Write([]byte(entry.State))
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// maxStateWait caps how long a long-poll request may block
+const maxStateWait = 5 * time.Minute
+
+// NodeStateChannel returns the pub/sub channel announcing changes to a node's state
+func (s *Server) NodeStateChannel(nodeID string) string {
+	return fmt.Sprintf("%s:nodes:%s:state:changed", s.version, nodeID)
+}
+
+// notifyStateChanged wakes up any long-poll requests waiting on the node
+func (s *Server) notifyStateChanged(ctx context.Context, nodeID, revision string) {
+	if err := s.redis.Publish(ctx, s.NodeStateChannel(nodeID), revision).Err(); err != nil {
+		slog.Warn(fmt.Sprintf("⚠️  Failed to publish state change for node %s: %v", nodeID, err))
+	}
+}
+
+// waitNodeState implements GET .../state?wait=<duration>[&revision=<rev>].
+// It blocks until the node's state changes, then returns the new state. If
+// the caller's revision is already stale the current state is returned
+// immediately; if nothing changes before the timeout it returns 304.
+func (s *Server) waitNodeState(ctx context.Context, w http.ResponseWriter, r *http.Request, nodeID string) {
+	wait, err := time.ParseDuration(r.URL.Query().Get("wait"))
+	if err != nil || wait <= 0 {
+		http.Error(w, "wait must be a positive duration (e.g. 30s)", http.StatusBadRequest)
+		return
+	}
+	if wait > maxStateWait {
+		wait = maxStateWait
+	}
+
+	// The server-wide write timeout is shorter than a long poll
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(wait + 10*time.Second)); err != nil {
+		slog.Warn(fmt.Sprintf("⚠️  Cannot extend write deadline for long poll: %v", err))
+	}
+
+	// Subscribe before reading the current revision so a change landing in
+	// between is not missed
+	sub := s.redis.Subscribe(ctx, s.NodeStateChannel(nodeID))
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to subscribe to state changes: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if known := r.URL.Query().Get("revision"); known != "" {
+		data, err := s.renderNodeState(ctx, nodeID)
+		if err != nil && err != redis.Nil {
+			http.Error(w, fmt.Sprintf("Failed to get state: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if err == nil && dataRevision(data) != known {
+			s.getNodeState(ctx, w, r, nodeID)
+			return
+		}
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-sub.Channel():
+		s.getNodeState(ctx, w, r, nodeID)
+	case <-timer.C:
+		w.WriteHeader(http.StatusNotModified)
+	case <-ctx.Done():
+		// Client went away; the deferred Close releases the subscription
+	}
+}
+
+// dataRevision extracts the revision annotation from state YAML
+func dataRevision(data []byte) string {
+	var state config.State
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return ""
+	}
+	return state.Revision()
+}
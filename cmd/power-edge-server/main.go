@@ -2,10 +2,15 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -17,6 +22,7 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/power-edge/power-edge/pkg/config"
+	"github.com/power-edge/power-edge/pkg/logging"
 )
 
 var (
@@ -28,8 +34,51 @@ var (
 
 // Server represents the power-edge control plane server
 type Server struct {
-	redis   *redis.Client
-	version string // Schema version (e.g., "v1")
+	redis    *redis.Client
+	version  string    // Schema version (e.g., "v1")
+	envelope *Envelope // Encryption at rest (nil = store plaintext)
+	health   *redisHealth
+
+	heartbeatTTL time.Duration // How long a node counts as online after a heartbeat
+	historySize  int64         // Previous state versions kept per node
+	eventsMaxLen int64         // Change events kept per node
+}
+
+// get reads a value from Redis, decrypting it when encryption at rest is enabled
+func (s *Server) get(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.redis.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return s.open(key, data)
+}
+
+// set writes a value to Redis, encrypting it when encryption at rest is enabled
+func (s *Server) set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	sealed, err := s.seal(key, data)
+	if err != nil {
+		return err
+	}
+	return s.redis.Set(ctx, key, sealed, ttl).Err()
+}
+
+// seal encrypts data bound to key when encryption at rest is enabled
+func (s *Server) seal(key string, data []byte) ([]byte, error) {
+	if s.envelope == nil {
+		return data, nil
+	}
+	return s.envelope.Seal(key, data)
+}
+
+// open decrypts a value read from key when encryption at rest is enabled
+func (s *Server) open(key string, data []byte) ([]byte, error) {
+	if s.envelope == nil {
+		if IsSealed(data) {
+			return nil, fmt.Errorf("value is encrypted but encryption at rest is not enabled")
+		}
+		return data, nil
+	}
+	return s.envelope.Open(key, data)
 }
 
 // NodeStateKey returns the Redis key for a node's state
@@ -47,21 +96,60 @@ func (s *Server) NodeComplianceKey(nodeID string) string {
 	return fmt.Sprintf("%s:nodes:%s:compliance", s.version, nodeID)
 }
 
+// NodeHistoryKey returns the Redis key for a node's previous state versions
+func (s *Server) NodeHistoryKey(nodeID string) string {
+	return fmt.Sprintf("%s:nodes:%s:history", s.version, nodeID)
+}
+
 // NodeHeartbeatKey returns the Redis key for a node's last heartbeat
 func (s *Server) NodeHeartbeatKey(nodeID string) string {
 	return fmt.Sprintf("%s:nodes:%s:heartbeat", s.version, nodeID)
 }
 
+// NodeLastSeenKey returns the Redis key for when a node last sent a
+// heartbeat; unlike the heartbeat key it doesn't expire
+func (s *Server) NodeLastSeenKey(nodeID string) string {
+	return fmt.Sprintf("%s:nodes:%s:last_seen", s.version, nodeID)
+}
+
 func main() {
 	// Flags
 	redisAddr := flag.String("redis-addr", "localhost:6379", "Redis server address")
 	redisPassword := flag.String("redis-password", "", "Redis password")
 	redisDB := flag.Int("redis-db", 0, "Redis database number")
+	redisPoolSize := flag.Int("redis-pool-size", 0, "Maximum Redis connections (0 uses the client default of 10 per CPU)")
+	redisDialTimeout := flag.Duration("redis-dial-timeout", 5*time.Second, "Timeout for establishing a Redis connection")
+	redisReadTimeout := flag.Duration("redis-read-timeout", 3*time.Second, "Timeout for reading a Redis reply")
+	redisWriteTimeout := flag.Duration("redis-write-timeout", 3*time.Second, "Timeout for writing a Redis command")
+	redisMaxRetries := flag.Int("redis-max-retries", 3, "Retries of a failed Redis command (-1 disables retries)")
+	redisConnectAttempts := flag.Int("redis-connect-attempts", 10, "Attempts to reach Redis at startup before giving up")
+	redisConnectMaxDelay := flag.Duration("redis-connect-max-delay", 30*time.Second, "Upper bound for the backoff between startup connection attempts")
+	redisHealthInterval := flag.Duration("redis-health-interval", 10*time.Second, "How often Redis is pinged; /health reports the server degraded while it is unreachable")
 	listenAddr := flag.String("listen", ":8080", "HTTP server listen address")
+	httpReadTimeout := flag.Duration("http-read-timeout", 10*time.Second, "Time allowed to read a request, including its body")
+	httpWriteTimeout := flag.Duration("http-write-timeout", 10*time.Second, "Time allowed to write a response (long-polls extend it for themselves)")
+	httpIdleTimeout := flag.Duration("http-idle-timeout", 120*time.Second, "How long an idle keep-alive connection stays open")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file (enables HTTPS together with --tls-key)")
+	tlsKey := flag.String("tls-key", "", "TLS private key file")
 	schemaVersion := flag.String("schema-version", "v1", "Control plane schema version")
+	historySize := flag.Int64("history-size", 20, "Previous state versions kept per node (0 disables history)")
+	eventsMaxLen := flag.Int64("events-max-len", 1000, "Change events kept per node, dropping the oldest (0 keeps all)")
+	heartbeatTTL := flag.Duration("heartbeat-ttl", 90*time.Second, "How long a node counts as online after its last heartbeat")
+	reapAfter := flag.Duration("reap-after", 0, "Delete nodes that haven't sent a heartbeat for this long (0 disables the reaper)")
+	reapInterval := flag.Duration("reap-interval", 10*time.Minute, "How often the reaper looks for stale nodes")
+	encryptAtRest := flag.Bool("encrypt-at-rest", false, "Encrypt stored state and status payloads with AES-GCM")
+	encryptionKeys := flag.String("encryption-keyring", "/etc/power-edge/keyring", "Keyring file with <key-id>=<base64 key> lines, or env:NAME to read them from an environment variable")
+	encryptionKey := flag.String("encryption-key", "", "Comma-separated <key-id>=<base64 key> entries used instead of -encryption-keyring (visible in the process list; prefer a file or env:NAME)")
+	encryptionKeyID := flag.String("encryption-key-id", "", "Key ID used for new writes (defaults to the last key in the keyring)")
 	versionFlag := flag.Bool("version", false, "Print version and exit")
+	var logOpts logging.Options
+	logOpts.AddFlags(flag.CommandLine)
 	flag.Parse()
 
+	if err := logging.Setup(os.Stderr, logOpts); err != nil {
+		logging.Fatalf("Invalid logging flags: %v", err)
+	}
+
 	// Version info
 	if *versionFlag {
 		fmt.Printf("power-edge-server %s\n", Version)
@@ -73,58 +161,150 @@ func main() {
 	log.Printf("🚀 Starting power-edge-server %s", Version)
 	log.Printf("   Redis:         %s (DB %d)", *redisAddr, *redisDB)
 	log.Printf("   Listen:        %s", *listenAddr)
+	if (*tlsCert == "") != (*tlsKey == "") {
+		logging.Fatalf("❌ --tls-cert and --tls-key must be set together")
+	}
+	useTLS := *tlsCert != ""
+	if useTLS {
+		log.Printf("   TLS:           %s", *tlsCert)
+	} else {
+		log.Printf("   TLS:           disabled (plaintext HTTP)")
+	}
 	log.Printf("   Schema:        %s", *schemaVersion)
+	if *reapAfter > 0 {
+		log.Printf("   Reaper:        nodes silent for %s (every %s)", *reapAfter, *reapInterval)
+	} else {
+		log.Printf("   Reaper:        disabled")
+	}
+
+	// Load encryption keyring
+	var envelope *Envelope
+	if *encryptAtRest {
+		var err error
+		if *encryptionKey != "" {
+			envelope, err = ParseKeyring(*encryptionKey, "-encryption-key", *encryptionKeyID)
+		} else {
+			envelope, err = LoadEnvelope(*encryptionKeys, *encryptionKeyID)
+		}
+		if err != nil {
+			logging.Fatalf("❌ Failed to load encryption keyring: %v", err)
+		}
+		log.Printf("   Encryption:    AES-GCM (active key %s)", envelope.ActiveKeyID())
+	} else {
+		log.Printf("   Encryption:    disabled")
+	}
 
 	// Initialize Redis client
+	if *redisHealthInterval <= 0 {
+		logging.Fatalf("❌ --redis-health-interval must be positive")
+	}
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     *redisAddr,
-		Password: *redisPassword,
-		DB:       *redisDB,
+		Addr:         *redisAddr,
+		Password:     *redisPassword,
+		DB:           *redisDB,
+		PoolSize:     *redisPoolSize,
+		DialTimeout:  *redisDialTimeout,
+		ReadTimeout:  *redisReadTimeout,
+		WriteTimeout: *redisWriteTimeout,
+		MaxRetries:   *redisMaxRetries,
 	})
 
 	// Test Redis connection
 	ctx := context.Background()
-	if err := rdb.Ping(ctx).Err(); err != nil {
-		log.Fatalf("❌ Failed to connect to Redis: %v", err)
+	if err := connectRedis(ctx, rdb, *redisConnectAttempts, *redisConnectMaxDelay); err != nil {
+		logging.Fatalf("❌ Failed to connect to Redis: %v", err)
 	}
 	log.Println("✅ Connected to Redis")
 
 	// Create server instance
 	server := &Server{
-		redis:   rdb,
-		version: *schemaVersion,
+		redis:    rdb,
+		version:  *schemaVersion,
+		envelope: envelope,
+		health:   newRedisHealth(),
+
+		heartbeatTTL: *heartbeatTTL,
+		historySize:  *historySize,
+		eventsMaxLen: *eventsMaxLen,
+	}
+
+	metrics := newServerMetrics(server)
+	rdb.AddHook(redisHook{metrics: metrics})
+
+	reaperCtx, stopReaper := context.WithCancel(ctx)
+	defer stopReaper()
+	if *reapAfter > 0 {
+		go server.runReaper(reaperCtx, *reapInterval, *reapAfter)
 	}
+	go server.runRedisHealthCheck(reaperCtx, *redisHealthInterval)
 
 	// Setup HTTP routes
 	mux := http.NewServeMux()
-	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("/health", server.healthHandler)
 	mux.HandleFunc("/version", versionHandler)
+	mux.Handle("/metrics", metrics.Handler())
 	mux.HandleFunc("/api/v1/nodes", server.listNodesHandler)
 	mux.HandleFunc("/api/v1/nodes/", server.nodeHandler) // Note: trailing slash for node-specific routes
+	mux.HandleFunc("/api/v1/profiles", server.listProfilesHandler)
+	mux.HandleFunc("/api/v1/profiles/", server.profileHandler)
+	mux.HandleFunc("/api/v1/compliance/summary", server.complianceSummaryHandler)
+	mux.HandleFunc("/api/v1/schema", server.schemaHandler)
+	mux.HandleFunc("/api/v1/schema/", server.schemaHandler)
 
 	// Start HTTP server
 	httpServer := &http.Server{
 		Addr:         *listenAddr,
-		Handler:      mux,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		Handler:      metrics.instrument(compress(mux)),
+		TLSConfig:    &tls.Config{MinVersion: tls.VersionTLS12},
+		ReadTimeout:  *httpReadTimeout,
+		WriteTimeout: *httpWriteTimeout,
+		IdleTimeout:  *httpIdleTimeout,
 	}
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("📊 HTTP server listening on %s", *listenAddr)
+		scheme := "HTTP"
+		if useTLS {
+			scheme = "HTTPS"
+		}
+		log.Printf("📊 %s server listening on %s", scheme, *listenAddr)
 		log.Println("   API Endpoints:")
-		log.Println("     GET  /health              - Health check")
+		log.Println("     GET  /health              - Health check (503 while Redis is unreachable)")
 		log.Println("     GET  /version             - Version info")
-		log.Println("     GET  /api/v1/nodes        - List all nodes")
+		log.Println("     GET  /metrics             - Prometheus metrics")
+		log.Println("     GET  /api/v1/nodes        - List all nodes (?selector=site=eu,role=gateway)")
+		log.Println("     PATCH /api/v1/nodes?selector=... - Overlay a partial state on matching nodes")
 		log.Println("     GET  /api/v1/nodes/{id}   - Get node state")
-		log.Println("     PUT  /api/v1/nodes/{id}   - Update node state")
+		log.Println("     PUT  /api/v1/nodes/{id}   - Update node state (?profiles=a,b sets its profiles)")
+		log.Println("     DELETE /api/v1/nodes/{id} - Delete the node and all its data")
+		log.Println("     GET  /api/v1/nodes/{id}/state?wait=30s - Wait for a state change")
 		log.Println("     GET  /api/v1/nodes/{id}/versions - Get system versions")
+		log.Println("     PUT  /api/v1/nodes/{id}/versions - Report system versions")
 		log.Println("     GET  /api/v1/nodes/{id}/compliance - Get compliance status")
-
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("HTTP server error: %v", err)
+		log.Println("     PUT  /api/v1/nodes/{id}/compliance - Report compliance status")
+		log.Println("     POST /api/v1/nodes/{id}/heartbeat  - Record node heartbeat")
+		log.Println("     GET  /api/v1/nodes/{id}/profiles   - List the profiles a node inherits")
+		log.Println("     PUT  /api/v1/nodes/{id}/profiles   - Set the profiles a node inherits")
+		log.Println("     GET  /api/v1/nodes/{id}/history    - List previous state versions")
+		log.Println("     GET  /api/v1/nodes/{id}/history/{n} - Get a previous state version")
+		log.Println("     GET  /api/v1/nodes/{id}/events     - List recent change events")
+		log.Println("     POST /api/v1/nodes/{id}/events     - Record change events")
+		log.Println("     GET  /api/v1/profiles               - List profiles")
+		log.Println("     GET  /api/v1/profiles/{name}        - Get a profile")
+		log.Println("     PUT  /api/v1/profiles/{name}        - Create or update a profile")
+		log.Println("     DELETE /api/v1/profiles/{name}      - Delete a profile no node inherits")
+		log.Println("     GET  /api/v1/compliance/summary     - Fleet-wide compliance")
+		log.Println("     GET  /api/v1/schema                 - List configuration schemas")
+		log.Println("     GET  /api/v1/schema/{name}          - Get a schema (e.g. state)")
+
+		var err error
+		if useTLS {
+			err = httpServer.ListenAndServeTLS(*tlsCert, *tlsKey)
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logging.Fatalf("HTTP server error: %v", err)
 		}
 	}()
 
@@ -134,27 +314,42 @@ func main() {
 	<-sigChan
 
 	log.Println("🛑 Shutting down gracefully...")
+	stopReaper()
 
 	// Shutdown HTTP server
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	if err := httpServer.Shutdown(shutdownCtx); err != nil {
-		log.Printf("HTTP server shutdown error: %v", err)
+		slog.Error(fmt.Sprintf("HTTP server shutdown error: %v", err))
 	}
 
 	// Close Redis connection
 	if err := rdb.Close(); err != nil {
-		log.Printf("Redis close error: %v", err)
+		slog.Error(fmt.Sprintf("Redis close error: %v", err))
 	}
 
 	log.Println("✅ Shutdown complete")
 }
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
+// healthHandler reports whether the server can serve requests: it is
+// degraded, with a 503, while the periodic Redis ping fails
+func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `{"status":"healthy","version":"%s"}`, Version)
+	since, err := s.health.status()
+	if err == nil {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"status":"healthy","version":"%s"}`, Version)
+		return
+	}
+
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "degraded",
+		"version": Version,
+		"redis":   err.Error(),
+		"since":   since.UTC().Format(time.RFC3339),
+	})
 }
 
 func versionHandler(w http.ResponseWriter, r *http.Request) {
@@ -163,35 +358,62 @@ func versionHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, `{"version":"%s","git_commit":"%s","build_time":"%s"}`, Version, GitCommit, BuildTime)
 }
 
-// listNodesHandler returns list of all nodes in Redis
+// listNodesHandler returns list of all nodes in Redis. With ?selector= only
+// nodes whose state labels match are listed, along with those labels.
 func (s *Server) listNodesHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPatch:
+		s.patchNodes(w, r)
+		return
+	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	ctx := r.Context()
 
-	// Scan for all node state keys
-	pattern := fmt.Sprintf("%s:nodes:*:state", s.version)
-	var nodes []string
-
-	iter := s.redis.Scan(ctx, 0, pattern, 0).Iterator()
-	for iter.Next(ctx) {
-		key := iter.Val()
-		// Extract node ID from key: v1:nodes:{node-id}:state
-		parts := strings.Split(key, ":")
-		if len(parts) >= 3 {
-			nodeID := parts[2]
-			nodes = append(nodes, nodeID)
-		}
+	sel, err := config.ParseSelector(r.URL.Query().Get("selector"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	if err := iter.Err(); err != nil {
+	// Scan for all node state keys; only selectors need the states decoded
+	var ids []string
+	var labels map[string]map[string]string
+	if sel.Empty() {
+		err = s.scanNodeIDs(ctx, "state", func(nodeID string) error {
+			ids = append(ids, nodeID)
+			return nil
+		})
+	} else {
+		ids, labels, err = s.selectNodes(ctx, sel)
+	}
+	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to scan nodes: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	nodes := []map[string]interface{}{}
+	for _, nodeID := range ids {
+		// The heartbeat key expires after the TTL, so existence means online
+		online, err := s.redis.Exists(ctx, s.NodeHeartbeatKey(nodeID)).Result()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to scan nodes: failed to check heartbeat: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		node := map[string]interface{}{
+			"id":     nodeID,
+			"online": online > 0,
+		}
+		if labels != nil {
+			node["labels"] = labels[nodeID]
+		}
+		nodes = append(nodes, node)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"nodes": nodes,
@@ -214,15 +436,70 @@ func (s *Server) nodeHandler(w http.ResponseWriter, r *http.Request) {
 	if len(parts) > 1 {
 		subresource = parts[1]
 	}
+	item := ""
+	if len(parts) > 2 {
+		item = parts[2]
+	}
 
 	ctx := r.Context()
 
 	// Route to appropriate handler
 	switch subresource {
 	case "versions":
-		s.getNodeVersions(ctx, w, r, nodeID)
+		switch r.Method {
+		case http.MethodGet:
+			s.getNodeVersions(ctx, w, r, nodeID)
+		case http.MethodPut:
+			s.putNodeVersions(ctx, w, r, nodeID)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
 	case "compliance":
-		s.getNodeCompliance(ctx, w, r, nodeID)
+		switch r.Method {
+		case http.MethodGet:
+			s.getNodeCompliance(ctx, w, r, nodeID)
+		case http.MethodPut:
+			s.putNodeCompliance(ctx, w, r, nodeID)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case "history":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if item == "" {
+			s.listNodeHistory(ctx, w, r, nodeID)
+		} else {
+			s.getNodeHistoryEntry(ctx, w, r, nodeID, item)
+		}
+	case "events":
+		switch r.Method {
+		case http.MethodGet:
+			s.getNodeEvents(ctx, w, r, nodeID)
+		case http.MethodPost:
+			s.postNodeEvents(ctx, w, r, nodeID)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case "state":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Query().Get("wait") != "" {
+			s.waitNodeState(ctx, w, r, nodeID)
+		} else {
+			s.getNodeState(ctx, w, r, nodeID)
+		}
+	case "profiles":
+		s.nodeProfilesHandler(ctx, w, r, nodeID)
+	case "heartbeat":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.postNodeHeartbeat(ctx, w, r, nodeID)
 	case "":
 		// Node state CRUD
 		switch r.Method {
@@ -231,7 +508,7 @@ func (s *Server) nodeHandler(w http.ResponseWriter, r *http.Request) {
 		case http.MethodPut:
 			s.putNodeState(ctx, w, r, nodeID)
 		case http.MethodDelete:
-			s.deleteNodeState(ctx, w, r, nodeID)
+			s.deleteNode(ctx, w, r, nodeID)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
@@ -240,11 +517,10 @@ func (s *Server) nodeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// getNodeState retrieves node state from Redis
+// getNodeState retrieves node state from Redis, merged with the profiles
+// the node inherits
 func (s *Server) getNodeState(ctx context.Context, w http.ResponseWriter, r *http.Request, nodeID string) {
-	key := s.NodeStateKey(nodeID)
-
-	data, err := s.redis.Get(ctx, key).Bytes()
+	data, err := s.renderNodeState(ctx, nodeID)
 	if err == redis.Nil {
 		http.Error(w, "Node not found", http.StatusNotFound)
 		return
@@ -253,74 +529,148 @@ func (s *Server) getNodeState(ctx context.Context, w http.ResponseWriter, r *htt
 		return
 	}
 
+	// Clients that already hold this exact state skip the download
+	etag := stateETag(data)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	// Return YAML data
 	w.Header().Set("Content-Type", "application/x-yaml")
 	w.Write(data)
 }
 
-// putNodeState updates node state in Redis
+// stateETag returns a strong ETag for the served state YAML
+func stateETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. Weak
+// validators compare by value, which is all a GET needs.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// putNodeState updates node state in Redis. With ?profiles=a,b the node's
+// profiles are replaced too, so a node can be created with only the state
+// its profiles don't provide.
 func (s *Server) putNodeState(ctx context.Context, w http.ResponseWriter, r *http.Request, nodeID string) {
 	// Read request body (should be YAML)
-	var state config.State
-	if err := yaml.NewDecoder(r.Body).Decode(&state); err != nil {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
+	parsed, err := config.ParseState(body)
+	if err != nil {
 		http.Error(w, fmt.Sprintf("Invalid YAML: %v", err), http.StatusBadRequest)
 		return
 	}
-
-	// Validate state (basic check)
-	if state.Version == "" {
-		http.Error(w, "State version required", http.StatusBadRequest)
+	state := *parsed
+
+	setProfiles := r.URL.Query().Has("profiles")
+	var profiles []string
+	if setProfiles {
+		profiles, err = parseProfiles(r.URL.Query().Get("profiles"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else if profiles, err = s.nodeProfiles(ctx, nodeID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Marshal to YAML for storage
-	yamlData, err := yaml.Marshal(&state)
+	// Reject states that decode but would break every agent that pulls them.
+	// Without profiles effective is state itself, stamped when it's stored.
+	effective, err := s.effectiveState(ctx, profiles, &state, nil)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to marshal state: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 		return
 	}
-
-	// Store in Redis
-	key := s.NodeStateKey(nodeID)
-	if err := s.redis.Set(ctx, key, yamlData, 0).Err(); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to store state: %v", err), http.StatusInternalServerError)
+	if err := effective.Validate(); err != nil {
+		writeInvalidState(w, err)
 		return
 	}
 
-	log.Printf("✅ Updated state for node: %s", nodeID)
+	if setProfiles {
+		if err := s.setNodeProfiles(ctx, nodeID, profiles); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to store profiles: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := s.storeNodeState(ctx, nodeID, &state); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":  "success",
-		"node_id": nodeID,
+		"status":   "success",
+		"node_id":  nodeID,
+		"revision": effective.Revision(),
 	})
 }
 
-// deleteNodeState removes node state from Redis
-func (s *Server) deleteNodeState(ctx context.Context, w http.ResponseWriter, r *http.Request, nodeID string) {
-	key := s.NodeStateKey(nodeID)
+// storeNodeState stamps a validated state with its revision, archives the
+// state it replaces and notifies agents waiting for a change
+func (s *Server) storeNodeState(ctx context.Context, nodeID string, state *config.State) error {
+	// Stamp a content-derived revision so agents can correlate their
+	// reconcile cycles with the exact state they applied
+	state.SetRevision(stateRevision(state))
 
-	if err := s.redis.Del(ctx, key).Err(); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete state: %v", err), http.StatusInternalServerError)
-		return
+	// Marshal to YAML for storage
+	yamlData, err := yaml.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
 	}
 
-	log.Printf("🗑️  Deleted state for node: %s", nodeID)
+	// Keep the version we're about to replace so it can be audited or restored
+	if err := s.archiveNodeState(ctx, nodeID); err != nil {
+		return fmt.Errorf("failed to archive previous state: %w", err)
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":  "success",
-		"node_id": nodeID,
-	})
+	// Store in Redis
+	if err := s.set(ctx, s.NodeStateKey(nodeID), yamlData, 0); err != nil {
+		return fmt.Errorf("failed to store state: %w", err)
+	}
+
+	log.Printf("✅ Updated state for node: %s (revision %s)", nodeID, state.Revision())
+	s.notifyStateChanged(ctx, nodeID, state.Revision())
+	return nil
+}
+
+// stateRevision derives a short revision ID from the state content, ignoring
+// any revision the caller may have sent back with it
+func stateRevision(state *config.State) string {
+	copied := *state
+	copied.Metadata.Annotations = make(map[string]interface{}, len(state.Metadata.Annotations))
+	for k, v := range state.Metadata.Annotations {
+		if k != config.RevisionAnnotation {
+			copied.Metadata.Annotations[k] = v
+		}
+	}
+
+	data, _ := yaml.Marshal(&copied)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
 }
 
 // getNodeVersions retrieves system versions from Redis
 func (s *Server) getNodeVersions(ctx context.Context, w http.ResponseWriter, r *http.Request, nodeID string) {
 	key := s.NodeVersionsKey(nodeID)
 
-	data, err := s.redis.Get(ctx, key).Bytes()
+	data, err := s.get(ctx, key)
 	if err == redis.Nil {
 		http.Error(w, "Versions not found", http.StatusNotFound)
 		return
@@ -334,11 +684,43 @@ func (s *Server) getNodeVersions(ctx context.Context, w http.ResponseWriter, r *
 	w.Write(data)
 }
 
+// putNodeVersions stores the system versions reported by a node
+func (s *Server) putNodeVersions(ctx context.Context, w http.ResponseWriter, r *http.Request, nodeID string) {
+	var versions config.SystemVersions
+	if err := json.NewDecoder(r.Body).Decode(&versions); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := versions.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid versions: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	data, err := json.Marshal(versions)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to marshal versions: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	key := s.NodeVersionsKey(nodeID)
+	if err := s.set(ctx, key, data, 0); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to store versions: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"node_id": nodeID,
+	})
+}
+
 // getNodeCompliance retrieves compliance status from Redis
 func (s *Server) getNodeCompliance(ctx context.Context, w http.ResponseWriter, r *http.Request, nodeID string) {
 	key := s.NodeComplianceKey(nodeID)
 
-	data, err := s.redis.Get(ctx, key).Bytes()
+	data, err := s.get(ctx, key)
 	if err == redis.Nil {
 		http.Error(w, "Compliance status not found", http.StatusNotFound)
 		return
@@ -351,3 +733,67 @@ func (s *Server) getNodeCompliance(ctx context.Context, w http.ResponseWriter, r
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(data)
 }
+
+// putNodeCompliance stores a compliance summary reported by a node
+func (s *Server) putNodeCompliance(ctx context.Context, w http.ResponseWriter, r *http.Request, nodeID string) {
+	var compliance map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&compliance); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// The node's own timestamp says when the check ran; received_at lets
+	// readers spot stale reports even when the node's clock is off
+	if _, ok := compliance["timestamp"]; !ok {
+		http.Error(w, "Compliance timestamp required", http.StatusBadRequest)
+		return
+	}
+	compliance["received_at"] = time.Now().UTC().Format(time.RFC3339)
+
+	data, err := json.Marshal(compliance)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to marshal compliance: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	key := s.NodeComplianceKey(nodeID)
+	if err := s.set(ctx, key, data, 0); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to store compliance: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("📋 Updated compliance for node: %s", nodeID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"node_id": nodeID,
+	})
+}
+
+// postNodeHeartbeat records that a node is alive. The server's receive time
+// is stored rather than anything the client sends, so clock skew on edge
+// nodes can't make them look online (or offline) when they aren't.
+func (s *Server) postNodeHeartbeat(ctx context.Context, w http.ResponseWriter, r *http.Request, nodeID string) {
+	now := time.Now().UTC()
+
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.NodeHeartbeatKey(nodeID), now.Format(time.RFC3339), s.heartbeatTTL)
+		pipe.Set(ctx, s.NodeLastSeenKey(nodeID), now.Format(time.RFC3339), 0)
+		return nil
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to store heartbeat: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      "success",
+		"node_id":     nodeID,
+		"received_at": now.Format(time.RFC3339),
+		"ttl_seconds": int(s.heartbeatTTL.Seconds()),
+	})
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+)
+
+const metricsNamespace = "power_edge_server"
+
+// scrapeTimeout bounds the Redis scans behind the node gauges, so a slow
+// Redis can't hold a scrape open indefinitely
+const scrapeTimeout = 5 * time.Second
+
+// nodeSubresources are the routes under /api/v1/nodes/{id}/ that get their
+// own label; anything else is counted together
+var nodeSubresources = map[string]bool{
+	"versions":   true,
+	"compliance": true,
+	"history":    true,
+	"events":     true,
+	"state":      true,
+	"heartbeat":  true,
+	"profiles":   true,
+}
+
+// serverMetrics are the control plane's own metrics, served on /metrics
+type serverMetrics struct {
+	registry *prometheus.Registry
+
+	requests      *prometheus.CounterVec
+	redisDuration *prometheus.HistogramVec
+	redisErrors   *prometheus.CounterVec
+}
+
+// newServerMetrics registers the server's metrics, including node gauges
+// counted from s's keyspace on every scrape
+func newServerMetrics(s *Server) *serverMetrics {
+	m := &serverMetrics{
+		registry: prometheus.NewRegistry(),
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests served, by route, method and status code",
+		}, []string{"route", "method", "code"}),
+
+		redisDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "redis_operation_duration_seconds",
+			Help:      "Latency of Redis commands and pipelines, by operation",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"operation"}),
+
+		redisErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "redis_errors_total",
+			Help:      "Redis commands and pipelines that failed, by operation (missing keys aren't errors)",
+		}, []string{"operation"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.redisDuration,
+		m.redisErrors,
+		&nodeCollector{server: s},
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "redis_up",
+			Help:      "Whether the latest periodic Redis ping succeeded",
+		}, func() float64 {
+			if _, err := s.health.status(); err != nil {
+				return 0
+			}
+			return 1
+		}),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format. When
+// Redis can't be scanned the node gauges are left out rather than failing
+// the scrape, so the request and Redis error counters still get through.
+func (m *serverMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
+}
+
+// instrument counts every request next serves
+func (m *serverMetrics) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		m.requests.WithLabelValues(routeLabel(r.URL.Path), r.Method, strconv.Itoa(rec.status)).Inc()
+	})
+}
+
+// routeLabel names the route serving path, with node IDs and other path
+// parameters replaced by placeholders so the label's values stay bounded
+func routeLabel(path string) string {
+	if rest, ok := strings.CutPrefix(path, "/api/v1/nodes/"); ok {
+		parts := strings.Split(rest, "/")
+		route := "/api/v1/nodes/{id}"
+		if len(parts) > 1 && parts[1] != "" {
+			if !nodeSubresources[parts[1]] {
+				return route + "/{unknown}"
+			}
+			route += "/" + parts[1]
+			if len(parts) > 2 && parts[2] != "" {
+				route += "/{item}"
+			}
+		}
+		return route
+	}
+	if name, ok := strings.CutPrefix(path, "/api/v1/schema/"); ok && name != "" {
+		return "/api/v1/schema/{name}"
+	}
+	if name, ok := strings.CutPrefix(path, "/api/v1/profiles/"); ok && name != "" {
+		return "/api/v1/profiles/{name}"
+	}
+
+	switch path {
+	case "/health", "/version", "/metrics", "/api/v1/nodes", "/api/v1/profiles", "/api/v1/profiles/", "/api/v1/compliance/summary", "/api/v1/schema", "/api/v1/schema/":
+		return path
+	}
+	return "other"
+}
+
+// statusRecorder remembers the status code a handler responded with
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, which
+// long-polls use to extend their write deadline
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// redisHook times every Redis command and pipeline
+type redisHook struct {
+	metrics *serverMetrics
+}
+
+func (h redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		if err != nil {
+			h.metrics.redisErrors.WithLabelValues("dial").Inc()
+		}
+		return conn, err
+	}
+}
+
+func (h redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.observe(cmd.Name(), start, err)
+		return err
+	}
+}
+
+func (h redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.observe("pipeline", start, err)
+		return err
+	}
+}
+
+func (h redisHook) observe(operation string, start time.Time, err error) {
+	h.metrics.redisDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, redis.Nil) && !errors.Is(err, redis.TxFailedErr) {
+		h.metrics.redisErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// nodeCollector counts registered and online nodes when scraped. A node is
+// registered while it has a state and online while its heartbeat key, which
+// expires after -heartbeat-ttl, exists.
+type nodeCollector struct {
+	server *Server
+}
+
+var (
+	nodesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "nodes"),
+		"Nodes with a state stored on the server",
+		nil, nil,
+	)
+	nodesOnlineDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "nodes_online"),
+		"Nodes that sent a heartbeat within the heartbeat TTL",
+		nil, nil,
+	)
+)
+
+func (c *nodeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- nodesDesc
+	ch <- nodesOnlineDesc
+}
+
+func (c *nodeCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+
+	for _, gauge := range []struct {
+		desc   *prometheus.Desc
+		suffix string
+	}{
+		{nodesDesc, "state"},
+		{nodesOnlineDesc, "heartbeat"},
+	} {
+		count := 0
+		err := c.server.scanNodeIDs(ctx, gauge.suffix, func(string) error {
+			count++
+			return nil
+		})
+		if err != nil {
+			slog.Warn(fmt.Sprintf("⚠️  Failed to count nodes for metrics: %v", err))
+			ch <- prometheus.NewInvalidMetric(gauge.desc, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(gauge.desc, prometheus.GaugeValue, float64(count))
+	}
+}
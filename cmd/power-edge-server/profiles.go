@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// profileName is what a profile may be called; it is part of its Redis key
+var profileName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ProfileStateKey returns the Redis key for a profile's state
+func (s *Server) ProfileStateKey(name string) string {
+	return fmt.Sprintf("%s:profiles:%s:state", s.version, name)
+}
+
+// NodeProfilesKey returns the Redis key for the profiles a node inherits
+func (s *Server) NodeProfilesKey(nodeID string) string {
+	return fmt.Sprintf("%s:nodes:%s:profiles", s.version, nodeID)
+}
+
+// profileState loads a stored profile, or nil if there is none by that name
+func (s *Server) profileState(ctx context.Context, name string) (*config.State, error) {
+	data, err := s.get(ctx, s.ProfileStateKey(name))
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get profile %s: %w", name, err)
+	}
+
+	var state config.State
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode profile %s: %w", name, err)
+	}
+	return &state, nil
+}
+
+// nodeProfiles returns the profiles a node inherits, in merge order
+func (s *Server) nodeProfiles(ctx context.Context, nodeID string) ([]string, error) {
+	data, err := s.get(ctx, s.NodeProfilesKey(nodeID))
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get profiles for %s: %w", nodeID, err)
+	}
+
+	var profiles []string
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to decode profiles for %s: %w", nodeID, err)
+	}
+	return profiles, nil
+}
+
+// setNodeProfiles stores the profiles a node inherits; none removes the key
+func (s *Server) setNodeProfiles(ctx context.Context, nodeID string, profiles []string) error {
+	if len(profiles) == 0 {
+		return s.redis.Del(ctx, s.NodeProfilesKey(nodeID)).Err()
+	}
+	data, err := json.Marshal(profiles)
+	if err != nil {
+		return err
+	}
+	return s.set(ctx, s.NodeProfilesKey(nodeID), data, 0)
+}
+
+// effectiveState merges the named profiles, in order, and then the node's
+// own state on top with config.Merge, so the node's overrides win. The
+// result carries a revision of its own, since changing a profile changes
+// what the node gets. Without profiles the node's state is returned as is.
+// Profiles in overrides are used instead of the stored ones of that name, so
+// a change can be checked before it is stored.
+func (s *Server) effectiveState(ctx context.Context, profiles []string, node *config.State, overrides map[string]*config.State) (*config.State, error) {
+	if len(profiles) == 0 {
+		return node, nil
+	}
+
+	var merged *config.State
+	for _, name := range profiles {
+		profile, ok := overrides[name]
+		if !ok {
+			var err error
+			if profile, err = s.profileState(ctx, name); err != nil {
+				return nil, err
+			}
+		}
+		if profile == nil {
+			return nil, fmt.Errorf("profile %s not found", name)
+		}
+		merged = config.Merge(merged, profile)
+	}
+	merged = config.Merge(merged, node)
+	merged.SetRevision(stateRevision(merged))
+	return merged, nil
+}
+
+// nodeEffectiveState is effectiveState for a stored node, or nil if the node
+// has no state
+func (s *Server) nodeEffectiveState(ctx context.Context, nodeID string, overrides map[string]*config.State) (*config.State, error) {
+	node, err := s.nodeState(ctx, nodeID)
+	if err != nil || node == nil {
+		return nil, err
+	}
+	profiles, err := s.nodeProfiles(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	return s.effectiveState(ctx, profiles, node, overrides)
+}
+
+// renderNodeState returns the YAML a node is served: its stored state, or
+// with profiles the merged state. It returns redis.Nil for unknown nodes.
+func (s *Server) renderNodeState(ctx context.Context, nodeID string) ([]byte, error) {
+	data, err := s.get(ctx, s.NodeStateKey(nodeID))
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := s.nodeProfiles(ctx, nodeID)
+	if err != nil || len(profiles) == 0 {
+		return data, err
+	}
+
+	var node config.State
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("failed to decode state for %s: %w", nodeID, err)
+	}
+	state, err := s.effectiveState(ctx, profiles, &node, nil)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(state)
+}
+
+// profileNodes returns the IDs of nodes inheriting the named profile
+func (s *Server) profileNodes(ctx context.Context, name string) ([]string, error) {
+	var ids []string
+	err := s.scanNodeIDs(ctx, "profiles", func(nodeID string) error {
+		profiles, err := s.nodeProfiles(ctx, nodeID)
+		if err != nil {
+			return err
+		}
+		for _, p := range profiles {
+			if p == name {
+				ids = append(ids, nodeID)
+				break
+			}
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// parseProfiles splits a comma-separated list of profile names, checking each
+func parseProfiles(raw string) ([]string, error) {
+	var profiles []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !profileName.MatchString(name) {
+			return nil, fmt.Errorf("invalid profile name %q", name)
+		}
+		profiles = append(profiles, name)
+	}
+	return profiles, nil
+}
+
+// writeInvalidState responds 422 with the validation errors of a state that
+// a change would leave invalid
+func writeInvalidState(w http.ResponseWriter, err error) {
+	var fieldErrs config.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "invalid",
+		"errors": fieldErrs,
+	})
+}
+
+// listProfilesHandler handles GET /api/v1/profiles
+func (s *Server) listProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+
+	profiles := []string{}
+	prefix := s.version + ":profiles:"
+	iter := s.redis.Scan(ctx, 0, prefix+"*:state", 0).Iterator()
+	for iter.Next(ctx) {
+		name := strings.TrimSuffix(strings.TrimPrefix(iter.Val(), prefix), ":state")
+		profiles = append(profiles, name)
+	}
+	if err := iter.Err(); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to scan profiles: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"profiles": profiles,
+		"count":    len(profiles),
+	})
+}
+
+// profileHandler handles /api/v1/profiles/{name}
+func (s *Server) profileHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/v1/profiles/")
+	if name == "" {
+		http.Error(w, "Profile name required", http.StatusBadRequest)
+		return
+	}
+	if !profileName.MatchString(name) {
+		http.Error(w, fmt.Sprintf("Invalid profile name %q", name), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+		data, err := s.get(ctx, s.ProfileStateKey(name))
+		if err == redis.Nil {
+			http.Error(w, "Profile not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get profile: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-yaml")
+		w.Write(data)
+	case http.MethodPut:
+		s.putProfile(ctx, w, r, name)
+	case http.MethodDelete:
+		s.deleteProfile(ctx, w, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// putProfile stores a profile. A profile is usually partial, so it isn't
+// validated on its own; instead every node inheriting it must still end up
+// with a valid state, or nothing is stored. Those nodes are then notified
+// like on a PUT of their own state.
+func (s *Server) putProfile(ctx context.Context, w http.ResponseWriter, r *http.Request, name string) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
+	profile, err := config.ParseState(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid YAML: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ids, err := s.profileNodes(ctx, name)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to find nodes using the profile: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	type invalidNode struct {
+		NodeID string                  `json:"node_id"`
+		Error  string                  `json:"error,omitempty"`
+		Errors config.ValidationErrors `json:"errors,omitempty"`
+	}
+	invalid := []invalidNode{}
+	revisions := map[string]string{}
+	for _, nodeID := range ids {
+		state, err := s.nodeEffectiveState(ctx, nodeID, map[string]*config.State{name: profile})
+		if err == nil && state == nil {
+			// Deleted since the scan saw it
+			continue
+		}
+		if err == nil {
+			err = state.Validate()
+		}
+		if err != nil {
+			entry := invalidNode{NodeID: nodeID, Error: err.Error()}
+			if errors.As(err, &entry.Errors) {
+				entry.Error = "state with the profile is invalid"
+			}
+			invalid = append(invalid, entry)
+			continue
+		}
+		revisions[nodeID] = state.Revision()
+	}
+	if len(invalid) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "invalid",
+			"nodes":  invalid,
+		})
+		return
+	}
+
+	yamlData, err := yaml.Marshal(profile)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to marshal profile: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := s.set(ctx, s.ProfileStateKey(name), yamlData, 0); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to store profile: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("✅ Updated profile %s (%d nodes)", name, len(revisions))
+	for nodeID, revision := range revisions {
+		s.notifyStateChanged(ctx, nodeID, revision)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"profile": name,
+		"nodes":   revisions,
+	})
+}
+
+// deleteProfile removes a profile no node inherits any more
+func (s *Server) deleteProfile(ctx context.Context, w http.ResponseWriter, name string) {
+	ids, err := s.profileNodes(ctx, name)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to find nodes using the profile: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if len(ids) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "in_use",
+			"nodes":  ids,
+		})
+		return
+	}
+
+	deleted, err := s.redis.Del(ctx, s.ProfileStateKey(name)).Result()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete profile: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	log.Printf("🗑️  Deleted profile %s", name)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"profile": name,
+	})
+}
+
+// nodeProfilesHandler handles GET and PUT /api/v1/nodes/{id}/profiles. A PUT
+// takes {"profiles": ["base", "eu"]}, merged in that order under the node's
+// own state; the result must be valid.
+func (s *Server) nodeProfilesHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, nodeID string) {
+	switch r.Method {
+	case http.MethodGet:
+		profiles, err := s.nodeProfiles(ctx, nodeID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if profiles == nil {
+			profiles = []string{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"node_id":  nodeID,
+			"profiles": profiles,
+		})
+	case http.MethodPut:
+		var req struct {
+			Profiles []string `json:"profiles"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		profiles, err := parseProfiles(strings.Join(req.Profiles, ","))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		node, err := s.nodeState(ctx, nodeID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if node == nil {
+			http.Error(w, "Node not found", http.StatusNotFound)
+			return
+		}
+		state, err := s.effectiveState(ctx, profiles, node, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if err := state.Validate(); err != nil {
+			writeInvalidState(w, err)
+			return
+		}
+		if err := s.setNodeProfiles(ctx, nodeID, profiles); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to store profiles: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("✅ Node %s now inherits profiles %v (revision %s)", nodeID, profiles, state.Revision())
+		s.notifyStateChanged(ctx, nodeID, state.Revision())
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":   "success",
+			"node_id":  nodeID,
+			"profiles": profiles,
+			"revision": state.Revision(),
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// errNodeSeen aborts a reap when the node sent a heartbeat while it was
+// being checked
+var errNodeSeen = errors.New("node seen during reap")
+
+// nodeKeys returns every Redis key holding data for a node
+func (s *Server) nodeKeys(nodeID string) []string {
+	return []string{
+		s.NodeStateKey(nodeID),
+		s.NodeVersionsKey(nodeID),
+		s.NodeComplianceKey(nodeID),
+		s.NodeHeartbeatKey(nodeID),
+		s.NodeLastSeenKey(nodeID),
+		s.NodeHistoryKey(nodeID),
+		s.NodeEventsKey(nodeID),
+		s.NodeProfilesKey(nodeID),
+	}
+}
+
+// deleteNode handles DELETE /api/v1/nodes/{id}, removing all of the node's
+// keys in a single DEL so no partial node is left behind
+func (s *Server) deleteNode(ctx context.Context, w http.ResponseWriter, r *http.Request, nodeID string) {
+	deleted, err := s.redis.Del(ctx, s.nodeKeys(nodeID)...).Result()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete node: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("🗑️  Deleted node %s (%d keys)", nodeID, deleted)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":       "success",
+		"node_id":      nodeID,
+		"keys_deleted": deleted,
+	})
+}
+
+// runReaper deletes nodes that haven't sent a heartbeat for longer than
+// after, checking every interval until ctx is done
+func (s *Server) runReaper(ctx context.Context, interval, after time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.reapStaleNodes(ctx, after); err != nil && ctx.Err() == nil {
+				slog.Warn(fmt.Sprintf("⚠️  Node reaper failed: %v", err))
+			}
+		}
+	}
+}
+
+// reapStaleNodes deletes every node last seen more than after ago. Nodes
+// that never sent a heartbeat, e.g. ones provisioned ahead of deployment,
+// are left alone.
+func (s *Server) reapStaleNodes(ctx context.Context, after time.Duration) error {
+	cutoff := time.Now().Add(-after)
+
+	var stale []string
+	err := s.scanNodeIDs(ctx, "last_seen", func(nodeID string) error {
+		lastSeen, err := s.nodeLastSeen(ctx, s.redis, nodeID)
+		if err != nil {
+			return err
+		}
+		if !lastSeen.IsZero() && lastSeen.Before(cutoff) {
+			stale = append(stale, nodeID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, nodeID := range stale {
+		deleted, err := s.reapNode(ctx, nodeID, cutoff)
+		if errors.Is(err, errNodeSeen) || errors.Is(err, redis.TxFailedErr) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to reap node %s: %w", nodeID, err)
+		}
+		log.Printf("🧹 Reaped node %s: no heartbeat since before %s (%d keys deleted)", nodeID, cutoff.UTC().Format(time.RFC3339), deleted)
+	}
+	return nil
+}
+
+// reapNode deletes a node unless it was seen after cutoff. The last-seen key
+// is watched, so a heartbeat arriving mid-reap aborts the delete.
+func (s *Server) reapNode(ctx context.Context, nodeID string, cutoff time.Time) (int64, error) {
+	var deleted *redis.IntCmd
+	err := s.redis.Watch(ctx, func(tx *redis.Tx) error {
+		lastSeen, err := s.nodeLastSeen(ctx, tx, nodeID)
+		if err != nil {
+			return err
+		}
+		if lastSeen.IsZero() || !lastSeen.Before(cutoff) {
+			return errNodeSeen
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			deleted = pipe.Del(ctx, s.nodeKeys(nodeID)...)
+			return nil
+		})
+		return err
+	}, s.NodeLastSeenKey(nodeID))
+	if err != nil {
+		return 0, err
+	}
+	return deleted.Val(), nil
+}
+
+// nodeLastSeen returns when the node last sent a heartbeat, or the zero time
+// if it never did
+func (s *Server) nodeLastSeen(ctx context.Context, c redis.Cmdable, nodeID string) (time.Time, error) {
+	value, err := c.Get(ctx, s.NodeLastSeenKey(nodeID)).Result()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get last heartbeat of %s: %w", nodeID, err)
+	}
+	lastSeen, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid last heartbeat of %s: %w", nodeID, err)
+	}
+	return lastSeen, nil
+}
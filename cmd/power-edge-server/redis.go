@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// connectRedis pings Redis until it answers, backing off exponentially from
+// one second up to maxDelay between attempts, so the server can be started
+// slightly before Redis is ready
+func connectRedis(ctx context.Context, rdb *redis.Client, attempts int, maxDelay time.Duration) error {
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		err := rdb.Ping(ctx).Err()
+		if err == nil {
+			return nil
+		}
+		if attempt >= attempts {
+			return err
+		}
+
+		slog.Warn(fmt.Sprintf("⚠️  Redis connection attempt %d/%d failed: %v (retrying in %s)", attempt, attempts, err, delay))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxDelay)
+	}
+}
+
+// redisHealth is the outcome of the latest periodic Redis ping. While Redis
+// is unreachable the server is degraded: /health reports it, and requests
+// touching Redis fail.
+type redisHealth struct {
+	mu    sync.Mutex
+	err   error     // Why the last ping failed; nil while healthy
+	since time.Time // When Redis last became reachable or unreachable
+}
+
+func newRedisHealth() *redisHealth {
+	return &redisHealth{since: time.Now()}
+}
+
+// record stores the outcome of a ping, reporting whether Redis went from
+// reachable to unreachable or back
+func (h *redisHealth) record(err error) (changed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	changed = (err == nil) != (h.err == nil)
+	if changed {
+		h.since = time.Now()
+	}
+	h.err = err
+	return changed
+}
+
+// status returns since when Redis has been reachable or not, and why it
+// isn't
+func (h *redisHealth) status() (since time.Time, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.since, h.err
+}
+
+// runRedisHealthCheck pings Redis every interval until ctx is done, logging
+// when it becomes unreachable and when it recovers
+func (s *Server) runRedisHealthCheck(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, interval)
+			err := s.redis.Ping(pingCtx).Err()
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+
+			if !s.health.record(err) {
+				continue
+			}
+			if err != nil {
+				slog.Warn(fmt.Sprintf("⚠️  Redis is unreachable, server degraded: %v", err))
+			} else {
+				log.Println("✅ Redis is reachable again")
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/power-edge/power-edge/schemas"
+)
+
+// schemaHandler serves the bundled schemas so clients can validate state
+// before pushing it. GET /api/v1/schema lists them and
+// GET /api/v1/schema/{file} returns one as YAML.
+func (s *Server) schemaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/schema"), "/")
+	if name == "" {
+		names, err := fs.Glob(schemas.FS, "*.schema.yaml")
+		if err != nil {
+			http.Error(w, "Failed to list schemas", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"version": s.version,
+			"schemas": names,
+		})
+		return
+	}
+
+	if !strings.HasSuffix(name, ".schema.yaml") {
+		name += ".schema.yaml"
+	}
+	data, err := fs.ReadFile(schemas.FS, name)
+	if err != nil {
+		http.Error(w, "Schema not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(data)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// nodeState loads the state stored for a node, or nil if it has none
+func (s *Server) nodeState(ctx context.Context, nodeID string) (*config.State, error) {
+	data, err := s.get(ctx, s.NodeStateKey(nodeID))
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get state for %s: %w", nodeID, err)
+	}
+
+	var state config.State
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode state for %s: %w", nodeID, err)
+	}
+	return &state, nil
+}
+
+// selectNodes returns the IDs of nodes whose state, with their profiles,
+// matches sel, along with the labels they were matched by
+func (s *Server) selectNodes(ctx context.Context, sel config.Selector) ([]string, map[string]map[string]string, error) {
+	var ids []string
+	labels := map[string]map[string]string{}
+	err := s.scanNodeIDs(ctx, "state", func(nodeID string) error {
+		state, err := s.nodeEffectiveState(ctx, nodeID, nil)
+		if err != nil || state == nil {
+			// Deleted since the scan saw it
+			return err
+		}
+		nodeLabels := state.Metadata.SelectorLabels()
+		if sel.Matches(nodeLabels) {
+			ids = append(ids, nodeID)
+			labels[nodeID] = nodeLabels
+		}
+		return nil
+	})
+	return ids, labels, err
+}
+
+// patchNodes handles PATCH /api/v1/nodes?selector=..., overlaying a partial
+// state document on the state of every matching node by the rules layered
+// state files follow (see config.Merge). The patch lands in the node's own
+// state, not its profiles. Each result is validated and stored like a PUT;
+// nodes it would leave invalid are left unchanged and reported.
+func (s *Server) patchNodes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	raw := r.URL.Query().Get("selector")
+	if raw == "" {
+		http.Error(w, "A selector is required to patch nodes", http.StatusBadRequest)
+		return
+	}
+	sel, err := config.ParseSelector(raw)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
+	patch, err := config.ParseState(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid YAML: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Select first, so a patch changing labels can't change what it applies to
+	ids, _, err := s.selectNodes(ctx, sel)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to select nodes: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	type patched struct {
+		NodeID   string                  `json:"node_id"`
+		Revision string                  `json:"revision,omitempty"`
+		Error    string                  `json:"error,omitempty"`
+		Errors   config.ValidationErrors `json:"errors,omitempty"`
+	}
+	updated, failed := []patched{}, []patched{}
+	for _, nodeID := range ids {
+		current, err := s.nodeState(ctx, nodeID)
+		if err == nil && current == nil {
+			err = fmt.Errorf("node was deleted")
+		}
+		if err != nil {
+			failed = append(failed, patched{NodeID: nodeID, Error: err.Error()})
+			continue
+		}
+
+		state := config.Merge(current, patch)
+		profiles, err := s.nodeProfiles(ctx, nodeID)
+		if err != nil {
+			failed = append(failed, patched{NodeID: nodeID, Error: err.Error()})
+			continue
+		}
+		effective, err := s.effectiveState(ctx, profiles, state, nil)
+		if err == nil {
+			err = effective.Validate()
+		}
+		if err != nil {
+			entry := patched{NodeID: nodeID, Error: "patched state is invalid"}
+			if !errors.As(err, &entry.Errors) {
+				entry.Error = err.Error()
+			}
+			failed = append(failed, entry)
+			continue
+		}
+		if err := s.storeNodeState(ctx, nodeID, state); err != nil {
+			failed = append(failed, patched{NodeID: nodeID, Error: err.Error()})
+			continue
+		}
+		updated = append(updated, patched{NodeID: nodeID, Revision: effective.Revision()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"selector": raw,
+		"matched":  len(ids),
+		"updated":  updated,
+		"failed":   failed,
+	})
+}
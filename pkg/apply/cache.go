@@ -0,0 +1,72 @@
+package apply
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// SourceCache keeps downloaded file sources by their SHA256, so an artifact
+// already fetched once is served locally, even when its source is down.
+// Only sources with a declared checksum are cached, and entries are
+// verified again when read.
+type SourceCache struct {
+	dir string
+}
+
+// NewSourceCache returns a cache kept in dir. The directory is created,
+// readable by the owner only, on the first write.
+func NewSourceCache(dir string) *SourceCache {
+	return &SourceCache{dir: dir}
+}
+
+// Get returns the cached content with the given checksum. An entry that no
+// longer matches it is removed and reported as a miss.
+func (c *SourceCache) Get(sum string) ([]byte, bool) {
+	if !sha256Pattern.MatchString(sum) {
+		return nil, false
+	}
+	path := filepath.Join(c.dir, sum)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	if fmt.Sprintf("%x", sha256.Sum256(data)) != sum {
+		os.Remove(path)
+		return nil, false
+	}
+	return data, true
+}
+
+// Put stores data under its checksum, atomically so a crash never leaves a
+// partial entry behind
+func (c *SourceCache) Put(sum string, data []byte) (err error) {
+	if !sha256Pattern.MatchString(sum) {
+		return fmt.Errorf("invalid checksum %q", sum)
+	}
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(c.dir, sum))
+}
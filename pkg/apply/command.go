@@ -0,0 +1,110 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultCommandTimeout bounds each command an applier runs, so a hung
+// apt-get or systemctl can't block reconciliation forever. It is generous
+// because package installs can legitimately take minutes.
+const DefaultCommandTimeout = 5 * time.Minute
+
+// ErrCommandTimeout is returned (wrapped) when a command exceeds its timeout
+var ErrCommandTimeout = errors.New("timed out")
+
+type commandTimeoutKey struct{}
+
+// WithCommandTimeout returns a context under which every command run by an
+// applier is killed after d. A zero or negative d keeps the default.
+func WithCommandTimeout(ctx context.Context, d time.Duration) context.Context {
+	if d <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, commandTimeoutKey{}, d)
+}
+
+// CommandTimeout returns the per-command timeout in effect for ctx
+func CommandTimeout(ctx context.Context) time.Duration {
+	if d, ok := ctx.Value(commandTimeoutKey{}).(time.Duration); ok {
+		return d
+	}
+	return DefaultCommandTimeout
+}
+
+// runOutput runs a command bound to ctx and returns its stdout
+func runOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return runCommand(ctx, nil, false, name, args...)
+}
+
+// runCombined runs a command bound to ctx and returns stdout and stderr
+func runCombined(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return runCommand(ctx, nil, true, name, args...)
+}
+
+// runCombinedInput is runCombined with stdin fed from r
+func runCombinedInput(ctx context.Context, r io.Reader, name string, args ...string) ([]byte, error) {
+	return runCommand(ctx, r, true, name, args...)
+}
+
+// PrivilegedOutput runs a command that needs root through the privilege
+// command in effect for ctx, bound to its command timeout, and returns its
+// stdout
+func PrivilegedOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return runOutput(ctx, "sudo", append([]string{name}, args...)...)
+}
+
+func runCommand(ctx context.Context, stdin io.Reader, combined bool, name string, args ...string) ([]byte, error) {
+	// Appliers run system-changing commands through sudo, which stands for
+	// the configured privilege command
+	privileged := name == "sudo"
+	if privileged {
+		command := append(append([]string{}, PrivilegeCommand(ctx)...), args...)
+		if len(command) == len(args) {
+			if err := CheckPrivileges(ctx); err != nil {
+				return nil, fmt.Errorf("command %q: %w", strings.Join(args, " "), err)
+			}
+		}
+		name, args = command[0], command[1:]
+	}
+
+	timeout := CommandTimeout(ctx)
+	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(cmdCtx, name, args...)
+	cmd.Stdin = stdin
+	// sudo's child can keep the output pipes open after sudo is killed
+	cmd.WaitDelay = 5 * time.Second
+
+	var output []byte
+	var err error
+	if combined {
+		output, err = cmd.CombinedOutput()
+	} else {
+		output, err = cmd.Output()
+	}
+
+	if err != nil {
+		line := strings.Join(append([]string{name}, args...), " ")
+		switch {
+		case ctx.Err() != nil:
+			return output, fmt.Errorf("command %q cancelled: %w", line, ctx.Err())
+		case cmdCtx.Err() != nil:
+			return output, fmt.Errorf("command %q %w after %s", line, ErrCommandTimeout, timeout)
+		case privileged && os.Geteuid() != 0:
+			// Say so if the failure is for lack of privileges rather than
+			// leaving an opaque exit status
+			if perr := CheckPrivileges(ctx); perr != nil {
+				return output, fmt.Errorf("command %q: %w", line, perr)
+			}
+		}
+	}
+	return output, err
+}
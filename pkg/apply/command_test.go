@@ -0,0 +1,63 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCommand_Timeout(t *testing.T) {
+	ctx := WithCommandTimeout(context.Background(), 50*time.Millisecond)
+
+	start := time.Now()
+	_, err := runCombined(ctx, "sleep", "5")
+	if err == nil {
+		t.Fatal("Expected timeout error")
+	}
+	if !errors.Is(err, ErrCommandTimeout) {
+		t.Errorf("Expected ErrCommandTimeout, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `"sleep 5" timed out after 50ms`) {
+		t.Errorf("Error should name the command and timeout: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Command was not killed promptly (%s)", elapsed)
+	}
+}
+
+func TestRunCommand_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := runOutput(ctx, "sleep", "5")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if errors.Is(err, ErrCommandTimeout) {
+		t.Error("Cancellation must not be reported as a timeout")
+	}
+}
+
+func TestRunCommand_ExitErrorPreserved(t *testing.T) {
+	output, err := runCombined(context.Background(), "sh", "-c", "echo oops; exit 3")
+	if err == nil {
+		t.Fatal("Expected exit error")
+	}
+	if errors.Is(err, ErrCommandTimeout) {
+		t.Errorf("Exit failure reported as timeout: %v", err)
+	}
+	if strings.TrimSpace(string(output)) != "oops" {
+		t.Errorf("Expected output to be captured, got %q", output)
+	}
+}
+
+func TestCommandTimeout_Default(t *testing.T) {
+	if d := CommandTimeout(context.Background()); d != DefaultCommandTimeout {
+		t.Errorf("CommandTimeout() = %s, want %s", d, DefaultCommandTimeout)
+	}
+	if d := CommandTimeout(WithCommandTimeout(context.Background(), 0)); d != DefaultCommandTimeout {
+		t.Errorf("Zero timeout should keep the default, got %s", d)
+	}
+}
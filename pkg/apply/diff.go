@@ -0,0 +1,226 @@
+package apply
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	diffContext = 3
+	// Beyond this many line pairs a diff is too costly to compute and too long
+	// to review; a summary is shown instead
+	maxDiffCells = 1 << 22
+)
+
+// contentDiff describes how the content at path would change to want. Text is
+// shown as a unified diff; binary content, or content that is only known by
+// checksum, is summarized instead.
+func contentDiff(path string, exists bool, want string, checksumOnly bool) string {
+	var have string
+	if exists {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Sprintf("current content unreadable: %v", err)
+		}
+		have = string(data)
+	}
+
+	if checksumOnly || isBinary(have) || isBinary(want) {
+		return summarizeChange(exists, have, want)
+	}
+
+	from := path
+	if !exists {
+		from = "/dev/null"
+	}
+	diff, ok := unifiedDiff(from, path, have, want)
+	if !ok {
+		return summarizeChange(exists, have, want)
+	}
+	return diff
+}
+
+// redact masks secret values interpolated into content. Each line of a
+// multi-line secret is masked too, since a diff prefixes every line.
+func redact(s string, secrets []string) string {
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+		for _, line := range strings.Split(secret, "\n") {
+			if strings.TrimSpace(line) != "" {
+				s = strings.ReplaceAll(s, line, redacted)
+			}
+		}
+	}
+	return s
+}
+
+// redacted stands in for secret values in diffs
+const redacted = "<redacted>"
+
+// isBinary applies the same heuristic as git: NUL bytes or invalid UTF-8
+func isBinary(s string) bool {
+	return strings.IndexByte(s, 0) >= 0 || !utf8.ValidString(s)
+}
+
+func summarizeChange(exists bool, have, want string) string {
+	wantSum := fmt.Sprintf("%x", sha256.Sum256([]byte(want)))
+	if !exists {
+		return fmt.Sprintf("new file: %d bytes, sha256 %s", len(want), wantSum)
+	}
+	haveSum := fmt.Sprintf("%x", sha256.Sum256([]byte(have)))
+	return fmt.Sprintf("content differs: %d → %d bytes, sha256 %s → %s", len(have), len(want), haveSum, wantSum)
+}
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// unifiedDiff returns a unified diff from a to b, or false if the inputs are
+// too large to diff
+func unifiedDiff(fromName, toName, a, b string) (string, bool) {
+	ops, ok := diffLines(splitLines(a), splitLines(b))
+	if !ok {
+		return "", false
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	// oldLine/newLine are the 1-based line numbers each op starts at
+	oldLine := make([]int, len(ops)+1)
+	newLine := make([]int, len(ops)+1)
+	oldLine[0], newLine[0] = 1, 1
+	for i, op := range ops {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if op.kind != '+' {
+			oldLine[i+1]++
+		}
+		if op.kind != '-' {
+			newLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while the next change is within two contexts
+		start := max(i-diffContext, 0)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		end = min(end+diffContext, len(ops))
+
+		oldCount := oldLine[end] - oldLine[start]
+		newCount := newLine[end] - newLine[start]
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldLine[start], oldCount), hunkRange(newLine[start], newCount))
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(strings.TrimSuffix(op.line, "\n"))
+			out.WriteByte('\n')
+			if !strings.HasSuffix(op.line, "\n") {
+				out.WriteString("\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+
+	return out.String(), true
+}
+
+// hunkRange formats a hunk's line range; an empty range names the line
+// before it, as diff(1) does
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// splitLines splits s into lines, keeping each line's newline so a missing
+// one at the end of the file shows up as a change
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes a minimal line edit script via the longest common
+// subsequence
+func diffLines(a, b []string) ([]diffOp, bool) {
+	// Trim the common prefix and suffix, which keeps the table small for
+	// the usual few-line edit
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	n, m := len(midA), len(midB)
+	if (n+1)*(m+1) > maxDiffCells {
+		return nil, false
+	}
+
+	// lcs[i][j] is the LCS length of midA[i:] and midB[j:]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && midA[i] == midB[j]:
+			ops = append(ops, diffOp{' ', midA[i]})
+			i++
+			j++
+		case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', midA[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', midB[j]})
+			j++
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops, true
+}
@@ -0,0 +1,177 @@
+package apply
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "changed line with context",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b:    "1\n2\n3\n4\nfive\n6\n7\n8\n",
+			want: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "new file",
+			a:    "",
+			b:    "x\ny\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+		{
+			name: "missing trailing newline",
+			a:    "x\n",
+			b:    "x",
+			want: "--- a\n+++ b\n@@ -1 +1 @@\n-x\n+x\n\\ No newline at end of file\n",
+		},
+		{
+			name: "distant changes get separate hunks",
+			a:    "a\n1\n2\n3\n4\n5\n6\n7\nb\n",
+			b:    "A\n1\n2\n3\n4\n5\n6\n7\nB\n",
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := unifiedDiff("a", "b", tt.a, tt.b)
+			if !ok {
+				t.Fatal("unifiedDiff() gave up")
+			}
+			if got != tt.want {
+				t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileApplier_DryRunDiff(t *testing.T) {
+	dir := t.TempDir()
+	text := filepath.Join(dir, "app.conf")
+	if err := os.WriteFile(text, []byte("port = 80\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(dir, "app.bin")
+	if err := os.WriteFile(binary, []byte{0x7f, 'E', 'L', 'F', 0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewFileApplier()
+	tests := []struct {
+		name string
+		file config.FileConfig
+		want string
+	}{
+		{
+			name: "text",
+			file: config.FileConfig{Path: config.UnixPath(text), Content: "port = 8080\n"},
+			want: "-port = 80\n+port = 8080\n",
+		},
+		{
+			name: "binary",
+			file: config.FileConfig{Path: config.UnixPath(binary), Content: "\x00\x01"},
+			want: "content differs: 5 → 2 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := a.Apply(context.Background(), tt.file, true)
+			if result.Error != nil {
+				t.Fatalf("Apply() error: %v", result.Error)
+			}
+			if !strings.Contains(result.Diff, tt.want) {
+				t.Errorf("Diff = %q, want it to contain %q", result.Diff, tt.want)
+			}
+		})
+	}
+
+	// Enforce mode leaves the diff to dry-runs
+	result := a.Apply(context.Background(), config.FileConfig{Path: config.UnixPath(text), Content: "port = 8080\n"}, false)
+	if result.Error != nil || result.Diff != "" {
+		t.Errorf("enforce Apply() = %+v, want no error and no diff", result)
+	}
+}
+
+func TestFileApplier_Interpolate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.env")
+	secretFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(secretFile, []byte("s3cr3t-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_DB_PASSWORD", "hunter2")
+
+	a := NewFileApplier()
+	file := config.FileConfig{
+		Path:        config.UnixPath(path),
+		Content:     "DB_PASSWORD=${TEST_DB_PASSWORD}\nTOKEN=${file:" + secretFile + "}\nSHELL_VAR=$${HOME}\n",
+		Interpolate: true,
+	}
+	want := "DB_PASSWORD=hunter2\nTOKEN=s3cr3t-token\nSHELL_VAR=${HOME}\n"
+
+	// Dry-runs show the change with secrets masked
+	result := a.Apply(context.Background(), file, true)
+	if result.Error != nil {
+		t.Fatalf("dry-run Apply() error: %v", result.Error)
+	}
+	if strings.Contains(result.Diff, "hunter2") || strings.Contains(result.Diff, "s3cr3t-token") {
+		t.Errorf("diff leaks a secret:\n%s", result.Diff)
+	}
+	if !strings.Contains(result.Diff, "+DB_PASSWORD="+redacted) {
+		t.Errorf("diff = %q, want the password redacted", result.Diff)
+	}
+
+	result = a.Apply(context.Background(), file, false)
+	if result.Error != nil || !result.Changed {
+		t.Fatalf("Apply() = %+v, want changed without error", result)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+
+	// Drift is measured against the resolved content
+	if result := a.Apply(context.Background(), file, false); result.Error != nil || result.Changed {
+		t.Errorf("second Apply() = %+v, want compliant", result)
+	}
+
+	// Unresolved references fail rather than writing a blank value
+	file.Content = "DB_PASSWORD=${TEST_UNSET_PASSWORD}\n"
+	if result := a.Apply(context.Background(), file, false); result.Error == nil || !strings.Contains(result.Error.Error(), "TEST_UNSET_PASSWORD") {
+		t.Errorf("Apply() error = %v, want it to name the unset variable", result.Error)
+	}
+}
+
+func TestFileApplier_NoInterpolate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "start.sh")
+	t.Setenv("TEST_DB_PASSWORD", "hunter2")
+
+	// Without interpolate, references are content like any other
+	content := "#!/bin/sh\nexec app --password \"${TEST_DB_PASSWORD}\" --home \"${HOME:-/srv}\"\n"
+	a := NewFileApplier()
+	result := a.Apply(context.Background(), config.FileConfig{Path: config.UnixPath(path), Content: content}, false)
+	if result.Error != nil || !result.Changed {
+		t.Fatalf("Apply() = %+v, want changed without error", result)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("content = %q, want it written literally as %q", got, content)
+	}
+}
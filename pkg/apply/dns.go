@@ -0,0 +1,322 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// DNSManager identifies who owns /etc/resolv.conf on this node
+type DNSManager string
+
+const (
+	DNSManagerResolved       DNSManager = "systemd-resolved"
+	DNSManagerNetworkManager DNSManager = "NetworkManager"
+	DNSManagerStatic         DNSManager = "static"
+)
+
+const (
+	defaultResolvConf     = "/etc/resolv.conf"
+	resolvedUpstreamConf  = "/run/systemd/resolve/resolv.conf"
+	resolvedDropInPath    = "/etc/systemd/resolved.conf.d/power-edge.conf"
+	networkManagerDropIn  = "/etc/NetworkManager/conf.d/90-power-edge-dns.conf"
+	networkManagerComment = "# Generated by NetworkManager"
+)
+
+// DNSApplier is the single source of truth for applying resolver configuration.
+// It never overwrites a resolv.conf that is owned by another daemon; settings are
+// applied through a resolved or NetworkManager drop-in instead.
+type DNSApplier struct {
+	resolvConfPath   string
+	resolvedUpstream string
+	resolvedDropIn   string
+	nmDropIn         string
+}
+
+// NewDNSApplier creates a new DNS applier
+func NewDNSApplier() *DNSApplier {
+	return &DNSApplier{
+		resolvConfPath:   defaultResolvConf,
+		resolvedUpstream: resolvedUpstreamConf,
+		resolvedDropIn:   resolvedDropInPath,
+		nmDropIn:         networkManagerDropIn,
+	}
+}
+
+// Apply ensures the effective resolver configuration matches the desired state
+func (a *DNSApplier) Apply(ctx context.Context, dns *config.DNSConfig, dryRun bool) ApplyResult {
+	result := ApplyResult{
+		Actions: []string{},
+	}
+
+	if dns == nil || (len(dns.Nameservers) == 0 && len(dns.Search) == 0) {
+		return result
+	}
+
+	manager, err := a.DetectManager()
+	if err != nil {
+		result.Error = fmt.Errorf("failed to detect resolv.conf owner: %w", err)
+		return result
+	}
+
+	nameservers, search, err := a.Check()
+	if err != nil {
+		result.Error = fmt.Errorf("failed to read effective resolver config: %w", err)
+		return result
+	}
+
+	if dnsCompliant(manager, dns, nameservers, search) {
+		return result
+	}
+
+	result.Changed = true
+
+	switch manager {
+	case DNSManagerResolved:
+		result.Actions = append(result.Actions,
+			fmt.Sprintf("write %s", a.resolvedDropIn),
+			"systemctl restart systemd-resolved")
+		if dryRun {
+			return result
+		}
+		if err := writeDropIn(ctx, a.resolvedDropIn, renderResolvedDropIn(dns)); err != nil {
+			result.Error = fmt.Errorf("failed to write resolved drop-in: %w", err)
+			return result
+		}
+		if err := a.restart(ctx, "systemd-resolved"); err != nil {
+			result.Error = err
+			return result
+		}
+
+	case DNSManagerNetworkManager:
+		result.Actions = append(result.Actions,
+			fmt.Sprintf("write %s", a.nmDropIn),
+			"systemctl reload NetworkManager")
+		if dryRun {
+			return result
+		}
+		if err := writeDropIn(ctx, a.nmDropIn, renderNetworkManagerDropIn(dns)); err != nil {
+			result.Error = fmt.Errorf("failed to write NetworkManager drop-in: %w", err)
+			return result
+		}
+		if err := a.reload(ctx, "NetworkManager"); err != nil {
+			result.Error = err
+			return result
+		}
+
+	default:
+		result.Actions = append(result.Actions,
+			fmt.Sprintf("write %s", a.resolvConfPath),
+			fmt.Sprintf("chattr +i %s", a.resolvConfPath))
+		if dryRun {
+			return result
+		}
+		if err := a.writeProtectedResolvConf(ctx, renderResolvConf(dns)); err != nil {
+			result.Error = err
+			return result
+		}
+	}
+
+	return result
+}
+
+// Check returns the effective nameservers and search domains in use
+func (a *DNSApplier) Check() (nameservers, search []string, err error) {
+	manager, err := a.DetectManager()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// resolv.conf only points at the 127.0.0.53 stub when resolved owns it;
+	// the real upstream servers live in resolved's own resolv.conf
+	path := a.resolvConfPath
+	if manager == DNSManagerResolved {
+		path = a.resolvedUpstream
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	nameservers, search = parseResolvConf(string(data))
+	return nameservers, search, nil
+}
+
+// DetectManager determines whether systemd-resolved, NetworkManager or nobody owns resolv.conf
+func (a *DNSApplier) DetectManager() (DNSManager, error) {
+	info, err := os.Lstat(a.resolvConfPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DNSManagerStatic, nil
+		}
+		return "", err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(a.resolvConfPath)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(target, "systemd/resolve") {
+			return DNSManagerResolved, nil
+		}
+		if strings.Contains(target, "NetworkManager") {
+			return DNSManagerNetworkManager, nil
+		}
+	}
+
+	data, err := os.ReadFile(a.resolvConfPath)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(string(data), networkManagerComment) {
+		return DNSManagerNetworkManager, nil
+	}
+
+	return DNSManagerStatic, nil
+}
+
+// dnsCompliant compares desired settings against the effective resolver config.
+// Daemon-managed resolvers merge our global settings with per-link ones learned
+// via DHCP, so only containment is required there; a static file must match exactly.
+func dnsCompliant(manager DNSManager, dns *config.DNSConfig, nameservers, search []string) bool {
+	if manager == DNSManagerStatic {
+		return (len(dns.Nameservers) == 0 || equalStrings(dns.Nameservers, nameservers)) &&
+			(len(dns.Search) == 0 || equalStrings(dns.Search, search))
+	}
+	return containsAll(nameservers, dns.Nameservers) && containsAll(search, dns.Search)
+}
+
+func parseResolvConf(content string) (nameservers, search []string) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			nameservers = append(nameservers, fields[1])
+		case "search", "domain":
+			search = append(search, fields[1:]...)
+		}
+	}
+	return nameservers, search
+}
+
+func renderResolvConf(dns *config.DNSConfig) string {
+	var b strings.Builder
+	b.WriteString("# Managed by power-edge - do not edit\n")
+	if len(dns.Search) > 0 {
+		fmt.Fprintf(&b, "search %s\n", strings.Join(dns.Search, " "))
+	}
+	for _, ns := range dns.Nameservers {
+		fmt.Fprintf(&b, "nameserver %s\n", ns)
+	}
+	return b.String()
+}
+
+func renderResolvedDropIn(dns *config.DNSConfig) string {
+	var b strings.Builder
+	b.WriteString("# Managed by power-edge - do not edit\n[Resolve]\n")
+	if len(dns.Nameservers) > 0 {
+		fmt.Fprintf(&b, "DNS=%s\n", strings.Join(dns.Nameservers, " "))
+	}
+	if len(dns.Search) > 0 {
+		fmt.Fprintf(&b, "Domains=%s\n", strings.Join(dns.Search, " "))
+	}
+	return b.String()
+}
+
+func renderNetworkManagerDropIn(dns *config.DNSConfig) string {
+	var b strings.Builder
+	b.WriteString("# Managed by power-edge - do not edit\n[global-dns]\n")
+	if len(dns.Search) > 0 {
+		fmt.Fprintf(&b, "searches=%s\n", strings.Join(dns.Search, ","))
+	}
+	if len(dns.Nameservers) > 0 {
+		fmt.Fprintf(&b, "\n[global-dns-domain-*]\nservers=%s\n", strings.Join(dns.Nameservers, ","))
+	}
+	return b.String()
+}
+
+// writeDropIn writes a resolver drop-in under /etc through the privilege
+// command, as resolv.conf is
+func writeDropIn(ctx context.Context, path, content string) error {
+	if err := mkdirPrivileged(ctx, filepath.Dir(path), defaultDirMode); err != nil {
+		return err
+	}
+	return writePrivileged(ctx, path, content, "0644")
+}
+
+// writeProtectedResolvConf replaces resolv.conf and marks it immutable so DHCP
+// clients can't overwrite it behind our back. The file is written through the
+// privilege command, like chattr, since only root can write it.
+func (a *DNSApplier) writeProtectedResolvConf(ctx context.Context, content string) error {
+	// Clear a previous immutable bit, or the write below fails
+	if _, err := os.Lstat(a.resolvConfPath); err == nil {
+		output, err := runCombined(ctx, "sudo", "chattr", "-i", a.resolvConfPath)
+		if err != nil {
+			return fmt.Errorf("failed to unprotect %s: %s (output: %s)", a.resolvConfPath, err, string(output))
+		}
+	}
+
+	output, err := runCombinedInput(ctx, strings.NewReader(content), "sudo", "dd", "of="+a.resolvConfPath, "status=none")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %s (output: %s)", a.resolvConfPath, err, string(output))
+	}
+
+	output, err = runCombined(ctx, "sudo", "chattr", "+i", a.resolvConfPath)
+	if err != nil {
+		return fmt.Errorf("failed to protect %s: %s (output: %s)", a.resolvConfPath, err, string(output))
+	}
+	return nil
+}
+
+func (a *DNSApplier) restart(ctx context.Context, unit string) error {
+	output, err := runCombined(ctx, "sudo", "systemctl", "restart", unit)
+	if err != nil {
+		return fmt.Errorf("failed to restart %s: %s (output: %s)", unit, err, string(output))
+	}
+	return nil
+}
+
+func (a *DNSApplier) reload(ctx context.Context, unit string) error {
+	output, err := runCombined(ctx, "sudo", "systemctl", "reload", unit)
+	if err != nil {
+		return fmt.Errorf("failed to reload %s: %s (output: %s)", unit, err, string(output))
+	}
+	return nil
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAll(have, want []string) bool {
+	set := make(map[string]bool, len(have))
+	for _, v := range have {
+		set[v] = true
+	}
+	for _, v := range want {
+		if !set[v] {
+			return false
+		}
+	}
+	return true
+}
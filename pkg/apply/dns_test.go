@@ -0,0 +1,263 @@
+package apply
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func newTestDNSApplier(t *testing.T) *DNSApplier {
+	tmpDir := t.TempDir()
+	return &DNSApplier{
+		resolvConfPath:   filepath.Join(tmpDir, "resolv.conf"),
+		resolvedUpstream: filepath.Join(tmpDir, "run-resolv.conf"),
+		resolvedDropIn:   filepath.Join(tmpDir, "resolved.conf.d", "power-edge.conf"),
+		nmDropIn:         filepath.Join(tmpDir, "NetworkManager", "conf.d", "90-power-edge-dns.conf"),
+	}
+}
+
+func TestDNSApplier_DetectManager(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(a *DNSApplier) error
+		want  DNSManager
+	}{
+		{
+			name: "static file",
+			setup: func(a *DNSApplier) error {
+				return os.WriteFile(a.resolvConfPath, []byte("nameserver 1.1.1.1\n"), 0644)
+			},
+			want: DNSManagerStatic,
+		},
+		{
+			name: "symlink to systemd-resolved stub",
+			setup: func(a *DNSApplier) error {
+				return os.Symlink("/run/systemd/resolve/stub-resolv.conf", a.resolvConfPath)
+			},
+			want: DNSManagerResolved,
+		},
+		{
+			name: "generated by NetworkManager",
+			setup: func(a *DNSApplier) error {
+				return os.WriteFile(a.resolvConfPath, []byte("# Generated by NetworkManager\nnameserver 10.0.0.1\n"), 0644)
+			},
+			want: DNSManagerNetworkManager,
+		},
+		{
+			name:  "missing file",
+			setup: func(a *DNSApplier) error { return nil },
+			want:  DNSManagerStatic,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestDNSApplier(t)
+			if err := tt.setup(a); err != nil {
+				t.Fatalf("setup failed: %v", err)
+			}
+
+			got, err := a.DetectManager()
+			if err != nil {
+				t.Fatalf("DetectManager() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DetectManager() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSApplier_Apply(t *testing.T) {
+	dns := &config.DNSConfig{
+		Nameservers: []string{"1.1.1.1", "9.9.9.9"},
+		Search:      []string{"lab.local"},
+	}
+
+	t.Run("static drift in dry-run", func(t *testing.T) {
+		a := newTestDNSApplier(t)
+		original := "nameserver 192.168.1.1\n"
+		if err := os.WriteFile(a.resolvConfPath, []byte(original), 0644); err != nil {
+			t.Fatalf("Failed to create resolv.conf: %v", err)
+		}
+
+		result := a.Apply(context.Background(), dns, true)
+		if result.Error != nil {
+			t.Fatalf("Apply() error = %v", result.Error)
+		}
+		if !result.Changed {
+			t.Error("Expected drift to be detected")
+		}
+
+		content, _ := os.ReadFile(a.resolvConfPath)
+		if string(content) != original {
+			t.Error("Dry-run must not modify resolv.conf")
+		}
+	})
+
+	t.Run("static compliant", func(t *testing.T) {
+		a := newTestDNSApplier(t)
+		if err := os.WriteFile(a.resolvConfPath, []byte(renderResolvConf(dns)), 0644); err != nil {
+			t.Fatalf("Failed to create resolv.conf: %v", err)
+		}
+
+		result := a.Apply(context.Background(), dns, true)
+		if result.Error != nil {
+			t.Fatalf("Apply() error = %v", result.Error)
+		}
+		if result.Changed {
+			t.Errorf("Expected compliant, got actions %v", result.Actions)
+		}
+	})
+
+	t.Run("resolved compares upstream servers", func(t *testing.T) {
+		a := newTestDNSApplier(t)
+		if err := os.Symlink("/run/systemd/resolve/stub-resolv.conf", a.resolvConfPath); err != nil {
+			t.Fatalf("Failed to create symlink: %v", err)
+		}
+		// DHCP-provided link servers are merged with our global ones
+		upstream := "nameserver 1.1.1.1\nnameserver 9.9.9.9\nnameserver 192.168.1.1\nsearch lab.local dhcp.local\n"
+		if err := os.WriteFile(a.resolvedUpstream, []byte(upstream), 0644); err != nil {
+			t.Fatalf("Failed to create upstream resolv.conf: %v", err)
+		}
+
+		result := a.Apply(context.Background(), dns, true)
+		if result.Error != nil {
+			t.Fatalf("Apply() error = %v", result.Error)
+		}
+		if result.Changed {
+			t.Errorf("Expected compliant, got actions %v", result.Actions)
+		}
+	})
+
+	t.Run("resolved drift uses drop-in", func(t *testing.T) {
+		a := newTestDNSApplier(t)
+		if err := os.Symlink("/run/systemd/resolve/stub-resolv.conf", a.resolvConfPath); err != nil {
+			t.Fatalf("Failed to create symlink: %v", err)
+		}
+
+		result := a.Apply(context.Background(), dns, true)
+		if result.Error != nil {
+			t.Fatalf("Apply() error = %v", result.Error)
+		}
+		if !result.Changed || len(result.Actions) == 0 {
+			t.Fatal("Expected drift to be detected")
+		}
+		if result.Actions[0] != "write "+a.resolvedDropIn {
+			t.Errorf("Expected resolved drop-in action, got %v", result.Actions)
+		}
+	})
+}
+
+func TestDNSApplier_WriteProtectedResolvConf(t *testing.T) {
+	dns := &config.DNSConfig{Nameservers: []string{"1.1.1.1"}}
+
+	// Record every privileged command, and stand in for chattr, which
+	// temporary filesystems don't support
+	setup := func(t *testing.T, chattr string) (*DNSApplier, context.Context, string) {
+		a := newTestDNSApplier(t)
+		bin := t.TempDir()
+		log := filepath.Join(bin, "commands.log")
+		priv := filepath.Join(bin, "priv")
+		if err := os.WriteFile(priv, []byte("#!/bin/sh\necho \"$@\" >> "+log+"\nexec \"$@\"\n"), 0755); err != nil {
+			t.Fatalf("Failed to create privilege command: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(bin, "chattr"), []byte("#!/bin/sh\n"+chattr+"\n"), 0755); err != nil {
+			t.Fatalf("Failed to create chattr: %v", err)
+		}
+		t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+		return a, WithPrivilegeCommand(context.Background(), []string{priv}), log
+	}
+
+	t.Run("writes through the privilege command", func(t *testing.T) {
+		a, ctx, log := setup(t, "exit 0")
+		if err := os.WriteFile(a.resolvConfPath, []byte("nameserver 192.168.1.1\n"), 0644); err != nil {
+			t.Fatalf("Failed to create resolv.conf: %v", err)
+		}
+
+		result := a.Apply(ctx, dns, false)
+		if result.Error != nil {
+			t.Fatalf("Apply() error = %v", result.Error)
+		}
+		if content, _ := os.ReadFile(a.resolvConfPath); string(content) != renderResolvConf(dns) {
+			t.Errorf("resolv.conf = %q, want %q", content, renderResolvConf(dns))
+		}
+
+		commands, _ := os.ReadFile(log)
+		want := "chattr -i " + a.resolvConfPath + "\n" +
+			"dd of=" + a.resolvConfPath + " status=none\n" +
+			"chattr +i " + a.resolvConfPath + "\n"
+		if string(commands) != want {
+			t.Errorf("privileged commands = %q, want %q", commands, want)
+		}
+	})
+
+	t.Run("unprotect failure", func(t *testing.T) {
+		a, ctx, _ := setup(t, "echo 'Operation not permitted' >&2; exit 1")
+		original := "nameserver 192.168.1.1\n"
+		if err := os.WriteFile(a.resolvConfPath, []byte(original), 0644); err != nil {
+			t.Fatalf("Failed to create resolv.conf: %v", err)
+		}
+
+		result := a.Apply(ctx, dns, false)
+		if result.Error == nil || !strings.Contains(result.Error.Error(), "failed to unprotect") {
+			t.Errorf("Apply() error = %v, want the chattr -i failure", result.Error)
+		}
+		if content, _ := os.ReadFile(a.resolvConfPath); string(content) != original {
+			t.Error("resolv.conf must not be written when it can't be unprotected")
+		}
+	})
+}
+
+func TestDNSApplier_DropInThroughSudo(t *testing.T) {
+	dns := &config.DNSConfig{Nameservers: []string{"1.1.1.1"}}
+	a := newTestDNSApplier(t)
+	if err := os.Symlink("/run/systemd/resolve/stub-resolv.conf", a.resolvConfPath); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	log := fakeSudo(t, 0)
+
+	result := a.Apply(context.Background(), dns, false)
+	if result.Error != nil {
+		t.Fatalf("Apply() error = %v", result.Error)
+	}
+
+	// The drop-in and its directory are only created by the privilege command
+	dir := filepath.Dir(a.resolvedDropIn)
+	tmp := filepath.Join(dir, ".power-edge.conf.power-edge-tmp")
+	want := []string{
+		"install -d -m 0755 " + dir,
+		"install -m 0644 /dev/null " + tmp,
+		"dd of=" + tmp + " status=none",
+		"mv -f " + tmp + " " + a.resolvedDropIn,
+		"systemctl restart systemd-resolved",
+	}
+	if calls := sudoCalls(t, log); !reflect.DeepEqual(calls, want) {
+		t.Errorf("sudo calls = %q, want %q", calls, want)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("drop-in directory created without sudo: %v", err)
+	}
+}
+
+func TestParseResolvConf(t *testing.T) {
+	content := `# comment
+nameserver 1.1.1.1
+nameserver  9.9.9.9
+search lab.local corp.local
+options edns0
+`
+	nameservers, search := parseResolvConf(content)
+
+	if !equalStrings(nameservers, []string{"1.1.1.1", "9.9.9.9"}) {
+		t.Errorf("Unexpected nameservers: %v", nameservers)
+	}
+	if !equalStrings(search, []string{"lab.local", "corp.local"}) {
+		t.Errorf("Unexpected search domains: %v", search)
+	}
+}
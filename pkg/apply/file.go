@@ -1,27 +1,107 @@
 package apply
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/power-edge/power-edge/pkg/config"
 )
 
+const (
+	defaultFileMode      os.FileMode = 0644
+	defaultDirMode       os.FileMode = 0755
+	defaultSourceTimeout             = 30 * time.Second
+)
+
 // FileApplier is the single source of truth for applying file state
-type FileApplier struct{}
+type FileApplier struct {
+	templateData TemplateData
+	transport    http.RoundTripper // Used for https sources (nil = http.DefaultTransport)
+	selinux      bool              // Whether file contexts are managed
+	cache        *SourceCache      // Downloaded https sources (nil = not cached)
+}
+
+// TemplateData is the data context available to templated file content,
+// e.g. {{ .Hostname }}, {{ .Site }} or {{ index .Labels "role" }}
+type TemplateData struct {
+	config.Metadata
+	Hostname string
+}
+
+// NewTemplateData builds the template context for a node's state metadata
+func NewTemplateData(meta config.Metadata) TemplateData {
+	hostname, _ := os.Hostname()
+	return TemplateData{
+		Metadata: meta,
+		Hostname: hostname,
+	}
+}
 
 // NewFileApplier creates a new file applier
 func NewFileApplier() *FileApplier {
-	return &FileApplier{}
+	return &FileApplier{
+		templateData: NewTemplateData(config.Metadata{}),
+		selinux:      selinuxEnabled(),
+	}
+}
+
+// selinuxEnabled reports whether the kernel has SELinux enabled, the same
+// check is_selinux_enabled(3) makes
+func selinuxEnabled() bool {
+	info, err := os.Stat("/sys/fs/selinux/enforce")
+	return err == nil && info.Mode().IsRegular()
+}
+
+// SetTemplateData sets the data context used to render templated files
+func (a *FileApplier) SetTemplateData(data TemplateData) {
+	a.templateData = data
+}
+
+// SetSourceCache keeps https sources with a declared checksum in cache, so
+// they're only downloaded once
+func (a *FileApplier) SetSourceCache(cache *SourceCache) {
+	a.cache = cache
+}
+
+// Apply ensures a file matches its desired state. With file.Transactional
+// a regular file is snapshotted first and restored if any step fails, so it
+// is never left half-applied (e.g. new content with the old owner).
+func (a *FileApplier) Apply(ctx context.Context, file config.FileConfig, dryRun bool) ApplyResult {
+	regular := file.Type == "" || file.Type == config.FileTypeFile
+	if !file.Transactional || dryRun || file.State == config.FileStateAbsent || !regular {
+		return a.apply(ctx, file, dryRun)
+	}
+
+	snap, err := a.snapshot(ctx, string(file.Path))
+	if err != nil {
+		return ApplyResult{
+			Actions: []string{},
+			Error:   fmt.Errorf("failed to snapshot %s: %w", file.Path, err),
+		}
+	}
+
+	result := a.apply(ctx, file, dryRun)
+	if result.Error != nil && result.Changed {
+		result.Actions = append(result.Actions, "rollback "+snap.path)
+		result.Error = &RollbackError{Err: result.Error, RollbackErr: a.restore(ctx, snap)}
+	}
+	return result
 }
 
-// Apply ensures a file matches its desired state
-func (a *FileApplier) Apply(file config.FileConfig, dryRun bool) ApplyResult {
+func (a *FileApplier) apply(ctx context.Context, file config.FileConfig, dryRun bool) ApplyResult {
 	result := ApplyResult{
 		Actions: []string{},
 	}
@@ -36,33 +116,49 @@ func (a *FileApplier) Apply(file config.FileConfig, dryRun bool) ApplyResult {
 		return result
 	}
 
-	// Handle content if specified
-	if file.Content != "" {
-		if !exists || a.needsContentUpdate(path, file.Content, file.SHA256) {
-			result.Changed = true
-			result.Actions = append(result.Actions, fmt.Sprintf("write content to %s", path))
-			if !dryRun {
-				if err := a.writeContent(path, file.Content); err != nil {
-					result.Error = fmt.Errorf("failed to write content: %w", err)
-					return result
-				}
-			}
+	// Handle removal
+	if file.State == config.FileStateAbsent {
+		return a.applyAbsent(path, file.Type, dryRun, result)
+	}
+
+	switch file.Type {
+	case config.FileTypeSymlink:
+		// Mode and ownership of a symlink are meaningless; only the target matters
+		return a.applySymlink(file, dryRun, result)
+
+	case config.FileTypeDirectory:
+		if err := a.applyDirectory(file, exists, dryRun, &result); err != nil {
+			result.Error = err
+			return result
+		}
+
+	default:
+		if err := a.applyContent(ctx, file, exists, dryRun, &result); err != nil {
+			result.Error = err
+			return result
 		}
 	}
+	written := result.Changed
 
 	// Handle permissions if specified
 	if file.Mode != "" {
+		// Both sides in canonical form, so 644 matches an existing 0644
+		wantMode, err := NormalizeMode(file.Mode)
+		if err != nil {
+			result.Error = err
+			return result
+		}
 		currentMode, err := a.getMode(path)
 		if err != nil && exists {
 			result.Error = fmt.Errorf("failed to get file mode: %w", err)
 			return result
 		}
 
-		if exists && currentMode != file.Mode {
+		if exists && currentMode != wantMode {
 			result.Changed = true
-			result.Actions = append(result.Actions, fmt.Sprintf("chmod %s %s", file.Mode, path))
+			result.Actions = append(result.Actions, fmt.Sprintf("chmod %s %s", wantMode, path))
 			if !dryRun {
-				if err := a.setMode(path, file.Mode); err != nil {
+				if err := a.setMode(path, wantMode); err != nil {
 					result.Error = fmt.Errorf("failed to set mode: %w", err)
 					return result
 				}
@@ -72,7 +168,7 @@ func (a *FileApplier) Apply(file config.FileConfig, dryRun bool) ApplyResult {
 
 	// Handle ownership if specified
 	if file.Owner != "" || file.Group != "" {
-		currentOwner, currentGroup, err := a.getOwnership(path)
+		currentOwner, currentGroup, err := a.getOwnership(ctx, path)
 		if err != nil && exists {
 			result.Error = fmt.Errorf("failed to get ownership: %w", err)
 			return result
@@ -91,7 +187,7 @@ func (a *FileApplier) Apply(file config.FileConfig, dryRun bool) ApplyResult {
 			result.Changed = true
 			result.Actions = append(result.Actions, fmt.Sprintf("chown %s:%s %s", owner, group, path))
 			if !dryRun {
-				if err := a.setOwnership(path, owner, group); err != nil {
+				if err := a.setOwnership(ctx, path, owner, group); err != nil {
 					result.Error = fmt.Errorf("failed to set ownership: %w", err)
 					return result
 				}
@@ -99,32 +195,581 @@ func (a *FileApplier) Apply(file config.FileConfig, dryRun bool) ApplyResult {
 		}
 	}
 
+	// Handle the SELinux context; there is nothing to label without SELinux
+	if a.selinux {
+		if err := a.applySELinuxContext(ctx, file, written, dryRun, &result); err != nil {
+			result.Error = err
+			return result
+		}
+	}
+
 	return result
 }
 
-// Check returns current file state
-func (a *FileApplier) Check(path string) (exists bool, mode, owner, group, sha256sum string, err error) {
+// applySELinuxContext keeps the file's SELinux context in line with state.
+// An explicit context is enforced whenever it drifts. Without one, a file
+// written by this pass is relabelled with restorecon, since the temp file it
+// was renamed from was labelled for its directory rather than its path.
+func (a *FileApplier) applySELinuxContext(ctx context.Context, file config.FileConfig, written, dryRun bool, result *ApplyResult) error {
+	path := string(file.Path)
+	want := file.SELinuxContext
+
+	if want == "" {
+		if !written {
+			return nil
+		}
+		result.Changed = true
+		result.Actions = append(result.Actions, "restorecon "+path)
+		if dryRun {
+			return nil
+		}
+		if output, err := runCombined(ctx, "restorecon", path); err != nil {
+			return fmt.Errorf("restorecon failed: %s (output: %s)", err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+
+	// A file that doesn't exist yet only does so in dry-run
+	exists, err := a.exists(path)
+	if err != nil {
+		return fmt.Errorf("failed to check file existence: %w", err)
+	}
+	if exists {
+		current, err := a.getSELinuxContext(ctx, path)
+		if err != nil {
+			return fmt.Errorf("failed to get SELinux context: %w", err)
+		}
+		if selinuxContextMatches(current, want) {
+			return nil
+		}
+	}
+
+	args := chconArgs(want, path)
+	result.Changed = true
+	result.Actions = append(result.Actions, "chcon "+strings.Join(args, " "))
+	if dryRun {
+		return nil
+	}
+	if output, err := runCombined(ctx, "chcon", args...); err != nil {
+		return fmt.Errorf("chcon failed: %s (output: %s)", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// RollbackError reports a failed transactional file apply and whether the
+// file was restored to its previous state
+type RollbackError struct {
+	Err         error // Why the apply failed
+	RollbackErr error // Why restoring the previous state failed; nil if it was restored
+}
+
+func (e *RollbackError) Error() string {
+	if e.RollbackErr != nil {
+		return fmt.Sprintf("%v (rollback failed: %v)", e.Err, e.RollbackErr)
+	}
+	return fmt.Sprintf("%v (rolled back)", e.Err)
+}
+
+func (e *RollbackError) Unwrap() error { return e.Err }
+
+// fileSnapshot is the state of a regular file before a transactional apply
+type fileSnapshot struct {
+	path         string
+	exists       bool
+	content      []byte
+	mode         string
+	owner, group string
+	seContext    string
+}
+
+// snapshot records what restore needs to put path back as it is now
+func (a *FileApplier) snapshot(ctx context.Context, path string) (fileSnapshot, error) {
+	snap := fileSnapshot{path: path}
+
+	exists, err := a.exists(path)
+	if err != nil || !exists {
+		return snap, err
+	}
+	snap.exists = true
+
+	if snap.content, err = os.ReadFile(path); err != nil {
+		return snap, err
+	}
+	if snap.mode, err = a.getMode(path); err != nil {
+		return snap, err
+	}
+	if snap.owner, snap.group, err = a.getOwnership(ctx, path); err != nil {
+		return snap, err
+	}
+	if a.selinux {
+		if snap.seContext, err = a.getSELinuxContext(ctx, path); err != nil {
+			return snap, err
+		}
+	}
+	return snap, nil
+}
+
+// restore puts a file back as snap recorded it, removing it if it didn't
+// exist. Parent directories created on the way are left in place.
+func (a *FileApplier) restore(ctx context.Context, snap fileSnapshot) error {
+	if !snap.exists {
+		if err := os.Remove(snap.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := writeContent(snap.path, string(snap.content), snap.mode); err != nil {
+		return fmt.Errorf("failed to restore content: %w", err)
+	}
+	// The failed apply may have changed the owner, so only chown if that differs
+	owner, group, err := a.getOwnership(ctx, snap.path)
+	if err != nil {
+		return fmt.Errorf("failed to get ownership: %w", err)
+	}
+	if owner != snap.owner || group != snap.group {
+		if err := a.setOwnership(ctx, snap.path, snap.owner, snap.group); err != nil {
+			return fmt.Errorf("failed to restore ownership: %w", err)
+		}
+	}
+	if snap.seContext != "" {
+		if output, err := runCombined(ctx, "chcon", snap.seContext, snap.path); err != nil {
+			return fmt.Errorf("chcon failed: %s (output: %s)", err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// selinuxContextMatches compares a file's full context with the desired one,
+// which may name only the type (e.g. httpd_config_t)
+func selinuxContextMatches(current, want string) bool {
+	if strings.Contains(want, ":") {
+		return current == want
+	}
+	fields := strings.SplitN(current, ":", 4)
+	return len(fields) >= 3 && fields[2] == want
+}
+
+// chconArgs returns the chcon arguments setting context want on path
+func chconArgs(want, path string) []string {
+	if strings.Contains(want, ":") {
+		return []string{want, path}
+	}
+	return []string{"-t", want, path}
+}
+
+// applyContent writes the desired content of a regular file, creating missing
+// parent directories first
+func (a *FileApplier) applyContent(ctx context.Context, file config.FileConfig, exists, dryRun bool, result *ApplyResult) error {
+	if file.Content == "" && file.Source == "" {
+		return nil
+	}
+
+	path := string(file.Path)
+	if file.Content != "" && file.Source != "" {
+		return fmt.Errorf("%s: content and source are mutually exclusive", path)
+	}
+
+	if file.Source != "" {
+		// A matching checksum on disk means there's nothing to fetch
+		if exists && file.SHA256 != "" && !file.Template && !file.Interpolate {
+			if actual, err := a.getSHA256(path); err == nil && actual == file.SHA256 {
+				return nil
+			}
+		}
+
+		fetched, err := a.fetchCached(ctx, file, result)
+		if err != nil {
+			return err
+		}
+		file.Content = fetched
+	}
+
+	content, expectedSHA256 := file.Content, file.SHA256
+	if file.Template {
+		rendered, err := a.Render(file)
+		if err != nil {
+			return err
+		}
+		// Drift is measured against the rendered output, not the raw template
+		content = rendered
+		expectedSHA256 = fmt.Sprintf("%x", sha256.Sum256([]byte(rendered)))
+	}
+
+	var secrets []string
+	if file.Interpolate {
+		resolved, values, err := config.Interpolate(content)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		content, secrets = resolved, values
+		expectedSHA256 = fmt.Sprintf("%x", sha256.Sum256([]byte(resolved)))
+	}
+
+	if !exists || a.needsContentUpdate(path, content, expectedSHA256) {
+		if !exists {
+			if err := a.ensureParent(path, file.DirMode, dryRun, result); err != nil {
+				return err
+			}
+		}
+
+		// Nothing is written, nor backed up, until the new content passes
+		if file.ValidateCmd != "" {
+			if err := validateContent(ctx, file, content, secrets); err != nil {
+				return err
+			}
+			result.Notes = append(result.Notes, fmt.Sprintf("new content passed %q", file.ValidateCmd))
+		}
+
+		result.Changed = true
+		if exists && file.Backup {
+			result.Actions = append(result.Actions, fmt.Sprintf("backup %s to %s.bak", path, path))
+			if !dryRun {
+				if err := a.backup(path); err != nil {
+					return fmt.Errorf("failed to back up file: %w", err)
+				}
+			}
+		}
+		result.Actions = append(result.Actions, fmt.Sprintf("write content to %s", path))
+		if dryRun {
+			// Content fetched from a source is pinned by its checksum
+			result.Diff = redact(contentDiff(path, exists, content, file.Source != "" && file.SHA256 != ""), secrets)
+		} else {
+			if err := writeContent(path, content, file.Mode); err != nil {
+				return fmt.Errorf("failed to write content: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateContent runs the file's validate command against content written
+// to a temporary file, whose quoted path replaces %s, failing if the command
+// exits non-zero. It runs the same in dry-run, so a plan shows content that
+// would be rejected. Secrets the command echoes are redacted from the error.
+func validateContent(ctx context.Context, file config.FileConfig, content string, secrets []string) error {
+	tmp, err := os.CreateTemp("", "power-edge-validate-*-"+filepath.Base(string(file.Path)))
+	if err != nil {
+		return fmt.Errorf("failed to create file to validate: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write file to validate: %w", err)
+	}
+
+	command := strings.ReplaceAll(file.ValidateCmd, "%s", shellQuote(tmp.Name()))
+	output, err := runCombined(ctx, "sh", "-c", command)
+	if err != nil {
+		return fmt.Errorf("new content failed validation with %q: %w (output: %s)", file.ValidateCmd, err, redact(strings.TrimSpace(string(output)), secrets))
+	}
+	return nil
+}
+
+// shellQuote quotes s as a single word for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fetchCached serves an https source from the source cache when it holds the
+// declared checksum, and only downloads it, then caches it, on a miss
+func (a *FileApplier) fetchCached(ctx context.Context, file config.FileConfig, result *ApplyResult) (string, error) {
+	if a.cache == nil || file.SHA256 == "" || !strings.HasPrefix(file.Source, "https://") {
+		return a.fetchSource(ctx, file)
+	}
+	if data, ok := a.cache.Get(file.SHA256); ok {
+		result.Notes = append(result.Notes, fmt.Sprintf("using cached copy of %s (sha256 %s)", file.Source, file.SHA256))
+		return string(data), nil
+	}
+
+	content, err := a.fetchSource(ctx, file)
+	if err != nil {
+		return "", err
+	}
+	// fetchSource verified the checksum, so the entry is what was declared
+	if err := a.cache.Put(file.SHA256, []byte(content)); err != nil {
+		result.Notes = append(result.Notes, fmt.Sprintf("failed to cache %s: %v", file.Source, err))
+	}
+	return content, nil
+}
+
+// fetchSource reads the file's source URL and verifies it against the expected
+// checksum. Nothing is written here, so a mismatch leaves the destination untouched.
+func (a *FileApplier) fetchSource(ctx context.Context, file config.FileConfig) (string, error) {
+	u, err := url.Parse(file.Source)
+	if err != nil {
+		return "", fmt.Errorf("invalid source %q: %w", file.Source, err)
+	}
+
+	var data []byte
+	switch u.Scheme {
+	case "file":
+		data, err = os.ReadFile(u.Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read source %s: %w", file.Source, err)
+		}
+
+	case "https":
+		timeout := defaultSourceTimeout
+		if file.SourceTimeout > 0 {
+			timeout = time.Duration(file.SourceTimeout) * time.Second
+		}
+		client := &http.Client{Transport: a.transport, Timeout: timeout}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, file.Source, nil)
+		if err != nil {
+			return "", fmt.Errorf("invalid source %q: %w", file.Source, err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch source %s: %w", file.Source, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("failed to fetch source %s: server returned %s", file.Source, resp.Status)
+		}
+
+		data, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch source %s: %w", file.Source, err)
+		}
+
+	default:
+		return "", fmt.Errorf("unsupported source scheme %q (want file:// or https://)", u.Scheme)
+	}
+
+	if file.SHA256 != "" {
+		actual := fmt.Sprintf("%x", sha256.Sum256(data))
+		if actual != file.SHA256 {
+			return "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", file.Source, file.SHA256, actual)
+		}
+	}
+
+	return string(data), nil
+}
+
+// applyDirectory ensures a directory exists, creating it (and its parents) if missing
+func (a *FileApplier) applyDirectory(file config.FileConfig, exists, dryRun bool, result *ApplyResult) error {
+	path := string(file.Path)
+
+	if exists {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to stat directory: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s exists but is not a directory", path)
+		}
+		return nil
+	}
+
+	mode := file.Mode
+	if mode == "" {
+		mode = file.DirMode
+	}
+	perm, err := parseMode(mode, defaultDirMode)
+	if err != nil {
+		return err
+	}
+
+	result.Changed = true
+	result.Actions = append(result.Actions, fmt.Sprintf("mkdir -p -m %s %s", formatMode(perm), path))
+	if dryRun {
+		return nil
+	}
+
+	if err := os.MkdirAll(path, perm); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	// MkdirAll is subject to the umask
+	return os.Chmod(path, perm)
+}
+
+// applySymlink ensures path is a symlink pointing at file.Target. An existing
+// link is swapped atomically; a regular file or directory is never replaced.
+func (a *FileApplier) applySymlink(file config.FileConfig, dryRun bool, result ApplyResult) ApplyResult {
+	path := string(file.Path)
+
+	if file.Target == "" {
+		result.Error = fmt.Errorf("symlink %s requires a target", path)
+		return result
+	}
+
+	info, err := os.Lstat(path)
+	switch {
+	case err == nil && info.Mode()&os.ModeSymlink == 0:
+		result.Error = fmt.Errorf("refusing to replace %s with a symlink: path exists and is not a symlink", path)
+		return result
+	case err == nil:
+		current, err := os.Readlink(path)
+		if err != nil {
+			result.Error = fmt.Errorf("failed to read symlink: %w", err)
+			return result
+		}
+		if current == file.Target {
+			return result
+		}
+	case !os.IsNotExist(err):
+		result.Error = fmt.Errorf("failed to check symlink: %w", err)
+		return result
+	}
+
+	if err := a.ensureParent(path, file.DirMode, dryRun, &result); err != nil {
+		result.Error = err
+		return result
+	}
+
+	result.Changed = true
+	result.Actions = append(result.Actions, fmt.Sprintf("ln -sfn %s %s", file.Target, path))
+	if dryRun {
+		return result
+	}
+
+	// Create the link under a temp name and rename it into place
+	tmp := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.tmp-%d", filepath.Base(path), os.Getpid()))
+	os.Remove(tmp)
+	if err := os.Symlink(file.Target, tmp); err != nil {
+		result.Error = fmt.Errorf("failed to create symlink: %w", err)
+		return result
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		result.Error = fmt.Errorf("failed to create symlink: %w", err)
+		return result
+	}
+
+	return result
+}
+
+// ensureParent creates the parent directories of path if they are missing
+func (a *FileApplier) ensureParent(path, dirMode string, dryRun bool, result *ApplyResult) error {
+	parent := filepath.Dir(path)
+
+	if _, err := os.Stat(parent); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check parent directory: %w", err)
+	}
+
+	perm, err := parseMode(dirMode, defaultDirMode)
+	if err != nil {
+		return err
+	}
+
+	result.Changed = true
+	result.Actions = append(result.Actions, fmt.Sprintf("mkdir -p -m %s %s", formatMode(perm), parent))
+	if dryRun {
+		return nil
+	}
+
+	// Note which directories are missing, so only those get perm
+	var created []string
+	for dir := parent; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil || dir == filepath.Dir(dir) {
+			break
+		}
+		created = append(created, dir)
+	}
+
+	if err := os.MkdirAll(parent, perm); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+	// MkdirAll is subject to the umask
+	for _, dir := range created {
+		if err := os.Chmod(dir, perm); err != nil {
+			return fmt.Errorf("failed to set parent directory mode: %w", err)
+		}
+	}
+	return nil
+}
+
+// Render executes the file's content as a text/template against the node's
+// template data. Missing keys are treated as errors rather than rendering as
+// "<no value>" into a config file.
+func (a *FileApplier) Render(file config.FileConfig) (string, error) {
+	tmpl, err := template.New(string(file.Path)).Option("missingkey=error").Parse(file.Content)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template for %s: %w", file.Path, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, a.templateData); err != nil {
+		return "", fmt.Errorf("failed to render template for %s: %w", file.Path, err)
+	}
+	return buf.String(), nil
+}
+
+// applyAbsent ensures the file, symlink or (empty) directory does not exist
+func (a *FileApplier) applyAbsent(path string, fileType config.FileType, dryRun bool, result ApplyResult) ApplyResult {
+	// Lstat rather than the exists check so dangling symlinks are removed too
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return result
+	}
+	if err != nil {
+		result.Error = fmt.Errorf("failed to stat file: %w", err)
+		return result
+	}
+
+	action := fmt.Sprintf("rm %s", path)
+	if info.IsDir() {
+		if fileType != config.FileTypeDirectory {
+			result.Error = fmt.Errorf("refusing to remove %s: path is a directory, not a file", path)
+			return result
+		}
+		// os.Remove only removes empty directories, which is what we want
+		action = fmt.Sprintf("rmdir %s", path)
+	}
+
+	result.Changed = true
+	result.Actions = append(result.Actions, action)
+	if !dryRun {
+		if err := os.Remove(path); err != nil {
+			result.Error = fmt.Errorf("failed to remove file: %w", err)
+			return result
+		}
+	}
+
+	return result
+}
+
+// Check returns current file state. The SELinux context is empty on hosts
+// without SELinux.
+func (a *FileApplier) Check(ctx context.Context, path string) (exists bool, mode, owner, group, sha256sum, seContext string, err error) {
 	exists, err = a.exists(path)
 	if err != nil || !exists {
-		return false, "", "", "", "", err
+		return false, "", "", "", "", "", err
 	}
 
 	mode, err = a.getMode(path)
 	if err != nil {
-		return true, "", "", "", "", err
+		return true, "", "", "", "", "", err
 	}
 
-	owner, group, err = a.getOwnership(path)
+	owner, group, err = a.getOwnership(ctx, path)
 	if err != nil {
-		return true, mode, "", "", "", err
+		return true, mode, "", "", "", "", err
 	}
 
 	sha256sum, err = a.getSHA256(path)
 	if err != nil {
-		return true, mode, owner, group, "", err
+		return true, mode, owner, group, "", "", err
+	}
+
+	if a.selinux {
+		seContext, err = a.getSELinuxContext(ctx, path)
+		if err != nil {
+			return true, mode, owner, group, sha256sum, "", err
+		}
 	}
 
-	return true, mode, owner, group, sha256sum, nil
+	return true, mode, owner, group, sha256sum, seContext, nil
 }
 
 func (a *FileApplier) exists(path string) (bool, error) {
@@ -156,8 +801,187 @@ func (a *FileApplier) needsContentUpdate(path, content, expectedSHA256 string) b
 	return string(actualContent) != content
 }
 
-func (a *FileApplier) writeContent(path, content string) error {
-	return os.WriteFile(path, []byte(content), 0644)
+// writeContent atomically replaces path with content: the data is written to a
+// temp file in the same directory and renamed into place, so readers never see
+// a half-written file. The temp file is removed if any step fails. A file being
+// replaced keeps its owner and group, and its mode too when mode is empty.
+func writeContent(path, content, mode string) (err error) {
+	existing, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	perm, err := parseMode(mode, defaultFileMode)
+	if err != nil {
+		return err
+	}
+	if mode == "" && existing != nil {
+		perm = existing.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.WriteString(content); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	// Chown first, since it clears the setuid and setgid bits
+	if existing != nil {
+		if err = copyOwner(tmp.Name(), existing); err != nil {
+			return err
+		}
+	}
+	// CreateTemp uses 0600 and chmod is not subject to the umask
+	if err = os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// writePrivileged replaces path with content through the privilege command,
+// for system files the agent's own user can't write. Like writeContent, the
+// data goes to a temp file next to path, created with its final mode before
+// anything is written to it, and is renamed into place. An empty mode keeps
+// an existing file's mode.
+func writePrivileged(ctx context.Context, path, content, mode string) error {
+	perm, err := parseMode(mode, defaultFileMode)
+	if err != nil {
+		return err
+	}
+	if existing, err := os.Stat(path); err == nil && mode == "" {
+		perm = existing.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	}
+
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".power-edge-tmp")
+	steps := []struct {
+		stdin io.Reader
+		args  []string
+	}{
+		{nil, []string{"install", "-m", formatMode(perm), "/dev/null", tmp}},
+		{strings.NewReader(content), []string{"dd", "of=" + tmp, "status=none"}},
+		{nil, []string{"mv", "-f", tmp, path}},
+	}
+	for _, step := range steps {
+		output, err := runCombinedInput(ctx, step.stdin, "sudo", step.args...)
+		if err != nil {
+			runCombined(ctx, "sudo", "rm", "-f", tmp)
+			return fmt.Errorf("%s: %s (output: %s)", step.args[0], err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// readPrivileged reads path, falling back to the privilege command when the
+// agent's own user may not read it, e.g. a root-owned 0600 file
+func readPrivileged(ctx context.Context, path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if !errors.Is(err, fs.ErrPermission) {
+		return data, err
+	}
+	data, err = runOutput(ctx, "sudo", "cat", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// mkdirPrivileged creates dir and any missing parents through the privilege
+// command. install -d gives dir itself perm, whatever the umask; parents get
+// the default mode.
+func mkdirPrivileged(ctx context.Context, dir string, perm os.FileMode) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	output, err := runCombined(ctx, "sudo", "install", "-d", "-m", formatMode(perm), dir)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %s (output: %s)", dir, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// parseMode parses an octal mode, falling back to def when empty. The
+// common spellings are accepted: 644, 0644 and 0o644, plus setuid, setgid
+// and sticky bits as in 4755 or 1777.
+func parseMode(mode string, def os.FileMode) (os.FileMode, error) {
+	if mode == "" {
+		return def, nil
+	}
+	digits := strings.TrimPrefix(strings.TrimPrefix(mode, "0o"), "0O")
+	bits, err := strconv.ParseUint(digits, 8, 32)
+	if err != nil || len(digits) < 3 {
+		return 0, fmt.Errorf("invalid mode %q: want octal permissions like 0644", mode)
+	}
+	if bits > 07777 {
+		return 0, fmt.Errorf("invalid mode %q: more than permission, setuid, setgid and sticky bits", mode)
+	}
+
+	perm := os.FileMode(bits & 0777)
+	if bits&04000 != 0 {
+		perm |= os.ModeSetuid
+	}
+	if bits&02000 != 0 {
+		perm |= os.ModeSetgid
+	}
+	if bits&01000 != 0 {
+		perm |= os.ModeSticky
+	}
+	return perm, nil
+}
+
+// formatMode renders the permission and special bits of m in the canonical
+// 4-digit octal form, e.g. 0644 or 4755
+func formatMode(m os.FileMode) string {
+	bits := uint32(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if m&os.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if m&os.ModeSticky != 0 {
+		bits |= 01000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
+
+// NormalizeMode returns mode in the canonical 4-digit form modes are read
+// back in, so "644", "0644" and "0o644" all compare equal
+func NormalizeMode(mode string) (string, error) {
+	perm, err := parseMode(mode, 0)
+	if err != nil {
+		return "", err
+	}
+	return formatMode(perm), nil
+}
+
+// backup copies the current file to <path>.bak, preserving its mode
+func (a *FileApplier) backup(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return writeContent(path+".bak", string(data), formatMode(info.Mode()))
 }
 
 func (a *FileApplier) getMode(path string) (string, error) {
@@ -165,28 +989,23 @@ func (a *FileApplier) getMode(path string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	mode := info.Mode().Perm()
-	return fmt.Sprintf("%04o", mode), nil
+	return formatMode(info.Mode()), nil
 }
 
 func (a *FileApplier) setMode(path, mode string) error {
-	// Parse octal mode string
-	modeInt, err := strconv.ParseUint(mode, 8, 32)
+	perm, err := parseMode(mode, defaultFileMode)
 	if err != nil {
-		return fmt.Errorf("invalid mode format: %s", mode)
+		return err
 	}
-
-	return os.Chmod(path, os.FileMode(modeInt))
+	return os.Chmod(path, perm)
 }
 
-func (a *FileApplier) getOwnership(path string) (owner, group string, err error) {
+func (a *FileApplier) getOwnership(ctx context.Context, path string) (owner, group string, err error) {
 	// Use stat command to get owner/group (cross-platform approach)
-	cmd := exec.Command("stat", "-c", "%U %G", path)
-	output, err := cmd.Output()
+	output, err := runOutput(ctx, "stat", "-c", "%U %G", path)
 	if err != nil {
 		// Try BSD stat format (macOS)
-		cmd = exec.Command("stat", "-f", "%Su %Sg", path)
-		output, err = cmd.Output()
+		output, err = runOutput(ctx, "stat", "-f", "%Su %Sg", path)
 		if err != nil {
 			return "", "", err
 		}
@@ -199,15 +1018,22 @@ func (a *FileApplier) getOwnership(path string) (owner, group string, err error)
 	return "", "", fmt.Errorf("failed to parse ownership")
 }
 
-func (a *FileApplier) setOwnership(path, owner, group string) error {
-	cmd := exec.Command("chown", fmt.Sprintf("%s:%s", owner, group), path)
-	output, err := cmd.CombinedOutput()
+func (a *FileApplier) setOwnership(ctx context.Context, path, owner, group string) error {
+	output, err := runCombined(ctx, "chown", fmt.Sprintf("%s:%s", owner, group), path)
 	if err != nil {
 		return fmt.Errorf("%s (output: %s)", err, string(output))
 	}
 	return nil
 }
 
+func (a *FileApplier) getSELinuxContext(ctx context.Context, path string) (string, error) {
+	output, err := runOutput(ctx, "stat", "-c", "%C", path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
 func (a *FileApplier) getSHA256(path string) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
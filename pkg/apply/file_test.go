@@ -1,8 +1,16 @@
 package apply
 
 import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/power-edge/power-edge/pkg/config"
@@ -42,7 +50,7 @@ func TestFileApplier_Apply(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := NewFileApplier()
-			result := a.Apply(tt.file, tt.dryRun)
+			result := a.Apply(context.Background(), tt.file, tt.dryRun)
 
 			if (result.Error != nil) != tt.wantErr {
 				t.Errorf("Apply() error = %v, wantErr %v", result.Error, tt.wantErr)
@@ -66,7 +74,7 @@ func TestFileApplier_Check(t *testing.T) {
 	}
 
 	a := NewFileApplier()
-	exists, mode, owner, group, sha256sum, err := a.Check(testFile)
+	exists, mode, owner, group, sha256sum, _, err := a.Check(context.Background(), testFile)
 
 	if err != nil {
 		t.Fatalf("Check() error = %v", err)
@@ -101,7 +109,7 @@ func TestFileApplier_WriteAndVerify(t *testing.T) {
 		Mode:    "0644",
 	}
 
-	result := a.Apply(file, false)
+	result := a.Apply(context.Background(), file, false)
 	if result.Error != nil {
 		t.Fatalf("Apply() failed: %v", result.Error)
 	}
@@ -121,3 +129,765 @@ func TestFileApplier_WriteAndVerify(t *testing.T) {
 		t.Errorf("Content mismatch: got %q, want %q", string(content), "verification test")
 	}
 }
+
+func TestFileApplier_Absent(t *testing.T) {
+	tmpDir := t.TempDir()
+	a := NewFileApplier()
+
+	t.Run("removes existing file", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "remove-me.txt")
+		if err := os.WriteFile(testFile, []byte("stale"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		file := config.FileConfig{
+			Path:  config.UnixPath(testFile),
+			State: config.FileStateAbsent,
+		}
+
+		// Dry-run reports the removal but leaves the file alone
+		result := a.Apply(context.Background(), file, true)
+		if result.Error != nil {
+			t.Fatalf("Apply() dry-run error = %v", result.Error)
+		}
+		if !result.Changed || len(result.Actions) != 1 || result.Actions[0] != "rm "+testFile {
+			t.Errorf("Expected rm action, got %v", result.Actions)
+		}
+		if _, err := os.Stat(testFile); err != nil {
+			t.Error("Dry-run must not delete the file")
+		}
+
+		result = a.Apply(context.Background(), file, false)
+		if result.Error != nil {
+			t.Fatalf("Apply() error = %v", result.Error)
+		}
+		if _, err := os.Stat(testFile); !os.IsNotExist(err) {
+			t.Error("File was not removed")
+		}
+	})
+
+	t.Run("already absent is compliant", func(t *testing.T) {
+		file := config.FileConfig{
+			Path:  config.UnixPath(filepath.Join(tmpDir, "never-existed.txt")),
+			State: config.FileStateAbsent,
+		}
+
+		result := a.Apply(context.Background(), file, false)
+		if result.Error != nil {
+			t.Fatalf("Apply() error = %v", result.Error)
+		}
+		if result.Changed {
+			t.Errorf("Expected no changes, got %v", result.Actions)
+		}
+	})
+
+	t.Run("directory is an error", func(t *testing.T) {
+		dir := filepath.Join(tmpDir, "a-directory")
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+
+		result := a.Apply(context.Background(), config.FileConfig{
+			Path:  config.UnixPath(dir),
+			State: config.FileStateAbsent,
+		}, false)
+		if result.Error == nil {
+			t.Error("Expected error when absent path is a directory")
+		}
+		if _, err := os.Stat(dir); err != nil {
+			t.Error("Directory must not be removed")
+		}
+	})
+}
+
+func TestFileApplier_Template(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "site.conf")
+
+	a := NewFileApplier()
+	data := NewTemplateData(config.Metadata{Site: "edge-01", Environment: "production"})
+	data.Hostname = "node-a"
+	a.SetTemplateData(data)
+
+	file := config.FileConfig{
+		Path:     config.UnixPath(path),
+		Content:  "site={{ .Site }} env={{ .Environment }} host={{ .Hostname }}\n",
+		Template: true,
+	}
+
+	result := a.Apply(context.Background(), file, false)
+	if result.Error != nil {
+		t.Fatalf("Apply() error = %v", result.Error)
+	}
+	if !result.Changed {
+		t.Error("Expected file to be written")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if want := "site=edge-01 env=production host=node-a\n"; string(content) != want {
+		t.Errorf("Rendered content = %q, want %q", content, want)
+	}
+
+	// Rendered output on disk matches, so the file is compliant
+	if result := a.Apply(context.Background(), file, true); result.Changed {
+		t.Errorf("Expected rendered file to be compliant, got actions: %v", result.Actions)
+	}
+
+	// Template errors name the offending file
+	file.Content = "{{ .Missing }"
+	result = a.Apply(context.Background(), file, true)
+	if result.Error == nil || !strings.Contains(result.Error.Error(), path) {
+		t.Errorf("Expected parse error naming %s, got %v", path, result.Error)
+	}
+
+	file.Content = "{{ .NoSuchField }}"
+	result = a.Apply(context.Background(), file, true)
+	if result.Error == nil || !strings.Contains(result.Error.Error(), path) {
+		t.Errorf("Expected execution error naming %s, got %v", path, result.Error)
+	}
+}
+
+func TestNormalizeMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    string
+		wantErr string
+	}{
+		{mode: "644", want: "0644"},
+		{mode: "0644", want: "0644"},
+		{mode: "0o644", want: "0644"},
+		{mode: "0O600", want: "0600"},
+		{mode: "4755", want: "4755"},
+		{mode: "1777", want: "1777"},
+		{mode: "02775", want: "2775"},
+		{mode: "0o4755", want: "4755"},
+		{mode: "0x644", wantErr: "want octal permissions"},
+		{mode: "rw-r--r--", wantErr: "want octal permissions"},
+		{mode: "0688", wantErr: "want octal permissions"},
+		{mode: "64", wantErr: "want octal permissions"},
+		{mode: "17777", wantErr: "more than permission"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeMode(tt.mode)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NormalizeMode(%q) error = %v, want %q", tt.mode, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeMode(%q) = %q, %v; want %q", tt.mode, got, err, tt.want)
+		}
+	}
+}
+
+func TestFileApplier_ModeVariants(t *testing.T) {
+	dir := t.TempDir()
+	a := NewFileApplier()
+
+	// 644 written by hand is the 0644 already on disk
+	existing := filepath.Join(dir, "existing.conf")
+	if err := os.WriteFile(existing, []byte("same"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(existing, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range []string{"644", "0644", "0o644"} {
+		result := a.Apply(context.Background(), config.FileConfig{Path: config.UnixPath(existing), Content: "same", Mode: mode}, true)
+		if result.Error != nil || result.Changed {
+			t.Errorf("mode %q: Changed = %v, error = %v; want compliant", mode, result.Changed, result.Error)
+		}
+	}
+
+	// Special bits are set and read back as written
+	for _, mode := range []string{"4755", "1777", "2750"} {
+		path := filepath.Join(dir, "special-"+mode)
+		file := config.FileConfig{Path: config.UnixPath(path), Content: "x", Mode: mode}
+		if result := a.Apply(context.Background(), file, false); result.Error != nil {
+			t.Fatalf("mode %s: Apply() error = %v", mode, result.Error)
+		}
+		if got, _ := a.getMode(path); got != mode {
+			t.Errorf("mode %s: read back %s", mode, got)
+		}
+		if result := a.Apply(context.Background(), file, true); result.Changed {
+			t.Errorf("mode %s: not compliant after apply: %v", mode, result.Actions)
+		}
+
+		// And a drifted mode is put back
+		if err := os.Chmod(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		result := a.Apply(context.Background(), file, false)
+		if result.Error != nil || !result.Changed {
+			t.Fatalf("mode %s: repair Changed = %v, error = %v", mode, result.Changed, result.Error)
+		}
+		if got, _ := a.getMode(path); got != mode {
+			t.Errorf("mode %s: repaired to %s", mode, got)
+		}
+	}
+
+	result := a.Apply(context.Background(), config.FileConfig{Path: config.UnixPath(existing), Content: "same", Mode: "0x644"}, true)
+	if result.Error == nil || !strings.Contains(result.Error.Error(), `invalid mode "0x644"`) {
+		t.Errorf("Apply() error = %v, want the invalid mode named", result.Error)
+	}
+}
+
+func TestFileApplier_AtomicWriteAndBackup(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "app.conf")
+
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	a := NewFileApplier()
+	file := config.FileConfig{
+		Path:    config.UnixPath(path),
+		Content: "new",
+		Mode:    "0640",
+		Backup:  true,
+	}
+
+	result := a.Apply(context.Background(), file, false)
+	if result.Error != nil {
+		t.Fatalf("Apply() error = %v", result.Error)
+	}
+
+	content, _ := os.ReadFile(path)
+	if string(content) != "new" {
+		t.Errorf("Content = %q, want %q", content, "new")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Mode = %04o, want 0640", info.Mode().Perm())
+	}
+
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil {
+		t.Fatalf("Expected backup file: %v", err)
+	}
+	if string(backup) != "old" {
+		t.Errorf("Backup content = %q, want %q", backup, "old")
+	}
+}
+
+func TestFileApplier_ValidateCmd(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "app's.conf")
+	if err := os.WriteFile(path, []byte("valid = old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Accepts content whose every line starts with "valid"
+	validate := `! grep -qv '^valid' %s`
+	a := NewFileApplier()
+	applyContent := func(content string, dryRun bool) ApplyResult {
+		return a.Apply(context.Background(), config.FileConfig{
+			Path:        config.UnixPath(path),
+			Content:     content,
+			ValidateCmd: validate,
+			Backup:      true,
+		}, dryRun)
+	}
+	assertContent := func(want string) {
+		t.Helper()
+		if got, _ := os.ReadFile(path); string(got) != want {
+			t.Errorf("content = %q, want %q", got, want)
+		}
+	}
+
+	// Rejected content is neither written nor backed up, in either mode
+	for _, dryRun := range []bool{true, false} {
+		result := applyContent("broken\n", dryRun)
+		if result.Error == nil || !strings.Contains(result.Error.Error(), "failed validation") {
+			t.Errorf("Apply(dryRun=%v) error = %v, want a validation failure", dryRun, result.Error)
+		}
+		if result.Changed {
+			t.Errorf("Apply(dryRun=%v) reported a change for rejected content", dryRun)
+		}
+		assertContent("valid = old\n")
+		if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+			t.Errorf("Apply(dryRun=%v) backed up the file before validating", dryRun)
+		}
+	}
+
+	// Accepted content is reported in dry-run and written in enforce
+	result := applyContent("valid = new\n", true)
+	if result.Error != nil || !result.Changed || len(result.Notes) != 1 {
+		t.Errorf("Apply(dry-run) = %+v, want a change with a validation note", result)
+	}
+	assertContent("valid = old\n")
+	if result := applyContent("valid = new\n", false); result.Error != nil {
+		t.Fatalf("Apply() error = %v", result.Error)
+	}
+	assertContent("valid = new\n")
+
+	// Compliant content isn't validated again
+	validate = "false %s"
+	if result := applyContent("valid = new\n", false); result.Error != nil || result.Changed {
+		t.Errorf("Apply() on compliant content = %+v, want no change", result)
+	}
+}
+
+func TestFileApplier_WriteContentCleansUpOnFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Renaming a file over a non-empty directory fails
+	target := filepath.Join(tmpDir, "target")
+	if err := os.MkdirAll(filepath.Join(target, "child"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	if err := writeContent(target, "data", "0644"); err == nil {
+		t.Fatal("Expected rename over directory to fail")
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read dir: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "target" {
+			t.Errorf("Temp file left behind: %s", entry.Name())
+		}
+	}
+}
+
+func TestFileApplier_ContentChangeKeepsMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "secret.conf")
+	if err := os.WriteFile(path, []byte("old\n"), 0600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	// WriteFile is subject to the umask
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatalf("Failed to chmod file: %v", err)
+	}
+
+	a := NewFileApplier()
+	file := config.FileConfig{
+		Path:    config.UnixPath(path),
+		Content: "new\n",
+	}
+	if result := a.Apply(context.Background(), file, false); result.Error != nil || !result.Changed {
+		t.Fatalf("Apply() = %+v, want content changed", result)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode after content change = %04o, want 0600", info.Mode().Perm())
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new\n" {
+		t.Errorf("content = %q, want %q", data, "new\n")
+	}
+}
+
+func TestFileApplier_CreatesParentDirectories(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "sub", "dir", "file.conf")
+
+	a := NewFileApplier()
+	file := config.FileConfig{
+		Path:    config.UnixPath(path),
+		Content: "data",
+		// Group-writable, so a typical umask would strip it
+		DirMode: "0770",
+	}
+
+	result := a.Apply(context.Background(), file, false)
+	if result.Error != nil {
+		t.Fatalf("Apply() error = %v", result.Error)
+	}
+	if len(result.Actions) != 2 || !strings.HasPrefix(result.Actions[0], "mkdir -p -m 0770 ") {
+		t.Errorf("Expected mkdir then write actions, got %v", result.Actions)
+	}
+
+	for _, dir := range []string{filepath.Join(tmpDir, "sub"), filepath.Dir(path)} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			t.Fatalf("Parent directory not created: %v", err)
+		}
+		if !info.IsDir() {
+			t.Errorf("Expected %s to be a directory", dir)
+		}
+		if info.Mode().Perm() != 0770 {
+			t.Errorf("mode of %s = %04o, want 0770", dir, info.Mode().Perm())
+		}
+	}
+}
+
+func TestFileApplier_Directory(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "data", "cache")
+
+	a := NewFileApplier()
+	file := config.FileConfig{
+		Path: config.UnixPath(path),
+		Type: config.FileTypeDirectory,
+		Mode: "0700",
+	}
+
+	result := a.Apply(context.Background(), file, false)
+	if result.Error != nil {
+		t.Fatalf("Apply() error = %v", result.Error)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		t.Fatalf("Expected directory at %s: %v", path, err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("Mode = %04o, want 0700", info.Mode().Perm())
+	}
+
+	if result := a.Apply(context.Background(), file, false); result.Changed {
+		t.Errorf("Expected existing directory to be compliant, got actions: %v", result.Actions)
+	}
+
+	file.State = config.FileStateAbsent
+	if result := a.Apply(context.Background(), file, false); result.Error != nil || !result.Changed {
+		t.Errorf("Expected empty directory to be removed, got %+v", result)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected directory to be removed")
+	}
+}
+
+func TestFileApplier_Symlink(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "current")
+
+	a := NewFileApplier()
+	file := config.FileConfig{
+		Path:   config.UnixPath(path),
+		Type:   config.FileTypeSymlink,
+		Target: "releases/v1",
+	}
+
+	result := a.Apply(context.Background(), file, false)
+	if result.Error != nil {
+		t.Fatalf("Apply() error = %v", result.Error)
+	}
+	if target, _ := os.Readlink(path); target != "releases/v1" {
+		t.Errorf("Link target = %q, want %q", target, "releases/v1")
+	}
+
+	// Dangling links are fine; only the target matters
+	if result := a.Apply(context.Background(), file, false); result.Changed {
+		t.Errorf("Expected symlink to be compliant, got actions: %v", result.Actions)
+	}
+
+	file.Target = "releases/v2"
+	result = a.Apply(context.Background(), file, false)
+	if result.Error != nil || !result.Changed {
+		t.Fatalf("Expected link to be retargeted, got %+v", result)
+	}
+	if target, _ := os.Readlink(path); target != "releases/v2" {
+		t.Errorf("Link target = %q, want %q", target, "releases/v2")
+	}
+
+	// Never replace a regular file with a link
+	regular := filepath.Join(tmpDir, "regular")
+	os.WriteFile(regular, []byte("x"), 0644)
+	file.Path = config.UnixPath(regular)
+	if result := a.Apply(context.Background(), file, false); result.Error == nil {
+		t.Error("Expected error replacing a regular file with a symlink")
+	}
+}
+
+func TestFileApplier_Source(t *testing.T) {
+	tmpDir := t.TempDir()
+	body := "payload from source\n"
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte(body)))
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/app.conf" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	a := NewFileApplier()
+	a.transport = srv.Client().Transport
+
+	srcPath := filepath.Join(tmpDir, "src.conf")
+	os.WriteFile(srcPath, []byte(body), 0644)
+
+	tests := []struct {
+		name    string
+		source  string
+		sha     string
+		wantErr string
+	}{
+		{name: "file source", source: "file://" + srcPath, sha: sum},
+		{name: "https source", source: srv.URL + "/app.conf", sha: sum},
+		{name: "https without checksum", source: srv.URL + "/app.conf"},
+		{name: "checksum mismatch", source: srv.URL + "/app.conf", sha: strings.Repeat("0", 64), wantErr: "checksum mismatch"},
+		{name: "non-200", source: srv.URL + "/missing", wantErr: "404"},
+		{name: "unsupported scheme", source: "ftp://example.com/x", wantErr: "unsupported source scheme"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, strings.ReplaceAll(tt.name, " ", "-"))
+			result := a.Apply(context.Background(), config.FileConfig{
+				Path:   config.UnixPath(path),
+				Source: tt.source,
+				SHA256: tt.sha,
+			}, false)
+
+			if tt.wantErr != "" {
+				if result.Error == nil || !strings.Contains(result.Error.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, result.Error)
+				}
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Error("Destination must not be touched on failure")
+				}
+				return
+			}
+
+			if result.Error != nil {
+				t.Fatalf("Apply() error = %v", result.Error)
+			}
+			content, _ := os.ReadFile(path)
+			if string(content) != body {
+				t.Errorf("Content = %q, want %q", content, body)
+			}
+		})
+	}
+}
+
+func TestFileApplier_SourceCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	body := "large artifact\n"
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte(body)))
+
+	requests := 0
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	cacheDir := filepath.Join(tmpDir, "cache")
+	a := NewFileApplier()
+	a.transport = srv.Client().Transport
+	a.SetSourceCache(NewSourceCache(cacheDir))
+
+	file := config.FileConfig{Path: config.UnixPath(filepath.Join(tmpDir, "first")), Source: srv.URL + "/artifact", SHA256: sum}
+	if result := a.Apply(context.Background(), file, false); result.Error != nil {
+		t.Fatalf("Apply() error = %v", result.Error)
+	}
+	if requests != 1 {
+		t.Fatalf("requests = %d after a cache miss, want 1", requests)
+	}
+	info, err := os.Stat(filepath.Join(cacheDir, sum))
+	if err != nil {
+		t.Fatalf("artifact not cached: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("cache entry mode = %o, want 600", info.Mode().Perm())
+	}
+	if dir, _ := os.Stat(cacheDir); dir.Mode().Perm() != 0700 {
+		t.Errorf("cache dir mode = %o, want 700", dir.Mode().Perm())
+	}
+
+	// The source going away doesn't matter once the artifact is cached
+	srv.Close()
+	file.Path = config.UnixPath(filepath.Join(tmpDir, "second"))
+	result := a.Apply(context.Background(), file, false)
+	if result.Error != nil {
+		t.Fatalf("Apply() from cache error = %v", result.Error)
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want the cached copy used", requests)
+	}
+	if len(result.Notes) != 1 || !strings.Contains(result.Notes[0], "using cached copy") {
+		t.Errorf("Notes = %v, want the cache use reported", result.Notes)
+	}
+	if content, _ := os.ReadFile(string(file.Path)); string(content) != body {
+		t.Errorf("Content = %q, want %q", content, body)
+	}
+
+	// A corrupted entry is dropped and fetched again, here failing
+	os.WriteFile(filepath.Join(cacheDir, sum), []byte("tampered"), 0600)
+	file.Path = config.UnixPath(filepath.Join(tmpDir, "third"))
+	if result := a.Apply(context.Background(), file, false); result.Error == nil {
+		t.Error("Expected a tampered cache entry to be refetched")
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, sum)); !os.IsNotExist(err) {
+		t.Error("Tampered cache entry must be removed")
+	}
+}
+
+func TestFileApplier_SELinuxContext(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.conf")
+	if err := os.WriteFile(existing, []byte("same"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(dir, "created.conf")
+
+	tests := []struct {
+		name        string
+		selinux     bool
+		file        config.FileConfig
+		wantActions []string
+	}{
+		{
+			name:        "explicit type",
+			selinux:     true,
+			file:        config.FileConfig{Path: config.UnixPath(created), Content: "new", SELinuxContext: "httpd_config_t"},
+			wantActions: []string{"write content to " + created, "chcon -t httpd_config_t " + created},
+		},
+		{
+			name:        "explicit full context",
+			selinux:     true,
+			file:        config.FileConfig{Path: config.UnixPath(created), Content: "new", SELinuxContext: "system_u:object_r:etc_t:s0"},
+			wantActions: []string{"write content to " + created, "chcon system_u:object_r:etc_t:s0 " + created},
+		},
+		{
+			name:        "written file is relabelled",
+			selinux:     true,
+			file:        config.FileConfig{Path: config.UnixPath(created), Content: "new"},
+			wantActions: []string{"write content to " + created, "restorecon " + created},
+		},
+		{
+			name:        "unchanged file is left alone",
+			selinux:     true,
+			file:        config.FileConfig{Path: config.UnixPath(existing), Content: "same"},
+			wantActions: []string{},
+		},
+		{
+			name:        "ignored without SELinux",
+			file:        config.FileConfig{Path: config.UnixPath(existing), Content: "same", SELinuxContext: "httpd_config_t"},
+			wantActions: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewFileApplier()
+			a.selinux = tt.selinux
+
+			result := a.Apply(context.Background(), tt.file, true)
+			if result.Error != nil {
+				t.Fatalf("Apply() error = %v", result.Error)
+			}
+			if !reflect.DeepEqual(result.Actions, tt.wantActions) {
+				t.Errorf("Actions = %q, want %q", result.Actions, tt.wantActions)
+			}
+		})
+	}
+}
+
+func TestSELinuxContextMatches(t *testing.T) {
+	const current = "system_u:object_r:httpd_config_t:s0"
+	tests := []struct {
+		want  string
+		match bool
+	}{
+		{"httpd_config_t", true},
+		{"etc_t", false},
+		{current, true},
+		{"system_u:object_r:etc_t:s0", false},
+	}
+	for _, tt := range tests {
+		if got := selinuxContextMatches(current, tt.want); got != tt.match {
+			t.Errorf("selinuxContextMatches(%q, %q) = %v, want %v", current, tt.want, got, tt.match)
+		}
+	}
+	if selinuxContextMatches("?", "etc_t") {
+		t.Error("an unlabelled file should not match a type")
+	}
+}
+
+func TestFileApplier_Transactional(t *testing.T) {
+	const badOwner = "power-edge-no-such-user"
+
+	tests := []struct {
+		name          string
+		transactional bool
+		existing      bool
+		wantContent   string // "" = file removed
+		wantRollback  bool
+		wantCause     string
+	}{
+		{"rolls back existing file", true, true, "old", true, "failed to set ownership"},
+		// Ownership is only applied to files that already existed, so fail
+		// the file's SELinux step instead
+		{"removes created file", true, false, "", true, "failed to get SELinux context"},
+		{"best effort by default", false, true, "new", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "managed.conf")
+			if tt.existing {
+				if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			a := NewFileApplier()
+			file := config.FileConfig{
+				Path:          config.UnixPath(path),
+				Content:       "new",
+				Mode:          "0644",
+				Owner:         badOwner,
+				Transactional: tt.transactional,
+			}
+			if !tt.existing {
+				if a.selinux {
+					t.Skip("SELinux context can be read on this host")
+				}
+				a.selinux = true
+				file.SELinuxContext = "etc_t"
+			}
+
+			result := a.Apply(context.Background(), file, false)
+			if result.Error == nil {
+				t.Fatal("Expected the apply to fail")
+			}
+
+			var rbErr *RollbackError
+			if rolledBack := errors.As(result.Error, &rbErr); rolledBack != tt.wantRollback {
+				t.Fatalf("RollbackError = %v, want %v: %v", rolledBack, tt.wantRollback, result.Error)
+			}
+			if tt.wantRollback {
+				if rbErr.RollbackErr != nil {
+					t.Fatalf("Rollback failed: %v", rbErr.RollbackErr)
+				}
+				if !strings.Contains(result.Error.Error(), tt.wantCause) || !strings.HasSuffix(result.Error.Error(), "(rolled back)") {
+					t.Errorf("Error should carry the cause and the rollback outcome: %v", result.Error)
+				}
+			}
+
+			data, err := os.ReadFile(path)
+			switch {
+			case tt.wantContent == "" && !os.IsNotExist(err):
+				t.Errorf("Created file should be removed, got %q, %v", data, err)
+			case tt.wantContent != "" && string(data) != tt.wantContent:
+				t.Errorf("Content = %q, want %q", data, tt.wantContent)
+			}
+			if tt.wantRollback && tt.existing {
+				if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+					t.Errorf("Mode should be restored to 0600: %v, %v", info, err)
+				}
+			}
+		})
+	}
+}
@@ -1,40 +1,122 @@
 package apply
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"os"
 	"os/exec"
+	"regexp"
 	"strings"
 
 	"github.com/power-edge/power-edge/pkg/config"
 )
 
-// FirewallApplier is the single source of truth for applying firewall state (UFW)
-type FirewallApplier struct{}
+var (
+	// ErrFirewallProviderUnsupported is returned (wrapped) when the state
+	// selects a firewall provider power-edge has no backend for
+	ErrFirewallProviderUnsupported = errors.New("firewall provider is not supported")
+	// ErrFirewallBackendMissing is returned (wrapped) when the tool the
+	// selected provider is driven with isn't installed
+	ErrFirewallBackendMissing = errors.New("firewall tool is not installed")
+)
+
+// firewallBackend programs one firewall implementation
+type firewallBackend interface {
+	apply(ctx context.Context, fw *config.FirewallConfig, dryRun bool) ApplyResult
+	check(ctx context.Context) (enabled bool, err error)
+}
+
+// FirewallApplier is the single source of truth for applying firewall state.
+// It delegates to the backend selected by the config's provider (UFW by default).
+type FirewallApplier struct {
+	backends map[config.FirewallProvider]firewallBackend
+}
 
 // NewFirewallApplier creates a new firewall applier
 func NewFirewallApplier() *FirewallApplier {
-	return &FirewallApplier{}
+	return &FirewallApplier{
+		backends: map[config.FirewallProvider]firewallBackend{
+			config.FirewallProviderUfw:       &ufwBackend{},
+			config.FirewallProviderFirewalld: &firewalldBackend{},
+			config.FirewallProviderNftables:  newNftBackend(),
+		},
+	}
 }
 
-// Apply ensures firewall matches desired state
-func (a *FirewallApplier) Apply(fw *config.FirewallConfig, dryRun bool) ApplyResult {
-	result := ApplyResult{
-		Actions: []string{},
+// FirewallProvider returns the provider that manages fw, defaulting to UFW
+func FirewallProvider(fw *config.FirewallConfig) config.FirewallProvider {
+	if fw == nil || fw.Provider == "" {
+		return config.FirewallProviderUfw
 	}
+	return fw.Provider
+}
+
+func (a *FirewallApplier) backend(fw *config.FirewallConfig) (firewallBackend, error) {
+	provider := FirewallProvider(fw)
+	b, ok := a.backends[provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrFirewallProviderUnsupported, provider)
+	}
+	return b, nil
+}
 
+// Apply ensures firewall matches desired state
+func (a *FirewallApplier) Apply(ctx context.Context, fw *config.FirewallConfig, dryRun bool) ApplyResult {
 	if fw == nil {
-		result.Changed = false
-		return result
+		return ApplyResult{Actions: []string{}}
+	}
+
+	b, err := a.backend(fw)
+	if err != nil {
+		return ApplyResult{Actions: []string{}, Error: err}
+	}
+	return b.apply(ctx, fw, dryRun)
+}
+
+// Check returns whether the firewall managed by fw's provider is enabled
+func (a *FirewallApplier) Check(ctx context.Context, fw *config.FirewallConfig) (enabled bool, err error) {
+	b, err := a.backend(fw)
+	if err != nil {
+		return false, err
+	}
+	return b.check(ctx)
+}
+
+// ufw keeps its settings in these files. `ufw status verbose` only reports
+// them while the firewall is active, so they're read directly otherwise.
+const (
+	ufwDefaultsPath = "/etc/default/ufw"
+	ufwConfPath     = "/etc/ufw/ufw.conf"
+)
+
+// ufwSettings are the default policies and log level ufw is configured with,
+// in the terms FirewallConfig uses
+type ufwSettings struct {
+	incoming string
+	outgoing string
+	logging  string
+}
+
+// ufwBackend manages the firewall through ufw
+type ufwBackend struct{}
+
+func (a *ufwBackend) apply(ctx context.Context, fw *config.FirewallConfig, dryRun bool) ApplyResult {
+	result := ApplyResult{
+		Actions: []string{},
 	}
 
 	// Check if UFW is available
 	if !a.isUFWInstalled() {
-		result.Error = fmt.Errorf("ufw is not installed")
+		result.Error = fmt.Errorf("%w: ufw", ErrFirewallBackendMissing)
 		return result
 	}
 
 	// Check enabled/disabled state
-	isEnabled, err := a.isEnabled()
+	isEnabled, err := a.isEnabled(ctx)
 	if err != nil {
 		result.Error = fmt.Errorf("failed to check UFW status: %w", err)
 		return result
@@ -44,7 +126,7 @@ func (a *FirewallApplier) Apply(fw *config.FirewallConfig, dryRun bool) ApplyRes
 		result.Changed = true
 		result.Actions = append(result.Actions, "ufw enable")
 		if !dryRun {
-			if err := a.enable(); err != nil {
+			if err := a.enable(ctx); err != nil {
 				result.Error = err
 				return result
 			}
@@ -53,20 +135,36 @@ func (a *FirewallApplier) Apply(fw *config.FirewallConfig, dryRun bool) ApplyRes
 		result.Changed = true
 		result.Actions = append(result.Actions, "ufw disable")
 		if !dryRun {
-			if err := a.disable(); err != nil {
+			if err := a.disable(ctx); err != nil {
 				result.Error = err
 				return result
 			}
 		}
 	}
 
-	// Apply allowed services
-	if fw.Enabled && len(fw.AllowedServices) > 0 {
-		for _, service := range fw.AllowedServices {
-			result.Actions = append(result.Actions, fmt.Sprintf("ufw allow %s", service))
+	if err := a.applySettings(ctx, fw, dryRun, &result); err != nil {
+		result.Error = err
+		return result
+	}
+
+	// Apply allowed services, skipping those a rule already allows. An
+	// inactive ufw lists no rules, so while enabling in dry-run every
+	// service is reported.
+	if allows := allowedServices(fw); fw.Enabled && len(allows) > 0 {
+		rules, err := a.rules(ctx)
+		if err != nil {
+			result.Error = fmt.Errorf("failed to list UFW rules: %w", err)
+			return result
+		}
+		for _, allow := range allows {
+			if ufwAllows(rules, allow.service, allow.iface) {
+				continue
+			}
+			args := allow.ufwArgs()
+			result.Actions = append(result.Actions, "ufw "+strings.Join(args, " "))
 			if !dryRun {
-				if err := a.allowService(service); err != nil {
-					result.Error = fmt.Errorf("failed to allow service %s: %w", service, err)
+				if err := a.run(ctx, args...); err != nil {
+					result.Error = fmt.Errorf("failed to allow service %s: %w", allow, err)
 					return result
 				}
 			}
@@ -77,19 +175,314 @@ func (a *FirewallApplier) Apply(fw *config.FirewallConfig, dryRun bool) ApplyRes
 	return result
 }
 
-// Check returns current firewall state
-func (a *FirewallApplier) Check() (enabled bool, err error) {
-	return a.isEnabled()
+// applySettings brings ufw's default policies and log level in line with fw,
+// leaving those fw doesn't set alone
+func (a *ufwBackend) applySettings(ctx context.Context, fw *config.FirewallConfig, dryRun bool, result *ApplyResult) error {
+	if fw.DefaultPolicy.Incoming == "" && fw.DefaultPolicy.Outgoing == "" && fw.Logging == "" {
+		return nil
+	}
+
+	current, err := a.settings(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check UFW settings: %w", err)
+	}
+
+	var changes [][]string
+	if want := fw.DefaultPolicy.Incoming; want != "" && want != current.incoming {
+		changes = append(changes, []string{"default", want, "incoming"})
+	}
+	if want := fw.DefaultPolicy.Outgoing; want != "" && want != current.outgoing {
+		changes = append(changes, []string{"default", want, "outgoing"})
+	}
+	if want := string(fw.Logging); want != "" && want != current.logging {
+		changes = append(changes, []string{"logging", want})
+	}
+
+	for _, args := range changes {
+		action := "ufw " + strings.Join(args, " ")
+		result.Changed = true
+		result.Actions = append(result.Actions, action)
+		if !dryRun {
+			if err := a.run(ctx, args...); err != nil {
+				return fmt.Errorf("failed to run %s: %w", action, err)
+			}
+		}
+	}
+	return nil
+}
+
+// settings returns ufw's current default policies and log level
+func (a *ufwBackend) settings(ctx context.Context) (ufwSettings, error) {
+	output, err := runOutput(ctx, "sudo", "ufw", "status", "verbose")
+	if err != nil {
+		return ufwSettings{}, err
+	}
+	if settings, active := parseUfwVerbose(output); active {
+		return settings, nil
+	}
+
+	defaults, err := os.ReadFile(ufwDefaultsPath)
+	if err != nil {
+		return ufwSettings{}, fmt.Errorf("failed to read %s: %w", ufwDefaultsPath, err)
+	}
+	conf, err := os.ReadFile(ufwConfPath)
+	if err != nil {
+		return ufwSettings{}, fmt.Errorf("failed to read %s: %w", ufwConfPath, err)
+	}
+	return parseUfwConfig(defaults, conf), nil
+}
+
+// parseUfwVerbose reads the settings out of `ufw status verbose`, reporting
+// whether the firewall is active; an inactive one lists none of them
+func parseUfwVerbose(output []byte) (settings ufwSettings, active bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "Status":
+			active = value == "active"
+		case "Logging":
+			// "on (medium)" or "off"
+			settings.logging = value
+			if _, level, ok := strings.Cut(value, "("); ok {
+				settings.logging = strings.TrimSuffix(level, ")")
+			}
+		case "Default":
+			// "deny (incoming), allow (outgoing), disabled (routed)"
+			for _, part := range strings.Split(value, ",") {
+				policy, direction, _ := strings.Cut(strings.TrimSpace(part), " ")
+				switch direction {
+				case "(incoming)":
+					settings.incoming = policy
+				case "(outgoing)":
+					settings.outgoing = policy
+				}
+			}
+		}
+	}
+	return settings, active
+}
+
+// allowedService is a service allowed in on one interface, or on any when
+// iface is empty
+type allowedService struct {
+	service string
+	iface   string
+}
+
+// allowedServices lists the services fw allows, those on any interface first
+func allowedServices(fw *config.FirewallConfig) []allowedService {
+	var allows []allowedService
+	for _, service := range fw.AllowedServices {
+		allows = append(allows, allowedService{service: service})
+	}
+	for _, iface := range fw.Interfaces {
+		for _, service := range iface.AllowedServices {
+			allows = append(allows, allowedService{service: service, iface: iface.Name})
+		}
+	}
+	return allows
+}
+
+func (w allowedService) String() string {
+	if w.iface == "" {
+		return w.service
+	}
+	return w.service + " on " + w.iface
+}
+
+// ufwArgs returns the ufw arguments adding the rule. A service on any interface
+// uses the simple syntax; scoping one to an interface needs the extended
+// syntax, which takes a port and protocol or an application profile rather
+// than a name from /etc/services.
+func (w allowedService) ufwArgs() []string {
+	if w.iface == "" {
+		return []string{"allow", w.service}
+	}
+
+	args := []string{"allow", "in", "on", w.iface, "to", "any"}
+	name, proto, hasProto := strings.Cut(w.service, "/")
+	if isDigits(strings.ReplaceAll(name, ":", "")) {
+		args = append(args, "port", name)
+		if hasProto {
+			args = append(args, "proto", proto)
+		}
+		return args
+	}
+
+	var port int
+	var protos []string
+	for _, p := range []string{"tcp", "udp"} {
+		if hasProto && p != proto {
+			continue
+		}
+		if n, err := net.LookupPort(p, name); err == nil {
+			port = n
+			protos = append(protos, p)
+		}
+	}
+	switch len(protos) {
+	case 0:
+		return append(args, "app", w.service)
+	case 1:
+		return append(args, "port", fmt.Sprint(port), "proto", protos[0])
+	default:
+		return append(args, "port", fmt.Sprint(port))
+	}
+}
+
+// ufwRule is one row of `ufw status`. iface is set for rules scoped to an
+// interface, which ufw shows as "22/tcp on eth0".
+type ufwRule struct {
+	to     string
+	iface  string
+	action string
+	from   string
+}
+
+// ufwColumns separates the columns of `ufw status`, which are padded with
+// runs of spaces while values contain single ones ("ALLOW IN")
+var ufwColumns = regexp.MustCompile(`\s{2,}`)
+
+// rules returns the rules ufw is enforcing; none while it is inactive
+func (a *ufwBackend) rules(ctx context.Context) ([]ufwRule, error) {
+	output, err := runOutput(ctx, "sudo", "ufw", "status")
+	if err != nil {
+		return nil, err
+	}
+	return parseUfwRules(output), nil
+}
+
+// parseUfwRules reads the rule table out of `ufw status`. IPv6 rows are
+// folded into their IPv4 counterparts, and trailing comments dropped.
+func parseUfwRules(output []byte) []ufwRule {
+	var rules []ufwRule
+	inTable := false
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !inTable {
+			inTable = strings.HasPrefix(line, "--")
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		if i := strings.Index(line, " # "); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		fields := ufwColumns.Split(line, -1)
+		if len(fields) < 3 {
+			continue
+		}
+		to, iface, _ := strings.Cut(strings.Replace(fields[0], " (v6)", "", 1), " on ")
+		rules = append(rules, ufwRule{
+			to:     to,
+			iface:  iface,
+			action: fields[1],
+			from:   strings.TrimSuffix(fields[2], " (v6)"),
+		})
+	}
+	return rules
+}
+
+// ufwAllows reports whether rules already allow service in from anywhere,
+// on iface or, when it is empty, on any interface. A rule scoped to an
+// interface doesn't count for any other, nor for all of them, and the other
+// way round. ufw shows a service from /etc/services by its port ("ssh" as
+// "22/tcp", or "53" for one on both protocols), so those forms match too; an
+// application profile is shown by name.
+func ufwAllows(rules []ufwRule, service, iface string) bool {
+	targets := map[string]bool{service: true}
+	name, proto, hasProto := strings.Cut(service, "/")
+	var ports []string
+	for _, p := range []string{"tcp", "udp"} {
+		if hasProto && p != proto {
+			continue
+		}
+		if port, err := net.LookupPort(p, name); err == nil {
+			ports = append(ports, fmt.Sprintf("%d/%s", port, p))
+		}
+	}
+	// A rule for both protocols covers either one, but one protocol doesn't
+	// cover a service on both
+	for _, port := range ports {
+		number, _, _ := strings.Cut(port, "/")
+		targets[number] = true
+		if len(ports) == 1 {
+			targets[port] = true
+		}
+	}
+
+	for _, rule := range rules {
+		if (rule.action == "ALLOW" || rule.action == "ALLOW IN") && rule.from == "Anywhere" && rule.iface == iface && targets[rule.to] {
+			return true
+		}
+	}
+	return false
+}
+
+// ufwPolicies maps the iptables targets in /etc/default/ufw to ufw policies
+var ufwPolicies = map[string]string{
+	"ACCEPT": "allow",
+	"DROP":   "deny",
+	"REJECT": "reject",
+}
+
+// parseUfwConfig reads the settings out of /etc/default/ufw and
+// /etc/ufw/ufw.conf
+func parseUfwConfig(defaults, conf []byte) ufwSettings {
+	var settings ufwSettings
+	for key, value := range shellAssignments(defaults) {
+		switch key {
+		case "DEFAULT_INPUT_POLICY":
+			settings.incoming = ufwPolicies[value]
+		case "DEFAULT_OUTPUT_POLICY":
+			settings.outgoing = ufwPolicies[value]
+		}
+	}
+	settings.logging = shellAssignments(conf)["LOGLEVEL"]
+	return settings
+}
+
+// shellAssignments returns the KEY=value lines of a shell-style config file,
+// with quotes around values removed
+func shellAssignments(data []byte) map[string]string {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	return values
+}
+
+func (a *ufwBackend) check(ctx context.Context) (enabled bool, err error) {
+	if !a.isUFWInstalled() {
+		return false, fmt.Errorf("%w: ufw", ErrFirewallBackendMissing)
+	}
+	return a.isEnabled(ctx)
 }
 
-func (a *FirewallApplier) isUFWInstalled() bool {
+func (a *ufwBackend) isUFWInstalled() bool {
 	_, err := exec.LookPath("ufw")
 	return err == nil
 }
 
-func (a *FirewallApplier) isEnabled() (bool, error) {
-	cmd := exec.Command("sudo", "ufw", "status")
-	output, err := cmd.Output()
+func (a *ufwBackend) isEnabled(ctx context.Context) (bool, error) {
+	output, err := runOutput(ctx, "sudo", "ufw", "status")
 	if err != nil {
 		return false, err
 	}
@@ -97,28 +490,25 @@ func (a *FirewallApplier) isEnabled() (bool, error) {
 	return strings.Contains(string(output), "Status: active"), nil
 }
 
-func (a *FirewallApplier) enable() error {
+func (a *ufwBackend) enable(ctx context.Context) error {
 	// Use --force to avoid interactive prompt
-	cmd := exec.Command("sudo", "ufw", "--force", "enable")
-	output, err := cmd.CombinedOutput()
+	output, err := runCombined(ctx, "sudo", "ufw", "--force", "enable")
 	if err != nil {
 		return fmt.Errorf("%s (output: %s)", err, string(output))
 	}
 	return nil
 }
 
-func (a *FirewallApplier) disable() error {
-	cmd := exec.Command("sudo", "ufw", "disable")
-	output, err := cmd.CombinedOutput()
+func (a *ufwBackend) disable(ctx context.Context) error {
+	output, err := runCombined(ctx, "sudo", "ufw", "disable")
 	if err != nil {
 		return fmt.Errorf("%s (output: %s)", err, string(output))
 	}
 	return nil
 }
 
-func (a *FirewallApplier) allowService(service string) error {
-	cmd := exec.Command("sudo", "ufw", "allow", service)
-	output, err := cmd.CombinedOutput()
+func (a *ufwBackend) run(ctx context.Context, args ...string) error {
+	output, err := runCombined(ctx, "sudo", append([]string{"ufw"}, args...)...)
 	if err != nil {
 		return fmt.Errorf("%s (output: %s)", err, string(output))
 	}
@@ -0,0 +1,306 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// firewalldBackend manages the firewall through firewalld. Changes go to the
+// permanent configuration and are loaded with a single reload, so they survive
+// a restart. Interfaces are assigned to their zone; services allowed on any
+// interface are added to the default zone, and those scoped to an interface
+// to that interface's zone.
+type firewalldBackend struct{}
+
+// firewalldZone is what a zone's permanent configuration allows
+type firewalldZone struct {
+	services map[string]bool
+	ports    map[string]bool
+}
+
+// firewalldEntry is one service or port to add to a zone
+type firewalldEntry struct {
+	port  bool
+	value string
+}
+
+func (e firewalldEntry) arg() string {
+	if e.port {
+		return "--add-port=" + e.value
+	}
+	return "--add-service=" + e.value
+}
+
+func (a *firewalldBackend) apply(ctx context.Context, fw *config.FirewallConfig, dryRun bool) ApplyResult {
+	result := ApplyResult{
+		Actions: []string{},
+	}
+
+	if _, err := exec.LookPath("firewall-cmd"); err != nil {
+		result.Error = fmt.Errorf("%w: firewall-cmd", ErrFirewallBackendMissing)
+		return result
+	}
+
+	running, err := a.isRunning(ctx)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to check firewalld state: %w", err)
+		return result
+	}
+
+	if !fw.Enabled {
+		if running {
+			result.Changed = true
+			result.Actions = append(result.Actions, "systemctl disable --now firewalld")
+			if !dryRun {
+				if err := a.systemctl(ctx, "disable"); err != nil {
+					result.Error = err
+				}
+			}
+		}
+		return result
+	}
+
+	if !running {
+		result.Changed = true
+		result.Actions = append(result.Actions, "systemctl enable --now firewalld")
+		if !dryRun {
+			if err := a.systemctl(ctx, "enable"); err != nil {
+				result.Error = err
+				return result
+			}
+		}
+	}
+
+	// firewall-cmd can only be queried while firewalld runs, so while
+	// enabling it in dry-run every change is reported
+	query := running || !dryRun
+
+	changes, err := a.changes(ctx, fw, query)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	if len(changes) == 0 {
+		return result
+	}
+
+	result.Changed = true
+	for _, args := range changes {
+		action := "firewall-cmd " + strings.Join(args, " ")
+		result.Actions = append(result.Actions, action)
+		if !dryRun {
+			if err := a.run(ctx, args...); err != nil {
+				result.Error = fmt.Errorf("failed to run %s: %w", action, err)
+				return result
+			}
+		}
+	}
+	result.Actions = append(result.Actions, "firewall-cmd --reload")
+	if !dryRun {
+		if err := a.run(ctx, "--reload"); err != nil {
+			result.Error = fmt.Errorf("failed to reload firewalld: %w", err)
+		}
+	}
+	return result
+}
+
+// changes returns the firewall-cmd arguments bringing the permanent
+// configuration in line with fw: interface zone assignments first, then the
+// services and ports missing from each zone. Without query the current
+// configuration is taken to be empty.
+func (a *firewalldBackend) changes(ctx context.Context, fw *config.FirewallConfig, query bool) ([][]string, error) {
+	var changes [][]string
+
+	ifaceZones := make(map[string]string, len(fw.Interfaces))
+	for _, iface := range fw.Interfaces {
+		ifaceZones[iface.Name] = iface.Zone
+		if iface.Zone == "" {
+			continue
+		}
+		current := ""
+		if query {
+			var err error
+			if current, err = a.zoneOfInterface(ctx, iface.Name); err != nil {
+				return nil, fmt.Errorf("failed to get zone of interface %s: %w", iface.Name, err)
+			}
+		}
+		if current != iface.Zone {
+			changes = append(changes, []string{"--permanent", "--zone=" + iface.Zone, "--change-interface=" + iface.Name})
+		}
+	}
+
+	// The empty zone is the default one
+	zones := make(map[string]firewalldZone)
+	for _, allow := range allowedServices(fw) {
+		zone := ""
+		if allow.iface != "" {
+			if zone = ifaceZones[allow.iface]; zone == "" {
+				return nil, fmt.Errorf("cannot allow %s: firewalld scopes services by zone and interface %s has none", allow, allow.iface)
+			}
+		}
+
+		entries, err := firewalldEntries(allow.service)
+		if err != nil {
+			return nil, err
+		}
+
+		current, ok := zones[zone]
+		if !ok {
+			current = firewalldZone{services: map[string]bool{}, ports: map[string]bool{}}
+			if query {
+				if current, err = a.zone(ctx, zone); err != nil {
+					return nil, fmt.Errorf("failed to list firewalld zone %s: %w", firewalldZoneName(zone), err)
+				}
+			}
+			zones[zone] = current
+		}
+
+		for _, entry := range entries {
+			if (entry.port && current.ports[entry.value]) || (!entry.port && current.services[entry.value]) {
+				continue
+			}
+			if entry.port {
+				current.ports[entry.value] = true
+			} else {
+				current.services[entry.value] = true
+			}
+			args := append([]string{"--permanent"}, firewalldZoneArgs(zone)...)
+			changes = append(changes, append(args, entry.arg()))
+		}
+	}
+	return changes, nil
+}
+
+// firewalldEntries translates an allowed service into what firewalld allows.
+// A name is a firewalld service ("ssh"); a port or range is allowed on TCP and
+// UDP unless a protocol is given ("51820/udp", "60000:61000/udp"), and a name
+// with a protocol ("https/tcp") is looked up in /etc/services.
+func firewalldEntries(service string) ([]firewalldEntry, error) {
+	protos := []string{"tcp", "udp"}
+
+	if m := nftPortSpec.FindStringSubmatch(service); m != nil {
+		port := m[1]
+		if m[2] != "" {
+			port += "-" + m[2]
+		}
+		if m[3] != "" {
+			protos = []string{m[3]}
+		}
+		var entries []firewalldEntry
+		for _, proto := range protos {
+			entries = append(entries, firewalldEntry{port: true, value: port + "/" + proto})
+		}
+		return entries, nil
+	}
+
+	name, proto, hasProto := strings.Cut(service, "/")
+	if !nftServiceName.MatchString(name) || (hasProto && proto != "tcp" && proto != "udp") {
+		return nil, fmt.Errorf("invalid allowed service %q: want a service name, port or port/proto", service)
+	}
+	if !hasProto {
+		return []firewalldEntry{{value: name}}, nil
+	}
+	port, err := net.LookupPort(proto, name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allowed service %q: %w", service, err)
+	}
+	return []firewalldEntry{{port: true, value: fmt.Sprintf("%d/%s", port, proto)}}, nil
+}
+
+// firewalldZoneArgs selects zone, or the default zone when it is empty
+func firewalldZoneArgs(zone string) []string {
+	if zone == "" {
+		return nil
+	}
+	return []string{"--zone=" + zone}
+}
+
+func firewalldZoneName(zone string) string {
+	if zone == "" {
+		return "(default)"
+	}
+	return zone
+}
+
+// zone returns the services and ports zone's permanent configuration allows
+func (a *firewalldBackend) zone(ctx context.Context, zone string) (firewalldZone, error) {
+	list := func(what string) (map[string]bool, error) {
+		args := append([]string{"firewall-cmd", "--permanent"}, firewalldZoneArgs(zone)...)
+		output, err := runOutput(ctx, "sudo", append(args, "--list-"+what)...)
+		if err != nil {
+			return nil, err
+		}
+		set := make(map[string]bool)
+		for _, field := range strings.Fields(string(output)) {
+			set[field] = true
+		}
+		return set, nil
+	}
+
+	services, err := list("services")
+	if err != nil {
+		return firewalldZone{}, err
+	}
+	ports, err := list("ports")
+	if err != nil {
+		return firewalldZone{}, err
+	}
+	return firewalldZone{services: services, ports: ports}, nil
+}
+
+// zoneOfInterface returns the zone iface is assigned to in the permanent
+// configuration, or "" when it has none
+func (a *firewalldBackend) zoneOfInterface(ctx context.Context, iface string) (string, error) {
+	output, err := runCombined(ctx, "sudo", "firewall-cmd", "--permanent", "--get-zone-of-interface="+iface)
+	zone := strings.TrimSpace(string(output))
+	if err != nil {
+		if zone == "no zone" {
+			return "", nil
+		}
+		return "", fmt.Errorf("%s (output: %s)", err, zone)
+	}
+	return zone, nil
+}
+
+func (a *firewalldBackend) check(ctx context.Context) (bool, error) {
+	if _, err := exec.LookPath("firewall-cmd"); err != nil {
+		return false, fmt.Errorf("%w: firewall-cmd", ErrFirewallBackendMissing)
+	}
+	return a.isRunning(ctx)
+}
+
+// isRunning reports whether firewalld is running; `firewall-cmd --state`
+// exits non-zero and prints "not running" when it isn't
+func (a *firewalldBackend) isRunning(ctx context.Context) (bool, error) {
+	output, err := runCombined(ctx, "sudo", "firewall-cmd", "--state")
+	state := strings.TrimSpace(string(output))
+	if err != nil {
+		if state == "not running" {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s (output: %s)", err, state)
+	}
+	return state == "running", nil
+}
+
+// systemctl enables or disables firewalld, starting or stopping it with it
+func (a *firewalldBackend) systemctl(ctx context.Context, verb string) error {
+	output, err := runCombined(ctx, "sudo", "systemctl", verb, "--now", "firewalld")
+	if err != nil {
+		return fmt.Errorf("failed to %s firewalld: %s (output: %s)", verb, err, string(output))
+	}
+	return nil
+}
+
+func (a *firewalldBackend) run(ctx context.Context, args ...string) error {
+	output, err := runCombined(ctx, "sudo", append([]string{"firewall-cmd"}, args...)...)
+	if err != nil {
+		return fmt.Errorf("%s (output: %s)", err, string(output))
+	}
+	return nil
+}
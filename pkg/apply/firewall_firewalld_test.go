@@ -0,0 +1,168 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestFirewalldEntries(t *testing.T) {
+	tests := []struct {
+		service string
+		want    []firewalldEntry
+		wantErr bool
+	}{
+		{service: "ssh", want: []firewalldEntry{{value: "ssh"}}},
+		{service: "22", want: []firewalldEntry{{port: true, value: "22/tcp"}, {port: true, value: "22/udp"}}},
+		{service: "51820/udp", want: []firewalldEntry{{port: true, value: "51820/udp"}}},
+		{service: "60000:61000/udp", want: []firewalldEntry{{port: true, value: "60000-61000/udp"}}},
+		{service: "ssh/sctp", wantErr: true},
+		{service: "ssh; drop", wantErr: true},
+		{service: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			got, err := firewalldEntries(tt.service)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("firewalldEntries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("firewalldEntries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeFirewallCmd puts a firewall-cmd on PATH that logs its arguments and
+// answers queries from a firewalld in state ("running" or "not running")
+// with eth0 in the public zone, ssh allowed in the default zone and 53/udp in
+// the internal zone. It returns a context running commands directly and the
+// log.
+func fakeFirewallCmd(t *testing.T, state string) (context.Context, string) {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "calls")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$*" >> %s
+case "$*" in
+--state) echo %q; [ %q = running ] || exit 252 ;;
+"--permanent --get-zone-of-interface=eth0") echo public ;;
+--permanent\ --get-zone-of-interface=*) echo "no zone"; exit 2 ;;
+"--permanent --list-services") echo "ssh dhcpv6-client" ;;
+"--permanent --zone=internal --list-ports") echo "53/udp" ;;
+esac
+`, log, state, state)
+	if err := os.WriteFile(filepath.Join(dir, "firewall-cmd"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	return WithPrivilegeCommand(context.Background(), nil), log
+}
+
+func firewalldConfig() *config.FirewallConfig {
+	return &config.FirewallConfig{
+		Enabled:         true,
+		Provider:        config.FirewallProviderFirewalld,
+		AllowedServices: []string{"ssh", "443/tcp"},
+		Interfaces: []config.FirewallInterface{
+			{Name: "eth0", Zone: "public"},
+			{Name: "eth1", Zone: "internal", AllowedServices: []string{"53/udp", "dns"}},
+		},
+	}
+}
+
+func TestFirewalldBackend_Apply(t *testing.T) {
+	ctx, log := fakeFirewallCmd(t, "running")
+
+	result := NewFirewallApplier().Apply(ctx, firewalldConfig(), false)
+	if result.Error != nil {
+		t.Fatalf("Apply() error = %v", result.Error)
+	}
+	if !result.Changed {
+		t.Error("Apply() Changed = false, want true")
+	}
+
+	want := []string{
+		"--state",
+		"--permanent --get-zone-of-interface=eth0",
+		"--permanent --get-zone-of-interface=eth1",
+		"--permanent --list-services",
+		"--permanent --list-ports",
+		"--permanent --zone=internal --list-services",
+		"--permanent --zone=internal --list-ports",
+		// eth0 is already in public, ssh and 53/udp already allowed
+		"--permanent --zone=internal --change-interface=eth1",
+		"--permanent --add-port=443/tcp",
+		"--permanent --zone=internal --add-service=dns",
+		"--reload",
+	}
+	if got := sudoCalls(t, log); !reflect.DeepEqual(got, want) {
+		t.Errorf("firewall-cmd calls:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFirewalldBackend_Compliant(t *testing.T) {
+	ctx, log := fakeFirewallCmd(t, "running")
+
+	fw := &config.FirewallConfig{
+		Enabled:         true,
+		Provider:        config.FirewallProviderFirewalld,
+		AllowedServices: []string{"ssh"},
+		Interfaces:      []config.FirewallInterface{{Name: "eth0", Zone: "public"}},
+	}
+	result := NewFirewallApplier().Apply(ctx, fw, false)
+	if result.Error != nil || result.Changed {
+		t.Fatalf("Apply() = %+v, want no change", result)
+	}
+	for _, call := range sudoCalls(t, log) {
+		if call == "--reload" {
+			t.Error("compliant firewalld was reloaded")
+		}
+	}
+}
+
+func TestFirewalldBackend_DryRunNotRunning(t *testing.T) {
+	ctx, log := fakeFirewallCmd(t, "not running")
+
+	result := NewFirewallApplier().Apply(ctx, firewalldConfig(), true)
+	if result.Error != nil {
+		t.Fatalf("Apply() error = %v", result.Error)
+	}
+
+	// firewalld can't be queried while stopped, so everything is reported
+	want := []string{
+		"systemctl enable --now firewalld",
+		"firewall-cmd --permanent --zone=public --change-interface=eth0",
+		"firewall-cmd --permanent --zone=internal --change-interface=eth1",
+		"firewall-cmd --permanent --add-service=ssh",
+		"firewall-cmd --permanent --add-port=443/tcp",
+		"firewall-cmd --permanent --zone=internal --add-port=53/udp",
+		"firewall-cmd --permanent --zone=internal --add-service=dns",
+		"firewall-cmd --reload",
+	}
+	if !reflect.DeepEqual(result.Actions, want) {
+		t.Errorf("Actions:\n got %q\nwant %q", result.Actions, want)
+	}
+	if got := sudoCalls(t, log); !reflect.DeepEqual(got, []string{"--state"}) {
+		t.Errorf("dry-run ran %q", got)
+	}
+}
+
+func TestFirewalldBackend_InterfaceWithoutZone(t *testing.T) {
+	ctx, _ := fakeFirewallCmd(t, "running")
+
+	fw := &config.FirewallConfig{
+		Enabled:    true,
+		Provider:   config.FirewallProviderFirewalld,
+		Interfaces: []config.FirewallInterface{{Name: "eth1", AllowedServices: []string{"ssh"}}},
+	}
+	if result := NewFirewallApplier().Apply(ctx, fw, true); result.Error == nil {
+		t.Error("expected error for an interface-scoped service without a zone")
+	}
+}
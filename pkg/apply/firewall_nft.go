@@ -0,0 +1,201 @@
+package apply
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// nftManagedTable is the inet table power-edge owns. Nothing outside it is
+// touched, so rules from other tools keep working alongside ours.
+const nftManagedTable = "power-edge"
+
+var (
+	nftServiceName   = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+	nftPortSpec      = regexp.MustCompile(`^([0-9]+)(?:[:-]([0-9]+))?(?:/(tcp|udp))?$`)
+	nftDigestComment = regexp.MustCompile(`comment "power-edge:([0-9a-f]+)"`)
+)
+
+// nftBackend manages the firewall as a dedicated nftables table. The table is
+// declarative: every enforce replaces it atomically, and a digest of the
+// rendered ruleset stored in the table's comment tells whether it has drifted.
+type nftBackend struct {
+	table string
+}
+
+func newNftBackend() *nftBackend {
+	return &nftBackend{table: nftManagedTable}
+}
+
+func (a *nftBackend) apply(ctx context.Context, fw *config.FirewallConfig, dryRun bool) ApplyResult {
+	result := ApplyResult{
+		Actions: []string{},
+	}
+
+	if _, err := exec.LookPath("nft"); err != nil {
+		result.Error = fmt.Errorf("%w: nft", ErrFirewallBackendMissing)
+		return result
+	}
+
+	present, digest, err := a.current(ctx)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to read nftables table %s: %w", a.table, err)
+		return result
+	}
+
+	if !fw.Enabled {
+		if present {
+			result.Changed = true
+			result.Actions = append(result.Actions, fmt.Sprintf("nft delete table inet %s", a.table))
+			if !dryRun {
+				if err := a.deleteTable(ctx); err != nil {
+					result.Error = err
+				}
+			}
+		}
+		return result
+	}
+
+	ruleset, want, err := renderNftRuleset(a.table, fw)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	if present && digest == want {
+		return result
+	}
+
+	result.Changed = true
+	var allows []string
+	for _, allow := range allowedServices(fw) {
+		allows = append(allows, allow.String())
+	}
+	result.Actions = append(result.Actions, fmt.Sprintf("nft replace table inet %s (allow: %s)", a.table, strings.Join(allows, ", ")))
+	if !dryRun {
+		if err := a.replaceTable(ctx, ruleset); err != nil {
+			result.Error = err
+		}
+	}
+	return result
+}
+
+// check reports whether the managed table is loaded
+func (a *nftBackend) check(ctx context.Context) (bool, error) {
+	if _, err := exec.LookPath("nft"); err != nil {
+		return false, fmt.Errorf("%w: nft", ErrFirewallBackendMissing)
+	}
+	present, _, err := a.current(ctx)
+	return present, err
+}
+
+// current returns whether the managed table exists and the digest it was
+// loaded with
+func (a *nftBackend) current(ctx context.Context) (present bool, digest string, err error) {
+	output, err := runCombined(ctx, "sudo", "nft", "list", "table", "inet", a.table)
+	if err != nil {
+		if strings.Contains(string(output), "No such file or directory") {
+			return false, "", nil
+		}
+		return false, "", fmt.Errorf("%s (output: %s)", err, string(output))
+	}
+
+	if m := nftDigestComment.FindSubmatch(output); m != nil {
+		digest = string(m[1])
+	}
+	return true, digest, nil
+}
+
+// replaceTable swaps in ruleset in a single nft transaction. Declaring the
+// table first makes the delete succeed when it doesn't exist yet.
+func (a *nftBackend) replaceTable(ctx context.Context, ruleset string) error {
+	script := fmt.Sprintf("table inet %s\ndelete table inet %s\n%s", a.table, a.table, ruleset)
+
+	output, err := runCombinedInput(ctx, strings.NewReader(script), "sudo", "nft", "-f", "-")
+	if err != nil {
+		return fmt.Errorf("%s (output: %s)", err, string(output))
+	}
+	return nil
+}
+
+func (a *nftBackend) deleteTable(ctx context.Context) error {
+	output, err := runCombined(ctx, "sudo", "nft", "delete", "table", "inet", a.table)
+	if err != nil {
+		return fmt.Errorf("%s (output: %s)", err, string(output))
+	}
+	return nil
+}
+
+// renderNftRuleset builds the managed table for fw, returning it along with
+// the digest embedded in its comment
+func renderNftRuleset(table string, fw *config.FirewallConfig) (ruleset, digest string, err error) {
+	var chain strings.Builder
+
+	policy := "drop"
+	if fw.DefaultPolicy.Incoming == string(config.FirewallActionAllow) {
+		policy = "accept"
+	}
+	fmt.Fprintf(&chain, "\tchain input {\n\t\ttype filter hook input priority 0; policy %s;\n", policy)
+	chain.WriteString("\t\tct state established,related accept\n")
+	chain.WriteString("\t\tct state invalid drop\n")
+	chain.WriteString("\t\tiif \"lo\" accept\n")
+	chain.WriteString("\t\tmeta l4proto { icmp, ipv6-icmp } accept\n")
+
+	for _, service := range fw.AllowedServices {
+		rule, err := nftAcceptRule(service)
+		if err != nil {
+			return "", "", err
+		}
+		fmt.Fprintf(&chain, "\t\t%s\n", rule)
+	}
+	for _, iface := range fw.Interfaces {
+		for _, service := range iface.AllowedServices {
+			rule, err := nftAcceptRule(service)
+			if err != nil {
+				return "", "", err
+			}
+			fmt.Fprintf(&chain, "\t\tiifname %q %s\n", iface.Name, rule)
+		}
+	}
+	if fw.DefaultPolicy.Incoming == string(config.FirewallActionReject) {
+		chain.WriteString("\t\treject\n")
+	}
+	chain.WriteString("\t}\n")
+
+	sum := sha256.Sum256([]byte(chain.String()))
+	digest = hex.EncodeToString(sum[:])[:16]
+
+	ruleset = fmt.Sprintf("table inet %s {\n\tcomment \"power-edge:%s\"\n%s}\n", table, digest, chain.String())
+	return ruleset, digest, nil
+}
+
+// nftAcceptRule translates an allowed service into an nft accept rule. It
+// takes a service name from /etc/services ("ssh"), a port ("22"), or a port
+// or range with a protocol ("51820/udp", "60000:61000/udp"). Without a
+// protocol both TCP and UDP are allowed.
+func nftAcceptRule(service string) (string, error) {
+	if m := nftPortSpec.FindStringSubmatch(service); m != nil {
+		port := m[1]
+		if m[2] != "" {
+			port += "-" + m[2]
+		}
+		if m[3] != "" {
+			return fmt.Sprintf("%s dport %s accept", m[3], port), nil
+		}
+		return fmt.Sprintf("meta l4proto { tcp, udp } th dport %s accept", port), nil
+	}
+
+	name, proto, hasProto := strings.Cut(service, "/")
+	if !nftServiceName.MatchString(name) || (hasProto && proto != "tcp" && proto != "udp") {
+		return "", fmt.Errorf("invalid allowed service %q: want a service name, port or port/proto", service)
+	}
+	if hasProto {
+		return fmt.Sprintf("%s dport %s accept", proto, name), nil
+	}
+	return fmt.Sprintf("meta l4proto { tcp, udp } th dport %s accept", name), nil
+}
@@ -0,0 +1,111 @@
+package apply
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestNftAcceptRule(t *testing.T) {
+	tests := []struct {
+		service string
+		want    string
+		wantErr bool
+	}{
+		{service: "ssh", want: "meta l4proto { tcp, udp } th dport ssh accept"},
+		{service: "https/tcp", want: "tcp dport https accept"},
+		{service: "22", want: "meta l4proto { tcp, udp } th dport 22 accept"},
+		{service: "51820/udp", want: "udp dport 51820 accept"},
+		{service: "60000:61000/udp", want: "udp dport 60000-61000 accept"},
+		{service: "ssh/sctp", wantErr: true},
+		{service: "ssh; drop", wantErr: true},
+		{service: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			got, err := nftAcceptRule(tt.service)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("nftAcceptRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("nftAcceptRule() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderNftRuleset(t *testing.T) {
+	fw := &config.FirewallConfig{
+		Enabled:         true,
+		AllowedServices: []string{"ssh", "443/tcp"},
+		Interfaces:      []config.FirewallInterface{{Name: "eth1", AllowedServices: []string{"53/udp"}}},
+	}
+
+	ruleset, digest, err := renderNftRuleset("power-edge", fw)
+	if err != nil {
+		t.Fatalf("renderNftRuleset() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"table inet power-edge {",
+		`comment "power-edge:` + digest + `"`,
+		"policy drop;",
+		"th dport ssh accept",
+		"tcp dport 443 accept",
+		`iifname "eth1" udp dport 53 accept`,
+	} {
+		if !strings.Contains(ruleset, want) {
+			t.Errorf("ruleset missing %q:\n%s", want, ruleset)
+		}
+	}
+
+	// The digest must be stable across passes and track the rules
+	_, again, _ := renderNftRuleset("power-edge", fw)
+	if again != digest {
+		t.Errorf("digest changed between renders: %s != %s", again, digest)
+	}
+	fw.AllowedServices = append(fw.AllowedServices, "80/tcp")
+	_, changed, _ := renderNftRuleset("power-edge", fw)
+	if changed == digest {
+		t.Error("digest did not change when rules changed")
+	}
+}
+
+func TestRenderNftRuleset_DefaultPolicy(t *testing.T) {
+	tests := []struct {
+		incoming string
+		want     string
+	}{
+		{incoming: "", want: "policy drop;"},
+		{incoming: "deny", want: "policy drop;"},
+		{incoming: "allow", want: "policy accept;"},
+		{incoming: "reject", want: "\t\treject\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.incoming, func(t *testing.T) {
+			fw := &config.FirewallConfig{Enabled: true}
+			fw.DefaultPolicy.Incoming = tt.incoming
+
+			ruleset, _, err := renderNftRuleset("power-edge", fw)
+			if err != nil {
+				t.Fatalf("renderNftRuleset() error = %v", err)
+			}
+			if !strings.Contains(ruleset, tt.want) {
+				t.Errorf("ruleset missing %q:\n%s", tt.want, ruleset)
+			}
+		})
+	}
+}
+
+func TestRenderNftRuleset_InvalidService(t *testing.T) {
+	fw := &config.FirewallConfig{
+		Enabled:         true,
+		AllowedServices: []string{"ssh", "bad service"},
+	}
+	if _, _, err := renderNftRuleset("power-edge", fw); err == nil {
+		t.Error("expected error for invalid service")
+	}
+}
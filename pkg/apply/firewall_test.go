@@ -1,6 +1,9 @@
 package apply
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/power-edge/power-edge/pkg/config"
@@ -41,10 +44,10 @@ func TestFirewallApplier_Apply(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := NewFirewallApplier()
-			result := a.Apply(tt.fw, tt.dryRun)
+			result := a.Apply(context.Background(), tt.fw, tt.dryRun)
 
 			// If UFW is not installed, skip the test
-			if result.Error != nil && result.Error.Error() == "ufw is not installed" {
+			if errors.Is(result.Error, ErrFirewallBackendMissing) {
 				t.Skip("UFW not installed, skipping test")
 			}
 
@@ -55,16 +58,174 @@ func TestFirewallApplier_Apply(t *testing.T) {
 	}
 }
 
+func TestFirewallApplier_UnsupportedProvider(t *testing.T) {
+	a := NewFirewallApplier()
+	fw := &config.FirewallConfig{
+		Enabled:  true,
+		Provider: config.FirewallProviderIptables,
+	}
+
+	result := a.Apply(context.Background(), fw, true)
+	if !errors.Is(result.Error, ErrFirewallProviderUnsupported) {
+		t.Errorf("Apply() error = %v, want ErrFirewallProviderUnsupported", result.Error)
+	}
+	if _, err := a.Check(context.Background(), fw); !errors.Is(err, ErrFirewallProviderUnsupported) {
+		t.Errorf("Check() error = %v, want ErrFirewallProviderUnsupported", err)
+	}
+}
+
 func TestFirewallApplier_Check(t *testing.T) {
 	a := NewFirewallApplier()
 
-	enabled, err := a.Check()
+	enabled, err := a.Check(context.Background(), nil)
 
-	// If UFW is not installed, that's ok for the test
+	if errors.Is(err, ErrFirewallBackendMissing) {
+		t.Skip("UFW not installed, skipping test")
+	}
 	if err != nil {
-		t.Logf("Check() error (UFW may not be installed): %v", err)
+		t.Logf("Check() error: %v", err)
 		return
 	}
 
 	t.Logf("UFW enabled: %v", enabled)
 }
+
+func TestParseUfwVerbose(t *testing.T) {
+	output := `Status: active
+Logging: on (medium)
+Default: deny (incoming), allow (outgoing), disabled (routed)
+New profiles: skip
+
+To                         Action      From
+--                         ------      ----
+22/tcp                     ALLOW IN    Anywhere
+`
+	settings, active := parseUfwVerbose([]byte(output))
+	if !active {
+		t.Error("active = false, want true")
+	}
+	want := ufwSettings{incoming: "deny", outgoing: "allow", logging: "medium"}
+	if settings != want {
+		t.Errorf("settings = %+v, want %+v", settings, want)
+	}
+
+	settings, _ = parseUfwVerbose([]byte("Status: active\nLogging: off\nDefault: reject (incoming), reject (outgoing), deny (routed)\n"))
+	want = ufwSettings{incoming: "reject", outgoing: "reject", logging: "off"}
+	if settings != want {
+		t.Errorf("settings = %+v, want %+v", settings, want)
+	}
+
+	if _, active := parseUfwVerbose([]byte("Status: inactive\n")); active {
+		t.Error("active = true for an inactive firewall")
+	}
+}
+
+func TestParseUfwConfig(t *testing.T) {
+	defaults := `# /etc/default/ufw
+IPV6=yes
+DEFAULT_INPUT_POLICY="DROP"
+DEFAULT_OUTPUT_POLICY="ACCEPT"
+DEFAULT_FORWARD_POLICY="DROP"
+`
+	conf := "# /etc/ufw/ufw.conf\nENABLED=no\nLOGLEVEL=high\n"
+
+	settings := parseUfwConfig([]byte(defaults), []byte(conf))
+	want := ufwSettings{incoming: "deny", outgoing: "allow", logging: "high"}
+	if settings != want {
+		t.Errorf("settings = %+v, want %+v", settings, want)
+	}
+}
+
+func TestParseUfwRules(t *testing.T) {
+	output := `Status: active
+
+To                         Action      From
+--                         ------      ----
+22/tcp                     ALLOW       Anywhere
+OpenSSH                    ALLOW       Anywhere                   # admin access
+8080                       DENY        Anywhere
+443/tcp                    ALLOW       10.0.0.0/8
+22/tcp (v6)                ALLOW       Anywhere (v6)
+80/tcp on eth1             ALLOW       Anywhere
+80/tcp (v6) on eth1        ALLOW       Anywhere (v6)
+`
+	got := parseUfwRules([]byte(output))
+	want := []ufwRule{
+		{to: "22/tcp", action: "ALLOW", from: "Anywhere"},
+		{to: "OpenSSH", action: "ALLOW", from: "Anywhere"},
+		{to: "8080", action: "DENY", from: "Anywhere"},
+		{to: "443/tcp", action: "ALLOW", from: "10.0.0.0/8"},
+		{to: "22/tcp", action: "ALLOW", from: "Anywhere"},
+		{to: "80/tcp", iface: "eth1", action: "ALLOW", from: "Anywhere"},
+		{to: "80/tcp", iface: "eth1", action: "ALLOW", from: "Anywhere"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parseUfwRules() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("rule %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if rules := parseUfwRules([]byte("Status: inactive\n")); len(rules) != 0 {
+		t.Errorf("parseUfwRules() = %+v for an inactive firewall, want none", rules)
+	}
+}
+
+func TestUfwAllows(t *testing.T) {
+	rules := []ufwRule{
+		{to: "22/tcp", action: "ALLOW", from: "Anywhere"},
+		{to: "OpenSSH", action: "ALLOW IN", from: "Anywhere"},
+		{to: "51820/udp", action: "ALLOW", from: "Anywhere"},
+		{to: "8080", action: "DENY", from: "Anywhere"},
+		{to: "443/tcp", action: "ALLOW", from: "10.0.0.0/8"},
+		{to: "9100", action: "ALLOW", from: "Anywhere"},
+		{to: "80/tcp", iface: "eth1", action: "ALLOW IN", from: "Anywhere"},
+		{to: "WireGuard", iface: "wg0", action: "ALLOW IN", from: "Anywhere"},
+	}
+
+	tests := []struct {
+		service string
+		iface   string
+		want    bool
+	}{
+		{"ssh", "", true},       // shown by its port
+		{"22/tcp", "", true},    // exact
+		{"22", "", false},       // 22/udp isn't allowed
+		{"OpenSSH", "", true},   // application profile
+		{"51820/udp", "", true}, // exact
+		{"51820/tcp", "", false},
+		{"8080", "", false},    // denied, not allowed
+		{"https", "", false},   // only allowed from 10.0.0.0/8
+		{"9100/tcp", "", true}, // covered by a rule for both protocols
+		{"http", "", false},    // only allowed on eth1
+		{"80/tcp", "eth1", true},
+		{"80/tcp", "eth0", false},
+		{"22/tcp", "eth0", false}, // allowed everywhere isn't the same rule
+		{"WireGuard", "wg0", true},
+	}
+	for _, tt := range tests {
+		if got := ufwAllows(rules, tt.service, tt.iface); got != tt.want {
+			t.Errorf("ufwAllows(%q, %q) = %v, want %v", tt.service, tt.iface, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedService_UfwArgs(t *testing.T) {
+	tests := []struct {
+		allow allowedService
+		want  string
+	}{
+		{allowedService{service: "ssh"}, "allow ssh"},
+		{allowedService{service: "22/tcp", iface: "eth0"}, "allow in on eth0 to any port 22 proto tcp"},
+		{allowedService{service: "9100", iface: "eth0"}, "allow in on eth0 to any port 9100"},
+		{allowedService{service: "60000:61000/udp", iface: "wg0"}, "allow in on wg0 to any port 60000:61000 proto udp"},
+		{allowedService{service: "No Such Profile", iface: "eth0"}, "allow in on eth0 to any app No Such Profile"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(tt.allow.ufwArgs(), " "); got != tt.want {
+			t.Errorf("ufwArgs(%s) = %q, want %q", tt.allow, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package apply
+
+import "os"
+
+// copyOwner is a no-op where Unix ownership doesn't apply
+func copyOwner(path string, info os.FileInfo) error {
+	return nil
+}
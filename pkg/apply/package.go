@@ -1,16 +1,35 @@
 package apply
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/power-edge/power-edge/pkg/config"
 )
 
+// ErrNoPackageManager is returned when the host has none of the package
+// managers power-edge drives, so packages and repositories can't be managed
+var ErrNoPackageManager = errors.New("no supported package manager found (apt/yum/dnf)")
+
+// defaultCacheMaxAge is how old the apt index may get under the if-stale
+// refresh policy when the state doesn't say
+const defaultCacheMaxAge = time.Hour
+
 // PackageApplier is the single source of truth for applying package state
 type PackageApplier struct {
 	packageManager string // "apt", "yum", "dnf"
+	aptListsDir    string // Where apt keeps the downloaded index; its age is the index's
+
+	refreshMu   sync.Mutex
+	policy      config.PackagePolicy
+	refreshed   bool      // The index was refreshed, or tried to be, during the current pass
+	lastRefresh time.Time // When this applier last refreshed the index
 }
 
 // NewPackageApplier creates a new package applier (auto-detects package manager)
@@ -18,44 +37,83 @@ func NewPackageApplier() *PackageApplier {
 	pm := detectPackageManager()
 	return &PackageApplier{
 		packageManager: pm,
+		aptListsDir:    "/var/lib/apt/lists",
 	}
 }
 
+// SetPolicy sets when the package index is refreshed before installs
+func (a *PackageApplier) SetPolicy(policy config.PackagePolicy) {
+	a.refreshMu.Lock()
+	defer a.refreshMu.Unlock()
+	a.policy = policy
+}
+
+// BeginPass starts a reconcile pass, which may refresh the index once more
+func (a *PackageApplier) BeginPass() {
+	a.refreshMu.Lock()
+	defer a.refreshMu.Unlock()
+	a.refreshed = false
+}
+
+// MarkRefreshed records that the index was just refreshed by someone else,
+// e.g. after a repository change, so this pass doesn't refresh it again
+func (a *PackageApplier) MarkRefreshed() {
+	a.refreshMu.Lock()
+	defer a.refreshMu.Unlock()
+	a.refreshed = true
+	a.lastRefresh = time.Now()
+}
+
 // Apply ensures a package matches its desired state
-func (a *PackageApplier) Apply(pkg config.PackageConfig, dryRun bool) ApplyResult {
+func (a *PackageApplier) Apply(ctx context.Context, pkg config.PackageConfig, dryRun bool) ApplyResult {
 	result := ApplyResult{
 		Actions: []string{},
 	}
 
 	if a.packageManager == "" {
-		result.Error = fmt.Errorf("no supported package manager found (apt/yum/dnf)")
+		result.Error = ErrNoPackageManager
 		return result
 	}
 
 	// Check if package is installed
-	isInstalled, installedVersion, err := a.isInstalled(pkg.Name)
+	isInstalled, installedVersion, err := a.isInstalled(ctx, pkg.Name)
 	if err != nil {
 		result.Error = fmt.Errorf("failed to check package status: %w", err)
 		return result
 	}
 
+	// Installs and upgrades need a current index; refresh it first if due
+	refresh := func() {
+		if dryRun {
+			return
+		}
+		action, err := a.refreshIndex(ctx)
+		if err != nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("continuing with the current package index: %v", err))
+		} else if action != "" {
+			result.Actions = append(result.Actions, action)
+		}
+	}
+
 	// Determine required action based on desired state
 	switch pkg.State {
 	case config.PackageStatePresent:
 		if !isInstalled {
+			refresh()
 			result.Changed = true
 			result.Actions = append(result.Actions, fmt.Sprintf("%s install %s", a.packageManager, pkg.Name))
 			if !dryRun {
-				if err := a.install(pkg.Name, pkg.Version); err != nil {
+				if err := a.install(ctx, pkg.Name, pkg.Version); err != nil {
 					result.Error = err
 					return result
 				}
 			}
 		} else if pkg.Version != "" && installedVersion != pkg.Version {
+			refresh()
 			result.Changed = true
 			result.Actions = append(result.Actions, fmt.Sprintf("%s install %s=%s", a.packageManager, pkg.Name, pkg.Version))
 			if !dryRun {
-				if err := a.install(pkg.Name, pkg.Version); err != nil {
+				if err := a.install(ctx, pkg.Name, pkg.Version); err != nil {
 					result.Error = err
 					return result
 				}
@@ -67,7 +125,7 @@ func (a *PackageApplier) Apply(pkg config.PackageConfig, dryRun bool) ApplyResul
 			result.Changed = true
 			result.Actions = append(result.Actions, fmt.Sprintf("%s remove %s", a.packageManager, pkg.Name))
 			if !dryRun {
-				if err := a.remove(pkg.Name); err != nil {
+				if err := a.remove(ctx, pkg.Name); err != nil {
 					result.Error = err
 					return result
 				}
@@ -75,34 +133,169 @@ func (a *PackageApplier) Apply(pkg config.PackageConfig, dryRun bool) ApplyResul
 		}
 
 	case config.PackageStateLatest:
+		refresh()
 		if !isInstalled {
 			result.Changed = true
 			result.Actions = append(result.Actions, fmt.Sprintf("%s install %s", a.packageManager, pkg.Name))
 			if !dryRun {
-				if err := a.install(pkg.Name, ""); err != nil {
+				if err := a.install(ctx, pkg.Name, ""); err != nil {
 					result.Error = err
 					return result
 				}
 			}
 		} else {
-			// Check if update available (simplified - just try to upgrade)
-			result.Actions = append(result.Actions, fmt.Sprintf("%s upgrade %s", a.packageManager, pkg.Name))
-			if !dryRun {
-				if err := a.upgrade(pkg.Name); err != nil {
-					result.Error = err
-					return result
+			target, available, err := a.availableUpdate(ctx, pkg.Name)
+			if err != nil {
+				result.Error = fmt.Errorf("failed to check for updates: %w", err)
+				return result
+			}
+			if available {
+				result.Changed = true
+				result.Actions = append(result.Actions, upgradeAction(pkg.Name, installedVersion, target))
+				if !dryRun {
+					if err := a.upgrade(ctx, pkg.Name); err != nil {
+						result.Error = err
+						return result
+					}
 				}
 			}
-			result.Changed = true
+		}
+	}
+
+	// Reconcile version hold once the package is (or will be) installed
+	if pkg.State != config.PackageStateAbsent {
+		if err := a.applyHold(ctx, pkg, dryRun, &result); err != nil {
+			result.Error = err
+			return result
 		}
 	}
 
 	return result
 }
 
-// Check returns whether a package is installed and its version
-func (a *PackageApplier) Check(name string) (installed bool, version string, err error) {
-	return a.isInstalled(name)
+// Check returns whether a package is installed, its version, and whether it is held
+func (a *PackageApplier) Check(ctx context.Context, name string) (installed bool, version string, held bool, err error) {
+	installed, version, err = a.isInstalled(ctx, name)
+	if err != nil || !installed {
+		return installed, version, false, err
+	}
+
+	held, err = a.isHeld(ctx, name)
+	if errors.Is(err, errVersionlockMissing) {
+		// Without the plugin nothing can be locked
+		return installed, version, false, nil
+	}
+	return installed, version, held, err
+}
+
+// errVersionlockMissing is returned when dnf/yum lack the versionlock plugin
+var errVersionlockMissing = errors.New("versionlock plugin not available")
+
+func (a *PackageApplier) applyHold(ctx context.Context, pkg config.PackageConfig, dryRun bool, result *ApplyResult) error {
+	held, err := a.isHeld(ctx, pkg.Name)
+	if errors.Is(err, errVersionlockMissing) {
+		if !pkg.Hold {
+			// Nothing can be held without the plugin, so unpinned is already satisfied
+			return nil
+		}
+		return fmt.Errorf("cannot hold %s: %s versionlock plugin is not installed (install python3-dnf-plugin-versionlock or yum-plugin-versionlock)", pkg.Name, a.packageManager)
+	} else if err != nil {
+		return fmt.Errorf("failed to check hold status: %w", err)
+	}
+
+	if pkg.Hold == held {
+		return nil
+	}
+
+	action, args := a.holdCommand(pkg.Name, pkg.Hold)
+	result.Changed = true
+	result.Actions = append(result.Actions, action)
+	if dryRun {
+		return nil
+	}
+
+	output, err := runCombined(ctx, "sudo", args...)
+	if err != nil {
+		return fmt.Errorf("%s failed: %s (output: %s)", action, err, string(output))
+	}
+	return nil
+}
+
+func (a *PackageApplier) holdCommand(name string, hold bool) (action string, args []string) {
+	switch a.packageManager {
+	case "apt":
+		verb := "unhold"
+		if hold {
+			verb = "hold"
+		}
+		args = []string{"apt-mark", verb, name}
+	default:
+		verb := "delete"
+		if hold {
+			verb = "add"
+		}
+		args = []string{a.packageManager, "versionlock", verb, name}
+	}
+	return strings.Join(args, " "), args
+}
+
+func (a *PackageApplier) isHeld(ctx context.Context, name string) (bool, error) {
+	switch a.packageManager {
+	case "apt":
+		output, err := runOutput(ctx, "apt-mark", "showhold", name)
+		if err != nil {
+			return false, err
+		}
+		for _, line := range strings.Split(string(output), "\n") {
+			if strings.TrimSpace(line) == name {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "yum", "dnf":
+		output, err := runCombined(ctx, a.packageManager, "versionlock", "list")
+		if err != nil {
+			if strings.Contains(string(output), "No such command") {
+				return false, errVersionlockMissing
+			}
+			return false, fmt.Errorf("%s (output: %s)", err, string(output))
+		}
+		return versionlockContains(string(output), name), nil
+	default:
+		return false, fmt.Errorf("unsupported package manager: %s", a.packageManager)
+	}
+}
+
+// versionlockContains reports whether `versionlock list` output pins name.
+// dnf prints "nginx-1:1.20.1-1.el8.*" while yum prefixes the epoch: "0:nginx-1.20.1-1.el8.*".
+func versionlockContains(output, name string) bool {
+	for _, line := range strings.Split(output, "\n") {
+		entry := strings.TrimSpace(line)
+		if epoch, rest, ok := strings.Cut(entry, ":"); ok && isDigits(epoch) {
+			entry = rest
+		}
+		if !strings.HasPrefix(entry, name+"-") {
+			continue
+		}
+		// The remainder must start with a version, not another package name (nginx-mod-...)
+		rest := entry[len(name)+1:]
+		if rest != "" && isDigits(rest[:1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
 }
 
 func detectPackageManager() string {
@@ -115,21 +308,23 @@ func detectPackageManager() string {
 	return ""
 }
 
-func (a *PackageApplier) isInstalled(name string) (bool, string, error) {
+func (a *PackageApplier) isInstalled(ctx context.Context, name string) (bool, string, error) {
 	switch a.packageManager {
 	case "apt":
-		return a.isInstalledApt(name)
+		return a.isInstalledApt(ctx, name)
 	case "yum", "dnf":
-		return a.isInstalledYum(name)
+		return a.isInstalledYum(ctx, name)
 	default:
 		return false, "", fmt.Errorf("unsupported package manager: %s", a.packageManager)
 	}
 }
 
-func (a *PackageApplier) isInstalledApt(name string) (bool, string, error) {
-	cmd := exec.Command("dpkg-query", "-W", "-f=${Status} ${Version}", name)
-	output, err := cmd.Output()
+func (a *PackageApplier) isInstalledApt(ctx context.Context, name string) (bool, string, error) {
+	output, err := runOutput(ctx, "dpkg-query", "-W", "-f=${Status} ${Version}", name)
 	if err != nil {
+		if errors.Is(err, ErrCommandTimeout) || ctx.Err() != nil {
+			return false, "", err
+		}
 		// Package not installed
 		return false, "", nil
 	}
@@ -142,10 +337,12 @@ func (a *PackageApplier) isInstalledApt(name string) (bool, string, error) {
 	return false, "", nil
 }
 
-func (a *PackageApplier) isInstalledYum(name string) (bool, string, error) {
-	cmd := exec.Command("rpm", "-q", name)
-	output, err := cmd.Output()
+func (a *PackageApplier) isInstalledYum(ctx context.Context, name string) (bool, string, error) {
+	output, err := runOutput(ctx, "rpm", "-q", name)
 	if err != nil {
+		if errors.Is(err, ErrCommandTimeout) || ctx.Err() != nil {
+			return false, "", err
+		}
 		// Package not installed
 		return false, "", nil
 	}
@@ -155,8 +352,55 @@ func (a *PackageApplier) isInstalledYum(name string) (bool, string, error) {
 	return true, version, nil
 }
 
-func (a *PackageApplier) install(name, version string) error {
-	var cmd *exec.Cmd
+// refreshIndex runs apt-get update before the first install or upgrade of a
+// pass, if the policy calls for it, and returns the action it took. dnf and
+// yum refresh expired metadata on their own.
+func (a *PackageApplier) refreshIndex(ctx context.Context) (string, error) {
+	if a.packageManager != "apt" {
+		return "", nil
+	}
+
+	a.refreshMu.Lock()
+	defer a.refreshMu.Unlock()
+	if a.refreshed {
+		return "", nil
+	}
+	switch a.policy.CacheRefresh {
+	case config.PackageCacheRefreshNever:
+		return "", nil
+	case config.PackageCacheRefreshAlways:
+	default:
+		maxAge := defaultCacheMaxAge
+		if a.policy.CacheMaxAge > 0 {
+			maxAge = time.Duration(a.policy.CacheMaxAge) * time.Second
+		}
+		if time.Since(a.indexUpdatedLocked()) < maxAge {
+			return "", nil
+		}
+	}
+
+	// Tried once per pass, even if it fails
+	a.refreshed = true
+	output, err := runCombined(ctx, "sudo", "apt-get", "update")
+	if err != nil {
+		return "", fmt.Errorf("apt-get update failed: %s (output: %s)", err, string(output))
+	}
+	a.lastRefresh = time.Now()
+	return "apt-get update", nil
+}
+
+// indexUpdatedLocked returns when the apt index was last refreshed, as far
+// as can be told
+func (a *PackageApplier) indexUpdatedLocked() time.Time {
+	updated := a.lastRefresh
+	if info, err := os.Stat(a.aptListsDir); err == nil && info.ModTime().After(updated) {
+		updated = info.ModTime()
+	}
+	return updated
+}
+
+func (a *PackageApplier) install(ctx context.Context, name, version string) error {
+	var args []string
 
 	packageSpec := name
 	if version != "" {
@@ -165,58 +409,133 @@ func (a *PackageApplier) install(name, version string) error {
 
 	switch a.packageManager {
 	case "apt":
-		cmd = exec.Command("sudo", "apt-get", "install", "-y", packageSpec)
+		args = []string{"apt-get", "install", "-y", packageSpec}
 	case "yum":
-		cmd = exec.Command("sudo", "yum", "install", "-y", packageSpec)
+		args = []string{"yum", "install", "-y", packageSpec}
 	case "dnf":
-		cmd = exec.Command("sudo", "dnf", "install", "-y", packageSpec)
+		args = []string{"dnf", "install", "-y", packageSpec}
 	default:
 		return fmt.Errorf("unsupported package manager: %s", a.packageManager)
 	}
 
-	output, err := cmd.CombinedOutput()
+	output, err := runCombined(ctx, "sudo", args...)
 	if err != nil {
 		return fmt.Errorf("%s (output: %s)", err, string(output))
 	}
 	return nil
 }
 
-func (a *PackageApplier) remove(name string) error {
-	var cmd *exec.Cmd
+func (a *PackageApplier) remove(ctx context.Context, name string) error {
+	var args []string
 
 	switch a.packageManager {
 	case "apt":
-		cmd = exec.Command("sudo", "apt-get", "remove", "-y", name)
+		args = []string{"apt-get", "remove", "-y", name}
 	case "yum":
-		cmd = exec.Command("sudo", "yum", "remove", "-y", name)
+		args = []string{"yum", "remove", "-y", name}
 	case "dnf":
-		cmd = exec.Command("sudo", "dnf", "remove", "-y", name)
+		args = []string{"dnf", "remove", "-y", name}
 	default:
 		return fmt.Errorf("unsupported package manager: %s", a.packageManager)
 	}
 
-	output, err := cmd.CombinedOutput()
+	output, err := runCombined(ctx, "sudo", args...)
 	if err != nil {
 		return fmt.Errorf("%s (output: %s)", err, string(output))
 	}
 	return nil
 }
 
-func (a *PackageApplier) upgrade(name string) error {
-	var cmd *exec.Cmd
+// availableUpdate reports whether a newer version of an installed package is
+// available from the configured repositories, and which version that is
+// ("" if the package manager doesn't say)
+func (a *PackageApplier) availableUpdate(ctx context.Context, name string) (version string, available bool, err error) {
+	switch a.packageManager {
+	case "apt":
+		output, err := runOutput(ctx, "apt", "list", "--upgradable", name)
+		if err != nil {
+			return "", false, err
+		}
+		version, available = parseAptUpgradable(string(output), name)
+		return version, available, nil
+	case "yum", "dnf":
+		// check-update exits 100 when updates are available
+		output, err := runOutput(ctx, a.packageManager, "check-update", "-q", name)
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 100 {
+			version, _ = parseCheckUpdate(string(output), name)
+			return version, true, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		return "", false, nil
+	default:
+		return "", false, fmt.Errorf("unsupported package manager: %s", a.packageManager)
+	}
+}
+
+// parseAptUpgradable finds name in `apt list --upgradable` output, e.g.
+// "nginx/jammy-updates 1.18.0-6ubuntu14.4 amd64 [upgradable from: 1.18.0-6ubuntu14.3]"
+func parseAptUpgradable(output, name string) (version string, found bool) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if pkg, _, ok := strings.Cut(fields[0], "/"); ok && pkg == name {
+			return fields[1], true
+		}
+	}
+	return "", false
+}
+
+// parseCheckUpdate finds name in `dnf check-update` output, e.g.
+// "nginx.x86_64    1:1.20.1-14.el9    appstream"
+func parseCheckUpdate(output, name string) (version string, found bool) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		pkg := fields[0]
+		if i := strings.LastIndex(pkg, "."); i > 0 {
+			pkg = pkg[:i]
+		}
+		if pkg == name {
+			return fields[1], true
+		}
+	}
+	return "", false
+}
+
+// upgradeAction describes an upgrade, naming the versions when known
+func upgradeAction(name, from, to string) string {
+	action := fmt.Sprintf("upgrade %s", name)
+	if from != "" {
+		action += " from " + from
+	}
+	if to != "" {
+		action += " to " + to
+	}
+	return action
+}
+
+func (a *PackageApplier) upgrade(ctx context.Context, name string) error {
+	var args []string
 
 	switch a.packageManager {
 	case "apt":
-		cmd = exec.Command("sudo", "apt-get", "install", "--only-upgrade", "-y", name)
+		args = []string{"apt-get", "install", "--only-upgrade", "-y", name}
 	case "yum":
-		cmd = exec.Command("sudo", "yum", "update", "-y", name)
+		args = []string{"yum", "update", "-y", name}
 	case "dnf":
-		cmd = exec.Command("sudo", "dnf", "upgrade", "-y", name)
+		args = []string{"dnf", "upgrade", "-y", name}
 	default:
 		return fmt.Errorf("unsupported package manager: %s", a.packageManager)
 	}
 
-	output, err := cmd.CombinedOutput()
+	output, err := runCombined(ctx, "sudo", args...)
 	if err != nil {
 		return fmt.Errorf("%s (output: %s)", err, string(output))
 	}
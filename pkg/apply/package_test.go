@@ -1,7 +1,14 @@
 package apply
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/power-edge/power-edge/pkg/config"
 )
@@ -36,10 +43,10 @@ func TestPackageApplier_Apply(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := NewPackageApplier()
-			result := a.Apply(tt.pkg, tt.dryRun)
+			result := a.Apply(context.Background(), tt.pkg, tt.dryRun)
 
 			// If no package manager found, skip
-			if result.Error != nil && result.Error.Error() == "no supported package manager found (apt/yum/dnf)" {
+			if errors.Is(result.Error, ErrNoPackageManager) {
 				t.Skip("No supported package manager found")
 			}
 
@@ -54,14 +61,14 @@ func TestPackageApplier_Check(t *testing.T) {
 	a := NewPackageApplier()
 
 	// Test checking a package that likely exists on most systems
-	installed, version, err := a.Check("bash")
+	installed, version, held, err := a.Check(context.Background(), "bash")
 
 	if err != nil {
 		t.Logf("Check() error: %v", err)
 		return
 	}
 
-	t.Logf("bash installed: %v, version: %s", installed, version)
+	t.Logf("bash installed: %v, version: %s, held: %v", installed, version, held)
 }
 
 func TestDetectPackageManager(t *testing.T) {
@@ -83,3 +90,245 @@ func TestDetectPackageManager(t *testing.T) {
 		t.Errorf("Unexpected package manager: %s", pm)
 	}
 }
+
+func TestPackageApplier_NoPackageManager(t *testing.T) {
+	a := &PackageApplier{}
+	result := a.Apply(context.Background(), config.PackageConfig{Name: "curl", State: "present"}, true)
+	if !errors.Is(result.Error, ErrNoPackageManager) {
+		t.Errorf("Apply() error = %v, want ErrNoPackageManager", result.Error)
+	}
+
+	repos := &RepositoryApplier{}
+	if _, err := repos.desiredFiles(context.Background(), config.RepoConfig{Name: "docker", URL: "https://example.com"}); !errors.Is(err, ErrNoPackageManager) {
+		t.Errorf("desiredFiles() error = %v, want ErrNoPackageManager", err)
+	}
+}
+
+func TestVersionlockContains(t *testing.T) {
+	output := `Last metadata expiration check: 0:12:34 ago.
+nginx-1:1.20.1-1.el8.*
+0:curl-7.61.1-22.el8.*
+`
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "nginx", want: true},
+		{name: "curl", want: true},
+		{name: "nginx-mod-http", want: false},
+		{name: "ngin", want: false},
+		{name: "bash", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := versionlockContains(output, tt.name); got != tt.want {
+			t.Errorf("versionlockContains(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPackageApplier_HoldCommand(t *testing.T) {
+	tests := []struct {
+		manager string
+		hold    bool
+		want    string
+	}{
+		{manager: "apt", hold: true, want: "apt-mark hold nginx"},
+		{manager: "apt", hold: false, want: "apt-mark unhold nginx"},
+		{manager: "dnf", hold: true, want: "dnf versionlock add nginx"},
+		{manager: "dnf", hold: false, want: "dnf versionlock delete nginx"},
+	}
+
+	for _, tt := range tests {
+		a := &PackageApplier{packageManager: tt.manager}
+		action, _ := a.holdCommand("nginx", tt.hold)
+		if action != tt.want {
+			t.Errorf("holdCommand(%s, %v) = %q, want %q", tt.manager, tt.hold, action, tt.want)
+		}
+	}
+}
+
+// fakeSudo puts a sudo on PATH that logs its arguments and exits with
+// status, returning the log's path
+func fakeSudo(t *testing.T, status int) string {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "calls")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$*\" >> %s\nexit %d\n", log, status)
+	if err := os.WriteFile(filepath.Join(dir, "sudo"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	return log
+}
+
+func sudoCalls(t *testing.T, log string) []string {
+	t.Helper()
+	data, err := os.ReadFile(log)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestPackageApplier_RefreshIndex(t *testing.T) {
+	ctx := context.Background()
+	lists := t.TempDir()
+	stale := time.Now().Add(-2 * time.Hour)
+
+	newApplier := func(policy config.PackagePolicy) *PackageApplier {
+		a := &PackageApplier{packageManager: "apt", aptListsDir: lists}
+		a.SetPolicy(policy)
+		return a
+	}
+
+	t.Run("always refreshes once per pass", func(t *testing.T) {
+		log := fakeSudo(t, 0)
+		a := newApplier(config.PackagePolicy{CacheRefresh: config.PackageCacheRefreshAlways})
+		for i := 0; i < 3; i++ {
+			if _, err := a.refreshIndex(ctx); err != nil {
+				t.Fatalf("refreshIndex() error = %v", err)
+			}
+		}
+		if calls := sudoCalls(t, log); len(calls) != 1 || calls[0] != "apt-get update" {
+			t.Fatalf("calls = %q, want one apt-get update", calls)
+		}
+		a.BeginPass()
+		if action, _ := a.refreshIndex(ctx); action != "apt-get update" {
+			t.Errorf("action = %q in a new pass, want apt-get update", action)
+		}
+	})
+
+	t.Run("if-stale skips a fresh index", func(t *testing.T) {
+		log := fakeSudo(t, 0)
+		if err := os.Chtimes(lists, time.Now(), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		a := newApplier(config.PackagePolicy{})
+		if action, _ := a.refreshIndex(ctx); action != "" {
+			t.Errorf("action = %q for a fresh index, want none", action)
+		}
+
+		if err := os.Chtimes(lists, stale, stale); err != nil {
+			t.Fatal(err)
+		}
+		if action, _ := a.refreshIndex(ctx); action != "apt-get update" {
+			t.Errorf("action = %q for a 2h old index, want apt-get update", action)
+		}
+		a.BeginPass()
+		if action, _ := a.refreshIndex(ctx); action != "" {
+			t.Errorf("action = %q right after a refresh, want none", action)
+		}
+		if calls := sudoCalls(t, log); len(calls) != 1 {
+			t.Errorf("calls = %q, want one", calls)
+		}
+
+		// A longer max age makes the same index fresh enough
+		if err := os.Chtimes(lists, stale, stale); err != nil {
+			t.Fatal(err)
+		}
+		a = newApplier(config.PackagePolicy{CacheRefresh: config.PackageCacheRefreshIfStale, CacheMaxAge: 3 * 3600})
+		if action, _ := a.refreshIndex(ctx); action != "" {
+			t.Errorf("action = %q within cache_max_age, want none", action)
+		}
+	})
+
+	t.Run("never and repository refreshes skip it", func(t *testing.T) {
+		log := fakeSudo(t, 0)
+		if err := os.Chtimes(lists, stale, stale); err != nil {
+			t.Fatal(err)
+		}
+		a := newApplier(config.PackagePolicy{CacheRefresh: config.PackageCacheRefreshNever})
+		a.refreshIndex(ctx)
+
+		a = newApplier(config.PackagePolicy{CacheRefresh: config.PackageCacheRefreshAlways})
+		a.MarkRefreshed()
+		a.refreshIndex(ctx)
+
+		a = &PackageApplier{packageManager: "dnf"}
+		a.refreshIndex(ctx)
+
+		if calls := sudoCalls(t, log); len(calls) != 0 {
+			t.Errorf("calls = %q, want none", calls)
+		}
+	})
+
+	t.Run("a failed refresh isn't retried in the pass", func(t *testing.T) {
+		log := fakeSudo(t, 100)
+		a := newApplier(config.PackagePolicy{CacheRefresh: config.PackageCacheRefreshAlways})
+		if _, err := a.refreshIndex(ctx); err == nil || !strings.Contains(err.Error(), "apt-get update failed") {
+			t.Errorf("refreshIndex() error = %v, want apt-get update failure", err)
+		}
+		if _, err := a.refreshIndex(ctx); err != nil {
+			t.Errorf("second refreshIndex() error = %v, want it skipped", err)
+		}
+		if calls := sudoCalls(t, log); len(calls) != 1 {
+			t.Errorf("calls = %q, want one", calls)
+		}
+	})
+}
+
+func TestParseAptUpgradable(t *testing.T) {
+	output := `Listing...
+nginx/jammy-updates 1.18.0-6ubuntu14.4 amd64 [upgradable from: 1.18.0-6ubuntu14.3]
+nginx-common/jammy-updates 1.18.0-6ubuntu14.4 all [upgradable from: 1.18.0-6ubuntu14.3]
+`
+	tests := []struct {
+		name      string
+		wantVer   string
+		wantFound bool
+	}{
+		{name: "nginx", wantVer: "1.18.0-6ubuntu14.4", wantFound: true},
+		{name: "nginx-common", wantVer: "1.18.0-6ubuntu14.4", wantFound: true},
+		{name: "curl", wantFound: false},
+	}
+
+	for _, tt := range tests {
+		ver, found := parseAptUpgradable(output, tt.name)
+		if ver != tt.wantVer || found != tt.wantFound {
+			t.Errorf("parseAptUpgradable(%q) = (%q, %v), want (%q, %v)", tt.name, ver, found, tt.wantVer, tt.wantFound)
+		}
+	}
+}
+
+func TestParseCheckUpdate(t *testing.T) {
+	output := `
+nginx.x86_64                 1:1.20.1-14.el9_2.1        appstream
+python3.11.x86_64            3.11.5-1.el9_3             appstream
+`
+	tests := []struct {
+		name      string
+		wantVer   string
+		wantFound bool
+	}{
+		{name: "nginx", wantVer: "1:1.20.1-14.el9_2.1", wantFound: true},
+		{name: "python3.11", wantVer: "3.11.5-1.el9_3", wantFound: true},
+		{name: "python3", wantFound: false},
+	}
+
+	for _, tt := range tests {
+		ver, found := parseCheckUpdate(output, tt.name)
+		if ver != tt.wantVer || found != tt.wantFound {
+			t.Errorf("parseCheckUpdate(%q) = (%q, %v), want (%q, %v)", tt.name, ver, found, tt.wantVer, tt.wantFound)
+		}
+	}
+}
+
+func TestUpgradeAction(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     string
+	}{
+		{from: "1.18.0-1", to: "1.18.0-2", want: "upgrade nginx from 1.18.0-1 to 1.18.0-2"},
+		{from: "1.18.0-1", want: "upgrade nginx from 1.18.0-1"},
+	}
+
+	for _, tt := range tests {
+		if got := upgradeAction("nginx", tt.from, tt.to); got != tt.want {
+			t.Errorf("upgradeAction(%q, %q) = %q, want %q", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package apply
+
+import "sync"
+
+// DefaultCheckConcurrency caps how many checks run at once when collecting
+// status or metrics. Each check usually spawns a subprocess (systemctl,
+// sysctl, dpkg-query), so an unbounded fan-out on a node with hundreds of
+// resources would fork them all at once.
+const DefaultCheckConcurrency = 8
+
+// CheckEach calls check(i) for every i in [0, n), running at most limit calls
+// at once, and returns when all of them are done. Callers store the result
+// for i at index i of a slice sized n, so output order matches input order
+// however the calls interleave. A limit below 1 runs the checks serially.
+func CheckEach(n, limit int, check func(i int)) {
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > n {
+		limit = n
+	}
+
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for w := 0; w < limit; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				check(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
@@ -0,0 +1,72 @@
+package apply
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckEach(t *testing.T) {
+	for _, limit := range []int{0, 1, 4, 100} {
+		var running, peak atomic.Int32
+		results := make([]int, 50)
+
+		CheckEach(len(results), limit, func(i int) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			results[i] = i * i
+			running.Add(-1)
+		})
+
+		for i, got := range results {
+			if got != i*i {
+				t.Fatalf("limit %d: results[%d] = %d, want %d", limit, i, got, i*i)
+			}
+		}
+		want := int32(max(limit, 1))
+		if want > int32(len(results)) {
+			want = int32(len(results))
+		}
+		if p := peak.Load(); p > want {
+			t.Errorf("limit %d: %d checks ran at once", limit, p)
+		}
+	}
+}
+
+func TestCheckEach_Empty(t *testing.T) {
+	CheckEach(0, DefaultCheckConcurrency, func(i int) {
+		t.Errorf("check(%d) called with n = 0", i)
+	})
+}
+
+// benchmarkServiceChecks checks 50 services, each a subprocess taking a few
+// milliseconds like `systemctl show` does
+func benchmarkServiceChecks(b *testing.B, limit int) {
+	const services = 50
+	ctx := context.Background()
+	for n := 0; n < b.N; n++ {
+		states := make([]string, services)
+		CheckEach(services, limit, func(i int) {
+			output, err := runOutput(ctx, "sh", "-c", "sleep 0.005; echo active")
+			if err != nil {
+				b.Fatal(err)
+			}
+			states[i] = string(output)
+		})
+	}
+}
+
+func BenchmarkCheckEach_50Services_Serial(b *testing.B) {
+	benchmarkServiceChecks(b, 1)
+}
+
+func BenchmarkCheckEach_50Services_Parallel(b *testing.B) {
+	benchmarkServiceChecks(b, DefaultCheckConcurrency)
+}
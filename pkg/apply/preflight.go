@@ -0,0 +1,126 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// The Preflight methods check that applying a resource can succeed, without
+// changing anything: the tools it needs are present and the names it refers
+// to exist. A nil error means no problem was found, not that applying is
+// guaranteed to work.
+
+// Preflight checks that the package can be installed: a package manager is
+// present and the package, at the pinned version if any, is in the index.
+// Removals need nothing beyond the package manager.
+func (a *PackageApplier) Preflight(ctx context.Context, pkg config.PackageConfig) error {
+	if a.packageManager == "" {
+		return ErrNoPackageManager
+	}
+	if pkg.State == config.PackageStateAbsent {
+		return nil
+	}
+
+	var args []string
+	spec := pkg.Name
+	switch a.packageManager {
+	case "apt":
+		if pkg.Version != "" {
+			spec += "=" + pkg.Version
+		}
+		args = []string{"apt-cache", "show", spec}
+	case "yum", "dnf":
+		if pkg.Version != "" {
+			spec += "-" + pkg.Version
+		}
+		args = []string{a.packageManager, "-q", "list", "--showduplicates", spec}
+	default:
+		return fmt.Errorf("unsupported package manager: %s", a.packageManager)
+	}
+
+	output, err := runCombined(ctx, args[0], args[1:]...)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return fmt.Errorf("failed to search the package index: %w", err)
+		}
+		if pkg.Version != "" {
+			return fmt.Errorf("package %s version %s not found in the package index", pkg.Name, pkg.Version)
+		}
+		return fmt.Errorf("package %s not found in the package index", pkg.Name)
+	}
+	// apt-cache show succeeds for purely virtual packages, printing nothing
+	if strings.TrimSpace(string(output)) == "" {
+		return fmt.Errorf("package %s has no installation candidate", pkg.Name)
+	}
+	return nil
+}
+
+// Preflight checks that systemd is running and knows a unit called name
+func (a *ServiceApplier) Preflight(ctx context.Context, name string) error {
+	if !a.systemd {
+		return ErrSystemdUnavailable
+	}
+	props, err := a.showUnit(ctx, name, "LoadState")
+	if err != nil {
+		return fmt.Errorf("failed to look up unit: %w", err)
+	}
+	switch state := props["LoadState"]; state {
+	case "not-found":
+		return fmt.Errorf("unit %s not found", name)
+	case "error", "bad-setting":
+		return fmt.Errorf("unit %s failed to load (%s)", name, state)
+	}
+	return nil
+}
+
+// Preflight checks that the file can be written where the state puts it:
+// the closest existing ancestor of its directory is a directory this process
+// can write to, and its owner and group exist
+func (a *FileApplier) Preflight(file config.FileConfig) error {
+	dir := filepath.Dir(string(file.Path))
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		if !os.IsNotExist(err) && !errors.Is(err, syscall.ENOTDIR) {
+			return fmt.Errorf("failed to check parent directory: %w", err)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	if err := checkWritable(dir); err != nil {
+		return fmt.Errorf("directory %s is not writable: %w", dir, err)
+	}
+
+	if file.State == config.FileStateAbsent {
+		return nil
+	}
+	if file.Owner != "" {
+		if _, err := user.Lookup(file.Owner); err != nil {
+			return fmt.Errorf("failed to look up owner: %w", err)
+		}
+	}
+	if file.Group != "" {
+		if _, err := user.LookupGroup(file.Group); err != nil {
+			return fmt.Errorf("failed to look up group: %w", err)
+		}
+	}
+	return nil
+}
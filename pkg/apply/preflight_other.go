@@ -0,0 +1,9 @@
+//go:build !unix
+
+package apply
+
+// checkWritable can't be told without writing where access(2) doesn't exist,
+// so dir is assumed writable
+func checkWritable(dir string) error {
+	return nil
+}
@@ -0,0 +1,87 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestFileApplier_Preflight(t *testing.T) {
+	dir := t.TempDir()
+	notDir := filepath.Join(dir, "plain")
+	if err := os.WriteFile(notDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewFileApplier()
+	tests := []struct {
+		name    string
+		file    config.FileConfig
+		wantErr string
+	}{
+		{"existing directory", config.FileConfig{Path: config.UnixPath(filepath.Join(dir, "a.conf"))}, ""},
+		{"missing parents", config.FileConfig{Path: config.UnixPath(filepath.Join(dir, "x", "y", "a.conf"))}, ""},
+		{"parent is a file", config.FileConfig{Path: config.UnixPath(filepath.Join(notDir, "x", "a.conf"))}, notDir + " is not a directory"},
+		{"unknown owner", config.FileConfig{Path: config.UnixPath(filepath.Join(dir, "a.conf")), Owner: "no-such-user-pe"}, "failed to look up owner"},
+		{"unknown group", config.FileConfig{Path: config.UnixPath(filepath.Join(dir, "a.conf")), Group: "no-such-group-pe"}, "failed to look up group"},
+		{"absent ignores owner", config.FileConfig{Path: config.UnixPath(filepath.Join(dir, "a.conf")), Owner: "no-such-user-pe", State: config.FileStateAbsent}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.Preflight(tt.file)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Preflight() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Preflight() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPackageApplier_Preflight(t *testing.T) {
+	ctx := context.Background()
+
+	// apt-cache knows nginx at any version and curl only as a virtual package
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncase \"$2\" in\nnginx|nginx=1.24*) echo Package: nginx ;;\ncurl) ;;\n*) echo 'E: No packages found' >&2; exit 100 ;;\nesac\n"
+	if err := os.WriteFile(filepath.Join(dir, "apt-cache"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	a := &PackageApplier{packageManager: "apt"}
+	tests := []struct {
+		name    string
+		pkg     config.PackageConfig
+		wantErr string
+	}{
+		{"resolves", config.PackageConfig{Name: "nginx"}, ""},
+		{"pinned version resolves", config.PackageConfig{Name: "nginx", Version: "1.24.0"}, ""},
+		{"typo", config.PackageConfig{Name: "ngnix"}, "package ngnix not found in the package index"},
+		{"unknown version", config.PackageConfig{Name: "nginx", Version: "9.9"}, "version 9.9 not found"},
+		{"virtual", config.PackageConfig{Name: "curl"}, "no installation candidate"},
+		{"removal", config.PackageConfig{Name: "ngnix", State: config.PackageStateAbsent}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.Preflight(ctx, tt.pkg)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Preflight() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Preflight() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	a = &PackageApplier{}
+	if err := a.Preflight(ctx, config.PackageConfig{Name: "nginx"}); !errors.Is(err, ErrNoPackageManager) {
+		t.Errorf("Preflight() without a package manager error = %v, want ErrNoPackageManager", err)
+	}
+}
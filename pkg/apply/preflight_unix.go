@@ -0,0 +1,12 @@
+//go:build unix
+
+package apply
+
+import "syscall"
+
+// checkWritable reports whether this process may create files in dir,
+// without creating any
+func checkWritable(dir string) error {
+	const wOK = 0x2 // W_OK in unistd.h
+	return syscall.Access(dir, wOK)
+}
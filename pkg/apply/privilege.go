@@ -0,0 +1,72 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultPrivilegeCommand is what system-changing commands run through
+// unless configured otherwise
+var DefaultPrivilegeCommand = []string{"sudo"}
+
+// ErrInsufficientPrivileges is returned (wrapped) when a system-changing
+// command can't be run with the privileges it needs
+var ErrInsufficientPrivileges = errors.New("insufficient privileges, run as root or enable -sudo")
+
+// privilegeCheckTimeout bounds the check that the privilege command works
+const privilegeCheckTimeout = 10 * time.Second
+
+type privilegeCommandKey struct{}
+
+// WithPrivilegeCommand returns a context under which the appliers run
+// system-changing commands through command, e.g. ["sudo", "-n"] or
+// ["doas"]. An empty command runs them directly, which needs root.
+func WithPrivilegeCommand(ctx context.Context, command []string) context.Context {
+	return context.WithValue(ctx, privilegeCommandKey{}, command)
+}
+
+// PrivilegeCommand returns the privilege command in effect for ctx
+func PrivilegeCommand(ctx context.Context) []string {
+	if command, ok := ctx.Value(privilegeCommandKey{}).([]string); ok {
+		return command
+	}
+	return DefaultPrivilegeCommand
+}
+
+// CheckPrivileges reports whether system-changing commands can run under
+// ctx: either the agent is root and runs them directly, or the privilege
+// command works without asking for a password.
+func CheckPrivileges(ctx context.Context) error {
+	command := PrivilegeCommand(ctx)
+	if len(command) == 0 {
+		if os.Geteuid() != 0 {
+			return fmt.Errorf("%w (running as uid %d without -sudo)", ErrInsufficientPrivileges, os.Geteuid())
+		}
+		return nil
+	}
+
+	args := append([]string{}, command[1:]...)
+	if filepath.Base(command[0]) == "sudo" {
+		// Fail instead of prompting for a password nobody will type
+		args = append(args, "-n")
+	}
+	args = append(args, "true")
+
+	checkCtx, cancel := context.WithTimeout(ctx, privilegeCheckTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(checkCtx, command[0], args...).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	line := strings.Join(command, " ")
+	if os.Geteuid() == 0 {
+		return fmt.Errorf("privilege command %q doesn't work: %v (output: %s); as root, -sudo= runs commands directly", line, err, strings.TrimSpace(string(output)))
+	}
+	return fmt.Errorf("%w: %q doesn't work: %v (output: %s)", ErrInsufficientPrivileges, line, err, strings.TrimSpace(string(output)))
+}
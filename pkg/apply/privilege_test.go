@@ -0,0 +1,53 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPrivilegeCommand(t *testing.T) {
+	ctx := context.Background()
+	if got := PrivilegeCommand(ctx); len(got) != 1 || got[0] != "sudo" {
+		t.Errorf("PrivilegeCommand() = %v, want sudo by default", got)
+	}
+	if got := PrivilegeCommand(WithPrivilegeCommand(ctx, nil)); len(got) != 0 {
+		t.Errorf("PrivilegeCommand() = %v, want none once cleared", got)
+	}
+}
+
+func TestRunCommand_PrivilegeCommand(t *testing.T) {
+	// "sudo" stands for the configured privilege command
+	ctx := WithPrivilegeCommand(context.Background(), []string{"env", "PE_ESCALATED=1"})
+	output, err := runOutput(ctx, "sudo", "sh", "-c", "echo $PE_ESCALATED")
+	if err != nil {
+		t.Fatalf("runOutput() error = %v", err)
+	}
+	if got := strings.TrimSpace(string(output)); got != "1" {
+		t.Errorf("command ran without the privilege command, got %q", got)
+	}
+
+	// Without one, commands run directly
+	output, err = runOutput(WithPrivilegeCommand(context.Background(), nil), "sudo", "echo", "direct")
+	if os.Geteuid() != 0 {
+		if !errors.Is(err, ErrInsufficientPrivileges) {
+			t.Errorf("runOutput() error = %v, want insufficient privileges when not root", err)
+		}
+		return
+	}
+	if err != nil || strings.TrimSpace(string(output)) != "direct" {
+		t.Errorf("runOutput() = %q, %v; want it run directly as root", output, err)
+	}
+}
+
+func TestCheckPrivileges(t *testing.T) {
+	if err := CheckPrivileges(WithPrivilegeCommand(context.Background(), []string{"env"})); err != nil {
+		t.Errorf("CheckPrivileges() with a working command = %v", err)
+	}
+	err := CheckPrivileges(WithPrivilegeCommand(context.Background(), []string{"false"}))
+	if err == nil || !strings.Contains(err.Error(), `"false" doesn't work`) {
+		t.Errorf("CheckPrivileges() with a failing command = %v, want it named", err)
+	}
+}
@@ -0,0 +1,278 @@
+package apply
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+const repoFileHeader = "# Managed by power-edge; local changes will be overwritten\n"
+
+// RepositoryApplier is the single source of truth for applying package
+// repositories: an apt deb822 .sources file or a dnf/yum .repo file, plus the
+// repository's signing key
+type RepositoryApplier struct {
+	packageManager string // "apt", "yum", "dnf"
+	aptSourcesDir  string
+	aptKeyringDir  string
+	yumReposDir    string
+	rpmKeyDir      string
+	files          *FileApplier // Fetches keys from key_url
+}
+
+// repoFile is one file a repository is configured with
+type repoFile struct {
+	path    string
+	content string
+	key     bool // Signing keys are summarized rather than diffed
+}
+
+// NewRepositoryApplier creates a new repository applier (auto-detects package manager)
+func NewRepositoryApplier() *RepositoryApplier {
+	return &RepositoryApplier{
+		packageManager: detectPackageManager(),
+		aptSourcesDir:  "/etc/apt/sources.list.d",
+		aptKeyringDir:  "/etc/apt/keyrings",
+		yumReposDir:    "/etc/yum.repos.d",
+		rpmKeyDir:      "/etc/pki/rpm-gpg",
+		files:          NewFileApplier(),
+	}
+}
+
+// ApplyAll ensures every repository's source file and signing key are in
+// place. The package index is refreshed once, and only if some repository
+// changed; a failed refresh is reported on every repository that changed.
+func (a *RepositoryApplier) ApplyAll(ctx context.Context, repos []config.RepoConfig, dryRun bool) map[string]ApplyResult {
+	results := make(map[string]ApplyResult, len(repos))
+
+	var changed []string
+	for _, repo := range repos {
+		result := a.apply(ctx, repo, dryRun)
+		if result.Changed && result.Error == nil {
+			changed = append(changed, repo.Name)
+		}
+		results[repo.Name] = result
+	}
+	if len(changed) == 0 {
+		return results
+	}
+
+	action, args := a.refreshCommand()
+	for _, name := range changed {
+		result := results[name]
+		result.Actions = append(result.Actions, action)
+		results[name] = result
+	}
+	if dryRun {
+		return results
+	}
+
+	if output, err := runCombined(ctx, "sudo", args...); err != nil {
+		err = fmt.Errorf("%s failed: %s (output: %s)", action, err, string(output))
+		for _, name := range changed {
+			result := results[name]
+			result.Error = err
+			results[name] = result
+		}
+	}
+	return results
+}
+
+// apply writes the files of one repository that differ from the desired ones
+func (a *RepositoryApplier) apply(ctx context.Context, repo config.RepoConfig, dryRun bool) ApplyResult {
+	result := ApplyResult{
+		Actions: []string{},
+	}
+
+	files, err := a.desiredFiles(ctx, repo)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+
+	var secrets []string
+	if repo.Interpolate {
+		for i, f := range files {
+			// A key fetched from key_url is used as served
+			if f.key && repo.KeyURL != "" {
+				continue
+			}
+			resolved, values, err := config.Interpolate(f.content)
+			if err != nil {
+				result.Error = fmt.Errorf("repository %s: %w", repo.Name, err)
+				return result
+			}
+			files[i].content = resolved
+			secrets = append(secrets, values...)
+		}
+	}
+
+	mode, err := repoFileMode(repo)
+	if err != nil {
+		result.Error = fmt.Errorf("repository %s: %w", repo.Name, err)
+		return result
+	}
+
+	var diffs []string
+	for _, f := range files {
+		current, err := readPrivileged(ctx, f.path)
+		exists := err == nil
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			result.Error = fmt.Errorf("failed to read %s: %w", f.path, err)
+			return result
+		}
+		if exists && string(current) == f.content && hasMode(f.path, mode) {
+			continue
+		}
+
+		result.Changed = true
+		result.Actions = append(result.Actions, fmt.Sprintf("write %s", f.path))
+		if dryRun {
+			diffs = append(diffs, contentDiff(f.path, exists, f.content, f.key))
+			continue
+		}
+
+		// Under /etc, like the cache refresh, so through the privilege command
+		if err := mkdirPrivileged(ctx, filepath.Dir(f.path), defaultDirMode); err != nil {
+			result.Error = err
+			return result
+		}
+		if err := writePrivileged(ctx, f.path, f.content, mode); err != nil {
+			result.Error = fmt.Errorf("failed to write %s: %w", f.path, err)
+			return result
+		}
+	}
+	result.Diff = redact(strings.Join(diffs, "\n"), secrets)
+
+	return result
+}
+
+// repoFileMode returns the mode of a repository's files: the configured one,
+// or 0600 when interpolated credentials may end up in them
+func repoFileMode(repo config.RepoConfig) (string, error) {
+	switch {
+	case repo.Mode != "":
+		return NormalizeMode(repo.Mode)
+	case repo.Interpolate:
+		return "0600", nil
+	default:
+		return formatMode(defaultFileMode), nil
+	}
+}
+
+// hasMode reports whether path has mode, as formatted by formatMode
+func hasMode(path, mode string) bool {
+	info, err := os.Stat(path)
+	return err == nil && formatMode(info.Mode()) == mode
+}
+
+// desiredFiles renders the files repo is configured with for the detected
+// package manager, the signing key first
+func (a *RepositoryApplier) desiredFiles(ctx context.Context, repo config.RepoConfig) ([]repoFile, error) {
+	var keyPath, sourcePath string
+	switch a.packageManager {
+	case "apt":
+		if repo.Suite == "" {
+			return nil, fmt.Errorf("repository %s: suite is required for apt", repo.Name)
+		}
+		keyPath = filepath.Join(a.aptKeyringDir, repo.Name+".asc")
+		sourcePath = filepath.Join(a.aptSourcesDir, repo.Name+".sources")
+	case "yum", "dnf":
+		keyPath = filepath.Join(a.rpmKeyDir, "RPM-GPG-KEY-"+repo.Name)
+		sourcePath = filepath.Join(a.yumReposDir, repo.Name+".repo")
+	case "":
+		return nil, ErrNoPackageManager
+	default:
+		return nil, fmt.Errorf("unsupported package manager: %s", a.packageManager)
+	}
+
+	var files []repoFile
+	key, err := a.signingKey(ctx, repo, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		keyPath = ""
+	} else {
+		files = append(files, repoFile{path: keyPath, content: key, key: true})
+	}
+
+	source := renderRepoFile(a.packageManager, repo, keyPath)
+	return append(files, repoFile{path: sourcePath, content: source}), nil
+}
+
+// signingKey returns the armored key to install at path, or "" for an
+// unsigned repository. A key_url is only fetched while the installed key is
+// missing or doesn't match key_sha256, not on every pass.
+func (a *RepositoryApplier) signingKey(ctx context.Context, repo config.RepoConfig, path string) (string, error) {
+	switch {
+	case repo.Key != "":
+		if !strings.HasSuffix(repo.Key, "\n") {
+			return repo.Key + "\n", nil
+		}
+		return repo.Key, nil
+
+	case repo.KeyURL != "":
+		if current, err := readPrivileged(ctx, path); err == nil {
+			if repo.KeySHA256 == "" || fmt.Sprintf("%x", sha256.Sum256(current)) == repo.KeySHA256 {
+				return string(current), nil
+			}
+		}
+		key, err := a.files.fetchSource(ctx, config.FileConfig{Source: repo.KeyURL, SHA256: repo.KeySHA256})
+		if err != nil {
+			return "", fmt.Errorf("repository %s: %w", repo.Name, err)
+		}
+		return key, nil
+	}
+	return "", nil
+}
+
+// renderRepoFile renders the apt deb822 source or dnf/yum .repo definition of
+// repo, referencing the key installed at keyPath ("" when unsigned)
+func renderRepoFile(packageManager string, repo config.RepoConfig, keyPath string) string {
+	var b strings.Builder
+	b.WriteString(repoFileHeader)
+
+	if packageManager == "apt" {
+		b.WriteString("Types: deb\n")
+		fmt.Fprintf(&b, "URIs: %s\n", repo.URL)
+		fmt.Fprintf(&b, "Suites: %s\n", repo.Suite)
+		if len(repo.Components) > 0 {
+			fmt.Fprintf(&b, "Components: %s\n", strings.Join(repo.Components, " "))
+		}
+		if keyPath != "" {
+			fmt.Fprintf(&b, "Signed-By: %s\n", keyPath)
+		}
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "[%s]\n", repo.Name)
+	fmt.Fprintf(&b, "name=%s\n", repo.Name)
+	fmt.Fprintf(&b, "baseurl=%s\n", repo.URL)
+	b.WriteString("enabled=1\n")
+	if keyPath != "" {
+		b.WriteString("gpgcheck=1\n")
+		fmt.Fprintf(&b, "gpgkey=file://%s\n", keyPath)
+	} else {
+		b.WriteString("gpgcheck=0\n")
+	}
+	return b.String()
+}
+
+// refreshCommand returns the command that reloads the package index
+func (a *RepositoryApplier) refreshCommand() (action string, args []string) {
+	switch a.packageManager {
+	case "apt":
+		args = []string{"apt-get", "update"}
+	default:
+		args = []string{a.packageManager, "makecache"}
+	}
+	return strings.Join(args, " "), args
+}
@@ -1,19 +1,40 @@
 package apply
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	"github.com/power-edge/power-edge/pkg/config"
 )
 
+// ErrSystemdUnavailable is returned when systemd isn't running on this host,
+// e.g. in a container, so services can't be managed at all
+var ErrSystemdUnavailable = errors.New("systemd is not running on this host")
+
 // ServiceApplier is the single source of truth for applying service state
-type ServiceApplier struct{}
+type ServiceApplier struct {
+	unitDir string // Where administrators' units live; only these may be removed by default
+	systemd bool   // Whether systemd is the running init system
+}
 
 // NewServiceApplier creates a new service applier
 func NewServiceApplier() *ServiceApplier {
-	return &ServiceApplier{}
+	return &ServiceApplier{
+		unitDir: "/etc/systemd/system",
+		systemd: systemdRunning(),
+	}
+}
+
+// systemdRunning reports whether systemd is the init system, the same check
+// sd_booted(3) makes
+func systemdRunning() bool {
+	info, err := os.Stat("/run/systemd/system")
+	return err == nil && info.IsDir()
 }
 
 // ApplyResult contains the outcome of applying state
@@ -21,23 +42,39 @@ type ApplyResult struct {
 	Changed bool
 	Actions []string
 	Error   error
+	// Skipped is set when the resource can't be managed on this host and
+	// policy says to pass over it rather than fail
+	Skipped bool
+	// Diff shows the planned content change of a file in dry-run: a unified
+	// diff, or a summary for binary or checksum-only content
+	Diff string
+	// Notes are worth logging without being changes or errors, e.g. that
+	// content came from the source cache
+	Notes []string
 }
 
 // Apply ensures a service matches its desired state
 // This is the ONLY place that knows HOW to apply service state
-func (a *ServiceApplier) Apply(svc config.ServiceConfig, dryRun bool) ApplyResult {
+func (a *ServiceApplier) Apply(ctx context.Context, svc config.ServiceConfig, dryRun bool) ApplyResult {
+	if !a.systemd {
+		return ApplyResult{Actions: []string{}, Error: ErrSystemdUnavailable}
+	}
+	if svc.State == config.ServiceStateAbsent {
+		return a.applyAbsent(ctx, svc, dryRun)
+	}
+
 	result := ApplyResult{
 		Actions: []string{},
 	}
 
 	// Check current state
-	isActive, err := a.isServiceActive(svc.Name)
+	isActive, err := a.isServiceActive(ctx, svc.Name)
 	if err != nil {
 		result.Error = fmt.Errorf("failed to check service status: %w", err)
 		return result
 	}
 
-	isEnabled, err := a.isServiceEnabled(svc.Name)
+	isEnabled, err := a.isServiceEnabled(ctx, svc.Name)
 	if err != nil {
 		result.Error = fmt.Errorf("failed to check service enabled status: %w", err)
 		return result
@@ -81,7 +118,7 @@ func (a *ServiceApplier) Apply(svc config.ServiceConfig, dryRun bool) ApplyResul
 
 	// Apply changes
 	for _, action := range actions {
-		if err := a.executeSystemctl(action, svc.Name); err != nil {
+		if err := a.executeSystemctl(ctx, action, svc.Name); err != nil {
 			result.Error = fmt.Errorf("failed to %s service: %w", action, err)
 			return result
 		}
@@ -90,14 +127,138 @@ func (a *ServiceApplier) Apply(svc config.ServiceConfig, dryRun bool) ApplyResul
 	return result
 }
 
+// applyAbsent stops and disables a unit, removes its unit file and reloads
+// systemd, so decommissioned software leaves nothing behind. Actions are full
+// commands since they aren't all systemctl verbs.
+func (a *ServiceApplier) applyAbsent(ctx context.Context, svc config.ServiceConfig, dryRun bool) ApplyResult {
+	result := ApplyResult{
+		Actions: []string{},
+	}
+
+	props, err := a.showUnit(ctx, svc.Name, "LoadState", "FragmentPath")
+	if err != nil {
+		result.Error = fmt.Errorf("failed to check unit file: %w", err)
+		return result
+	}
+
+	// A masked unit can't be started, and removing the mask would expose
+	// the unit underneath it, so its file is left alone
+	unitPath := props["FragmentPath"]
+	if props["LoadState"] == "masked" {
+		unitPath = ""
+	}
+
+	unitExists := false
+	if unitPath != "" {
+		if _, err := os.Lstat(unitPath); err == nil {
+			unitExists = true
+		} else if !os.IsNotExist(err) {
+			result.Error = fmt.Errorf("failed to stat unit file: %w", err)
+			return result
+		}
+		// Check before stopping anything, so a refused unit is left as it is
+		if unitExists && !svc.AllowVendorUnit {
+			if err := checkRemovableUnit(unitPath, a.unitDir); err != nil {
+				result.Error = err
+				return result
+			}
+		}
+	}
+
+	isActive, err := a.isServiceActive(ctx, svc.Name)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to check service status: %w", err)
+		return result
+	}
+	isEnabled, err := a.isServiceEnabled(ctx, svc.Name)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to check service enabled status: %w", err)
+		return result
+	}
+
+	var commands [][]string
+	if isActive {
+		commands = append(commands, []string{"systemctl", "stop", svc.Name})
+	}
+	if isEnabled {
+		commands = append(commands, []string{"systemctl", "disable", svc.Name})
+	}
+	if unitExists {
+		commands = append(commands, []string{"rm", "-f", unitPath})
+	}
+	// systemd still knows a unit whose file is gone until it reloads
+	if len(commands) > 0 || (unitPath != "" && props["LoadState"] != "not-found") {
+		commands = append(commands, []string{"systemctl", "daemon-reload"})
+	}
+
+	if len(commands) == 0 {
+		return result
+	}
+
+	result.Changed = true
+	for _, args := range commands {
+		result.Actions = append(result.Actions, strings.Join(args, " "))
+	}
+	if dryRun {
+		return result
+	}
+
+	for _, args := range commands {
+		output, err := runCombined(ctx, "sudo", args...)
+		if err != nil {
+			result.Error = fmt.Errorf("%s failed: %s (output: %s)", strings.Join(args, " "), err, string(output))
+			return result
+		}
+	}
+
+	return result
+}
+
+// checkRemovableUnit refuses to remove a unit file outside unitDir, such as
+// one shipped by a distro package under /usr/lib/systemd/system
+func checkRemovableUnit(path, unitDir string) error {
+	rel, err := filepath.Rel(unitDir, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("refusing to remove %s: not under %s (set allow_vendor_unit to remove it anyway)", path, unitDir)
+	}
+	return nil
+}
+
+// showUnit reads unit properties with systemctl show
+func (a *ServiceApplier) showUnit(ctx context.Context, name string, properties ...string) (map[string]string, error) {
+	args := []string{"show", name}
+	for _, p := range properties {
+		args = append(args, "-p", p)
+	}
+	output, err := runOutput(ctx, "systemctl", args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseUnitProperties(string(output)), nil
+}
+
+// parseUnitProperties parses the Key=value lines of systemctl show
+func parseUnitProperties(output string) map[string]string {
+	props := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			props[key] = value
+		}
+	}
+	return props
+}
+
 // Check returns the current state of a service
-func (a *ServiceApplier) Check(name string) (isActive, isEnabled bool, err error) {
-	isActive, err = a.isServiceActive(name)
+func (a *ServiceApplier) Check(ctx context.Context, name string) (isActive, isEnabled bool, err error) {
+	if !a.systemd {
+		return false, false, ErrSystemdUnavailable
+	}
+	isActive, err = a.isServiceActive(ctx, name)
 	if err != nil {
 		return false, false, err
 	}
 
-	isEnabled, err = a.isServiceEnabled(name)
+	isEnabled, err = a.isServiceEnabled(ctx, name)
 	if err != nil {
 		return false, false, err
 	}
@@ -105,9 +266,8 @@ func (a *ServiceApplier) Check(name string) (isActive, isEnabled bool, err error
 	return isActive, isEnabled, nil
 }
 
-func (a *ServiceApplier) isServiceActive(name string) (bool, error) {
-	cmd := exec.Command("systemctl", "is-active", name)
-	output, err := cmd.Output()
+func (a *ServiceApplier) isServiceActive(ctx context.Context, name string) (bool, error) {
+	output, err := runOutput(ctx, "systemctl", "is-active", name)
 	status := strings.TrimSpace(string(output))
 
 	// systemctl is-active returns exit code 3 if inactive (not an error for us)
@@ -121,9 +281,8 @@ func (a *ServiceApplier) isServiceActive(name string) (bool, error) {
 	return status == "active", nil
 }
 
-func (a *ServiceApplier) isServiceEnabled(name string) (bool, error) {
-	cmd := exec.Command("systemctl", "is-enabled", name)
-	output, err := cmd.Output()
+func (a *ServiceApplier) isServiceEnabled(ctx context.Context, name string) (bool, error) {
+	output, err := runOutput(ctx, "systemctl", "is-enabled", name)
 	status := strings.TrimSpace(string(output))
 
 	// systemctl is-enabled returns exit code 1 if disabled
@@ -137,9 +296,25 @@ func (a *ServiceApplier) isServiceEnabled(name string) (bool, error) {
 	return status == "enabled", nil
 }
 
-func (a *ServiceApplier) executeSystemctl(action, serviceName string) error {
-	cmd := exec.Command("sudo", "systemctl", action, serviceName)
-	output, err := cmd.CombinedOutput()
+// Restart restarts a running service, e.g. to pick up changed configuration.
+// It uses try-restart so a service that is meant to be stopped stays
+// stopped.
+func (a *ServiceApplier) Restart(ctx context.Context, name string, dryRun bool) ApplyResult {
+	if !a.systemd {
+		return ApplyResult{Actions: []string{}, Error: ErrSystemdUnavailable}
+	}
+	result := ApplyResult{Changed: true, Actions: []string{"try-restart"}}
+	if dryRun {
+		return result
+	}
+	if err := a.executeSystemctl(ctx, "try-restart", name); err != nil {
+		result.Error = fmt.Errorf("failed to restart service: %w", err)
+	}
+	return result
+}
+
+func (a *ServiceApplier) executeSystemctl(ctx context.Context, action, serviceName string) error {
+	output, err := runCombined(ctx, "sudo", "systemctl", action, serviceName)
 	if err != nil {
 		return fmt.Errorf("%s (output: %s)", err, string(output))
 	}
@@ -1,6 +1,8 @@
 package apply
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/power-edge/power-edge/pkg/config"
@@ -38,7 +40,10 @@ func TestServiceApplier_Apply(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := NewServiceApplier()
-			result := a.Apply(tt.svc, tt.dryRun)
+			if !a.systemd {
+				t.Skip("systemd is not running")
+			}
+			result := a.Apply(context.Background(), tt.svc, tt.dryRun)
 
 			if (result.Error != nil) != tt.wantErr {
 				t.Errorf("Apply() error = %v, wantErr %v", result.Error, tt.wantErr)
@@ -51,11 +56,24 @@ func TestServiceApplier_Apply(t *testing.T) {
 	}
 }
 
+func TestServiceApplier_NoSystemd(t *testing.T) {
+	a := &ServiceApplier{unitDir: "/etc/systemd/system"}
+	svc := config.ServiceConfig{Name: "nginx", State: config.ServiceStateRunning, Enabled: true}
+
+	result := a.Apply(context.Background(), svc, false)
+	if !errors.Is(result.Error, ErrSystemdUnavailable) || result.Changed {
+		t.Errorf("Apply() = %+v, want ErrSystemdUnavailable without changes", result)
+	}
+	if _, _, err := a.Check(context.Background(), "nginx"); !errors.Is(err, ErrSystemdUnavailable) {
+		t.Errorf("Check() error = %v, want ErrSystemdUnavailable", err)
+	}
+}
+
 func TestServiceApplier_Check(t *testing.T) {
 	a := NewServiceApplier()
 
 	// Test checking a service that likely doesn't exist
-	_, _, err := a.Check("nonexistent-test-service-12345")
+	_, _, err := a.Check(context.Background(), "nonexistent-test-service-12345")
 
 	// We expect either no error (service not found) or a specific error
 	// This is mainly to ensure the Check function doesn't panic
@@ -83,3 +101,36 @@ func TestApplyResult_Structure(t *testing.T) {
 		t.Errorf("Expected no error, got %v", result.Error)
 	}
 }
+
+func TestCheckRemovableUnit(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{path: "/etc/systemd/system/legacy-agent.service", wantErr: false},
+		{path: "/etc/systemd/system/legacy-agent.service.d/override.conf", wantErr: false},
+		{path: "/lib/systemd/system/nginx.service", wantErr: true},
+		{path: "/usr/lib/systemd/system/sshd.service", wantErr: true},
+		{path: "/etc/systemd/system-generators/foo", wantErr: true},
+		{path: "/etc/systemd/system", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := checkRemovableUnit(tt.path, "/etc/systemd/system")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkRemovableUnit(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseUnitProperties(t *testing.T) {
+	props := parseUnitProperties("LoadState=loaded\nFragmentPath=/etc/systemd/system/legacy-agent.service\n")
+	if props["LoadState"] != "loaded" || props["FragmentPath"] != "/etc/systemd/system/legacy-agent.service" {
+		t.Errorf("parseUnitProperties() = %v", props)
+	}
+
+	props = parseUnitProperties("LoadState=not-found\nFragmentPath=\n")
+	if props["LoadState"] != "not-found" || props["FragmentPath"] != "" {
+		t.Errorf("parseUnitProperties() = %v", props)
+	}
+}
@@ -1,41 +1,72 @@
 package apply
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os/exec"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+
+	"github.com/power-edge/power-edge/pkg/config"
 )
 
+// ErrSysctlKeyUnknown is returned (wrapped) when a sysctl key does not exist
+// on the running kernel and policy says to fail rather than skip it
+var ErrSysctlKeyUnknown = errors.New("does not exist on this kernel")
+
+// SysctlConfFile is the sysctl.d drop-in power-edge owns. It holds every
+// managed parameter so values survive a reboot, and is rewritten as a whole.
+const SysctlConfFile = "/etc/sysctl.d/99-power-edge.conf"
+
 // SysctlApplier is the single source of truth for applying sysctl parameters
-type SysctlApplier struct{}
+type SysctlApplier struct {
+	procSysDir string
+	confPath   string
+	policy     config.SysctlPolicy
+}
 
 // NewSysctlApplier creates a new sysctl applier
 func NewSysctlApplier() *SysctlApplier {
-	return &SysctlApplier{}
+	return &SysctlApplier{
+		procSysDir: "/proc/sys",
+		confPath:   SysctlConfFile,
+	}
 }
 
-// Apply ensures a sysctl parameter matches its desired value
+// SetPolicy sets how keys are compared and how keys missing from the running
+// kernel are handled
+func (a *SysctlApplier) SetPolicy(policy config.SysctlPolicy) {
+	a.policy = policy
+}
+
+// Apply ensures a sysctl parameter matches its desired value at runtime
 // This is the ONLY place that knows HOW to apply sysctl state
-func (a *SysctlApplier) Apply(key, desiredValue string, dryRun bool) ApplyResult {
+func (a *SysctlApplier) Apply(ctx context.Context, key, desiredValue string, dryRun bool) ApplyResult {
 	result := ApplyResult{
 		Actions: []string{},
 	}
 
-	// Get current value
-	actualValue, err := a.Get(key)
+	actualValue, available, err := a.current(ctx, key, dryRun, &result)
 	if err != nil {
-		result.Error = fmt.Errorf("failed to get sysctl value: %w", err)
+		result.Error = err
+		return result
+	}
+	if !available {
+		a.unavailable(key, &result)
 		return result
 	}
 
 	// Check if change needed
-	if actualValue == desiredValue {
-		result.Changed = false
+	if a.equal(key, desiredValue, actualValue) {
 		return result
 	}
 
 	result.Changed = true
-	result.Actions = []string{fmt.Sprintf("sysctl -w %s=%s", key, desiredValue)}
+	result.Actions = append(result.Actions, fmt.Sprintf("sysctl -w %s=%s", key, desiredValue))
 
 	// Dry-run mode: don't apply
 	if dryRun {
@@ -43,7 +74,7 @@ func (a *SysctlApplier) Apply(key, desiredValue string, dryRun bool) ApplyResult
 	}
 
 	// Apply change
-	if err := a.Set(key, desiredValue); err != nil {
+	if err := a.Set(ctx, key, desiredValue); err != nil {
 		result.Error = fmt.Errorf("failed to set sysctl value: %w", err)
 		return result
 	}
@@ -51,35 +82,269 @@ func (a *SysctlApplier) Apply(key, desiredValue string, dryRun bool) ApplyResult
 	return result
 }
 
-// Get retrieves the current value of a sysctl parameter
-func (a *SysctlApplier) Get(key string) (string, error) {
-	cmd := exec.Command("sysctl", "-n", key)
-	output, err := cmd.Output()
+// ApplyAll ensures every parameter in params matches its desired value and is
+// persisted in the managed drop-in. Rather than a `sysctl -w` per key, the
+// drop-in is rewritten once and loaded with a single `sysctl -p`. A key
+// drifts if either its runtime value or its persisted value is wrong; the
+// returned results are per key, and a failed write or load is reported on
+// every key that needed it.
+func (a *SysctlApplier) ApplyAll(ctx context.Context, params map[string]string, dryRun bool) map[string]ApplyResult {
+	results := make(map[string]ApplyResult, len(params))
+
+	persisted, err := readSysctlConf(a.confPath)
+	if err != nil {
+		for key := range params {
+			results[key] = ApplyResult{
+				Actions: []string{},
+				Error:   fmt.Errorf("failed to read %s: %w", a.confPath, err),
+			}
+		}
+		return results
+	}
+
+	managed := make(map[string]string, len(params))
+	var drifted []string
+	for key, desiredValue := range params {
+		result := ApplyResult{
+			Actions: []string{},
+		}
+
+		actualValue, available, err := a.current(ctx, key, dryRun, &result)
+		switch {
+		case err != nil:
+			// Keep it persisted; a failed read says nothing about the file
+			result.Error = err
+			managed[key] = desiredValue
+		case !available:
+			a.unavailable(key, &result)
+		default:
+			managed[key] = desiredValue
+			if !a.equal(key, desiredValue, actualValue) || !a.equal(key, desiredValue, persisted[key]) {
+				result.Changed = true
+				result.Actions = append(result.Actions, fmt.Sprintf("persist %s=%s in %s", key, desiredValue, a.confPath))
+				drifted = append(drifted, key)
+			}
+		}
+		results[key] = result
+	}
+
+	// Keys no longer managed are dropped from the file without a result
+	if dryRun || (len(drifted) == 0 && a.samePersisted(persisted, managed)) {
+		return results
+	}
+
+	if err := a.persist(ctx, managed); err != nil {
+		for _, key := range drifted {
+			result := results[key]
+			result.Error = err
+			results[key] = result
+		}
+	}
+	return results
+}
+
+// samePersisted reports whether the drop-in already holds exactly params
+func (a *SysctlApplier) samePersisted(persisted, params map[string]string) bool {
+	if len(persisted) != len(params) {
+		return false
+	}
+	for key, value := range params {
+		if v, ok := persisted[key]; !ok || !a.equal(key, value, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// equal compares a desired and an actual value of key, as unordered sets if
+// policy lists the key
+func (a *SysctlApplier) equal(key, desired, actual string) bool {
+	return SysctlValuesEqual(desired, actual, slices.Contains(a.policy.Unordered, key))
+}
+
+// NormalizeSysctlValue collapses runs of whitespace to single spaces. The
+// kernel prints list values such as net.ipv4.tcp_rmem tab-separated, while
+// state files usually separate them with spaces.
+func NormalizeSysctlValue(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// SysctlValuesEqual compares sysctl values regardless of whitespace. Unordered
+// values are compared as sets of fields, for list-valued keys whose order
+// doesn't matter such as net.ipv4.ip_local_reserved_ports.
+func SysctlValuesEqual(desired, actual string, unordered bool) bool {
+	if !unordered {
+		return NormalizeSysctlValue(desired) == NormalizeSysctlValue(actual)
+	}
+
+	want := strings.Fields(desired)
+	have := strings.Fields(actual)
+	sort.Strings(want)
+	sort.Strings(have)
+	return slices.Equal(slices.Compact(want), slices.Compact(have))
+}
+
+// persist rewrites the drop-in with params and loads it. Both go through the
+// privilege command, as /etc/sysctl.d is only writable by root.
+func (a *SysctlApplier) persist(ctx context.Context, params map[string]string) error {
+	if err := writePrivileged(ctx, a.confPath, renderSysctlConf(params), "0644"); err != nil {
+		return fmt.Errorf("failed to write %s: %w", a.confPath, err)
+	}
+
+	output, err := runCombined(ctx, "sudo", "sysctl", "-p", a.confPath)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %s (output: %s)", a.confPath, err, string(output))
+	}
+	return nil
+}
+
+// current returns key's runtime value, loading the module configured for it
+// if the key is missing. In dry-run the module load is only planned: the key
+// is reported available with an unknown ("") value so the write is planned
+// too. Actions taken along the way are recorded on result.
+func (a *SysctlApplier) current(ctx context.Context, key string, dryRun bool, result *ApplyResult) (value string, available bool, err error) {
+	available, err = a.Available(key)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to check sysctl key: %w", err)
+	}
+
+	// A missing key may belong to a module that isn't loaded yet
+	if !available {
+		module := a.moduleFor(key)
+		if module == "" {
+			return "", false, nil
+		}
+
+		result.Changed = true
+		result.Actions = append(result.Actions, fmt.Sprintf("modprobe %s", module))
+		if dryRun {
+			return "", true, nil
+		}
+		if err := a.loadModule(ctx, module); err != nil {
+			return "", false, fmt.Errorf("failed to load module %s for %s: %w", module, key, err)
+		}
+		if available, err = a.Available(key); err != nil || !available {
+			return "", available, err
+		}
+	}
+
+	value, err = a.Get(ctx, key)
+	if err != nil {
+		return "", true, fmt.Errorf("failed to get sysctl value: %w", err)
+	}
+	return value, true, nil
+}
+
+// unavailable records on result that key doesn't exist on this kernel,
+// as an error or a skip depending on policy
+func (a *SysctlApplier) unavailable(key string, result *ApplyResult) {
+	if a.policy.UnknownKeys == config.SysctlUnknownKeysError {
+		result.Error = fmt.Errorf("sysctl key %s %w", key, ErrSysctlKeyUnknown)
+		return
+	}
+	result.Skipped = true
+	result.Actions = append(result.Actions, "skip: key not available on this kernel")
+}
+
+// Available reports whether key exists on the running kernel. Only a missing
+// /proc/sys entry counts as unavailable; other stat errors are returned so
+// they aren't mistaken for an unknown key.
+func (a *SysctlApplier) Available(key string) (bool, error) {
+	path := key
+	if !strings.Contains(key, "/") {
+		path = strings.ReplaceAll(key, ".", "/")
+	}
+
+	_, err := os.Stat(filepath.Join(a.procSysDir, path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// moduleFor returns the kernel module configured for the longest key prefix
+// matching key, or "" if there is none
+func (a *SysctlApplier) moduleFor(key string) string {
+	var module, matched string
+	for prefix, m := range a.policy.Modules {
+		if strings.HasPrefix(key, prefix) && len(prefix) > len(matched) {
+			module, matched = m, prefix
+		}
+	}
+	return module
+}
+
+func (a *SysctlApplier) loadModule(ctx context.Context, module string) error {
+	output, err := runCombined(ctx, "sudo", "modprobe", module)
+	if err != nil {
+		return fmt.Errorf("%s (output: %s)", err, string(output))
+	}
+	return nil
+}
+
+// Get retrieves the current value of a sysctl parameter, with list values
+// space-separated
+func (a *SysctlApplier) Get(ctx context.Context, key string) (string, error) {
+	output, err := runOutput(ctx, "sysctl", "-n", key)
 	if err != nil {
 		return "", err
 	}
-	return strings.TrimSpace(string(output)), nil
+	return NormalizeSysctlValue(string(output)), nil
 }
 
 // Set applies a new value to a sysctl parameter
-func (a *SysctlApplier) Set(key, value string) error {
-	cmd := exec.Command("sudo", "sysctl", "-w", fmt.Sprintf("%s=%s", key, value))
-	output, err := cmd.CombinedOutput()
+func (a *SysctlApplier) Set(ctx context.Context, key, value string) error {
+	output, err := runCombined(ctx, "sudo", "sysctl", "-w", fmt.Sprintf("%s=%s", key, value))
 	if err != nil {
 		return fmt.Errorf("%s (output: %s)", err, string(output))
 	}
 	return nil
 }
 
-// SetPersistent writes sysctl changes to /etc/sysctl.d/ for persistence across reboots
-func (a *SysctlApplier) SetPersistent(key, value, configFile string) error {
-	// First apply runtime change
-	if err := a.Set(key, value); err != nil {
-		return fmt.Errorf("failed to set runtime value: %w", err)
+// readSysctlConf parses a sysctl.d file into its key/value pairs. A missing
+// file has none.
+func readSysctlConf(path string) (map[string]string, error) {
+	params := map[string]string{}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return params, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// Then persist to config file
-	// Note: This is a placeholder for future implementation
-	// Would need proper file management to update /etc/sysctl.d/99-power-edge.conf
-	return nil
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		// A leading "-" only tells sysctl to ignore failures for the key
+		key = strings.TrimPrefix(strings.TrimSpace(key), "-")
+		params[key] = strings.TrimSpace(value)
+	}
+	return params, nil
+}
+
+// renderSysctlConf renders params as a sysctl.d file, sorted by key
+func renderSysctlConf(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("# Managed by power-edge; local changes will be overwritten\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s = %s\n", key, params[key])
+	}
+	return b.String()
 }
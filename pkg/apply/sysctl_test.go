@@ -1,16 +1,24 @@
 package apply
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
 )
 
 func TestSysctlApplier_Apply(t *testing.T) {
 	tests := []struct {
-		name     string
-		key      string
-		value    string
-		dryRun   bool
-		wantErr  bool
+		name    string
+		key     string
+		value   string
+		dryRun  bool
+		wantErr bool
 	}{
 		{
 			name:    "valid sysctl key in dry-run",
@@ -31,7 +39,7 @@ func TestSysctlApplier_Apply(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := NewSysctlApplier()
-			result := a.Apply(tt.key, tt.value, tt.dryRun)
+			result := a.Apply(context.Background(), tt.key, tt.value, tt.dryRun)
 
 			if (result.Error != nil) != tt.wantErr {
 				t.Errorf("Apply() error = %v, wantErr %v", result.Error, tt.wantErr)
@@ -49,7 +57,7 @@ func TestSysctlApplier_Get(t *testing.T) {
 	a := NewSysctlApplier()
 
 	// Test getting a common sysctl value (should exist on most systems)
-	value, err := a.Get("kernel.hostname")
+	value, err := a.Get(context.Background(), "kernel.hostname")
 	if err != nil {
 		t.Skipf("Skipping test, sysctl not available: %v", err)
 	}
@@ -65,8 +73,242 @@ func TestSysctlApplier_InvalidKey(t *testing.T) {
 	a := NewSysctlApplier()
 
 	// Test with completely invalid key
-	_, err := a.Get("invalid.nonexistent.key.12345")
+	_, err := a.Get(context.Background(), "invalid.nonexistent.key.12345")
 	if err == nil {
 		t.Error("Expected error for invalid sysctl key")
 	}
 }
+
+func TestSysctlApplier_Available(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "net", "ipv4"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "net", "ipv4", "ip_forward"), []byte("0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a := &SysctlApplier{procSysDir: dir}
+
+	for key, want := range map[string]bool{
+		"net.ipv4.ip_forward":                true,
+		"net/ipv4/ip_forward":                true,
+		"net.bridge.bridge-nf-call-iptables": false,
+	} {
+		got, err := a.Available(key)
+		if err != nil {
+			t.Fatalf("Available(%q) error: %v", key, err)
+		}
+		if got != want {
+			t.Errorf("Available(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestSysctlApplier_UnknownKey(t *testing.T) {
+	const key = "net.bridge.bridge-nf-call-iptables"
+
+	tests := []struct {
+		name        string
+		policy      config.SysctlPolicy
+		wantSkipped bool
+		wantErr     error
+		wantActions []string
+	}{
+		{
+			name:        "skipped by default",
+			wantSkipped: true,
+			wantActions: []string{"skip: key not available on this kernel"},
+		},
+		{
+			name:    "error when policy says so",
+			policy:  config.SysctlPolicy{UnknownKeys: config.SysctlUnknownKeysError},
+			wantErr: ErrSysctlKeyUnknown,
+		},
+		{
+			name: "module load planned in dry-run",
+			policy: config.SysctlPolicy{Modules: map[string]string{
+				"net.":        "ignored",
+				"net.bridge.": "br_netfilter",
+			}},
+			wantActions: []string{"modprobe br_netfilter", "sysctl -w " + key + "=1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &SysctlApplier{procSysDir: t.TempDir()}
+			a.SetPolicy(tt.policy)
+
+			result := a.Apply(context.Background(), key, "1", true)
+			if !errors.Is(result.Error, tt.wantErr) {
+				t.Fatalf("Apply() error = %v, want %v", result.Error, tt.wantErr)
+			}
+			if result.Skipped != tt.wantSkipped {
+				t.Errorf("Skipped = %v, want %v", result.Skipped, tt.wantSkipped)
+			}
+			if tt.wantActions != nil && !reflect.DeepEqual(result.Actions, tt.wantActions) {
+				t.Errorf("Actions = %q, want %q", result.Actions, tt.wantActions)
+			}
+		})
+	}
+}
+
+func TestSysctlConf_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "99-power-edge.conf")
+
+	params, err := readSysctlConf(path)
+	if err != nil || len(params) != 0 {
+		t.Fatalf("readSysctlConf(missing) = %v, %v; want empty", params, err)
+	}
+
+	want := map[string]string{
+		"net.ipv4.ip_forward": "1",
+		"net.ipv4.tcp_rmem":   "4096 87380 6291456",
+	}
+	if err := os.WriteFile(path, []byte(renderSysctlConf(want)+"; comment\n-vm.swappiness=10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want["vm.swappiness"] = "10"
+
+	got, err := readSysctlConf(path)
+	if err != nil {
+		t.Fatalf("readSysctlConf() error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readSysctlConf() = %v, want %v", got, want)
+	}
+}
+
+func TestSysctlApplier_ApplyAll(t *testing.T) {
+	a := NewSysctlApplier()
+	a.procSysDir = t.TempDir()
+	a.confPath = filepath.Join(t.TempDir(), "99-power-edge.conf")
+
+	// Only keys present under procSysDir are read from the running kernel
+	if err := os.MkdirAll(filepath.Join(a.procSysDir, "kernel"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(a.procSysDir, "kernel", "ostype"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	current, err := a.Get(context.Background(), "kernel.ostype")
+	if err != nil {
+		t.Skipf("Skipping test, sysctl not available: %v", err)
+	}
+
+	results := a.ApplyAll(context.Background(), map[string]string{
+		"kernel.ostype":                      current,
+		"net.bridge.bridge-nf-call-iptables": "1",
+	}, true)
+
+	// Compliant at runtime but not yet persisted
+	got := results["kernel.ostype"]
+	if got.Error != nil || !got.Changed {
+		t.Errorf("kernel.ostype = %+v, want changed without error", got)
+	}
+	want := []string{"persist kernel.ostype=" + current + " in " + a.confPath}
+	if !reflect.DeepEqual(got.Actions, want) {
+		t.Errorf("kernel.ostype actions = %q, want %q", got.Actions, want)
+	}
+
+	if got := results["net.bridge.bridge-nf-call-iptables"]; !got.Skipped {
+		t.Errorf("missing key = %+v, want skipped", got)
+	}
+
+	// Dry-run never writes the drop-in
+	if _, err := os.Stat(a.confPath); !os.IsNotExist(err) {
+		t.Errorf("drop-in written in dry-run: %v", err)
+	}
+}
+
+func TestSysctlApplier_PersistThroughSudo(t *testing.T) {
+	a := NewSysctlApplier()
+	a.confPath = filepath.Join(t.TempDir(), "99-power-edge.conf")
+	log := fakeSudo(t, 0)
+
+	if err := a.persist(context.Background(), map[string]string{"vm.swappiness": "10"}); err != nil {
+		t.Fatalf("persist() error = %v", err)
+	}
+
+	// The drop-in is only written by the privilege command
+	tmp := filepath.Join(filepath.Dir(a.confPath), ".99-power-edge.conf.power-edge-tmp")
+	want := []string{
+		"install -m 0644 /dev/null " + tmp,
+		"dd of=" + tmp + " status=none",
+		"mv -f " + tmp + " " + a.confPath,
+		"sysctl -p " + a.confPath,
+	}
+	if calls := sudoCalls(t, log); !reflect.DeepEqual(calls, want) {
+		t.Errorf("sudo calls = %q, want %q", calls, want)
+	}
+	if _, err := os.Stat(a.confPath); !os.IsNotExist(err) {
+		t.Errorf("drop-in written without sudo: %v", err)
+	}
+}
+
+func TestSysctlApplier_PersistFailure(t *testing.T) {
+	a := NewSysctlApplier()
+	a.confPath = filepath.Join(t.TempDir(), "99-power-edge.conf")
+	log := fakeSudo(t, 1)
+
+	if err := a.persist(context.Background(), map[string]string{"vm.swappiness": "10"}); err == nil {
+		t.Fatal("persist() succeeded, want the failed write")
+	}
+	// The temp file is cleaned up and nothing is loaded
+	calls := sudoCalls(t, log)
+	if len(calls) != 2 || !strings.HasPrefix(calls[1], "rm -f ") {
+		t.Errorf("sudo calls = %q, want the install then cleanup", calls)
+	}
+}
+
+func TestSysctlValuesEqual(t *testing.T) {
+	tests := []struct {
+		desired, actual string
+		unordered       bool
+		want            bool
+	}{
+		{"1", "1", false, true},
+		{"1", "0", false, false},
+		{"16384 131072 6291456", "16384\t131072\t6291456", false, true},
+		{" 16384  131072 6291456\n", "16384\t131072\t6291456", false, true},
+		{"16384 131072 6291456", "131072 16384 6291456", false, false},
+		{"16384 131072 6291456", "131072\t16384\t6291456", true, true},
+		{"8080 9090", "8080", true, false},
+		{"8080 8080 9090", "9090 8080", true, true},
+	}
+
+	for _, tt := range tests {
+		if got := SysctlValuesEqual(tt.desired, tt.actual, tt.unordered); got != tt.want {
+			t.Errorf("SysctlValuesEqual(%q, %q, %v) = %v, want %v", tt.desired, tt.actual, tt.unordered, got, tt.want)
+		}
+	}
+}
+
+func TestSysctlApplier_ApplyAll_ListWhitespace(t *testing.T) {
+	a := NewSysctlApplier()
+	a.procSysDir = t.TempDir()
+	a.confPath = filepath.Join(t.TempDir(), "99-power-edge.conf")
+
+	if err := os.MkdirAll(filepath.Join(a.procSysDir, "net", "ipv4"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(a.procSysDir, "net", "ipv4", "tcp_rmem"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	current, err := a.Get(context.Background(), "net.ipv4.tcp_rmem")
+	if err != nil {
+		t.Skipf("Skipping test, sysctl not available: %v", err)
+	}
+
+	// The kernel reports the value tab-separated; already persisted with tabs
+	if err := os.WriteFile(a.confPath, []byte("net.ipv4.tcp_rmem = "+strings.ReplaceAll(current, " ", "\t")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := a.ApplyAll(context.Background(), map[string]string{
+		"net.ipv4.tcp_rmem": "  " + strings.ReplaceAll(current, " ", "   "),
+	}, true)
+	if got := results["net.ipv4.tcp_rmem"]; got.Error != nil || got.Changed {
+		t.Errorf("net.ipv4.tcp_rmem = %+v, want compliant", got)
+	}
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ResourceRef names a resource in depends_on, e.g. "package:nginx"
+func ResourceRef(resourceType, name string) string {
+	return resourceType + ":" + name
+}
+
+// dependencies returns each resource that can be depended on, mapped to the
+// resources it depends on. Resources that can't declare dependencies map to
+// nil.
+func (s *State) dependencies() map[string][]string {
+	deps := make(map[string][]string, len(s.Services)+len(s.Repositories)+len(s.Packages)+len(s.Files)+len(s.Sysctl))
+	for key := range s.Sysctl {
+		deps[ResourceRef("sysctl", key)] = nil
+	}
+	for _, r := range s.Repositories {
+		deps[ResourceRef("repository", r.Name)] = r.DependsOn
+	}
+	for _, p := range s.Packages {
+		deps[ResourceRef("package", p.Name)] = p.DependsOn
+	}
+	for _, f := range s.Files {
+		deps[ResourceRef("file", string(f.Path))] = f.DependsOn
+	}
+	for _, svc := range s.Services {
+		deps[ResourceRef("service", svc.Name)] = svc.DependsOn
+	}
+	return deps
+}
+
+// HasDependencies reports whether any resource declares depends_on
+func (s *State) HasDependencies() bool {
+	for _, deps := range s.dependencies() {
+		if len(deps) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// DependencyLevels returns how many rounds of reconciliation each resource
+// has to wait for: 0 for resources without dependencies, otherwise one more
+// than the deepest resource they depend on. Resources of a level can be
+// reconciled together once every lower level is done. Unknown references
+// and cycles are errors.
+func (s *State) DependencyLevels() (map[string]int, error) {
+	deps := s.dependencies()
+	levels := make(map[string]int, len(deps))
+	visiting := map[string]bool{}
+
+	var visit func(ref string, path []string) (int, error)
+	visit = func(ref string, path []string) (int, error) {
+		if level, ok := levels[ref]; ok {
+			return level, nil
+		}
+		if visiting[ref] {
+			return 0, fmt.Errorf("dependency cycle: %s", strings.Join(append(path, ref), " -> "))
+		}
+		visiting[ref] = true
+		defer delete(visiting, ref)
+
+		level := 0
+		for _, dep := range deps[ref] {
+			if _, ok := deps[dep]; !ok {
+				return 0, fmt.Errorf("%s depends on unknown resource %q", ref, dep)
+			}
+			depLevel, err := visit(dep, append(path, ref))
+			if err != nil {
+				return 0, err
+			}
+			level = max(level, depLevel+1)
+		}
+		levels[ref] = level
+		return level, nil
+	}
+
+	for _, ref := range slices.Sorted(maps.Keys(deps)) {
+		if _, err := visit(ref, nil); err != nil {
+			return nil, err
+		}
+	}
+	return levels, nil
+}
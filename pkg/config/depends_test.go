@@ -0,0 +1,71 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDependencyLevels(t *testing.T) {
+	state := &State{
+		Version:  "1.0",
+		Metadata: Metadata{Site: "lab", Environment: "development"},
+		Sysctl:   map[string]string{"vm.swappiness": "10"},
+		Packages: []PackageConfig{{Name: "nginx"}},
+		Files: []FileConfig{
+			{Path: "/etc/nginx/nginx.conf", DependsOn: []string{"package:nginx"}},
+			{Path: "/etc/motd"},
+		},
+		Services: []ServiceConfig{
+			{Name: "nginx", State: ServiceStateRunning, DependsOn: []string{"file:/etc/nginx/nginx.conf", "package:nginx", "sysctl:vm.swappiness"}},
+		},
+	}
+	if !state.HasDependencies() {
+		t.Fatal("HasDependencies() = false")
+	}
+
+	levels, err := state.DependencyLevels()
+	if err != nil {
+		t.Fatalf("DependencyLevels() error = %v", err)
+	}
+	want := map[string]int{
+		"sysctl:vm.swappiness":       0,
+		"package:nginx":              0,
+		"file:/etc/motd":             0,
+		"file:/etc/nginx/nginx.conf": 1,
+		"service:nginx":              2,
+	}
+	if !reflect.DeepEqual(levels, want) {
+		t.Errorf("DependencyLevels() = %v, want %v", levels, want)
+	}
+	if err := state.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestDependencyLevels_Errors(t *testing.T) {
+	metadata := Metadata{Site: "lab", Environment: "development"}
+
+	cyclic := &State{
+		Metadata: metadata,
+		Packages: []PackageConfig{{Name: "a", DependsOn: []string{"file:/b"}}},
+		Files:    []FileConfig{{Path: "/b", DependsOn: []string{"package:a"}}},
+	}
+	if _, err := cyclic.DependencyLevels(); err == nil || !strings.Contains(err.Error(), "dependency cycle: file:/b -> package:a -> file:/b") {
+		t.Errorf("DependencyLevels() error = %v, want the cycle", err)
+	}
+	if err := cyclic.Validate(); err == nil || !strings.Contains(err.Error(), "depends_on: dependency cycle") {
+		t.Errorf("Validate() error = %v, want the cycle", err)
+	}
+
+	unknown := &State{
+		Metadata: metadata,
+		Services: []ServiceConfig{{Name: "app", State: ServiceStateRunning, DependsOn: []string{"nginx"}}},
+	}
+	if _, err := unknown.DependencyLevels(); err == nil || !strings.Contains(err.Error(), `service:app depends on unknown resource "nginx"`) {
+		t.Errorf("DependencyLevels() error = %v, want the unknown reference", err)
+	}
+	if err := unknown.Validate(); err == nil || !strings.Contains(err.Error(), `services[0].depends_on: unknown resource "nginx"`) {
+		t.Errorf("Validate() error = %v, want the unknown reference", err)
+	}
+}
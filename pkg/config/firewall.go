@@ -0,0 +1,8 @@
+package config
+
+// Configured reports whether f asks for anything to be managed. A zero
+// FirewallConfig leaves the host's firewall alone.
+func (f *FirewallConfig) Configured() bool {
+	return f.Enabled || len(f.AllowedServices) > 0 || len(f.Interfaces) > 0 ||
+		f.DefaultPolicy.Incoming != "" || f.DefaultPolicy.Outgoing != "" || f.Logging != ""
+}
@@ -0,0 +1,21 @@
+package config
+
+import "time"
+
+// Active reports whether the freeze is in effect at now. A freeze with an
+// end time expires on its own; one without lasts until it is disabled. An
+// unparseable end time keeps the freeze active, erring on the side of not
+// changing anything.
+func (f FreezeConfig) Active(now time.Time) bool {
+	if !f.Enabled {
+		return false
+	}
+	if f.Until == "" {
+		return true
+	}
+	until, err := time.Parse(time.RFC3339, f.Until)
+	if err != nil {
+		return true
+	}
+	return now.Before(until)
+}
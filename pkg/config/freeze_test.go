@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFreezeConfig_Active(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		freeze FreezeConfig
+		want   bool
+	}{
+		{"disabled", FreezeConfig{}, false},
+		{"disabled with end time", FreezeConfig{Until: "2026-03-02T00:00:00Z"}, false},
+		{"open-ended", FreezeConfig{Enabled: true}, true},
+		{"before end time", FreezeConfig{Enabled: true, Until: "2026-03-02T00:00:00Z"}, true},
+		{"after end time", FreezeConfig{Enabled: true, Until: "2026-03-01T11:59:59Z"}, false},
+		{"at end time", FreezeConfig{Enabled: true, Until: "2026-03-01T12:00:00Z"}, false},
+		{"end time in another zone", FreezeConfig{Enabled: true, Until: "2026-03-01T13:30:00+01:00"}, true},
+		{"unparseable end time", FreezeConfig{Enabled: true, Until: "tomorrow"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.freeze.Active(now); got != tt.want {
+				t.Errorf("Active() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
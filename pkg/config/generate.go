@@ -1,3 +1,3 @@
 package config
 
-//go:generate go run ../../cmd/generator/main.go -schema-dir=../../schemas -output-dir=.
+//go:generate go run ../../cmd/generator -schema-dir=../../schemas -output-dir=.
@@ -6,17 +6,51 @@ package config
 import (
 	"fmt"
 	"os"
+	"reflect"
+	"regexp"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
+// Command Shell command or binary name
+type Command string
+
 // Metadata represents a generated type.
 type Metadata struct {
-	Environment string                 `json:"environment" yaml:"environment"` //
-	Description string                 `json:"description" yaml:"description"` //
-	Labels      map[string]interface{} `json:"labels" yaml:"labels"`           //
-	Annotations map[string]interface{} `json:"annotations" yaml:"annotations"` //
-	Site        string                 `json:"site" yaml:"site"`               // Unique site identifier (typically hostname)
+	Annotations map[string]interface{} `json:"annotations,omitempty" yaml:"annotations,omitempty"` //
+	Description string                 `json:"description,omitempty" yaml:"description,omitempty"` //
+	Environment string                 `json:"environment" yaml:"environment"`                     //
+	Labels      map[string]interface{} `json:"labels,omitempty" yaml:"labels,omitempty"`           //
+	Site        string                 `json:"site" yaml:"site"`                                   // Unique site identifier (typically hostname)
+}
+
+// Validate checks Metadata against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *Metadata) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *Metadata) validate(prefix string, errs *ValidationErrors) {
+	if x.Environment == "" {
+		errs.add(prefix+"environment", "is required")
+	}
+	switch x.Environment {
+	case "", "production", "staging", "development", "home-lab":
+	default:
+		errs.add(prefix+"environment", "must be one of production, staging, development, home-lab, got %q", x.Environment)
+	}
+	if x.Site == "" {
+		errs.add(prefix+"site", "is required")
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
 }
 
 // EnvironmentEnum represents a generated type.
@@ -29,6 +63,15 @@ const (
 	EnvironmentEnumHomeLab     EnvironmentEnum = "home-lab"
 )
 
+// Valid reports whether v is one of the defined EnvironmentEnum values
+func (v EnvironmentEnum) Valid() bool {
+	switch v {
+	case EnvironmentEnumProduction, EnvironmentEnumStaging, EnvironmentEnumDevelopment, EnvironmentEnumHomeLab:
+		return true
+	}
+	return false
+}
+
 // Port Valid TCP/UDP port number
 type Port int
 
@@ -41,6 +84,33 @@ const (
 	ProtocolIcmp Protocol = "icmp"
 )
 
+// Valid reports whether v is one of the defined Protocol values
+func (v Protocol) Valid() bool {
+	switch v {
+	case ProtocolTcp, ProtocolUdp, ProtocolIcmp:
+		return true
+	}
+	return false
+}
+
+// ReconcileMode How drift is handled - ignored, logged, or fixed
+type ReconcileMode string
+
+const (
+	ReconcileModeDisabled ReconcileMode = "disabled"
+	ReconcileModeDryRun   ReconcileMode = "dry-run"
+	ReconcileModeEnforce  ReconcileMode = "enforce"
+)
+
+// Valid reports whether v is one of the defined ReconcileMode values
+func (v ReconcileMode) Valid() bool {
+	switch v {
+	case ReconcileModeDisabled, ReconcileModeDryRun, ReconcileModeEnforce:
+		return true
+	}
+	return false
+}
+
 // ServiceState Systemd service state
 type ServiceState string
 
@@ -48,132 +118,368 @@ const (
 	ServiceStateRunning  ServiceState = "running"
 	ServiceStateStopped  ServiceState = "stopped"
 	ServiceStateDisabled ServiceState = "disabled"
+	ServiceStateAbsent   ServiceState = "absent"
 )
 
-// UnixPath Absolute Unix filesystem path
-type UnixPath string
-
-// Command Shell command or binary name
-type Command string
+// Valid reports whether v is one of the defined ServiceState values
+func (v ServiceState) Valid() bool {
+	switch v {
+	case ServiceStateRunning, ServiceStateStopped, ServiceStateDisabled, ServiceStateAbsent:
+		return true
+	}
+	return false
+}
 
 // ServiceUnit Systemd unit name
 type ServiceUnit string
 
+// UnixPath Absolute Unix filesystem path
+type UnixPath string
+
 // Version Schema version in MAJOR.MINOR format
 type Version string
 
 // NodeIdentity represents a generated type.
 type NodeIdentity struct {
-	Node          NodeMetadata        `json:"node" yaml:"node"`                     //
-	Roles         []NodeRole          `json:"roles" yaml:"roles"`                   // Roles this node performs (maps to actual workloads)
-	VpnGateway    VPNGatewayConfig    `json:"vpn_gateway" yaml:"vpn_gateway"`       // Configuration for VPN gateway role
-	ContainerHost ContainerHostConfig `json:"container_host" yaml:"container_host"` // Configuration for container host role
-	NetworkTuning NetworkTuning       `json:"network_tuning" yaml:"network_tuning"` // Network-specific kernel parameters (semantic sysctl)
-	SystemTuning  SystemTuning        `json:"system_tuning" yaml:"system_tuning"`   // System-level kernel parameters (semantic sysctl)
-	AccessControl AccessControl       `json:"access_control" yaml:"access_control"` // Remote access and firewall configuration
-	Version       string              `json:"version" yaml:"version"`               //
+	AccessControl AccessControl       `json:"access_control,omitempty" yaml:"access_control,omitempty"` // Remote access and firewall configuration
+	ContainerHost ContainerHostConfig `json:"container_host,omitempty" yaml:"container_host,omitempty"` // Configuration for container host role
+	NetworkTuning NetworkTuning       `json:"network_tuning,omitempty" yaml:"network_tuning,omitempty"` // Network-specific kernel parameters (semantic sysctl)
+	Node          NodeMetadata        `json:"node" yaml:"node"`                                         //
+	Roles         []NodeRole          `json:"roles" yaml:"roles"`                                       // Roles this node performs (maps to actual workloads)
+	SystemTuning  SystemTuning        `json:"system_tuning,omitempty" yaml:"system_tuning,omitempty"`   // System-level kernel parameters (semantic sysctl)
+	Version       string              `json:"version" yaml:"version"`                                   //
+	VpnGateway    VPNGatewayConfig    `json:"vpn_gateway,omitempty" yaml:"vpn_gateway,omitempty"`       // Configuration for VPN gateway role
 }
 
-// NodeMetadata represents a generated type.
-type NodeMetadata struct {
-	Tags     []string     `json:"tags" yaml:"tags"`         // Semantic tags describing node capabilities
-	Hardware HardwareInfo `json:"hardware" yaml:"hardware"` //
-	Hostname string       `json:"hostname" yaml:"hostname"` // Canonical hostname of the node
-	Purpose  string       `json:"purpose" yaml:"purpose"`   // Primary purpose of this node
+var (
+	patternNodeIdentityVersion = regexp.MustCompile(`^[0-9]+\.[0-9]+$`)
+)
+
+// Validate checks NodeIdentity against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *NodeIdentity) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
-// HardwareInfo represents a generated type.
-type HardwareInfo struct {
-	CPUCores     int          `json:"cpu_cores" yaml:"cpu_cores"`       //
-	MemoryGB     int          `json:"memory_gb" yaml:"memory_gb"`       //
-	Architecture Architecture `json:"architecture" yaml:"architecture"` //
-	Model        string       `json:"model" yaml:"model"`               // Hardware model
+func (x *NodeIdentity) validate(prefix string, errs *ValidationErrors) {
+	x.AccessControl.validate(prefix+"access_control.", errs)
+	x.ContainerHost.validate(prefix+"container_host.", errs)
+	x.NetworkTuning.validate(prefix+"network_tuning.", errs)
+	x.Node.validate(prefix+"node.", errs)
+	if len(x.Roles) == 0 {
+		errs.add(prefix+"roles", "is required")
+	}
+	for i := range x.Roles {
+		x.Roles[i].validate(fmt.Sprintf("%sroles[%d].", prefix, i), errs)
+	}
+	x.SystemTuning.validate(prefix+"system_tuning.", errs)
+	if x.Version == "" {
+		errs.add(prefix+"version", "is required")
+	}
+	if x.Version != "" && !patternNodeIdentityVersion.MatchString(string(x.Version)) {
+		errs.add(prefix+"version", "must match %s, got %q", patternNodeIdentityVersion, x.Version)
+	}
+	x.VpnGateway.validate(prefix+"vpn_gateway.", errs)
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
 }
 
-// Architecture represents a generated type.
-type Architecture string
+// AccessControl Remote access and firewall configuration
+type AccessControl struct {
+	Firewall FirewallConfig `json:"firewall,omitempty" yaml:"firewall,omitempty"` //
+	Ssh      SSHConfig      `json:"ssh,omitempty" yaml:"ssh,omitempty"`           //
+}
+
+// Validate checks AccessControl against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *AccessControl) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *AccessControl) validate(prefix string, errs *ValidationErrors) {
+	x.Firewall.validate(prefix+"firewall.", errs)
+	x.Ssh.validate(prefix+"ssh.", errs)
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// FirewallConfig represents a generated type.
+type FirewallConfig struct {
+	AllowedServices []string              `json:"allowed_services,omitempty" yaml:"allowed_services,omitempty"` // Services to allow (ssh, http, https, openvpn, etc.)
+	DefaultPolicy   FirewallDefaultPolicy `json:"default_policy,omitempty" yaml:"default_policy,omitempty"`     //
+	Enabled         bool                  `json:"enabled,omitempty" yaml:"enabled,omitempty"`                   //
+	Interfaces      []FirewallInterface   `json:"interfaces,omitempty" yaml:"interfaces,omitempty"`             // Rules scoped to one interface, e.g. WAN vs LAN on a gateway; allowed_services apply on any interface
+	Logging         FirewallLogging       `json:"logging,omitempty" yaml:"logging,omitempty"`                   // Firewall log level (ufw only)
+	Provider        FirewallProvider      `json:"provider,omitempty" yaml:"provider,omitempty"`                 //
+}
+
+// Validate checks FirewallConfig against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *FirewallConfig) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *FirewallConfig) validate(prefix string, errs *ValidationErrors) {
+	x.DefaultPolicy.validate(prefix+"default_policy.", errs)
+	for i := range x.Interfaces {
+		x.Interfaces[i].validate(fmt.Sprintf("%sinterfaces[%d].", prefix, i), errs)
+	}
+	if x.Logging != "" && !x.Logging.Valid() {
+		errs.add(prefix+"logging", "must be one of off, low, medium, high, got %q", x.Logging)
+	}
+	if x.Provider != "" && !x.Provider.Valid() {
+		errs.add(prefix+"provider", "must be one of ufw, firewalld, iptables, nftables, got %q", x.Provider)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// FirewallDefaultPolicy represents a generated type.
+type FirewallDefaultPolicy struct {
+	Incoming string `json:"incoming,omitempty" yaml:"incoming,omitempty"` //
+	Outgoing string `json:"outgoing,omitempty" yaml:"outgoing,omitempty"` //
+}
+
+// Validate checks FirewallDefaultPolicy against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *FirewallDefaultPolicy) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *FirewallDefaultPolicy) validate(prefix string, errs *ValidationErrors) {
+	switch x.Incoming {
+	case "", "allow", "deny", "reject":
+	default:
+		errs.add(prefix+"incoming", "must be one of allow, deny, reject, got %q", x.Incoming)
+	}
+	switch x.Outgoing {
+	case "", "allow", "deny", "reject":
+	default:
+		errs.add(prefix+"outgoing", "must be one of allow, deny, reject, got %q", x.Outgoing)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// IncomingEnum represents a generated type.
+type IncomingEnum string
 
 const (
-	ArchitectureX8664 Architecture = "x86_64"
-	ArchitectureArm64 Architecture = "arm64"
-	ArchitectureArmv7 Architecture = "armv7"
+	IncomingEnumAllow  IncomingEnum = "allow"
+	IncomingEnumDeny   IncomingEnum = "deny"
+	IncomingEnumReject IncomingEnum = "reject"
 )
 
-// NodeRole represents a generated type.
-type NodeRole struct {
-	Name    RoleName               `json:"name" yaml:"name"`       // Semantic role identifier
-	Enabled bool                   `json:"enabled" yaml:"enabled"` //
-	Config  map[string]interface{} `json:"config" yaml:"config"`   // Role-specific configuration
+// Valid reports whether v is one of the defined IncomingEnum values
+func (v IncomingEnum) Valid() bool {
+	switch v {
+	case IncomingEnumAllow, IncomingEnumDeny, IncomingEnumReject:
+		return true
+	}
+	return false
 }
 
-// RoleName Semantic role identifier
-type RoleName string
+// OutgoingEnum represents a generated type.
+type OutgoingEnum string
 
 const (
-	RoleNameVpnGateway       RoleName = "vpn-gateway"
-	RoleNameContainerHost    RoleName = "container-host"
-	RoleNameEdgeRouter       RoleName = "edge-router"
-	RoleNameMonitoringTarget RoleName = "monitoring-target"
-	RoleNameDevWorkstation   RoleName = "dev-workstation"
-	RoleNameK8sNode          RoleName = "k8s-node"
+	OutgoingEnumAllow  OutgoingEnum = "allow"
+	OutgoingEnumDeny   OutgoingEnum = "deny"
+	OutgoingEnumReject OutgoingEnum = "reject"
 )
 
-// VPNGatewayConfig Configuration for VPN gateway role
-type VPNGatewayConfig struct {
-	Provider     VPNProvider     `json:"provider" yaml:"provider"`           //
-	ServerConfig VPNServerConfig `json:"server_config" yaml:"server_config"` //
-	Routing      VPNRouting      `json:"routing" yaml:"routing"`             //
+// Valid reports whether v is one of the defined OutgoingEnum values
+func (v OutgoingEnum) Valid() bool {
+	switch v {
+	case OutgoingEnumAllow, OutgoingEnumDeny, OutgoingEnumReject:
+		return true
+	}
+	return false
 }
 
-// VPNProvider represents a generated type.
-type VPNProvider string
+// FirewallInterface represents a generated type.
+type FirewallInterface struct {
+	AllowedServices []string `json:"allowed_services,omitempty" yaml:"allowed_services,omitempty"` // Services to allow in on this interface only
+	Name            string   `json:"name" yaml:"name"`                                             // Interface name (eth0, wg0, ...)
+	Zone            string   `json:"zone,omitempty" yaml:"zone,omitempty"`                         // firewalld zone the interface is assigned to, which its allowed_services are added to (firewalld only)
+}
+
+var (
+	patternFirewallInterfaceName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{0,14}$`)
+	patternFirewallInterfaceZone = regexp.MustCompile(`^[A-Za-z0-9_-]{1,17}$`)
+)
+
+// Validate checks FirewallInterface against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *FirewallInterface) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *FirewallInterface) validate(prefix string, errs *ValidationErrors) {
+	if x.Name == "" {
+		errs.add(prefix+"name", "is required")
+	}
+	if x.Name != "" && !patternFirewallInterfaceName.MatchString(string(x.Name)) {
+		errs.add(prefix+"name", "must match %s, got %q", patternFirewallInterfaceName, x.Name)
+	}
+	if x.Zone != "" && !patternFirewallInterfaceZone.MatchString(string(x.Zone)) {
+		errs.add(prefix+"zone", "must match %s, got %q", patternFirewallInterfaceZone, x.Zone)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// FirewallLogging Firewall log level (ufw only)
+type FirewallLogging string
 
 const (
-	VPNProviderOpenvpn   VPNProvider = "openvpn"
-	VPNProviderWireguard VPNProvider = "wireguard"
-	VPNProviderTailscale VPNProvider = "tailscale"
+	FirewallLoggingOff    FirewallLogging = "off"
+	FirewallLoggingLow    FirewallLogging = "low"
+	FirewallLoggingMedium FirewallLogging = "medium"
+	FirewallLoggingHigh   FirewallLogging = "high"
 )
 
-// VPNServerConfig represents a generated type.
-type VPNServerConfig struct {
-	Port     int    `json:"port" yaml:"port"`         //
-	Protocol string `json:"protocol" yaml:"protocol"` //
-	Network  string `json:"network" yaml:"network"`   // VPN network CIDR
+// Valid reports whether v is one of the defined FirewallLogging values
+func (v FirewallLogging) Valid() bool {
+	switch v {
+	case FirewallLoggingOff, FirewallLoggingLow, FirewallLoggingMedium, FirewallLoggingHigh:
+		return true
+	}
+	return false
 }
 
-// ProtocolEnum represents a generated type.
-type ProtocolEnum string
+// FirewallProvider represents a generated type.
+type FirewallProvider string
 
 const (
-	ProtocolEnumUdp ProtocolEnum = "udp"
-	ProtocolEnumTcp ProtocolEnum = "tcp"
+	FirewallProviderUfw       FirewallProvider = "ufw"
+	FirewallProviderFirewalld FirewallProvider = "firewalld"
+	FirewallProviderIptables  FirewallProvider = "iptables"
+	FirewallProviderNftables  FirewallProvider = "nftables"
 )
 
-// VPNRouting represents a generated type.
-type VPNRouting struct {
-	IPForward  bool       `json:"ip_forward" yaml:"ip_forward"` // Enable IP forwarding (net.ipv4.ip_forward)
-	Masquerade bool       `json:"masquerade" yaml:"masquerade"` // Enable NAT masquerading for VPN clients
-	Routes     []VPNRoute `json:"routes" yaml:"routes"`         //
+// Valid reports whether v is one of the defined FirewallProvider values
+func (v FirewallProvider) Valid() bool {
+	switch v {
+	case FirewallProviderUfw, FirewallProviderFirewalld, FirewallProviderIptables, FirewallProviderNftables:
+		return true
+	}
+	return false
 }
 
-// VPNRoute represents a generated type.
-type VPNRoute struct {
-	Network string `json:"network" yaml:"network"` // Destination network
-	Via     string `json:"via" yaml:"via"`         // Gateway or interface
+// SSHConfig represents a generated type.
+type SSHConfig struct {
+	Enabled      bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`             //
+	PasswordAuth bool `json:"password_auth,omitempty" yaml:"password_auth,omitempty"` // Allow password authentication
+	Port         int  `json:"port,omitempty" yaml:"port,omitempty"`                   //
+	RootLogin    bool `json:"root_login,omitempty" yaml:"root_login,omitempty"`       // Allow root login
+}
+
+// Validate checks SSHConfig against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *SSHConfig) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *SSHConfig) validate(prefix string, errs *ValidationErrors) {
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
 }
 
 // ContainerHostConfig Configuration for container host role
 type ContainerHostConfig struct {
-	Networks  []ContainerNetwork  `json:"networks" yaml:"networks"`   //
-	Runtime   ContainerRuntime    `json:"runtime" yaml:"runtime"`     //
-	Workloads []ContainerWorkload `json:"workloads" yaml:"workloads"` //
+	Networks  []ContainerNetwork  `json:"networks,omitempty" yaml:"networks,omitempty"`   //
+	Runtime   ContainerRuntime    `json:"runtime,omitempty" yaml:"runtime,omitempty"`     //
+	Workloads []ContainerWorkload `json:"workloads,omitempty" yaml:"workloads,omitempty"` //
+}
+
+// Validate checks ContainerHostConfig against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *ContainerHostConfig) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *ContainerHostConfig) validate(prefix string, errs *ValidationErrors) {
+	for i := range x.Networks {
+		x.Networks[i].validate(fmt.Sprintf("%snetworks[%d].", prefix, i), errs)
+	}
+	if x.Runtime != "" && !x.Runtime.Valid() {
+		errs.add(prefix+"runtime", "must be one of docker, containerd, podman, got %q", x.Runtime)
+	}
+	for i := range x.Workloads {
+		x.Workloads[i].validate(fmt.Sprintf("%sworkloads[%d].", prefix, i), errs)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
 }
 
 // ContainerNetwork represents a generated type.
 type ContainerNetwork struct {
-	Subnet string        `json:"subnet" yaml:"subnet"` // Network subnet CIDR
-	Name   string        `json:"name" yaml:"name"`     //
-	Driver NetworkDriver `json:"driver" yaml:"driver"` //
+	Driver NetworkDriver `json:"driver,omitempty" yaml:"driver,omitempty"` //
+	Name   string        `json:"name,omitempty" yaml:"name,omitempty"`     //
+	Subnet string        `json:"subnet,omitempty" yaml:"subnet,omitempty"` // Network subnet CIDR
+}
+
+// Validate checks ContainerNetwork against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *ContainerNetwork) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *ContainerNetwork) validate(prefix string, errs *ValidationErrors) {
+	if x.Driver != "" && !x.Driver.Valid() {
+		errs.add(prefix+"driver", "must be one of bridge, host, overlay, macvlan, got %q", x.Driver)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
 }
 
 // NetworkDriver represents a generated type.
@@ -186,6 +492,15 @@ const (
 	NetworkDriverMacvlan NetworkDriver = "macvlan"
 )
 
+// Valid reports whether v is one of the defined NetworkDriver values
+func (v NetworkDriver) Valid() bool {
+	switch v {
+	case NetworkDriverBridge, NetworkDriverHost, NetworkDriverOverlay, NetworkDriverMacvlan:
+		return true
+	}
+	return false
+}
+
 // ContainerRuntime represents a generated type.
 type ContainerRuntime string
 
@@ -195,27 +510,102 @@ const (
 	ContainerRuntimePodman     ContainerRuntime = "podman"
 )
 
+// Valid reports whether v is one of the defined ContainerRuntime values
+func (v ContainerRuntime) Valid() bool {
+	switch v {
+	case ContainerRuntimeDocker, ContainerRuntimeContainerd, ContainerRuntimePodman:
+		return true
+	}
+	return false
+}
+
 // ContainerWorkload represents a generated type.
 type ContainerWorkload struct {
-	State   string        `json:"state" yaml:"state"`     //
-	Ports   []PortMapping `json:"ports" yaml:"ports"`     //
-	Volumes []VolumeMount `json:"volumes" yaml:"volumes"` //
-	Name    string        `json:"name" yaml:"name"`       // Container name
-	Image   string        `json:"image" yaml:"image"`     // Container image
+	Image   string        `json:"image" yaml:"image"`                         // Container image
+	Name    string        `json:"name" yaml:"name"`                           // Container name
+	Ports   []PortMapping `json:"ports,omitempty" yaml:"ports,omitempty"`     //
+	State   string        `json:"state,omitempty" yaml:"state,omitempty"`     //
+	Volumes []VolumeMount `json:"volumes,omitempty" yaml:"volumes,omitempty"` //
+}
+
+// Validate checks ContainerWorkload against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *ContainerWorkload) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *ContainerWorkload) validate(prefix string, errs *ValidationErrors) {
+	if x.Image == "" {
+		errs.add(prefix+"image", "is required")
+	}
+	if x.Name == "" {
+		errs.add(prefix+"name", "is required")
+	}
+	for i := range x.Ports {
+		x.Ports[i].validate(fmt.Sprintf("%sports[%d].", prefix, i), errs)
+	}
+	switch x.State {
+	case "", "running", "stopped", "absent":
+	default:
+		errs.add(prefix+"state", "must be one of running, stopped, absent, got %q", x.State)
+	}
+	for i := range x.Volumes {
+		x.Volumes[i].validate(fmt.Sprintf("%svolumes[%d].", prefix, i), errs)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
 }
 
 // PortMapping represents a generated type.
 type PortMapping struct {
-	Host      int    `json:"host" yaml:"host"`           //
-	Container int    `json:"container" yaml:"container"` //
-	Protocol  string `json:"protocol" yaml:"protocol"`   //
+	Container int    `json:"container,omitempty" yaml:"container,omitempty"` //
+	Host      int    `json:"host,omitempty" yaml:"host,omitempty"`           //
+	Protocol  string `json:"protocol,omitempty" yaml:"protocol,omitempty"`   //
 }
 
-// VolumeMount represents a generated type.
-type VolumeMount struct {
-	Host      string `json:"host" yaml:"host"`           //
-	Container string `json:"container" yaml:"container"` //
-	ReadOnly  bool   `json:"read_only" yaml:"read_only"` //
+// Validate checks PortMapping against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *PortMapping) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *PortMapping) validate(prefix string, errs *ValidationErrors) {
+	switch x.Protocol {
+	case "", "tcp", "udp":
+	default:
+		errs.add(prefix+"protocol", "must be one of tcp, udp, got %q", x.Protocol)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// ProtocolEnum represents a generated type.
+type ProtocolEnum string
+
+const (
+	ProtocolEnumTcp ProtocolEnum = "tcp"
+	ProtocolEnumUdp ProtocolEnum = "udp"
+)
+
+// Valid reports whether v is one of the defined ProtocolEnum values
+func (v ProtocolEnum) Valid() bool {
+	switch v {
+	case ProtocolEnumTcp, ProtocolEnumUdp:
+		return true
+	}
+	return false
 }
 
 // StateEnum represents a generated type.
@@ -227,185 +617,1206 @@ const (
 	StateEnumAbsent  StateEnum = "absent"
 )
 
+// Valid reports whether v is one of the defined StateEnum values
+func (v StateEnum) Valid() bool {
+	switch v {
+	case StateEnumRunning, StateEnumStopped, StateEnumAbsent:
+		return true
+	}
+	return false
+}
+
+// VolumeMount represents a generated type.
+type VolumeMount struct {
+	Container string `json:"container,omitempty" yaml:"container,omitempty"` //
+	Host      string `json:"host,omitempty" yaml:"host,omitempty"`           //
+	ReadOnly  bool   `json:"read_only,omitempty" yaml:"read_only,omitempty"` //
+}
+
+// Validate checks VolumeMount against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *VolumeMount) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *VolumeMount) validate(prefix string, errs *ValidationErrors) {
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
 // NetworkTuning Network-specific kernel parameters (semantic sysctl)
 type NetworkTuning struct {
-	IPForward            bool `json:"ip_forward" yaml:"ip_forward"`                           // Enable IP forwarding (net.ipv4.ip_forward)
-	BridgeNfCallIptables bool `json:"bridge_nf_call_iptables" yaml:"bridge_nf_call_iptables"` // Enable bridge netfilter (net.bridge.bridge-nf-call-iptables)
-	TCPKeepaliveTime     int  `json:"tcp_keepalive_time" yaml:"tcp_keepalive_time"`           // TCP keepalive time in seconds
-	MaxSynBacklog        int  `json:"max_syn_backlog" yaml:"max_syn_backlog"`                 // Maximum SYN backlog
-	RmemMax              int  `json:"rmem_max" yaml:"rmem_max"`                               // Maximum receive buffer size
-	WmemMax              int  `json:"wmem_max" yaml:"wmem_max"`                               // Maximum send buffer size
+	BridgeNfCallIptables bool `json:"bridge_nf_call_iptables,omitempty" yaml:"bridge_nf_call_iptables,omitempty"` // Enable bridge netfilter (net.bridge.bridge-nf-call-iptables)
+	IPForward            bool `json:"ip_forward,omitempty" yaml:"ip_forward,omitempty"`                           // Enable IP forwarding (net.ipv4.ip_forward)
+	MaxSynBacklog        int  `json:"max_syn_backlog,omitempty" yaml:"max_syn_backlog,omitempty"`                 // Maximum SYN backlog
+	RmemMax              int  `json:"rmem_max,omitempty" yaml:"rmem_max,omitempty"`                               // Maximum receive buffer size
+	TCPKeepaliveTime     int  `json:"tcp_keepalive_time,omitempty" yaml:"tcp_keepalive_time,omitempty"`           // TCP keepalive time in seconds
+	WmemMax              int  `json:"wmem_max,omitempty" yaml:"wmem_max,omitempty"`                               // Maximum send buffer size
 }
 
-// SystemTuning System-level kernel parameters (semantic sysctl)
-type SystemTuning struct {
-	Swappiness            int `json:"swappiness" yaml:"swappiness"`                             // VM swappiness (vm.swappiness)
-	InotifyMaxUserWatches int `json:"inotify_max_user_watches" yaml:"inotify_max_user_watches"` // Maximum inotify watches (fs.inotify.max_user_watches)
-	KernelPanic           int `json:"kernel_panic" yaml:"kernel_panic"`                         // Seconds to wait before rebooting on panic
+// Validate checks NetworkTuning against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *NetworkTuning) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
-// AccessControl Remote access and firewall configuration
-type AccessControl struct {
-	Ssh      SSHConfig      `json:"ssh" yaml:"ssh"`           //
-	Firewall FirewallConfig `json:"firewall" yaml:"firewall"` //
+func (x *NetworkTuning) validate(prefix string, errs *ValidationErrors) {
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
 }
 
-// SSHConfig represents a generated type.
-type SSHConfig struct {
-	Port         int  `json:"port" yaml:"port"`                   //
-	PasswordAuth bool `json:"password_auth" yaml:"password_auth"` // Allow password authentication
-	RootLogin    bool `json:"root_login" yaml:"root_login"`       // Allow root login
-	Enabled      bool `json:"enabled" yaml:"enabled"`             //
+// NodeMetadata represents a generated type.
+type NodeMetadata struct {
+	Hardware HardwareInfo `json:"hardware,omitempty" yaml:"hardware,omitempty"` //
+	Hostname string       `json:"hostname" yaml:"hostname"`                     // Canonical hostname of the node
+	Purpose  string       `json:"purpose" yaml:"purpose"`                       // Primary purpose of this node
+	Tags     []string     `json:"tags,omitempty" yaml:"tags,omitempty"`         // Semantic tags describing node capabilities
 }
 
-// FirewallConfig represents a generated type.
-type FirewallConfig struct {
-	AllowedServices []string              `json:"allowed_services" yaml:"allowed_services"` // Services to allow (ssh, http, https, openvpn, etc.)
-	Enabled         bool                  `json:"enabled" yaml:"enabled"`                   //
-	Provider        FirewallProvider      `json:"provider" yaml:"provider"`                 //
-	DefaultPolicy   FirewallDefaultPolicy `json:"default_policy" yaml:"default_policy"`     //
+// Validate checks NodeMetadata against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *NodeMetadata) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
-// FirewallProvider represents a generated type.
-type FirewallProvider string
+func (x *NodeMetadata) validate(prefix string, errs *ValidationErrors) {
+	x.Hardware.validate(prefix+"hardware.", errs)
+	if x.Hostname == "" {
+		errs.add(prefix+"hostname", "is required")
+	}
+	if x.Purpose == "" {
+		errs.add(prefix+"purpose", "is required")
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
 
-const (
-	FirewallProviderUfw       FirewallProvider = "ufw"
-	FirewallProviderFirewalld FirewallProvider = "firewalld"
-	FirewallProviderIptables  FirewallProvider = "iptables"
-)
+// HardwareInfo represents a generated type.
+type HardwareInfo struct {
+	Architecture Architecture `json:"architecture,omitempty" yaml:"architecture,omitempty"` //
+	CPUCores     int          `json:"cpu_cores,omitempty" yaml:"cpu_cores,omitempty"`       //
+	MemoryGB     int          `json:"memory_gb,omitempty" yaml:"memory_gb,omitempty"`       //
+	Model        string       `json:"model,omitempty" yaml:"model,omitempty"`               // Hardware model
+}
 
-// FirewallDefaultPolicy represents a generated type.
-type FirewallDefaultPolicy struct {
-	Outgoing string `json:"outgoing" yaml:"outgoing"` //
-	Incoming string `json:"incoming" yaml:"incoming"` //
+// Validate checks HardwareInfo against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *HardwareInfo) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
-// IncomingEnum represents a generated type.
-type IncomingEnum string
+func (x *HardwareInfo) validate(prefix string, errs *ValidationErrors) {
+	if x.Architecture != "" && !x.Architecture.Valid() {
+		errs.add(prefix+"architecture", "must be one of x86_64, arm64, armv7, got %q", x.Architecture)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// Architecture represents a generated type.
+type Architecture string
 
 const (
-	IncomingEnumAllow  IncomingEnum = "allow"
-	IncomingEnumDeny   IncomingEnum = "deny"
-	IncomingEnumReject IncomingEnum = "reject"
+	ArchitectureX8664 Architecture = "x86_64"
+	ArchitectureArm64 Architecture = "arm64"
+	ArchitectureArmv7 Architecture = "armv7"
+)
+
+// Valid reports whether v is one of the defined Architecture values
+func (v Architecture) Valid() bool {
+	switch v {
+	case ArchitectureX8664, ArchitectureArm64, ArchitectureArmv7:
+		return true
+	}
+	return false
+}
+
+// NodeRole represents a generated type.
+type NodeRole struct {
+	Config  map[string]interface{} `json:"config,omitempty" yaml:"config,omitempty"` // Role-specific configuration
+	Enabled bool                   `json:"enabled" yaml:"enabled"`                   //
+	Name    RoleName               `json:"name" yaml:"name"`                         // Semantic role identifier
+}
+
+// Validate checks NodeRole against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *NodeRole) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *NodeRole) validate(prefix string, errs *ValidationErrors) {
+	if x.Name == "" {
+		errs.add(prefix+"name", "is required")
+	}
+	if x.Name != "" && !x.Name.Valid() {
+		errs.add(prefix+"name", "must be one of vpn-gateway, container-host, edge-router, monitoring-target, dev-workstation, k8s-node, got %q", x.Name)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// RoleName Semantic role identifier
+type RoleName string
+
+const (
+	RoleNameVpnGateway       RoleName = "vpn-gateway"
+	RoleNameContainerHost    RoleName = "container-host"
+	RoleNameEdgeRouter       RoleName = "edge-router"
+	RoleNameMonitoringTarget RoleName = "monitoring-target"
+	RoleNameDevWorkstation   RoleName = "dev-workstation"
+	RoleNameK8sNode          RoleName = "k8s-node"
+)
+
+// Valid reports whether v is one of the defined RoleName values
+func (v RoleName) Valid() bool {
+	switch v {
+	case RoleNameVpnGateway, RoleNameContainerHost, RoleNameEdgeRouter, RoleNameMonitoringTarget, RoleNameDevWorkstation, RoleNameK8sNode:
+		return true
+	}
+	return false
+}
+
+// SystemTuning System-level kernel parameters (semantic sysctl)
+type SystemTuning struct {
+	InotifyMaxUserWatches int `json:"inotify_max_user_watches,omitempty" yaml:"inotify_max_user_watches,omitempty"` // Maximum inotify watches (fs.inotify.max_user_watches)
+	KernelPanic           int `json:"kernel_panic,omitempty" yaml:"kernel_panic,omitempty"`                         // Seconds to wait before rebooting on panic
+	Swappiness            int `json:"swappiness,omitempty" yaml:"swappiness,omitempty"`                             // VM swappiness (vm.swappiness)
+}
+
+// Validate checks SystemTuning against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *SystemTuning) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *SystemTuning) validate(prefix string, errs *ValidationErrors) {
+	if x.Swappiness != 0 && x.Swappiness < 0 {
+		errs.add(prefix+"swappiness", "must be at least 0, got %d", x.Swappiness)
+	}
+	if x.Swappiness > 100 {
+		errs.add(prefix+"swappiness", "must be at most 100, got %d", x.Swappiness)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// VPNGatewayConfig Configuration for VPN gateway role
+type VPNGatewayConfig struct {
+	Provider     VPNProvider     `json:"provider,omitempty" yaml:"provider,omitempty"`           //
+	Routing      VPNRouting      `json:"routing,omitempty" yaml:"routing,omitempty"`             //
+	ServerConfig VPNServerConfig `json:"server_config,omitempty" yaml:"server_config,omitempty"` //
+}
+
+// Validate checks VPNGatewayConfig against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *VPNGatewayConfig) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *VPNGatewayConfig) validate(prefix string, errs *ValidationErrors) {
+	if x.Provider != "" && !x.Provider.Valid() {
+		errs.add(prefix+"provider", "must be one of openvpn, wireguard, tailscale, got %q", x.Provider)
+	}
+	x.Routing.validate(prefix+"routing.", errs)
+	x.ServerConfig.validate(prefix+"server_config.", errs)
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// VPNProvider represents a generated type.
+type VPNProvider string
+
+const (
+	VPNProviderOpenvpn   VPNProvider = "openvpn"
+	VPNProviderWireguard VPNProvider = "wireguard"
+	VPNProviderTailscale VPNProvider = "tailscale"
+)
+
+// Valid reports whether v is one of the defined VPNProvider values
+func (v VPNProvider) Valid() bool {
+	switch v {
+	case VPNProviderOpenvpn, VPNProviderWireguard, VPNProviderTailscale:
+		return true
+	}
+	return false
+}
+
+// VPNRouting represents a generated type.
+type VPNRouting struct {
+	IPForward  bool       `json:"ip_forward,omitempty" yaml:"ip_forward,omitempty"` // Enable IP forwarding (net.ipv4.ip_forward)
+	Masquerade bool       `json:"masquerade,omitempty" yaml:"masquerade,omitempty"` // Enable NAT masquerading for VPN clients
+	Routes     []VPNRoute `json:"routes,omitempty" yaml:"routes,omitempty"`         //
+}
+
+// Validate checks VPNRouting against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *VPNRouting) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *VPNRouting) validate(prefix string, errs *ValidationErrors) {
+	for i := range x.Routes {
+		x.Routes[i].validate(fmt.Sprintf("%sroutes[%d].", prefix, i), errs)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// VPNRoute represents a generated type.
+type VPNRoute struct {
+	Network string `json:"network,omitempty" yaml:"network,omitempty"` // Destination network
+	Via     string `json:"via,omitempty" yaml:"via,omitempty"`         // Gateway or interface
+}
+
+// Validate checks VPNRoute against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *VPNRoute) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *VPNRoute) validate(prefix string, errs *ValidationErrors) {
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// VPNServerConfig represents a generated type.
+type VPNServerConfig struct {
+	Network  string `json:"network,omitempty" yaml:"network,omitempty"`   // VPN network CIDR
+	Port     int    `json:"port,omitempty" yaml:"port,omitempty"`         //
+	Protocol string `json:"protocol,omitempty" yaml:"protocol,omitempty"` //
+}
+
+var (
+	patternVPNServerConfigNetwork = regexp.MustCompile(`^([0-9]{1,3}\.){3}[0-9]{1,3}/[0-9]{1,2}$`)
+)
+
+// Validate checks VPNServerConfig against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *VPNServerConfig) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *VPNServerConfig) validate(prefix string, errs *ValidationErrors) {
+	if x.Network != "" && !patternVPNServerConfigNetwork.MatchString(string(x.Network)) {
+		errs.add(prefix+"network", "must match %s, got %q", patternVPNServerConfigNetwork, x.Network)
+	}
+	if x.Port != 0 && x.Port < 1 {
+		errs.add(prefix+"port", "must be at least 1, got %d", x.Port)
+	}
+	if x.Port > 65535 {
+		errs.add(prefix+"port", "must be at most 65535, got %d", x.Port)
+	}
+	switch x.Protocol {
+	case "", "udp", "tcp":
+	default:
+		errs.add(prefix+"protocol", "must be one of udp, tcp, got %q", x.Protocol)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// State represents a generated type.
+type State struct {
+	DNS           DNSConfig          `json:"dns,omitempty" yaml:"dns,omitempty"`                       // Resolver configuration applied via systemd-resolved, NetworkManager or resolv.conf
+	Files         []FileConfig       `json:"files,omitempty" yaml:"files,omitempty"`                   //
+	Firewall      FirewallConfig     `json:"firewall,omitempty" yaml:"firewall,omitempty"`             //
+	Freeze        FreezeConfig       `json:"freeze,omitempty" yaml:"freeze,omitempty"`                 // Change freeze - while active, enforcement is downgraded to dry-run
+	Hooks         HooksConfig        `json:"hooks,omitempty" yaml:"hooks,omitempty"`                   // Commands run around each enforce-mode reconciliation pass
+	Metadata      Metadata           `json:"metadata" yaml:"metadata"`                                 //
+	PackagePolicy PackagePolicy      `json:"package_policy,omitempty" yaml:"package_policy,omitempty"` // When the package index is refreshed before packages are installed
+	Packages      []PackageConfig    `json:"packages,omitempty" yaml:"packages,omitempty"`             //
+	Reconcile     ReconcileOverrides `json:"reconcile,omitempty" yaml:"reconcile,omitempty"`           // Per-resource-type reconcile mode; unset types follow the agent's global mode
+	Repositories  []RepoConfig       `json:"repositories,omitempty" yaml:"repositories,omitempty"`     // Package repositories configured before packages are reconciled
+	Services      []ServiceConfig    `json:"services,omitempty" yaml:"services,omitempty"`             //
+	Sysctl        map[string]string  `json:"sysctl,omitempty" yaml:"sysctl,omitempty"`                 //
+	SysctlPolicy  SysctlPolicy       `json:"sysctl_policy,omitempty" yaml:"sysctl_policy,omitempty"`   // How sysctl keys are compared, and how keys missing from the running kernel are handled
+	Version       Version            `json:"version" yaml:"version"`                                   //
+}
+
+var (
+	patternStateVersion = regexp.MustCompile(`^[0-9]+\.[0-9]+$`)
+)
+
+// Validate checks State against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *State) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *State) validate(prefix string, errs *ValidationErrors) {
+	x.DNS.validate(prefix+"dns.", errs)
+	for i := range x.Files {
+		x.Files[i].validate(fmt.Sprintf("%sfiles[%d].", prefix, i), errs)
+	}
+	x.Firewall.validate(prefix+"firewall.", errs)
+	x.Freeze.validate(prefix+"freeze.", errs)
+	x.Hooks.validate(prefix+"hooks.", errs)
+	x.Metadata.validate(prefix+"metadata.", errs)
+	x.PackagePolicy.validate(prefix+"package_policy.", errs)
+	for i := range x.Packages {
+		x.Packages[i].validate(fmt.Sprintf("%spackages[%d].", prefix, i), errs)
+	}
+	x.Reconcile.validate(prefix+"reconcile.", errs)
+	for i := range x.Repositories {
+		x.Repositories[i].validate(fmt.Sprintf("%srepositories[%d].", prefix, i), errs)
+	}
+	for i := range x.Services {
+		x.Services[i].validate(fmt.Sprintf("%sservices[%d].", prefix, i), errs)
+	}
+	x.SysctlPolicy.validate(prefix+"sysctl_policy.", errs)
+	if x.Version == "" {
+		errs.add(prefix+"version", "is required")
+	}
+	if x.Version != "" && !patternStateVersion.MatchString(string(x.Version)) {
+		errs.add(prefix+"version", "must match %s, got %q", patternStateVersion, x.Version)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// DNSConfig Resolver configuration applied via systemd-resolved, NetworkManager or resolv.conf
+type DNSConfig struct {
+	Nameservers []string `json:"nameservers,omitempty" yaml:"nameservers,omitempty"` // Nameserver addresses in priority order
+	Search      []string `json:"search,omitempty" yaml:"search,omitempty"`           // Search domains
+}
+
+// Validate checks DNSConfig against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *DNSConfig) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *DNSConfig) validate(prefix string, errs *ValidationErrors) {
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// FileConfig represents a generated type.
+type FileConfig struct {
+	Backup         bool      `json:"backup,omitempty" yaml:"backup,omitempty"`                   // Copy the existing file to <path>.bak before overwriting
+	Content        string    `json:"content,omitempty" yaml:"content,omitempty"`                 // Desired file content
+	DependsOn      []string  `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`           // Resources reconciled before this one, as type:name (service, package, file, repository or sysctl), e.g. package:nginx
+	DirMode        string    `json:"dir_mode,omitempty" yaml:"dir_mode,omitempty"`               // Mode for parent directories created on demand
+	Group          string    `json:"group,omitempty" yaml:"group,omitempty"`                     //
+	Interpolate    bool      `json:"interpolate,omitempty" yaml:"interpolate,omitempty"`         // Resolve ${ENV_VAR} and ${file:/path} references in the content on the agent
+	Mode           string    `json:"mode,omitempty" yaml:"mode,omitempty"`                       //
+	Notify         []string  `json:"notify,omitempty" yaml:"notify,omitempty"`                   // Services restarted once at the end of an enforce pass when this resource changed
+	Owner          string    `json:"owner,omitempty" yaml:"owner,omitempty"`                     //
+	Path           UnixPath  `json:"path" yaml:"path"`                                           //
+	SELinuxContext string    `json:"selinux_context,omitempty" yaml:"selinux_context,omitempty"` // SELinux context (user:role:type:level, or just a type) kept on the file; unset relabels written files with restorecon. Ignored without SELinux
+	SHA256         string    `json:"sha256,omitempty" yaml:"sha256,omitempty"`                   // Expected SHA256 hash
+	Source         string    `json:"source,omitempty" yaml:"source,omitempty"`                   // Fetch content from a file:// or https:// URL instead of inline content
+	SourceTimeout  int       `json:"source_timeout,omitempty" yaml:"source_timeout,omitempty"`   // Timeout in seconds for fetching https sources
+	State          FileState `json:"state,omitempty" yaml:"state,omitempty"`                     // Whether the file should exist
+	Target         string    `json:"target,omitempty" yaml:"target,omitempty"`                   // Link target when type is symlink
+	Template       bool      `json:"template,omitempty" yaml:"template,omitempty"`               // Render content as a Go text/template with node metadata
+	Transactional  bool      `json:"transactional,omitempty" yaml:"transactional,omitempty"`     // Restore the previous content, mode and ownership of a regular file if any step of applying it fails
+	Type           FileType  `json:"type,omitempty" yaml:"type,omitempty"`                       // Kind of filesystem entry to manage
+	ValidateCmd    string    `json:"validate,omitempty" yaml:"validate,omitempty"`               // Command checking new content before it is written, with %s standing for a temporary file holding it, e.g. "nginx -t -c %s"; a non-zero exit aborts the write
+}
+
+var (
+	patternFileConfigDirMode = regexp.MustCompile(`^(0[oO])?[0-7]{3,4}$`)
+	patternFileConfigMode    = regexp.MustCompile(`^(0[oO])?[0-7]{3,4}$`)
+	patternFileConfigPath    = regexp.MustCompile(`^/`)
+	patternFileConfigSHA256  = regexp.MustCompile(`^[a-f0-9]{64}$`)
+	patternFileConfigSource  = regexp.MustCompile(`^(file|https)://`)
 )
 
-// OutgoingEnum represents a generated type.
-type OutgoingEnum string
+// Validate checks FileConfig against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *FileConfig) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *FileConfig) validate(prefix string, errs *ValidationErrors) {
+	if x.DirMode != "" && !patternFileConfigDirMode.MatchString(string(x.DirMode)) {
+		errs.add(prefix+"dir_mode", "must match %s, got %q", patternFileConfigDirMode, x.DirMode)
+	}
+	if x.Mode != "" && !patternFileConfigMode.MatchString(string(x.Mode)) {
+		errs.add(prefix+"mode", "must match %s, got %q", patternFileConfigMode, x.Mode)
+	}
+	if x.Path == "" {
+		errs.add(prefix+"path", "is required")
+	}
+	if x.Path != "" && !patternFileConfigPath.MatchString(string(x.Path)) {
+		errs.add(prefix+"path", "must match %s, got %q", patternFileConfigPath, x.Path)
+	}
+	if x.SHA256 != "" && !patternFileConfigSHA256.MatchString(string(x.SHA256)) {
+		errs.add(prefix+"sha256", "must match %s, got %q", patternFileConfigSHA256, x.SHA256)
+	}
+	if x.Source != "" && !patternFileConfigSource.MatchString(string(x.Source)) {
+		errs.add(prefix+"source", "must match %s, got %q", patternFileConfigSource, x.Source)
+	}
+	if x.SourceTimeout != 0 && x.SourceTimeout < 1 {
+		errs.add(prefix+"source_timeout", "must be at least 1, got %d", x.SourceTimeout)
+	}
+	if x.State != "" && !x.State.Valid() {
+		errs.add(prefix+"state", "must be one of present, absent, got %q", x.State)
+	}
+	if x.Type != "" && !x.Type.Valid() {
+		errs.add(prefix+"type", "must be one of file, directory, symlink, got %q", x.Type)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// FileState Whether the file should exist
+type FileState string
+
+const (
+	FileStatePresent FileState = "present"
+	FileStateAbsent  FileState = "absent"
+)
+
+// Valid reports whether v is one of the defined FileState values
+func (v FileState) Valid() bool {
+	switch v {
+	case FileStatePresent, FileStateAbsent:
+		return true
+	}
+	return false
+}
+
+// FileType Kind of filesystem entry to manage
+type FileType string
+
+const (
+	FileTypeFile      FileType = "file"
+	FileTypeDirectory FileType = "directory"
+	FileTypeSymlink   FileType = "symlink"
+)
+
+// Valid reports whether v is one of the defined FileType values
+func (v FileType) Valid() bool {
+	switch v {
+	case FileTypeFile, FileTypeDirectory, FileTypeSymlink:
+		return true
+	}
+	return false
+}
+
+// FirewallAction represents a generated type.
+type FirewallAction string
+
+const (
+	FirewallActionAllow  FirewallAction = "allow"
+	FirewallActionDeny   FirewallAction = "deny"
+	FirewallActionReject FirewallAction = "reject"
+)
+
+// Valid reports whether v is one of the defined FirewallAction values
+func (v FirewallAction) Valid() bool {
+	switch v {
+	case FirewallActionAllow, FirewallActionDeny, FirewallActionReject:
+		return true
+	}
+	return false
+}
+
+// DefaultOutgoingEnum represents a generated type.
+type DefaultOutgoingEnum string
+
+const (
+	DefaultOutgoingEnumAllow  DefaultOutgoingEnum = "allow"
+	DefaultOutgoingEnumDeny   DefaultOutgoingEnum = "deny"
+	DefaultOutgoingEnumReject DefaultOutgoingEnum = "reject"
+)
+
+// Valid reports whether v is one of the defined DefaultOutgoingEnum values
+func (v DefaultOutgoingEnum) Valid() bool {
+	switch v {
+	case DefaultOutgoingEnumAllow, DefaultOutgoingEnumDeny, DefaultOutgoingEnumReject:
+		return true
+	}
+	return false
+}
+
+// FirewallRule represents a generated type.
+type FirewallRule struct {
+	Action  string   `json:"action" yaml:"action"`                       //
+	Comment string   `json:"comment,omitempty" yaml:"comment,omitempty"` //
+	From    string   `json:"from,omitempty" yaml:"from,omitempty"`       //
+	Port    Port     `json:"port" yaml:"port"`                           //
+	Proto   Protocol `json:"proto" yaml:"proto"`                         //
+	To      string   `json:"to,omitempty" yaml:"to,omitempty"`           //
+}
+
+// Validate checks FirewallRule against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *FirewallRule) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *FirewallRule) validate(prefix string, errs *ValidationErrors) {
+	if x.Action == "" {
+		errs.add(prefix+"action", "is required")
+	}
+	switch x.Action {
+	case "", "allow", "deny", "reject":
+	default:
+		errs.add(prefix+"action", "must be one of allow, deny, reject, got %q", x.Action)
+	}
+	if x.Port < 1 {
+		errs.add(prefix+"port", "must be at least 1, got %d", x.Port)
+	}
+	if x.Port > 65535 {
+		errs.add(prefix+"port", "must be at most 65535, got %d", x.Port)
+	}
+	if x.Proto == "" {
+		errs.add(prefix+"proto", "is required")
+	}
+	if x.Proto != "" && !x.Proto.Valid() {
+		errs.add(prefix+"proto", "must be one of tcp, udp, icmp, got %q", x.Proto)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// ActionEnum represents a generated type.
+type ActionEnum string
+
+const (
+	ActionEnumAllow  ActionEnum = "allow"
+	ActionEnumDeny   ActionEnum = "deny"
+	ActionEnumReject ActionEnum = "reject"
+)
+
+// Valid reports whether v is one of the defined ActionEnum values
+func (v ActionEnum) Valid() bool {
+	switch v {
+	case ActionEnumAllow, ActionEnumDeny, ActionEnumReject:
+		return true
+	}
+	return false
+}
+
+// FreezeConfig Change freeze - while active, enforcement is downgraded to dry-run
+type FreezeConfig struct {
+	Enabled bool   `json:"enabled,omitempty" yaml:"enabled,omitempty"` // Whether the freeze is in effect
+	Reason  string `json:"reason,omitempty" yaml:"reason,omitempty"`   // Why changes are frozen, shown in status
+	Until   string `json:"until,omitempty" yaml:"until,omitempty"`     // When the freeze ends (RFC 3339); unset freezes until disabled
+}
+
+// Validate checks FreezeConfig against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *FreezeConfig) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *FreezeConfig) validate(prefix string, errs *ValidationErrors) {
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// HooksConfig Commands run around each enforce-mode reconciliation pass
+type HooksConfig struct {
+	Post    string `json:"post,omitempty" yaml:"post,omitempty"`       // Shell command run after enforcing, even if some resources failed
+	Pre     string `json:"pre,omitempty" yaml:"pre,omitempty"`         // Shell command run before enforcing; a failure aborts the pass
+	Timeout int    `json:"timeout,omitempty" yaml:"timeout,omitempty"` // Timeout in seconds for each hook
+}
+
+// Validate checks HooksConfig against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *HooksConfig) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *HooksConfig) validate(prefix string, errs *ValidationErrors) {
+	if x.Timeout != 0 && x.Timeout < 1 {
+		errs.add(prefix+"timeout", "must be at least 1, got %d", x.Timeout)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// PackagePolicy When the package index is refreshed before packages are installed
+type PackagePolicy struct {
+	CacheMaxAge  int                 `json:"cache_max_age,omitempty" yaml:"cache_max_age,omitempty"` // Seconds after which the apt index counts as stale for if-stale
+	CacheRefresh PackageCacheRefresh `json:"cache_refresh,omitempty" yaml:"cache_refresh,omitempty"` // Run apt-get update once per pass before the first install or upgrade, always or only when the index is older than cache_max_age; never leaves it to the administrator
+}
+
+// Validate checks PackagePolicy against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *PackagePolicy) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *PackagePolicy) validate(prefix string, errs *ValidationErrors) {
+	if x.CacheMaxAge != 0 && x.CacheMaxAge < 0 {
+		errs.add(prefix+"cache_max_age", "must be at least 0, got %d", x.CacheMaxAge)
+	}
+	if x.CacheRefresh != "" && !x.CacheRefresh.Valid() {
+		errs.add(prefix+"cache_refresh", "must be one of always, if-stale, never, got %q", x.CacheRefresh)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// PackageCacheRefresh Run apt-get update once per pass before the first install or upgrade, always or only when the index is older than cache_max_age; never leaves it to the administrator
+type PackageCacheRefresh string
+
+const (
+	PackageCacheRefreshAlways  PackageCacheRefresh = "always"
+	PackageCacheRefreshIfStale PackageCacheRefresh = "if-stale"
+	PackageCacheRefreshNever   PackageCacheRefresh = "never"
+)
+
+// Valid reports whether v is one of the defined PackageCacheRefresh values
+func (v PackageCacheRefresh) Valid() bool {
+	switch v {
+	case PackageCacheRefreshAlways, PackageCacheRefreshIfStale, PackageCacheRefreshNever:
+		return true
+	}
+	return false
+}
+
+// PackageConfig represents a generated type.
+type PackageConfig struct {
+	DependsOn []string     `json:"depends_on,omitempty" yaml:"depends_on,omitempty"` // Resources reconciled before this one, as type:name (service, package, file, repository or sysctl), e.g. package:nginx
+	Hold      bool         `json:"hold,omitempty" yaml:"hold,omitempty"`             // Pin the installed version (apt-mark hold / dnf versionlock)
+	Name      string       `json:"name" yaml:"name"`                                 //
+	Notify    []string     `json:"notify,omitempty" yaml:"notify,omitempty"`         // Services restarted once at the end of an enforce pass when this resource changed
+	State     PackageState `json:"state,omitempty" yaml:"state,omitempty"`           //
+	Version   string       `json:"version,omitempty" yaml:"version,omitempty"`       // Desired version (empty means any)
+}
+
+// Validate checks PackageConfig against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *PackageConfig) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *PackageConfig) validate(prefix string, errs *ValidationErrors) {
+	if x.Name == "" {
+		errs.add(prefix+"name", "is required")
+	}
+	if x.State != "" && !x.State.Valid() {
+		errs.add(prefix+"state", "must be one of present, absent, latest, got %q", x.State)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// PackageState represents a generated type.
+type PackageState string
+
+const (
+	PackageStatePresent PackageState = "present"
+	PackageStateAbsent  PackageState = "absent"
+	PackageStateLatest  PackageState = "latest"
+)
+
+// Valid reports whether v is one of the defined PackageState values
+func (v PackageState) Valid() bool {
+	switch v {
+	case PackageStatePresent, PackageStateAbsent, PackageStateLatest:
+		return true
+	}
+	return false
+}
+
+// ReconcileOverrides Per-resource-type reconcile mode; unset types follow the agent's global mode
+type ReconcileOverrides struct {
+	DNS      ReconcileMode `json:"dns,omitempty" yaml:"dns,omitempty"`           //
+	Files    ReconcileMode `json:"files,omitempty" yaml:"files,omitempty"`       //
+	Firewall ReconcileMode `json:"firewall,omitempty" yaml:"firewall,omitempty"` //
+	Packages ReconcileMode `json:"packages,omitempty" yaml:"packages,omitempty"` //
+	Services ReconcileMode `json:"services,omitempty" yaml:"services,omitempty"` //
+	Sysctl   ReconcileMode `json:"sysctl,omitempty" yaml:"sysctl,omitempty"`     //
+}
+
+// Validate checks ReconcileOverrides against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *ReconcileOverrides) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *ReconcileOverrides) validate(prefix string, errs *ValidationErrors) {
+	if x.DNS != "" && !x.DNS.Valid() {
+		errs.add(prefix+"dns", "must be one of disabled, dry-run, enforce, got %q", x.DNS)
+	}
+	if x.Files != "" && !x.Files.Valid() {
+		errs.add(prefix+"files", "must be one of disabled, dry-run, enforce, got %q", x.Files)
+	}
+	if x.Firewall != "" && !x.Firewall.Valid() {
+		errs.add(prefix+"firewall", "must be one of disabled, dry-run, enforce, got %q", x.Firewall)
+	}
+	if x.Packages != "" && !x.Packages.Valid() {
+		errs.add(prefix+"packages", "must be one of disabled, dry-run, enforce, got %q", x.Packages)
+	}
+	if x.Services != "" && !x.Services.Valid() {
+		errs.add(prefix+"services", "must be one of disabled, dry-run, enforce, got %q", x.Services)
+	}
+	if x.Sysctl != "" && !x.Sysctl.Valid() {
+		errs.add(prefix+"sysctl", "must be one of disabled, dry-run, enforce, got %q", x.Sysctl)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// RepoConfig represents a generated type.
+type RepoConfig struct {
+	Components  []string `json:"components,omitempty" yaml:"components,omitempty"`   // apt components, e.g. main
+	DependsOn   []string `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`   // Resources reconciled before this one, as type:name (service, package, file, repository or sysctl), e.g. package:nginx
+	Interpolate bool     `json:"interpolate,omitempty" yaml:"interpolate,omitempty"` // Resolve ${ENV_VAR} and ${file:/path} references in the repository definition and inline key on the agent
+	Key         string   `json:"key,omitempty" yaml:"key,omitempty"`                 // Inline ASCII-armored signing key
+	KeySHA256   string   `json:"key_sha256,omitempty" yaml:"key_sha256,omitempty"`   // Expected SHA256 hash of the key fetched from key_url
+	KeyURL      string   `json:"key_url,omitempty" yaml:"key_url,omitempty"`         // Fetch the signing key from an https:// URL instead of inline key
+	Mode        string   `json:"mode,omitempty" yaml:"mode,omitempty"`               // Mode of the source and key files (default 0644, or 0600 with interpolate)
+	Name        string   `json:"name" yaml:"name"`                                   // Repository id, also used to name its source and keyring files
+	Suite       string   `json:"suite,omitempty" yaml:"suite,omitempty"`             // apt suite, e.g. jammy or stable
+	URL         string   `json:"url" yaml:"url"`                                     // Repository base URL (apt URIs, dnf baseurl)
+}
+
+var (
+	patternRepoConfigKeySHA256 = regexp.MustCompile(`^[a-f0-9]{64}$`)
+	patternRepoConfigKeyURL    = regexp.MustCompile(`^https://`)
+	patternRepoConfigMode      = regexp.MustCompile(`^(0[oO])?[0-7]{3,4}$`)
+	patternRepoConfigName      = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	patternRepoConfigURL       = regexp.MustCompile(`^(https?|file)://`)
+)
+
+// Validate checks RepoConfig against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *RepoConfig) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *RepoConfig) validate(prefix string, errs *ValidationErrors) {
+	if x.KeySHA256 != "" && !patternRepoConfigKeySHA256.MatchString(string(x.KeySHA256)) {
+		errs.add(prefix+"key_sha256", "must match %s, got %q", patternRepoConfigKeySHA256, x.KeySHA256)
+	}
+	if x.KeyURL != "" && !patternRepoConfigKeyURL.MatchString(string(x.KeyURL)) {
+		errs.add(prefix+"key_url", "must match %s, got %q", patternRepoConfigKeyURL, x.KeyURL)
+	}
+	if x.Mode != "" && !patternRepoConfigMode.MatchString(string(x.Mode)) {
+		errs.add(prefix+"mode", "must match %s, got %q", patternRepoConfigMode, x.Mode)
+	}
+	if x.Name == "" {
+		errs.add(prefix+"name", "is required")
+	}
+	if x.Name != "" && !patternRepoConfigName.MatchString(string(x.Name)) {
+		errs.add(prefix+"name", "must match %s, got %q", patternRepoConfigName, x.Name)
+	}
+	if x.URL == "" {
+		errs.add(prefix+"url", "is required")
+	}
+	if x.URL != "" && !patternRepoConfigURL.MatchString(string(x.URL)) {
+		errs.add(prefix+"url", "must match %s, got %q", patternRepoConfigURL, x.URL)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// ServiceConfig represents a generated type.
+type ServiceConfig struct {
+	AllowVendorUnit bool         `json:"allow_vendor_unit,omitempty" yaml:"allow_vendor_unit,omitempty"` // Let state absent remove a unit file outside /etc/systemd/system, e.g. one shipped by a package
+	DependsOn       []string     `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`               // Resources reconciled before this one, as type:name (service, package, file, repository or sysctl), e.g. package:nginx
+	Enabled         bool         `json:"enabled,omitempty" yaml:"enabled,omitempty"`                     //
+	Name            string       `json:"name" yaml:"name"`                                               // Service name (without .service suffix)
+	State           ServiceState `json:"state" yaml:"state"`                                             //
+}
+
+// Validate checks ServiceConfig against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *ServiceConfig) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *ServiceConfig) validate(prefix string, errs *ValidationErrors) {
+	if x.Name == "" {
+		errs.add(prefix+"name", "is required")
+	}
+	if x.State == "" {
+		errs.add(prefix+"state", "is required")
+	}
+	if x.State != "" && !x.State.Valid() {
+		errs.add(prefix+"state", "must be one of running, stopped, disabled, absent, got %q", x.State)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// SysctlPolicy How sysctl keys are compared, and how keys missing from the running kernel are handled
+type SysctlPolicy struct {
+	Modules     map[string]string `json:"modules,omitempty" yaml:"modules,omitempty"`           // Kernel module to load when a key under a prefix is missing, e.g. net.bridge. → br_netfilter
+	UnknownKeys SysctlUnknownKeys `json:"unknown_keys,omitempty" yaml:"unknown_keys,omitempty"` // Report a missing key as skipped, or fail the resource
+	Unordered   []string          `json:"unordered,omitempty" yaml:"unordered,omitempty"`       // List-valued keys whose values compare as sets, ignoring order
+}
+
+// Validate checks SysctlPolicy against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *SysctlPolicy) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *SysctlPolicy) validate(prefix string, errs *ValidationErrors) {
+	if x.UnknownKeys != "" && !x.UnknownKeys.Valid() {
+		errs.add(prefix+"unknown_keys", "must be one of skip, error, got %q", x.UnknownKeys)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// SysctlUnknownKeys Report a missing key as skipped, or fail the resource
+type SysctlUnknownKeys string
 
 const (
-	OutgoingEnumAllow  OutgoingEnum = "allow"
-	OutgoingEnumDeny   OutgoingEnum = "deny"
-	OutgoingEnumReject OutgoingEnum = "reject"
+	SysctlUnknownKeysSkip  SysctlUnknownKeys = "skip"
+	SysctlUnknownKeysError SysctlUnknownKeys = "error"
 )
 
-// State represents a generated type.
-type State struct {
-	Files    []FileConfig      `json:"files" yaml:"files"`       //
-	Version  Version           `json:"version" yaml:"version"`   //
-	Metadata Metadata          `json:"metadata" yaml:"metadata"` //
-	Firewall FirewallConfig    `json:"firewall" yaml:"firewall"` //
-	Services []ServiceConfig   `json:"services" yaml:"services"` //
-	Sysctl   map[string]string `json:"sysctl" yaml:"sysctl"`     //
-	Packages []PackageConfig   `json:"packages" yaml:"packages"` //
+// Valid reports whether v is one of the defined SysctlUnknownKeys values
+func (v SysctlUnknownKeys) Valid() bool {
+	switch v {
+	case SysctlUnknownKeysSkip, SysctlUnknownKeysError:
+		return true
+	}
+	return false
 }
 
-// FirewallAction represents a generated type.
-type FirewallAction string
+// SystemIdentity Immutable system identifiers for node registration and validation
+type SystemIdentity struct {
+	CompositeKey CompositeKey       `json:"composite_key,omitempty" yaml:"composite_key,omitempty"` // Composite identifier for database indexing
+	Identifiers  SystemIdentifiers  `json:"identifiers" yaml:"identifiers"`                         // Platform-specific immutable identifiers
+	Platform     PlatformInfo       `json:"platform" yaml:"platform"`                               //
+	Registration NodeRegistration   `json:"registration,omitempty" yaml:"registration,omitempty"`   // Controller registration metadata
+	Validation   IdentityValidation `json:"validation,omitempty" yaml:"validation,omitempty"`       // Identity validation configuration
+}
 
-const (
-	FirewallActionAllow  FirewallAction = "allow"
-	FirewallActionDeny   FirewallAction = "deny"
-	FirewallActionReject FirewallAction = "reject"
-)
+// Validate checks SystemIdentity against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *SystemIdentity) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
 
-// DefaultOutgoingEnum represents a generated type.
-type DefaultOutgoingEnum string
+func (x *SystemIdentity) validate(prefix string, errs *ValidationErrors) {
+	if !reflect.ValueOf(x.CompositeKey).IsZero() {
+		x.CompositeKey.validate(prefix+"composite_key.", errs)
+	}
+	x.Identifiers.validate(prefix+"identifiers.", errs)
+	x.Platform.validate(prefix+"platform.", errs)
+	x.Registration.validate(prefix+"registration.", errs)
+	x.Validation.validate(prefix+"validation.", errs)
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
 
-const (
-	DefaultOutgoingEnumAllow  DefaultOutgoingEnum = "allow"
-	DefaultOutgoingEnumDeny   DefaultOutgoingEnum = "deny"
-	DefaultOutgoingEnumReject DefaultOutgoingEnum = "reject"
+// CompositeKey Composite identifier for database indexing
+type CompositeKey struct {
+	Components []KeyComponent `json:"components" yaml:"components"`         //
+	Hash       string         `json:"hash,omitempty" yaml:"hash,omitempty"` // SHA256 hash of composite key for indexing
+	Key        string         `json:"key" yaml:"key"`                       // Concatenated composite key (os_type:primary_id)
+}
+
+var (
+	patternCompositeKeyHash = regexp.MustCompile(`^[a-f0-9]{64}$`)
 )
 
-// FirewallRule represents a generated type.
-type FirewallRule struct {
-	To      string   `json:"to" yaml:"to"`           //
-	Comment string   `json:"comment" yaml:"comment"` //
-	Port    Port     `json:"port" yaml:"port"`       //
-	Proto   Protocol `json:"proto" yaml:"proto"`     //
-	Action  string   `json:"action" yaml:"action"`   //
-	From    string   `json:"from" yaml:"from"`       //
+// Validate checks CompositeKey against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *CompositeKey) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
-// ActionEnum represents a generated type.
-type ActionEnum string
+func (x *CompositeKey) validate(prefix string, errs *ValidationErrors) {
+	if len(x.Components) == 0 {
+		errs.add(prefix+"components", "is required")
+	}
+	for i := range x.Components {
+		x.Components[i].validate(fmt.Sprintf("%scomponents[%d].", prefix, i), errs)
+	}
+	if x.Hash != "" && !patternCompositeKeyHash.MatchString(string(x.Hash)) {
+		errs.add(prefix+"hash", "must match %s, got %q", patternCompositeKeyHash, x.Hash)
+	}
+	if x.Key == "" {
+		errs.add(prefix+"key", "is required")
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
 
-const (
-	ActionEnumAllow  ActionEnum = "allow"
-	ActionEnumDeny   ActionEnum = "deny"
-	ActionEnumReject ActionEnum = "reject"
-)
+// KeyComponent represents a generated type.
+type KeyComponent struct {
+	Name  string `json:"name,omitempty" yaml:"name,omitempty"`   // Component name (os_type, machine_id, etc.)
+	Value string `json:"value,omitempty" yaml:"value,omitempty"` // Component value
+}
 
-// ServiceConfig represents a generated type.
-type ServiceConfig struct {
-	Enabled bool         `json:"enabled" yaml:"enabled"` //
-	Name    string       `json:"name" yaml:"name"`       // Service name (without .service suffix)
-	State   ServiceState `json:"state" yaml:"state"`     //
+// Validate checks KeyComponent against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *KeyComponent) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
-// PackageConfig represents a generated type.
-type PackageConfig struct {
-	Name    string       `json:"name" yaml:"name"`       //
-	Version string       `json:"version" yaml:"version"` // Desired version (empty means any)
-	State   PackageState `json:"state" yaml:"state"`     //
+func (x *KeyComponent) validate(prefix string, errs *ValidationErrors) {
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
 }
 
-// PackageState represents a generated type.
-type PackageState string
+// SystemIdentifiers Platform-specific immutable identifiers
+type SystemIdentifiers struct {
+	CollectedAt    string         `json:"collected_at,omitempty" yaml:"collected_at,omitempty"`         // When these identifiers were collected
+	CollectedBy    string         `json:"collected_by,omitempty" yaml:"collected_by,omitempty"`         // How identifiers were collected (probe script version)
+	DMIBoardSerial string         `json:"dmi_board_serial,omitempty" yaml:"dmi_board_serial,omitempty"` // Motherboard serial number
+	DMIProductUUID string         `json:"dmi_product_uuid,omitempty" yaml:"dmi_product_uuid,omitempty"` // DMI/SMBIOS product UUID from /sys/class/dmi/id/product_uuid
+	HardwareUUID   string         `json:"hardware_uuid,omitempty" yaml:"hardware_uuid,omitempty"`       // macOS Hardware UUID
+	IDType         IdentifierType `json:"id_type" yaml:"id_type"`                                       // Type of primary identifier
+	IOPlatformUUID string         `json:"io_platform_uuid,omitempty" yaml:"io_platform_uuid,omitempty"` // macOS IOPlatformUUID from I/O Registry
+	MachineID      string         `json:"machine_id,omitempty" yaml:"machine_id,omitempty"`             // systemd machine-id from /etc/machine-id
+	PrimaryID      string         `json:"primary_id" yaml:"primary_id"`                                 // Primary system identifier (machine-id or hardware UUID)
+}
 
-const (
-	PackageStatePresent PackageState = "present"
-	PackageStateAbsent  PackageState = "absent"
-	PackageStateLatest  PackageState = "latest"
+var (
+	patternSystemIdentifiersDMIProductUUID = regexp.MustCompile(`^[A-F0-9]{8}-([A-F0-9]{4}-){3}[A-F0-9]{12}$`)
+	patternSystemIdentifiersHardwareUUID   = regexp.MustCompile(`^[A-F0-9]{8}-([A-F0-9]{4}-){3}[A-F0-9]{12}$`)
+	patternSystemIdentifiersIOPlatformUUID = regexp.MustCompile(`^[A-F0-9]{8}-([A-F0-9]{4}-){3}[A-F0-9]{12}$`)
+	patternSystemIdentifiersMachineID      = regexp.MustCompile(`^[a-f0-9]{32}$`)
 )
 
-// FileConfig represents a generated type.
-type FileConfig struct {
-	Path    UnixPath `json:"path" yaml:"path"`       //
-	Content string   `json:"content" yaml:"content"` // Desired file content
-	SHA256  string   `json:"sha256" yaml:"sha256"`   // Expected SHA256 hash
-	Mode    string   `json:"mode" yaml:"mode"`       //
-	Owner   string   `json:"owner" yaml:"owner"`     //
-	Group   string   `json:"group" yaml:"group"`     //
+// Validate checks SystemIdentifiers against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *SystemIdentifiers) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
-// SystemIdentity Immutable system identifiers for node registration and validation
-type SystemIdentity struct {
-	Validation   IdentityValidation `json:"validation" yaml:"validation"`       // Identity validation configuration
-	Registration NodeRegistration   `json:"registration" yaml:"registration"`   // Controller registration metadata
-	Platform     PlatformInfo       `json:"platform" yaml:"platform"`           //
-	Identifiers  SystemIdentifiers  `json:"identifiers" yaml:"identifiers"`     // Platform-specific immutable identifiers
-	CompositeKey CompositeKey       `json:"composite_key" yaml:"composite_key"` // Composite identifier for database indexing
+func (x *SystemIdentifiers) validate(prefix string, errs *ValidationErrors) {
+	if x.DMIProductUUID != "" && !patternSystemIdentifiersDMIProductUUID.MatchString(string(x.DMIProductUUID)) {
+		errs.add(prefix+"dmi_product_uuid", "must match %s, got %q", patternSystemIdentifiersDMIProductUUID, x.DMIProductUUID)
+	}
+	if x.HardwareUUID != "" && !patternSystemIdentifiersHardwareUUID.MatchString(string(x.HardwareUUID)) {
+		errs.add(prefix+"hardware_uuid", "must match %s, got %q", patternSystemIdentifiersHardwareUUID, x.HardwareUUID)
+	}
+	if x.IDType == "" {
+		errs.add(prefix+"id_type", "is required")
+	}
+	if x.IDType != "" && !x.IDType.Valid() {
+		errs.add(prefix+"id_type", "must be one of machine-id, hardware-uuid, io-platform-uuid, product-uuid, got %q", x.IDType)
+	}
+	if x.IOPlatformUUID != "" && !patternSystemIdentifiersIOPlatformUUID.MatchString(string(x.IOPlatformUUID)) {
+		errs.add(prefix+"io_platform_uuid", "must match %s, got %q", patternSystemIdentifiersIOPlatformUUID, x.IOPlatformUUID)
+	}
+	if x.MachineID != "" && !patternSystemIdentifiersMachineID.MatchString(string(x.MachineID)) {
+		errs.add(prefix+"machine_id", "must match %s, got %q", patternSystemIdentifiersMachineID, x.MachineID)
+	}
+	if x.PrimaryID == "" {
+		errs.add(prefix+"primary_id", "is required")
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// IdentifierType Type of primary identifier
+type IdentifierType string
+
+const (
+	IdentifierTypeMachineId      IdentifierType = "machine-id"
+	IdentifierTypeHardwareUuid   IdentifierType = "hardware-uuid"
+	IdentifierTypeIoPlatformUuid IdentifierType = "io-platform-uuid"
+	IdentifierTypeProductUuid    IdentifierType = "product-uuid"
+)
+
+// Valid reports whether v is one of the defined IdentifierType values
+func (v IdentifierType) Valid() bool {
+	switch v {
+	case IdentifierTypeMachineId, IdentifierTypeHardwareUuid, IdentifierTypeIoPlatformUuid, IdentifierTypeProductUuid:
+		return true
+	}
+	return false
 }
 
 // PlatformInfo represents a generated type.
 type PlatformInfo struct {
-	OSType        OSType   `json:"os_type" yaml:"os_type"`               // Operating system type
-	OSFamily      OSFamily `json:"os_family" yaml:"os_family"`           // OS distribution family
-	OSVersion     string   `json:"os_version" yaml:"os_version"`         // OS version string
-	KernelVersion string   `json:"kernel_version" yaml:"kernel_version"` // Kernel version
+	KernelVersion string   `json:"kernel_version,omitempty" yaml:"kernel_version,omitempty"` // Kernel version
+	OSFamily      OSFamily `json:"os_family" yaml:"os_family"`                               // OS distribution family
+	OSType        OSType   `json:"os_type" yaml:"os_type"`                                   // Operating system type
+	OSVersion     string   `json:"os_version,omitempty" yaml:"os_version,omitempty"`         // OS version string
 }
 
-// OSType Operating system type
-type OSType string
+// Validate checks PlatformInfo against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *PlatformInfo) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
 
-const (
-	OSTypeLinux   OSType = "linux"
-	OSTypeDarwin  OSType = "darwin"
-	OSTypeWindows OSType = "windows"
-	OSTypeBsd     OSType = "bsd"
-)
+func (x *PlatformInfo) validate(prefix string, errs *ValidationErrors) {
+	if x.OSFamily == "" {
+		errs.add(prefix+"os_family", "is required")
+	}
+	if x.OSFamily != "" && !x.OSFamily.Valid() {
+		errs.add(prefix+"os_family", "must be one of debian, rhel, arch, alpine, macos, windows, got %q", x.OSFamily)
+	}
+	if x.OSType == "" {
+		errs.add(prefix+"os_type", "is required")
+	}
+	if x.OSType != "" && !x.OSType.Valid() {
+		errs.add(prefix+"os_type", "must be one of linux, darwin, windows, bsd, got %q", x.OSType)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
 
 // OSFamily OS distribution family
 type OSFamily string
@@ -419,105 +1830,386 @@ const (
 	OSFamilyWindows OSFamily = "windows"
 )
 
-// SystemIdentifiers Platform-specific immutable identifiers
-type SystemIdentifiers struct {
-	MachineID      string         `json:"machine_id" yaml:"machine_id"`             // systemd machine-id from /etc/machine-id
-	IOPlatformUUID string         `json:"io_platform_uuid" yaml:"io_platform_uuid"` // macOS IOPlatformUUID from I/O Registry
-	HardwareUUID   string         `json:"hardware_uuid" yaml:"hardware_uuid"`       // macOS Hardware UUID
-	CollectedAt    string         `json:"collected_at" yaml:"collected_at"`         // When these identifiers were collected
-	PrimaryID      string         `json:"primary_id" yaml:"primary_id"`             // Primary system identifier (machine-id or hardware UUID)
-	IDType         IdentifierType `json:"id_type" yaml:"id_type"`                   // Type of primary identifier
-	DMIProductUUID string         `json:"dmi_product_uuid" yaml:"dmi_product_uuid"` // DMI/SMBIOS product UUID from /sys/class/dmi/id/product_uuid
-	DMIBoardSerial string         `json:"dmi_board_serial" yaml:"dmi_board_serial"` // Motherboard serial number
-	CollectedBy    string         `json:"collected_by" yaml:"collected_by"`         // How identifiers were collected (probe script version)
+// Valid reports whether v is one of the defined OSFamily values
+func (v OSFamily) Valid() bool {
+	switch v {
+	case OSFamilyDebian, OSFamilyRhel, OSFamilyArch, OSFamilyAlpine, OSFamilyMacos, OSFamilyWindows:
+		return true
+	}
+	return false
 }
 
-// IdentifierType Type of primary identifier
-type IdentifierType string
+// OSType Operating system type
+type OSType string
 
 const (
-	IdentifierTypeMachineId      IdentifierType = "machine-id"
-	IdentifierTypeHardwareUuid   IdentifierType = "hardware-uuid"
-	IdentifierTypeIoPlatformUuid IdentifierType = "io-platform-uuid"
-	IdentifierTypeProductUuid    IdentifierType = "product-uuid"
+	OSTypeLinux   OSType = "linux"
+	OSTypeDarwin  OSType = "darwin"
+	OSTypeWindows OSType = "windows"
+	OSTypeBsd     OSType = "bsd"
 )
 
-// CompositeKey Composite identifier for database indexing
-type CompositeKey struct {
-	Components []KeyComponent `json:"components" yaml:"components"` //
-	Hash       string         `json:"hash" yaml:"hash"`             // SHA256 hash of composite key for indexing
-	Key        string         `json:"key" yaml:"key"`               // Concatenated composite key (os_type:primary_id)
+// Valid reports whether v is one of the defined OSType values
+func (v OSType) Valid() bool {
+	switch v {
+	case OSTypeLinux, OSTypeDarwin, OSTypeWindows, OSTypeBsd:
+		return true
+	}
+	return false
 }
 
-// KeyComponent represents a generated type.
-type KeyComponent struct {
-	Name  string `json:"name" yaml:"name"`   // Component name (os_type, machine_id, etc.)
-	Value string `json:"value" yaml:"value"` // Component value
+// NodeRegistration Controller registration metadata
+type NodeRegistration struct {
+	ControllerURL     string `json:"controller_url,omitempty" yaml:"controller_url,omitempty"`         // URL of controlling instance
+	LastCheckin       string `json:"last_checkin,omitempty" yaml:"last_checkin,omitempty"`             //
+	Registered        bool   `json:"registered,omitempty" yaml:"registered,omitempty"`                 // Whether node is registered with controller
+	RegisteredAt      string `json:"registered_at,omitempty" yaml:"registered_at,omitempty"`           //
+	RegistrationToken string `json:"registration_token,omitempty" yaml:"registration_token,omitempty"` // Token for initial registration (rotated after first use)
+}
+
+// Validate checks NodeRegistration against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *NodeRegistration) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *NodeRegistration) validate(prefix string, errs *ValidationErrors) {
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
 }
 
 // IdentityValidation Identity validation configuration
 type IdentityValidation struct {
-	RequireMatch     bool `json:"require_match" yaml:"require_match"`           // Fail if identity doesn't match config
-	CheckOnStartup   bool `json:"check_on_startup" yaml:"check_on_startup"`     // Validate identity when exporter starts
-	AllowOSMigration bool `json:"allow_os_migration" yaml:"allow_os_migration"` // Allow OS reinstall (machine-id changes, hardware UUID stays)
-	AlertOnMismatch  bool `json:"alert_on_mismatch" yaml:"alert_on_mismatch"`   // Send alert if identity doesn't match
+	AlertOnMismatch  bool `json:"alert_on_mismatch,omitempty" yaml:"alert_on_mismatch,omitempty"`   // Send alert if identity doesn't match
+	AllowOSMigration bool `json:"allow_os_migration,omitempty" yaml:"allow_os_migration,omitempty"` // Allow OS reinstall (machine-id changes, hardware UUID stays)
+	CheckOnStartup   bool `json:"check_on_startup,omitempty" yaml:"check_on_startup,omitempty"`     // Validate identity when exporter starts
+	RequireMatch     bool `json:"require_match,omitempty" yaml:"require_match,omitempty"`           // Fail if identity doesn't match config
 }
 
-// NodeRegistration Controller registration metadata
-type NodeRegistration struct {
-	LastCheckin       string `json:"last_checkin" yaml:"last_checkin"`             //
-	Registered        bool   `json:"registered" yaml:"registered"`                 // Whether node is registered with controller
-	RegisteredAt      string `json:"registered_at" yaml:"registered_at"`           //
-	ControllerURL     string `json:"controller_url" yaml:"controller_url"`         // URL of controlling instance
-	RegistrationToken string `json:"registration_token" yaml:"registration_token"` // Token for initial registration (rotated after first use)
+// Validate checks IdentityValidation against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *IdentityValidation) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *IdentityValidation) validate(prefix string, errs *ValidationErrors) {
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// SystemVersions Software versions a node reports to the control plane
+type SystemVersions struct {
+	Agent        string            `json:"agent,omitempty" yaml:"agent,omitempty"`                   // power-edge-client version
+	CollectedAt  string            `json:"collected_at" yaml:"collected_at"`                         // When the versions were collected (RFC 3339)
+	Kernel       string            `json:"kernel,omitempty" yaml:"kernel,omitempty"`                 // Running kernel release (uname -r)
+	OSID         string            `json:"os_id,omitempty" yaml:"os_id,omitempty"`                   // ID from /etc/os-release (e.g. ubuntu, rhel)
+	OSPrettyName string            `json:"os_pretty_name,omitempty" yaml:"os_pretty_name,omitempty"` // PRETTY_NAME from /etc/os-release
+	OSVersion    string            `json:"os_version,omitempty" yaml:"os_version,omitempty"`         // VERSION_ID from /etc/os-release
+	Packages     map[string]string `json:"packages,omitempty" yaml:"packages,omitempty"`             // Installed version of each managed package; missing packages are omitted
+}
+
+// Validate checks SystemVersions against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *SystemVersions) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *SystemVersions) validate(prefix string, errs *ValidationErrors) {
+	if x.CollectedAt == "" {
+		errs.add(prefix+"collected_at", "is required")
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
 }
 
 // WatcherConfig represents a generated type.
 type WatcherConfig struct {
-	Version      Version      `json:"version" yaml:"version"`             //
-	Watchers     Watchers     `json:"watchers" yaml:"watchers"`           //
-	EventHandler EventHandler `json:"event_handler" yaml:"event_handler"` // Configuration for event processing
+	EventHandler EventHandler  `json:"event_handler,omitempty" yaml:"event_handler,omitempty"` // Configuration for event processing
+	Reconcile    ReconcileMode `json:"reconcile,omitempty" yaml:"reconcile,omitempty"`         // Agent-wide reconcile mode, overriding -reconcile; a SIGHUP applies a changed value
+	Version      Version       `json:"version" yaml:"version"`                                 //
+	Watchers     Watchers      `json:"watchers" yaml:"watchers"`                               //
+}
+
+var (
+	patternWatcherConfigVersion = regexp.MustCompile(`^[0-9]+\.[0-9]+$`)
+)
+
+// Validate checks WatcherConfig against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *WatcherConfig) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *WatcherConfig) validate(prefix string, errs *ValidationErrors) {
+	x.EventHandler.validate(prefix+"event_handler.", errs)
+	if x.Reconcile != "" && !x.Reconcile.Valid() {
+		errs.add(prefix+"reconcile", "must be one of disabled, dry-run, enforce, got %q", x.Reconcile)
+	}
+	if x.Version == "" {
+		errs.add(prefix+"version", "is required")
+	}
+	if x.Version != "" && !patternWatcherConfigVersion.MatchString(string(x.Version)) {
+		errs.add(prefix+"version", "must match %s, got %q", patternWatcherConfigVersion, x.Version)
+	}
+	x.Watchers.validate(prefix+"watchers.", errs)
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
 }
 
 // EventHandler Configuration for event processing
 type EventHandler struct {
-	BufferSize int `json:"buffer_size" yaml:"buffer_size"` // Event channel buffer size
-	DebounceMs int `json:"debounce_ms" yaml:"debounce_ms"` // Milliseconds to debounce rapid events
-	BatchSize  int `json:"batch_size" yaml:"batch_size"`   // Max events to batch before processing
+	BatchSize  int `json:"batch_size,omitempty" yaml:"batch_size,omitempty"`   // Max events to batch before processing
+	BufferSize int `json:"buffer_size,omitempty" yaml:"buffer_size,omitempty"` // Event channel buffer size
+	DebounceMs int `json:"debounce_ms,omitempty" yaml:"debounce_ms,omitempty"` // Milliseconds to debounce rapid events
+}
+
+// Validate checks EventHandler against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *EventHandler) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *EventHandler) validate(prefix string, errs *ValidationErrors) {
+	if x.BatchSize != 0 && x.BatchSize < 1 {
+		errs.add(prefix+"batch_size", "must be at least 1, got %d", x.BatchSize)
+	}
+	if x.BatchSize > 1000 {
+		errs.add(prefix+"batch_size", "must be at most 1000, got %d", x.BatchSize)
+	}
+	if x.BufferSize != 0 && x.BufferSize < 1 {
+		errs.add(prefix+"buffer_size", "must be at least 1, got %d", x.BufferSize)
+	}
+	if x.BufferSize > 10000 {
+		errs.add(prefix+"buffer_size", "must be at most 10000, got %d", x.BufferSize)
+	}
+	if x.DebounceMs != 0 && x.DebounceMs < 0 {
+		errs.add(prefix+"debounce_ms", "must be at least 0, got %d", x.DebounceMs)
+	}
+	if x.DebounceMs > 60000 {
+		errs.add(prefix+"debounce_ms", "must be at most 60000, got %d", x.DebounceMs)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
 }
 
 // Watchers represents a generated type.
 type Watchers struct {
-	Dbus     DBusWatcher     `json:"dbus" yaml:"dbus"`         //
-	Enabled  bool            `json:"enabled" yaml:"enabled"`   // Master switch for all watchers
-	Inotify  InotifyWatcher  `json:"inotify" yaml:"inotify"`   //
-	Journald JournaldWatcher `json:"journald" yaml:"journald"` //
-	Auditd   AuditdWatcher   `json:"auditd" yaml:"auditd"`     //
+	Auditd   AuditdWatcher   `json:"auditd,omitempty" yaml:"auditd,omitempty"`     //
+	Dbus     DBusWatcher     `json:"dbus,omitempty" yaml:"dbus,omitempty"`         //
+	Enabled  bool            `json:"enabled" yaml:"enabled"`                       // Master switch for all watchers
+	Inotify  InotifyWatcher  `json:"inotify,omitempty" yaml:"inotify,omitempty"`   //
+	Journald JournaldWatcher `json:"journald,omitempty" yaml:"journald,omitempty"` //
 }
 
-// InotifyWatcher represents a generated type.
-type InotifyWatcher struct {
-	Enabled bool       `json:"enabled" yaml:"enabled"` //
-	Paths   []UnixPath `json:"paths" yaml:"paths"`     // File paths to monitor for changes
+// Validate checks Watchers against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *Watchers) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
-// JournaldWatcher represents a generated type.
-type JournaldWatcher struct {
-	Enabled bool          `json:"enabled" yaml:"enabled"` //
-	Units   []ServiceUnit `json:"units" yaml:"units"`     // Systemd units to monitor logs
+func (x *Watchers) validate(prefix string, errs *ValidationErrors) {
+	x.Auditd.validate(prefix+"auditd.", errs)
+	x.Dbus.validate(prefix+"dbus.", errs)
+	x.Inotify.validate(prefix+"inotify.", errs)
+	x.Journald.validate(prefix+"journald.", errs)
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
 }
 
 // AuditdWatcher represents a generated type.
 type AuditdWatcher struct {
-	Enabled  bool      `json:"enabled" yaml:"enabled"`   //
-	Commands []Command `json:"commands" yaml:"commands"` // Commands to audit for execution
-	Syscalls []string  `json:"syscalls" yaml:"syscalls"` // Syscalls to monitor (e.g., execve, open, connect)
+	Commands []Command `json:"commands,omitempty" yaml:"commands,omitempty"` // Commands to audit for execution
+	Enabled  bool      `json:"enabled,omitempty" yaml:"enabled,omitempty"`   //
+	Syscalls []string  `json:"syscalls,omitempty" yaml:"syscalls,omitempty"` // Syscalls to monitor (e.g., execve, open, connect)
+}
+
+// Validate checks AuditdWatcher against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *AuditdWatcher) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *AuditdWatcher) validate(prefix string, errs *ValidationErrors) {
+	for i, v := range x.Commands {
+		if len(v) < 1 {
+			errs.add(fmt.Sprintf("%scommands[%d]", prefix, i), "must be at least 1 characters")
+		}
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
 }
 
 // DBusWatcher represents a generated type.
 type DBusWatcher struct {
-	Enabled bool     `json:"enabled" yaml:"enabled"` //
-	Signals []string `json:"signals" yaml:"signals"` // D-Bus signals to monitor
+	Enabled bool     `json:"enabled,omitempty" yaml:"enabled,omitempty"` //
+	Signals []string `json:"signals,omitempty" yaml:"signals,omitempty"` // D-Bus signals to monitor
+}
+
+// Validate checks DBusWatcher against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *DBusWatcher) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *DBusWatcher) validate(prefix string, errs *ValidationErrors) {
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// InotifyWatcher represents a generated type.
+type InotifyWatcher struct {
+	Enabled   bool              `json:"enabled,omitempty" yaml:"enabled,omitempty"`     //
+	Ignore    []string          `json:"ignore,omitempty" yaml:"ignore,omitempty"`       // Glob patterns for paths whose events are dropped; * stays within one path segment, ** spans any number, and patterns without a slash match the file name
+	Paths     []UnixPath        `json:"paths,omitempty" yaml:"paths,omitempty"`         // File paths to monitor for changes
+	Resources []InotifyResource `json:"resources,omitempty" yaml:"resources,omitempty"` // Managed resources to reconcile when a matching path changes, instead of everything
+}
+
+var (
+	patternInotifyWatcherPathsItem = regexp.MustCompile(`^/`)
+)
+
+// Validate checks InotifyWatcher against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *InotifyWatcher) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *InotifyWatcher) validate(prefix string, errs *ValidationErrors) {
+	for i, v := range x.Paths {
+		if !patternInotifyWatcherPathsItem.MatchString(string(v)) {
+			errs.add(fmt.Sprintf("%spaths[%d]", prefix, i), "must match %s, got %q", patternInotifyWatcherPathsItem, v)
+		}
+	}
+	for i := range x.Resources {
+		x.Resources[i].validate(fmt.Sprintf("%sresources[%d].", prefix, i), errs)
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// InotifyResource represents a generated type.
+type InotifyResource struct {
+	Path      string   `json:"path" yaml:"path"`           // Glob pattern for changed paths, with the same syntax as ignore
+	Resources []string `json:"resources" yaml:"resources"` // Resources reconciled for the path, as file:PATH or service:NAME, e.g. service:nginx
+}
+
+var (
+	patternInotifyResourceResourcesItem = regexp.MustCompile(`^(file:/[^,]*|service:[^,:]+)$`)
+)
+
+// Validate checks InotifyResource against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *InotifyResource) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *InotifyResource) validate(prefix string, errs *ValidationErrors) {
+	if x.Path == "" {
+		errs.add(prefix+"path", "is required")
+	}
+	if len(x.Resources) == 0 {
+		errs.add(prefix+"resources", "is required")
+	}
+	for i, v := range x.Resources {
+		if !patternInotifyResourceResourcesItem.MatchString(string(v)) {
+			errs.add(fmt.Sprintf("%sresources[%d]", prefix, i), "must match %s, got %q", patternInotifyResourceResourcesItem, v)
+		}
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
+}
+
+// JournaldWatcher represents a generated type.
+type JournaldWatcher struct {
+	Enabled  bool          `json:"enabled,omitempty" yaml:"enabled,omitempty"`   //
+	Patterns []string      `json:"patterns,omitempty" yaml:"patterns,omitempty"` // Regular expressions selecting messages that signal a unit state change
+	Units    []ServiceUnit `json:"units,omitempty" yaml:"units,omitempty"`       // Systemd units to monitor logs
+}
+
+var (
+	patternJournaldWatcherUnitsItem = regexp.MustCompile(`^[a-zA-Z0-9_-]+\.(service|socket|timer|target)$`)
+)
+
+// Validate checks JournaldWatcher against its schema constraints, returning
+// ValidationErrors listing every violation
+func (x *JournaldWatcher) Validate() error {
+	var errs ValidationErrors
+	x.validate("", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (x *JournaldWatcher) validate(prefix string, errs *ValidationErrors) {
+	for i, v := range x.Units {
+		if !patternJournaldWatcherUnitsItem.MatchString(string(v)) {
+			errs.add(fmt.Sprintf("%sunits[%d]", prefix, i), "must match %s, got %q", patternJournaldWatcherUnitsItem, v)
+		}
+	}
+	if v, ok := interface{}(x).(extraValidator); ok {
+		v.validateExtra(prefix, errs)
+	}
 }
 
 // LoadStateConfig loads state configuration from YAML file
@@ -527,12 +2219,7 @@ func LoadStateConfig(path string) (*State, error) {
 		return nil, fmt.Errorf("read file: %w", err)
 	}
 
-	var config State
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("parse yaml: %w", err)
-	}
-
-	return &config, nil
+	return ParseState(data)
 }
 
 // LoadWatcherConfig loads watcher configuration from YAML file
@@ -549,3 +2236,34 @@ func LoadWatcherConfig(path string) (*WatcherConfig, error) {
 
 	return &config, nil
 }
+
+// FieldError describes a single field that violates the schema
+type FieldError struct {
+	Field   string `json:"field"`   // Path to the field, e.g. services[0].state
+	Message string `json:"message"` // What is wrong with it
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors collects every field error found by Validate
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return fmt.Sprintf("validation failed: %s", strings.Join(msgs, "; "))
+}
+
+func (e *ValidationErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// extraValidator is implemented (outside generated code) by types that need
+// checks the schema can't express; their generated validate calls it
+type extraValidator interface {
+	validateExtra(prefix string, errs *ValidationErrors)
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envNamePattern matches the variable names ${NAME} may refer to
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Interpolate resolves references in s against the agent's environment and
+// filesystem:
+//
+//	${NAME}                the environment variable NAME
+//	${file:/path}          the content of /path, without a trailing newline
+//	${NAME:-default}       default when NAME is unset (may be empty)
+//	${file:/path:-default} default when /path doesn't exist
+//	$${                    a literal ${
+//
+// A reference that can't be resolved and has no default is an error. The
+// resolved values are returned too, so callers can keep them out of logs
+// and diffs.
+func Interpolate(s string) (string, []string, error) {
+	var out strings.Builder
+	var secrets []string
+
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			out.WriteString(s)
+			return out.String(), secrets, nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			out.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+		out.WriteString(s[:i])
+
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", nil, fmt.Errorf("unterminated reference %q", truncate(s[i:], 32))
+		}
+		ref := s[i+2 : i+end]
+		s = s[i+end+1:]
+
+		value, secret, err := resolveReference(ref)
+		if err != nil {
+			return "", nil, err
+		}
+		out.WriteString(value)
+		if secret && value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+}
+
+// resolveReference resolves the inside of one ${...}, reporting whether the
+// value came from the environment or a file rather than its default
+func resolveReference(ref string) (value string, secret bool, err error) {
+	ref, def, optional := strings.Cut(ref, ":-")
+
+	if path, ok := strings.CutPrefix(ref, "file:"); ok {
+		if !strings.HasPrefix(path, "/") {
+			return "", false, fmt.Errorf("${%s}: file path must be absolute", ref)
+		}
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) && optional {
+			return def, false, nil
+		} else if err != nil {
+			return "", false, fmt.Errorf("${%s}: %w", ref, err)
+		}
+		return strings.TrimSuffix(string(data), "\n"), true, nil
+	}
+
+	if !envNamePattern.MatchString(ref) {
+		return "", false, fmt.Errorf("invalid reference ${%s}", ref)
+	}
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		if optional {
+			return def, false, nil
+		}
+		return "", false, fmt.Errorf("${%s}: environment variable %s is not set", ref, ref)
+	}
+	return value, true, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("PE_TOKEN", "s3cret")
+	t.Setenv("PE_EMPTY", "")
+	secretFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(secretFile, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	missingFile := filepath.Join(t.TempDir(), "missing")
+
+	tests := []struct {
+		name        string
+		in          string
+		want        string
+		wantSecrets []string
+		wantErr     string
+	}{
+		{"no references", "plain $HOME text", "plain $HOME text", nil, ""},
+		{"env", "token=${PE_TOKEN}", "token=s3cret", []string{"s3cret"}, ""},
+		{"file", "password: ${file:" + secretFile + "}!", "password: hunter2!", []string{"hunter2"}, ""},
+		{"empty env is set", "[${PE_EMPTY}]", "[]", nil, ""},
+		{"default for unset env", "${PE_UNSET:-fallback}", "fallback", nil, ""},
+		{"empty default", "[${PE_UNSET:-}]", "[]", nil, ""},
+		{"default ignored when set", "${PE_TOKEN:-fallback}", "s3cret", []string{"s3cret"}, ""},
+		{"default for missing file", "${file:" + missingFile + ":-none}", "none", nil, ""},
+		{"escaped", "$${PE_TOKEN} ${PE_TOKEN}", "${PE_TOKEN} s3cret", []string{"s3cret"}, ""},
+		{"unset env", "${PE_UNSET}", "", nil, "environment variable PE_UNSET is not set"},
+		{"missing file", "${file:" + missingFile + "}", "", nil, "no such file"},
+		{"relative file", "${file:password}", "", nil, "must be absolute"},
+		{"invalid name", "${not valid}", "", nil, "invalid reference"},
+		{"unterminated", "x ${PE_TOKEN", "", nil, "unterminated reference"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, secrets, err := Interpolate(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Interpolate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Interpolate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Interpolate() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(secrets, tt.wantSecrets) {
+				t.Errorf("secrets = %q, want %q", secrets, tt.wantSecrets)
+			}
+		})
+	}
+}
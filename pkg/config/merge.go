@@ -0,0 +1,184 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Merge overlays one state document on another, returning a new state; the
+// inputs are not modified. It lets a site-wide base be combined with
+// per-node overrides.
+//
+// Precedence, field by field:
+//   - version and metadata scalars: the overlay's value wins if set
+//   - metadata labels/annotations, sysctl and sysctl_policy modules: merged
+//     by key, overlay wins
+//   - reconcile overrides and sysctl_policy unknown_keys: the overlay's value
+//     wins per resource type / field if set
+//   - firewall, dns, hooks and freeze: replaced as a whole if the overlay sets them
+//   - services, packages and files: merged by natural key (service name,
+//     package name, file path). An overlay entry replaces the base entry with
+//     the same key wholesale, keeping the base entry's position; entries with
+//     new keys are appended in overlay order. Each list is also deduplicated
+//     by key, the last occurrence winning.
+//
+// Entries are replaced rather than merged field by field because unset
+// fields can't be told apart from zero values (e.g. enabled: false).
+func Merge(base, overlay *State) *State {
+	if base == nil {
+		base = &State{}
+	}
+	if overlay == nil {
+		overlay = &State{}
+	}
+
+	merged := &State{
+		Version:  base.Version,
+		Metadata: mergeMetadata(base.Metadata, overlay.Metadata),
+		Firewall: base.Firewall,
+		DNS:      base.DNS,
+		Hooks:    base.Hooks,
+		Freeze:   base.Freeze,
+		Sysctl:   mergeStringMap(base.Sysctl, overlay.Sysctl),
+		SysctlPolicy: SysctlPolicy{
+			UnknownKeys: base.SysctlPolicy.UnknownKeys,
+			Modules:     mergeStringMap(base.SysctlPolicy.Modules, overlay.SysctlPolicy.Modules),
+			Unordered:   mergeByKey(base.SysctlPolicy.Unordered, overlay.SysctlPolicy.Unordered, func(key string) string { return key }),
+		},
+		PackagePolicy: base.PackagePolicy,
+		Reconcile:     mergeReconcileOverrides(base.Reconcile, overlay.Reconcile),
+		Services:      mergeByKey(base.Services, overlay.Services, func(s ServiceConfig) string { return s.Name }),
+		Repositories:  mergeByKey(base.Repositories, overlay.Repositories, func(r RepoConfig) string { return r.Name }),
+		Packages:      mergeByKey(base.Packages, overlay.Packages, func(p PackageConfig) string { return p.Name }),
+		Files:         mergeByKey(base.Files, overlay.Files, func(f FileConfig) string { return string(f.Path) }),
+	}
+
+	if overlay.Version != "" {
+		merged.Version = overlay.Version
+	}
+	if !reflect.ValueOf(overlay.Firewall).IsZero() {
+		merged.Firewall = overlay.Firewall
+	}
+	if !reflect.ValueOf(overlay.DNS).IsZero() {
+		merged.DNS = overlay.DNS
+	}
+	if overlay.Hooks != (HooksConfig{}) {
+		merged.Hooks = overlay.Hooks
+	}
+	if overlay.Freeze != (FreezeConfig{}) {
+		merged.Freeze = overlay.Freeze
+	}
+	if overlay.SysctlPolicy.UnknownKeys != "" {
+		merged.SysctlPolicy.UnknownKeys = overlay.SysctlPolicy.UnknownKeys
+	}
+	if overlay.PackagePolicy.CacheRefresh != "" {
+		merged.PackagePolicy.CacheRefresh = overlay.PackagePolicy.CacheRefresh
+	}
+	if overlay.PackagePolicy.CacheMaxAge != 0 {
+		merged.PackagePolicy.CacheMaxAge = overlay.PackagePolicy.CacheMaxAge
+	}
+
+	return merged
+}
+
+// LoadStateConfigs loads each path and merges them in order, later files
+// overriding earlier ones
+func LoadStateConfigs(paths ...string) (*State, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no state files given")
+	}
+
+	var merged *State
+	for _, path := range paths {
+		state, err := LoadStateConfig(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		merged = Merge(merged, state)
+	}
+	return merged, nil
+}
+
+func mergeReconcileOverrides(base, overlay ReconcileOverrides) ReconcileOverrides {
+	pick := func(base, overlay ReconcileMode) ReconcileMode {
+		if overlay != "" {
+			return overlay
+		}
+		return base
+	}
+	return ReconcileOverrides{
+		Services: pick(base.Services, overlay.Services),
+		Sysctl:   pick(base.Sysctl, overlay.Sysctl),
+		Firewall: pick(base.Firewall, overlay.Firewall),
+		Packages: pick(base.Packages, overlay.Packages),
+		Files:    pick(base.Files, overlay.Files),
+		DNS:      pick(base.DNS, overlay.DNS),
+	}
+}
+
+func mergeMetadata(base, overlay Metadata) Metadata {
+	merged := base
+	if overlay.Site != "" {
+		merged.Site = overlay.Site
+	}
+	if overlay.Environment != "" {
+		merged.Environment = overlay.Environment
+	}
+	if overlay.Description != "" {
+		merged.Description = overlay.Description
+	}
+	merged.Labels = mergeMap(base.Labels, overlay.Labels)
+	merged.Annotations = mergeMap(base.Annotations, overlay.Annotations)
+	return merged
+}
+
+func mergeMap(base, overlay map[string]interface{}) map[string]interface{} {
+	if len(base) == 0 && len(overlay) == 0 {
+		return nil
+	}
+	merged := make(map[string]interface{}, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		merged[k] = v
+	}
+	return merged
+}
+
+func mergeStringMap(base, overlay map[string]string) map[string]string {
+	if len(base) == 0 && len(overlay) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		merged[k] = v
+	}
+	return merged
+}
+
+// mergeByKey concatenates base and overlay, keeping one entry per key: the
+// last one seen, at the position the key first appeared
+func mergeByKey[T any](base, overlay []T, key func(T) string) []T {
+	if len(base) == 0 && len(overlay) == 0 {
+		return nil
+	}
+
+	var merged []T
+	index := make(map[string]int, len(base)+len(overlay))
+	for _, list := range [][]T{base, overlay} {
+		for _, item := range list {
+			k := key(item)
+			if i, ok := index[k]; ok {
+				merged[i] = item
+				continue
+			}
+			index[k] = len(merged)
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
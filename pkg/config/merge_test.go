@@ -0,0 +1,177 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMerge_ConflictingServiceStates(t *testing.T) {
+	base := &State{
+		Services: []ServiceConfig{
+			{Name: "nginx", State: ServiceStateRunning, Enabled: true},
+			{Name: "sshd", State: ServiceStateRunning, Enabled: true},
+		},
+	}
+	overlay := &State{
+		Services: []ServiceConfig{
+			{Name: "nginx", State: ServiceStateStopped, Enabled: false},
+			{Name: "docker", State: ServiceStateRunning},
+		},
+	}
+
+	got := Merge(base, overlay).Services
+	want := []ServiceConfig{
+		{Name: "nginx", State: ServiceStateStopped, Enabled: false},
+		{Name: "sshd", State: ServiceStateRunning, Enabled: true},
+		{Name: "docker", State: ServiceStateRunning},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge() services = %+v, want %+v", got, want)
+	}
+
+	// The inputs must be left untouched
+	if base.Services[0].State != ServiceStateRunning {
+		t.Errorf("Merge() modified base: %+v", base.Services[0])
+	}
+}
+
+func TestMerge_OverlappingFilePaths(t *testing.T) {
+	base := &State{
+		Files: []FileConfig{
+			{Path: "/etc/motd", Content: "site\n", Mode: "0644"},
+			{Path: "/etc/issue", Content: "base\n"},
+		},
+	}
+	overlay := &State{
+		Files: []FileConfig{
+			{Path: "/etc/motd", Content: "node\n"},
+			{Path: "/etc/motd", Content: "node, again\n"},
+		},
+	}
+
+	got := Merge(base, overlay).Files
+	want := []FileConfig{
+		// Replaced wholesale by the last occurrence: the base's mode is not kept
+		{Path: "/etc/motd", Content: "node, again\n"},
+		{Path: "/etc/issue", Content: "base\n"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge() files = %+v, want %+v", got, want)
+	}
+}
+
+func TestMerge_Scalars(t *testing.T) {
+	base := &State{
+		Version: "1.0",
+		Metadata: Metadata{
+			Site:        "site-a",
+			Environment: "production",
+			Labels:      map[string]interface{}{"tier": "edge", "rack": "1"},
+		},
+		Sysctl:   map[string]string{"vm.swappiness": "10", "net.ipv4.ip_forward": "0"},
+		Firewall: FirewallConfig{Enabled: true, AllowedServices: []string{"ssh"}},
+		Hooks:    HooksConfig{Pre: "true"},
+	}
+	overlay := &State{
+		Metadata: Metadata{
+			Site:   "node-1",
+			Labels: map[string]interface{}{"rack": "2"},
+		},
+		Sysctl: map[string]string{"net.ipv4.ip_forward": "1"},
+	}
+
+	got := Merge(base, overlay)
+
+	if got.Version != "1.0" {
+		t.Errorf("Version = %q, want base value kept", got.Version)
+	}
+	if got.Metadata.Site != "node-1" || got.Metadata.Environment != "production" {
+		t.Errorf("Metadata = %+v, want overlay site and base environment", got.Metadata)
+	}
+	if want := map[string]interface{}{"tier": "edge", "rack": "2"}; !reflect.DeepEqual(got.Metadata.Labels, want) {
+		t.Errorf("Labels = %v, want %v", got.Metadata.Labels, want)
+	}
+	if want := map[string]string{"vm.swappiness": "10", "net.ipv4.ip_forward": "1"}; !reflect.DeepEqual(got.Sysctl, want) {
+		t.Errorf("Sysctl = %v, want %v", got.Sysctl, want)
+	}
+	if !got.Firewall.Enabled || got.Hooks.Pre != "true" {
+		t.Errorf("unset overlay objects should keep the base: firewall=%+v hooks=%+v", got.Firewall, got.Hooks)
+	}
+
+	if got := Merge(base, &State{Freeze: FreezeConfig{Enabled: true}}); !got.Freeze.Enabled {
+		t.Errorf("Freeze = %+v, want overlay to set it", got.Freeze)
+	}
+
+	overlay.Firewall = FirewallConfig{AllowedServices: []string{"https"}}
+	if got := Merge(base, overlay); got.Firewall.Enabled || !reflect.DeepEqual(got.Firewall.AllowedServices, []string{"https"}) {
+		t.Errorf("Firewall = %+v, want overlay to replace it", got.Firewall)
+	}
+}
+
+func TestMerge_Policies(t *testing.T) {
+	base := &State{
+		Reconcile:     ReconcileOverrides{Services: ReconcileModeEnforce, Packages: ReconcileModeDryRun},
+		SysctlPolicy:  SysctlPolicy{UnknownKeys: SysctlUnknownKeysError, Modules: map[string]string{"net.bridge.": "br_netfilter"}, Unordered: []string{"net.ipv4.tcp_rmem"}},
+		PackagePolicy: PackagePolicy{CacheRefresh: PackageCacheRefreshAlways, CacheMaxAge: 600},
+	}
+	overlay := &State{
+		Reconcile:     ReconcileOverrides{Packages: ReconcileModeDisabled},
+		SysctlPolicy:  SysctlPolicy{Modules: map[string]string{"net.netfilter.": "nf_conntrack"}, Unordered: []string{"net.ipv4.tcp_rmem", "net.ipv4.ip_local_reserved_ports"}},
+		PackagePolicy: PackagePolicy{CacheMaxAge: 86400},
+	}
+
+	got := Merge(base, overlay)
+
+	if want := (ReconcileOverrides{Services: ReconcileModeEnforce, Packages: ReconcileModeDisabled}); got.Reconcile != want {
+		t.Errorf("Reconcile = %+v, want %+v", got.Reconcile, want)
+	}
+	if got.SysctlPolicy.UnknownKeys != SysctlUnknownKeysError {
+		t.Errorf("UnknownKeys = %q, want base value kept", got.SysctlPolicy.UnknownKeys)
+	}
+	if want := map[string]string{"net.bridge.": "br_netfilter", "net.netfilter.": "nf_conntrack"}; !reflect.DeepEqual(got.SysctlPolicy.Modules, want) {
+		t.Errorf("Modules = %v, want %v", got.SysctlPolicy.Modules, want)
+	}
+	if want := []string{"net.ipv4.tcp_rmem", "net.ipv4.ip_local_reserved_ports"}; !reflect.DeepEqual(got.SysctlPolicy.Unordered, want) {
+		t.Errorf("Unordered = %v, want %v", got.SysctlPolicy.Unordered, want)
+	}
+	if want := (PackagePolicy{CacheRefresh: PackageCacheRefreshAlways, CacheMaxAge: 86400}); got.PackagePolicy != want {
+		t.Errorf("PackagePolicy = %+v, want %+v", got.PackagePolicy, want)
+	}
+}
+
+func TestLoadStateConfigs(t *testing.T) {
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "base.yaml")
+	nodePath := filepath.Join(dir, "node.yaml")
+
+	if err := os.WriteFile(basePath, []byte(`version: "1.0"
+metadata:
+  site: site-a
+  environment: production
+services:
+  - name: sshd
+    state: running
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(nodePath, []byte(`services:
+  - name: sshd
+    state: stopped
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := LoadStateConfigs(basePath, nodePath)
+	if err != nil {
+		t.Fatalf("LoadStateConfigs() error = %v", err)
+	}
+	if state.Metadata.Site != "site-a" || len(state.Services) != 1 || state.Services[0].State != ServiceStateStopped {
+		t.Errorf("LoadStateConfigs() = %+v", state)
+	}
+
+	if _, err := LoadStateConfigs(basePath, filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
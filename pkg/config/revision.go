@@ -0,0 +1,26 @@
+package config
+
+import "fmt"
+
+// RevisionAnnotation is the metadata annotation the server stamps on stored
+// state so agents can correlate their activity with the revision they applied
+const RevisionAnnotation = "power-edge.dev/revision"
+
+// Revision returns the server-provided revision of the state, if any
+func (s *State) Revision() string {
+	if s == nil || s.Metadata.Annotations == nil {
+		return ""
+	}
+	if rev, ok := s.Metadata.Annotations[RevisionAnnotation]; ok {
+		return fmt.Sprint(rev)
+	}
+	return ""
+}
+
+// SetRevision records rev in the state's annotations
+func (s *State) SetRevision(rev string) {
+	if s.Metadata.Annotations == nil {
+		s.Metadata.Annotations = make(map[string]interface{})
+	}
+	s.Metadata.Annotations[RevisionAnnotation] = rev
+}
@@ -0,0 +1,20 @@
+package config
+
+// ParseStateStrict parses a state document like ParseState, but rejects
+// fields the schema doesn't define, so typos fail instead of being silently
+// ignored
+func ParseStateStrict(data []byte) (*State, error) {
+	return parseState(data, true)
+}
+
+// ValidateAgainstSchema checks a complete state document against the state
+// schema: unknown fields, types, and the required, enum, pattern and range
+// constraints the generated validators carry. It needs neither the schema
+// files nor a server, so candidate state can be checked before it is pushed.
+func ValidateAgainstSchema(data []byte) error {
+	state, err := ParseStateStrict(data)
+	if err != nil {
+		return err
+	}
+	return state.Validate()
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAgainstSchema(t *testing.T) {
+	valid := `version: "1.0"
+metadata:
+  site: lab
+  environment: development
+services:
+  - name: nginx
+    state: running
+    enabled: true
+`
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{name: "valid", doc: valid},
+		{name: "bad enum", doc: strings.Replace(valid, "state: running", "state: started", 1), wantErr: "services[0].state"},
+		{name: "missing required", doc: strings.Replace(valid, "  environment: development\n", "", 1), wantErr: "metadata.environment: is required"},
+		{name: "unknown field", doc: strings.Replace(valid, "enabled: true", "enabeld: true", 1), wantErr: "field enabeld not found"},
+		{name: "wrong type", doc: strings.Replace(valid, "enabled: true", "enabled: [yes]", 1), wantErr: "parse yaml"},
+		{name: "empty", doc: "", wantErr: "empty state document"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAgainstSchema([]byte(tt.doc))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateAgainstSchema() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateAgainstSchema() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
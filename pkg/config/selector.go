@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Selector picks nodes by their labels, e.g. "site=eu,role in (gateway,edge)".
+// Requirements are comma-separated and must all hold:
+//
+//	key=value, key==value   label is set to value
+//	key!=value              label is unset or set to something else
+//	key in (a,b)            label is set to one of the values
+//	key notin (a,b)         label is unset or set to none of the values
+//	key                     label is set
+//	!key                    label is unset
+//
+// The empty selector matches every node.
+type Selector struct {
+	requirements []requirement
+}
+
+type requirement struct {
+	key    string
+	op     string // "=", "!=", "in", "notin", "exists" or "!exists"
+	values []string
+}
+
+// ParseSelector parses a label selector
+func ParseSelector(s string) (Selector, error) {
+	var sel Selector
+	for _, part := range splitRequirements(s) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			if strings.TrimSpace(s) == "" {
+				break
+			}
+			return Selector{}, fmt.Errorf("invalid selector %q: empty requirement", s)
+		}
+		req, err := parseRequirement(part)
+		if err != nil {
+			return Selector{}, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		sel.requirements = append(sel.requirements, req)
+	}
+	return sel, nil
+}
+
+// splitRequirements splits s on commas outside parentheses
+func splitRequirements(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func parseRequirement(s string) (requirement, error) {
+	if open := strings.Index(s, "("); open >= 0 {
+		fields := strings.Fields(s[:open])
+		if len(fields) != 2 || (fields[1] != "in" && fields[1] != "notin") || !strings.HasSuffix(s, ")") {
+			return requirement{}, fmt.Errorf("%q: want key in (a,b) or key notin (a,b)", s)
+		}
+		var values []string
+		for _, v := range strings.Split(s[open+1:len(s)-1], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			return requirement{}, fmt.Errorf("%q: empty value set", s)
+		}
+		return newRequirement(fields[0], fields[1], values)
+	}
+
+	for _, op := range []string{"!=", "==", "="} {
+		if key, value, ok := strings.Cut(s, op); ok {
+			if op == "==" {
+				op = "="
+			}
+			return newRequirement(strings.TrimSpace(key), op, []string{strings.TrimSpace(value)})
+		}
+	}
+
+	if key, ok := strings.CutPrefix(s, "!"); ok {
+		return newRequirement(strings.TrimSpace(key), "!exists", nil)
+	}
+	return newRequirement(s, "exists", nil)
+}
+
+func newRequirement(key, op string, values []string) (requirement, error) {
+	if key == "" || strings.ContainsAny(key, " \t=!(),") {
+		return requirement{}, fmt.Errorf("invalid label key %q", key)
+	}
+	return requirement{key: key, op: op, values: values}, nil
+}
+
+// Empty reports whether the selector has no requirements
+func (s Selector) Empty() bool {
+	return len(s.requirements) == 0
+}
+
+// Matches reports whether labels satisfy every requirement of the selector
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, req := range s.requirements {
+		value, set := labels[req.key]
+		var ok bool
+		switch req.op {
+		case "=":
+			ok = set && value == req.values[0]
+		case "!=":
+			ok = !set || value != req.values[0]
+		case "in":
+			ok = set && slices.Contains(req.values, value)
+		case "notin":
+			ok = !set || !slices.Contains(req.values, value)
+		case "exists":
+			ok = set
+		case "!exists":
+			ok = !set
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// SelectorLabels returns the labels selectors match a node by: its labels,
+// plus site and environment unless a label of that name overrides them
+func (m Metadata) SelectorLabels() map[string]string {
+	labels := make(map[string]string, len(m.Labels)+2)
+	if m.Site != "" {
+		labels["site"] = m.Site
+	}
+	if m.Environment != "" {
+		labels["environment"] = m.Environment
+	}
+	for k, v := range m.Labels {
+		labels[k] = fmt.Sprint(v)
+	}
+	return labels
+}
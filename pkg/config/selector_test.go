@@ -0,0 +1,66 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelector(t *testing.T) {
+	labels := map[string]string{"site": "eu", "role": "gateway", "tier": "edge"}
+
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"site=eu", true},
+		{"site==eu,role=gateway", true},
+		{"site=eu, role=worker", false},
+		{"site!=us", true},
+		{"missing!=x", true},
+		{"role in (gateway, edge)", true},
+		{"role in (worker)", false},
+		{"role notin (worker,db),site=eu", true},
+		{"missing notin (x)", true},
+		{"tier", true},
+		{"missing", false},
+		{"!missing", true},
+		{"!tier", false},
+	}
+	for _, tt := range tests {
+		sel, err := ParseSelector(tt.selector)
+		if err != nil {
+			t.Errorf("ParseSelector(%q) error = %v", tt.selector, err)
+			continue
+		}
+		if got := sel.Matches(labels); got != tt.want {
+			t.Errorf("ParseSelector(%q).Matches() = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestParseSelector_Invalid(t *testing.T) {
+	for _, selector := range []string{"site=eu,", "=eu", "role in ()", "role within (a)", "role in (a", "!", "a b=c"} {
+		if _, err := ParseSelector(selector); err == nil || !strings.Contains(err.Error(), "invalid selector") {
+			t.Errorf("ParseSelector(%q) error = %v, want an invalid selector error", selector, err)
+		}
+	}
+}
+
+func TestMetadata_SelectorLabels(t *testing.T) {
+	m := Metadata{
+		Site:        "eu-1",
+		Environment: "production",
+		Labels:      map[string]interface{}{"role": "gateway", "rack": 4, "environment": "canary"},
+	}
+	got := m.SelectorLabels()
+	want := map[string]string{"site": "eu-1", "environment": "canary", "role": "gateway", "rack": "4"}
+	if len(got) != len(want) {
+		t.Fatalf("SelectorLabels() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("SelectorLabels()[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+}
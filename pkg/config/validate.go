@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Checks the schema can't express. Validate itself, and the constraints
+// declared in schemas/, are generated into generated.go.
+
+var sysctlKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.:/-]*$`)
+
+func (s *State) validateExtra(prefix string, errs *ValidationErrors) {
+	for key := range s.Sysctl {
+		if !sysctlKeyPattern.MatchString(key) {
+			errs.add(prefix+"sysctl", "invalid key %q", key)
+		}
+	}
+	s.validateDependencies(prefix, errs)
+}
+
+// validateDependencies reports depends_on references to resources the state
+// doesn't define, and otherwise any dependency cycle
+func (s *State) validateDependencies(prefix string, errs *ValidationErrors) {
+	deps := s.dependencies()
+	unknown := false
+	check := func(field string, refs []string) {
+		for _, ref := range refs {
+			if _, ok := deps[ref]; !ok {
+				errs.add(prefix+field+".depends_on", "unknown resource %q (want type:name, e.g. package:nginx)", ref)
+				unknown = true
+			}
+		}
+	}
+	for i, svc := range s.Services {
+		check(fmt.Sprintf("services[%d]", i), svc.DependsOn)
+	}
+	for i, r := range s.Repositories {
+		check(fmt.Sprintf("repositories[%d]", i), r.DependsOn)
+	}
+	for i, p := range s.Packages {
+		check(fmt.Sprintf("packages[%d]", i), p.DependsOn)
+	}
+	for i, f := range s.Files {
+		check(fmt.Sprintf("files[%d]", i), f.DependsOn)
+	}
+	if unknown {
+		return
+	}
+	if _, err := s.DependencyLevels(); err != nil {
+		errs.add(prefix+"depends_on", "%v", err)
+	}
+}
+
+func (f *FileConfig) validateExtra(prefix string, errs *ValidationErrors) {
+	if f.Type == FileTypeSymlink && f.State != FileStateAbsent && f.Target == "" {
+		errs.add(prefix+"target", "is required for symlinks")
+	}
+	if f.ValidateCmd != "" {
+		if !strings.Contains(f.ValidateCmd, "%s") {
+			errs.add(prefix+"validate", "must reference the file to check as %%s")
+		}
+		if f.Type == FileTypeDirectory || f.Type == FileTypeSymlink {
+			errs.add(prefix+"validate", "only applies to regular files")
+		}
+	}
+	validateNotify(prefix, f.Notify, errs)
+}
+
+func (p *PackageConfig) validateExtra(prefix string, errs *ValidationErrors) {
+	validateNotify(prefix, p.Notify, errs)
+}
+
+// validateNotify checks notify lists service names, not type:name references
+func validateNotify(prefix string, services []string, errs *ValidationErrors) {
+	for _, name := range services {
+		if name == "" || strings.ContainsAny(name, " \t:") {
+			errs.add(prefix+"notify", "invalid service name %q", name)
+		}
+	}
+}
+
+func (f *FirewallConfig) validateExtra(prefix string, errs *ValidationErrors) {
+	if f.Logging != "" && f.Provider != "" && f.Provider != FirewallProviderUfw {
+		errs.add(prefix+"logging", "is only supported by the ufw provider")
+	}
+	firewalld := f.Provider == FirewallProviderFirewalld
+	if firewalld && (f.DefaultPolicy.Incoming != "" || f.DefaultPolicy.Outgoing != "") {
+		errs.add(prefix+"default_policy", "is not supported by the firewalld provider, which follows each zone's target")
+	}
+	seen := make(map[string]bool, len(f.Interfaces))
+	for i, iface := range f.Interfaces {
+		field := fmt.Sprintf("%sinterfaces[%d].", prefix, i)
+		if seen[iface.Name] {
+			errs.add(field+"name", "duplicates interface %q", iface.Name)
+		}
+		seen[iface.Name] = true
+		if iface.Zone != "" && !firewalld {
+			errs.add(field+"zone", "is only supported by the firewalld provider")
+		}
+		if firewalld && iface.Zone == "" && len(iface.AllowedServices) > 0 {
+			errs.add(field+"zone", "is required by the firewalld provider to allow services on an interface")
+		}
+	}
+}
+
+func (r *RepoConfig) validateExtra(prefix string, errs *ValidationErrors) {
+	if r.Key != "" && r.KeyURL != "" {
+		errs.add(prefix+"key", "is mutually exclusive with key_url")
+	}
+	if r.KeySHA256 != "" && r.KeyURL == "" {
+		errs.add(prefix+"key_sha256", "requires key_url")
+	}
+}
+
+func (f *FreezeConfig) validateExtra(prefix string, errs *ValidationErrors) {
+	if f.Until == "" {
+		return
+	}
+	if _, err := time.Parse(time.RFC3339, f.Until); err != nil {
+		errs.add(prefix+"until", "must be an RFC 3339 time, got %q", f.Until)
+	}
+}
@@ -0,0 +1,43 @@
+package config
+
+import "testing"
+
+// Cases for the checks in validate.go and for constraints whose generated
+// examples don't exercise them; the generated cases live in generated_test.go
+func TestValidateExtra(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{ Validate() error }
+		wantErr bool
+	}{
+		{"FileConfig/validate", &FileConfig{Path: "/etc/nginx/nginx.conf", ValidateCmd: "nginx -t -c %s"}, false},
+		{"FileConfig/validate without path", &FileConfig{Path: "/etc/nginx/nginx.conf", ValidateCmd: "nginx -t"}, true},
+		{"FileConfig/validate directory", &FileConfig{Path: "/etc/nginx", Type: FileTypeDirectory, ValidateCmd: "test -d %s"}, true},
+		{"FirewallConfig/bad logging", &FirewallConfig{Logging: "loud"}, true},
+		{"FirewallConfig/logging without ufw", &FirewallConfig{Provider: FirewallProviderNftables, Logging: FirewallLoggingLow}, true},
+		{"FirewallConfig/logging", &FirewallConfig{Logging: FirewallLoggingMedium, DefaultPolicy: FirewallDefaultPolicy{Incoming: "deny"}}, false},
+		{"FirewallConfig/interfaces", &FirewallConfig{Interfaces: []FirewallInterface{{Name: "eth0", AllowedServices: []string{"ssh"}}, {Name: "wg0"}}}, false},
+		{"FirewallConfig/duplicate interface", &FirewallConfig{Interfaces: []FirewallInterface{{Name: "eth0"}, {Name: "eth0"}}}, true},
+		{"FirewallConfig/zone without firewalld", &FirewallConfig{Interfaces: []FirewallInterface{{Name: "eth0", Zone: "external"}}}, true},
+		{"FirewallConfig/zone", &FirewallConfig{Provider: FirewallProviderFirewalld, Interfaces: []FirewallInterface{{Name: "eth0", Zone: "external"}}}, false},
+		{"FirewallConfig/firewalld services without zone", &FirewallConfig{Provider: FirewallProviderFirewalld, Interfaces: []FirewallInterface{{Name: "eth0", AllowedServices: []string{"ssh"}}}}, true},
+		{"FirewallConfig/firewalld default policy", &FirewallConfig{Provider: FirewallProviderFirewalld, DefaultPolicy: FirewallDefaultPolicy{Incoming: "deny"}}, true},
+		{"FirewallInterface/shell metacharacters", &FirewallInterface{Name: "eth0; reboot"}, true},
+		{"FirewallInterface/vlan", &FirewallInterface{Name: "eth0.100"}, false},
+		{"FreezeConfig/bad until", &FreezeConfig{Enabled: true, Until: "tomorrow"}, true},
+		{"FreezeConfig/until", &FreezeConfig{Enabled: true, Until: "2026-01-02T15:04:05Z"}, false},
+		{"InotifyResource/unsupported type", &InotifyResource{Path: "/etc/nginx/nginx.conf", Resources: []string{"package:nginx"}}, true},
+		{"InotifyResource/file and service", &InotifyResource{Path: "/etc/nginx/nginx.conf", Resources: []string{"file:/etc/nginx/nginx.conf", "service:nginx"}}, false},
+		{"RepoConfig/url", &RepoConfig{Name: "example", URL: "https://example.com/apt"}, false},
+		{"SystemVersions/collected at", &SystemVersions{CollectedAt: "2024-01-01T00:00:00Z"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.value.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// extensionPrefix marks top-level keys that only hold YAML anchors for reuse
+// elsewhere in the document, as in Compose files
+const extensionPrefix = "x-"
+
+// stateDocument is a state document plus its top-level extension keys
+type stateDocument struct {
+	State      `yaml:",inline"`
+	Extensions map[string]yaml.Node `yaml:",inline"`
+}
+
+// ParseState parses a state document. Anchors, aliases and << merge keys are
+// expanded as YAML defines them: keys set in a mapping win over merged ones,
+// and in <<: [*a, *b] earlier mappings win over later ones. Top-level keys
+// starting with x- may hold anchors and are otherwise ignored. Constructs
+// that would silently lose data, like a second document or a second merge
+// key in one mapping, fail with the line they're on.
+func ParseState(data []byte) (*State, error) {
+	return parseState(data, false)
+}
+
+func parseState(data []byte, strict bool) (*State, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var root yaml.Node
+	if err := dec.Decode(&root); errors.Is(err, io.EOF) {
+		if strict {
+			return nil, fmt.Errorf("empty state document")
+		}
+		return &State{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("parse yaml: %w", err)
+	}
+
+	var next yaml.Node
+	if err := dec.Decode(&next); err == nil {
+		return nil, fmt.Errorf("parse yaml: line %d: a state file holds a single document; overlay several files instead", next.Line)
+	} else if !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse yaml: %w", err)
+	}
+	if err := checkMerges(&root); err != nil {
+		return nil, fmt.Errorf("parse yaml: %w", err)
+	}
+
+	dec = yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(strict)
+	var doc stateDocument
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parse yaml: %w", err)
+	}
+	if strict {
+		for key, value := range doc.Extensions {
+			if !strings.HasPrefix(key, extensionPrefix) {
+				return nil, fmt.Errorf("parse yaml: line %d: field %s not found in type config.State", value.Line, key)
+			}
+		}
+	}
+	return &doc.State, nil
+}
+
+// checkMerges rejects merge keys and aliases yaml.v3 would either reject
+// with an unhelpful message or decode into something other than intended
+func checkMerges(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			if err := checkMerges(child); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		merged := false
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Kind == yaml.ScalarNode && key.ShortTag() == "!!merge" {
+				if merged {
+					return fmt.Errorf("line %d: a mapping can have only one << merge key; merge several with <<: [*a, *b]", key.Line)
+				}
+				merged = true
+				if err := checkMergeValue(value); err != nil {
+					return err
+				}
+			}
+			if err := checkMerges(value); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if item.Kind == yaml.AliasNode && item.Alias != nil && item.Alias.Kind == yaml.SequenceNode {
+				return fmt.Errorf("line %d: *%s is a list and YAML can't splice lists into lists; anchor the items instead", item.Line, item.Value)
+			}
+			if err := checkMerges(item); err != nil {
+				return err
+			}
+		}
+	}
+	// Aliases are checked where their anchor is defined
+	return nil
+}
+
+// checkMergeValue checks a << value is a mapping or a list of mappings
+func checkMergeValue(value *yaml.Node) error {
+	isMapping := func(n *yaml.Node) bool {
+		if n.Kind == yaml.AliasNode && n.Alias != nil {
+			n = n.Alias
+		}
+		return n.Kind == yaml.MappingNode
+	}
+	if isMapping(value) {
+		return nil
+	}
+	if value.Kind == yaml.SequenceNode {
+		for _, item := range value.Content {
+			if !isMapping(item) {
+				return fmt.Errorf("line %d: << merges mappings, not %s", item.Line, describeNode(item))
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("line %d: << merges mappings, not %s", value.Line, describeNode(value))
+}
+
+// describeNode names the kind of YAML value n is (or refers to)
+func describeNode(n *yaml.Node) string {
+	if n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	switch n.Kind {
+	case yaml.SequenceNode:
+		return "a list"
+	case yaml.ScalarNode:
+		return fmt.Sprintf("the scalar %q", n.Value)
+	}
+	return "this value"
+}
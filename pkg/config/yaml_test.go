@@ -0,0 +1,124 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseState_Merges(t *testing.T) {
+	doc := `version: "1.0"
+x-base: &base
+  vm.swappiness: "10"
+  net.ipv4.ip_forward: "1"
+x-web: &web
+  <<: *base
+  net.core.somaxconn: "1024"
+  vm.swappiness: "1"
+x-db: &db
+  vm.dirty_ratio: "5"
+  net.core.somaxconn: "4096"
+x-service: &service
+  state: running
+  enabled: true
+sysctl:
+  <<: [*web, *db]
+  kernel.pid_max: "65536"
+  net.ipv4.ip_forward: "0"
+services:
+  - <<: *service
+    name: nginx
+  - <<: *service
+    name: cron
+    state: stopped
+`
+	for _, strict := range []bool{false, true} {
+		state, err := parseState([]byte(doc), strict)
+		if err != nil {
+			t.Fatalf("parseState(strict=%v) error = %v", strict, err)
+		}
+
+		wantSysctl := map[string]string{
+			"vm.swappiness":       "1",    // x-web's own key wins over the one it merges
+			"net.ipv4.ip_forward": "0",    // Keys set in the mapping win over merged ones
+			"net.core.somaxconn":  "1024", // Earlier mappings in the list win
+			"vm.dirty_ratio":      "5",
+			"kernel.pid_max":      "65536",
+		}
+		if !reflect.DeepEqual(state.Sysctl, wantSysctl) {
+			t.Errorf("strict=%v: sysctl = %v, want %v", strict, state.Sysctl, wantSysctl)
+		}
+
+		wantServices := []ServiceConfig{
+			{Name: "nginx", State: ServiceStateRunning, Enabled: true},
+			{Name: "cron", State: ServiceStateStopped, Enabled: true},
+		}
+		if !reflect.DeepEqual(state.Services, wantServices) {
+			t.Errorf("strict=%v: services = %+v, want %+v", strict, state.Services, wantServices)
+		}
+	}
+}
+
+func TestParseState_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{
+			name:    "second merge key",
+			doc:     "x-a: &a {a: \"1\"}\nx-b: &b {b: \"2\"}\nsysctl:\n  <<: *a\n  <<: *b\n",
+			wantErr: "line 5: a mapping can have only one << merge key",
+		},
+		{
+			name:    "merged scalar",
+			doc:     "x-a: &a \"1\"\nsysctl:\n  <<: *a\n",
+			wantErr: `line 3: << merges mappings, not the scalar "1"`,
+		},
+		{
+			name:    "merged list entry",
+			doc:     "x-a: &a {a: \"1\"}\nsysctl:\n  <<: [*a, [b]]\n",
+			wantErr: "line 3: << merges mappings, not a list",
+		},
+		{
+			name:    "list alias in list",
+			doc:     "x-svcs: &svcs\n  - {name: a, state: running}\nservices:\n  - *svcs\n  - {name: b, state: running}\n",
+			wantErr: "line 4: *svcs is a list",
+		},
+		{
+			name:    "several documents",
+			doc:     "sysctl: {a: \"1\"}\n---\nsysctl: {b: \"2\"}\n",
+			wantErr: "line 2: a state file holds a single document",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseState([]byte(tt.doc))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseState() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseStateStrict_Extensions(t *testing.T) {
+	// Only x- keys may hold anchors at the top level
+	_, err := ParseStateStrict([]byte("x-base: &base {a: \"1\"}\nsysctl: *base\n"))
+	if err != nil {
+		t.Errorf("ParseStateStrict() error = %v", err)
+	}
+
+	_, err = ParseStateStrict([]byte("version: \"1.0\"\nbase: &base {a: \"1\"}\nsysctl: *base\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2: field base not found") {
+		t.Errorf("ParseStateStrict() error = %v, want the unknown field reported", err)
+	}
+
+	// Lenient parsing ignores unknown keys, and empty documents are empty states
+	if state, err := ParseState([]byte("base: {a: \"1\"}\n")); err != nil || state == nil {
+		t.Errorf("ParseState() = %v, %v", state, err)
+	}
+	if state, err := ParseState(nil); err != nil || state == nil {
+		t.Errorf("ParseState(nil) = %v, %v", state, err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package gitops
+
+// repairOwnership is a no-op where Unix ownership doesn't apply
+func repairOwnership(root string) error {
+	return nil
+}
@@ -0,0 +1,45 @@
+//go:build unix
+
+package gitops
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// repairOwnership chowns entries in the clone that are owned by someone else
+// to the agent's user, e.g. after a manual clone as another user. Only root
+// can do that, so it is skipped otherwise. Entries that can't be repaired
+// don't stop the walk; their errors are returned together, one per path.
+func repairOwnership(root string) error {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	uid, gid := os.Geteuid(), os.Getegid()
+
+	var errs []error
+	walkErr := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			return nil
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok || (int(st.Uid) == uid && int(st.Gid) == gid) {
+			return nil
+		}
+		if err := os.Lchown(path, uid, gid); err != nil {
+			errs = append(errs, err)
+		}
+		return nil
+	})
+	return errors.Join(append(errs, walkErr)...)
+}
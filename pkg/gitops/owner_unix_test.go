@@ -0,0 +1,37 @@
+//go:build unix
+
+package gitops
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestRepairOwnership(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("chown needs root")
+	}
+	root := t.TempDir()
+	foreign := filepath.Join(root, "state.yaml")
+	if err := os.WriteFile(foreign, []byte("services: []\n"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.Chown(foreign, 4321, 4321); err != nil {
+		t.Fatalf("Failed to chown test file: %v", err)
+	}
+
+	if err := repairOwnership(root); err != nil {
+		t.Fatalf("repairOwnership() error = %v", err)
+	}
+
+	info, err := os.Stat(foreign)
+	if err != nil {
+		t.Fatalf("Failed to stat test file: %v", err)
+	}
+	st := info.Sys().(*syscall.Stat_t)
+	if int(st.Uid) != os.Geteuid() || int(st.Gid) != os.Getegid() {
+		t.Errorf("owner = %d:%d, want %d:%d", st.Uid, st.Gid, os.Geteuid(), os.Getegid())
+	}
+}
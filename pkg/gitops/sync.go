@@ -2,16 +2,20 @@ package gitops
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/power-edge/power-edge/pkg/config"
 )
 
+// DefaultLocalPath is where the repository is cloned when Config.LocalPath is unset
+const DefaultLocalPath = "/var/lib/power-edge/gitops"
+
 // GitOpsSync periodically syncs state configuration from a Git repository
 type GitOpsSync struct {
 	repoURL      string
@@ -20,6 +24,14 @@ type GitOpsSync struct {
 	localPath    string // Local clone path
 	pollInterval time.Duration
 	onUpdate     func(*config.State) error // Callback when state changes
+
+	verifySignature bool     // Require a trusted GPG signature on HEAD
+	allowedSigners  []string // Fingerprints allowed to sign (any trusted key when empty)
+	signersKeyring  string   // Keyring holding the signers' public keys
+	lastGoodCommit  string   // Last commit that passed signature verification
+	lastAppliedSum  string   // SHA256 of the state file last handed to onUpdate
+
+	auth Auth
 }
 
 // Config represents GitOps sync configuration
@@ -27,8 +39,17 @@ type Config struct {
 	RepoURL      string
 	Branch       string
 	StatePath    string        // e.g., "config/nodes/hostname/state.yaml"
+	LocalPath    string        // Clone directory, created 0700 (default DefaultLocalPath)
 	PollInterval time.Duration // e.g., 30s
 	OnUpdate     func(*config.State) error
+
+	// VerifySignature requires HEAD to be GPG-signed by an allowed key
+	// before its state is applied
+	VerifySignature bool
+	AllowedSigners  []string // Key fingerprints; empty trusts any key in the keyring
+	SignersKeyring  string   // Public keyring file (defaults to the user's GnuPG keyring)
+
+	Auth Auth // Credentials for private repositories
 }
 
 // NewGitOpsSync creates a new GitOps syncer
@@ -39,21 +60,33 @@ func NewGitOpsSync(cfg Config) *GitOpsSync {
 	if cfg.PollInterval == 0 {
 		cfg.PollInterval = 30 * time.Second
 	}
+	if cfg.LocalPath == "" {
+		cfg.LocalPath = DefaultLocalPath
+	}
 
 	return &GitOpsSync{
 		repoURL:      cfg.RepoURL,
 		branch:       cfg.Branch,
 		statePath:    cfg.StatePath,
-		localPath:    filepath.Join("/tmp", "power-edge-gitops"),
+		localPath:    cfg.LocalPath,
 		pollInterval: cfg.PollInterval,
 		onUpdate:     cfg.OnUpdate,
+
+		verifySignature: cfg.VerifySignature,
+		allowedSigners:  cfg.AllowedSigners,
+		signersKeyring:  cfg.SignersKeyring,
+
+		auth: cfg.Auth,
 	}
 }
 
 // Start begins polling the Git repository for changes
 func (g *GitOpsSync) Start(ctx context.Context) error {
-	log.Printf("🔄 Starting GitOps sync: %s@%s", g.repoURL, g.branch)
+	log.Printf("🔄 Starting GitOps sync: %s@%s (auth: %s)", redactURL(g.repoURL), g.branch, g.auth.method())
 	log.Printf("   Polling every %s for changes to %s", g.pollInterval, g.statePath)
+	if g.verifySignature {
+		log.Printf("   Requiring signed commits (%d allowed signers)", len(g.allowedSigners))
+	}
 
 	// Initial clone
 	if err := g.cloneOrPull(); err != nil {
@@ -89,22 +122,26 @@ func (g *GitOpsSync) Start(ctx context.Context) error {
 }
 
 func (g *GitOpsSync) cloneOrPull() error {
+	if err := g.prepareLocalPath(); err != nil {
+		return err
+	}
+
 	// Check if repo exists
 	if _, err := os.Stat(filepath.Join(g.localPath, ".git")); os.IsNotExist(err) {
 		// Clone
-		log.Printf("   Cloning %s...", g.repoURL)
-		cmd := exec.Command("git", "clone", "--depth=1", "--branch", g.branch, g.repoURL, g.localPath)
+		log.Printf("   Cloning %s...", redactURL(g.repoURL))
+		cmd := g.auth.command("clone", "--depth=1", "--branch", g.branch, g.repoURL, g.localPath)
 		if output, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("git clone failed: %s (output: %s)", err, string(output))
+			return fmt.Errorf("git clone failed: %s (output: %s)", err, g.auth.redact(string(output)))
 		}
 		return nil
 	}
 
 	// Pull latest
-	cmd := exec.Command("git", "-C", g.localPath, "pull", "origin", g.branch)
+	cmd := g.auth.command("-C", g.localPath, "pull", "origin", g.branch)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("git pull failed: %s (output: %s)", err, string(output))
+		return fmt.Errorf("git pull failed: %s (output: %s)", err, g.auth.redact(string(output)))
 	}
 
 	// Check if anything changed
@@ -112,7 +149,63 @@ func (g *GitOpsSync) cloneOrPull() error {
 		return nil
 	}
 
-	log.Printf("   ✅ Pulled latest changes from %s", g.repoURL)
+	log.Printf("   ✅ Pulled latest changes from %s", redactURL(g.repoURL))
+	return nil
+}
+
+// prepareLocalPath makes sure the clone directory exists, is private to the
+// agent, and holds a clone of the configured remote and branch. A clone of
+// anything else is removed so the next step clones afresh.
+func (g *GitOpsSync) prepareLocalPath() error {
+	if err := os.MkdirAll(g.localPath, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", g.localPath, err)
+	}
+	// State files may contain secrets; MkdirAll won't tighten an existing dir
+	if err := os.Chmod(g.localPath, 0700); err != nil {
+		return fmt.Errorf("failed to secure %s: %w", g.localPath, err)
+	}
+	// A clone that can't be fully repaired may still be usable; git reports
+	// the paths it can't write
+	if err := repairOwnership(g.localPath); err != nil {
+		log.Printf("   ⚠️  Failed to repair ownership in %s: %v", g.localPath, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(g.localPath, ".git")); os.IsNotExist(err) {
+		return nil
+	}
+
+	remote, err := g.auth.command("-C", g.localPath, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return g.resetLocalPath("cannot read remote")
+	}
+	branch, err := g.auth.command("-C", g.localPath, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return g.resetLocalPath("cannot read branch")
+	}
+
+	if got := strings.TrimSpace(string(remote)); got != g.repoURL {
+		return g.resetLocalPath(fmt.Sprintf("remote is %s", redactURL(got)))
+	}
+	if got := strings.TrimSpace(string(branch)); got != g.branch {
+		return g.resetLocalPath(fmt.Sprintf("branch is %s", got))
+	}
+	return nil
+}
+
+// resetLocalPath empties the clone directory so it can be re-cloned
+func (g *GitOpsSync) resetLocalPath(reason string) error {
+	log.Printf("   ⚠️  Existing clone at %s doesn't match %s@%s (%s), re-cloning", g.localPath, redactURL(g.repoURL), g.branch, reason)
+
+	entries, err := os.ReadDir(g.localPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", g.localPath, err)
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(g.localPath, entry.Name())); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", g.localPath, err)
+		}
+	}
+	g.lastGoodCommit = ""
 	return nil
 }
 
@@ -124,26 +217,64 @@ func (g *GitOpsSync) checkAndUpdate() error {
 		return fmt.Errorf("state file not found: %s", stateFile)
 	}
 
+	// Never hand unverified state to the callback; the previous state stays in effect
+	if g.verifySignature {
+		commit, err := g.verifyHead()
+		if err != nil {
+			log.Printf("🚨 GitOps signature verification FAILED, keeping last-known-good state (commit %s): %v", shortCommit(g.lastGoodCommit), err)
+			return fmt.Errorf("signature verification failed: %w", err)
+		}
+		if commit != g.lastGoodCommit {
+			log.Printf("   🔏 Commit %s signature verified", shortCommit(commit))
+		}
+		g.lastGoodCommit = commit
+	}
+
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		return fmt.Errorf("failed to read state: %w", err)
+	}
+
+	// Pulls that don't touch the state file mustn't trigger a reconcile
+	sum := fmt.Sprintf("%x", sha256.Sum256(data))
+	if sum == g.lastAppliedSum {
+		return nil
+	}
+
 	// Load state
-	newState, err := config.LoadStateConfig(stateFile)
+	newState, err := config.ParseState(data)
 	if err != nil {
 		return fmt.Errorf("failed to load state: %w", err)
 	}
 
 	// Trigger update callback
 	if g.onUpdate != nil {
-		log.Printf("   📝 State updated from Git, triggering reconciliation...")
+		log.Printf("   📝 State changed in Git (sha256 %s), triggering reconciliation...", sum[:12])
 		if err := g.onUpdate(newState); err != nil {
 			return fmt.Errorf("update callback failed: %w", err)
 		}
 	}
 
+	// Only remember the state once it has been applied, so a failed
+	// callback is retried on the next poll
+	g.lastAppliedSum = sum
 	return nil
 }
 
+func shortCommit(commit string) string {
+	if commit == "" {
+		return "none"
+	}
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
 func containsChange(output string) bool {
-	// Check if git pull output indicates changes
-	return !(output == "Already up to date.\n" ||
-		output == "Already up-to-date.\n" ||
+	// Check if git pull output indicates changes; the message may follow a
+	// "From <remote>" line
+	return !(strings.Contains(output, "Already up to date.") ||
+		strings.Contains(output, "Already up-to-date.") ||
 		len(output) == 0)
 }
@@ -0,0 +1,106 @@
+package gitops
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// verifyHead checks that the HEAD commit of the local clone carries a good
+// GPG signature from one of the allowed signers, returning the commit hash
+func (g *GitOpsSync) verifyHead() (string, error) {
+	out, err := exec.Command("git", "-C", g.localPath, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	commit := strings.TrimSpace(string(out))
+
+	cmd := exec.Command("git", "-C", g.localPath, "verify-commit", "--raw", commit)
+	if g.signersKeyring != "" {
+		home, err := importKeyring(g.signersKeyring)
+		if err != nil {
+			return commit, err
+		}
+		defer os.RemoveAll(home)
+		cmd.Env = append(os.Environ(), "GNUPGHOME="+home)
+	}
+
+	// --raw writes GnuPG status lines to stderr; a missing or bad
+	// signature makes verify-commit exit non-zero
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return commit, fmt.Errorf("commit %s is not signed by a trusted key: %s (output: %s)", commit, err, strings.TrimSpace(string(output)))
+	}
+
+	return commit, checkSignature(commit, string(output), g.allowedSigners)
+}
+
+// checkSignature checks GnuPG status output for a good signature by one of
+// the allowed signers, matched on either the signing subkey or the primary
+// key. An empty allowed list accepts any good signature.
+func checkSignature(commit, status string, allowed []string) error {
+	signer, primary := validSigFingerprints(status)
+	if signer == "" {
+		return fmt.Errorf("commit %s has no valid signature", commit)
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, fp := range allowed {
+		fp = normalizeFingerprint(fp)
+		if fp == signer || fp == primary {
+			return nil
+		}
+	}
+	return fmt.Errorf("commit %s is signed by %s, which is not an allowed signer", commit, signer)
+}
+
+// validSigFingerprints extracts the signing key and primary key fingerprints
+// from a "[GNUPG:] VALIDSIG" status line. GnuPG writes VALIDSIG for expired
+// signatures and keys too, so both are empty unless there is also a GOODSIG
+// and nothing marks the signature bad, expired or revoked.
+func validSigFingerprints(status string) (signer, primary string) {
+	good := false
+	for _, line := range strings.Split(status, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "[GNUPG:]" {
+			continue
+		}
+		switch fields[1] {
+		case "GOODSIG":
+			good = true
+		case "BADSIG", "ERRSIG", "EXPSIG", "EXPKEYSIG", "REVKEYSIG":
+			return "", ""
+		case "VALIDSIG":
+			if len(fields) >= 3 && signer == "" {
+				signer = normalizeFingerprint(fields[2])
+				primary = normalizeFingerprint(fields[len(fields)-1])
+			}
+		}
+	}
+	if !good {
+		return "", ""
+	}
+	return signer, primary
+}
+
+func normalizeFingerprint(fp string) string {
+	return strings.ToUpper(strings.ReplaceAll(fp, " ", ""))
+}
+
+// importKeyring loads the public keys in keyring into a throwaway GnuPG home,
+// so only those keys are trusted for verification
+func importKeyring(keyring string) (string, error) {
+	home, err := os.MkdirTemp("", "power-edge-gnupg-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create GnuPG home: %w", err)
+	}
+
+	cmd := exec.Command("gpg", "--homedir", home, "--batch", "--quiet", "--import", keyring)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.RemoveAll(home)
+		return "", fmt.Errorf("failed to import signers keyring %s: %s (output: %s)", keyring, err, string(output))
+	}
+	return home, nil
+}
@@ -0,0 +1,86 @@
+package gitops
+
+import "testing"
+
+const (
+	testSigner  = "0123456789ABCDEF0123456789ABCDEF01234567"
+	testPrimary = "89ABCDEF0123456789ABCDEF0123456789ABCDEF"
+	testLongID  = "89ABCDEF01234567"
+	testValid   = "[GNUPG:] VALIDSIG " + testSigner + " 2024-01-01 1704067200 0 4 0 22 10 00 " + testPrimary
+)
+
+func TestCheckSignature(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		allowed []string
+		wantErr bool
+	}{
+		{
+			name:   "good signature, any signer",
+			status: "[GNUPG:] NEWSIG\n[GNUPG:] GOODSIG " + testLongID + " Ops <ops@example.com>\n" + testValid + "\n[GNUPG:] TRUST_UNDEFINED 0 pgp",
+		},
+		{
+			name:    "good signature by allowed subkey",
+			status:  "[GNUPG:] GOODSIG " + testLongID + " Ops <ops@example.com>\n" + testValid,
+			allowed: []string{testSigner},
+		},
+		{
+			name:    "good signature by allowed primary key, spaced and lowercase",
+			status:  "[GNUPG:] GOODSIG " + testLongID + " Ops <ops@example.com>\n" + testValid,
+			allowed: []string{"89ab cdef 0123 4567 89ab  cdef 0123 4567 89ab cdef"},
+		},
+		{
+			name:    "bad signature",
+			status:  "[GNUPG:] NEWSIG\n[GNUPG:] BADSIG " + testLongID + " Ops <ops@example.com>",
+			wantErr: true,
+		},
+		{
+			name:    "expired signature",
+			status:  "[GNUPG:] EXPSIG " + testLongID + " Ops <ops@example.com>\n" + testValid,
+			wantErr: true,
+		},
+		{
+			name:    "expired key",
+			status:  "[GNUPG:] EXPKEYSIG " + testLongID + " Ops <ops@example.com>\n" + testValid,
+			wantErr: true,
+		},
+		{
+			name:    "revoked key",
+			status:  "[GNUPG:] REVKEYSIG " + testLongID + " Ops <ops@example.com>\n" + testValid,
+			wantErr: true,
+		},
+		{
+			name:    "unknown key",
+			status:  "[GNUPG:] ERRSIG " + testLongID + " 22 10 00 1704067200 9 -\n[GNUPG:] NO_PUBKEY " + testLongID,
+			wantErr: true,
+		},
+		{
+			name:    "untrusted signer",
+			status:  "[GNUPG:] GOODSIG " + testLongID + " Ops <ops@example.com>\n" + testValid,
+			allowed: []string{"FEDCBA9876543210FEDCBA9876543210FEDCBA98"},
+			wantErr: true,
+		},
+		{
+			name:    "unsigned",
+			status:  "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSignature("abc123", tt.status, tt.allowed)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidSigFingerprints(t *testing.T) {
+	signer, primary := validSigFingerprints("[GNUPG:] GOODSIG " + testLongID + " Ops <ops@example.com>\n" + testValid)
+	if signer != testSigner || primary != testPrimary {
+		t.Errorf("validSigFingerprints() = %q, %q, want %q, %q", signer, primary, testSigner, testPrimary)
+	}
+}
@@ -0,0 +1,151 @@
+// Package logging configures the slog logger shared by the agent, the server
+// and the packages they use. The text format keeps the human-friendly lines
+// operators are used to; the JSON format adds structured fields for log
+// ingestion.
+package logging
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Supported values of -log-format
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// RunIDKey is the attribute carrying the reconcile run ID; the text format
+// shows it as a "[run <id>]" prefix
+const RunIDKey = "run_id"
+
+// Options selects the log format and minimum level
+type Options struct {
+	Format string // text (default) or json
+	Level  string // debug, info (default), warn or error
+}
+
+// AddFlags registers -log-format and -log-level on fs
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Format, "log-format", FormatText, "Log format: text (human-friendly) or json (structured)")
+	fs.StringVar(&o.Level, "log-level", "info", "Minimum log level: debug, info, warn, error")
+}
+
+// ParseLevel parses a level name as accepted by -log-level
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("invalid log level %q (must be debug, info, warn or error)", s)
+	}
+}
+
+// NewHandler returns a handler writing records at or above level to w
+func NewHandler(w io.Writer, format string, level slog.Leveler) (slog.Handler, error) {
+	switch format {
+	case "", FormatText:
+		return &textHandler{mu: &sync.Mutex{}, w: w, level: level}, nil
+	case FormatJSON:
+		return slog.NewJSONHandler(w, &slog.HandlerOptions{
+			Level: level,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				// Messages are indented for the text format
+				if len(groups) == 0 && a.Key == slog.MessageKey {
+					return slog.String(slog.MessageKey, strings.TrimSpace(a.Value.String()))
+				}
+				return a
+			},
+		}), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q (must be text or json)", format)
+	}
+}
+
+// Setup makes a logger writing to w the slog default. Output of the standard
+// log package is routed through it too, at info level.
+func Setup(w io.Writer, opts Options) error {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	handler, err := NewHandler(w, opts.Format, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// textHandler writes "<time> [run <id>] <message>" lines, the format the
+// agent has always logged in. Attributes other than the run ID are only
+// emitted by the JSON format; messages already spell out what matters.
+type textHandler struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	level slog.Leveler
+	runID string
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	runID := h.runID
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == RunIDKey {
+			runID = a.Value.String()
+			return false
+		}
+		return true
+	})
+
+	var b strings.Builder
+	if !r.Time.IsZero() {
+		b.WriteString(r.Time.Format("2006/01/02 15:04:05 "))
+	}
+	if runID != "" {
+		b.WriteString("[run " + runID + "] ")
+	}
+	b.WriteString(r.Message)
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	for _, a := range attrs {
+		if a.Key == RunIDKey {
+			clone.runID = a.Value.String()
+		}
+	}
+	return &clone
+}
+
+func (h *textHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// Fatalf logs at error level, so the message survives any -log-level, and
+// exits with status 1
+func Fatalf(format string, args ...interface{}) {
+	slog.Error(fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
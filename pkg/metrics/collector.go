@@ -1,129 +1,364 @@
 package metrics
 
 import (
+	"context"
+	"crypto/sha256"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
+	"os"
 	"os/exec"
+	"runtime"
+	"slices"
 	"strings"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/power-edge/power-edge/pkg/apply"
 	"github.com/power-edge/power-edge/pkg/config"
 )
 
+const namespace = "edge_state"
+
 // Collector collects and exposes Prometheus metrics
 type Collector struct {
-	state   *config.State
-	metrics map[string]MetricValue
-}
+	state    *config.State
+	registry *prometheus.Registry
 
-// MetricValue represents a single metric
-type MetricValue struct {
-	Value       float64
-	Labels      map[string]string
-	Description string
+	serviceCompliant *prometheus.GaugeVec
+	sysctlCompliant  *prometheus.GaugeVec
+	packageCompliant *prometheus.GaugeVec
+	fileCompliant    *prometheus.GaugeVec
+	firewallEnabled  *prometheus.GaugeVec
+	stateInfo        *prometheus.GaugeVec
+	buildInfo        *prometheus.GaugeVec
+	reconcile        *reconcileMetrics
+
+	packages *apply.PackageApplier
+	files    *apply.FileApplier
+	firewall *apply.FirewallApplier
 }
 
 // NewCollector creates a new metrics collector
 func NewCollector(state *config.State) *Collector {
-	return &Collector{
-		state:   state,
-		metrics: make(map[string]MetricValue),
+	c := &Collector{
+		state:    state,
+		registry: prometheus.NewRegistry(),
+
+		serviceCompliant: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "service_compliant",
+			Help:      "Service compliance (1 = compliant, 0 = non-compliant)",
+		}, []string{"name", "expected", "actual"}),
+
+		sysctlCompliant: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sysctl_compliant",
+			Help:      "Sysctl parameter compliance (1 = compliant, 0 = non-compliant)",
+		}, []string{"key", "expected", "actual"}),
+
+		packageCompliant: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "package_compliant",
+			Help:      "Package compliance (1 = compliant, 0 = non-compliant)",
+		}, []string{"name", "expected", "version"}),
+
+		fileCompliant: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "file_compliant",
+			Help:      "File compliance: existence and content checksum (1 = compliant, 0 = non-compliant)",
+		}, []string{"path", "expected"}),
+
+		firewallEnabled: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "firewall_compliant",
+			Help:      "Firewall enabled state compliance (1 = compliant, 0 = non-compliant)",
+		}, []string{"expected", "actual"}),
+
+		stateInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "info",
+			Help:      "Edge state information",
+		}, []string{"site", "environment"}),
+
+		buildInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "build_info",
+			Help:      "Build information of the running agent",
+		}, []string{"version", "git_commit", "build_time", "go_version"}),
+
+		reconcile: newReconcileMetrics(),
+
+		packages: apply.NewPackageApplier(),
+		files:    apply.NewFileApplier(),
+		firewall: apply.NewFirewallApplier(),
 	}
+
+	c.registry.MustRegister(
+		c.serviceCompliant,
+		c.sysctlCompliant,
+		c.packageCompliant,
+		c.fileCompliant,
+		c.firewallEnabled,
+		c.stateInfo,
+		c.buildInfo,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	c.registry.MustRegister(c.reconcile.collectors()...)
+
+	c.stateInfo.WithLabelValues(state.Metadata.Site, state.Metadata.Environment).Set(1)
+
+	return c
 }
 
-// CheckAndUpdate runs state checks and updates metrics
-func (c *Collector) CheckAndUpdate(state *config.State) error {
+// SetBuildInfo records the agent's build metadata in the build-info gauge
+func (c *Collector) SetBuildInfo(version, gitCommit, buildTime string) {
+	c.buildInfo.Reset()
+	c.buildInfo.WithLabelValues(version, gitCommit, buildTime, runtime.Version()).Set(1)
+}
+
+// Registry returns the registry metrics are exposed from, so other packages
+// can register their own collectors alongside the state gauges
+func (c *Collector) Registry() *prometheus.Registry {
+	return c.registry
+}
+
+// CheckAndUpdate runs state checks and updates metrics. Commands are run
+// with the privilege command and timeout carried by ctx.
+func (c *Collector) CheckAndUpdate(ctx context.Context, state *config.State) error {
 	log.Println("Checking services...")
 	if err := c.checkServices(state.Services); err != nil {
 		log.Printf("Service check error: %v", err)
 	}
 
 	log.Println("Checking sysctl parameters...")
-	if err := c.checkSysctl(state.Sysctl); err != nil {
+	if err := c.checkSysctl(state.Sysctl, state.SysctlPolicy); err != nil {
 		log.Printf("Sysctl check error: %v", err)
 	}
 
+	if len(state.Packages) > 0 {
+		log.Println("Checking packages...")
+		c.checkPackages(ctx, state.Packages)
+	}
+
+	if len(state.Files) > 0 {
+		log.Println("Checking files...")
+		c.checkFiles(ctx, state.Files)
+	}
+
+	if state.Firewall.Configured() {
+		log.Println("Checking firewall...")
+		c.checkFirewall(ctx, &state.Firewall)
+	}
+
 	return nil
 }
 
 func (c *Collector) checkServices(services []config.ServiceConfig) error {
-	for _, svc := range services {
+	// The actual label changes with the observed state, so drop stale series
+	c.serviceCompliant.Reset()
+
+	// Each check forks systemctl, so run them in parallel; results are
+	// logged and exported in state order below
+	type serviceCheck struct {
+		status string
+		err    error
+		absent bool
+	}
+	checks := make([]serviceCheck, len(services))
+	apply.CheckEach(len(services), apply.DefaultCheckConcurrency, func(i int) {
+		svc := services[i]
+		if svc.State == config.ServiceStateAbsent {
+			checks[i].absent = unitAbsent(svc.Name)
+			return
+		}
 		// Check if service is active
-		cmd := exec.Command("systemctl", "is-active", svc.Name)
-		output, err := cmd.Output()
-		status := strings.TrimSpace(string(output))
+		output, err := exec.Command("systemctl", "is-active", svc.Name).Output()
+		checks[i] = serviceCheck{status: strings.TrimSpace(string(output)), err: err}
+	})
+
+	for i, svc := range services {
+		status, err := checks[i].status, checks[i].err
 
 		compliant := 0.0
-		if err == nil && status == "active" && svc.State == "running" {
+		if svc.State == config.ServiceStateAbsent {
+			if checks[i].absent {
+				compliant = 1.0
+				status = "absent"
+				log.Printf("  ✓ %s: absent (compliant)", svc.Name)
+			} else {
+				log.Printf("  ✗ %s: unit present (expected: absent)", svc.Name)
+			}
+		} else if err == nil && status == "active" && svc.State == "running" {
 			compliant = 1.0
 			log.Printf("  ✓ %s: active (compliant)", svc.Name)
 		} else {
 			log.Printf("  ✗ %s: %s (expected: %s)", svc.Name, status, svc.State)
 		}
 
-		c.metrics[fmt.Sprintf("service_compliant{name=%q}", svc.Name)] = MetricValue{
-			Value: compliant,
-			Labels: map[string]string{
-				"name":     svc.Name,
-				"expected": string(svc.State),
-				"actual":   status,
-			},
-			Description: "Service compliance (1 = compliant, 0 = non-compliant)",
-		}
+		c.serviceCompliant.WithLabelValues(svc.Name, string(svc.State), status).Set(compliant)
 	}
 
 	return nil
 }
 
-func (c *Collector) checkSysctl(params map[string]string) error {
-	for key, expectedValue := range params {
-		cmd := exec.Command("sysctl", "-n", key)
-		output, err := cmd.Output()
-		actualValue := strings.TrimSpace(string(output))
+// unitAbsent reports whether systemd has no unit file for name
+func unitAbsent(name string) bool {
+	output, err := exec.Command("systemctl", "show", name, "-p", "LoadState", "--value").Output()
+	return err == nil && strings.TrimSpace(string(output)) == "not-found"
+}
+
+func (c *Collector) checkSysctl(params map[string]string, policy config.SysctlPolicy) error {
+	c.sysctlCompliant.Reset()
+
+	keys := slices.Sorted(maps.Keys(params))
+	type sysctlCheck struct {
+		actual string
+		err    error
+	}
+	checks := make([]sysctlCheck, len(keys))
+	apply.CheckEach(len(keys), apply.DefaultCheckConcurrency, func(i int) {
+		output, err := exec.Command("sysctl", "-n", keys[i]).Output()
+		// Normalized so a tab-separated list value matches a spaced one
+		checks[i] = sysctlCheck{actual: apply.NormalizeSysctlValue(string(output)), err: err}
+	})
+
+	for i, key := range keys {
+		expectedValue := params[key]
+		actualValue, err := checks[i].actual, checks[i].err
 
 		compliant := 0.0
-		if err == nil && actualValue == expectedValue {
+		if err == nil && apply.SysctlValuesEqual(expectedValue, actualValue, slices.Contains(policy.Unordered, key)) {
 			compliant = 1.0
 			log.Printf("  ✓ %s: %s (compliant)", key, actualValue)
 		} else {
 			log.Printf("  ✗ %s: %s (expected: %s)", key, actualValue, expectedValue)
 		}
 
-		c.metrics[fmt.Sprintf("sysctl_compliant{key=%q}", key)] = MetricValue{
-			Value: compliant,
-			Labels: map[string]string{
-				"key":      key,
-				"expected": expectedValue,
-				"actual":   actualValue,
-			},
-			Description: "Sysctl parameter compliance (1 = compliant, 0 = non-compliant)",
-		}
+		c.sysctlCompliant.WithLabelValues(key, expectedValue, actualValue).Set(compliant)
 	}
 
 	return nil
 }
 
-// Handler returns an HTTP handler for Prometheus metrics
-func (c *Collector) Handler() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
-
-		// Write metrics in Prometheus format
-		for name, metric := range c.metrics {
-			// Write HELP
-			fmt.Fprintf(w, "# HELP %s\n", metric.Description)
-			// Write TYPE
-			fmt.Fprintf(w, "# TYPE edge_state_compliance gauge\n")
-			// Write metric
-			fmt.Fprintf(w, "edge_state_compliance%s %v\n", name, metric.Value)
-		}
+func (c *Collector) checkPackages(ctx context.Context, packages []config.PackageConfig) {
+	c.packageCompliant.Reset()
 
-		// Write metadata
-		fmt.Fprintf(w, "# HELP edge_state_info Edge state information\n")
-		fmt.Fprintf(w, "# TYPE edge_state_info gauge\n")
-		fmt.Fprintf(w, "edge_state_info{site=%q,environment=%q} 1\n",
-			c.state.Metadata.Site,
-			c.state.Metadata.Environment,
-		)
+	type packageCheck struct {
+		installed bool
+		version   string
+		err       error
+	}
+	checks := make([]packageCheck, len(packages))
+	apply.CheckEach(len(packages), apply.DefaultCheckConcurrency, func(i int) {
+		installed, version, _, err := c.packages.Check(ctx, packages[i].Name)
+		checks[i] = packageCheck{installed, version, err}
 	})
+
+	for i, pkg := range packages {
+		expected := string(pkg.State)
+		if expected == "" {
+			expected = string(config.PackageStatePresent)
+		}
+
+		installed, version, err := checks[i].installed, checks[i].version, checks[i].err
+
+		compliant := 0.0
+		switch {
+		case err != nil:
+			log.Printf("  ✗ %s: check failed: %v", pkg.Name, err)
+		case pkg.State == config.PackageStateAbsent && !installed:
+			compliant = 1.0
+		case pkg.State != config.PackageStateAbsent && installed && (pkg.Version == "" || pkg.Version == version):
+			compliant = 1.0
+		}
+
+		if compliant == 1.0 {
+			log.Printf("  ✓ %s: %s (compliant)", pkg.Name, expected)
+		} else if err == nil {
+			log.Printf("  ✗ %s: installed=%v version=%s (expected: %s %s)", pkg.Name, installed, version, expected, pkg.Version)
+		}
+
+		c.packageCompliant.WithLabelValues(pkg.Name, expected, version).Set(compliant)
+	}
+}
+
+func (c *Collector) checkFiles(ctx context.Context, files []config.FileConfig) {
+	c.fileCompliant.Reset()
+
+	for _, file := range files {
+		path := string(file.Path)
+		absent := file.State == config.FileStateAbsent
+		expected := string(config.FileStatePresent)
+		if absent {
+			expected = string(config.FileStateAbsent)
+		}
+
+		// Without an explicit checksum, compare against inline content.
+		// Templated or interpolated content is only known at apply time, so only existence is checked.
+		wantSum := file.SHA256
+		if wantSum == "" && file.Content != "" && !file.Template && !file.Interpolate {
+			wantSum = fmt.Sprintf("%x", sha256.Sum256([]byte(file.Content)))
+		}
+
+		var exists bool
+		var sum string
+		var err error
+		if file.Type == config.FileTypeDirectory || file.Type == config.FileTypeSymlink {
+			// Checksums only apply to regular files
+			_, statErr := os.Lstat(path)
+			exists, wantSum = statErr == nil, ""
+			if statErr != nil && !os.IsNotExist(statErr) {
+				err = statErr
+			}
+		} else {
+			exists, _, _, _, sum, _, err = c.files.Check(ctx, path)
+		}
+
+		compliant := 0.0
+		switch {
+		case err != nil:
+			log.Printf("  ✗ %s: check failed: %v", path, err)
+		case absent && !exists:
+			compliant = 1.0
+		case !absent && exists && (wantSum == "" || sum == wantSum):
+			compliant = 1.0
+		}
+
+		if compliant == 1.0 {
+			log.Printf("  ✓ %s: %s (compliant)", path, expected)
+		} else if err == nil {
+			log.Printf("  ✗ %s: exists=%v (expected: %s)", path, exists, expected)
+		}
+
+		c.fileCompliant.WithLabelValues(path, expected).Set(compliant)
+	}
+}
+
+func (c *Collector) checkFirewall(ctx context.Context, fw *config.FirewallConfig) {
+	c.firewallEnabled.Reset()
+
+	enabled, err := c.firewall.Check(ctx, fw)
+	if err != nil {
+		log.Printf("  ✗ firewall: check failed: %v", err)
+	}
+
+	compliant := 0.0
+	if err == nil && enabled == fw.Enabled {
+		compliant = 1.0
+		log.Printf("  ✓ firewall: enabled=%v (compliant)", enabled)
+	} else if err == nil {
+		log.Printf("  ✗ firewall: enabled=%v (expected: %v)", enabled, fw.Enabled)
+	}
+
+	c.firewallEnabled.WithLabelValues(fmt.Sprint(fw.Enabled), fmt.Sprint(enabled)).Set(compliant)
+}
+
+// Handler returns an HTTP handler for Prometheus metrics
+func (c *Collector) Handler() http.Handler {
+	return promhttp.HandlerFor(c.registry, promhttp.HandlerOpts{})
 }
@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// PushJob is the job name metrics are pushed to a Pushgateway under
+const PushJob = "power-edge-client"
+
+// Push sends the current metrics to the Pushgateway at url, grouped by node
+// ID, for nodes Prometheus can't scrape. Each push replaces the node's
+// previous one, so metrics that went away don't linger.
+func (c *Collector) Push(ctx context.Context, client *http.Client, url, nodeID string) error {
+	pusher := push.New(url, PushJob).
+		Gatherer(c.registry).
+		Grouping("node_id", nodeID).
+		Client(client)
+	if err := pusher.PushContext(ctx); err != nil {
+		return fmt.Errorf("failed to push metrics to %s: %w", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestCollector_Push(t *testing.T) {
+	var method, path, body string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+
+	c := NewCollector(&config.State{})
+	c.SetBuildInfo("1.2.3", "abc", "now")
+	if err := c.Push(context.Background(), gateway.Client(), gateway.URL, "edge-01"); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+
+	if method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", method)
+	}
+	if want := "/metrics/job/" + PushJob + "/node_id/edge-01"; path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+	if !strings.Contains(body, "build_info") {
+		t.Errorf("pushed metrics don't include build_info")
+	}
+}
+
+func TestCollector_PushFailure(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer gateway.Close()
+
+	c := NewCollector(&config.State{})
+	err := c.Push(context.Background(), gateway.Client(), gateway.URL, "edge-01")
+	if err == nil || !strings.Contains(err.Error(), "failed to push metrics") {
+		t.Errorf("Push() error = %v, want a push failure", err)
+	}
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/power-edge/power-edge/pkg/reconciler"
+)
+
+// reconcileMetrics tracks reconciliation activity
+type reconcileMetrics struct {
+	actions      *prometheus.CounterVec
+	failures     *prometheus.CounterVec
+	enforcements *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	passDuration prometheus.Histogram
+}
+
+func newReconcileMetrics() *reconcileMetrics {
+	return &reconcileMetrics{
+		actions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "power_edge",
+			Subsystem: "reconcile",
+			Name:      "actions_total",
+			Help:      "Enforcement actions taken to fix drift",
+		}, []string{"type"}),
+
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "power_edge",
+			Subsystem: "reconcile",
+			Name:      "failures_total",
+			Help:      "Resources that failed to reconcile",
+		}, []string{"type"}),
+
+		enforcements: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "power_edge",
+			Subsystem: "resource",
+			Name:      "enforcements_total",
+			Help:      "Times drift on a resource was fixed since the agent started",
+		}, []string{"type", "name"}),
+
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "power_edge",
+			Subsystem: "reconcile",
+			Name:      "duration_seconds",
+			Help:      "Time spent reconciling a single resource, including retries",
+			Buckets:   []float64{.005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+		}, []string{"type"}),
+
+		passDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "power_edge",
+			Subsystem: "reconcile",
+			Name:      "pass_duration_seconds",
+			Help:      "Time taken by a full reconciliation pass",
+			Buckets:   []float64{.1, .5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+		}),
+	}
+}
+
+func (m *reconcileMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.actions, m.failures, m.enforcements, m.duration, m.passDuration}
+}
+
+// RecordReconcile updates reconciliation metrics from the results of a pass.
+// Only real changes count as actions; dry-run results are just observed.
+func (c *Collector) RecordReconcile(results []reconciler.ReconcileResult, passDuration time.Duration) {
+	for _, result := range results {
+		c.reconcile.duration.WithLabelValues(result.ResourceType).Observe(result.Duration.Seconds())
+
+		switch {
+		case result.Error != nil:
+			c.reconcile.failures.WithLabelValues(result.ResourceType).Inc()
+		case !result.WasCompliant && !result.DryRun:
+			c.reconcile.actions.WithLabelValues(result.ResourceType).Inc()
+		}
+	}
+
+	c.reconcile.passDuration.Observe(passDuration.Seconds())
+}
+
+// RecordResult counts a result as its pass observes it, so event-triggered
+// passes count as well as periodic ones. Each real fix of drift counts
+// towards the resource's enforcements; notified restarts, hooks and failures
+// don't. The counts live as long as the process, so a resource whose count
+// keeps rising is being changed behind the agent's back.
+func (c *Collector) RecordResult(record reconciler.ResultRecord) {
+	if record.WasCompliant || record.DryRun || record.Error != "" || record.ResourceType == "hook" {
+		return
+	}
+	if strings.HasPrefix(record.Action, "skipped") || strings.HasPrefix(record.Action, "restart (notified") {
+		return
+	}
+	c.reconcile.enforcements.WithLabelValues(record.ResourceType, record.ResourceName).Inc()
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+	"github.com/power-edge/power-edge/pkg/reconciler"
+)
+
+func TestCollector_RecordResult(t *testing.T) {
+	c := NewCollector(&config.State{})
+	for _, record := range []reconciler.ResultRecord{
+		{ResourceType: "file", ResourceName: "/etc/hosts", Action: "updated content"},
+		{ResourceType: "file", ResourceName: "/etc/hosts", Action: "updated content"},
+		{ResourceType: "service", ResourceName: "nginx", Action: "started service"},
+		{ResourceType: "service", ResourceName: "nginx", WasCompliant: true},
+		{ResourceType: "service", ResourceName: "nginx", Action: "restart (notified by file:/etc/hosts)"},
+		{ResourceType: "service", ResourceName: "nginx", Action: "would start", DryRun: true},
+		{ResourceType: "service", ResourceName: "nginx", Action: "start", Error: "failed"},
+		{ResourceType: "hook", ResourceName: "pre", Action: "ran"},
+	} {
+		c.RecordResult(record)
+	}
+
+	rec := httptest.NewRecorder()
+	c.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	var got []string
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, "power_edge_resource_enforcements_total{") {
+			got = append(got, line)
+		}
+	}
+	want := []string{
+		`power_edge_resource_enforcements_total{name="/etc/hosts",type="file"} 2`,
+		`power_edge_resource_enforcements_total{name="nginx",type="service"} 1`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("enforcements =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,102 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// dependencyWaves splits state into the rounds its depends_on references
+// call for: each wave holds the resources whose dependencies are all in
+// earlier waves. Sysctl, firewall and DNS settings can't declare
+// dependencies and go in the first wave. Without any depends_on the state
+// is a single wave, reconciled in the usual type order. Waves are copies, so
+// state itself is never changed.
+func dependencyWaves(state *config.State) ([]*config.State, error) {
+	if !state.HasDependencies() {
+		wave := *state
+		return []*config.State{&wave}, nil
+	}
+	levels, err := state.DependencyLevels()
+	if err != nil {
+		return nil, err
+	}
+
+	depth := 0
+	for _, level := range levels {
+		depth = max(depth, level+1)
+	}
+
+	waves := make([]*config.State, depth)
+	for i := range waves {
+		wave := *state
+		if i > 0 {
+			wave.Sysctl = nil
+			wave.Firewall = config.FirewallConfig{}
+			wave.DNS = config.DNSConfig{}
+		}
+		level := func(resourceType, name string) bool {
+			return levels[config.ResourceRef(resourceType, name)] == i
+		}
+		wave.Services = filter(state.Services, func(s config.ServiceConfig) bool { return level("service", s.Name) })
+		wave.Repositories = filter(state.Repositories, func(r config.RepoConfig) bool { return level("repository", r.Name) })
+		wave.Packages = filter(state.Packages, func(p config.PackageConfig) bool { return level("package", p.Name) })
+		wave.Files = filter(state.Files, func(f config.FileConfig) bool { return level("file", string(f.Path)) })
+		waves[i] = &wave
+	}
+	return waves, nil
+}
+
+func filter[T any](items []T, keep func(T) bool) []T {
+	var kept []T
+	for _, item := range items {
+		if keep(item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// dropBlocked returns a copy of wave without the resources that depend on a
+// failed resource, and a failed result for each of those. The removed
+// resources count as failed themselves, so their own dependents are dropped
+// in later waves.
+func (r *Reconciler) dropBlocked(ctx context.Context, wave *config.State, failed map[string]bool) (*config.State, []ReconcileResult) {
+	var blocked []ReconcileResult
+	keep := func(resourceType, name string, deps []string) bool {
+		mode := r.modeFor(ctx, resourceType)
+		if mode == ModeDisabled {
+			return true
+		}
+		for _, dep := range deps {
+			if failed[dep] {
+				failed[config.ResourceRef(resourceType, name)] = true
+				blocked = append(blocked, ReconcileResult{
+					ResourceType: resourceType,
+					ResourceName: name,
+					Error:        fmt.Errorf("not reconciled: dependency %s failed", dep),
+					DryRun:       mode == ModeDryRun,
+					RunID:        RunIDFromContext(ctx),
+				})
+				return false
+			}
+		}
+		return true
+	}
+	kept := *wave
+	kept.Services = filter(wave.Services, func(s config.ServiceConfig) bool { return keep("service", s.Name, s.DependsOn) })
+	kept.Repositories = filter(wave.Repositories, func(r config.RepoConfig) bool { return keep("repository", r.Name, r.DependsOn) })
+	kept.Packages = filter(wave.Packages, func(p config.PackageConfig) bool { return keep("package", p.Name, p.DependsOn) })
+	kept.Files = filter(wave.Files, func(f config.FileConfig) bool { return keep("file", string(f.Path), f.DependsOn) })
+	return &kept, blocked
+}
+
+// recordFailures adds the resources that failed in results to failed
+func recordFailures(results []ReconcileResult, failed map[string]bool) {
+	for _, result := range results {
+		if result.Error != nil {
+			failed[config.ResourceRef(result.ResourceType, result.ResourceName)] = true
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package reconciler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestReconcileAll_DependsOn(t *testing.T) {
+	dir := t.TempDir()
+	regular := filepath.Join(dir, "regular")
+	if err := os.WriteFile(regular, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(dir, "base.conf")
+	app := filepath.Join(dir, "app.conf")
+	broken := filepath.Join(regular, "broken.conf") // Its parent is a file, so writing fails
+	dependent := filepath.Join(dir, "dependent.conf")
+	transitive := filepath.Join(dir, "transitive.conf")
+
+	state := &config.State{
+		Files: []config.FileConfig{
+			{Path: config.UnixPath(app), Content: "app", DependsOn: []string{"file:" + base}},
+			{Path: config.UnixPath(base), Content: "base"},
+			{Path: config.UnixPath(transitive), Content: "t", DependsOn: []string{"file:" + dependent}},
+			{Path: config.UnixPath(dependent), Content: "d", DependsOn: []string{"file:" + broken}},
+			{Path: config.UnixPath(broken), Content: "b"},
+		},
+	}
+
+	r := NewReconciler(ModeEnforce)
+	results, err := r.ReconcileAll(context.Background(), state)
+	if err != nil {
+		t.Fatalf("ReconcileAll() error = %v", err)
+	}
+
+	var order []string
+	for _, result := range results {
+		order = append(order, filepath.Base(result.ResourceName))
+	}
+	want := []string{"base.conf", "broken.conf", "dependent.conf", "app.conf", "transitive.conf"}
+	if strings.Join(order, " ") != strings.Join(want, " ") {
+		t.Errorf("reconcile order = %v, want %v", order, want)
+	}
+
+	errs := map[string]string{}
+	for _, result := range results {
+		if result.Error != nil {
+			errs[filepath.Base(result.ResourceName)] = result.Error.Error()
+		}
+	}
+	if !strings.Contains(errs["dependent.conf"], "not reconciled: dependency file:"+broken+" failed") {
+		t.Errorf("dependent.conf error = %q, want it blocked by broken.conf", errs["dependent.conf"])
+	}
+	if !strings.Contains(errs["transitive.conf"], "not reconciled: dependency file:"+dependent+" failed") {
+		t.Errorf("transitive.conf error = %q, want it blocked by dependent.conf", errs["transitive.conf"])
+	}
+	if _, err := os.Stat(dependent); !os.IsNotExist(err) {
+		t.Errorf("dependent.conf was written although its dependency failed")
+	}
+	if data, err := os.ReadFile(app); err != nil || string(data) != "app" {
+		t.Errorf("app.conf = %q, %v; want it written after base.conf", data, err)
+	}
+	if len(state.Files) != 5 {
+		t.Errorf("state has %d files after the pass, want blocked files kept for later passes", len(state.Files))
+	}
+}
+
+func TestDependencyWaves_NoDependencies(t *testing.T) {
+	state := &config.State{
+		Sysctl: map[string]string{"vm.swappiness": "10"},
+		Files:  []config.FileConfig{{Path: "/etc/a"}, {Path: "/etc/b"}},
+	}
+	waves, err := dependencyWaves(state)
+	if err != nil {
+		t.Fatalf("dependencyWaves() error = %v", err)
+	}
+	if len(waves) != 1 || waves[0] == state || len(waves[0].Files) != 2 || len(waves[0].Sysctl) != 1 {
+		t.Errorf("dependencyWaves() = %d waves, want one copy of the state", len(waves))
+	}
+}
+
+func TestReconcileAll_DependencyCycle(t *testing.T) {
+	state := &config.State{
+		Files: []config.FileConfig{
+			{Path: "/tmp/a", DependsOn: []string{"file:/tmp/b"}},
+			{Path: "/tmp/b", DependsOn: []string{"file:/tmp/a"}},
+		},
+	}
+	r := NewReconciler(ModeEnforce)
+	if _, err := r.ReconcileAll(context.Background(), state); err == nil || !strings.Contains(err.Error(), "dependency cycle") {
+		t.Errorf("ReconcileAll() error = %v, want a dependency cycle", err)
+	}
+}
@@ -0,0 +1,78 @@
+package reconciler
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/power-edge/power-edge/pkg/apply"
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// DNSEnforcer orchestrates WHEN to apply resolver configuration
+// The actual HOW is delegated to pkg/apply
+type DNSEnforcer struct {
+	applier *apply.DNSApplier
+}
+
+// NewDNSEnforcer creates a new DNS enforcer
+func NewDNSEnforcer() *DNSEnforcer {
+	return &DNSEnforcer{
+		applier: apply.NewDNSApplier(),
+	}
+}
+
+// Reconcile detects drift and triggers applier to fix it
+func (e *DNSEnforcer) Reconcile(ctx context.Context, dns *config.DNSConfig, mode ReconcileMode) (ReconcileResult, error) {
+	result := ReconcileResult{
+		ResourceType: "dns",
+		ResourceName: "resolver",
+		DryRun:       mode == ModeDryRun,
+		RunID:        RunIDFromContext(ctx),
+	}
+
+	if dns == nil {
+		result.WasCompliant = true
+		result.Action = "not configured"
+		return result, nil
+	}
+
+	if manager, err := e.applier.DetectManager(); err == nil {
+		result.ResourceName = string(manager)
+	}
+
+	// Use the applier to check and potentially apply state
+	dryRun := (mode != ModeEnforce)
+	applyResult := e.applier.Apply(ctx, dns, dryRun)
+
+	if applyResult.Error != nil {
+		result.Error = applyResult.Error
+		return result, applyResult.Error
+	}
+
+	// Already compliant
+	if !applyResult.Changed {
+		result.WasCompliant = true
+		result.Action = "compliant"
+		logResult(ctx, slog.LevelDebug, result, "      ✓ dns: already compliant")
+		return result, nil
+	}
+
+	// Changes needed/applied
+	result.WasCompliant = false
+	result.Action = strings.Join(applyResult.Actions, "; ")
+	result.Actions = applyResult.Actions
+
+	if mode == ModeDryRun {
+		logResult(ctx, slog.LevelInfo, result, "      🔍 [DRY-RUN] dns: would execute: %s", result.Action)
+	} else if mode == ModeEnforce {
+		logResult(ctx, slog.LevelInfo, result, "      ✓ dns: executed '%s'", result.Action)
+	}
+
+	return result, nil
+}
+
+// Check returns the effective nameservers and search domains without applying changes
+func (e *DNSEnforcer) Check() (nameservers, search []string, err error) {
+	return e.applier.Check()
+}
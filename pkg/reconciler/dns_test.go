@@ -0,0 +1,62 @@
+package reconciler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestNewDNSEnforcer(t *testing.T) {
+	e := NewDNSEnforcer()
+
+	if e.applier == nil {
+		t.Error("Applier not initialized")
+	}
+}
+
+func TestDNSEnforcer_Reconcile(t *testing.T) {
+	tests := []struct {
+		name    string
+		dns     *config.DNSConfig
+		mode    ReconcileMode
+		wantErr bool
+	}{
+		{
+			name: "dry-run nameservers",
+			dns: &config.DNSConfig{
+				Nameservers: []string{"1.1.1.1"},
+				Search:      []string{"lab.local"},
+			},
+			mode:    ModeDryRun,
+			wantErr: false,
+		},
+		{
+			name:    "nil dns config",
+			dns:     nil,
+			mode:    ModeDryRun,
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewDNSEnforcer()
+			ctx := context.Background()
+
+			result, err := e.Reconcile(ctx, tt.dns, tt.mode)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if result.ResourceType != "dns" {
+				t.Errorf("Expected ResourceType 'dns', got '%s'", result.ResourceType)
+			}
+
+			if tt.mode == ModeDryRun && result.DryRun != true {
+				t.Error("Expected DryRun to be true in dry-run mode")
+			}
+		})
+	}
+}
@@ -2,7 +2,7 @@ package reconciler
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"strings"
 
 	"github.com/power-edge/power-edge/pkg/apply"
@@ -28,11 +28,15 @@ func (e *FileEnforcer) Reconcile(ctx context.Context, file config.FileConfig, mo
 		ResourceType: "file",
 		ResourceName: string(file.Path),
 		DryRun:       mode == ModeDryRun,
+		RunID:        RunIDFromContext(ctx),
 	}
 
 	// Use the applier to check and potentially apply state
 	dryRun := (mode != ModeEnforce)
-	applyResult := e.applier.Apply(file, dryRun)
+	applyResult := e.applier.Apply(ctx, file, dryRun)
+	for _, note := range applyResult.Notes {
+		logResult(ctx, slog.LevelInfo, result, "      📦 %s: %s", file.Path, note)
+	}
 
 	if applyResult.Error != nil {
 		result.Error = applyResult.Error
@@ -43,24 +47,41 @@ func (e *FileEnforcer) Reconcile(ctx context.Context, file config.FileConfig, mo
 	if !applyResult.Changed {
 		result.WasCompliant = true
 		result.Action = "compliant"
-		log.Printf("      ✓ %s: already compliant", file.Path)
+		logResult(ctx, slog.LevelDebug, result, "      ✓ %s: already compliant", file.Path)
 		return result, nil
 	}
 
 	// Changes needed/applied
 	result.WasCompliant = false
 	result.Action = strings.Join(applyResult.Actions, " + ")
+	result.Actions = applyResult.Actions
+	result.Diff = applyResult.Diff
 
 	if mode == ModeDryRun {
-		log.Printf("      🔍 [DRY-RUN] %s: would execute: %s", file.Path, result.Action)
+		logResult(ctx, slog.LevelInfo, result, "      🔍 [DRY-RUN] %s: would execute: %s", file.Path, result.Action)
 	} else if mode == ModeEnforce {
-		log.Printf("      ✓ %s: executed '%s'", file.Path, result.Action)
+		logResult(ctx, slog.LevelInfo, result, "      ✓ %s: executed '%s'", file.Path, result.Action)
 	}
 
 	return result, nil
 }
 
+// SetSourceCache keeps downloaded file sources in cache
+func (e *FileEnforcer) SetSourceCache(cache *apply.SourceCache) {
+	e.applier.SetSourceCache(cache)
+}
+
+// SetTemplateData sets the node metadata used to render templated files
+func (e *FileEnforcer) SetTemplateData(data apply.TemplateData) {
+	e.applier.SetTemplateData(data)
+}
+
 // Check returns current file state without applying changes
-func (e *FileEnforcer) Check(path string) (exists bool, mode, owner, group, sha256sum string, err error) {
-	return e.applier.Check(path)
+func (e *FileEnforcer) Check(ctx context.Context, path string) (exists bool, mode, owner, group, sha256sum, seContext string, err error) {
+	return e.applier.Check(ctx, path)
+}
+
+// Preflight checks, without changing anything, that applying can succeed
+func (e *FileEnforcer) Preflight(file config.FileConfig) error {
+	return e.applier.Preflight(file)
 }
@@ -116,7 +116,7 @@ func TestFileEnforcer_Check(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	exists, mode, owner, group, sha256sum, err := e.Check(testFile)
+	exists, mode, owner, group, sha256sum, _, err := e.Check(context.Background(), testFile)
 
 	if err != nil {
 		t.Fatalf("Check() error = %v", err)
@@ -2,7 +2,7 @@ package reconciler
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"strings"
 
 	"github.com/power-edge/power-edge/pkg/apply"
@@ -26,8 +26,9 @@ func NewFirewallEnforcer() *FirewallEnforcer {
 func (e *FirewallEnforcer) Reconcile(ctx context.Context, fw *config.FirewallConfig, mode ReconcileMode) (ReconcileResult, error) {
 	result := ReconcileResult{
 		ResourceType: "firewall",
-		ResourceName: "ufw",
+		ResourceName: string(apply.FirewallProvider(fw)),
 		DryRun:       mode == ModeDryRun,
+		RunID:        RunIDFromContext(ctx),
 	}
 
 	if fw == nil {
@@ -38,7 +39,7 @@ func (e *FirewallEnforcer) Reconcile(ctx context.Context, fw *config.FirewallCon
 
 	// Use the applier to check and potentially apply state
 	dryRun := (mode != ModeEnforce)
-	applyResult := e.applier.Apply(fw, dryRun)
+	applyResult := e.applier.Apply(ctx, fw, dryRun)
 
 	if applyResult.Error != nil {
 		result.Error = applyResult.Error
@@ -49,24 +50,25 @@ func (e *FirewallEnforcer) Reconcile(ctx context.Context, fw *config.FirewallCon
 	if !applyResult.Changed {
 		result.WasCompliant = true
 		result.Action = "compliant"
-		log.Printf("      ✓ firewall: already compliant")
+		logResult(ctx, slog.LevelDebug, result, "      ✓ firewall: already compliant")
 		return result, nil
 	}
 
 	// Changes needed/applied
 	result.WasCompliant = false
 	result.Action = strings.Join(applyResult.Actions, "; ")
+	result.Actions = applyResult.Actions
 
 	if mode == ModeDryRun {
-		log.Printf("      🔍 [DRY-RUN] firewall: would execute: %s", result.Action)
+		logResult(ctx, slog.LevelInfo, result, "      🔍 [DRY-RUN] firewall: would execute: %s", result.Action)
 	} else if mode == ModeEnforce {
-		log.Printf("      ✓ firewall: applied %d changes", len(applyResult.Actions))
+		logResult(ctx, slog.LevelInfo, result, "      ✓ firewall: applied %d changes", len(applyResult.Actions))
 	}
 
 	return result, nil
 }
 
-// Check returns the current firewall state without applying changes
-func (e *FirewallEnforcer) Check() (enabled bool, err error) {
-	return e.applier.Check()
+// Check returns the current state of fw's firewall without applying changes
+func (e *FirewallEnforcer) Check(ctx context.Context, fw *config.FirewallConfig) (enabled bool, err error) {
+	return e.applier.Check(ctx, fw)
 }
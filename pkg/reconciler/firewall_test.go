@@ -2,8 +2,10 @@ package reconciler
 
 import (
 	"context"
+	"errors"
 	"testing"
 
+	"github.com/power-edge/power-edge/pkg/apply"
 	"github.com/power-edge/power-edge/pkg/config"
 )
 
@@ -64,7 +66,7 @@ func TestFirewallEnforcer_Reconcile(t *testing.T) {
 			result, err := e.Reconcile(ctx, tt.fw, tt.mode)
 
 			// If UFW is not installed, skip the test
-			if err != nil && result.Error != nil && result.Error.Error() == "ufw is not installed" {
+			if errors.Is(err, apply.ErrFirewallBackendMissing) {
 				t.Skip("UFW not installed, skipping test")
 			}
 
@@ -86,7 +88,7 @@ func TestFirewallEnforcer_Reconcile(t *testing.T) {
 func TestFirewallEnforcer_Check(t *testing.T) {
 	e := NewFirewallEnforcer()
 
-	enabled, err := e.Check()
+	enabled, err := e.Check(context.Background(), nil)
 
 	// If UFW is not installed, that's ok for the test
 	if err != nil {
@@ -0,0 +1,57 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+const defaultHookTimeout = 5 * time.Minute
+
+// runHook executes a pre/post hook through the shell and records its combined
+// stdout/stderr in the result. Hooks only run in enforce mode; in dry-run the
+// result just notes that the hook would have run.
+func (r *Reconciler) runHook(ctx context.Context, name, command string, hooks *config.HooksConfig) ReconcileResult {
+	result := ReconcileResult{
+		ResourceType: "hook",
+		ResourceName: name,
+		DryRun:       r.passMode(ctx) == ModeDryRun,
+		RunID:        RunIDFromContext(ctx),
+		Action:       fmt.Sprintf("run %s", command),
+	}
+
+	if r.passMode(ctx) != ModeEnforce {
+		logf(ctx, "      🔍 [DRY-RUN] %s-hook: would run '%s'", name, command)
+		return result
+	}
+
+	timeout := defaultHookTimeout
+	if hooks.Timeout > 0 {
+		timeout = time.Duration(hooks.Timeout) * time.Second
+	}
+	hookCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	logf(ctx, "   Running %s-hook: %s", name, command)
+	start := time.Now()
+	output, err := exec.CommandContext(hookCtx, "sh", "-c", command).CombinedOutput()
+	result.Duration = time.Since(start)
+	result.Output = strings.TrimSpace(string(output))
+
+	if err != nil {
+		if hookCtx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		result.Error = fmt.Errorf("%s-hook failed: %s (output: %s)", name, err, result.Output)
+		logAtf(ctx, slog.LevelError, "      ✗ %v", result.Error)
+		return result
+	}
+
+	logf(ctx, "      ✓ %s-hook succeeded", name)
+	return result
+}
@@ -0,0 +1,92 @@
+package reconciler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestReconcileAll_Hooks(t *testing.T) {
+	tmpDir := t.TempDir()
+	trace := filepath.Join(tmpDir, "trace")
+
+	state := &config.State{
+		Hooks: config.HooksConfig{
+			Pre:  "echo pre >> " + trace + " && echo draining",
+			Post: "echo post >> " + trace,
+		},
+		Files: []config.FileConfig{
+			{Path: config.UnixPath(filepath.Join(tmpDir, "managed.conf")), Content: "managed"},
+		},
+	}
+
+	r := NewReconciler(ModeEnforce)
+	results, err := r.ReconcileAll(context.Background(), state)
+	if err != nil {
+		t.Fatalf("ReconcileAll() error = %v", err)
+	}
+
+	if len(results) != 3 || results[0].ResourceName != "pre" || results[2].ResourceName != "post" {
+		t.Fatalf("Expected pre-hook, file, post-hook results, got %+v", results)
+	}
+	if results[0].Output != "draining" {
+		t.Errorf("Expected captured hook output, got %q", results[0].Output)
+	}
+
+	data, _ := os.ReadFile(trace)
+	if string(data) != "pre\npost\n" {
+		t.Errorf("Expected hooks to run in order, got %q", data)
+	}
+}
+
+func TestReconcileAll_PreHookFailureAborts(t *testing.T) {
+	tmpDir := t.TempDir()
+	managed := filepath.Join(tmpDir, "managed.conf")
+
+	state := &config.State{
+		Hooks: config.HooksConfig{
+			Pre:  "echo lb unreachable >&2; exit 3",
+			Post: "touch " + filepath.Join(tmpDir, "post-ran"),
+		},
+		Files: []config.FileConfig{
+			{Path: config.UnixPath(managed), Content: "managed"},
+		},
+	}
+
+	r := NewReconciler(ModeEnforce)
+	results, err := r.ReconcileAll(context.Background(), state)
+	if err == nil || !strings.Contains(err.Error(), "pre-hook failed") {
+		t.Fatalf("Expected pre-hook failure, got %v", err)
+	}
+	if len(results) != 1 || !strings.Contains(results[0].Output, "lb unreachable") {
+		t.Errorf("Expected only the failed hook result with its output, got %+v", results)
+	}
+	if _, err := os.Stat(managed); !os.IsNotExist(err) {
+		t.Error("Resources must not be enforced after a failed pre-hook")
+	}
+}
+
+func TestReconcileAll_HooksSkippedInDryRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	marker := filepath.Join(tmpDir, "ran")
+
+	state := &config.State{
+		Hooks: config.HooksConfig{Pre: "touch " + marker},
+	}
+
+	r := NewReconciler(ModeDryRun)
+	results, err := r.ReconcileAll(context.Background(), state)
+	if err != nil {
+		t.Fatalf("ReconcileAll() error = %v", err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("Hooks must not run in dry-run mode")
+	}
+	if len(results) != 1 || !results[0].DryRun {
+		t.Errorf("Expected a dry-run hook result, got %+v", results)
+	}
+}
@@ -0,0 +1,371 @@
+package reconciler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/power-edge/power-edge/pkg/apply"
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// AppliedRecord is what the agent last enforced on one resource. Values hold
+// the properties it set (e.g. "value" for sysctl, "active"/"enabled" for
+// services, "sha256"/"mode" for files) in the same form they are read back.
+type AppliedRecord struct {
+	Type      string            `json:"type"`
+	Name      string            `json:"name"`
+	Values    map[string]string `json:"values"`
+	AppliedAt time.Time         `json:"applied_at"`
+	RunID     string            `json:"run_id,omitempty"`
+}
+
+func (rec AppliedRecord) key() string {
+	return rec.Type + "/" + rec.Name
+}
+
+// LastAppliedStore persists the records of what enforce-mode passes applied,
+// so changes made while the agent wasn't running can be found after a
+// restart. Unlike the desired state it only ever holds what was actually
+// set. It is safe for concurrent use.
+type LastAppliedStore struct {
+	path    string
+	mu      sync.Mutex
+	records map[string]AppliedRecord
+}
+
+// OpenLastAppliedStore loads the store kept at path. A missing file is an
+// empty store; it is created on the first save.
+func OpenLastAppliedStore(path string) (*LastAppliedStore, error) {
+	s := &LastAppliedStore{
+		path:    path,
+		records: make(map[string]AppliedRecord),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []AppliedRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, rec := range records {
+		s.records[rec.key()] = rec
+	}
+	return s, nil
+}
+
+// Records returns every record, sorted by type and name
+func (s *LastAppliedStore) Records() []AppliedRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedLocked()
+}
+
+func (s *LastAppliedStore) sortedLocked() []AppliedRecord {
+	records := make([]AppliedRecord, 0, len(s.records))
+	for _, rec := range s.records {
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].key() < records[j].key()
+	})
+	return records
+}
+
+// update stores records, keeping the original AppliedAt of any whose values
+// haven't changed, and drops existing records rejected by keep (nil keeps
+// everything). The file is only rewritten when something changed.
+func (s *LastAppliedStore) update(records []AppliedRecord, keep func(key string) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	if keep != nil {
+		for key := range s.records {
+			if !keep(key) {
+				delete(s.records, key)
+				changed = true
+			}
+		}
+	}
+	for _, rec := range records {
+		if old, ok := s.records[rec.key()]; ok && sameParams(old.Values, rec.Values) {
+			continue
+		}
+		s.records[rec.key()] = rec
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+// saveLocked atomically replaces the store file
+func (s *LastAppliedStore) saveLocked() (err error) {
+	data, err := json.MarshalIndent(s.sortedLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func sameParams(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if v, ok := b[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// SetLastAppliedStore records what every enforce-mode pass applies in s
+// (nil disables recording)
+func (r *Reconciler) SetLastAppliedStore(s *LastAppliedStore) {
+	r.resultMu.Lock()
+	defer r.resultMu.Unlock()
+	r.lastApplied = s
+}
+
+// recordApplied stores the values of every resource the pass enforced
+// successfully. A full pass (prune) also forgets resources that are no
+// longer in state.
+func (r *Reconciler) recordApplied(ctx context.Context, state *config.State, results []ReconcileResult, prune bool) {
+	r.resultMu.Lock()
+	s := r.lastApplied
+	r.resultMu.Unlock()
+
+	if s == nil {
+		return
+	}
+
+	desired := appliedValues(state)
+	now := time.Now().UTC()
+	var records []AppliedRecord
+	for _, result := range results {
+		if result.Error != nil || result.DryRun || r.modeFor(ctx, result.ResourceType) != ModeEnforce {
+			continue
+		}
+		// Skipped resources, e.g. a sysctl key the kernel doesn't have or a
+		// service without systemd, were never set
+		if strings.HasPrefix(result.Action, "skipped") {
+			continue
+		}
+		values, ok := desired[result.ResourceType+"/"+result.ResourceName]
+		if !ok {
+			continue
+		}
+		rec := AppliedRecord{
+			Type:      result.ResourceType,
+			Name:      result.ResourceName,
+			Values:    values,
+			AppliedAt: now,
+			RunID:     result.RunID,
+		}
+		// Content is recorded as it was written, whatever its source
+		if rec.Type == "file" && values["sha256"] == "" && values["exists"] == "true" {
+			if sum, err := fileSHA256(rec.Name); err == nil {
+				values["sha256"] = sum
+			}
+		}
+		records = append(records, rec)
+	}
+
+	var keep func(string) bool
+	if prune {
+		keep = func(key string) bool {
+			_, ok := desired[key]
+			return ok
+		}
+	}
+	if err := s.update(records, keep); err != nil {
+		logAtf(ctx, slog.LevelWarn, "   ⚠️  Failed to save last-applied state: %v", err)
+	}
+}
+
+// appliedValues maps the resources in state to the values enforcing them
+// sets, keyed by type/name. Only resources that can be read back one by one
+// are included.
+func appliedValues(state *config.State) map[string]map[string]string {
+	values := make(map[string]map[string]string)
+
+	for _, svc := range state.Services {
+		values["service/"+svc.Name] = map[string]string{
+			"active":  strconv.FormatBool(svc.State == config.ServiceStateRunning),
+			"enabled": strconv.FormatBool(svc.Enabled && svc.State != config.ServiceStateAbsent),
+		}
+	}
+
+	for key, value := range state.Sysctl {
+		values["sysctl/"+key] = map[string]string{"value": apply.NormalizeSysctlValue(value)}
+	}
+
+	for _, file := range state.Files {
+		// Directories and symlinks have no content to compare
+		if file.Type != "" && file.Type != config.FileTypeFile {
+			continue
+		}
+		v := map[string]string{"exists": strconv.FormatBool(file.State != config.FileStateAbsent)}
+		if file.State != config.FileStateAbsent {
+			// In the form modes are read back in
+			if mode, err := apply.NormalizeMode(file.Mode); err == nil && file.Mode != "" {
+				v["mode"] = mode
+			}
+			if file.Owner != "" {
+				v["owner"] = file.Owner
+			}
+			if file.Group != "" {
+				v["group"] = file.Group
+			}
+			if file.SHA256 != "" && !file.Template {
+				v["sha256"] = file.SHA256
+			}
+		}
+		values["file/"+string(file.Path)] = v
+	}
+
+	return values
+}
+
+// currentValues reads back the values recorded for rec, in the same form
+func (r *Reconciler) currentValues(ctx context.Context, rec AppliedRecord) (map[string]string, error) {
+	switch rec.Type {
+	case "service":
+		active, enabled, err := r.serviceEnforcer.Check(ctx, rec.Name)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{
+			"active":  strconv.FormatBool(active),
+			"enabled": strconv.FormatBool(enabled),
+		}, nil
+
+	case "sysctl":
+		value, err := r.sysctlEnforcer.Get(ctx, rec.Name)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"value": value}, nil
+
+	case "file":
+		exists, mode, owner, group, sum, _, err := r.fileEnforcer.Check(ctx, rec.Name)
+		if err != nil {
+			return nil, err
+		}
+		current := map[string]string{"exists": strconv.FormatBool(exists)}
+		if exists {
+			current["mode"], current["owner"], current["group"], current["sha256"] = mode, owner, group, sum
+		}
+		return current, nil
+	}
+	return nil, fmt.Errorf("unsupported resource type %q", rec.Type)
+}
+
+// CheckLastApplied compares every record in s with the system as it is now,
+// reporting the resources changed since the agent last enforced them, e.g.
+// while it was not running. Only the recorded values are compared.
+func (r *Reconciler) CheckLastApplied(ctx context.Context, s *LastAppliedStore) DriftReport {
+	ctx = withQuiet(ctx)
+
+	report := DriftReport{
+		Timestamp: time.Now().UTC(),
+		RunID:     RunIDFromContext(ctx),
+		Compliant: []ResourceDrift{},
+		Drifted:   []ResourceDrift{},
+		Errors:    []ResourceDrift{},
+	}
+
+	for _, rec := range s.Records() {
+		drift := ResourceDrift{
+			Type:    rec.Type,
+			Name:    rec.Name,
+			Desired: rec.Values,
+		}
+
+		current, err := r.currentValues(ctx, rec)
+		if err != nil {
+			drift.Error = err.Error()
+			report.Errors = append(report.Errors, drift)
+			continue
+		}
+
+		// Only what was recorded is compared
+		seen := make(map[string]string, len(rec.Values))
+		var changed []string
+		for key, value := range rec.Values {
+			seen[key] = current[key]
+			if current[key] != value {
+				changed = append(changed, key)
+			}
+		}
+		drift.Current = seen
+
+		if len(changed) == 0 {
+			report.Compliant = append(report.Compliant, drift)
+			continue
+		}
+		sort.Strings(changed)
+		drift.Action = fmt.Sprintf("changed since last applied at %s: %s",
+			rec.AppliedAt.Format(time.RFC3339), strings.Join(changed, ", "))
+		report.Drifted = append(report.Drifted, drift)
+	}
+
+	return report
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,89 @@
+package reconciler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestLastAppliedStore_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "last-applied.json")
+
+	s, err := OpenLastAppliedStore(path)
+	if err != nil {
+		t.Fatalf("OpenLastAppliedStore(missing) error: %v", err)
+	}
+	if len(s.Records()) != 0 {
+		t.Fatalf("new store has %d records", len(s.Records()))
+	}
+
+	rec := AppliedRecord{Type: "sysctl", Name: "vm.swappiness", Values: map[string]string{"value": "10"}}
+	if err := s.update([]AppliedRecord{rec}, nil); err != nil {
+		t.Fatalf("update() error: %v", err)
+	}
+
+	reopened, err := OpenLastAppliedStore(path)
+	if err != nil {
+		t.Fatalf("OpenLastAppliedStore() error: %v", err)
+	}
+	records := reopened.Records()
+	if len(records) != 1 || records[0].Values["value"] != "10" {
+		t.Fatalf("Records() = %+v, want the saved record", records)
+	}
+
+	// Pruning drops records rejected by keep
+	if err := reopened.update(nil, func(string) bool { return false }); err != nil {
+		t.Fatalf("update() error: %v", err)
+	}
+	if len(reopened.Records()) != 0 {
+		t.Errorf("Records() = %+v after prune, want none", reopened.Records())
+	}
+}
+
+func TestReconciler_CheckLastApplied(t *testing.T) {
+	dir := t.TempDir()
+	managed := filepath.Join(dir, "app.conf")
+	if err := os.WriteFile(managed, []byte("port = 80\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := OpenLastAppliedStore(filepath.Join(dir, "last-applied.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReconciler(ModeEnforce)
+	r.SetLastAppliedStore(s)
+
+	state := &config.State{
+		Files: []config.FileConfig{{Path: config.UnixPath(managed), Content: "port = 80\n"}},
+	}
+	r.recordApplied(context.Background(), state, []ReconcileResult{
+		{ResourceType: "file", ResourceName: managed, WasCompliant: true, Action: "compliant"},
+		// Dry-run and failed results were not applied
+		{ResourceType: "sysctl", ResourceName: "vm.swappiness", DryRun: true},
+	}, true)
+
+	if records := s.Records(); len(records) != 1 || records[0].Values["sha256"] == "" {
+		t.Fatalf("Records() = %+v, want the file with its checksum", records)
+	}
+
+	report := r.CheckLastApplied(context.Background(), s)
+	if len(report.Compliant) != 1 || len(report.Drifted) != 0 {
+		t.Fatalf("unchanged file: report = %+v, want compliant", report)
+	}
+
+	// Changed out of band
+	if err := os.WriteFile(managed, []byte("port = 8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report = r.CheckLastApplied(context.Background(), s)
+	if len(report.Drifted) != 1 {
+		t.Fatalf("changed file: report = %+v, want drifted", report)
+	}
+	if report.Drifted[0].Name != managed {
+		t.Errorf("drifted %s, want %s", report.Drifted[0].Name, managed)
+	}
+}
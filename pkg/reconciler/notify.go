@@ -0,0 +1,84 @@
+package reconciler
+
+import (
+	"context"
+	"strings"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// notification is a service to restart and the resources that asked for it
+type notification struct {
+	service  string
+	by       []string
+	enforced bool // Whether any of them was actually changed, not just found drifting
+}
+
+// notifications returns the services that the files and packages changed in
+// results notify, once each, in the order they were first notified
+func notifications(state *config.State, results []ReconcileResult) []notification {
+	notify := map[string][]string{}
+	for _, f := range state.Files {
+		notify[config.ResourceRef("file", string(f.Path))] = f.Notify
+	}
+	for _, p := range state.Packages {
+		notify[config.ResourceRef("package", p.Name)] = p.Notify
+	}
+
+	var notes []notification
+	index := map[string]int{}
+	for _, result := range results {
+		if result.WasCompliant || result.Error != nil || strings.HasPrefix(result.Action, "skipped") {
+			continue
+		}
+		if result.ResourceType != "file" && result.ResourceType != "package" {
+			continue
+		}
+		ref := config.ResourceRef(result.ResourceType, result.ResourceName)
+		for _, service := range notify[ref] {
+			i, ok := index[service]
+			if !ok {
+				i = len(notes)
+				index[service] = i
+				notes = append(notes, notification{service: service})
+			}
+			notes[i].by = append(notes[i].by, ref)
+			notes[i].enforced = notes[i].enforced || !result.DryRun
+		}
+	}
+	return notes
+}
+
+// restartNotified restarts the services notified by changes in results. A
+// restart only happens when the service mode is enforce and a notifying
+// resource was really changed; otherwise it's reported as one that would be.
+func (r *Reconciler) restartNotified(ctx context.Context, state *config.State, results []ReconcileResult) []ReconcileResult {
+	mode := r.modeFor(ctx, "service")
+	if mode == ModeDisabled {
+		return nil
+	}
+	notes := notifications(state, results)
+	if len(notes) == 0 {
+		return nil
+	}
+
+	logf(ctx, "   Restarting notified services...")
+	var restarts []ReconcileResult
+	for _, note := range notes {
+		noteMode := mode
+		if !note.enforced {
+			noteMode = ModeDryRun
+		}
+		result, err := recoverReconcile(ctx, noteMode, "service", note.service, func() (ReconcileResult, error) {
+			return r.withRetry(ctx, func() (ReconcileResult, error) {
+				result := r.serviceEnforcer.Restart(ctx, note.service, note.by, noteMode)
+				return result, result.Error
+			})
+		})
+		if err != nil {
+			result.Error = err
+		}
+		restarts = append(restarts, result)
+	}
+	return restarts
+}
@@ -0,0 +1,96 @@
+package reconciler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestNotifications(t *testing.T) {
+	state := &config.State{
+		Files: []config.FileConfig{
+			{Path: "/etc/nginx/nginx.conf", Notify: []string{"nginx"}},
+			{Path: "/etc/nginx/site.conf", Notify: []string{"nginx", "php-fpm"}},
+			{Path: "/etc/postgresql/postgresql.conf", Notify: []string{"postgresql"}},
+			{Path: "/etc/motd", Notify: []string{"motd"}},
+		},
+		Packages: []config.PackageConfig{{Name: "php", Notify: []string{"php-fpm"}}},
+	}
+	results := []ReconcileResult{
+		{ResourceType: "file", ResourceName: "/etc/nginx/nginx.conf", Action: "write content"},
+		{ResourceType: "file", ResourceName: "/etc/nginx/site.conf", Action: "write content", DryRun: true},
+		{ResourceType: "file", ResourceName: "/etc/postgresql/postgresql.conf", WasCompliant: true, Action: "compliant"},
+		{ResourceType: "file", ResourceName: "/etc/motd", Action: "write content", Error: os.ErrPermission},
+		{ResourceType: "package", ResourceName: "php", Action: "install", DryRun: true},
+	}
+
+	want := []notification{
+		{service: "nginx", by: []string{"file:/etc/nginx/nginx.conf", "file:/etc/nginx/site.conf"}, enforced: true},
+		{service: "php-fpm", by: []string{"file:/etc/nginx/site.conf", "package:php"}},
+	}
+	if got := notifications(state, results); !reflect.DeepEqual(got, want) {
+		t.Errorf("notifications() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReconcileAll_Notify(t *testing.T) {
+	dir := t.TempDir()
+	compliant := filepath.Join(dir, "compliant.conf")
+	if err := os.WriteFile(compliant, []byte("same"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state := &config.State{
+		Files: []config.FileConfig{
+			{Path: config.UnixPath(filepath.Join(dir, "a.conf")), Content: "a", Notify: []string{"nginx"}},
+			{Path: config.UnixPath(filepath.Join(dir, "b.conf")), Content: "b", Notify: []string{"nginx"}},
+			{Path: config.UnixPath(compliant), Content: "same", Notify: []string{"postgresql"}},
+		},
+	}
+
+	r := NewReconciler(ModeDryRun)
+	results, err := r.ReconcileAll(context.Background(), state)
+	if err != nil {
+		t.Fatalf("ReconcileAll() error = %v", err)
+	}
+
+	var restarts []ReconcileResult
+	for _, result := range results {
+		if result.ResourceType == "service" {
+			restarts = append(restarts, result)
+		}
+	}
+	if len(restarts) != 1 || restarts[0].ResourceName != "nginx" {
+		t.Fatalf("service results = %+v, want one for nginx", restarts)
+	}
+	if !restarts[0].DryRun || restarts[0].Error != nil {
+		t.Errorf("nginx restart = %+v, want a dry-run without error", restarts[0])
+	}
+	if results[len(results)-1].ResourceType != "service" {
+		t.Errorf("restart isn't the last result: %+v", results)
+	}
+}
+
+func TestReconcileEvent_Notify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nginx.conf")
+	state := &config.State{
+		Files: []config.FileConfig{
+			{Path: config.UnixPath(path), Content: "a", Notify: []string{"nginx"}},
+		},
+	}
+
+	r := NewReconciler(ModeDryRun)
+	results, err := r.ReconcileEvent(context.Background(), "file_modified", "file:"+path, state)
+	if err != nil {
+		t.Fatalf("ReconcileEvent() error = %v", err)
+	}
+	if len(results) != 2 || results[0].ResourceName != path {
+		t.Fatalf("results = %+v, want the file then its notified service", results)
+	}
+	if restart := results[1]; restart.ResourceType != "service" || restart.ResourceName != "nginx" || !restart.DryRun {
+		t.Errorf("restart = %+v, want a dry-run restart of nginx", restart)
+	}
+}
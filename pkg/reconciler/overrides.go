@@ -0,0 +1,110 @@
+package reconciler
+
+import (
+	"context"
+	"time"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+type modeOverridesKey struct{}
+
+// passModes is what a pass needs from the state to decide its modes
+type passModes struct {
+	overrides config.ReconcileOverrides
+	frozen    bool
+}
+
+// withModeOverrides returns a context carrying the state's per-resource-type
+// modes and whether a change freeze is active, so every enforcer in the pass
+// sees the same modes even if the freeze ends mid-pass
+func withModeOverrides(ctx context.Context, state *config.State) context.Context {
+	return context.WithValue(ctx, modeOverridesKey{}, passModes{
+		overrides: state.Reconcile,
+		frozen:    state.Freeze.Active(time.Now()),
+	})
+}
+
+// frozen reports whether the pass ctx belongs to runs under a change freeze
+func frozen(ctx context.Context) bool {
+	modes, _ := ctx.Value(modeOverridesKey{}).(passModes)
+	return modes.frozen
+}
+
+// overrideFor returns the mode overrides set for a resource type, or "" if
+// the type follows the global mode
+func overrideFor(overrides config.ReconcileOverrides, resourceType string) config.ReconcileMode {
+	switch resourceType {
+	case "service":
+		return overrides.Services
+	case "sysctl":
+		return overrides.Sysctl
+	case "firewall":
+		return overrides.Firewall
+	case "package", "repository":
+		// Repositories follow the packages installed from them
+		return overrides.Packages
+	case "file":
+		return overrides.Files
+	case "dns":
+		return overrides.DNS
+	}
+	return ""
+}
+
+// modeFor returns the effective mode for resourceType in the pass ctx
+// belongs to. A change freeze downgrades enforce to dry-run, so drift is
+// still detected and reported but nothing is changed.
+func (r *Reconciler) modeFor(ctx context.Context, resourceType string) ReconcileMode {
+	modes, _ := ctx.Value(modeOverridesKey{}).(passModes)
+	mode := r.GetMode()
+	if override := overrideFor(modes.overrides, resourceType); override != "" {
+		mode = ReconcileMode(override)
+	}
+	if mode == ModeEnforce && modes.frozen {
+		return ModeDryRun
+	}
+	return mode
+}
+
+// passMode returns the most active effective mode across all resource types:
+// enforce if anything is enforced, dry-run if anything is checked, otherwise
+// disabled. Hooks and the pass as a whole follow it.
+func (r *Reconciler) passMode(ctx context.Context) ReconcileMode {
+	mode := ModeDisabled
+	for _, resourceType := range []string{"service", "sysctl", "firewall", "package", "file", "dns"} {
+		switch r.modeFor(ctx, resourceType) {
+		case ModeEnforce:
+			return ModeEnforce
+		case ModeDryRun:
+			mode = ModeDryRun
+		}
+	}
+	return mode
+}
+
+// EffectiveMode returns the mode resourceType ("service", "sysctl",
+// "firewall", "package", "repository", "file" or "dns") is reconciled with
+// under state: the state's override if set, otherwise the global mode, and
+// dry-run rather than enforce while the state's freeze is active
+func (r *Reconciler) EffectiveMode(state *config.State, resourceType string) ReconcileMode {
+	return r.modeFor(withModeOverrides(context.Background(), state), resourceType)
+}
+
+// Enabled reports whether a pass over state would reconcile anything, taking
+// the state's per-resource-type overrides into account
+func (r *Reconciler) Enabled(state *config.State) bool {
+	return r.passMode(withModeOverrides(context.Background(), state)) != ModeDisabled
+}
+
+// freezeMessage describes an active freeze for the pass log
+func freezeMessage(freeze config.FreezeConfig) string {
+	msg := "Change freeze active, enforcement downgraded to dry-run"
+	if freeze.Until != "" {
+		msg += " until " + freeze.Until
+	}
+	if freeze.Reason != "" {
+		msg += " (" + freeze.Reason + ")"
+	}
+	return msg
+}
@@ -0,0 +1,128 @@
+package reconciler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestReconciler_ModeOverrides(t *testing.T) {
+	tests := []struct {
+		name        string
+		global      ReconcileMode
+		files       config.ReconcileMode
+		wantResults int
+		wantCreated bool
+	}{
+		{"global enforce, files dry-run", ModeEnforce, config.ReconcileModeDryRun, 1, false},
+		{"global dry-run, files enforce", ModeDryRun, config.ReconcileModeEnforce, 1, true},
+		{"global disabled, files enforce", ModeDisabled, config.ReconcileModeEnforce, 1, true},
+		{"global enforce, files disabled", ModeEnforce, config.ReconcileModeDisabled, 0, false},
+		{"no override follows global", ModeEnforce, "", 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "managed.conf")
+			state := &config.State{
+				Files:     []config.FileConfig{{Path: config.UnixPath(path), Content: "hello"}},
+				Reconcile: config.ReconcileOverrides{Files: tt.files},
+			}
+
+			r := NewReconciler(tt.global)
+			results, err := r.ReconcileAll(context.Background(), state)
+			if err != nil {
+				t.Fatalf("ReconcileAll() error = %v", err)
+			}
+			if len(results) != tt.wantResults {
+				t.Fatalf("Expected %d results, got %d: %+v", tt.wantResults, len(results), results)
+			}
+
+			_, statErr := os.Stat(path)
+			if created := statErr == nil; created != tt.wantCreated {
+				t.Errorf("File created = %v, want %v", created, tt.wantCreated)
+			}
+		})
+	}
+}
+
+func TestReconciler_EffectiveMode(t *testing.T) {
+	r := NewReconciler(ModeDryRun)
+	state := &config.State{
+		Reconcile: config.ReconcileOverrides{
+			Sysctl:   config.ReconcileModeEnforce,
+			Packages: config.ReconcileModeDisabled,
+		},
+	}
+
+	if mode := r.EffectiveMode(state, "sysctl"); mode != ModeEnforce {
+		t.Errorf("sysctl mode = %s, want enforce", mode)
+	}
+	if mode := r.EffectiveMode(state, "package"); mode != ModeDisabled {
+		t.Errorf("package mode = %s, want disabled", mode)
+	}
+	if mode := r.EffectiveMode(state, "service"); mode != ModeDryRun {
+		t.Errorf("service mode = %s, want the global dry-run", mode)
+	}
+
+	if !r.Enabled(state) {
+		t.Error("Enabled() = false with sysctl enforced")
+	}
+	disabled := NewReconciler(ModeDisabled)
+	if disabled.Enabled(&config.State{}) {
+		t.Error("Enabled() = true with everything disabled")
+	}
+}
+
+func TestReconciler_Freeze(t *testing.T) {
+	tests := []struct {
+		name        string
+		freeze      config.FreezeConfig
+		wantCreated bool
+	}{
+		{"open-ended freeze", config.FreezeConfig{Enabled: true, Reason: "release"}, false},
+		{"freeze until later", config.FreezeConfig{Enabled: true, Until: time.Now().Add(time.Hour).Format(time.RFC3339)}, false},
+		{"expired freeze", config.FreezeConfig{Enabled: true, Until: time.Now().Add(-time.Hour).Format(time.RFC3339)}, true},
+		{"freeze disabled", config.FreezeConfig{Until: time.Now().Add(time.Hour).Format(time.RFC3339)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "managed.conf")
+			state := &config.State{
+				Files:     []config.FileConfig{{Path: config.UnixPath(path), Content: "hello"}},
+				Reconcile: config.ReconcileOverrides{Files: config.ReconcileModeEnforce},
+				Freeze:    tt.freeze,
+			}
+
+			r := NewReconciler(ModeEnforce)
+			results, err := r.ReconcileAll(context.Background(), state)
+			if err != nil {
+				t.Fatalf("ReconcileAll() error = %v", err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("Expected 1 result, got %d: %+v", len(results), results)
+			}
+			if results[0].DryRun == tt.wantCreated {
+				t.Errorf("DryRun = %v, want %v", results[0].DryRun, !tt.wantCreated)
+			}
+
+			_, statErr := os.Stat(path)
+			if created := statErr == nil; created != tt.wantCreated {
+				t.Errorf("File created = %v, want %v", created, tt.wantCreated)
+			}
+
+			wantMode := ModeEnforce
+			if !tt.wantCreated {
+				wantMode = ModeDryRun
+			}
+			if mode := r.EffectiveMode(state, "file"); mode != wantMode {
+				t.Errorf("EffectiveMode() = %s, want %s", mode, wantMode)
+			}
+		})
+	}
+}
@@ -2,7 +2,7 @@ package reconciler
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"strings"
 
 	"github.com/power-edge/power-edge/pkg/apply"
@@ -28,11 +28,15 @@ func (e *PackageEnforcer) Reconcile(ctx context.Context, pkg config.PackageConfi
 		ResourceType: "package",
 		ResourceName: pkg.Name,
 		DryRun:       mode == ModeDryRun,
+		RunID:        RunIDFromContext(ctx),
 	}
 
 	// Use the applier to check and potentially apply state
 	dryRun := (mode != ModeEnforce)
-	applyResult := e.applier.Apply(pkg, dryRun)
+	applyResult := e.applier.Apply(ctx, pkg, dryRun)
+	for _, note := range applyResult.Notes {
+		logResult(ctx, slog.LevelWarn, result, "      ⚠️  %s: %s", pkg.Name, note)
+	}
 
 	if applyResult.Error != nil {
 		result.Error = applyResult.Error
@@ -43,24 +47,45 @@ func (e *PackageEnforcer) Reconcile(ctx context.Context, pkg config.PackageConfi
 	if !applyResult.Changed {
 		result.WasCompliant = true
 		result.Action = "compliant"
-		log.Printf("      ✓ %s: already compliant", pkg.Name)
+		logResult(ctx, slog.LevelDebug, result, "      ✓ %s: already compliant", pkg.Name)
 		return result, nil
 	}
 
 	// Changes needed/applied
 	result.WasCompliant = false
 	result.Action = strings.Join(applyResult.Actions, " + ")
+	result.Actions = applyResult.Actions
 
 	if mode == ModeDryRun {
-		log.Printf("      🔍 [DRY-RUN] %s: would execute: %s", pkg.Name, result.Action)
+		logResult(ctx, slog.LevelInfo, result, "      🔍 [DRY-RUN] %s: would execute: %s", pkg.Name, result.Action)
 	} else if mode == ModeEnforce {
-		log.Printf("      ✓ %s: executed '%s'", pkg.Name, result.Action)
+		logResult(ctx, slog.LevelInfo, result, "      ✓ %s: executed '%s'", pkg.Name, result.Action)
 	}
 
 	return result, nil
 }
 
-// Check returns whether a package is installed and its version
-func (e *PackageEnforcer) Check(name string) (installed bool, version string, err error) {
-	return e.applier.Check(name)
+// Check returns whether a package is installed, its version, and whether it is held
+func (e *PackageEnforcer) Check(ctx context.Context, name string) (installed bool, version string, held bool, err error) {
+	return e.applier.Check(ctx, name)
+}
+
+// Preflight checks, without changing anything, that applying can succeed
+func (e *PackageEnforcer) Preflight(ctx context.Context, pkg config.PackageConfig) error {
+	return e.applier.Preflight(ctx, pkg)
+}
+
+// SetPolicy sets when the package index is refreshed before installs
+func (e *PackageEnforcer) SetPolicy(policy config.PackagePolicy) {
+	e.applier.SetPolicy(policy)
+}
+
+// BeginPass allows one more index refresh, for a new pass
+func (e *PackageEnforcer) BeginPass() {
+	e.applier.BeginPass()
+}
+
+// MarkRefreshed records that the index was refreshed during this pass
+func (e *PackageEnforcer) MarkRefreshed() {
+	e.applier.MarkRefreshed()
 }
@@ -2,8 +2,10 @@ package reconciler
 
 import (
 	"context"
+	"errors"
 	"testing"
 
+	"github.com/power-edge/power-edge/pkg/apply"
 	"github.com/power-edge/power-edge/pkg/config"
 )
 
@@ -59,7 +61,7 @@ func TestPackageEnforcer_Reconcile(t *testing.T) {
 			result, err := e.Reconcile(ctx, tt.pkg, tt.mode)
 
 			// If no package manager found, skip
-			if err != nil && result.Error != nil && result.Error.Error() == "no supported package manager found (apt/yum/dnf)" {
+			if errors.Is(err, apply.ErrNoPackageManager) {
 				t.Skip("No supported package manager found")
 			}
 
@@ -86,12 +88,12 @@ func TestPackageEnforcer_Check(t *testing.T) {
 	e := NewPackageEnforcer()
 
 	// Test checking a package that likely exists on most systems
-	installed, version, err := e.Check("bash")
+	installed, version, held, err := e.Check(context.Background(), "bash")
 
 	if err != nil {
 		t.Logf("Check() error: %v", err)
 		return
 	}
 
-	t.Logf("bash installed: %v, version: %s", installed, version)
+	t.Logf("bash installed: %v, version: %s, held: %v", installed, version, held)
 }
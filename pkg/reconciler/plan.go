@@ -0,0 +1,241 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/power-edge/power-edge/pkg/apply"
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// PlannedAction is one step enforcing a state would take
+type PlannedAction struct {
+	Step   int    `json:"step"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// Plan returns the ordered actions an enforce pass over state would take,
+// without running any of them. Resources are visited in the order a pass
+// visits them, dependencies first, and resource types whose mode is disabled
+// are left out. Services notified by a planned change are restarted after
+// everything else.
+// Resources that can't be checked are left out of the plan too, and
+// reported together in the returned error.
+func (r *Reconciler) Plan(ctx context.Context, state *config.State) ([]PlannedAction, error) {
+	plan, failed, err := r.plan(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	if len(failed) > 0 {
+		errs := make([]error, len(failed))
+		for i, result := range failed {
+			errs[i] = fmt.Errorf("%s/%s: %w", result.ResourceType, result.ResourceName, result.Error)
+		}
+		return plan, fmt.Errorf("failed to plan %d resources: %w", len(errs), errors.Join(errs...))
+	}
+	return plan, nil
+}
+
+// plan returns the actions Plan reports along with the resources that
+// couldn't be checked
+func (r *Reconciler) plan(ctx context.Context, state *config.State) ([]PlannedAction, []ReconcileResult, error) {
+	ctx = withQuiet(withModeOverrides(r.CommandContext(ensureRunID(ctx, state)), state))
+
+	waves, err := dependencyWaves(state)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		plan    []PlannedAction
+		failed  []ReconcileResult
+		checked []ReconcileResult
+	)
+	add := func(result ReconcileResult, reason string) {
+		checked = append(checked, result)
+		switch {
+		case result.Error != nil:
+			failed = append(failed, result)
+		case result.WasCompliant:
+		default:
+			actions := result.Actions
+			if len(actions) == 0 {
+				actions = []string{result.Action}
+			}
+			for _, action := range actions {
+				plan = append(plan, PlannedAction{
+					Step:   len(plan) + 1,
+					Type:   result.ResourceType,
+					Name:   result.ResourceName,
+					Action: action,
+					Reason: reason,
+				})
+			}
+		}
+	}
+	enabled := func(resourceType string) bool {
+		return r.modeFor(ctx, resourceType) != ModeDisabled
+	}
+
+	if state.Hooks.Pre != "" {
+		add(ReconcileResult{ResourceType: "hook", ResourceName: "pre", Action: "run " + state.Hooks.Pre}, "runs before every enforce pass")
+	}
+
+	// Resources with dependencies are planned after them, as a pass runs them
+	for _, wave := range waves {
+		if enabled("service") {
+			for _, svc := range wave.Services {
+				result, _ := r.serviceEnforcer.Reconcile(ctx, svc, ModeDryRun)
+				active, isEnabled, _ := r.serviceEnforcer.Check(ctx, svc.Name)
+				add(result, serviceReason(svc, active, isEnabled))
+			}
+		}
+
+		if enabled("sysctl") {
+			r.sysctlEnforcer.SetPolicy(wave.SysctlPolicy)
+			for _, key := range sortedKeys(wave.Sysctl) {
+				result, _ := r.sysctlEnforcer.Reconcile(ctx, key, wave.Sysctl[key], ModeDryRun)
+				current, _ := r.sysctlEnforcer.Get(ctx, key)
+				add(result, fmt.Sprintf("value is %q, want %q", current, wave.Sysctl[key]))
+			}
+		}
+
+		if wave.Firewall.Configured() && enabled("firewall") {
+			result, _ := r.firewallEnforcer.Reconcile(ctx, &wave.Firewall, ModeDryRun)
+			reason := "rules differ"
+			if on, _ := r.firewallEnforcer.Check(ctx, &wave.Firewall); !on && wave.Firewall.Enabled {
+				reason = "firewall is disabled"
+			}
+			add(result, reason)
+		}
+
+		if enabled("repository") {
+			for _, result := range r.repoEnforcer.ReconcileAll(ctx, wave.Repositories, ModeDryRun) {
+				add(result, "repository definition differs")
+			}
+		}
+
+		if enabled("package") {
+			for _, pkg := range wave.Packages {
+				result, _ := r.packageEnforcer.Reconcile(ctx, pkg, ModeDryRun)
+				installed, version, held, _ := r.packageEnforcer.Check(ctx, pkg.Name)
+				add(result, packageReason(pkg, installed, version, held))
+			}
+		}
+
+		if enabled("file") && len(wave.Files) > 0 {
+			r.fileEnforcer.SetTemplateData(apply.NewTemplateData(wave.Metadata))
+			for _, file := range wave.Files {
+				result, _ := r.fileEnforcer.Reconcile(ctx, file, ModeDryRun)
+				exists, mode, owner, group, _, _, _ := r.fileEnforcer.Check(ctx, string(file.Path))
+				add(result, fileReason(file, result, exists, mode, owner, group))
+			}
+		}
+
+		if (len(wave.DNS.Nameservers) > 0 || len(wave.DNS.Search) > 0) && enabled("dns") {
+			result, _ := r.dnsEnforcer.Reconcile(ctx, &wave.DNS, ModeDryRun)
+			add(result, "resolver configuration differs")
+		}
+	}
+
+	if enabled("service") {
+		for _, note := range notifications(state, checked) {
+			result := r.serviceEnforcer.Restart(ctx, note.service, note.by, ModeDryRun)
+			add(result, "notified by "+strings.Join(note.by, ", "))
+		}
+	}
+
+	if state.Hooks.Post != "" {
+		add(ReconcileResult{ResourceType: "hook", ResourceName: "post", Action: "run " + state.Hooks.Post}, "runs after every enforce pass")
+	}
+
+	return plan, failed, nil
+}
+
+// serviceReason describes how a service differs from its desired state
+func serviceReason(svc config.ServiceConfig, active, enabled bool) string {
+	var reasons []string
+	switch svc.State {
+	case config.ServiceStateRunning:
+		if !active {
+			reasons = append(reasons, "not running")
+		}
+	case config.ServiceStateStopped, config.ServiceStateDisabled:
+		if active {
+			reasons = append(reasons, "running")
+		}
+	case config.ServiceStateAbsent:
+		return "unit is installed"
+	}
+	if enabled != svc.Enabled {
+		if enabled {
+			reasons = append(reasons, "enabled at boot")
+		} else {
+			reasons = append(reasons, "not enabled at boot")
+		}
+	}
+	return joinReasons(reasons)
+}
+
+// packageReason describes how a package differs from its desired state
+func packageReason(pkg config.PackageConfig, installed bool, version string, held bool) string {
+	switch {
+	case pkg.State == config.PackageStateAbsent:
+		return "installed"
+	case !installed:
+		return "not installed"
+	}
+	var reasons []string
+	switch {
+	case pkg.State == config.PackageStateLatest:
+		reasons = append(reasons, fmt.Sprintf("version %s may be outdated", version))
+	case pkg.Version != "" && version != pkg.Version:
+		reasons = append(reasons, fmt.Sprintf("version is %s, want %s", version, pkg.Version))
+	}
+	if held != pkg.Hold {
+		if held {
+			reasons = append(reasons, "held")
+		} else {
+			reasons = append(reasons, "not held")
+		}
+	}
+	return joinReasons(reasons)
+}
+
+// fileReason describes how a file differs from its desired state
+func fileReason(file config.FileConfig, result ReconcileResult, exists bool, mode, owner, group string) string {
+	switch {
+	case file.State == config.FileStateAbsent:
+		return "exists"
+	case !exists:
+		return "missing"
+	}
+	var reasons []string
+	if result.Diff != "" {
+		reasons = append(reasons, "content differs")
+	}
+	if want, err := apply.NormalizeMode(file.Mode); err == nil && file.Mode != "" && mode != want {
+		reasons = append(reasons, fmt.Sprintf("mode is %s, want %s", mode, want))
+	}
+	if file.Owner != "" && owner != file.Owner {
+		reasons = append(reasons, fmt.Sprintf("owner is %s, want %s", owner, file.Owner))
+	}
+	if file.Group != "" && group != file.Group {
+		reasons = append(reasons, fmt.Sprintf("group is %s, want %s", group, file.Group))
+	}
+	return joinReasons(reasons)
+}
+
+// joinReasons joins the differences found, falling back to a generic reason
+// for drift the checks above don't tell apart
+func joinReasons(reasons []string) string {
+	if len(reasons) == 0 {
+		return "differs from desired state"
+	}
+	return strings.Join(reasons, ", ")
+}
@@ -0,0 +1,63 @@
+package reconciler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestPlan(t *testing.T) {
+	tmpDir := t.TempDir()
+	compliant := filepath.Join(tmpDir, "compliant.conf")
+	missing := filepath.Join(tmpDir, "missing.conf")
+	loose := filepath.Join(tmpDir, "loose.conf")
+	for _, path := range []string{compliant, loose} {
+		if err := os.WriteFile(path, []byte("ok"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state := &config.State{
+		Hooks: config.HooksConfig{Pre: "drain", Post: "undrain"},
+		Files: []config.FileConfig{
+			{Path: config.UnixPath(compliant), Content: "ok"},
+			{Path: config.UnixPath(missing), Content: "new"},
+			{Path: config.UnixPath(loose), Content: "changed", Mode: "0600"},
+		},
+	}
+
+	// Plan never enforces, whatever the configured mode
+	r := NewReconciler(ModeEnforce)
+	plan, err := r.Plan(context.Background(), state)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Error("Plan() must not change the system")
+	}
+
+	want := []PlannedAction{
+		{Step: 1, Type: "hook", Name: "pre", Action: "run drain", Reason: "runs before every enforce pass"},
+		{Step: 2, Type: "file", Name: missing, Action: "write content to " + missing, Reason: "missing"},
+		{Step: 3, Type: "file", Name: loose, Action: "write content to " + loose, Reason: "content differs, mode is 0644, want 0600"},
+		{Step: 4, Type: "file", Name: loose, Action: "chmod 0600 " + loose, Reason: "content differs, mode is 0644, want 0600"},
+		{Step: 5, Type: "hook", Name: "post", Action: "run undrain", Reason: "runs after every enforce pass"},
+	}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("Plan() =\n%+v\nwant\n%+v", plan, want)
+	}
+
+	// Types disabled by an override are left out
+	state.Reconcile.Files = config.ReconcileModeDisabled
+	plan, err = r.Plan(context.Background(), state)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(plan) != 2 || plan[0].Type != "hook" || plan[1].Step != 2 {
+		t.Errorf("Plan() with files disabled = %+v, want only the hooks", plan)
+	}
+}
@@ -0,0 +1,52 @@
+package reconciler
+
+import (
+	"sync"
+)
+
+// SetWorkers sets how many resources of one type are reconciled concurrently
+func (r *Reconciler) SetWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	r.workers = n
+}
+
+// runPool calls fn for every index in [0, n) on a bounded pool of workers.
+// Results are stored by index, so the output order matches the input order
+// regardless of which reconciliation finishes first.
+func (r *Reconciler) runPool(n int, fn func(i int) ReconcileResult) []ReconcileResult {
+	if n == 0 {
+		return nil
+	}
+
+	results := make([]ReconcileResult, n)
+
+	workers := r.workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,44 @@
+package reconciler
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunPool(t *testing.T) {
+	r := NewReconciler(ModeDryRun)
+	r.SetWorkers(4)
+
+	var running, peak int32
+	results := r.runPool(20, func(i int) ReconcileResult {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		// Finish in reverse order to make sure ordering doesn't depend on timing
+		time.Sleep(time.Duration(20-i) * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return ReconcileResult{ResourceName: fmt.Sprintf("r%d", i)}
+	})
+
+	if len(results) != 20 {
+		t.Fatalf("Expected 20 results, got %d", len(results))
+	}
+	for i, result := range results {
+		if want := fmt.Sprintf("r%d", i); result.ResourceName != want {
+			t.Errorf("results[%d] = %s, want %s", i, result.ResourceName, want)
+		}
+	}
+	if peak > 4 {
+		t.Errorf("Expected at most 4 concurrent workers, saw %d", peak)
+	}
+
+	if results := r.runPool(0, nil); results != nil {
+		t.Errorf("Expected nil results for empty input, got %v", results)
+	}
+}
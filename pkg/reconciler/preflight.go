@@ -0,0 +1,95 @@
+package reconciler
+
+import (
+	"context"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// PreflightProblem is a resource whose planned actions are predicted to fail
+type PreflightProblem struct {
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+	Problem string   `json:"problem"`
+}
+
+// Preflight predicts which of the actions Plan returns would fail if state
+// were enforced, without changing anything. On top of planning, it checks
+// each changed resource's prerequisites: packages are in the index, file
+// directories are writable, service units exist. Resources that can't even
+// be checked, e.g. a firewall whose tool isn't installed, are problems too.
+// If anything is planned but the agent lacks the privileges to change the
+// system, that comes first, as an "agent" problem named "privileges".
+func (r *Reconciler) Preflight(ctx context.Context, state *config.State) ([]PreflightProblem, error) {
+	plan, failed, err := r.plan(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = r.CommandContext(ctx)
+	var problems []PreflightProblem
+	if len(plan) > 0 {
+		if err := r.CheckPrivileges(ctx); err != nil {
+			problems = append(problems, PreflightProblem{
+				Type:    "agent",
+				Name:    "privileges",
+				Actions: []string{},
+				Problem: err.Error(),
+			})
+		}
+	}
+	for _, result := range failed {
+		problems = append(problems, PreflightProblem{
+			Type:    result.ResourceType,
+			Name:    result.ResourceName,
+			Actions: []string{},
+			Problem: result.Error.Error(),
+		})
+	}
+
+	packages := make(map[string]config.PackageConfig, len(state.Packages))
+	for _, pkg := range state.Packages {
+		packages[pkg.Name] = pkg
+	}
+	files := make(map[string]config.FileConfig, len(state.Files))
+	for _, file := range state.Files {
+		files[string(file.Path)] = file
+	}
+
+	for _, resource := range groupPlan(plan) {
+		var err error
+		switch resource.Type {
+		case "package":
+			err = r.packageEnforcer.Preflight(ctx, packages[resource.Name])
+		case "file":
+			err = r.fileEnforcer.Preflight(files[resource.Name])
+		case "service":
+			err = r.serviceEnforcer.Preflight(ctx, resource.Name)
+		}
+		if err != nil {
+			resource.Problem = err.Error()
+			problems = append(problems, resource)
+		}
+	}
+	return problems, nil
+}
+
+// groupPlan collects the planned actions of each resource, in the order the
+// resources are first planned
+func groupPlan(plan []PlannedAction) []PreflightProblem {
+	type key struct{ resourceType, name string }
+	var groups []PreflightProblem
+	index := make(map[key]int)
+	for _, action := range plan {
+		k := key{action.Type, action.Name}
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, PreflightProblem{Type: action.Type, Name: action.Name})
+		}
+		groups[i].Actions = append(groups[i].Actions, action.Action)
+	}
+	return groups
+}
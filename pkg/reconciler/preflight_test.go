@@ -0,0 +1,93 @@
+package reconciler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestPreflight(t *testing.T) {
+	tmpDir := t.TempDir()
+	blocker := filepath.Join(tmpDir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ok := filepath.Join(tmpDir, "ok.conf")
+	orphan := filepath.Join(tmpDir, "orphan.conf")
+	blocked := filepath.Join(blocker, "blocked.conf")
+
+	state := &config.State{
+		Files: []config.FileConfig{
+			{Path: config.UnixPath(ok), Content: "ok"},
+			{Path: config.UnixPath(orphan), Content: "new", Owner: "no-such-user-pe"},
+			{Path: config.UnixPath(blocked), Content: "never"},
+		},
+	}
+
+	r := NewReconciler(ModeEnforce)
+	r.SetPrivilegeCommand([]string{"env"}) // Works wherever sudo isn't installed
+	problems, err := r.Preflight(context.Background(), state)
+	if err != nil {
+		t.Fatalf("Preflight() error = %v", err)
+	}
+	if _, err := os.Stat(ok); !os.IsNotExist(err) {
+		t.Error("Preflight() must not change the system")
+	}
+
+	if len(problems) != 2 {
+		t.Fatalf("Preflight() = %+v, want two problems", problems)
+	}
+
+	// Resources that can't be checked come first, with nothing planned
+	if p := problems[0]; p.Name != blocked || len(p.Actions) != 0 || p.Problem == "" {
+		t.Errorf("Preflight() problem = %+v, want %s unchecked", p, blocked)
+	}
+	if p := problems[1]; p.Type != "file" || p.Name != orphan || len(p.Actions) == 0 || !strings.Contains(p.Problem, "failed to look up owner") {
+		t.Errorf("Preflight() problem = %+v, want %s with an unknown owner", p, orphan)
+	}
+}
+
+func TestPreflight_Privileges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new.conf")
+	state := &config.State{
+		Files: []config.FileConfig{{Path: config.UnixPath(path), Content: "new"}},
+	}
+
+	r := NewReconciler(ModeEnforce)
+	r.SetPrivilegeCommand([]string{"false"})
+	problems, err := r.Preflight(context.Background(), state)
+	if err != nil {
+		t.Fatalf("Preflight() error = %v", err)
+	}
+	if len(problems) != 1 || problems[0].Type != "agent" || problems[0].Name != "privileges" {
+		t.Fatalf("Preflight() = %+v, want only a privileges problem", problems)
+	}
+
+	// Nothing planned needs no privileges
+	if err := os.WriteFile(path, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if problems, _ := r.Preflight(context.Background(), state); len(problems) != 0 {
+		t.Errorf("Preflight() = %+v, want no problems once compliant", problems)
+	}
+}
+
+func TestGroupPlan(t *testing.T) {
+	plan := []PlannedAction{
+		{Step: 1, Type: "file", Name: "/a", Action: "write content to /a"},
+		{Step: 2, Type: "package", Name: "nginx", Action: "install nginx"},
+		{Step: 3, Type: "file", Name: "/a", Action: "chmod 0600 /a"},
+	}
+	want := []PreflightProblem{
+		{Type: "file", Name: "/a", Actions: []string{"write content to /a", "chmod 0600 /a"}},
+		{Type: "package", Name: "nginx", Actions: []string{"install nginx"}},
+	}
+	if got := groupPlan(plan); !reflect.DeepEqual(got, want) {
+		t.Errorf("groupPlan() = %+v, want %+v", got, want)
+	}
+}
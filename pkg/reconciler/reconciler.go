@@ -2,9 +2,18 @@ package reconciler
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/power-edge/power-edge/pkg/apply"
 	"github.com/power-edge/power-edge/pkg/config"
 )
 
@@ -22,19 +31,44 @@ type ReconcileResult struct {
 	ResourceType string
 	ResourceName string
 	WasCompliant bool
-	Action       string // e.g., "started service", "set sysctl", "no-op"
+	Action       string   // e.g., "started service", "set sysctl", "no-op"
+	Actions      []string // The individual steps Action summarizes, when there are changes
 	Error        error
 	DryRun       bool
+	RunID        string // Correlation ID of the reconcile cycle that produced this result
+	Output       string // Captured stdout/stderr (hooks)
+	Diff         string // Planned content change (files, dry-run)
+	Stack        string // Stack trace of a panic recovered while reconciling
+	Duration     time.Duration
 }
 
 // Reconciler enforces desired state on the edge node
 type Reconciler struct {
 	mode             ReconcileMode
+	modeMu           sync.RWMutex // Mode can be changed while a pass is running
 	serviceEnforcer  *ServiceEnforcer
 	sysctlEnforcer   *SysctlEnforcer
 	firewallEnforcer *FirewallEnforcer
 	packageEnforcer  *PackageEnforcer
+	repoEnforcer     *RepositoryEnforcer
 	fileEnforcer     *FileEnforcer
+	dnsEnforcer      *DNSEnforcer
+	retry            RetryPolicy
+	workers          int        // Concurrent reconciliations per resource type
+	packageMu        sync.Mutex // Package managers hold an exclusive lock while changing state
+	resultWriter     *ResultWriter
+	changeHandler    ChangeHandler
+	resultObserver   ResultObserver
+	lastApplied      *LastAppliedStore
+	resultMu         sync.Mutex
+	commandTimeout   time.Duration // Per-command limit for appliers; zero keeps apply.DefaultCommandTimeout
+	privilegeCommand []string      // What appliers run system-changing commands through, see SetPrivilegeCommand
+	passMu           sync.Mutex    // Held for a whole pass, so passes never shell out concurrently
+	pendingMu        sync.Mutex    // Guards pending and the release of passMu
+	pending          *config.State // State of events coalesced into the running pass
+	passes           atomic.Uint64 // Passes run so far; a cached report is stale once this moves
+	reportMu         sync.Mutex    // Guards the cached report
+	lastReport       cachedReport
 }
 
 // NewReconciler creates a new reconciler with the specified mode
@@ -45,148 +79,384 @@ func NewReconciler(mode ReconcileMode) *Reconciler {
 		sysctlEnforcer:   NewSysctlEnforcer(),
 		firewallEnforcer: NewFirewallEnforcer(),
 		packageEnforcer:  NewPackageEnforcer(),
+		repoEnforcer:     NewRepositoryEnforcer(),
 		fileEnforcer:     NewFileEnforcer(),
+		dnsEnforcer:      NewDNSEnforcer(),
+		retry:            DefaultRetryPolicy,
+		workers:          runtime.NumCPU(),
+		privilegeCommand: apply.DefaultPrivilegeCommand,
 	}
 }
 
-// ReconcileAll runs reconciliation for all state components
+// ReconcileAll runs reconciliation for all state components.
+// A resource whose enforcer panics fails with the stack trace in its result;
+// the rest of the pass carries on.
+// Every log line and result of the pass carries the run ID found in ctx
+// (see WithRunID); one is generated when the caller didn't provide it.
+// Resource types listed in state.Reconcile use that mode instead of the
+// global one, and are skipped entirely when it is disabled.
+// Only one pass runs at a time: a second caller waits for the first.
 func (r *Reconciler) ReconcileAll(ctx context.Context, state *config.State) ([]ReconcileResult, error) {
-	if r.mode == ModeDisabled {
-		log.Println("   Reconciliation disabled, skipping enforcement")
+	r.passMu.Lock()
+	defer r.releasePass(ctx)
+	return r.reconcileAll(ctx, state)
+}
+
+func (r *Reconciler) reconcileAll(ctx context.Context, state *config.State) ([]ReconcileResult, error) {
+	r.passes.Add(1)
+	ctx = withModeOverrides(r.CommandContext(ensureRunID(ctx, state)), state)
+
+	if r.passMode(ctx) == ModeDisabled {
+		logf(ctx, "   Reconciliation disabled, skipping enforcement")
 		return nil, nil
 	}
+	if frozen(ctx) {
+		logf(ctx, "❄️  %s", freezeMessage(state.Freeze))
+	}
+
+	waves, err := dependencyWaves(state)
+	if err != nil {
+		logAtf(ctx, slog.LevelError, "   Cannot order resources: %v", err)
+		return nil, fmt.Errorf("aborting reconciliation: %w", err)
+	}
 
+	var results []ReconcileResult
+	r.packageEnforcer.BeginPass()
+
+	// Pre-hook (e.g. drain from a load balancer); a failure aborts the pass
+	if state.Hooks.Pre != "" {
+		hookResult := r.runHook(ctx, "pre", state.Hooks.Pre, &state.Hooks)
+		results = append(results, r.observe(hookResult)...)
+		if hookResult.Error != nil {
+			r.logResults(ctx, results)
+			r.recordResults(ctx, results)
+			return results, fmt.Errorf("aborting reconciliation: %w", hookResult.Error)
+		}
+	}
+
+	// Resources are reconciled in waves when they declare dependencies
+	failed := map[string]bool{}
+	for i, wave := range waves {
+		if len(waves) > 1 {
+			logf(ctx, "   Reconciling dependency level %d of %d...", i+1, len(waves))
+		}
+		wave, blocked := r.dropBlocked(ctx, wave, failed)
+		waveResults := r.observe(blocked...)
+		waveResults = append(waveResults, r.reconcileWave(ctx, wave)...)
+		recordFailures(waveResults, failed)
+		results = append(results, waveResults...)
+	}
+
+	// Services notified by changed files and packages restart once, after
+	// everything else is in place
+	results = append(results, r.observe(r.restartNotified(ctx, state, results)...)...)
+
+	// Post-hook runs regardless of individual resource failures
+	if state.Hooks.Post != "" {
+		results = append(results, r.observe(r.runHook(ctx, "post", state.Hooks.Post, &state.Hooks))...)
+	}
+
+	// Log summary
+	r.logResults(ctx, results)
+	r.recordResults(ctx, results)
+	r.recordApplied(ctx, state, results, true)
+
+	return results, nil
+}
+
+// reconcileWave reconciles the resources of one dependency wave in the
+// fixed type order: services, sysctl, firewall, repositories, packages,
+// files, DNS
+func (r *Reconciler) reconcileWave(ctx context.Context, state *config.State) []ReconcileResult {
 	var results []ReconcileResult
 
 	// Reconcile services
-	log.Println("   Reconciling services...")
-	serviceResults, err := r.ReconcileServices(ctx, state.Services)
-	if err != nil {
-		log.Printf("   Service reconciliation error: %v", err)
+	if len(state.Services) > 0 {
+		logf(ctx, "   Reconciling services...")
+		serviceResults, err := r.ReconcileServices(ctx, state.Services)
+		if err != nil {
+			logAtf(ctx, slog.LevelError, "   Service reconciliation error: %v", err)
+		}
+		results = append(results, r.observe(serviceResults...)...)
 	}
-	results = append(results, serviceResults...)
 
 	// Reconcile sysctl
-	log.Println("   Reconciling sysctl parameters...")
-	sysctlResults, err := r.ReconcileSysctl(ctx, state.Sysctl)
-	if err != nil {
-		log.Printf("   Sysctl reconciliation error: %v", err)
+	if len(state.Sysctl) > 0 {
+		logf(ctx, "   Reconciling sysctl parameters...")
+		r.sysctlEnforcer.SetPolicy(state.SysctlPolicy)
+		sysctlResults, err := r.ReconcileSysctl(ctx, state.Sysctl)
+		if err != nil {
+			logAtf(ctx, slog.LevelError, "   Sysctl reconciliation error: %v", err)
+		}
+		results = append(results, r.observe(sysctlResults...)...)
 	}
-	results = append(results, sysctlResults...)
 
 	// Reconcile firewall
-	if state.Firewall.Enabled || len(state.Firewall.AllowedServices) > 0 {
-		log.Println("   Reconciling firewall...")
+	if state.Firewall.Configured() && r.modeFor(ctx, "firewall") != ModeDisabled {
+		logf(ctx, "   Reconciling firewall...")
 		firewallResult, err := r.ReconcileFirewall(ctx, &state.Firewall)
 		if err != nil {
-			log.Printf("   Firewall reconciliation error: %v", err)
+			logAtf(ctx, slog.LevelError, "   Firewall reconciliation error: %v", err)
+		}
+		results = append(results, r.observe(firewallResult)...)
+	}
+
+	// Reconcile repositories before the packages installed from them
+	if len(state.Repositories) > 0 {
+		logf(ctx, "   Reconciling repositories...")
+		repoResults, err := r.ReconcileRepositories(ctx, state.Repositories)
+		if err != nil {
+			logAtf(ctx, slog.LevelError, "   Repository reconciliation error: %v", err)
+		}
+		results = append(results, r.observe(repoResults...)...)
+
+		// Changed repositories refreshed the index already
+		for _, result := range repoResults {
+			if !result.WasCompliant && !result.DryRun && result.Error == nil {
+				r.packageEnforcer.MarkRefreshed()
+				break
+			}
 		}
-		results = append(results, firewallResult)
 	}
 
 	// Reconcile packages
 	if len(state.Packages) > 0 {
-		log.Println("   Reconciling packages...")
+		logf(ctx, "   Reconciling packages...")
+		r.packageEnforcer.SetPolicy(state.PackagePolicy)
 		packageResults, err := r.ReconcilePackages(ctx, state.Packages)
 		if err != nil {
-			log.Printf("   Package reconciliation error: %v", err)
+			logAtf(ctx, slog.LevelError, "   Package reconciliation error: %v", err)
 		}
-		results = append(results, packageResults...)
+		results = append(results, r.observe(packageResults...)...)
 	}
 
 	// Reconcile files
 	if len(state.Files) > 0 {
-		log.Println("   Reconciling files...")
+		logf(ctx, "   Reconciling files...")
+		r.fileEnforcer.SetTemplateData(apply.NewTemplateData(state.Metadata))
 		fileResults, err := r.ReconcileFiles(ctx, state.Files)
 		if err != nil {
-			log.Printf("   File reconciliation error: %v", err)
+			logAtf(ctx, slog.LevelError, "   File reconciliation error: %v", err)
 		}
-		results = append(results, fileResults...)
+		results = append(results, r.observe(fileResults...)...)
 	}
 
-	// Log summary
-	r.logResults(results)
+	// Reconcile DNS
+	if (len(state.DNS.Nameservers) > 0 || len(state.DNS.Search) > 0) && r.modeFor(ctx, "dns") != ModeDisabled {
+		logf(ctx, "   Reconciling DNS...")
+		dnsResult, err := r.ReconcileDNS(ctx, &state.DNS)
+		if err != nil {
+			logAtf(ctx, slog.LevelError, "   DNS reconciliation error: %v", err)
+		}
+		results = append(results, r.observe(dnsResult)...)
+	}
 
-	return results, nil
+	return results
 }
 
 // ReconcileServices enforces desired service state
 func (r *Reconciler) ReconcileServices(ctx context.Context, services []config.ServiceConfig) ([]ReconcileResult, error) {
-	var results []ReconcileResult
+	mode := r.modeFor(ctx, "service")
+	if mode == ModeDisabled {
+		return nil, nil
+	}
 
-	for _, svc := range services {
-		result, err := r.serviceEnforcer.Reconcile(ctx, svc, r.mode)
+	results := r.runPool(len(services), func(i int) ReconcileResult {
+		result, err := recoverReconcile(ctx, mode, "service", services[i].Name, func() (ReconcileResult, error) {
+			return r.withRetry(ctx, func() (ReconcileResult, error) {
+				return r.serviceEnforcer.Reconcile(ctx, services[i], mode)
+			})
+		})
 		if err != nil {
 			result.Error = err
 		}
-		results = append(results, result)
-	}
+		return result
+	})
 
 	return results, nil
 }
 
-// ReconcileSysctl enforces desired sysctl parameters
+// ReconcileSysctl enforces desired sysctl parameters. Drifted keys are
+// written to the managed sysctl.d drop-in and loaded together, so the batch
+// is retried as a whole.
 func (r *Reconciler) ReconcileSysctl(ctx context.Context, params map[string]string) ([]ReconcileResult, error) {
-	var results []ReconcileResult
-
-	for key, expectedValue := range params {
-		result, err := r.sysctlEnforcer.Reconcile(ctx, key, expectedValue, r.mode)
-		if err != nil {
-			result.Error = err
-		}
-		results = append(results, result)
+	mode := r.modeFor(ctx, "sysctl")
+	if mode == ModeDisabled || len(params) == 0 {
+		return nil, nil
 	}
 
-	return results, nil
+	return recoverBatch(ctx, mode, "sysctl", sortedKeys(params), func() []ReconcileResult {
+		return r.withBatchRetry(ctx, mode, func() []ReconcileResult {
+			return r.sysctlEnforcer.ReconcileAll(ctx, params, mode)
+		})
+	}), nil
 }
 
-// ReconcileFirewall enforces desired firewall state
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ReconcileFirewall enforces desired firewall state, returning a zero result
+// if firewall reconciliation is disabled.
+// UFW commands aren't safe to run concurrently, so this is never parallelized.
 func (r *Reconciler) ReconcileFirewall(ctx context.Context, fw *config.FirewallConfig) (ReconcileResult, error) {
-	return r.firewallEnforcer.Reconcile(ctx, fw, r.mode)
+	mode := r.modeFor(ctx, "firewall")
+	if mode == ModeDisabled {
+		return ReconcileResult{}, nil
+	}
+
+	return recoverReconcile(ctx, mode, "firewall", string(apply.FirewallProvider(fw)), func() (ReconcileResult, error) {
+		return r.withRetry(ctx, func() (ReconcileResult, error) {
+			return r.firewallEnforcer.Reconcile(ctx, fw, mode)
+		})
+	})
 }
 
-// ReconcilePackages enforces desired package state
+// ReconcilePackages enforces desired package state.
+// Checks run in parallel, but only one package is changed at a time since
+// apt/dnf hold an exclusive lock while installing. The apt index is refreshed
+// before the first install or upgrade of a ReconcileAll pass, at most once,
+// as the state's package_policy says.
 func (r *Reconciler) ReconcilePackages(ctx context.Context, packages []config.PackageConfig) ([]ReconcileResult, error) {
-	var results []ReconcileResult
+	mode := r.modeFor(ctx, "package")
+	if mode == ModeDisabled {
+		return nil, nil
+	}
 
-	for _, pkg := range packages {
-		result, err := r.packageEnforcer.Reconcile(ctx, pkg, r.mode)
+	results := r.runPool(len(packages), func(i int) ReconcileResult {
+		result, err := recoverReconcile(ctx, mode, "package", packages[i].Name, func() (ReconcileResult, error) {
+			return r.withRetry(ctx, func() (ReconcileResult, error) {
+				if mode == ModeEnforce {
+					r.packageMu.Lock()
+					defer r.packageMu.Unlock()
+				}
+				return r.packageEnforcer.Reconcile(ctx, packages[i], mode)
+			})
+		})
 		if err != nil {
 			result.Error = err
 		}
-		results = append(results, result)
-	}
+		return result
+	})
 
 	return results, nil
 }
 
+// ReconcileRepositories enforces desired package repositories. They share
+// the package manager's lock with package changes, and the index refresh
+// covers all of them, so the batch is retried as a whole.
+func (r *Reconciler) ReconcileRepositories(ctx context.Context, repos []config.RepoConfig) ([]ReconcileResult, error) {
+	mode := r.modeFor(ctx, "repository")
+	if mode == ModeDisabled || len(repos) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		names = append(names, repo.Name)
+	}
+	return recoverBatch(ctx, mode, "repository", names, func() []ReconcileResult {
+		return r.withBatchRetry(ctx, mode, func() []ReconcileResult {
+			if mode == ModeEnforce {
+				r.packageMu.Lock()
+				defer r.packageMu.Unlock()
+			}
+			return r.repoEnforcer.ReconcileAll(ctx, repos, mode)
+		})
+	}), nil
+}
+
 // ReconcileFiles enforces desired file state
 func (r *Reconciler) ReconcileFiles(ctx context.Context, files []config.FileConfig) ([]ReconcileResult, error) {
-	var results []ReconcileResult
+	mode := r.modeFor(ctx, "file")
+	if mode == ModeDisabled {
+		return nil, nil
+	}
 
-	for _, file := range files {
-		result, err := r.fileEnforcer.Reconcile(ctx, file, r.mode)
+	results := r.runPool(len(files), func(i int) ReconcileResult {
+		result, err := recoverReconcile(ctx, mode, "file", string(files[i].Path), func() (ReconcileResult, error) {
+			return r.withRetry(ctx, func() (ReconcileResult, error) {
+				return r.fileEnforcer.Reconcile(ctx, files[i], mode)
+			})
+		})
 		if err != nil {
 			result.Error = err
 		}
-		results = append(results, result)
-	}
+		return result
+	})
 
 	return results, nil
 }
 
+// ReconcileDNS enforces desired resolver configuration, returning a zero
+// result if DNS reconciliation is disabled
+func (r *Reconciler) ReconcileDNS(ctx context.Context, dns *config.DNSConfig) (ReconcileResult, error) {
+	mode := r.modeFor(ctx, "dns")
+	if mode == ModeDisabled {
+		return ReconcileResult{}, nil
+	}
+
+	return recoverReconcile(ctx, mode, "dns", "resolver", func() (ReconcileResult, error) {
+		return r.withRetry(ctx, func() (ReconcileResult, error) {
+			return r.dnsEnforcer.Reconcile(ctx, dns, mode)
+		})
+	})
+}
+
 // SetMode updates the reconciliation mode at runtime
 func (r *Reconciler) SetMode(mode ReconcileMode) {
-	log.Printf("Reconciliation mode changed: %s → %s", r.mode, mode)
+	r.modeMu.Lock()
+	defer r.modeMu.Unlock()
+	slog.Info(fmt.Sprintf("Reconciliation mode changed: %s → %s", r.mode, mode))
 	r.mode = mode
 }
 
 // GetMode returns the current reconciliation mode
 func (r *Reconciler) GetMode() ReconcileMode {
+	r.modeMu.RLock()
+	defer r.modeMu.RUnlock()
 	return r.mode
 }
 
-func (r *Reconciler) logResults(results []ReconcileResult) {
+// SetCommandTimeout bounds each command the appliers run. A command that
+// exceeds it is killed and its resource fails with a timeout error; zero
+// restores apply.DefaultCommandTimeout.
+func (r *Reconciler) SetCommandTimeout(d time.Duration) {
+	r.commandTimeout = d
+}
+
+// SetPrivilegeCommand sets what the appliers run system-changing commands
+// through, e.g. ["sudo", "-n"] or ["doas"]. An empty command runs them
+// directly, which only works as root.
+func (r *Reconciler) SetPrivilegeCommand(command []string) {
+	r.privilegeCommand = command
+}
+
+// CheckPrivileges reports whether the appliers can run system-changing
+// commands, naming the fix when they can't
+func (r *Reconciler) CheckPrivileges(ctx context.Context) error {
+	return apply.CheckPrivileges(r.CommandContext(ctx))
+}
+
+// CommandContext carries the settings for the commands appliers run, so
+// checks made outside a pass run their commands the same way
+func (r *Reconciler) CommandContext(ctx context.Context) context.Context {
+	return apply.WithPrivilegeCommand(apply.WithCommandTimeout(ctx, r.commandTimeout), r.privilegeCommand)
+}
+
+// SetSourceCache serves https file sources with a declared checksum from
+// cache once downloaded
+func (r *Reconciler) SetSourceCache(cache *apply.SourceCache) {
+	r.fileEnforcer.SetSourceCache(cache)
+}
+
+func (r *Reconciler) logResults(ctx context.Context, results []ReconcileResult) {
 	compliant := 0
 	enforced := 0
 	failed := 0
@@ -194,34 +464,163 @@ func (r *Reconciler) logResults(results []ReconcileResult) {
 	for _, result := range results {
 		if result.Error != nil {
 			failed++
-			log.Printf("   ✗ %s/%s: %v", result.ResourceType, result.ResourceName, result.Error)
+			logResult(ctx, slog.LevelError, result, "   ✗ %s/%s: %v", result.ResourceType, result.ResourceName, result.Error)
 		} else if result.WasCompliant {
 			compliant++
 		} else {
 			enforced++
 			if result.DryRun {
-				log.Printf("   🔍 [DRY-RUN] %s/%s: would execute '%s'", result.ResourceType, result.ResourceName, result.Action)
+				logResult(ctx, slog.LevelInfo, result, "   🔍 [DRY-RUN] %s/%s: would execute '%s'", result.ResourceType, result.ResourceName, result.Action)
 			} else {
-				log.Printf("   ✓ %s/%s: %s", result.ResourceType, result.ResourceName, result.Action)
+				logResult(ctx, slog.LevelInfo, result, "   ✓ %s/%s: %s", result.ResourceType, result.ResourceName, result.Action)
+			}
+		}
+	}
+
+	logAttrs(ctx, slog.LevelInfo, fmt.Sprintf("   Summary: %d compliant, %d enforced, %d failed", compliant, enforced, failed),
+		slog.Int("compliant", compliant),
+		slog.Int("enforced", enforced),
+		slog.Int("failed", failed))
+}
+
+// ReconcileEvent reconciles only the resources affected by an event. A file
+// change reconciles the matching FileConfig and a unit state change the
+// matching ServiceConfig; anything that can't be mapped to a specific
+// resource falls back to a full ReconcileAll. For a file change,
+// resourceName may instead be a comma-separated list of file:PATH and
+// service:NAME references that the watcher mapped the path to.
+// An event arriving while another pass runs doesn't start a competing one:
+// it is coalesced into a full pass run as soon as the current one finishes,
+// and ReconcileEvent returns no results.
+func (r *Reconciler) ReconcileEvent(ctx context.Context, eventType, resourceName string, state *config.State) ([]ReconcileResult, error) {
+	r.pendingMu.Lock()
+	if !r.passMu.TryLock() {
+		r.pending = state
+		r.pendingMu.Unlock()
+		slog.Info(fmt.Sprintf("🔧 %s changed (%s) during a running pass, coalescing into a follow-up pass", resourceName, eventType))
+		return nil, nil
+	}
+	r.pendingMu.Unlock()
+	defer r.releasePass(ctx)
+
+	ctx = withModeOverrides(r.CommandContext(ensureRunID(ctx, state)), state)
+	if r.passMode(ctx) == ModeDisabled {
+		return nil, nil
+	}
+
+	logf(ctx, "🔧 Triggered reconciliation: %s changed (%s)", resourceName, eventType)
+	if frozen(ctx) {
+		logf(ctx, "❄️  %s", freezeMessage(state.Freeze))
+	}
+
+	switch eventType {
+	case "file_modified":
+		if !strings.HasPrefix(resourceName, "/") {
+			files, services := referencedResources(state, strings.Split(resourceName, ","))
+			if len(files)+len(services) > 0 {
+				return r.reconcileScoped(ctx, state, files, services)
 			}
+		} else if files := matchingFiles(state.Files, resourceName); len(files) > 0 {
+			return r.reconcileScoped(ctx, state, files, nil)
+		}
+	case "unit_state_change":
+		if services := matchingServices(state.Services, resourceName); len(services) > 0 {
+			return r.reconcileScoped(ctx, state, nil, services)
 		}
 	}
 
-	log.Printf("   Summary: %d compliant, %d enforced, %d failed", compliant, enforced, failed)
+	logf(ctx, "   No managed resource matches %s, reconciling everything", resourceName)
+	return r.reconcileAll(ctx, state)
 }
 
-// ReconcileEvent triggers reconciliation for a specific event
-func (r *Reconciler) ReconcileEvent(ctx context.Context, eventType, resourceName string, state *config.State) error {
-	if r.mode == ModeDisabled {
-		return nil
+// releasePass ends the running pass, first running one full pass for any
+// events coalesced into it. passMu is released under pendingMu, so an event
+// either sees the pass still running and leaves its state for this loop, or
+// finds it released and runs itself.
+func (r *Reconciler) releasePass(ctx context.Context) {
+	for {
+		r.pendingMu.Lock()
+		state := r.pending
+		r.pending = nil
+		if state == nil {
+			r.passMu.Unlock()
+			r.pendingMu.Unlock()
+			return
+		}
+		r.pendingMu.Unlock()
+
+		// A pass of its own, not a continuation of the one that just ended
+		passCtx := WithRunID(ctx, NewRunID(state))
+		logf(passCtx, "🔧 Running the pass coalesced from events during the previous one")
+		r.reconcileAll(passCtx, state)
 	}
+}
 
-	log.Printf("🔧 Triggered reconciliation: %s changed (%s)", resourceName, eventType)
+// matchingFiles returns the managed files whose path is the changed path
+func matchingFiles(files []config.FileConfig, path string) []config.FileConfig {
+	var matched []config.FileConfig
+	for _, file := range files {
+		if filepath.Clean(string(file.Path)) == filepath.Clean(path) {
+			matched = append(matched, file)
+		}
+	}
+	return matched
+}
+
+// reconcileScoped reconciles only the given services and files, then
+// restarts the services that changed files notify
+func (r *Reconciler) reconcileScoped(ctx context.Context, state *config.State, files []config.FileConfig, services []config.ServiceConfig) ([]ReconcileResult, error) {
+	r.passes.Add(1)
+	var results []ReconcileResult
+	var errs []error
+	if len(services) > 0 {
+		serviceResults, err := r.ReconcileServices(ctx, services)
+		results = append(results, r.observe(serviceResults...)...)
+		errs = append(errs, err)
+	}
+	if len(files) > 0 {
+		r.fileEnforcer.SetTemplateData(apply.NewTemplateData(state.Metadata))
+		fileResults, err := r.ReconcileFiles(ctx, files)
+		results = append(results, r.observe(fileResults...)...)
+		errs = append(errs, err)
+	}
+	results = append(results, r.observe(r.restartNotified(ctx, state, results)...)...)
+
+	r.recordResults(ctx, results)
+	r.recordApplied(ctx, state, results, false)
+	return results, errors.Join(errs...)
+}
 
-	// For now, reconcile everything
-	// TODO: Optimize to only reconcile affected resources
-	_, err := r.ReconcileAll(ctx, state)
-	return err
+// referencedResources returns the managed files and services named by
+// file:PATH and service:NAME references. References to anything else, or
+// to resources the state doesn't manage, are skipped.
+func referencedResources(state *config.State, refs []string) ([]config.FileConfig, []config.ServiceConfig) {
+	var files []config.FileConfig
+	var services []config.ServiceConfig
+	for _, ref := range refs {
+		resourceType, name, _ := strings.Cut(strings.TrimSpace(ref), ":")
+		switch resourceType {
+		case "file":
+			files = append(files, matchingFiles(state.Files, name)...)
+		case "service":
+			services = append(services, matchingServices(state.Services, name)...)
+		}
+	}
+	return files, services
+}
+
+// matchingServices returns the managed services for a unit name; units are
+// reported with their suffix (nginx.service) while config omits it
+func matchingServices(services []config.ServiceConfig, unit string) []config.ServiceConfig {
+	name := strings.TrimSuffix(unit, ".service")
+
+	var matched []config.ServiceConfig
+	for _, svc := range services {
+		if strings.TrimSuffix(svc.Name, ".service") == name {
+			matched = append(matched, svc)
+		}
+	}
+	return matched
 }
 
 // HealthCheck verifies the reconciler is functioning
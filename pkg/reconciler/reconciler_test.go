@@ -2,8 +2,12 @@ package reconciler
 
 import (
 	"context"
+	"errors"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/power-edge/power-edge/pkg/apply"
 	"github.com/power-edge/power-edge/pkg/config"
 )
 
@@ -33,6 +37,10 @@ func TestNewReconciler(t *testing.T) {
 	if r.fileEnforcer == nil {
 		t.Error("File enforcer not initialized")
 	}
+
+	if r.dnsEnforcer == nil {
+		t.Error("DNS enforcer not initialized")
+	}
 }
 
 func TestReconcileMode(t *testing.T) {
@@ -225,7 +233,7 @@ func TestReconcileFirewall(t *testing.T) {
 	result, err := r.ReconcileFirewall(ctx, fw)
 
 	// If UFW is not installed, skip the test
-	if err != nil && err.Error() == "ufw is not installed" {
+	if errors.Is(err, apply.ErrFirewallBackendMissing) {
 		t.Skip("UFW not installed, skipping test")
 	}
 
@@ -324,6 +332,8 @@ func TestHealthCheck(t *testing.T) {
 
 func TestReconcileEvent(t *testing.T) {
 	r := NewReconciler(ModeDryRun)
+	tmpDir := t.TempDir()
+	managed := filepath.Join(tmpDir, "managed.conf")
 
 	state := &config.State{
 		Services: []config.ServiceConfig{
@@ -333,22 +343,55 @@ func TestReconcileEvent(t *testing.T) {
 				Enabled: true,
 			},
 		},
+		Files: []config.FileConfig{
+			{Path: config.UnixPath(managed), Content: "managed"},
+			{Path: config.UnixPath(filepath.Join(tmpDir, "other.conf")), Content: "other"},
+		},
 	}
 
 	ctx := context.Background()
-	err := r.ReconcileEvent(ctx, "file_modified", "/etc/test.conf", state)
 
+	// A change to a managed file only reconciles that file
+	results, err := r.ReconcileEvent(ctx, "file_modified", managed, state)
 	if err != nil {
 		t.Errorf("ReconcileEvent() returned error: %v", err)
 	}
+	if len(results) != 1 || results[0].ResourceName != managed {
+		t.Errorf("Expected only %s to be reconciled, got %+v", managed, results)
+	}
+
+	// A unit change only reconciles the matching service
+	results, _ = r.ReconcileEvent(ctx, "unit_state_change", "test-service.service", state)
+	if len(results) != 1 || results[0].ResourceType != "service" {
+		t.Errorf("Expected only test-service to be reconciled, got %+v", results)
+	}
+
+	// A path mapped to resources reconciles exactly those
+	results, _ = r.ReconcileEvent(ctx, "file_modified", "file:"+managed+",service:test-service", state)
+	if len(results) != 2 || results[0].ResourceType != "service" || results[1].ResourceName != managed {
+		t.Errorf("Expected test-service and %s to be reconciled, got %+v", managed, results)
+	}
+
+	// Unmatched events fall back to reconciling everything
+	results, _ = r.ReconcileEvent(ctx, "file_modified", "service:missing", state)
+	if len(results) != 3 {
+		t.Errorf("Expected full reconciliation of 3 resources, got %d", len(results))
+	}
+	results, _ = r.ReconcileEvent(ctx, "file_modified", "/etc/test.conf", state)
+	if len(results) != 3 {
+		t.Errorf("Expected full reconciliation of 3 resources, got %d", len(results))
+	}
 
 	// Test with disabled mode
 	r.SetMode(ModeDisabled)
-	err = r.ReconcileEvent(ctx, "file_modified", "/etc/test.conf", state)
+	results, err = r.ReconcileEvent(ctx, "file_modified", managed, state)
 
 	if err != nil {
 		t.Errorf("ReconcileEvent() with disabled mode returned error: %v", err)
 	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results in disabled mode, got %d", len(results))
+	}
 }
 
 func TestReconcileResult(t *testing.T) {
@@ -385,3 +428,46 @@ func TestReconcileResult(t *testing.T) {
 		t.Error("Expected DryRun to be true")
 	}
 }
+
+func TestReconcileEvent_CoalescesIntoRunningPass(t *testing.T) {
+	r := NewReconciler(ModeDisabled)
+	ctx := context.Background()
+	state := &config.State{}
+
+	// A pass is running
+	r.passMu.Lock()
+
+	results, err := r.ReconcileEvent(ctx, "file_modified", "/etc/hosts", state)
+	if err != nil || results != nil {
+		t.Fatalf("ReconcileEvent() = %v, %v; want it coalesced", results, err)
+	}
+	if r.pending != state {
+		t.Fatal("event state not left for the running pass")
+	}
+
+	// A full pass waits for the running one
+	done := make(chan struct{})
+	go func() {
+		r.ReconcileAll(ctx, state)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("ReconcileAll() ran concurrently with another pass")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	r.releasePass(ctx)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ReconcileAll() still waiting after the pass ended")
+	}
+
+	if r.pending != nil {
+		t.Error("coalesced pass was not run")
+	}
+	if !r.passMu.TryLock() {
+		t.Error("pass lock still held")
+	}
+}
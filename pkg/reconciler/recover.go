@@ -0,0 +1,50 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+// recoverReconcile runs reconcile for one resource, turning a panic into a
+// failed result carrying the stack trace, so one broken resource can't abort
+// the pass or crash the agent. A panic is a bug rather than a transient
+// failure, so callers wrap it around withRetry and it is never retried.
+func recoverReconcile(ctx context.Context, mode ReconcileMode, resourceType, name string, reconcile func() (ReconcileResult, error)) (result ReconcileResult, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			result = panicResult(ctx, mode, resourceType, name, p, debug.Stack())
+			err = result.Error
+		}
+	}()
+	return reconcile()
+}
+
+// recoverBatch is recoverReconcile for enforcers that apply a whole batch at
+// once: a panic fails every resource in the batch
+func recoverBatch(ctx context.Context, mode ReconcileMode, resourceType string, names []string, reconcile func() []ReconcileResult) (results []ReconcileResult) {
+	defer func() {
+		if p := recover(); p != nil {
+			stack := debug.Stack()
+			results = make([]ReconcileResult, 0, len(names))
+			for _, name := range names {
+				results = append(results, panicResult(ctx, mode, resourceType, name, p, stack))
+			}
+		}
+	}()
+	return reconcile()
+}
+
+func panicResult(ctx context.Context, mode ReconcileMode, resourceType, name string, p interface{}, stack []byte) ReconcileResult {
+	err := fmt.Errorf("panic: %v", p)
+	logAtf(ctx, slog.LevelError, "      💥 %s/%s: %v\n%s", resourceType, name, err, stack)
+	return ReconcileResult{
+		ResourceType: resourceType,
+		ResourceName: name,
+		DryRun:       mode == ModeDryRun,
+		RunID:        RunIDFromContext(ctx),
+		Error:        err,
+		Stack:        string(stack),
+	}
+}
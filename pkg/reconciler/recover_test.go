@@ -0,0 +1,60 @@
+package reconciler
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestReconcileAll_RecoversPanickingEnforcer(t *testing.T) {
+	r := NewReconciler(ModeDryRun)
+	// Enforcers without an applier dereference nil as soon as they run
+	r.repoEnforcer = &RepositoryEnforcer{}
+	r.dnsEnforcer = &DNSEnforcer{}
+
+	path := filepath.Join(t.TempDir(), "managed.conf")
+	state := &config.State{
+		Repositories: []config.RepoConfig{
+			{Name: "docker", URL: "https://download.docker.com/linux/ubuntu", Suite: "jammy"},
+			{Name: "internal", URL: "https://apt.example.com", Suite: "stable"},
+		},
+		Files: []config.FileConfig{{Path: config.UnixPath(path), Content: "hello\n"}},
+		DNS:   config.DNSConfig{Nameservers: []string{"1.1.1.1"}},
+		Hooks: config.HooksConfig{Post: "true"},
+	}
+
+	results, err := r.ReconcileAll(context.Background(), state)
+	if err != nil {
+		t.Fatalf("ReconcileAll() error = %v", err)
+	}
+
+	got := make(map[string]ReconcileResult)
+	for _, result := range results {
+		got[result.ResourceType+"/"+result.ResourceName] = result
+	}
+
+	for _, key := range []string{"repository/docker", "repository/internal", "dns/resolver"} {
+		result, ok := got[key]
+		if !ok {
+			t.Errorf("no result for %s in %v", key, results)
+			continue
+		}
+		if result.Error == nil || !strings.HasPrefix(result.Error.Error(), "panic: ") {
+			t.Errorf("%s error = %v, want recovered panic", key, result.Error)
+		}
+		if !strings.Contains(result.Stack, "recoverReconcile") && !strings.Contains(result.Stack, "recoverBatch") {
+			t.Errorf("%s stack = %q, want a stack trace", key, result.Stack)
+		}
+	}
+
+	// Resources before and after the panics are still reconciled
+	if result, ok := got["file/"+path]; !ok || result.Error != nil {
+		t.Errorf("file result = %+v, want reconciled", result)
+	}
+	if _, ok := got["hook/post"]; !ok {
+		t.Errorf("post hook missing from %v, want the pass to complete", results)
+	}
+}
@@ -0,0 +1,269 @@
+package reconciler
+
+import (
+	"context"
+	"time"
+
+	"github.com/power-edge/power-edge/pkg/apply"
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// DriftReport describes how the node compares to its desired state
+type DriftReport struct {
+	Timestamp time.Time       `json:"timestamp"`
+	RunID     string          `json:"run_id"`
+	Compliant []ResourceDrift `json:"compliant"`
+	Drifted   []ResourceDrift `json:"drifted"`
+	Errors    []ResourceDrift `json:"errors"`
+}
+
+// ResourceDrift is the desired vs current state of a single resource
+type ResourceDrift struct {
+	Type    string      `json:"type"`
+	Name    string      `json:"name"`
+	Desired interface{} `json:"desired"`
+	Current interface{} `json:"current,omitempty"`
+	Action  string      `json:"action,omitempty"` // What enforcing would do
+	Error   string      `json:"error,omitempty"`
+}
+
+// Total returns the number of resources in the report
+func (d DriftReport) Total() int {
+	return len(d.Compliant) + len(d.Drifted) + len(d.Errors)
+}
+
+// CompliancePercentage returns the share of compliant resources (100 when empty)
+func (d DriftReport) CompliancePercentage() float64 {
+	if d.Total() == 0 {
+		return 100
+	}
+	return float64(len(d.Compliant)) / float64(d.Total()) * 100
+}
+
+// ComplianceSummary counts resource compliance for one resource type
+type ComplianceSummary struct {
+	Total      int     `json:"total"`
+	Compliant  int     `json:"compliant"`
+	Drifted    int     `json:"drifted"`
+	Errors     int     `json:"errors"`
+	Percentage float64 `json:"percentage"`
+}
+
+// ByType breaks the report down per resource type (service, sysctl, ...)
+func (d DriftReport) ByType() map[string]ComplianceSummary {
+	summaries := make(map[string]ComplianceSummary)
+	count := func(resources []ResourceDrift, field func(*ComplianceSummary)) {
+		for _, res := range resources {
+			s := summaries[res.Type]
+			s.Total++
+			field(&s)
+			summaries[res.Type] = s
+		}
+	}
+	count(d.Compliant, func(s *ComplianceSummary) { s.Compliant++ })
+	count(d.Drifted, func(s *ComplianceSummary) { s.Drifted++ })
+	count(d.Errors, func(s *ComplianceSummary) { s.Errors++ })
+
+	for typ, s := range summaries {
+		s.Percentage = float64(s.Compliant) / float64(s.Total) * 100
+		summaries[typ] = s
+	}
+	return summaries
+}
+
+// reportMaxAge is how long CachedReport serves a report when no pass has run
+// since, so drift made by hand still shows up on a node that isn't enforcing
+const reportMaxAge = 30 * time.Second
+
+// cachedReport is the last report and what it was computed for
+type cachedReport struct {
+	report DriftReport
+	state  *config.State
+	passes uint64 // Reconciler.passes when the report was computed
+}
+
+// CachedReport returns the last report computed for state, as long as no pass
+// has run since and it is younger than reportMaxAge, and a fresh Report
+// otherwise. Status endpoints use it so every request doesn't rerun the
+// enforcers' checks, including validate commands and source downloads.
+func (r *Reconciler) CachedReport(ctx context.Context, state *config.State) (DriftReport, error) {
+	r.reportMu.Lock()
+	cached := r.lastReport
+	r.reportMu.Unlock()
+
+	if cached.state == state && cached.passes == r.passes.Load() && time.Since(cached.report.Timestamp) < reportMaxAge {
+		return cached.report, nil
+	}
+	return r.Report(ctx, state)
+}
+
+// Report checks every resource in state and returns a drift report. It always
+// runs the enforcers in dry-run, whatever the configured mode, so it never
+// changes the system; hooks and retries are skipped as well.
+// The enforcers are shared with passes, so Report waits for a running pass
+// to finish rather than checking alongside it.
+func (r *Reconciler) Report(ctx context.Context, state *config.State) (DriftReport, error) {
+	r.passMu.Lock()
+	defer r.releasePass(ctx)
+
+	report := r.report(ctx, state)
+	r.reportMu.Lock()
+	r.lastReport = cachedReport{report: report, state: state, passes: r.passes.Load()}
+	r.reportMu.Unlock()
+	return report, nil
+}
+
+func (r *Reconciler) report(ctx context.Context, state *config.State) DriftReport {
+	ctx = withQuiet(r.CommandContext(ensureRunID(ctx, state)))
+
+	report := DriftReport{
+		Timestamp: time.Now().UTC(),
+		RunID:     RunIDFromContext(ctx),
+		Compliant: []ResourceDrift{},
+		Drifted:   []ResourceDrift{},
+		Errors:    []ResourceDrift{},
+	}
+
+	add := func(result ReconcileResult, desired, current interface{}) {
+		drift := ResourceDrift{
+			Type:    result.ResourceType,
+			Name:    result.ResourceName,
+			Desired: desired,
+			Current: current,
+		}
+		switch {
+		case result.Error != nil:
+			drift.Error = result.Error.Error()
+			report.Errors = append(report.Errors, drift)
+		case result.WasCompliant:
+			report.Compliant = append(report.Compliant, drift)
+		default:
+			drift.Action = result.Action
+			report.Drifted = append(report.Drifted, drift)
+		}
+	}
+
+	for _, svc := range state.Services {
+		result, _ := r.serviceEnforcer.Reconcile(ctx, svc, ModeDryRun)
+		active, enabled, _ := r.serviceEnforcer.Check(ctx, svc.Name)
+		add(result,
+			map[string]interface{}{"state": svc.State, "enabled": svc.Enabled},
+			map[string]interface{}{"active": active, "enabled": enabled})
+	}
+
+	r.sysctlEnforcer.SetPolicy(state.SysctlPolicy)
+	for _, key := range sortedKeys(state.Sysctl) {
+		result, _ := r.sysctlEnforcer.Reconcile(ctx, key, state.Sysctl[key], ModeDryRun)
+		current, _ := r.sysctlEnforcer.Get(ctx, key)
+		add(result, state.Sysctl[key], current)
+	}
+
+	if state.Firewall.Configured() {
+		result, _ := r.firewallEnforcer.Reconcile(ctx, &state.Firewall, ModeDryRun)
+		enabled, _ := r.firewallEnforcer.Check(ctx, &state.Firewall)
+		add(result,
+			map[string]interface{}{
+				"enabled":          state.Firewall.Enabled,
+				"allowed_services": state.Firewall.AllowedServices,
+				"interfaces":       state.Firewall.Interfaces,
+				"default_policy":   state.Firewall.DefaultPolicy,
+				"logging":          state.Firewall.Logging,
+			},
+			map[string]interface{}{"enabled": enabled})
+	}
+
+	for i, result := range r.repoEnforcer.ReconcileAll(ctx, state.Repositories, ModeDryRun) {
+		repo := state.Repositories[i]
+		add(result, map[string]interface{}{"url": repo.URL, "suite": repo.Suite, "components": repo.Components}, nil)
+	}
+
+	for _, pkg := range state.Packages {
+		result, _ := r.packageEnforcer.Reconcile(ctx, pkg, ModeDryRun)
+		installed, version, held, _ := r.packageEnforcer.Check(ctx, pkg.Name)
+		add(result,
+			map[string]interface{}{"state": pkg.State, "version": pkg.Version, "hold": pkg.Hold},
+			map[string]interface{}{"installed": installed, "version": version, "held": held})
+	}
+
+	if len(state.Files) > 0 {
+		r.fileEnforcer.SetTemplateData(apply.NewTemplateData(state.Metadata))
+	}
+	for _, file := range state.Files {
+		result, _ := r.fileEnforcer.Reconcile(ctx, file, ModeDryRun)
+		exists, mode, owner, group, sum, seContext, _ := r.fileEnforcer.Check(ctx, string(file.Path))
+		add(result,
+			map[string]interface{}{"state": file.State, "mode": file.Mode, "owner": file.Owner, "group": file.Group, "sha256": file.SHA256, "selinux_context": file.SELinuxContext},
+			map[string]interface{}{"exists": exists, "mode": mode, "owner": owner, "group": group, "sha256": sum, "selinux_context": seContext})
+	}
+
+	if len(state.DNS.Nameservers) > 0 || len(state.DNS.Search) > 0 {
+		result, _ := r.dnsEnforcer.Reconcile(ctx, &state.DNS, ModeDryRun)
+		nameservers, search, _ := r.dnsEnforcer.Check()
+		add(result, state.DNS, config.DNSConfig{Nameservers: nameservers, Search: search})
+	}
+
+	return report
+}
+
+// ResultsReport summarizes the results of a pass as a drift report, without
+// checking anything again. Resources an enforce pass changed count as
+// compliant, those a dry-run would change as drifted. A resource with several
+// results, such as a service that was also restarted, is counted once, by
+// its worst result. Hooks are left out, as in Report.
+func ResultsReport(results []ReconcileResult) DriftReport {
+	report := DriftReport{
+		Timestamp: time.Now().UTC(),
+		Compliant: []ResourceDrift{},
+		Drifted:   []ResourceDrift{},
+		Errors:    []ResourceDrift{},
+	}
+
+	const (
+		compliant = iota
+		drifted
+		failed
+	)
+	var order []string
+	worst := make(map[string]int)
+	drifts := make(map[string]ResourceDrift)
+	for _, result := range results {
+		if report.RunID == "" {
+			report.RunID = result.RunID
+		}
+		if result.ResourceType == "hook" {
+			continue
+		}
+
+		drift := ResourceDrift{Type: result.ResourceType, Name: result.ResourceName}
+		status := compliant
+		switch {
+		case result.Error != nil:
+			drift.Error = result.Error.Error()
+			status = failed
+		case !result.WasCompliant && result.DryRun:
+			drift.Action = result.Action
+			status = drifted
+		}
+
+		key := config.ResourceRef(result.ResourceType, result.ResourceName)
+		if prev, seen := worst[key]; !seen {
+			order = append(order, key)
+		} else if prev >= status {
+			continue
+		}
+		worst[key] = status
+		drifts[key] = drift
+	}
+
+	for _, key := range order {
+		switch worst[key] {
+		case failed:
+			report.Errors = append(report.Errors, drifts[key])
+		case drifted:
+			report.Drifted = append(report.Drifted, drifts[key])
+		default:
+			report.Compliant = append(report.Compliant, drifts[key])
+		}
+	}
+	return report
+}
@@ -0,0 +1,152 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestReport(t *testing.T) {
+	tmpDir := t.TempDir()
+	compliant := filepath.Join(tmpDir, "compliant.conf")
+	drifted := filepath.Join(tmpDir, "drifted.conf")
+
+	if err := os.WriteFile(compliant, []byte("ok"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	state := &config.State{
+		Files: []config.FileConfig{
+			{Path: config.UnixPath(compliant), Content: "ok"},
+			{Path: config.UnixPath(drifted), Content: "missing"},
+		},
+	}
+
+	// Report never enforces, even in enforce mode
+	r := NewReconciler(ModeEnforce)
+	report, err := r.Report(context.Background(), state)
+	if err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+
+	if _, err := os.Stat(drifted); !os.IsNotExist(err) {
+		t.Error("Report() must not change the system")
+	}
+
+	if len(report.Compliant) != 1 || report.Compliant[0].Name != compliant {
+		t.Errorf("Expected %s to be compliant, got %+v", compliant, report.Compliant)
+	}
+	if len(report.Drifted) != 1 || report.Drifted[0].Name != drifted || report.Drifted[0].Action == "" {
+		t.Errorf("Expected %s to be drifted with an action, got %+v", drifted, report.Drifted)
+	}
+	if report.Total() != 2 || report.CompliancePercentage() != 50 {
+		t.Errorf("Total() = %d, CompliancePercentage() = %.1f", report.Total(), report.CompliancePercentage())
+	}
+	if report.RunID == "" {
+		t.Error("Expected report to carry a run ID")
+	}
+
+	byType := report.ByType()
+	if files := byType["file"]; files.Total != 2 || files.Compliant != 1 || files.Drifted != 1 || files.Percentage != 50 {
+		t.Errorf("ByType()[file] = %+v", files)
+	}
+}
+
+func TestCachedReport(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "app.conf")
+	state := &config.State{
+		Files: []config.FileConfig{{Path: config.UnixPath(path), Content: "ok"}},
+	}
+
+	r := NewReconciler(ModeEnforce)
+	first, err := r.CachedReport(context.Background(), state)
+	if err != nil {
+		t.Fatalf("CachedReport() error = %v", err)
+	}
+	if len(first.Drifted) != 1 {
+		t.Fatalf("Expected %s to be drifted, got %+v", path, first)
+	}
+
+	// Drift fixed by hand isn't seen until the cache goes stale
+	if err := os.WriteFile(path, []byte("ok"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if cached, _ := r.CachedReport(context.Background(), state); cached.RunID != first.RunID {
+		t.Errorf("CachedReport() recomputed the report, want the cached one")
+	}
+
+	// A pass makes the cached report stale
+	if _, err := r.ReconcileAll(context.Background(), state); err != nil {
+		t.Fatalf("ReconcileAll() error = %v", err)
+	}
+	fresh, _ := r.CachedReport(context.Background(), state)
+	if fresh.RunID == first.RunID || len(fresh.Compliant) != 1 {
+		t.Errorf("CachedReport() after a pass = %+v, want a fresh compliant report", fresh)
+	}
+
+	// So does another state
+	other := &config.State{}
+	if report, _ := r.CachedReport(context.Background(), other); report.Total() != 0 {
+		t.Errorf("CachedReport() for another state = %+v, want an empty report", report)
+	}
+}
+
+func TestReportDuringPass(t *testing.T) {
+	tmpDir := t.TempDir()
+	state := &config.State{
+		Metadata: config.Metadata{Site: "lab"},
+		Files: []config.FileConfig{
+			{Path: config.UnixPath(filepath.Join(tmpDir, "a.conf")), Content: "{{ .Site }}", Template: true},
+		},
+	}
+
+	// Run with -race: reports and passes share the enforcers
+	r := NewReconciler(ModeEnforce)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			r.ReconcileAll(context.Background(), state)
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		if _, err := r.Report(context.Background(), state); err != nil {
+			t.Fatalf("Report() error = %v", err)
+		}
+	}
+	<-done
+}
+
+func TestResultsReport(t *testing.T) {
+	results := []ReconcileResult{
+		{ResourceType: "hook", ResourceName: "pre", RunID: "run-1"},
+		{ResourceType: "service", ResourceName: "nginx", WasCompliant: true, RunID: "run-1"},
+		{ResourceType: "file", ResourceName: "/etc/app.conf", Action: "wrote file", RunID: "run-1"},
+		{ResourceType: "sysctl", ResourceName: "vm.swappiness", Action: "would set", DryRun: true, RunID: "run-1"},
+		{ResourceType: "package", ResourceName: "curl", Error: errors.New("apt-get failed"), RunID: "run-1"},
+		// A notified restart that failed counts against the service
+		{ResourceType: "service", ResourceName: "nginx", Error: errors.New("restart failed"), RunID: "run-1"},
+	}
+
+	report := ResultsReport(results)
+	if report.RunID != "run-1" {
+		t.Errorf("RunID = %q, want run-1", report.RunID)
+	}
+	if report.Total() != 4 {
+		t.Errorf("Total() = %d, want 4 resources without the hook", report.Total())
+	}
+	if len(report.Compliant) != 1 || report.Compliant[0].Name != "/etc/app.conf" {
+		t.Errorf("Compliant = %+v, want the enforced file", report.Compliant)
+	}
+	if len(report.Drifted) != 1 || report.Drifted[0].Name != "vm.swappiness" || report.Drifted[0].Action != "would set" {
+		t.Errorf("Drifted = %+v, want the dry-run sysctl", report.Drifted)
+	}
+	if len(report.Errors) != 2 || report.Errors[0].Name != "nginx" || report.Errors[0].Error != "restart failed" {
+		t.Errorf("Errors = %+v, want nginx then curl", report.Errors)
+	}
+}
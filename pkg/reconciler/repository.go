@@ -0,0 +1,63 @@
+package reconciler
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/power-edge/power-edge/pkg/apply"
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// RepositoryEnforcer orchestrates WHEN to apply package repositories
+// The actual HOW is delegated to pkg/apply
+type RepositoryEnforcer struct {
+	applier *apply.RepositoryApplier
+}
+
+// NewRepositoryEnforcer creates a new repository enforcer
+func NewRepositoryEnforcer() *RepositoryEnforcer {
+	return &RepositoryEnforcer{
+		applier: apply.NewRepositoryApplier(),
+	}
+}
+
+// ReconcileAll detects drift across all repositories and fixes it, refreshing
+// the package index once if anything changed. Results are returned per
+// repository, in state order.
+func (e *RepositoryEnforcer) ReconcileAll(ctx context.Context, repos []config.RepoConfig, mode ReconcileMode) []ReconcileResult {
+	dryRun := (mode != ModeEnforce)
+	applyResults := e.applier.ApplyAll(ctx, repos, dryRun)
+
+	results := make([]ReconcileResult, 0, len(repos))
+	for _, repo := range repos {
+		applyResult := applyResults[repo.Name]
+		result := ReconcileResult{
+			ResourceType: "repository",
+			ResourceName: repo.Name,
+			DryRun:       mode == ModeDryRun,
+			RunID:        RunIDFromContext(ctx),
+			Error:        applyResult.Error,
+			Diff:         applyResult.Diff,
+		}
+
+		switch {
+		case applyResult.Error != nil:
+		case !applyResult.Changed:
+			result.WasCompliant = true
+			result.Action = "compliant"
+			logResult(ctx, slog.LevelDebug, result, "      ✓ %s: already compliant", repo.Name)
+		default:
+			result.Action = strings.Join(applyResult.Actions, "; ")
+			result.Actions = applyResult.Actions
+			if mode == ModeDryRun {
+				logResult(ctx, slog.LevelInfo, result, "      🔍 [DRY-RUN] %s: would execute: %s", repo.Name, result.Action)
+			} else if mode == ModeEnforce {
+				logResult(ctx, slog.LevelInfo, result, "      ✓ %s: executed '%s'", repo.Name, result.Action)
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
@@ -0,0 +1,24 @@
+package reconciler
+
+import (
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestNewRepositoryEnforcer(t *testing.T) {
+	e := NewRepositoryEnforcer()
+
+	if e.applier == nil {
+		t.Error("Applier not initialized")
+	}
+}
+
+func TestReconciler_RepositoriesFollowPackageMode(t *testing.T) {
+	r := NewReconciler(ModeEnforce)
+	state := &config.State{Reconcile: config.ReconcileOverrides{Packages: config.ReconcileModeDryRun}}
+
+	if got := r.EffectiveMode(state, "repository"); got != ModeDryRun {
+		t.Errorf("EffectiveMode(repository) = %s, want %s", got, ModeDryRun)
+	}
+}
@@ -0,0 +1,190 @@
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ResultRecord is the machine-readable form of a ReconcileResult, written
+// one JSON object per line by a ResultWriter
+type ResultRecord struct {
+	Time         time.Time `json:"time"`
+	RunID        string    `json:"run_id,omitempty"`
+	Mode         string    `json:"mode"`
+	ResourceType string    `json:"resource_type"`
+	ResourceName string    `json:"resource_name"`
+	WasCompliant bool      `json:"was_compliant"`
+	Action       string    `json:"action,omitempty"`
+	DryRun       bool      `json:"dry_run"`
+	Error        string    `json:"error,omitempty"`
+	Stack        string    `json:"stack,omitempty"`
+	DurationMS   int64     `json:"duration_ms"`
+}
+
+// ResultWriter serializes reconcile results as NDJSON. It is safe for
+// concurrent use, so periodic and event-driven passes can share one.
+type ResultWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewResultWriter creates a ResultWriter writing to w
+func NewResultWriter(w io.Writer) *ResultWriter {
+	return &ResultWriter{enc: json.NewEncoder(w)}
+}
+
+// WriteResults writes one record per result
+func (w *ResultWriter) WriteResults(mode ReconcileMode, results []ReconcileResult) error {
+	now := time.Now().UTC()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, result := range results {
+		if err := w.enc.Encode(newResultRecord(now, mode, result)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newResultRecord(now time.Time, mode ReconcileMode, result ReconcileResult) ResultRecord {
+	record := ResultRecord{
+		Time:         now,
+		RunID:        result.RunID,
+		Mode:         string(mode),
+		ResourceType: result.ResourceType,
+		ResourceName: result.ResourceName,
+		WasCompliant: result.WasCompliant,
+		Action:       result.Action,
+		DryRun:       result.DryRun,
+		Stack:        result.Stack,
+		DurationMS:   result.Duration.Milliseconds(),
+	}
+	if result.Error != nil {
+		record.Error = result.Error.Error()
+	}
+	return record
+}
+
+// ChangeRecords returns records for the results that changed the node or
+// failed to, leaving out compliant, skipped and dry-run results and hooks
+// that ran cleanly. A pass with nothing to do yields none.
+func ChangeRecords(mode ReconcileMode, results []ReconcileResult) []ResultRecord {
+	now := time.Now().UTC()
+	var records []ResultRecord
+	for _, result := range results {
+		changed := !result.WasCompliant && !result.DryRun && result.ResourceType != "hook"
+		if changed || result.Error != nil {
+			records = append(records, newResultRecord(now, mode, result))
+		}
+	}
+	return records
+}
+
+// ChangeHandler receives the change records of a reconcile pass
+type ChangeHandler func(ctx context.Context, records []ResultRecord)
+
+// ResultObserver receives each result while a pass is still running, as
+// soon as its resource type is done. It is called from the pass itself, so
+// it must not block.
+type ResultObserver func(record ResultRecord)
+
+// WriteReport writes one record per resource in a drift report. In disabled
+// mode nothing is reconciled, so this is how the read-only checks still
+// reach the stream.
+func (w *ResultWriter) WriteReport(mode ReconcileMode, report DriftReport) error {
+	var results []ReconcileResult
+	add := func(drifts []ResourceDrift, compliant bool) {
+		for _, d := range drifts {
+			result := ReconcileResult{
+				ResourceType: d.Type,
+				ResourceName: d.Name,
+				WasCompliant: compliant,
+				Action:       d.Action,
+				DryRun:       true,
+				RunID:        report.RunID,
+			}
+			if d.Error != "" {
+				result.Error = errorString(d.Error)
+			}
+			results = append(results, result)
+		}
+	}
+	add(report.Compliant, true)
+	add(report.Drifted, false)
+	add(report.Errors, false)
+
+	return w.WriteResults(mode, results)
+}
+
+// errorString restores an error flattened into a drift report
+type errorString string
+
+func (e errorString) Error() string { return string(e) }
+
+// SetResultWriter makes every reconcile pass, periodic or event-driven, write
+// its results to w. Pass nil to stop.
+func (r *Reconciler) SetResultWriter(w *ResultWriter) {
+	r.resultMu.Lock()
+	defer r.resultMu.Unlock()
+	r.resultWriter = w
+}
+
+// SetChangeHandler makes every reconcile pass, periodic or event-driven,
+// hand what it changed or failed to change to fn (see ChangeRecords). fn
+// isn't called for passes without changes. Pass nil to stop.
+func (r *Reconciler) SetChangeHandler(fn ChangeHandler) {
+	r.resultMu.Lock()
+	defer r.resultMu.Unlock()
+	r.changeHandler = fn
+}
+
+// SetResultObserver hands every result of every pass, periodic or
+// event-driven, to fn as it comes in. Pass nil to stop.
+func (r *Reconciler) SetResultObserver(fn ResultObserver) {
+	r.resultMu.Lock()
+	defer r.resultMu.Unlock()
+	r.resultObserver = fn
+}
+
+// observe passes results to the result observer, if any, and returns them
+func (r *Reconciler) observe(results ...ReconcileResult) []ReconcileResult {
+	r.resultMu.Lock()
+	fn := r.resultObserver
+	r.resultMu.Unlock()
+
+	if fn != nil {
+		now := time.Now().UTC()
+		mode := r.GetMode()
+		for _, result := range results {
+			fn(newResultRecord(now, mode, result))
+		}
+	}
+	return results
+}
+
+// recordResults writes results to the configured result writer and change
+// handler, if any
+func (r *Reconciler) recordResults(ctx context.Context, results []ReconcileResult) {
+	r.resultMu.Lock()
+	w, onChange := r.resultWriter, r.changeHandler
+	r.resultMu.Unlock()
+
+	if len(results) == 0 {
+		return
+	}
+	if w != nil {
+		if err := w.WriteResults(r.GetMode(), results); err != nil {
+			logAtf(ctx, slog.LevelWarn, "   ⚠️  Failed to write results: %v", err)
+		}
+	}
+	if onChange != nil {
+		if records := ChangeRecords(r.GetMode(), results); len(records) > 0 {
+			onChange(ctx, records)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package reconciler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func decodeRecords(t *testing.T, buf *bytes.Buffer) []ResultRecord {
+	t.Helper()
+	var records []ResultRecord
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var record ResultRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestResultWriter_WriteResults(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewResultWriter(&buf)
+
+	err := w.WriteResults(ModeEnforce, []ReconcileResult{
+		{ResourceType: "service", ResourceName: "nginx", WasCompliant: true, Action: "compliant", RunID: "abc"},
+		{ResourceType: "sysctl", ResourceName: "vm.swappiness", Action: "set to 10", Error: errors.New("permission denied"), RunID: "abc"},
+	})
+	if err != nil {
+		t.Fatalf("WriteResults() error = %v", err)
+	}
+
+	records := decodeRecords(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 NDJSON lines, got %d", len(records))
+	}
+	if r := records[0]; r.ResourceType != "service" || r.ResourceName != "nginx" || !r.WasCompliant || r.Mode != "enforce" || r.RunID != "abc" {
+		t.Errorf("Unexpected first record: %+v", r)
+	}
+	if r := records[1]; r.WasCompliant || r.Error != "permission denied" || r.Action != "set to 10" {
+		t.Errorf("Unexpected second record: %+v", r)
+	}
+}
+
+func TestResultWriter_WriteReport(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewResultWriter(&buf)
+
+	report := DriftReport{
+		RunID:     "run1",
+		Compliant: []ResourceDrift{{Type: "file", Name: "/a"}},
+		Drifted:   []ResourceDrift{{Type: "file", Name: "/b", Action: "write file"}},
+		Errors:    []ResourceDrift{{Type: "service", Name: "nginx", Error: "unit not found"}},
+	}
+	if err := w.WriteReport(ModeDisabled, report); err != nil {
+		t.Fatalf("WriteReport() error = %v", err)
+	}
+
+	records := decodeRecords(t, &buf)
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+	for _, r := range records {
+		if r.Mode != "disabled" || !r.DryRun || r.RunID != "run1" {
+			t.Errorf("Unexpected record: %+v", r)
+		}
+	}
+	if !records[0].WasCompliant || records[1].WasCompliant || records[1].Action != "write file" || records[2].Error != "unit not found" {
+		t.Errorf("Unexpected records: %+v", records)
+	}
+}
+
+func TestReconciler_SetResultWriter(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "managed.conf")
+
+	state := &config.State{
+		Files: []config.FileConfig{{Path: config.UnixPath(path), Content: "hello"}},
+	}
+
+	var buf bytes.Buffer
+	r := NewReconciler(ModeDryRun)
+	r.SetResultWriter(NewResultWriter(&buf))
+
+	if _, err := r.ReconcileAll(context.Background(), state); err != nil {
+		t.Fatalf("ReconcileAll() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("dry-run must not create the file")
+	}
+
+	records := decodeRecords(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %d: %+v", len(records), records)
+	}
+	if r := records[0]; r.ResourceName != path || r.WasCompliant || !r.DryRun || r.Mode != "dry-run" {
+		t.Errorf("Unexpected record: %+v", r)
+	}
+
+	// Event-driven passes are recorded too
+	if _, err := r.ReconcileEvent(context.Background(), "file_modified", path, state); err != nil {
+		t.Fatalf("ReconcileEvent() error = %v", err)
+	}
+	if records := decodeRecords(t, &buf); len(records) != 1 {
+		t.Errorf("Expected 1 record from the event, got %d", len(records))
+	}
+}
+
+func TestChangeRecords(t *testing.T) {
+	results := []ReconcileResult{
+		{ResourceType: "service", ResourceName: "nginx", WasCompliant: true, Action: "no-op"},
+		{ResourceType: "service", ResourceName: "redis", Action: "started service"},
+		{ResourceType: "sysctl", ResourceName: "vm.swappiness", Action: "set sysctl", DryRun: true},
+		{ResourceType: "file", ResourceName: "/etc/motd", Action: "write", Error: errors.New("permission denied")},
+		{ResourceType: "service", ResourceName: "sshd", WasCompliant: true, Action: "skipped: systemd not available"},
+		{ResourceType: "hook", ResourceName: "pre", Action: "run true"},
+		{ResourceType: "hook", ResourceName: "post", Action: "run false", Error: errors.New("post-hook failed")},
+	}
+
+	records := ChangeRecords(ModeEnforce, results)
+
+	var got []string
+	for _, r := range records {
+		got = append(got, r.ResourceType+"/"+r.ResourceName)
+	}
+	want := []string{"service/redis", "file//etc/motd", "hook/post"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangeRecords() = %v, want %v", got, want)
+	}
+	if records[1].Error != "permission denied" || records[0].Mode != "enforce" {
+		t.Errorf("Unexpected records: %+v", records)
+	}
+
+	if records := ChangeRecords(ModeEnforce, results[:1]); len(records) != 0 {
+		t.Errorf("A compliant pass should yield no records, got %+v", records)
+	}
+}
+
+func TestReconciler_SetChangeHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "managed.conf")
+	state := &config.State{
+		Files: []config.FileConfig{{Path: config.UnixPath(path), Content: "hello"}},
+	}
+
+	var calls [][]ResultRecord
+	r := NewReconciler(ModeEnforce)
+	r.SetChangeHandler(func(ctx context.Context, records []ResultRecord) {
+		calls = append(calls, records)
+	})
+
+	if _, err := r.ReconcileAll(context.Background(), state); err != nil {
+		t.Fatalf("ReconcileAll() error = %v", err)
+	}
+	if len(calls) != 1 || len(calls[0]) != 1 || calls[0][0].ResourceName != path {
+		t.Fatalf("Expected one change for %s, got %+v", path, calls)
+	}
+
+	// The file is compliant now, so the second pass has nothing to report
+	if _, err := r.ReconcileAll(context.Background(), state); err != nil {
+		t.Fatalf("ReconcileAll() error = %v", err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("A compliant pass should not call the handler, got %+v", calls[1:])
+	}
+}
+
+func TestReconciler_SetResultObserver(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "managed.conf")
+	marker := filepath.Join(dir, "post-hook-ran")
+	state := &config.State{
+		Files: []config.FileConfig{{Path: config.UnixPath(path), Content: "hello"}},
+		Hooks: config.HooksConfig{Post: "touch " + marker},
+	}
+
+	var observed []ResultRecord
+	firstPass := true
+	r := NewReconciler(ModeEnforce)
+	r.SetResultObserver(func(record ResultRecord) {
+		// Results are observed while the pass runs, not when it ends
+		if _, err := os.Stat(marker); firstPass && record.ResourceType == "file" && err == nil {
+			t.Error("file result observed only after the post-hook ran")
+		}
+		observed = append(observed, record)
+	})
+
+	results, err := r.ReconcileAll(context.Background(), state)
+	if err != nil {
+		t.Fatalf("ReconcileAll() error = %v", err)
+	}
+	if len(observed) != len(results) {
+		t.Fatalf("observed %d results, want %d", len(observed), len(results))
+	}
+	for i, result := range results {
+		if observed[i].ResourceType != result.ResourceType || observed[i].ResourceName != result.ResourceName {
+			t.Errorf("observed[%d] = %s/%s, want %s/%s", i, observed[i].ResourceType, observed[i].ResourceName, result.ResourceType, result.ResourceName)
+		}
+	}
+
+	// Event-driven passes are observed too
+	observed, firstPass = nil, false
+	if _, err := r.ReconcileEvent(context.Background(), "file_modified", path, state); err != nil {
+		t.Fatalf("ReconcileEvent() error = %v", err)
+	}
+	if len(observed) != 1 || observed[0].ResourceName != path {
+		t.Errorf("observed %+v from the event, want the file", observed)
+	}
+}
@@ -0,0 +1,144 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// RetryPolicy controls how enforce-mode apply operations are retried after
+// transient failures such as a held apt lock or a busy systemd
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first (<= 1 disables retries)
+	BaseDelay   time.Duration // Delay before the first retry
+	MaxDelay    time.Duration // Upper bound for the exponential backoff (0 = unbounded)
+}
+
+// DefaultRetryPolicy retries twice with 1s, 2s backoff
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   1 * time.Second,
+	MaxDelay:    30 * time.Second,
+}
+
+// Delay returns the backoff before the given retry (1-based)
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// SetRetryPolicy updates the retry policy used in enforce mode
+func (r *Reconciler) SetRetryPolicy(policy RetryPolicy) {
+	r.retry = policy
+}
+
+// withRetry runs an enforcer's reconcile, retrying failures with exponential
+// backoff. Retries only happen in enforce mode: a dry-run doesn't change
+// anything, so a failure there won't go away by itself. The returned result
+// is that of the last attempt, with the retry count noted in its Action and
+// the time spent across all attempts in its Duration.
+func (r *Reconciler) withRetry(ctx context.Context, reconcile func() (ReconcileResult, error)) (result ReconcileResult, err error) {
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	result, err = reconcile()
+	if err == nil || r.modeFor(ctx, result.ResourceType) != ModeEnforce {
+		return result, err
+	}
+
+	retries := 0
+	for attempt := 2; attempt <= r.retry.MaxAttempts; attempt++ {
+		delay := r.retry.Delay(attempt - 1)
+		logAtf(ctx, slog.LevelWarn, "      ↻ %s/%s failed (%v), retrying in %s (attempt %d/%d)",
+			result.ResourceType, result.ResourceName, err, delay, attempt, r.retry.MaxAttempts)
+
+		select {
+		case <-ctx.Done():
+			return noteRetries(result, retries), err
+		case <-time.After(delay):
+		}
+
+		retries++
+		result, err = reconcile()
+		if err == nil {
+			break
+		}
+	}
+
+	return noteRetries(result, retries), err
+}
+
+// withBatchRetry is withRetry for enforcers that apply a whole batch at once:
+// the batch is rerun while any of its results failed, and every result
+// carries the retry count and the time spent across all attempts.
+func (r *Reconciler) withBatchRetry(ctx context.Context, mode ReconcileMode, reconcile func() []ReconcileResult) (results []ReconcileResult) {
+	start := time.Now()
+	retries := 0
+	defer func() {
+		for i := range results {
+			results[i] = noteRetries(results[i], retries)
+			results[i].Duration = time.Since(start)
+		}
+	}()
+
+	results = reconcile()
+	if mode != ModeEnforce {
+		return results
+	}
+
+	for attempt := 2; attempt <= r.retry.MaxAttempts; attempt++ {
+		failed := firstFailure(results)
+		if failed == nil {
+			break
+		}
+
+		delay := r.retry.Delay(attempt - 1)
+		logAtf(ctx, slog.LevelWarn, "      ↻ %s/%s failed (%v), retrying batch in %s (attempt %d/%d)",
+			failed.ResourceType, failed.ResourceName, failed.Error, delay, attempt, r.retry.MaxAttempts)
+
+		select {
+		case <-ctx.Done():
+			return results
+		case <-time.After(delay):
+		}
+
+		retries++
+		results = reconcile()
+	}
+
+	return results
+}
+
+func firstFailure(results []ReconcileResult) *ReconcileResult {
+	for i := range results {
+		if results[i].Error != nil {
+			return &results[i]
+		}
+	}
+	return nil
+}
+
+func noteRetries(result ReconcileResult, retries int) ReconcileResult {
+	if retries == 0 {
+		return result
+	}
+	note := fmt.Sprintf("%d retries", retries)
+	if retries == 1 {
+		note = "1 retry"
+	}
+	if result.Action == "" {
+		result.Action = note
+	} else {
+		result.Action = fmt.Sprintf("%s (after %s)", result.Action, note)
+	}
+	return result
+}
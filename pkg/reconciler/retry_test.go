@@ -0,0 +1,95 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := p.Delay(i + 1); got != w {
+			t.Errorf("Delay(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	errBusy := errors.New("could not get lock")
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	// failUntil returns a reconcile func failing the first n calls
+	failUntil := func(n int, calls *int) func() (ReconcileResult, error) {
+		return func() (ReconcileResult, error) {
+			*calls++
+			result := ReconcileResult{ResourceType: "package", ResourceName: "nginx"}
+			if *calls <= n {
+				result.Error = errBusy
+				return result, errBusy
+			}
+			result.Action = "install nginx"
+			return result, nil
+		}
+	}
+
+	t.Run("succeeds after retries", func(t *testing.T) {
+		r := NewReconciler(ModeEnforce)
+		r.SetRetryPolicy(policy)
+
+		calls := 0
+		result, err := r.withRetry(context.Background(), failUntil(2, &calls))
+		if err != nil {
+			t.Fatalf("withRetry() error = %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("Expected 3 attempts, got %d", calls)
+		}
+		if result.Action != "install nginx (after 2 retries)" {
+			t.Errorf("Action = %q", result.Action)
+		}
+	})
+
+	t.Run("reports last error", func(t *testing.T) {
+		r := NewReconciler(ModeEnforce)
+		r.SetRetryPolicy(policy)
+
+		calls := 0
+		result, err := r.withRetry(context.Background(), failUntil(10, &calls))
+		if !errors.Is(err, errBusy) || result.Error != errBusy {
+			t.Errorf("Expected last error, got %v", err)
+		}
+		if calls != 3 || !strings.Contains(result.Action, "2 retries") {
+			t.Errorf("Expected 3 attempts noted in action, got %d calls, action %q", calls, result.Action)
+		}
+	})
+
+	t.Run("no retries in dry-run", func(t *testing.T) {
+		r := NewReconciler(ModeDryRun)
+		r.SetRetryPolicy(policy)
+
+		calls := 0
+		r.withRetry(context.Background(), failUntil(10, &calls))
+		if calls != 1 {
+			t.Errorf("Expected a single attempt in dry-run, got %d", calls)
+		}
+	})
+
+	t.Run("stops on context cancellation", func(t *testing.T) {
+		r := NewReconciler(ModeEnforce)
+		r.SetRetryPolicy(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		calls := 0
+		_, err := r.withRetry(ctx, failUntil(10, &calls))
+		if err == nil || calls != 1 {
+			t.Errorf("Expected to stop after first attempt, got %d calls, err %v", calls, err)
+		}
+	})
+}
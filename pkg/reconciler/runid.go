@@ -0,0 +1,91 @@
+package reconciler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+
+	"github.com/power-edge/power-edge/pkg/config"
+	"github.com/power-edge/power-edge/pkg/logging"
+)
+
+type runIDKey struct{}
+
+type quietKey struct{}
+
+// WithRunID returns a context carrying the correlation ID of a reconcile cycle
+func WithRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+// RunIDFromContext returns the correlation ID of the current reconcile cycle, if any
+func RunIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(runIDKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// NewRunID generates a per-cycle correlation ID. When the state carries a
+// server-provided revision it is used as a prefix so every cycle applying
+// that revision can be found with a single grep.
+func NewRunID(state *config.State) string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand never fails on supported platforms; fall back to a fixed marker
+		return "run-unknown"
+	}
+	id := hex.EncodeToString(buf)
+
+	if rev := state.Revision(); rev != "" {
+		return rev + "-" + id
+	}
+	return id
+}
+
+// ensureRunID returns ctx unchanged if it already carries a run ID, otherwise
+// attaches a freshly generated one
+func ensureRunID(ctx context.Context, state *config.State) context.Context {
+	if RunIDFromContext(ctx) != "" {
+		return ctx
+	}
+	return WithRunID(ctx, NewRunID(state))
+}
+
+// withQuiet marks ctx so enforcers don't log, for read-only passes such as
+// drift reports that may be requested frequently
+func withQuiet(ctx context.Context) context.Context {
+	return context.WithValue(ctx, quietKey{}, true)
+}
+
+// logf logs a progress line at info level
+func logf(ctx context.Context, format string, args ...interface{}) {
+	logAttrs(ctx, slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+
+// logAtf is logf at the given level
+func logAtf(ctx context.Context, level slog.Level, format string, args ...interface{}) {
+	logAttrs(ctx, level, fmt.Sprintf(format, args...))
+}
+
+// logResult logs a per-resource line, with the resource and action as
+// structured fields
+func logResult(ctx context.Context, level slog.Level, result ReconcileResult, format string, args ...interface{}) {
+	logAttrs(ctx, level, fmt.Sprintf(format, args...),
+		slog.String("resource_type", result.ResourceType),
+		slog.String("resource_name", result.ResourceName),
+		slog.String("action", result.Action))
+}
+
+// logAttrs logs with the run ID of ctx so a whole cycle can be traced end-to-end
+func logAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if quiet, _ := ctx.Value(quietKey{}).(bool); quiet {
+		return
+	}
+	if id := RunIDFromContext(ctx); id != "" {
+		attrs = append(attrs, slog.String(logging.RunIDKey, id))
+	}
+	slog.Default().LogAttrs(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,52 @@
+package reconciler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestNewRunID(t *testing.T) {
+	state := &config.State{}
+
+	a, b := NewRunID(state), NewRunID(state)
+	if a == "" || a == b {
+		t.Errorf("Expected unique non-empty run IDs, got %q and %q", a, b)
+	}
+
+	state.SetRevision("abc123")
+	if id := NewRunID(state); !strings.HasPrefix(id, "abc123-") {
+		t.Errorf("Expected run ID prefixed with revision, got %q", id)
+	}
+}
+
+func TestRunIDPropagation(t *testing.T) {
+	r := NewReconciler(ModeDryRun)
+	tmpDir := t.TempDir()
+
+	state := &config.State{
+		Files: []config.FileConfig{
+			{
+				Path:    config.UnixPath(tmpDir + "/test.txt"),
+				Content: "test content",
+			},
+		},
+	}
+
+	ctx := WithRunID(context.Background(), "run-42")
+	results, err := r.ReconcileAll(ctx, state)
+	if err != nil {
+		t.Fatalf("ReconcileAll() error = %v", err)
+	}
+
+	if len(results) == 0 {
+		t.Fatal("Expected results")
+	}
+	for _, result := range results {
+		if result.RunID != "run-42" {
+			t.Errorf("Result %s/%s has RunID %q, want %q", result.ResourceType, result.ResourceName, result.RunID, "run-42")
+		}
+	}
+}
@@ -2,7 +2,8 @@ package reconciler
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
 	"strings"
 
 	"github.com/power-edge/power-edge/pkg/apply"
@@ -28,11 +29,20 @@ func (e *ServiceEnforcer) Reconcile(ctx context.Context, svc config.ServiceConfi
 		ResourceType: "service",
 		ResourceName: svc.Name,
 		DryRun:       mode == ModeDryRun,
+		RunID:        RunIDFromContext(ctx),
 	}
 
 	// Use the applier to check and potentially apply state
 	dryRun := (mode != ModeEnforce)
-	applyResult := e.applier.Apply(svc, dryRun)
+	applyResult := e.applier.Apply(ctx, svc, dryRun)
+
+	// Without systemd (e.g. in a container) services can't be managed at all
+	if errors.Is(applyResult.Error, apply.ErrSystemdUnavailable) {
+		result.WasCompliant = true
+		result.Action = "skipped: systemd not available"
+		logResult(ctx, slog.LevelInfo, result, "      ⏭️  %s: skipped, systemd not available", svc.Name)
+		return result, nil
+	}
 
 	if applyResult.Error != nil {
 		result.Error = applyResult.Error
@@ -43,24 +53,67 @@ func (e *ServiceEnforcer) Reconcile(ctx context.Context, svc config.ServiceConfi
 	if !applyResult.Changed {
 		result.WasCompliant = true
 		result.Action = "compliant"
-		log.Printf("      ✓ %s: already compliant", svc.Name)
+		logResult(ctx, slog.LevelDebug, result, "      ✓ %s: already compliant", svc.Name)
 		return result, nil
 	}
 
 	// Changes needed/applied
 	result.WasCompliant = false
 	result.Action = strings.Join(applyResult.Actions, " + ")
+	result.Actions = applyResult.Actions
+
+	// Removal actions are full commands, the rest systemctl verbs
+	command := "systemctl " + result.Action
+	if svc.State == config.ServiceStateAbsent {
+		command = result.Action
+	}
 
 	if mode == ModeDryRun {
-		log.Printf("      🔍 [DRY-RUN] %s: would execute: systemctl %s", svc.Name, result.Action)
+		logResult(ctx, slog.LevelInfo, result, "      🔍 [DRY-RUN] %s: would execute: %s", svc.Name, command)
 	} else if mode == ModeEnforce {
-		log.Printf("      ✓ %s: executed 'systemctl %s'", svc.Name, result.Action)
+		logResult(ctx, slog.LevelInfo, result, "      ✓ %s: executed '%s'", svc.Name, command)
 	}
 
 	return result, nil
 }
 
 // Check returns the current state without applying changes
-func (e *ServiceEnforcer) Check(name string) (isActive, isEnabled bool, err error) {
-	return e.applier.Check(name)
+func (e *ServiceEnforcer) Check(ctx context.Context, name string) (isActive, isEnabled bool, err error) {
+	return e.applier.Check(ctx, name)
+}
+
+// Preflight checks, without changing anything, that applying can succeed
+func (e *ServiceEnforcer) Preflight(ctx context.Context, name string) error {
+	return e.applier.Preflight(ctx, name)
+}
+
+// Restart restarts a service notified by changes to other resources
+func (e *ServiceEnforcer) Restart(ctx context.Context, name string, notifiedBy []string, mode ReconcileMode) ReconcileResult {
+	result := ReconcileResult{
+		ResourceType: "service",
+		ResourceName: name,
+		Action:       "restart (notified by " + strings.Join(notifiedBy, ", ") + ")",
+		DryRun:       mode == ModeDryRun,
+		RunID:        RunIDFromContext(ctx),
+	}
+
+	applyResult := e.applier.Restart(ctx, name, mode != ModeEnforce)
+	if errors.Is(applyResult.Error, apply.ErrSystemdUnavailable) {
+		result.WasCompliant = true
+		result.Action = "skipped: systemd not available"
+		logResult(ctx, slog.LevelInfo, result, "      ⏭️  %s: restart skipped, systemd not available", name)
+		return result
+	}
+	result.Actions = applyResult.Actions
+	if applyResult.Error != nil {
+		result.Error = applyResult.Error
+		return result
+	}
+
+	if mode == ModeDryRun {
+		logResult(ctx, slog.LevelInfo, result, "      🔍 [DRY-RUN] %s: would restart, notified by %s", name, strings.Join(notifiedBy, ", "))
+	} else {
+		logResult(ctx, slog.LevelInfo, result, "      ✓ %s: restarted, notified by %s", name, strings.Join(notifiedBy, ", "))
+	}
+	return result
 }
@@ -93,7 +93,7 @@ func TestServiceEnforcer_Check(t *testing.T) {
 	e := NewServiceEnforcer()
 
 	// Test with a service that likely doesn't exist
-	_, _, err := e.Check("nonexistent-test-service-12345")
+	_, _, err := e.Check(context.Background(), "nonexistent-test-service-12345")
 
 	// We expect either no error (service not found) or a specific error
 	// This is mainly to ensure the Check function doesn't panic
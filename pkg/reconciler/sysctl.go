@@ -3,9 +3,11 @@ package reconciler
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 
 	"github.com/power-edge/power-edge/pkg/apply"
+	"github.com/power-edge/power-edge/pkg/config"
 )
 
 // SysctlEnforcer orchestrates WHEN to apply sysctl parameters
@@ -21,52 +23,119 @@ func NewSysctlEnforcer() *SysctlEnforcer {
 	}
 }
 
+// SetPolicy sets how keys missing from the running kernel are handled
+func (e *SysctlEnforcer) SetPolicy(policy config.SysctlPolicy) {
+	e.applier.SetPolicy(policy)
+}
+
 // Reconcile detects drift and triggers applier to fix it
 func (e *SysctlEnforcer) Reconcile(ctx context.Context, key, expectedValue string, mode ReconcileMode) (ReconcileResult, error) {
 	result := ReconcileResult{
 		ResourceType: "sysctl",
 		ResourceName: key,
 		DryRun:       mode == ModeDryRun,
+		RunID:        RunIDFromContext(ctx),
 	}
 
-	// Get current value for logging
-	actualValue, err := e.applier.Get(key)
+	// Get current value for logging; a key missing from this kernel is left
+	// to the applier's policy
+	available, err := e.applier.Available(key)
 	if err != nil {
-		result.Error = fmt.Errorf("failed to get current value: %w", err)
+		result.Error = fmt.Errorf("failed to check key: %w", err)
 		return result, result.Error
 	}
+	actualValue := "unavailable"
+	if available {
+		if actualValue, err = e.applier.Get(ctx, key); err != nil {
+			result.Error = fmt.Errorf("failed to get current value: %w", err)
+			return result, result.Error
+		}
+	}
 
 	// Use the applier to check and potentially apply state
 	dryRun := (mode != ModeEnforce)
-	applyResult := e.applier.Apply(key, expectedValue, dryRun)
+	applyResult := e.applier.Apply(ctx, key, expectedValue, dryRun)
 
 	if applyResult.Error != nil {
 		result.Error = applyResult.Error
 		return result, applyResult.Error
 	}
 
+	// Not present on this kernel and policy says to skip it
+	if applyResult.Skipped {
+		result.WasCompliant = true
+		result.Action = "skipped: key not available on this kernel"
+		logResult(ctx, slog.LevelInfo, result, "      ⏭️  %s: skipped, key not available on this kernel", key)
+		return result, nil
+	}
+
 	// Already compliant
 	if !applyResult.Changed {
 		result.WasCompliant = true
 		result.Action = "compliant"
-		log.Printf("      ✓ %s: already compliant (%s)", key, actualValue)
+		logResult(ctx, slog.LevelDebug, result, "      ✓ %s: already compliant (%s)", key, actualValue)
 		return result, nil
 	}
 
 	// Changes needed/applied
 	result.WasCompliant = false
-	result.Action = fmt.Sprintf("sysctl -w %s=%s", key, expectedValue)
+	result.Action = strings.Join(applyResult.Actions, "; ")
+	result.Actions = applyResult.Actions
 
 	if mode == ModeDryRun {
-		log.Printf("      🔍 [DRY-RUN] %s: would set to %s (current: %s)", key, expectedValue, actualValue)
+		logResult(ctx, slog.LevelInfo, result, "      🔍 [DRY-RUN] %s: would set to %s (current: %s)", key, expectedValue, actualValue)
 	} else if mode == ModeEnforce {
-		log.Printf("      ✓ %s: set to %s (was: %s)", key, expectedValue, actualValue)
+		logResult(ctx, slog.LevelInfo, result, "      ✓ %s: set to %s (was: %s)", key, expectedValue, actualValue)
 	}
 
 	return result, nil
 }
 
+// ReconcileAll detects drift across all params and fixes it in one batch,
+// persisting every managed key to the sysctl.d drop-in. Results are returned
+// per key, sorted by key.
+func (e *SysctlEnforcer) ReconcileAll(ctx context.Context, params map[string]string, mode ReconcileMode) []ReconcileResult {
+	dryRun := (mode != ModeEnforce)
+	applyResults := e.applier.ApplyAll(ctx, params, dryRun)
+
+	keys := sortedKeys(params)
+	results := make([]ReconcileResult, 0, len(keys))
+	for _, key := range keys {
+		applyResult := applyResults[key]
+		result := ReconcileResult{
+			ResourceType: "sysctl",
+			ResourceName: key,
+			DryRun:       mode == ModeDryRun,
+			RunID:        RunIDFromContext(ctx),
+			Error:        applyResult.Error,
+		}
+
+		switch {
+		case applyResult.Error != nil:
+		case applyResult.Skipped:
+			result.WasCompliant = true
+			result.Action = "skipped: key not available on this kernel"
+			logResult(ctx, slog.LevelInfo, result, "      ⏭️  %s: skipped, key not available on this kernel", key)
+		case !applyResult.Changed:
+			result.WasCompliant = true
+			result.Action = "compliant"
+			logResult(ctx, slog.LevelDebug, result, "      ✓ %s: already compliant (%s)", key, params[key])
+		default:
+			result.Action = strings.Join(applyResult.Actions, "; ")
+			result.Actions = applyResult.Actions
+			if mode == ModeDryRun {
+				logResult(ctx, slog.LevelInfo, result, "      🔍 [DRY-RUN] %s: would set and persist %s", key, params[key])
+			} else if mode == ModeEnforce {
+				logResult(ctx, slog.LevelInfo, result, "      ✓ %s: set and persisted %s", key, params[key])
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
 // Get returns the current value of a sysctl parameter
-func (e *SysctlEnforcer) Get(key string) (string, error) {
-	return e.applier.Get(key)
+func (e *SysctlEnforcer) Get(ctx context.Context, key string) (string, error) {
+	return e.applier.Get(ctx, key)
 }
@@ -79,7 +79,7 @@ func TestSysctlEnforcer_Get(t *testing.T) {
 	e := NewSysctlEnforcer()
 
 	// Test getting a common sysctl value (should exist on most systems)
-	value, err := e.Get("kernel.hostname")
+	value, err := e.Get(context.Background(), "kernel.hostname")
 	if err != nil {
 		t.Skipf("Skipping test, sysctl not available: %v", err)
 	}
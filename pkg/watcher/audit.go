@@ -0,0 +1,274 @@
+package watcher
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// maxPendingExecs bounds the SYSCALL records kept while waiting for their
+// EXECVE record, so a stream of unrelated syscalls can't grow it forever
+const maxPendingExecs = 1024
+
+var (
+	auditRecordHeader = regexp.MustCompile(`^type=(\S+) msg=audit\([0-9.]+:([0-9]+)\):\s*(.*)$`)
+	auditArgChunk     = regexp.MustCompile(`^a([0-9]+)\[([0-9]+)\]$`)
+)
+
+// auditExec is a command execution assembled from the SYSCALL and EXECVE
+// records of one audit event
+type auditExec struct {
+	Serial string
+	Exe    string
+	Argv   []string
+	UID    string
+	AUID   string
+}
+
+// CommandLine is the argv joined with spaces, quoting arguments that contain
+// whitespace
+func (e auditExec) CommandLine() string {
+	parts := make([]string, len(e.Argv))
+	for i, arg := range e.Argv {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'") {
+			arg = strconv.Quote(arg)
+		}
+		parts[i] = arg
+	}
+	return strings.Join(parts, " ")
+}
+
+// eventData is the Event.Data describing the execution
+func (e auditExec) eventData() map[string]string {
+	argv, _ := json.Marshal(e.Argv)
+	return map[string]string{
+		"exe":          e.Exe,
+		"argv":         string(argv),
+		"command_line": e.CommandLine(),
+		"uid":          e.UID,
+		"auid":         e.AUID,
+		"audit_serial": e.Serial,
+	}
+}
+
+// auditAssembler pairs the SYSCALL and EXECVE records of an audit event. The
+// kernel logs SYSCALL (with exe= and the uids) first and EXECVE (with the
+// argv) after it, both under the event's serial number.
+type auditAssembler struct {
+	pending map[string]auditExec
+}
+
+func newAuditAssembler() *auditAssembler {
+	return &auditAssembler{pending: make(map[string]auditExec)}
+}
+
+// feedLine parses a raw audit.log line, returning the execution it completes
+func (a *auditAssembler) feedLine(line string) (auditExec, bool) {
+	m := auditRecordHeader.FindStringSubmatch(line)
+	if m == nil {
+		return auditExec{}, false
+	}
+	return a.feed(m[1], m[2], m[3])
+}
+
+// feed adds one record, returning the execution it completes
+func (a *auditAssembler) feed(recordType, serial, body string) (auditExec, bool) {
+	switch recordType {
+	case "SYSCALL":
+		fields := parseAuditFields(body)
+		if _, ok := fields["exe"]; !ok {
+			return auditExec{}, false
+		}
+		if len(a.pending) >= maxPendingExecs {
+			a.pending = make(map[string]auditExec)
+		}
+		a.pending[serial] = auditExec{
+			Serial: serial,
+			Exe:    decodeAuditValue(fields["exe"]),
+			UID:    fields["uid"],
+			AUID:   fields["auid"],
+		}
+
+	case "EXECVE":
+		exec := a.pending[serial]
+		delete(a.pending, serial)
+		exec.Serial = serial
+		exec.Argv = parseExecveArgs(parseAuditFields(body))
+		return exec, true
+	}
+	return auditExec{}, false
+}
+
+// parseAuditFields splits a record body into its key=value fields. Quoted
+// values keep their quotes so decodeAuditValue can tell them from hex.
+func parseAuditFields(body string) map[string]string {
+	// Enriched logs append interpreted fields after a GS separator
+	body, _, _ = strings.Cut(body, "\x1d")
+
+	fields := make(map[string]string)
+	for len(body) > 0 {
+		body = strings.TrimLeft(body, " ")
+		key, rest, ok := strings.Cut(body, "=")
+		if !ok || key == "" {
+			break
+		}
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				value, rest = rest, ""
+			} else {
+				value, rest = rest[:end+2], rest[end+2:]
+			}
+		} else {
+			value, rest, _ = strings.Cut(rest, " ")
+		}
+		fields[key] = value
+		body = rest
+	}
+	return fields
+}
+
+// decodeAuditValue unquotes a field value. auditd logs values containing
+// spaces or control characters as unquoted hex instead.
+func decodeAuditValue(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return value[1 : len(value)-1]
+	}
+	if value == "(null)" {
+		return ""
+	}
+	if decoded, err := hex.DecodeString(value); err == nil && len(value) > 0 {
+		return string(decoded)
+	}
+	return value
+}
+
+// parseExecveArgs rebuilds argv from an EXECVE record's a0, a1, ... fields.
+// Arguments too long for one record are split into a<N>[<i>] chunks.
+func parseExecveArgs(fields map[string]string) []string {
+	argc, err := strconv.Atoi(fields["argc"])
+	if err != nil || argc < 0 {
+		return nil
+	}
+	// A corrupt argc shouldn't allocate more than the record holds
+	argc = min(argc, len(fields))
+
+	argv := make([]string, argc)
+	chunks := make(map[int][]string)
+	for key, value := range fields {
+		if m := auditArgChunk.FindStringSubmatch(key); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			i, _ := strconv.Atoi(m[2])
+			if n < argc {
+				for len(chunks[n]) <= i {
+					chunks[n] = append(chunks[n], "")
+				}
+				chunks[n][i] = value
+			}
+		}
+	}
+
+	for i := range argv {
+		if parts, ok := chunks[i]; ok {
+			var b strings.Builder
+			for _, part := range parts {
+				b.WriteString(decodeAuditValue(part))
+			}
+			argv[i] = b.String()
+			continue
+		}
+		argv[i] = decodeAuditValue(fields[fmt.Sprintf("a%d", i)])
+	}
+	return argv
+}
+
+// matchCommand reports whether exec runs cmd. The first word of cmd names
+// the program: a bare name matches the basename of the executable or of
+// argv[0], a path matches exactly. Any further words must be the leading
+// arguments, so "systemctl stop nginx" matches `systemctl stop nginx` but
+// not `systemctl status` or `grep systemctl`.
+func matchCommand(cmd config.Command, exec auditExec) bool {
+	words := strings.Fields(string(cmd))
+	if len(words) == 0 || len(exec.Argv) == 0 && exec.Exe == "" {
+		return false
+	}
+
+	program := words[0]
+	var candidates []string
+	if exec.Exe != "" {
+		candidates = append(candidates, exec.Exe)
+	}
+	if len(exec.Argv) > 0 {
+		candidates = append(candidates, exec.Argv[0])
+	}
+
+	matched := false
+	for _, c := range candidates {
+		if c == program || !strings.Contains(program, "/") && filepath.Base(c) == program {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+
+	args := words[1:]
+	if len(exec.Argv) < len(args)+1 {
+		return false
+	}
+	for i, arg := range args {
+		if exec.Argv[i+1] != arg {
+			return false
+		}
+	}
+	return true
+}
+
+// auditTypeNames maps the numeric record types journald reports for older
+// systemd versions that don't set _AUDIT_TYPE_NAME
+var auditTypeNames = map[string]string{
+	"1300": "SYSCALL",
+	"1309": "EXECVE",
+}
+
+// feedJournal adds an audit record read from journald's audit transport,
+// whose MESSAGE is the record body prefixed by its type name
+func (a *auditAssembler) feedJournal(fields map[string]string) (auditExec, bool) {
+	message := fields["MESSAGE"]
+	if auditRecordHeader.MatchString(message) {
+		return a.feedLine(message)
+	}
+
+	recordType := fields["_AUDIT_TYPE_NAME"]
+	if recordType == "" {
+		recordType = auditTypeNames[fields["_AUDIT_TYPE"]]
+	}
+	body := strings.TrimPrefix(message, recordType+" ")
+	return a.feed(recordType, fields["_AUDIT_ID"], body)
+}
+
+// emitExec emits a command event for each configured command exec runs
+func (w *EventWatcher) emitExec(source string, exec auditExec, at time.Time) {
+	for _, cmd := range w.config.Watchers.Auditd.Commands {
+		if !matchCommand(cmd, exec) {
+			continue
+		}
+		w.emit(Event{
+			Type:      EventCommandExecuted,
+			Source:    source,
+			Command:   string(cmd),
+			Timestamp: at,
+			Data:      exec.eventData(),
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package watcher
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestAuditAssembler(t *testing.T) {
+	lines := []string{
+		`type=SYSCALL msg=audit(1700000000.123:4242): arch=c000003e syscall=59 success=yes exit=0 ppid=1 pid=99 auid=1000 uid=0 gid=0 comm="systemctl" exe="/usr/bin/systemctl" key="power-edge"` + "\x1d" + `AUID="alice" UID="root"`,
+		`type=EXECVE msg=audit(1700000000.123:4242): argc=3 a0="systemctl" a1="stop" a2=6E67696E78`,
+		`type=CWD msg=audit(1700000000.123:4242): cwd="/root"`,
+	}
+
+	a := newAuditAssembler()
+	var got []auditExec
+	for _, line := range lines {
+		if exec, ok := a.feedLine(line); ok {
+			got = append(got, exec)
+		}
+	}
+
+	want := []auditExec{{
+		Serial: "4242",
+		Exe:    "/usr/bin/systemctl",
+		Argv:   []string{"systemctl", "stop", "nginx"},
+		UID:    "0",
+		AUID:   "1000",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("assembled %+v, want %+v", got, want)
+	}
+	if len(a.pending) != 0 {
+		t.Errorf("%d records left pending", len(a.pending))
+	}
+}
+
+func TestAuditAssembler_Journal(t *testing.T) {
+	a := newAuditAssembler()
+	a.feedJournal(map[string]string{
+		"_AUDIT_TYPE": "1300",
+		"_AUDIT_ID":   "7",
+		"MESSAGE":     `SYSCALL arch=c000003e syscall=59 success=yes uid=0 auid=1000 exe="/usr/sbin/sysctl"`,
+	})
+	exec, ok := a.feedJournal(map[string]string{
+		"_AUDIT_TYPE_NAME": "EXECVE",
+		"_AUDIT_ID":        "7",
+		"MESSAGE":          `EXECVE argc=3 a0="sysctl" a1="-w" a2="vm.swappiness=10"`,
+	})
+	if !ok {
+		t.Fatal("EXECVE record didn't complete the execution")
+	}
+	if exec.Exe != "/usr/sbin/sysctl" || exec.CommandLine() != "sysctl -w vm.swappiness=10" {
+		t.Errorf("exec = %+v", exec)
+	}
+}
+
+func TestParseExecveArgs(t *testing.T) {
+	fields := parseAuditFields(`argc=3 a0="sh" a1="-c" a2_len=22 a2[0]=6563686F2022612062 a2[1]=2220 a2[2]=3E202F746D702F78`)
+	got := parseExecveArgs(fields)
+	want := []string{"sh", "-c", `echo "a b" > /tmp/x`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseExecveArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestMatchCommand(t *testing.T) {
+	stop := auditExec{Exe: "/usr/bin/systemctl", Argv: []string{"systemctl", "stop", "nginx"}}
+	grep := auditExec{Exe: "/usr/bin/grep", Argv: []string{"grep", "systemctl", "/var/log/syslog"}}
+
+	tests := []struct {
+		cmd  config.Command
+		exec auditExec
+		want bool
+	}{
+		{"systemctl", stop, true},
+		{"/usr/bin/systemctl", stop, true},
+		{"/bin/systemctl", stop, false},
+		{"systemctl stop nginx", stop, true},
+		{"systemctl stop", stop, true},
+		{"systemctl restart nginx", stop, false},
+		{"systemctl stop nginx extra", stop, false},
+		// The name appearing as an argument is not a match
+		{"systemctl", grep, false},
+	}
+
+	for _, tt := range tests {
+		if got := matchCommand(tt.cmd, tt.exec); got != tt.want {
+			t.Errorf("matchCommand(%q, %q) = %v, want %v", tt.cmd, tt.exec.Argv, got, tt.want)
+		}
+	}
+}
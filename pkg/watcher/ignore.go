@@ -0,0 +1,50 @@
+package watcher
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// compileGlobs converts inotify ignore patterns to regular expressions.
+// `*` and `?` stay within a path segment while `**` spans any number of
+// them; a pattern without a slash is matched against the file name only, so
+// "*.swp" ignores swap files in every watched directory.
+func compileGlobs(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		if !strings.Contains(p, "/") {
+			p = "**/" + p
+		}
+		compiled = append(compiled, regexp.MustCompile("^"+globToRegexp(p)+"$"))
+	}
+	return compiled
+}
+
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			// Zero or more whole directories
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return b.String()
+}
+
+// ignoredPath reports whether events for path are dropped by the inotify
+// ignore list
+func (w *EventWatcher) ignoredPath(path string) bool {
+	return matchesAny(w.inotifyIgnore, filepath.Clean(path))
+}
@@ -0,0 +1,30 @@
+package watcher
+
+import "testing"
+
+func TestCompileGlobs(t *testing.T) {
+	patterns := compileGlobs([]string{"*.swp", "*~", "/etc/ld.so.cache", "/etc/**/lock", "/var/*.log"})
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/etc/.sshd_config.swp", true},
+		{"/etc/ssh/.sshd_config.swp", true},
+		{"/etc/hosts~", true},
+		{"/etc/ld.so.cache", true},
+		{"/etc/ld.so.cache.bak", false},
+		{"/etc/lock", true},
+		{"/etc/apt/cache/lock", true},
+		{"/var/app.log", true},
+		{"/var/app/app.log", false},
+		{"/etc/hosts", false},
+		{"/etc/swp", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesAny(patterns, tt.path); got != tt.want {
+			t.Errorf("ignored(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
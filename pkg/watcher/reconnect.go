@@ -0,0 +1,40 @@
+package watcher
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/power-edge/power-edge/pkg/reconciler"
+)
+
+// reconnectBackoff spaces out attempts to reopen a watcher's source
+var reconnectBackoff = reconciler.RetryPolicy{
+	BaseDelay: time.Second,
+	MaxDelay:  time.Minute,
+}
+
+// reconnect calls open, backing off between attempts, until it succeeds or
+// the watcher is stopped. It reports whether the source was reopened; the
+// watcher counts as not running in the meantime.
+func (w *EventWatcher) reconnect(name string, open func() error) bool {
+	w.setStopped(name, "reconnecting after repeated errors")
+	for attempt := 1; ; attempt++ {
+		timer := time.NewTimer(reconnectBackoff.Delay(attempt))
+		select {
+		case <-w.ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+
+		err := open()
+		if err == nil {
+			logf(slog.LevelInfo, name, "   [%s] Reconnected", name)
+			w.recordReconnect(name)
+			w.setRunning(name)
+			return true
+		}
+		logf(slog.LevelWarn, name, "   [%s] Failed to reconnect (attempt %d): %v", name, attempt, err)
+		w.recordError(name, err)
+	}
+}
@@ -0,0 +1,50 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/power-edge/power-edge/pkg/config"
+	"github.com/power-edge/power-edge/pkg/reconciler"
+)
+
+func TestEventWatcher_Reconnect(t *testing.T) {
+	saved := reconnectBackoff
+	reconnectBackoff = reconciler.RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	defer func() { reconnectBackoff = saved }()
+
+	cfg := &config.WatcherConfig{}
+	cfg.Watchers.Enabled = true
+	cfg.Watchers.Journald.Enabled = true
+	w := NewEventWatcher(cfg, nil, &config.State{})
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	defer w.cancel()
+
+	attempts := 0
+	ok := w.reconnect("journald", func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("journal unavailable")
+		}
+		return nil
+	})
+	if !ok || attempts != 3 {
+		t.Fatalf("reconnect() = %v after %d attempts, want true after 3", ok, attempts)
+	}
+
+	stats := w.Stats()["journald"]
+	if !stats.Running || stats.Reconnects != 1 || stats.LastError != "journal unavailable" {
+		t.Errorf("journald = %+v, want running with 1 reconnect and the last error", stats)
+	}
+
+	// Stopping the watcher ends the attempts
+	w.cancel()
+	if w.reconnect("journald", func() error { return errors.New("journal unavailable") }) {
+		t.Error("reconnect() = true after the watcher stopped")
+	}
+	if stats := w.Stats()["journald"]; stats.Running || stats.Reconnects != 1 {
+		t.Errorf("journald = %+v, want not running, still 1 reconnect", stats)
+	}
+}
@@ -0,0 +1,66 @@
+package watcher
+
+import (
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// resourceMapping sends file events for paths matching a glob to specific
+// managed resources instead of a full reconcile
+type resourceMapping struct {
+	path      *regexp.Regexp
+	resources []string // file:PATH and service:NAME references
+}
+
+// compileResourceMappings compiles the inotify path→resource mappings, using
+// the same glob syntax as the ignore list
+func compileResourceMappings(mappings []config.InotifyResource) []resourceMapping {
+	compiled := make([]resourceMapping, 0, len(mappings))
+	for _, m := range mappings {
+		compiled = append(compiled, resourceMapping{
+			path:      compileGlobs([]string{m.Path})[0],
+			resources: m.Resources,
+		})
+	}
+	return compiled
+}
+
+// mappedResources returns the resources mapped to path by every matching
+// mapping, in config order without duplicates, or nil if none match
+func (w *EventWatcher) mappedResources(path string) []string {
+	path = filepath.Clean(path)
+
+	var resources []string
+	for _, m := range w.inotifyResources {
+		if !m.path.MatchString(path) {
+			continue
+		}
+		for _, ref := range m.resources {
+			if !slices.Contains(resources, ref) {
+				resources = append(resources, ref)
+			}
+		}
+	}
+	return resources
+}
+
+// mapResources records the resources a file event is mapped to in its
+// "resources" data, comma-separated, so the reconciler and event stream
+// see what it targets
+func (w *EventWatcher) mapResources(event *Event) {
+	if event.Type != EventFileModified {
+		return
+	}
+	resources := w.mappedResources(event.Path)
+	if len(resources) == 0 {
+		return
+	}
+	if event.Data == nil {
+		event.Data = make(map[string]string)
+	}
+	event.Data["resources"] = strings.Join(resources, ",")
+}
@@ -0,0 +1,56 @@
+package watcher
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestMappedResources(t *testing.T) {
+	w := &EventWatcher{inotifyResources: compileResourceMappings([]config.InotifyResource{
+		{Path: "/etc/nginx/nginx.conf", Resources: []string{"file:/etc/nginx/nginx.conf", "service:nginx"}},
+		{Path: "/etc/nginx/**", Resources: []string{"service:nginx"}},
+		{Path: "*.pem", Resources: []string{"service:haproxy"}},
+	})}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/etc/nginx/nginx.conf", []string{"file:/etc/nginx/nginx.conf", "service:nginx"}},
+		{"/etc/nginx/conf.d/site.conf", []string{"service:nginx"}},
+		{"/etc/nginx/certs/site.pem", []string{"service:nginx", "service:haproxy"}},
+		{"/etc/nginx//nginx.conf", []string{"file:/etc/nginx/nginx.conf", "service:nginx"}},
+		{"/etc/hosts", nil},
+	}
+
+	for _, tt := range tests {
+		if got := w.mappedResources(tt.path); !slices.Equal(got, tt.want) {
+			t.Errorf("mappedResources(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMapResources(t *testing.T) {
+	w := &EventWatcher{inotifyResources: compileResourceMappings([]config.InotifyResource{
+		{Path: "/etc/nginx/nginx.conf", Resources: []string{"file:/etc/nginx/nginx.conf", "service:nginx"}},
+	})}
+
+	event := Event{Type: EventFileModified, Path: "/etc/nginx/nginx.conf"}
+	w.mapResources(&event)
+	if got := event.Data["resources"]; got != "file:/etc/nginx/nginx.conf,service:nginx" {
+		t.Errorf("resources = %q, want both mapped resources", got)
+	}
+
+	// Unmapped paths and other events are left alone
+	for _, event := range []Event{
+		{Type: EventFileModified, Path: "/etc/hosts"},
+		{Type: EventUnitStateChange, Path: "/etc/nginx/nginx.conf", Unit: "nginx.service"},
+	} {
+		w.mapResources(&event)
+		if event.Data != nil {
+			t.Errorf("mapResources(%+v) set data %v, want none", event, event.Data)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// watcherNames are the watchers Stats reports on, enabled or not
+var watcherNames = []string{"inotify", "journald", "auditd", "dbus"}
+
+// WatcherStats is the health and activity of one watcher
+type WatcherStats struct {
+	Enabled    bool       `json:"enabled"`
+	Running    bool       `json:"running"`
+	Events     uint64     `json:"events"`     // Events emitted, including dropped ones
+	Dropped    uint64     `json:"dropped"`    // Events dropped because the channel was full
+	Reconnects uint64     `json:"reconnects"` // Times the watcher reopened its source after repeated errors
+	LastEvent  *time.Time `json:"last_event,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+	Reason     string     `json:"reason,omitempty"` // Why the watcher isn't running
+}
+
+// watcherName maps an event source to the watcher that produced it; the
+// journald fallback for command events is part of the auditd watcher
+func watcherName(source string) string {
+	if strings.HasPrefix(source, "auditd") {
+		return "auditd"
+	}
+	return source
+}
+
+// logf logs a line at level, tagged with the watcher it concerns ("" for the
+// event watcher as a whole)
+func logf(level slog.Level, source, format string, args ...interface{}) {
+	var attrs []slog.Attr
+	if source != "" {
+		attrs = append(attrs, slog.String("watcher", watcherName(source)))
+	}
+	slog.Default().LogAttrs(context.Background(), level, fmt.Sprintf(format, args...), attrs...)
+}
+
+// statLocked returns the mutable stats of a watcher; the caller holds failMu
+func (w *EventWatcher) statLocked(name string) *WatcherStats {
+	if w.stats == nil {
+		w.stats = make(map[string]*WatcherStats)
+	}
+	s, ok := w.stats[name]
+	if !ok {
+		s = &WatcherStats{}
+		w.stats[name] = s
+	}
+	return s
+}
+
+// setRunning marks a watcher as started
+func (w *EventWatcher) setRunning(name string) {
+	w.failMu.Lock()
+	defer w.failMu.Unlock()
+	s := w.statLocked(watcherName(name))
+	s.Running = true
+	s.Reason = ""
+}
+
+// setStopped marks a watcher as no longer running, e.g. on shutdown or when
+// it has nothing to watch
+func (w *EventWatcher) setStopped(name, reason string) {
+	w.failMu.Lock()
+	defer w.failMu.Unlock()
+	s := w.statLocked(watcherName(name))
+	s.Running = false
+	s.Reason = reason
+}
+
+// recordError notes an error a watcher survived
+func (w *EventWatcher) recordError(name string, err error) {
+	w.failMu.Lock()
+	defer w.failMu.Unlock()
+	w.statLocked(watcherName(name)).LastError = err.Error()
+}
+
+// recordReconnect counts a watcher reopening its source
+func (w *EventWatcher) recordReconnect(name string) {
+	w.failMu.Lock()
+	defer w.failMu.Unlock()
+	w.statLocked(watcherName(name)).Reconnects++
+}
+
+// countEvent counts an event emitted by source
+func (w *EventWatcher) countEvent(event Event, dropped bool) {
+	w.failMu.Lock()
+	defer w.failMu.Unlock()
+	s := w.statLocked(watcherName(event.Source))
+	s.Events++
+	if dropped {
+		s.Dropped++
+	}
+	at := event.Timestamp
+	s.LastEvent = &at
+}
+
+// Stats returns the health and counters of every watcher, keyed by name
+func (w *EventWatcher) Stats() map[string]WatcherStats {
+	enabled := map[string]bool{
+		"inotify":  w.config.Watchers.Inotify.Enabled,
+		"journald": w.config.Watchers.Journald.Enabled,
+		"auditd":   w.config.Watchers.Auditd.Enabled,
+		"dbus":     w.config.Watchers.Dbus.Enabled,
+	}
+
+	w.failMu.Lock()
+	defer w.failMu.Unlock()
+
+	stats := make(map[string]WatcherStats, len(watcherNames))
+	for _, name := range watcherNames {
+		s := WatcherStats{}
+		if current, ok := w.stats[name]; ok {
+			s = *current
+		}
+		s.Enabled = enabled[name] && w.config.Watchers.Enabled
+		if !s.Enabled {
+			s.Running = false
+			s.Reason = "disabled in config"
+		}
+		stats[name] = s
+	}
+	return stats
+}
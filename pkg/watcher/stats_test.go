@@ -0,0 +1,53 @@
+package watcher
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+func TestEventWatcher_Stats(t *testing.T) {
+	cfg := &config.WatcherConfig{}
+	cfg.Watchers.Enabled = true
+	cfg.Watchers.Inotify.Enabled = true
+	cfg.Watchers.Auditd.Enabled = true
+	cfg.EventHandler.BufferSize = 1
+	w := NewEventWatcher(cfg, nil, &config.State{})
+
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	w.setRunning("inotify")
+	w.emit(Event{Type: EventFileModified, Source: "inotify", Timestamp: at})
+	w.emit(Event{Type: EventFileModified, Source: "inotify", Timestamp: at.Add(time.Second)}) // channel full
+	w.recordError("inotify", errors.New("queue overflow"))
+
+	w.setRunning("auditd-fallback")
+	w.fail("auditd-fallback", errors.New("failed to open journal"))
+
+	stats := w.Stats()
+
+	inotify := stats["inotify"]
+	if !inotify.Enabled || !inotify.Running || inotify.Events != 2 || inotify.Dropped != 1 {
+		t.Errorf("inotify = %+v, want running with 2 events, 1 dropped", inotify)
+	}
+	if inotify.LastEvent == nil || !inotify.LastEvent.Equal(at.Add(time.Second)) {
+		t.Errorf("inotify last event = %v, want %v", inotify.LastEvent, at.Add(time.Second))
+	}
+	if inotify.LastError != "queue overflow" {
+		t.Errorf("inotify last error = %q, want queue overflow", inotify.LastError)
+	}
+
+	// The journald fallback reports as the auditd watcher
+	auditd := stats["auditd"]
+	if auditd.Running || auditd.Reason != "failed to open journal" {
+		t.Errorf("auditd = %+v, want stopped with the failure as reason", auditd)
+	}
+
+	if dbus := stats["dbus"]; dbus.Enabled || dbus.Running || dbus.Reason != "disabled in config" {
+		t.Errorf("dbus = %+v, want disabled", dbus)
+	}
+	if len(stats) != 4 {
+		t.Errorf("Stats() has %d watchers, want 4", len(stats))
+	}
+}
@@ -3,12 +3,18 @@ package watcher
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"github.com/power-edge/power-edge/pkg/config"
+	"github.com/power-edge/power-edge/pkg/reconciler"
 )
 
 // EventType represents the type of event
@@ -23,41 +29,78 @@ const (
 
 // Event represents a system event
 type Event struct {
-	Type      EventType
-	Source    string
-	Path      string
-	Unit      string
-	Command   string
-	Timestamp time.Time
-	Data      map[string]string
+	Type      EventType         `json:"type"`
+	Source    string            `json:"source"`
+	Path      string            `json:"path,omitempty"`
+	Unit      string            `json:"unit,omitempty"`
+	Command   string            `json:"command,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+	Data      map[string]string `json:"data,omitempty"`
 }
 
+// defaultBufferSize is used when the config doesn't set event_handler.buffer_size
+const defaultBufferSize = 100
+
+// defaultJournaldPatterns select unit state changes when the config has none
+var defaultJournaldPatterns = []string{"Started", "Stopped", "Failed", "Reloaded"}
+
+// DroppedEvents counts events discarded because the event channel was full.
+// It is package-level so the count survives watcher restarts; register it
+// with the metrics registry to expose it.
+var DroppedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "power_edge_watcher_events_dropped_total",
+	Help: "Watcher events dropped because the event channel was full",
+}, []string{"source"})
+
 // Reconciler interface for triggering reconciliation
 type Reconciler interface {
-	ReconcileEvent(ctx context.Context, eventType, resourceName string, state *config.State) error
+	ReconcileEvent(ctx context.Context, eventType, resourceName string, state *config.State) ([]reconciler.ReconcileResult, error)
 }
 
 // EventWatcher manages all system event watchers
 type EventWatcher struct {
-	config      *config.WatcherConfig
-	reconciler  Reconciler
-	state       *config.State
-	eventChan   chan Event
-	ctx         context.Context
-	cancel      context.CancelFunc
-	wg          sync.WaitGroup
+	config     *config.WatcherConfig
+	reconciler Reconciler
+	state      *config.State
+	stateMu    sync.RWMutex
+	eventChan  chan Event
+	dropped    atomic.Uint64
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+
+	observer func(Event) // Sees every event before it is handled, see SetObserver
+
+	journaldPatterns []*regexp.Regexp  // Messages that become unit state change events
+	inotifyIgnore    []*regexp.Regexp  // Paths whose file events are dropped
+	inotifyResources []resourceMapping // Paths whose file events reconcile specific resources
+
+	failMu   sync.Mutex
+	failures map[string]error         // Watchers that stopped on their own, by name
+	stats    map[string]*WatcherStats // Per-watcher health and counters, see Stats
 }
 
 // NewEventWatcher creates a new event watcher
 func NewEventWatcher(cfg *config.WatcherConfig, reconciler Reconciler, state *config.State) *EventWatcher {
+	bufferSize := cfg.EventHandler.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+
 	return &EventWatcher{
 		config:     cfg,
 		reconciler: reconciler,
 		state:      state,
-		eventChan:  make(chan Event, 100), // Buffer size from config
+		eventChan:  make(chan Event, bufferSize),
 	}
 }
 
+// SetObserver makes fn see every event before it is handled, e.g. to
+// stream it. It must be called before Start, and fn must not block.
+func (w *EventWatcher) SetObserver(fn func(Event)) {
+	w.observer = fn
+}
+
 // Start initializes and starts all configured watchers
 func (w *EventWatcher) Start(ctx context.Context) error {
 	w.ctx, w.cancel = context.WithCancel(ctx)
@@ -66,34 +109,45 @@ func (w *EventWatcher) Start(ctx context.Context) error {
 		return fmt.Errorf("watchers are disabled in config")
 	}
 
+	if w.config.Watchers.Journald.Enabled {
+		patterns, err := compilePatterns(w.config.Watchers.Journald.Patterns, defaultJournaldPatterns)
+		if err != nil {
+			return fmt.Errorf("journald: %w", err)
+		}
+		w.journaldPatterns = patterns
+	}
+
+	w.inotifyIgnore = compileGlobs(w.config.Watchers.Inotify.Ignore)
+	w.inotifyResources = compileResourceMappings(w.config.Watchers.Inotify.Resources)
+
 	// Start event processor
 	w.wg.Add(1)
 	go w.processEvents()
 
 	// Start inotify watcher
 	if w.config.Watchers.Inotify.Enabled {
-		log.Printf("   Starting inotify watcher for %d paths", len(w.config.Watchers.Inotify.Paths))
+		logf(slog.LevelInfo, "inotify", "   Starting inotify watcher for %d paths", len(w.config.Watchers.Inotify.Paths))
 		w.wg.Add(1)
 		go w.runInotifyWatcher()
 	}
 
 	// Start journald watcher
 	if w.config.Watchers.Journald.Enabled {
-		log.Printf("   Starting journald watcher for %d units", len(w.config.Watchers.Journald.Units))
+		logf(slog.LevelInfo, "journald", "   Starting journald watcher for %d units", len(w.config.Watchers.Journald.Units))
 		w.wg.Add(1)
 		go w.runJournaldWatcher()
 	}
 
 	// Start auditd watcher
 	if w.config.Watchers.Auditd.Enabled {
-		log.Printf("   Starting auditd watcher for %d commands", len(w.config.Watchers.Auditd.Commands))
+		logf(slog.LevelInfo, "auditd", "   Starting auditd watcher for %d commands", len(w.config.Watchers.Auditd.Commands))
 		w.wg.Add(1)
 		go w.runAuditdWatcher()
 	}
 
 	// Start dbus watcher
 	if w.config.Watchers.Dbus.Enabled {
-		log.Printf("   Starting dbus watcher")
+		logf(slog.LevelInfo, "dbus", "   Starting dbus watcher")
 		w.wg.Add(1)
 		go w.runDbusWatcher()
 	}
@@ -103,14 +157,116 @@ func (w *EventWatcher) Start(ctx context.Context) error {
 
 // Stop gracefully stops all watchers
 func (w *EventWatcher) Stop() error {
-	log.Println("Stopping event watchers...")
+	logf(slog.LevelInfo, "", "Stopping event watchers...")
 	w.cancel()
 	w.wg.Wait()
 	close(w.eventChan)
-	log.Println("Event watchers stopped")
+	logf(slog.LevelInfo, "", "Event watchers stopped")
 	return nil
 }
 
+// SetState replaces the desired state used for event-triggered reconciliation
+func (w *EventWatcher) SetState(state *config.State) {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	w.state = state
+}
+
+func (w *EventWatcher) currentState() *config.State {
+	w.stateMu.RLock()
+	defer w.stateMu.RUnlock()
+	return w.state
+}
+
+// fail records that the named watcher stopped because of err. Watchers that
+// skip themselves for lack of configuration don't count as failed.
+func (w *EventWatcher) fail(name string, err error) {
+	w.failMu.Lock()
+	defer w.failMu.Unlock()
+	if w.failures == nil {
+		w.failures = make(map[string]error)
+	}
+	w.failures[name] = err
+
+	s := w.statLocked(watcherName(name))
+	s.Running = false
+	s.Reason = err.Error()
+	s.LastError = err.Error()
+}
+
+// Health returns an error naming every watcher that has stopped unexpectedly
+func (w *EventWatcher) Health() error {
+	w.failMu.Lock()
+	defer w.failMu.Unlock()
+	if len(w.failures) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(w.failures))
+	for name := range w.failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", name, w.failures[name]))
+	}
+	return fmt.Errorf("watchers stopped: %s", strings.Join(msgs, "; "))
+}
+
+// compilePatterns compiles each pattern, using defaults when none are given
+func compilePatterns(patterns, defaults []string) ([]*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		patterns = defaults
+	}
+
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// matchesAny reports whether s matches at least one pattern
+func matchesAny(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// emit queues an event for processing without ever blocking the producer.
+// If the channel is full the event is dropped and counted, so a slow
+// reconcile can't stall the journald or audit readers.
+// The full channel is the only rate limit. Events queued while a pass runs
+// are coalesced into one follow-up pass, so a burst the buffer absorbs costs
+// little, and a limiter in front of the channel would only drop events it
+// still had room for.
+func (w *EventWatcher) emit(event Event) {
+	select {
+	case w.eventChan <- event:
+		w.countEvent(event, false)
+	default:
+		w.countEvent(event, true)
+		DroppedEvents.WithLabelValues(event.Source).Inc()
+		if n := w.dropped.Add(1); n == 1 || n%100 == 0 {
+			logf(slog.LevelWarn, event.Source, "⚠️  Event channel full, dropped %d events so far (latest: %s from %s)", n, event.Type, event.Source)
+		}
+	}
+}
+
+// Dropped returns how many events this watcher has dropped
+func (w *EventWatcher) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
 // processEvents handles incoming events from all watchers
 func (w *EventWatcher) processEvents() {
 	defer w.wg.Done()
@@ -126,34 +282,52 @@ func (w *EventWatcher) processEvents() {
 }
 
 func (w *EventWatcher) handleEvent(event Event) {
-	log.Printf("📨 Event: %s from %s at %s", event.Type, event.Source, event.Timestamp.Format(time.RFC3339))
+	w.mapResources(&event)
+	if w.observer != nil {
+		w.observer(event)
+	}
+
+	slog.Default().LogAttrs(w.ctx, slog.LevelInfo,
+		fmt.Sprintf("📨 Event: %s from %s at %s", event.Type, event.Source, event.Timestamp.Format(time.RFC3339)),
+		slog.String("watcher", watcherName(event.Source)),
+		slog.String("event_type", string(event.Type)))
 
 	switch event.Type {
 	case EventFileModified:
-		log.Printf("   File modified: %s", event.Path)
-		// Trigger reconciliation for file changes
+		logf(slog.LevelDebug, event.Source, "   File modified: %s", event.Path)
+		// Trigger reconciliation for file changes, scoped to the mapped
+		// resources if the path has any
+		target := event.Path
+		if resources := event.Data["resources"]; resources != "" {
+			logf(slog.LevelDebug, event.Source, "   Mapped to %s", resources)
+			target = resources
+		}
 		if w.reconciler != nil {
-			if err := w.reconciler.ReconcileEvent(w.ctx, string(event.Type), event.Path, w.state); err != nil {
-				log.Printf("   Reconciliation triggered by file change failed: %v", err)
+			if _, err := w.reconciler.ReconcileEvent(w.ctx, string(event.Type), target, w.currentState()); err != nil {
+				logf(slog.LevelError, event.Source, "   Reconciliation triggered by file change failed: %v", err)
 			}
 		}
 	case EventServiceLog:
-		log.Printf("   Service log: %s", event.Unit)
+		logf(slog.LevelDebug, event.Source, "   Service log: %s", event.Unit)
 		// Parse log and trigger alerts if needed (future)
 	case EventCommandExecuted:
-		log.Printf("   Command executed: %s", event.Command)
+		if line := event.Data["command_line"]; line != "" {
+			logf(slog.LevelDebug, event.Source, "   Command executed: %s (%s, uid %s)", event.Command, line, event.Data["uid"])
+		} else {
+			logf(slog.LevelDebug, event.Source, "   Command executed: %s", event.Command)
+		}
 		// Trigger reconciliation for commands that might affect state
 		if w.reconciler != nil && w.affectsMonitoredState(event.Command) {
-			if err := w.reconciler.ReconcileEvent(w.ctx, string(event.Type), event.Command, w.state); err != nil {
-				log.Printf("   Reconciliation triggered by command failed: %v", err)
+			if _, err := w.reconciler.ReconcileEvent(w.ctx, string(event.Type), event.Command, w.currentState()); err != nil {
+				logf(slog.LevelError, event.Source, "   Reconciliation triggered by command failed: %v", err)
 			}
 		}
 	case EventUnitStateChange:
-		log.Printf("   Unit state changed: %s", event.Unit)
+		logf(slog.LevelDebug, event.Source, "   Unit state changed: %s", event.Unit)
 		// Trigger immediate reconciliation for unit state changes
 		if w.reconciler != nil {
-			if err := w.reconciler.ReconcileEvent(w.ctx, string(event.Type), event.Unit, w.state); err != nil {
-				log.Printf("   Reconciliation triggered by unit change failed: %v", err)
+			if _, err := w.reconciler.ReconcileEvent(w.ctx, string(event.Type), event.Unit, w.currentState()); err != nil {
+				logf(slog.LevelError, event.Source, "   Reconciliation triggered by unit change failed: %v", err)
 			}
 		}
 	}
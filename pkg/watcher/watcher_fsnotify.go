@@ -0,0 +1,79 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package watcher
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// runInotifyWatcher watches the configured paths through fsnotify, which
+// uses inotify on Linux and kqueue on macOS and the BSDs. kqueue holds a file
+// descriptor per watched file, and a watched directory only reports entries
+// being created or removed, not writes to the files in it.
+func (w *EventWatcher) runInotifyWatcher() {
+	defer w.wg.Done()
+
+	if len(w.config.Watchers.Inotify.Paths) == 0 {
+		logf(slog.LevelInfo, "inotify", "   [inotify] No paths configured, skipping")
+		w.setStopped("inotify", "no paths configured")
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logf(slog.LevelWarn, "inotify", "   [inotify] Failed to create watcher: %v", err)
+		w.fail("inotify", err)
+		return
+	}
+	defer watcher.Close()
+
+	// Add all configured paths
+	for _, path := range w.config.Watchers.Inotify.Paths {
+		if err := watcher.Add(string(path)); err != nil {
+			logf(slog.LevelWarn, "inotify", "   [inotify] Failed to watch %s: %v", path, err)
+		} else {
+			logf(slog.LevelInfo, "inotify", "   [inotify] Watching: %s", path)
+		}
+	}
+
+	logf(slog.LevelInfo, "inotify", "   [inotify] Watcher started")
+	w.setRunning("inotify")
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				w.fail("inotify", errors.New("event stream closed"))
+				return
+			}
+			if w.ignoredPath(event.Name) {
+				continue
+			}
+			// Only trigger on Write and Create events
+			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
+				w.emit(Event{
+					Type:      EventFileModified,
+					Source:    "inotify",
+					Path:      event.Name,
+					Timestamp: time.Now(),
+				})
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				w.fail("inotify", errors.New("error stream closed"))
+				return
+			}
+			logf(slog.LevelWarn, "inotify", "   [inotify] Error: %v", err)
+			w.recordError("inotify", err)
+		case <-w.ctx.Done():
+			logf(slog.LevelInfo, "inotify", "   [inotify] Watcher stopped")
+			w.setStopped("inotify", "stopped")
+			return
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package watcher
+
+import (
+	"log/slog"
+)
+
+func (w *EventWatcher) runInotifyWatcher() {
+	defer w.wg.Done()
+	logf(slog.LevelInfo, "inotify", "   [inotify] Not supported on this platform (Linux, macOS and BSD only)")
+	w.setStopped("inotify", "not supported on this platform")
+}
@@ -0,0 +1,71 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/power-edge/power-edge/pkg/config"
+	"github.com/power-edge/power-edge/pkg/reconciler"
+)
+
+// eventRecorder is a Reconciler that reports the events it is asked to handle
+type eventRecorder chan string
+
+func (r eventRecorder) ReconcileEvent(ctx context.Context, eventType, resourceName string, state *config.State) ([]reconciler.ReconcileResult, error) {
+	r <- eventType + " " + resourceName
+	return nil, nil
+}
+
+func TestEventWatcher_FileEvents(t *testing.T) {
+	dir := t.TempDir()
+	// macOS hands out temp dirs through the /var -> /private/var symlink
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.WatcherConfig{}
+	cfg.Watchers.Enabled = true
+	cfg.Watchers.Inotify.Enabled = true
+	cfg.Watchers.Inotify.Paths = []config.UnixPath{config.UnixPath(dir)}
+
+	events := make(eventRecorder, 16)
+	w := NewEventWatcher(cfg, events, &config.State{})
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer w.Stop()
+
+	// The watch is in place once the watcher reports itself running
+	deadline := time.Now().Add(5 * time.Second)
+	for !w.Stats()["inotify"].Running {
+		if time.Now().After(deadline) {
+			t.Fatal("inotify watcher did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	path := filepath.Join(dir, "managed.conf")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := string(EventFileModified) + " " + path
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got := <-events:
+			if got == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("No %q event within 5s", want)
+		}
+	}
+}
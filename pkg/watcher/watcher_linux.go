@@ -5,112 +5,85 @@ package watcher
 
 import (
 	"bufio"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
-	"strings"
+	"syscall"
 	"time"
 
 	"github.com/coreos/go-systemd/v22/sdjournal"
-	"github.com/fsnotify/fsnotify"
 	"github.com/godbus/dbus/v5"
 )
 
-func (w *EventWatcher) runInotifyWatcher() {
-	defer w.wg.Done()
-
-	if len(w.config.Watchers.Inotify.Paths) == 0 {
-		log.Println("   [inotify] No paths configured, skipping")
-		return
-	}
-
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Printf("   [inotify] Failed to create watcher: %v", err)
-		return
-	}
-	defer watcher.Close()
-
-	// Add all configured paths
-	for _, path := range w.config.Watchers.Inotify.Paths {
-		if err := watcher.Add(string(path)); err != nil {
-			log.Printf("   [inotify] Failed to watch %s: %v", path, err)
-		} else {
-			log.Printf("   [inotify] Watching: %s", path)
-		}
-	}
-
-	log.Println("   [inotify] Watcher started")
-
-	for {
-		select {
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return
-			}
-			// Only trigger on Write and Create events
-			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
-				w.eventChan <- Event{
-					Type:      EventFileModified,
-					Source:    "inotify",
-					Path:      event.Name,
-					Timestamp: time.Now(),
-				}
-			}
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				return
-			}
-			log.Printf("   [inotify] Error: %v", err)
-		case <-w.ctx.Done():
-			log.Println("   [inotify] Watcher stopped")
-			return
-		}
-	}
-}
+// journalErrorLimit is how many journal errors in a row the journald watcher
+// tolerates before reopening the journal, e.g. after journald restarted or
+// the files it had open were rotated or vacuumed away
+const journalErrorLimit = 5
 
 func (w *EventWatcher) runJournaldWatcher() {
 	defer w.wg.Done()
 
 	if len(w.config.Watchers.Journald.Units) == 0 {
-		log.Println("   [journald] No units configured, skipping")
+		logf(slog.LevelInfo, "journald", "   [journald] No units configured, skipping")
+		w.setStopped("journald", "no units configured")
 		return
 	}
 
-	journal, err := sdjournal.NewJournal()
+	journal, err := w.openJournal(true)
 	if err != nil {
-		log.Printf("   [journald] Failed to open journal: %v", err)
+		logf(slog.LevelWarn, "journald", "   [journald] %v", err)
+		w.fail("journald", err)
 		return
 	}
-	defer journal.Close()
-
-	// Add match for each configured unit
-	for _, unit := range w.config.Watchers.Journald.Units {
-		if err := journal.AddMatch("_SYSTEMD_UNIT=" + string(unit) + ".service"); err != nil {
-			log.Printf("   [journald] Failed to add match for %s: %v", unit, err)
-		} else {
-			log.Printf("   [journald] Watching unit: %s", unit)
+	defer func() {
+		if journal != nil {
+			journal.Close()
+		}
+	}()
+
+	logf(slog.LevelInfo, "journald", "   [journald] Watcher started")
+	w.setRunning("journald")
+
+	// failed records err and, once errors keep coming, reopens the journal.
+	// It reports whether the journal was replaced, or closed because the
+	// watcher stopped meanwhile.
+	consecutive := 0
+	failed := func(err error) bool {
+		w.recordError("journald", err)
+		consecutive++
+		if consecutive < journalErrorLimit {
+			return false
 		}
-	}
 
-	// Seek to end to only get new entries
-	if err := journal.SeekTail(); err != nil {
-		log.Printf("   [journald] Failed to seek to tail: %v", err)
-		return
+		logf(slog.LevelWarn, "journald", "   [journald] %d errors in a row, reopening the journal", consecutive)
+		consecutive = 0
+		journal.Close()
+		journal = nil
+		w.reconnect("journald", func() error {
+			reopened, err := w.openJournal(false)
+			if err != nil {
+				return err
+			}
+			journal = reopened
+			return nil
+		})
+		return true
 	}
 
-	log.Println("   [journald] Watcher started")
-
 	for {
 		select {
 		case <-w.ctx.Done():
-			log.Println("   [journald] Watcher stopped")
+			logf(slog.LevelInfo, "journald", "   [journald] Watcher stopped")
+			w.setStopped("journald", "stopped")
 			return
 		default:
 			// Wait for new entries
 			r := journal.Wait(1 * time.Second)
 			if r < 0 {
-				log.Printf("   [journald] Error waiting for entries")
+				err := fmt.Errorf("failed to wait for entries: %w", syscall.Errno(-r))
+				logf(slog.LevelWarn, "journald", "   [journald] Error waiting for entries: %v", err)
+				failed(err)
 				continue
 			}
 
@@ -118,16 +91,21 @@ func (w *EventWatcher) runJournaldWatcher() {
 			for {
 				n, err := journal.Next()
 				if err != nil {
-					log.Printf("   [journald] Error reading entry: %v", err)
+					logf(slog.LevelWarn, "journald", "   [journald] Error reading entry: %v", err)
+					failed(err)
 					break
 				}
+				consecutive = 0
 				if n == 0 {
 					break
 				}
 
 				entry, err := journal.GetEntry()
 				if err != nil {
-					log.Printf("   [journald] Error getting entry: %v", err)
+					logf(slog.LevelWarn, "journald", "   [journald] Error getting entry: %v", err)
+					if failed(err) {
+						break
+					}
 					continue
 				}
 
@@ -135,11 +113,8 @@ func (w *EventWatcher) runJournaldWatcher() {
 				unit := entry.Fields["_SYSTEMD_UNIT"]
 				message := entry.Fields["MESSAGE"]
 
-				if strings.Contains(message, "Started") ||
-				   strings.Contains(message, "Stopped") ||
-				   strings.Contains(message, "Failed") ||
-				   strings.Contains(message, "Reloaded") {
-					w.eventChan <- Event{
+				if matchesAny(w.journaldPatterns, message) {
+					w.emit(Event{
 						Type:      EventUnitStateChange,
 						Source:    "journald",
 						Unit:      unit,
@@ -147,81 +122,101 @@ func (w *EventWatcher) runJournaldWatcher() {
 						Data: map[string]string{
 							"message": message,
 						},
-					}
+					})
 				}
 			}
 		}
 	}
 }
 
+// openJournal opens the journal filtered to the configured units and
+// positioned at its tail, so only new entries are read
+func (w *EventWatcher) openJournal(logUnits bool) (*sdjournal.Journal, error) {
+	journal, err := sdjournal.NewJournal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	// Add match for each configured unit
+	for _, unit := range w.config.Watchers.Journald.Units {
+		if err := journal.AddMatch("_SYSTEMD_UNIT=" + string(unit) + ".service"); err != nil {
+			logf(slog.LevelWarn, "journald", "   [journald] Failed to add match for %s: %v", unit, err)
+		} else if logUnits {
+			logf(slog.LevelInfo, "journald", "   [journald] Watching unit: %s", unit)
+		}
+	}
+
+	// Seek to end to only get new entries
+	if err := journal.SeekTail(); err != nil {
+		journal.Close()
+		return nil, fmt.Errorf("failed to seek to tail: %w", err)
+	}
+	return journal, nil
+}
+
 func (w *EventWatcher) runAuditdWatcher() {
 	defer w.wg.Done()
 
 	if len(w.config.Watchers.Auditd.Commands) == 0 {
-		log.Println("   [auditd] No commands configured, skipping")
+		logf(slog.LevelInfo, "auditd", "   [auditd] No commands configured, skipping")
+		w.setStopped("auditd", "no commands configured")
 		return
 	}
 
 	// Check if auditd is available
 	auditLogPath := "/var/log/audit/audit.log"
 	if _, err := os.Stat(auditLogPath); os.IsNotExist(err) {
-		log.Printf("   [auditd] Audit log not found at %s, using journald for command execution", auditLogPath)
+		logf(slog.LevelInfo, "auditd", "   [auditd] Audit log not found at %s, using journald for command execution", auditLogPath)
 		// Fall back to monitoring via journald for command executions
 		w.runAuditdViaJournald()
 		return
 	}
 
-	log.Printf("   [auditd] Monitoring commands: %v", w.config.Watchers.Auditd.Commands)
-	log.Println("   [auditd] Watcher started (using audit log)")
+	logf(slog.LevelInfo, "auditd", "   [auditd] Monitoring commands: %v", w.config.Watchers.Auditd.Commands)
 
 	file, err := os.Open(auditLogPath)
 	if err != nil {
-		log.Printf("   [auditd] Failed to open audit log: %v", err)
+		logf(slog.LevelWarn, "auditd", "   [auditd] Failed to open audit log: %v", err)
+		w.fail("auditd", err)
 		return
 	}
 	defer file.Close()
 
+	logf(slog.LevelInfo, "auditd", "   [auditd] Watcher started (using audit log)")
+	w.setRunning("auditd")
+
 	// Seek to end
 	file.Seek(0, io.SeekEnd)
 
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
+	assembler := newAuditAssembler()
 	for {
 		select {
 		case <-ticker.C:
 			// Read new lines
 			scanner := bufio.NewScanner(file)
 			for scanner.Scan() {
-				line := scanner.Text()
-				// Check if line contains any of our monitored commands
-				for _, cmd := range w.config.Watchers.Auditd.Commands {
-					if strings.Contains(line, string(cmd)) && strings.Contains(line, "EXECVE") {
-						w.eventChan <- Event{
-							Type:      EventCommandExecuted,
-							Source:    "auditd",
-							Command:   string(cmd),
-							Timestamp: time.Now(),
-							Data: map[string]string{
-								"audit_line": line,
-							},
-						}
-					}
+				if exec, ok := assembler.feedLine(scanner.Text()); ok {
+					w.emitExec("auditd", exec, time.Now())
 				}
 			}
 		case <-w.ctx.Done():
-			log.Println("   [auditd] Watcher stopped")
+			logf(slog.LevelInfo, "auditd", "   [auditd] Watcher stopped")
+			w.setStopped("auditd", "stopped")
 			return
 		}
 	}
 }
 
 func (w *EventWatcher) runAuditdViaJournald() {
-	log.Println("   [auditd-fallback] Using journald to monitor command executions")
+	logf(slog.LevelInfo, "auditd-fallback", "   [auditd-fallback] Using journald to monitor command executions")
 
 	journal, err := sdjournal.NewJournal()
 	if err != nil {
-		log.Printf("   [auditd-fallback] Failed to open journal: %v", err)
+		logf(slog.LevelWarn, "auditd-fallback", "   [auditd-fallback] Failed to open journal: %v", err)
+		w.fail("auditd-fallback", err)
 		return
 	}
 	defer journal.Close()
@@ -230,16 +225,20 @@ func (w *EventWatcher) runAuditdViaJournald() {
 	journal.AddMatch("_TRANSPORT=audit")
 
 	if err := journal.SeekTail(); err != nil {
-		log.Printf("   [auditd-fallback] Failed to seek to tail: %v", err)
+		logf(slog.LevelWarn, "auditd-fallback", "   [auditd-fallback] Failed to seek to tail: %v", err)
+		w.fail("auditd-fallback", err)
 		return
 	}
 
-	log.Println("   [auditd-fallback] Watcher started")
+	logf(slog.LevelInfo, "auditd-fallback", "   [auditd-fallback] Watcher started")
+	w.setRunning("auditd-fallback")
 
+	assembler := newAuditAssembler()
 	for {
 		select {
 		case <-w.ctx.Done():
-			log.Println("   [auditd-fallback] Watcher stopped")
+			logf(slog.LevelInfo, "auditd-fallback", "   [auditd-fallback] Watcher stopped")
+			w.setStopped("auditd-fallback", "stopped")
 			return
 		default:
 			r := journal.Wait(1 * time.Second)
@@ -258,19 +257,8 @@ func (w *EventWatcher) runAuditdViaJournald() {
 					continue
 				}
 
-				message := entry.Fields["MESSAGE"]
-				for _, cmd := range w.config.Watchers.Auditd.Commands {
-					if strings.Contains(message, string(cmd)) {
-						w.eventChan <- Event{
-							Type:      EventCommandExecuted,
-							Source:    "auditd-fallback",
-							Command:   string(cmd),
-							Timestamp: time.Unix(0, int64(entry.RealtimeTimestamp)*1000),
-							Data: map[string]string{
-								"message": message,
-							},
-						}
-					}
+				if exec, ok := assembler.feedJournal(entry.Fields); ok {
+					w.emitExec("auditd-fallback", exec, time.Unix(0, int64(entry.RealtimeTimestamp)*1000))
 				}
 			}
 		}
@@ -282,7 +270,8 @@ func (w *EventWatcher) runDbusWatcher() {
 
 	conn, err := dbus.ConnectSystemBus()
 	if err != nil {
-		log.Printf("   [dbus] Failed to connect to system bus: %v", err)
+		logf(slog.LevelWarn, "dbus", "   [dbus] Failed to connect to system bus: %v", err)
+		w.fail("dbus", err)
 		return
 	}
 	defer conn.Close()
@@ -292,14 +281,16 @@ func (w *EventWatcher) runDbusWatcher() {
 		dbus.WithMatchObjectPath("/org/freedesktop/systemd1"),
 		dbus.WithMatchInterface("org.freedesktop.systemd1.Manager"),
 	); err != nil {
-		log.Printf("   [dbus] Failed to add match signal: %v", err)
+		logf(slog.LevelWarn, "dbus", "   [dbus] Failed to add match signal: %v", err)
+		w.fail("dbus", err)
 		return
 	}
 
 	signals := make(chan *dbus.Signal, 10)
 	conn.Signal(signals)
 
-	log.Println("   [dbus] Watcher started (monitoring systemd D-Bus signals)")
+	logf(slog.LevelInfo, "dbus", "   [dbus] Watcher started (monitoring systemd D-Bus signals)")
+	w.setRunning("dbus")
 
 	for {
 		select {
@@ -313,8 +304,8 @@ func (w *EventWatcher) runDbusWatcher() {
 			case "org.freedesktop.systemd1.Manager.UnitNew":
 				if len(signal.Body) >= 2 {
 					unitName := signal.Body[0].(string)
-					log.Printf("   [dbus] New unit: %s", unitName)
-					w.eventChan <- Event{
+					logf(slog.LevelDebug, "dbus", "   [dbus] New unit: %s", unitName)
+					w.emit(Event{
 						Type:      EventUnitStateChange,
 						Source:    "dbus",
 						Unit:      unitName,
@@ -322,14 +313,14 @@ func (w *EventWatcher) runDbusWatcher() {
 						Data: map[string]string{
 							"signal": "UnitNew",
 						},
-					}
+					})
 				}
 
 			case "org.freedesktop.systemd1.Manager.UnitRemoved":
 				if len(signal.Body) >= 2 {
 					unitName := signal.Body[0].(string)
-					log.Printf("   [dbus] Unit removed: %s", unitName)
-					w.eventChan <- Event{
+					logf(slog.LevelDebug, "dbus", "   [dbus] Unit removed: %s", unitName)
+					w.emit(Event{
 						Type:      EventUnitStateChange,
 						Source:    "dbus",
 						Unit:      unitName,
@@ -337,7 +328,7 @@ func (w *EventWatcher) runDbusWatcher() {
 						Data: map[string]string{
 							"signal": "UnitRemoved",
 						},
-					}
+					})
 				}
 
 			case "org.freedesktop.systemd1.Manager.JobNew":
@@ -345,7 +336,7 @@ func (w *EventWatcher) runDbusWatcher() {
 				if len(signal.Body) >= 2 {
 					jobID := signal.Body[0].(uint32)
 					unitName := signal.Body[2].(string)
-					log.Printf("   [dbus] Job started for unit: %s (job %d)", unitName, jobID)
+					logf(slog.LevelDebug, "dbus", "   [dbus] Job started for unit: %s (job %d)", unitName, jobID)
 				}
 
 			case "org.freedesktop.systemd1.Manager.JobRemoved":
@@ -353,11 +344,11 @@ func (w *EventWatcher) runDbusWatcher() {
 				if len(signal.Body) >= 4 {
 					unitName := signal.Body[2].(string)
 					result := signal.Body[3].(string)
-					log.Printf("   [dbus] Job completed for unit: %s (result: %s)", unitName, result)
+					logf(slog.LevelDebug, "dbus", "   [dbus] Job completed for unit: %s (result: %s)", unitName, result)
 
 					// Only trigger reconciliation on failed jobs
 					if result != "done" {
-						w.eventChan <- Event{
+						w.emit(Event{
 							Type:      EventUnitStateChange,
 							Source:    "dbus",
 							Unit:      unitName,
@@ -366,13 +357,14 @@ func (w *EventWatcher) runDbusWatcher() {
 								"signal": "JobRemoved",
 								"result": result,
 							},
-						}
+						})
 					}
 				}
 			}
 
 		case <-w.ctx.Done():
-			log.Println("   [dbus] Watcher stopped")
+			logf(slog.LevelInfo, "dbus", "   [dbus] Watcher stopped")
+			w.setStopped("dbus", "stopped")
 			return
 		}
 	}
@@ -4,32 +4,31 @@
 package watcher
 
 import (
-	"log"
+	"log/slog"
 )
 
 // Stub implementations for non-Linux platforms
-// Event watchers are Linux-specific and use systemd, inotify, auditd, and dbus
-
-func (w *EventWatcher) runInotifyWatcher() {
-	defer w.wg.Done()
-	log.Println("   [inotify] Not supported on this platform (Linux-only)")
-}
+// These event watchers are Linux-specific and use systemd, auditd, and dbus;
+// file watching is in watcher_fsnotify.go
 
 func (w *EventWatcher) runJournaldWatcher() {
 	defer w.wg.Done()
-	log.Println("   [journald] Not supported on this platform (Linux-only)")
+	logf(slog.LevelInfo, "journald", "   [journald] Not supported on this platform (Linux-only)")
+	w.setStopped("journald", "not supported on this platform")
 }
 
 func (w *EventWatcher) runAuditdWatcher() {
 	defer w.wg.Done()
-	log.Println("   [auditd] Not supported on this platform (Linux-only)")
+	logf(slog.LevelInfo, "auditd", "   [auditd] Not supported on this platform (Linux-only)")
+	w.setStopped("auditd", "not supported on this platform")
 }
 
 func (w *EventWatcher) runAuditdViaJournald() {
-	log.Println("   [auditd-fallback] Not supported on this platform (Linux-only)")
+	logf(slog.LevelInfo, "auditd-fallback", "   [auditd-fallback] Not supported on this platform (Linux-only)")
 }
 
 func (w *EventWatcher) runDbusWatcher() {
 	defer w.wg.Done()
-	log.Println("   [dbus] Not supported on this platform (Linux-only)")
+	logf(slog.LevelInfo, "dbus", "   [dbus] Not supported on this platform (Linux-only)")
+	w.setStopped("dbus", "not supported on this platform")
 }
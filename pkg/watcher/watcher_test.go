@@ -0,0 +1,56 @@
+package watcher
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/power-edge/power-edge/pkg/config"
+)
+
+// droppedTotal reads DroppedEvents for source
+func droppedTotal(t *testing.T, source string) float64 {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(DroppedEvents); err != nil {
+		t.Fatalf("Failed to register DroppedEvents: %v", err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "source" && label.GetValue() == source {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestEventWatcher_EmitDropsWhenFull(t *testing.T) {
+	cfg := &config.WatcherConfig{}
+	cfg.EventHandler.BufferSize = 2
+	w := NewEventWatcher(cfg, nil, &config.State{})
+
+	const source = "test-emit-drops"
+	before := droppedTotal(t, source)
+
+	// Nothing drains the channel, so emit must drop rather than block
+	for i := 0; i < 5; i++ {
+		w.emit(Event{Type: EventFileModified, Source: source})
+	}
+
+	if got := len(w.eventChan); got != 2 {
+		t.Errorf("queued %d events, want the buffer size 2", got)
+	}
+	if got := w.Dropped(); got != 3 {
+		t.Errorf("Dropped() = %d, want 3", got)
+	}
+	if got := droppedTotal(t, source) - before; got != 3 {
+		t.Errorf("power_edge_watcher_events_dropped_total{source=%q} increased by %v, want 3", source, got)
+	}
+}
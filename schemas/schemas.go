@@ -0,0 +1,10 @@
+// Package schemas bundles the JSON Schemas (in YAML) that describe power-edge
+// configuration, so binaries can serve them without the schema directory
+package schemas
+
+import "embed"
+
+// FS holds every *.schema.yaml file in this directory
+//
+//go:embed *.schema.yaml
+var FS embed.FS